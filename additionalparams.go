@@ -0,0 +1,128 @@
+package goth
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+)
+
+// AdditionalParams holds the parameters some identity providers need on top
+// of the OAuth2 ones to issue usable access tokens, e.g. Auth0's and Okta's
+// audience or Azure AD's resource. Providers supporting them embed it, so
+// that they can be set on the provider:
+//
+//	p := auth0.New(key, secret, callbackURL, domain)
+//	p.AdditionalTokenParams = url.Values{"audience": {"https://api.example.com"}}
+type AdditionalParams struct {
+	// AdditionalAuthParams are added to the authorization URL.
+	AdditionalAuthParams url.Values
+	// AdditionalTokenParams are added to the requests made to the token
+	// endpoint, i.e. to exchange the code and to refresh the token.
+	AdditionalTokenParams url.Values
+
+	clientAuth *ClientAuth
+}
+
+// AdditionalParamsProvider is implemented by the providers embedding
+// AdditionalParams.
+type AdditionalParamsProvider interface {
+	SetAdditionalParams(authParams, tokenParams url.Values)
+}
+
+// SetAdditionalParams replaces the additional authorization URL and token
+// request parameters.
+func (a *AdditionalParams) SetAdditionalParams(authParams, tokenParams url.Values) {
+	a.AdditionalAuthParams = authParams
+	a.AdditionalTokenParams = tokenParams
+}
+
+// AuthCodeOptions returns the options adding AdditionalAuthParams to the
+// authorization URL. Parameters the OAuth2 flow relies on are skipped, as
+// with AuthURLParamOptions.
+func (a *AdditionalParams) AuthCodeOptions() []oauth2.AuthCodeOption {
+	return AuthURLParamOptions(a.AdditionalAuthParams)
+}
+
+// SetClientAuth selects how the client authenticates at the token endpoint,
+// e.g. AuthMethodPrivateKeyJWT, instead of sending the secret in the way
+// oauth2 detects, which makes the providers embedding AdditionalParams
+// implement ClientAuthProvider.
+func (a *AdditionalParams) SetClientAuth(auth ClientAuth) error {
+	if err := auth.Validate(); err != nil {
+		return err
+	}
+	a.clientAuth = &auth
+	return nil
+}
+
+// TokenClient returns a copy of h adding AdditionalTokenParams to the token
+// requests it makes and authenticating them as set with SetClientAuth, or h
+// itself if there is nothing to add.
+func (a *AdditionalParams) TokenClient(h *http.Client) *http.Client {
+	if len(a.AdditionalTokenParams) == 0 && a.clientAuth == nil {
+		return h
+	}
+	c := *h
+	if len(a.AdditionalTokenParams) > 0 {
+		c.Transport = &TokenParamsTransport{Base: c.Transport, Params: a.AdditionalTokenParams}
+	}
+	if a.clientAuth != nil {
+		c.Transport = &ClientAuthTransport{Base: c.Transport, Auth: *a.clientAuth}
+	}
+	return &c
+}
+
+// TokenParamsTransport is an http.RoundTripper adding Params to the form
+// encoded POST requests carrying a grant_type, i.e. the requests made to
+// OAuth2 token endpoints. Parameters already in the request are kept.
+type TokenParamsTransport struct {
+	// Base is the RoundTripper making the requests. http.DefaultTransport is
+	// used if nil.
+	Base   http.RoundTripper
+	Params url.Values
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *TokenParamsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Method != http.MethodPost || req.Body == nil {
+		return base.RoundTrip(req)
+	}
+	if ct, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); ct != "application/x-www-form-urlencoded" {
+		return base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil || form.Get("grant_type") == "" {
+		return base.RoundTrip(withBody(req, body))
+	}
+	for key, values := range t.Params {
+		if _, ok := form[key]; !ok {
+			form[key] = values
+		}
+	}
+	return base.RoundTrip(withBody(req, []byte(form.Encode())))
+}
+
+// withBody returns a copy of req sending body.
+func withBody(req *http.Request, body []byte) *http.Request {
+	r := req.Clone(req.Context())
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	r.ContentLength = int64(len(body))
+	return r
+}
@@ -0,0 +1,47 @@
+package goth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AdditionalParams_TokenClient(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var forms []url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		forms = append(forms, r.PostForm)
+	}))
+	defer ts.Close()
+
+	params := &goth.AdditionalParams{}
+	a.Equal(http.DefaultClient, params.TokenClient(http.DefaultClient))
+
+	params.SetAdditionalParams(nil, url.Values{"resource": {"https://graph.example.com"}, "audience": {"api"}})
+	client := params.TokenClient(&http.Client{})
+
+	_, err := client.PostForm(ts.URL, url.Values{"grant_type": {"refresh_token"}, "audience": {"other"}})
+	a.NoError(err)
+	_, err = client.PostForm(ts.URL, url.Values{"token": {"revoked"}})
+	a.NoError(err)
+
+	a.Len(forms, 2)
+	a.Equal("https://graph.example.com", forms[0].Get("resource"))
+	a.Equal("other", forms[0].Get("audience"))
+	a.Equal(url.Values{"token": {"revoked"}}, forms[1])
+}
+
+func Test_AdditionalParams_AuthCodeOptions(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	params := &goth.AdditionalParams{AdditionalAuthParams: url.Values{"audience": {"api"}, "state": {"forged"}}}
+	a.Len(params.AuthCodeOptions(), 1)
+}
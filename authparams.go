@@ -0,0 +1,67 @@
+package goth
+
+import (
+	"errors"
+	"net/url"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrAuthURLParamsNotSupported is returned by BeginAuthWithOptions for
+// providers that don't implement AuthURLParamsProvider.
+var ErrAuthURLParamsNotSupported = errors.New("the provider does not support custom auth URL parameters")
+
+// AuthURLParamsProvider is implemented by providers accepting additional
+// parameters on the authorization URL at request time, e.g. Google's
+// login_hint and prompt, Auth0's connection or Entra ID's domain_hint.
+type AuthURLParamsProvider interface {
+	BeginAuthWithParams(state string, params url.Values) (Session, error)
+}
+
+// ForwardedParamsProvider is implemented by AuthURLParamsProviders reading
+// parameters of their own from the request starting the authentication
+// process, e.g. the shop of Shopify. gothic forwards them to these providers
+// only, along with the parameters every provider gets.
+type ForwardedParamsProvider interface {
+	ForwardedAuthURLParams() []string
+}
+
+// BeginAuthWithOptions starts the authentication process like
+// provider.BeginAuth, adding params to the authorization URL. It returns
+// ErrAuthURLParamsNotSupported if params are given and the provider doesn't
+// implement AuthURLParamsProvider.
+func BeginAuthWithOptions(provider Provider, state string, params url.Values) (Session, error) {
+	if len(params) == 0 {
+		return provider.BeginAuth(state)
+	}
+	p, ok := provider.(AuthURLParamsProvider)
+	if !ok {
+		return nil, ErrAuthURLParamsNotSupported
+	}
+	return p.BeginAuthWithParams(state, params)
+}
+
+// reservedAuthURLParams are set by the OAuth2 flow itself and can't be
+// overridden through AuthURLParamOptions.
+var reservedAuthURLParams = map[string]bool{
+	"client_id":             true,
+	"redirect_uri":          true,
+	"response_type":         true,
+	"state":                 true,
+	"code_challenge":        true,
+	"code_challenge_method": true,
+}
+
+// AuthURLParamOptions converts params to options for oauth2.Config.AuthCodeURL,
+// for use by providers implementing AuthURLParamsProvider. Parameters the
+// OAuth2 flow relies on, like state or redirect_uri, are skipped.
+func AuthURLParamOptions(params url.Values) []oauth2.AuthCodeOption {
+	var opts []oauth2.AuthCodeOption
+	for key := range params {
+		if reservedAuthURLParams[key] {
+			continue
+		}
+		opts = append(opts, oauth2.SetAuthURLParam(key, params.Get(key)))
+	}
+	return opts
+}
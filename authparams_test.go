@@ -0,0 +1,39 @@
+package goth_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BeginAuthWithOptions(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	sess, err := goth.BeginAuthWithOptions(&faux.Provider{}, "state", url.Values{
+		"login_hint": {"homer@example.com"},
+		"state":      {"forged"},
+	})
+	a.NoError(err)
+
+	authURL, err := sess.GetAuthURL()
+	a.NoError(err)
+	u, err := url.Parse(authURL)
+	a.NoError(err)
+	a.Equal("homer@example.com", u.Query().Get("login_hint"))
+	a.Equal("state", u.Query().Get("state"))
+
+	_, err = goth.BeginAuthWithOptions(noParamsProvider{&faux.Provider{}}, "state", url.Values{"prompt": {"login"}})
+	a.ErrorIs(err, goth.ErrAuthURLParamsNotSupported)
+
+	_, err = goth.BeginAuthWithOptions(noParamsProvider{&faux.Provider{}}, "state", nil)
+	a.NoError(err)
+}
+
+// noParamsProvider only exposes the goth.Provider methods of the wrapped provider.
+type noParamsProvider struct {
+	goth.Provider
+}
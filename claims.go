@@ -0,0 +1,244 @@
+package goth
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Standard claim names, see https://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
+const (
+	ClaimSubject             = "sub"
+	ClaimName                = "name"
+	ClaimGivenName           = "given_name"
+	ClaimFamilyName          = "family_name"
+	ClaimNickname            = "nickname"
+	ClaimPreferredUsername   = "preferred_username"
+	ClaimPicture             = "picture"
+	ClaimEmail               = "email"
+	ClaimEmailVerified       = "email_verified"
+	ClaimPhoneNumber         = "phone_number"
+	ClaimPhoneNumberVerified = "phone_number_verified"
+	ClaimLocale              = "locale"
+	ClaimZoneinfo            = "zoneinfo"
+	ClaimGroups              = "groups"
+	ClaimRoles               = "roles"
+)
+
+// ClaimMapping maps standard claim names to the keys under which a provider
+// stores them in User.RawData. Nested keys are separated by dots, e.g.
+// "profile.phone". The first key present in RawData wins.
+type ClaimMapping map[string][]string
+
+var (
+	claimMappingsMu sync.RWMutex
+	claimMappings   = map[string]ClaimMapping{
+		"google": {
+			ClaimEmailVerified: {"verified_email"},
+		},
+		"discord": {
+			ClaimEmailVerified:     {"verified"},
+			ClaimPreferredUsername: {"username"},
+		},
+		"github": {
+			ClaimPreferredUsername: {"login"},
+		},
+		"gitlab": {
+			ClaimPreferredUsername: {"username"},
+		},
+		"microsoftonline": {
+			ClaimPhoneNumber:       {"mobilePhone"},
+			ClaimLocale:            {"preferredLanguage"},
+			ClaimPreferredUsername: {"userPrincipalName"},
+		},
+		"azureadv2": {
+			ClaimPhoneNumber:       {"mobilePhone"},
+			ClaimLocale:            {"preferredLanguage"},
+			ClaimPreferredUsername: {"userPrincipalName"},
+		},
+		"adfs": {
+			ClaimPreferredUsername: {"upn", "unique_name"},
+			ClaimGroups:            {"group", "groupsid"},
+			ClaimRoles:             {"role"},
+		},
+		"okta": {
+			ClaimLocale:            {"Locale"},
+			ClaimZoneinfo:          {"Zoneinfo"},
+			ClaimPreferredUsername: {"Username"},
+		},
+		"cognito": {
+			ClaimPreferredUsername: {"Username"},
+			ClaimGroups:            {"cognito:groups"},
+		},
+	}
+)
+
+// RegisterClaimMapping sets the claim mapping used to normalize the RawData
+// of users authenticated with the named provider. Use it for providers
+// registered under a custom name, or to override the built-in mappings.
+func RegisterClaimMapping(provider string, mapping ClaimMapping) {
+	claimMappingsMu.Lock()
+	defer claimMappingsMu.Unlock()
+	claimMappings[provider] = mapping
+}
+
+func claimMapping(provider string) ClaimMapping {
+	claimMappingsMu.RLock()
+	defer claimMappingsMu.RUnlock()
+	return claimMappings[provider]
+}
+
+// Claims holds the claims of a user normalized to the standard
+// OpenID Connect claim names, regardless of the provider.
+type Claims map[string]interface{}
+
+// Claims returns the normalized claims of the user. Standard claims found in
+// RawData are used as is, provider specific ones are mapped according to the
+// provider's ClaimMapping, and the remaining gaps are filled from the User fields.
+func (u User) Claims() Claims {
+	c := Claims{}
+	for k, v := range u.RawData {
+		c[k] = v
+	}
+
+	for claim, keys := range claimMapping(u.Provider) {
+		if _, ok := c[claim]; ok {
+			continue
+		}
+		for _, key := range keys {
+			if v, ok := lookupRawData(u.RawData, key); ok {
+				c[claim] = v
+				break
+			}
+		}
+	}
+
+	fields := map[string]string{
+		ClaimSubject:    u.UserID,
+		ClaimName:       u.Name,
+		ClaimGivenName:  u.FirstName,
+		ClaimFamilyName: u.LastName,
+		ClaimNickname:   u.NickName,
+		ClaimPicture:    u.AvatarURL,
+		ClaimEmail:      u.Email,
+	}
+	for claim, v := range fields {
+		if _, ok := c[claim]; !ok && v != "" {
+			c[claim] = v
+		}
+	}
+	if _, ok := c[ClaimGroups]; !ok && len(u.Groups) > 0 {
+		c[ClaimGroups] = u.Groups
+	}
+	if _, ok := c[ClaimRoles]; !ok && len(u.Roles) > 0 {
+		c[ClaimRoles] = u.Roles
+	}
+	return c
+}
+
+func lookupRawData(data map[string]interface{}, key string) (interface{}, bool) {
+	parts := strings.Split(key, ".")
+	var current interface{} = data
+	for _, part := range parts {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok || current == nil {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// String returns the named claim as a string, or "" if it isn't set.
+func (c Claims) String(name string) string {
+	switch v := c[name].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ""
+}
+
+// Bool returns the named claim as a boolean. Some providers send booleans as
+// strings, so "true" and "false" are accepted as well.
+func (c Claims) Bool(name string) bool {
+	switch v := c[name].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+// Strings returns the named claim as a list of strings. A single string value
+// is returned as a list with one element.
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// Subject returns the "sub" claim.
+func (c Claims) Subject() string {
+	return c.String(ClaimSubject)
+}
+
+// Email returns the "email" claim.
+func (c Claims) Email() string {
+	return c.String(ClaimEmail)
+}
+
+// EmailVerified returns the "email_verified" claim.
+func (c Claims) EmailVerified() bool {
+	return c.Bool(ClaimEmailVerified)
+}
+
+// PhoneNumber returns the "phone_number" claim.
+func (c Claims) PhoneNumber() string {
+	return c.String(ClaimPhoneNumber)
+}
+
+// PhoneNumberVerified returns the "phone_number_verified" claim.
+func (c Claims) PhoneNumberVerified() bool {
+	return c.Bool(ClaimPhoneNumberVerified)
+}
+
+// Locale returns the "locale" claim.
+func (c Claims) Locale() string {
+	return c.String(ClaimLocale)
+}
+
+// PreferredUsername returns the "preferred_username" claim.
+func (c Claims) PreferredUsername() string {
+	return c.String(ClaimPreferredUsername)
+}
+
+// Groups returns the "groups" claim.
+func (c Claims) Groups() []string {
+	return c.Strings(ClaimGroups)
+}
+
+// Roles returns the "roles" claim.
+func (c Claims) Roles() []string {
+	return c.Strings(ClaimRoles)
+}
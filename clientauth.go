@@ -0,0 +1,324 @@
+package goth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// Methods clients authenticate with at token endpoints, as listed by the
+// token_endpoint_auth_methods_supported of OpenID Connect discovery documents.
+// See https://openid.net/specs/openid-connect-core-1_0.html#ClientAuthentication
+const (
+	// AuthMethodClientSecretBasic sends the client ID and secret in the
+	// Authorization header.
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	// AuthMethodClientSecretPost sends the client ID and secret in the body.
+	AuthMethodClientSecretPost = "client_secret_post"
+	// AuthMethodPrivateKeyJWT sends a JWT signed with the private key of the
+	// client instead of a secret, see ClientAuth.
+	AuthMethodPrivateKeyJWT = "private_key_jwt"
+	// AuthMethodTLSClientAuth authenticates the client with the certificate
+	// its HTTP client presents in the TLS handshake (RFC 8705), so only the
+	// client ID is sent.
+	AuthMethodTLSClientAuth = "tls_client_auth"
+	// AuthMethodNone only sends the client ID, for public clients.
+	AuthMethodNone = "none"
+)
+
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// ClientAuth selects how a provider authenticates the application at the
+// token endpoint, instead of letting oauth2 guess it. Providers supporting it
+// implement ClientAuthProvider:
+//
+//	key, _ := x509.ParsePKCS8PrivateKey(der)
+//	err := p.SetClientAuth(goth.ClientAuth{
+//		Method: goth.AuthMethodPrivateKeyJWT,
+//		Key:    key.(crypto.Signer),
+//		KeyID:  "2024-01",
+//	})
+type ClientAuth struct {
+	// Method is one of the AuthMethod constants.
+	Method string
+	// Key signs the client assertions of AuthMethodPrivateKeyJWT. It must be
+	// an *rsa.PrivateKey, an *ecdsa.PrivateKey or an ed25519.PrivateKey,
+	// whose public key is registered with the provider.
+	Key crypto.Signer
+	// KeyID is the kid of the client assertions, if the provider needs it to
+	// find the public key.
+	KeyID string
+	// Certificate, if set, is the certificate of Key, whose SHA-1 thumbprint
+	// is sent as the x5t of the client assertions, for providers finding the
+	// public key by certificate like Microsoft Entra ID.
+	Certificate *x509.Certificate
+	// Audience is the aud of the client assertions. The URL of the token
+	// endpoint is used if empty, some providers expect their issuer instead.
+	Audience string
+}
+
+// ClientAuthProvider is implemented by the providers letting the method
+// authenticating the application at the token endpoint be selected:
+// openidConnect, which checks the method against its discovery document, and
+// the OAuth2 providers embedding AdditionalParams, e.g. okta or auth0.
+type ClientAuthProvider interface {
+	SetClientAuth(auth ClientAuth) error
+}
+
+// Validate checks that the method is known and that a usable Key is set for
+// AuthMethodPrivateKeyJWT.
+func (a ClientAuth) Validate() error {
+	switch a.Method {
+	case AuthMethodClientSecretBasic, AuthMethodClientSecretPost, AuthMethodTLSClientAuth, AuthMethodNone:
+		return nil
+	case AuthMethodPrivateKeyJWT:
+		_, err := a.signingMethod()
+		return err
+	}
+	return fmt.Errorf("unknown token endpoint auth method %q", a.Method)
+}
+
+// Configure sets the AuthStyle of c for the method, and removes the client
+// secret from it when the method doesn't send it.
+func (a ClientAuth) Configure(c *oauth2.Config) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	switch a.Method {
+	case AuthMethodClientSecretBasic:
+		c.Endpoint.AuthStyle = oauth2.AuthStyleInHeader
+	case AuthMethodClientSecretPost:
+		c.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+	default:
+		// oauth2 only sends the client_id in the body without a secret
+		c.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+		c.ClientSecret = ""
+	}
+	return nil
+}
+
+// Client returns a copy of h adding a client assertion to the token requests
+// it makes to tokenURL with AuthMethodPrivateKeyJWT, or h itself with the
+// other methods.
+func (a ClientAuth) Client(h *http.Client, clientID, tokenURL string) *http.Client {
+	if a.Method != AuthMethodPrivateKeyJWT {
+		return h
+	}
+	c := *h
+	c.Transport = &ClientAssertionTransport{Base: h.Transport, Auth: a, ClientID: clientID, TokenURL: tokenURL}
+	return &c
+}
+
+// ClientAssertion returns a client assertion of clientID for audience, a JWT
+// signed with Key valid for a minute.
+// See https://www.rfc-editor.org/rfc/rfc7523#section-3
+func (a ClientAuth) ClientAssertion(clientID, audience string) (string, error) {
+	method, err := a.signingMethod()
+	if err != nil {
+		return "", err
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	now := Now()
+	token := jwt.NewWithClaims(method, jwt.RegisteredClaims{
+		Issuer:    clientID,
+		Subject:   clientID,
+		Audience:  jwt.ClaimStrings{audience},
+		ID:        hex.EncodeToString(jti),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	})
+	if a.KeyID != "" {
+		token.Header["kid"] = a.KeyID
+	}
+	if a.Certificate != nil {
+		thumbprint := sha1.Sum(a.Certificate.Raw)
+		token.Header["x5t"] = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+	}
+	return token.SignedString(a.Key)
+}
+
+func (a ClientAuth) signingMethod() (jwt.SigningMethod, error) {
+	switch key := a.Key.(type) {
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PrivateKey:
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return jwt.SigningMethodES256, nil
+		case 384:
+			return jwt.SigningMethodES384, nil
+		case 521:
+			return jwt.SigningMethodES512, nil
+		}
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA, nil
+	case nil:
+		return nil, errors.New("private_key_jwt requires a private key")
+	}
+	return nil, fmt.Errorf("private_key_jwt doesn't support %T keys", a.Key)
+}
+
+// ClientAssertionTransport is an http.RoundTripper adding a fresh client
+// assertion to the form encoded POST requests made to TokenURL, for the
+// AuthMethodPrivateKeyJWT method. Any client secret in them is removed.
+type ClientAssertionTransport struct {
+	// Base is the RoundTripper making the requests. http.DefaultTransport is
+	// used if nil.
+	Base     http.RoundTripper
+	Auth     ClientAuth
+	ClientID string
+	TokenURL string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *ClientAssertionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Method != http.MethodPost || req.Body == nil || !sameEndpoint(req.URL, t.TokenURL) {
+		return base.RoundTrip(req)
+	}
+	if ct, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); ct != "application/x-www-form-urlencoded" {
+		return base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return base.RoundTrip(withBody(req, body))
+	}
+
+	audience := t.Auth.Audience
+	if audience == "" {
+		audience = t.TokenURL
+	}
+	assertion, err := t.Auth.ClientAssertion(t.ClientID, audience)
+	if err != nil {
+		return nil, err
+	}
+	form.Del("client_secret")
+	form.Set("client_id", t.ClientID)
+	form.Set("client_assertion_type", clientAssertionType)
+	form.Set("client_assertion", assertion)
+
+	r := withBody(req, []byte(form.Encode()))
+	r.Header.Del("Authorization")
+	return base.RoundTrip(r)
+}
+
+func sameEndpoint(u *url.URL, endpoint string) bool {
+	e, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == e.Scheme && u.Host == e.Host && u.Path == e.Path
+}
+
+// ClientAuthTransport is an http.RoundTripper authenticating the client of
+// the form encoded POST requests carrying a grant_type, i.e. the requests made
+// to OAuth2 token endpoints, with Auth. The client ID and secret are taken
+// from the request, whether oauth2 sent them in the Authorization header or
+// in the body, so it works without access to the oauth2.Config of the
+// provider. Providers embedding AdditionalParams use it, see
+// AdditionalParams.SetClientAuth.
+type ClientAuthTransport struct {
+	// Base is the RoundTripper making the requests. http.DefaultTransport is
+	// used if nil.
+	Base http.RoundTripper
+	Auth ClientAuth
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *ClientAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Method != http.MethodPost || req.Body == nil {
+		return base.RoundTrip(req)
+	}
+	if ct, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); ct != "application/x-www-form-urlencoded" {
+		return base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil || form.Get("grant_type") == "" {
+		return base.RoundTrip(withBody(req, body))
+	}
+
+	clientID, secret := form.Get("client_id"), form.Get("client_secret")
+	if id, pass, ok := req.BasicAuth(); ok {
+		// oauth2 escapes the credentials it puts in the header
+		clientID, secret = unescapeCredential(id), unescapeCredential(pass)
+	}
+	form.Del("client_id")
+	form.Del("client_secret")
+	form.Set("client_id", clientID)
+
+	basic := false
+	switch t.Auth.Method {
+	case AuthMethodClientSecretBasic:
+		form.Del("client_id")
+		basic = true
+	case AuthMethodClientSecretPost:
+		form.Set("client_secret", secret)
+	case AuthMethodPrivateKeyJWT:
+		audience := t.Auth.Audience
+		if audience == "" {
+			u := *req.URL
+			u.RawQuery, u.Fragment = "", ""
+			audience = u.String()
+		}
+		assertion, err := t.Auth.ClientAssertion(clientID, audience)
+		if err != nil {
+			return nil, err
+		}
+		form.Set("client_assertion_type", clientAssertionType)
+		form.Set("client_assertion", assertion)
+	}
+
+	r := withBody(req, []byte(form.Encode()))
+	r.Header.Del("Authorization")
+	if basic {
+		r.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(secret))
+	}
+	return base.RoundTrip(r)
+}
+
+func unescapeCredential(s string) string {
+	if u, err := url.QueryUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
@@ -0,0 +1,167 @@
+package goth_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_ClientAuth_Configure(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	c := &oauth2.Config{ClientID: "id", ClientSecret: "secret"}
+	a.NoError(goth.ClientAuth{Method: goth.AuthMethodClientSecretBasic}.Configure(c))
+	a.Equal(oauth2.AuthStyleInHeader, c.Endpoint.AuthStyle)
+	a.Equal("secret", c.ClientSecret)
+
+	a.NoError(goth.ClientAuth{Method: goth.AuthMethodTLSClientAuth}.Configure(c))
+	a.Equal(oauth2.AuthStyleInParams, c.Endpoint.AuthStyle)
+	a.Equal("", c.ClientSecret)
+
+	a.Error(goth.ClientAuth{Method: "client_secret_jwt"}.Configure(c))
+	a.Error(goth.ClientAuth{Method: goth.AuthMethodPrivateKeyJWT}.Configure(c))
+}
+
+func Test_ClientAuth_PrivateKeyJWT(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a.NoError(err)
+
+	var form url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"access","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer ts.Close()
+
+	auth := goth.ClientAuth{Method: goth.AuthMethodPrivateKeyJWT, Key: key, KeyID: "key-1"}
+	c := &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: ts.URL + "/token"}}
+	a.NoError(auth.Configure(c))
+	client := auth.Client(&http.Client{}, c.ClientID, c.Endpoint.TokenURL)
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+	token, err := c.TokenSource(ctx, &oauth2.Token{RefreshToken: "refresh"}).Token()
+	a.NoError(err)
+	a.Equal("access", token.AccessToken)
+
+	a.Equal("id", form.Get("client_id"))
+	a.Empty(form.Get("client_secret"))
+	a.Equal("urn:ietf:params:oauth:client-assertion-type:jwt-bearer", form.Get("client_assertion_type"))
+
+	claims := jwt.RegisteredClaims{}
+	parsed, err := jwt.ParseWithClaims(form.Get("client_assertion"), &claims, func(token *jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience(ts.URL+"/token"), jwt.WithIssuer("id"), jwt.WithSubject("id"))
+	a.NoError(err)
+	a.Equal("key-1", parsed.Header["kid"])
+	a.NotEmpty(claims.ID)
+
+	// other requests are left alone
+	_, err = client.PostForm(ts.URL+"/revoke", url.Values{"token": {"access"}})
+	a.NoError(err)
+	a.Empty(form.Get("client_assertion"))
+}
+
+func Test_ClientAuth_ClientAssertionRSA(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+
+	auth := goth.ClientAuth{Method: goth.AuthMethodPrivateKeyJWT, Key: key}
+	assertion, err := auth.ClientAssertion("id", "https://idp.example.com")
+	a.NoError(err)
+
+	parsed, err := jwt.Parse(assertion, func(token *jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithAudience("https://idp.example.com"))
+	a.NoError(err)
+	a.NotContains(parsed.Header, "kid")
+	a.NotContains(parsed.Header, "x5t")
+
+	// the thumbprint of the certificate is sent along
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	a.NoError(err)
+	auth.Certificate, err = x509.ParseCertificate(der)
+	a.NoError(err)
+	assertion, err = auth.ClientAssertion("id", "https://idp.example.com")
+	a.NoError(err)
+	parsed, _, err = jwt.NewParser().ParseUnverified(assertion, jwt.MapClaims{})
+	a.NoError(err)
+	thumbprint := sha1.Sum(der)
+	a.Equal(base64.RawURLEncoding.EncodeToString(thumbprint[:]), parsed.Header["x5t"])
+}
+
+func Test_AdditionalParams_SetClientAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var req *http.Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		req = r
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"access","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer ts.Close()
+
+	refresh := func(p *goth.AdditionalParams) {
+		// the secret is sent in the header, whatever the method selected
+		c := &oauth2.Config{ClientID: "id", ClientSecret: "s&cret", Endpoint: oauth2.Endpoint{TokenURL: ts.URL + "/token?tenant=1", AuthStyle: oauth2.AuthStyleInHeader}}
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, p.TokenClient(&http.Client{}))
+		_, err := c.TokenSource(ctx, &oauth2.Token{RefreshToken: "refresh"}).Token()
+		a.NoError(err)
+	}
+
+	p := &goth.AdditionalParams{AdditionalTokenParams: url.Values{"resource": {"api"}}}
+	a.Implements((*goth.ClientAuthProvider)(nil), p)
+	a.Error(p.SetClientAuth(goth.ClientAuth{Method: goth.AuthMethodPrivateKeyJWT}))
+
+	a.NoError(p.SetClientAuth(goth.ClientAuth{Method: goth.AuthMethodClientSecretPost}))
+	refresh(p)
+	_, _, basic := req.BasicAuth()
+	a.False(basic)
+	a.Equal("id", req.PostForm.Get("client_id"))
+	a.Equal("s&cret", req.PostForm.Get("client_secret"))
+	a.Equal("api", req.PostForm.Get("resource"))
+
+	a.NoError(p.SetClientAuth(goth.ClientAuth{Method: goth.AuthMethodNone}))
+	refresh(p)
+	a.Equal("id", req.PostForm.Get("client_id"))
+	a.Empty(req.PostForm.Get("client_secret"))
+	a.Empty(req.Header.Get("Authorization"))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	a.NoError(p.SetClientAuth(goth.ClientAuth{Method: goth.AuthMethodPrivateKeyJWT, Key: key}))
+	refresh(p)
+	a.Empty(req.PostForm.Get("client_secret"))
+	a.Empty(req.Header.Get("Authorization"))
+	_, err = jwt.Parse(req.PostForm.Get("client_assertion"), func(token *jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	}, jwt.WithAudience(ts.URL+"/token"), jwt.WithSubject("id"))
+	a.NoError(err)
+}
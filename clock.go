@@ -0,0 +1,37 @@
+package goth
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	clockMu sync.RWMutex
+	clock   = time.Now
+)
+
+// SetClock replaces the time source Goth, its providers and gothic use to
+// compute the expiry of tokens and to validate the time based claims of ID
+// tokens, so that tests can check expiry logic deterministically:
+//
+//	goth.SetClock(func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) })
+//	defer goth.SetClock(nil)
+//
+// Passing nil restores time.Now. Expiries computed by golang.org/x/oauth2
+// itself, e.g. the Expiry of the tokens returned by a code exchange, aren't
+// affected.
+func SetClock(now func() time.Time) {
+	clockMu.Lock()
+	defer clockMu.Unlock()
+	if now == nil {
+		now = time.Now
+	}
+	clock = now
+}
+
+// Now returns the current time according to the clock set with SetClock.
+func Now() time.Time {
+	clockMu.RLock()
+	defer clockMu.RUnlock()
+	return clock()
+}
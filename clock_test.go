@@ -0,0 +1,20 @@
+package goth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SetClock(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	goth.SetClock(func() time.Time { return now })
+	a.Equal(now, goth.Now())
+
+	goth.SetClock(nil)
+	a.WithinDuration(time.Now(), goth.Now(), time.Minute)
+}
@@ -0,0 +1,65 @@
+/*
+Command gothdoctor checks the configuration of the providers declared in a
+config file, or in environment variables, and prints what is wrong with it.
+
+Usage:
+
+	gothdoctor [-offline] [-v] providers.yaml
+	gothdoctor [-offline] [-v] -env GOTH
+
+It exits with the status 1 if some provider cannot work as configured. See the
+config package for the format of the file and the variables.
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/config"
+	"github.com/markbates/goth/doctor"
+)
+
+func main() {
+	env := flag.String("env", "", "load the providers from the environment variables with this prefix")
+	offline := flag.Bool("offline", false, "skip the checks making requests to the providers")
+	verbose := flag.Bool("v", false, "also print the checks which passed")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: gothdoctor [-offline] [-v] <config file> | -env <prefix>")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var providers []goth.Provider
+	var err error
+	switch {
+	case *env != "" && flag.NArg() == 0:
+		providers, err = config.LoadEnv(*env)
+	case *env == "" && flag.NArg() == 1:
+		providers, err = config.Load(flag.Arg(0))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if len(providers) == 0 {
+		fmt.Fprintln(os.Stderr, "no provider is configured")
+		os.Exit(1)
+	}
+
+	diagnostics := doctor.Check(context.Background(), doctor.Options{Offline: *offline}, providers...)
+	for _, d := range diagnostics {
+		if d.Severity != doctor.OK || *verbose {
+			fmt.Println(d)
+		}
+	}
+	if doctor.HasErrors(diagnostics) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,512 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/adfs"
+	"github.com/markbates/goth/providers/amazon"
+	"github.com/markbates/goth/providers/apple"
+	"github.com/markbates/goth/providers/auth0"
+	"github.com/markbates/goth/providers/authelia"
+	"github.com/markbates/goth/providers/authentik"
+	"github.com/markbates/goth/providers/azuread"
+	"github.com/markbates/goth/providers/basecamp"
+	"github.com/markbates/goth/providers/battlenet"
+	"github.com/markbates/goth/providers/bitbucket"
+	"github.com/markbates/goth/providers/bitly"
+	"github.com/markbates/goth/providers/box"
+	"github.com/markbates/goth/providers/classlink"
+	"github.com/markbates/goth/providers/cloudfoundry"
+	"github.com/markbates/goth/providers/cognito"
+	"github.com/markbates/goth/providers/dailymotion"
+	"github.com/markbates/goth/providers/deezer"
+	"github.com/markbates/goth/providers/digitalocean"
+	"github.com/markbates/goth/providers/discord"
+	"github.com/markbates/goth/providers/dropbox"
+	"github.com/markbates/goth/providers/eveonline"
+	"github.com/markbates/goth/providers/facebook"
+	"github.com/markbates/goth/providers/fitbit"
+	"github.com/markbates/goth/providers/gitea"
+	"github.com/markbates/goth/providers/github"
+	"github.com/markbates/goth/providers/gitlab"
+	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providers/gplus"
+	"github.com/markbates/goth/providers/heroku"
+	"github.com/markbates/goth/providers/hubspot"
+	"github.com/markbates/goth/providers/influxcloud"
+	"github.com/markbates/goth/providers/instagram"
+	"github.com/markbates/goth/providers/intercom"
+	"github.com/markbates/goth/providers/intuit"
+	"github.com/markbates/goth/providers/jellyfin"
+	"github.com/markbates/goth/providers/kakao"
+	"github.com/markbates/goth/providers/lastfm"
+	"github.com/markbates/goth/providers/line"
+	"github.com/markbates/goth/providers/linkedin"
+	"github.com/markbates/goth/providers/linode"
+	"github.com/markbates/goth/providers/mailru"
+	"github.com/markbates/goth/providers/mastodon"
+	"github.com/markbates/goth/providers/meetup"
+	"github.com/markbates/goth/providers/microsoftonline"
+	"github.com/markbates/goth/providers/naver"
+	"github.com/markbates/goth/providers/nextcloud"
+	"github.com/markbates/goth/providers/okta"
+	"github.com/markbates/goth/providers/onedrive"
+	"github.com/markbates/goth/providers/openidConnect"
+	"github.com/markbates/goth/providers/ory"
+	"github.com/markbates/goth/providers/oura"
+	"github.com/markbates/goth/providers/patreon"
+	"github.com/markbates/goth/providers/paypal"
+	"github.com/markbates/goth/providers/plex"
+	"github.com/markbates/goth/providers/salesforce"
+	"github.com/markbates/goth/providers/seatalk"
+	"github.com/markbates/goth/providers/shopify"
+	"github.com/markbates/goth/providers/slack"
+	"github.com/markbates/goth/providers/soundcloud"
+	"github.com/markbates/goth/providers/spotify"
+	"github.com/markbates/goth/providers/steam"
+	"github.com/markbates/goth/providers/strava"
+	"github.com/markbates/goth/providers/stripe"
+	"github.com/markbates/goth/providers/tiktok"
+	"github.com/markbates/goth/providers/tumblr"
+	"github.com/markbates/goth/providers/twitch"
+	"github.com/markbates/goth/providers/twitter"
+	"github.com/markbates/goth/providers/twitterv2"
+	"github.com/markbates/goth/providers/typetalk"
+	"github.com/markbates/goth/providers/uber"
+	"github.com/markbates/goth/providers/vk"
+	"github.com/markbates/goth/providers/wepay"
+	"github.com/markbates/goth/providers/workos"
+	"github.com/markbates/goth/providers/xbox"
+	"github.com/markbates/goth/providers/xero"
+	"github.com/markbates/goth/providers/yahoo"
+	"github.com/markbates/goth/providers/yammer"
+	"github.com/markbates/goth/providers/yandex"
+	"github.com/markbates/goth/providers/zoom"
+)
+
+func init() {
+	for typ, f := range map[string]Factory{
+		"amazon":          standard(amazon.New),
+		"battlenet":       standard(battlenet.New),
+		"bitbucket":       standard(bitbucket.New),
+		"bitly":           standard(bitly.New),
+		"box":             standard(box.New),
+		"classlink":       standard(classlink.New),
+		"dailymotion":     standard(dailymotion.New),
+		"deezer":          standard(deezer.New),
+		"digitalocean":    standard(digitalocean.New),
+		"discord":         standard(discord.New),
+		"dropbox":         standard(dropbox.New),
+		"eveonline":       standard(eveonline.New),
+		"facebook":        standard(facebook.New),
+		"fitbit":          standard(fitbit.New),
+		"google":          standard(google.New),
+		"gplus":           standard(gplus.New),
+		"heroku":          standard(heroku.New),
+		"hubspot":         standard(hubspot.New),
+		"instagram":       standard(instagram.New),
+		"intercom":        standard(intercom.New),
+		"intuit":          standard(intuit.New),
+		"kakao":           standard(kakao.New),
+		"line":            standard(line.New),
+		"linkedin":        standard(linkedin.New),
+		"linode":          standard(linode.New),
+		"mailru":          standard(mailru.New),
+		"meetup":          standard(meetup.New),
+		"microsoftonline": standard(microsoftonline.New),
+		"onedrive":        standard(onedrive.New),
+		"oura":            standard(oura.New),
+		"seatalk":         standard(seatalk.New),
+		"shopify":         standard(shopify.New),
+		"slack":           standard(slack.New),
+		"soundcloud":      standard(soundcloud.New),
+		"spotify":         standard(spotify.New),
+		"strava":          standard(strava.New),
+		"stripe":          standard(stripe.New),
+		"tiktok":          standard(tiktok.New),
+		"twitch":          standard(twitch.New),
+		"typetalk":        standard(typetalk.New),
+		"uber":            standard(uber.New),
+		"vk":              standard(vk.New),
+		"vkid":            standard(vk.NewVKID),
+		"wepay":           standard(wepay.New),
+		"xbox":            standard(xbox.New),
+		"yahoo":           standard(yahoo.New),
+		"yammer":          standard(yammer.New),
+		"yandex":          standard(yandex.New),
+		"zoom":            standard(zoom.New),
+
+		"basecamp":  withoutScopes(basecamp.New),
+		"lastfm":    withoutScopes(lastfm.New),
+		"naver":     withoutScopes(naver.New),
+		"tumblr":    withoutScopes(tumblr.New),
+		"twitter":   withoutScopes(twitter.New),
+		"twitterv2": withoutScopes(twitterv2.New),
+		"workos":    withoutScopes(workos.New),
+		"xero":      withoutScopes(xero.New),
+
+		"adfs":           newADFS,
+		"apple":          newApple,
+		"auth0":          newAuth0,
+		"authelia":       newAuthelia,
+		"authentik":      newAuthentik,
+		"azuread":        newAzureAD,
+		"cloudfoundry":   newCloudFoundry,
+		"cognito":        newCognito,
+		"gitea":          newGitea,
+		"github":         newGitHub,
+		"gitlab":         newGitLab,
+		"influxcloud":    newInfluxCloud,
+		"jellyfin":       newJellyfin,
+		"mastodon":       newMastodon,
+		"nextcloud":      newNextcloud,
+		"okta":           newOkta,
+		"openid-connect": newOpenIDConnect,
+		"ory":            newOry,
+		"patreon":        newPatreon,
+		"paypal":         newPayPal,
+		"paypal-sandbox": newPayPalSandbox,
+		"plex":           newPlex,
+		"salesforce":     newSalesforce,
+		"steam":          newSteam,
+	} {
+		Register(typ, f)
+	}
+}
+
+// standard returns a factory calling the usual constructor of providers.
+func standard[P goth.Provider](newProvider func(clientKey, secret, callbackURL string, scopes ...string) P) Factory {
+	return func(c ProviderConfig) (goth.Provider, error) {
+		if err := c.allowURLs(); err != nil {
+			return nil, err
+		}
+		return newProvider(c.Key, c.Secret, c.Callback, c.Scopes...), nil
+	}
+}
+
+// withoutScopes returns a factory calling the constructor of providers
+// which don't take scopes.
+func withoutScopes[P goth.Provider](newProvider func(clientKey, secret, callbackURL string) P) Factory {
+	return func(c ProviderConfig) (goth.Provider, error) {
+		if err := c.allowURLs(); err != nil {
+			return nil, err
+		}
+		return newProvider(c.Key, c.Secret, c.Callback), nil
+	}
+}
+
+// allowURLs returns an error if the URLs hold any key but the given ones,
+// which the factory would otherwise ignore.
+func (c ProviderConfig) allowURLs(keys ...string) error {
+	var unknown []string
+	for k := range c.URLs {
+		known := false
+		for _, key := range keys {
+			known = known || k == key
+		}
+		if !known {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("unknown URLs: %s", strings.Join(unknown, ", "))
+}
+
+// customised reports whether any of the given URLs is set, in which case the
+// NewCustomisedURL constructor of the provider is used. All of them are then
+// required, as the endpoints left out would be empty rather than the default
+// ones, and no other URL may be set.
+func (c ProviderConfig) customised(keys ...string) (bool, error) {
+	return c.customisedWith(keys)
+}
+
+// customisedWith is customised for constructors taking optional URLs as well.
+func (c ProviderConfig) customisedWith(required []string, optional ...string) (bool, error) {
+	var missing []string
+	for _, k := range required {
+		if c.URL(k) == "" {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == len(required) {
+		return false, nil
+	}
+	if len(missing) > 0 {
+		return true, fmt.Errorf("the %s URLs are required along with the %s ones", strings.Join(missing, ", "), strings.Join(required, ", "))
+	}
+	return true, c.allowURLs(append(required, optional...)...)
+}
+
+// requireURL returns an error if the URL stored under key is not set.
+func (c ProviderConfig) requireURL(key string) error {
+	if c.URL(key) == "" {
+		return errors.New("the " + key + " URL is required")
+	}
+	return nil
+}
+
+// requireOnlyURL returns an error if the URL stored under key is not set, or
+// if any other is.
+func (c ProviderConfig) requireOnlyURL(key string) error {
+	if err := c.requireURL(key); err != nil {
+		return err
+	}
+	return c.allowURLs(key)
+}
+
+// newADFS creates an AD FS provider, whose resource parameter can be set in
+// both the auth_params and token_params.
+func newADFS(c ProviderConfig) (goth.Provider, error) {
+	if err := c.requireOnlyURL("server_url"); err != nil {
+		return nil, err
+	}
+	return adfs.New(c.Key, c.Secret, c.Callback, c.URL("server_url"), adfs.Options{Scopes: c.Scopes}), nil
+}
+
+func newApple(c ProviderConfig) (goth.Provider, error) {
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return apple.New(c.Key, c.Secret, c.Callback, nil, c.Scopes...), nil
+}
+
+func newAuth0(c ProviderConfig) (goth.Provider, error) {
+	if err := c.requireOnlyURL("domain"); err != nil {
+		return nil, err
+	}
+	return auth0.New(c.Key, c.Secret, c.Callback, c.URL("domain"), c.Scopes...), nil
+}
+
+func newAuthelia(c ProviderConfig) (goth.Provider, error) {
+	if err := c.requireOnlyURL("base_url"); err != nil {
+		return nil, err
+	}
+	return authelia.New(c.Key, c.Secret, c.Callback, c.URL("base_url"), c.Scopes...)
+}
+
+// newAuthentik creates an authentik provider for the application whose slug
+// is stored under "application_slug" in the URLs.
+func newAuthentik(c ProviderConfig) (goth.Provider, error) {
+	if err := c.requireURL("base_url"); err != nil {
+		return nil, err
+	}
+	if c.URL("application_slug") == "" {
+		return nil, errors.New("the application_slug is required")
+	}
+	if err := c.allowURLs("base_url", "application_slug"); err != nil {
+		return nil, err
+	}
+	return authentik.New(c.Key, c.Secret, c.Callback, c.URL("base_url"), c.URL("application_slug"), c.Scopes...)
+}
+
+func newAzureAD(c ProviderConfig) (goth.Provider, error) {
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return azuread.New(c.Key, c.Secret, c.Callback, nil, c.Scopes...), nil
+}
+
+func newCloudFoundry(c ProviderConfig) (goth.Provider, error) {
+	if err := c.requireOnlyURL("uaa_url"); err != nil {
+		return nil, err
+	}
+	return cloudfoundry.New(c.URL("uaa_url"), c.Key, c.Secret, c.Callback, c.Scopes...), nil
+}
+
+func newCognito(c ProviderConfig) (goth.Provider, error) {
+	if custom, err := c.customised("auth_url", "token_url", "issuer_url", "profile_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return cognito.NewCustomisedURL(c.Key, c.Secret, c.Callback, c.URL("auth_url"), c.URL("token_url"), c.URL("issuer_url"), c.URL("profile_url"), c.Scopes...), nil
+	}
+	if err := c.requireOnlyURL("base_url"); err != nil {
+		return nil, err
+	}
+	return cognito.New(c.Key, c.Secret, c.URL("base_url"), c.Callback, c.Scopes...), nil
+}
+
+func newGitea(c ProviderConfig) (goth.Provider, error) {
+	if custom, err := c.customised("auth_url", "token_url", "profile_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return gitea.NewCustomisedURL(c.Key, c.Secret, c.Callback, c.URL("auth_url"), c.URL("token_url"), c.URL("profile_url"), c.Scopes...), nil
+	}
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return gitea.New(c.Key, c.Secret, c.Callback, c.Scopes...), nil
+}
+
+func newGitHub(c ProviderConfig) (goth.Provider, error) {
+	if custom, err := c.customised("auth_url", "token_url", "profile_url", "email_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return github.NewCustomisedURL(c.Key, c.Secret, c.Callback, c.URL("auth_url"), c.URL("token_url"), c.URL("profile_url"), c.URL("email_url"), c.Scopes...), nil
+	}
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return github.New(c.Key, c.Secret, c.Callback, c.Scopes...), nil
+}
+
+func newGitLab(c ProviderConfig) (goth.Provider, error) {
+	if custom, err := c.customised("auth_url", "token_url", "profile_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return gitlab.NewCustomisedURL(c.Key, c.Secret, c.Callback, c.URL("auth_url"), c.URL("token_url"), c.URL("profile_url"), c.Scopes...), nil
+	}
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return gitlab.New(c.Key, c.Secret, c.Callback, c.Scopes...), nil
+}
+
+func newInfluxCloud(c ProviderConfig) (goth.Provider, error) {
+	if custom, err := c.customised("auth_url", "token_url", "profile_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return influxcloud.NewCustomisedURL(c.Key, c.Secret, c.Callback, c.URL("auth_url"), c.URL("token_url"), c.URL("profile_url"), c.Scopes...), nil
+	}
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return influxcloud.New(c.Key, c.Secret, c.Callback, c.Scopes...), nil
+}
+
+func newJellyfin(c ProviderConfig) (goth.Provider, error) {
+	if err := c.requireOnlyURL("server_url"); err != nil {
+		return nil, err
+	}
+	return jellyfin.New(c.URL("server_url"), c.Callback), nil
+}
+
+func newMastodon(c ProviderConfig) (goth.Provider, error) {
+	if custom, err := c.customised("instance_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return mastodon.NewCustomisedURL(c.Key, c.Secret, c.Callback, c.URL("instance_url"), c.Scopes...), nil
+	}
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return mastodon.New(c.Key, c.Secret, c.Callback, c.Scopes...), nil
+}
+
+func newNextcloud(c ProviderConfig) (goth.Provider, error) {
+	if custom, err := c.customised("auth_url", "token_url", "profile_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return nextcloud.NewCustomisedURL(c.Key, c.Secret, c.Callback, c.URL("auth_url"), c.URL("token_url"), c.URL("profile_url"), c.Scopes...), nil
+	}
+	if custom, err := c.customised("nextcloud_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return nextcloud.NewCustomisedDNS(c.Key, c.Secret, c.Callback, c.URL("nextcloud_url"), c.Scopes...), nil
+	}
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return nextcloud.New(c.Key, c.Secret, c.Callback, c.Scopes...), nil
+}
+
+func newOkta(c ProviderConfig) (goth.Provider, error) {
+	if custom, err := c.customised("auth_url", "token_url", "issuer_url", "profile_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return okta.NewCustomisedURL(c.Key, c.Secret, c.Callback, c.URL("auth_url"), c.URL("token_url"), c.URL("issuer_url"), c.URL("profile_url"), c.Scopes...), nil
+	}
+	if err := c.requireOnlyURL("org_url"); err != nil {
+		return nil, err
+	}
+	return okta.New(c.Key, c.Secret, c.URL("org_url"), c.Callback, c.Scopes...), nil
+}
+
+func newOpenIDConnect(c ProviderConfig) (goth.Provider, error) {
+	if custom, err := c.customisedWith([]string{"auth_url", "token_url", "issuer_url"}, "userinfo_url", "end_session_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return openidConnect.NewCustomisedURL(c.Key, c.Secret, c.Callback, c.URL("auth_url"), c.URL("token_url"), c.URL("issuer_url"), c.URL("userinfo_url"), c.URL("end_session_url"), c.Scopes...)
+	}
+	if err := c.requireOnlyURL("discovery_url"); err != nil {
+		return nil, err
+	}
+	return openidConnect.New(c.Key, c.Secret, c.Callback, c.URL("discovery_url"), c.Scopes...)
+}
+
+func newOry(c ProviderConfig) (goth.Provider, error) {
+	if err := c.requireOnlyURL("issuer_url"); err != nil {
+		return nil, err
+	}
+	return ory.New(c.Key, c.Secret, c.Callback, c.URL("issuer_url"), c.Scopes...)
+}
+
+func newPatreon(c ProviderConfig) (goth.Provider, error) {
+	if custom, err := c.customised("auth_url", "token_url", "profile_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return patreon.NewCustomisedURL(c.Key, c.Secret, c.Callback, c.URL("auth_url"), c.URL("token_url"), c.URL("profile_url"), c.Scopes...), nil
+	}
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return patreon.New(c.Key, c.Secret, c.Callback, c.Scopes...), nil
+}
+
+func newPayPal(c ProviderConfig) (goth.Provider, error) {
+	if custom, err := c.customised("auth_url", "token_url", "profile_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return paypal.NewCustomisedURL(c.Key, c.Secret, c.Callback, c.URL("auth_url"), c.URL("token_url"), c.URL("profile_url"), c.Scopes...), nil
+	}
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return paypal.New(c.Key, c.Secret, c.Callback, c.Scopes...), nil
+}
+
+func newPayPalSandbox(c ProviderConfig) (goth.Provider, error) {
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return paypal.NewSandbox(c.Key, c.Secret, c.Callback, c.Scopes...), nil
+}
+
+// newPlex creates a Plex provider, whose key is the client identifier of the
+// application.
+func newPlex(c ProviderConfig) (goth.Provider, error) {
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return plex.New(c.Key, c.Callback), nil
+}
+
+func newSalesforce(c ProviderConfig) (goth.Provider, error) {
+	if custom, err := c.customised("auth_url", "token_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return salesforce.NewCustomisedURL(c.Key, c.Secret, c.Callback, c.URL("auth_url"), c.URL("token_url"), c.Scopes...), nil
+	}
+	if custom, err := c.customised("domain_url"); err != nil {
+		return nil, err
+	} else if custom {
+		return salesforce.NewWithDomain(c.Key, c.Secret, c.Callback, c.URL("domain_url"), c.Scopes...), nil
+	}
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return salesforce.New(c.Key, c.Secret, c.Callback, c.Scopes...), nil
+}
+
+func newSteam(c ProviderConfig) (goth.Provider, error) {
+	if err := c.allowURLs(); err != nil {
+		return nil, err
+	}
+	return steam.New(c.Key, c.Callback), nil
+}
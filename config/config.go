@@ -0,0 +1,262 @@
+// Package config creates Goth providers from declarations loaded from YAML
+// or JSON files or from environment variables, instead of calling the
+// constructor of every provider by hand:
+//
+//	goth.UseProviders(config.MustLoad("providers.yaml")...)
+//
+// A file lists the providers under the "providers" key:
+//
+//	providers:
+//	  - name: github
+//	    key: ${GITHUB_KEY}
+//	    secret: ${GITHUB_SECRET}
+//	    callback: http://localhost:3000/auth/github/callback
+//	    scopes: [read:user, user:email]
+//	  - name: github-enterprise
+//	    type: github
+//	    key: ${GHE_KEY}
+//	    secret: ${GHE_SECRET}
+//	    callback: http://localhost:3000/auth/github-enterprise/callback
+//	    urls:
+//	      auth_url: https://github.example.com/login/oauth/authorize
+//	      token_url: https://github.example.com/login/oauth/access_token
+//	      profile_url: https://github.example.com/api/v3/user
+//	      email_url: https://github.example.com/api/v3/user/emails
+//
+// References to environment variables, written ${VAR}, are expanded, so that
+// secrets don't have to be written in the file, and $$ stands for a literal $.
+// Any other $ is kept as written. The secret and the auth and token params are
+// only expanded when the whole value is a reference, as secret: ${GHE_SECRET}
+// above, so that secrets containing $ or ${ are used as they are.
+//
+// The providers are instantiated by the factory registered for their type,
+// which defaults to their name. Factories for the providers of this module
+// taking a key, a secret and a callback URL are registered by default, and
+// Register adds others.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/markbates/goth"
+	"gopkg.in/yaml.v3"
+)
+
+// ProviderConfig declares a provider.
+type ProviderConfig struct {
+	// Name is the name the provider is used with, e.g. in the callback URLs.
+	Name string `json:"name" yaml:"name"`
+	// Type is the name of the factory creating the provider. Name is used if
+	// empty.
+	Type     string   `json:"type,omitempty" yaml:"type,omitempty"`
+	Key      string   `json:"key" yaml:"key"`
+	Secret   string   `json:"secret,omitempty" yaml:"secret,omitempty"`
+	Callback string   `json:"callback" yaml:"callback"`
+	Scopes   []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+	// URLs holds the custom endpoints of the provider, e.g. "auth_url",
+	// "token_url" and "profile_url" for self-hosted instances. The keys
+	// understood depend on the factory, which rejects the others, as well as
+	// incomplete sets of custom endpoints.
+	URLs map[string]string `json:"urls,omitempty" yaml:"urls,omitempty"`
+	// AuthParams and TokenParams are added to the authorization URL and to
+	// the token requests of the providers implementing
+	// goth.AdditionalParamsProvider, e.g. an Auth0 or Okta audience.
+	AuthParams  map[string]string `json:"auth_params,omitempty" yaml:"auth_params,omitempty"`
+	TokenParams map[string]string `json:"token_params,omitempty" yaml:"token_params,omitempty"`
+}
+
+// URL returns the custom URL stored under key, or an empty string.
+func (c ProviderConfig) URL(key string) string {
+	return c.URLs[key]
+}
+
+func (c ProviderConfig) factoryName() string {
+	if c.Type != "" {
+		return c.Type
+	}
+	return c.Name
+}
+
+// File is the content of a configuration file.
+type File struct {
+	Providers []ProviderConfig `json:"providers" yaml:"providers"`
+}
+
+// Factory creates a provider from its declaration.
+type Factory func(c ProviderConfig) (goth.Provider, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{}
+)
+
+// Register makes the factory available for the providers of the given type,
+// replacing any factory previously registered for it.
+func Register(typ string, f Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[typ] = f
+}
+
+// Types returns the sorted names of the registered factories.
+func Types() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	types := make([]string, 0, len(factories))
+	for typ := range factories {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func factory(typ string) (Factory, bool) {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	f, ok := factories[typ]
+	return f, ok
+}
+
+// Build creates the declared providers, named after their declaration.
+func Build(configs ...ProviderConfig) ([]goth.Provider, error) {
+	providers := make([]goth.Provider, 0, len(configs))
+	for _, c := range configs {
+		if c.Name == "" {
+			return nil, fmt.Errorf("config: a %q provider has no name", c.Type)
+		}
+		f, ok := factory(c.factoryName())
+		if !ok {
+			return nil, fmt.Errorf("config: no factory is registered for the %q provider type", c.factoryName())
+		}
+		p, err := f(c)
+		if err != nil {
+			return nil, fmt.Errorf("config: cannot create the %q provider: %w", c.Name, err)
+		}
+		if p.Name() != c.Name {
+			p.SetName(c.Name)
+		}
+		if len(c.AuthParams) > 0 || len(c.TokenParams) > 0 {
+			ap, ok := p.(goth.AdditionalParamsProvider)
+			if !ok {
+				return nil, fmt.Errorf("config: the %q provider doesn't support additional parameters", c.Name)
+			}
+			ap.SetAdditionalParams(values(c.AuthParams), values(c.TokenParams))
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
+
+func values(params map[string]string) url.Values {
+	if len(params) == 0 {
+		return nil
+	}
+	v := url.Values{}
+	for key, value := range params {
+		v.Set(key, value)
+	}
+	return v
+}
+
+// Parse decodes the provider declarations of a configuration file, whose
+// format is either "yaml" or "json", and expands the environment variables
+// they reference.
+func Parse(data []byte, format string) ([]ProviderConfig, error) {
+	var f File
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		if err := yaml.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	case "json":
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("config: unsupported format %q", format)
+	}
+
+	for i := range f.Providers {
+		f.Providers[i].expand()
+	}
+	return f.Providers, nil
+}
+
+var (
+	// envRef matches the ${VAR} references expanded in the values, and the
+	// $$ escaping a literal $.
+	envRef = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	// wholeEnvRef matches the values made of a single ${VAR} reference.
+	wholeEnvRef = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+)
+
+// expandEnv replaces the ${VAR} references of s with the value of the
+// environment variables, and $$ with $.
+func expandEnv(s string) string {
+	return envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		return os.Getenv(ref[2 : len(ref)-1])
+	})
+}
+
+// expandSecret returns the value of the environment variable s refers to if
+// s is a single ${VAR} reference, and s as is otherwise.
+func expandSecret(s string) string {
+	if m := wholeEnvRef.FindStringSubmatch(s); m != nil {
+		return os.Getenv(m[1])
+	}
+	return s
+}
+
+func (c *ProviderConfig) expand() {
+	c.Name = expandEnv(c.Name)
+	c.Type = expandEnv(c.Type)
+	c.Key = expandEnv(c.Key)
+	c.Secret = expandSecret(c.Secret)
+	c.Callback = expandEnv(c.Callback)
+	for i, s := range c.Scopes {
+		c.Scopes[i] = expandEnv(s)
+	}
+	for k, u := range c.URLs {
+		c.URLs[k] = expandEnv(u)
+	}
+	for k, v := range c.AuthParams {
+		c.AuthParams[k] = expandSecret(v)
+	}
+	for k, v := range c.TokenParams {
+		c.TokenParams[k] = expandSecret(v)
+	}
+}
+
+// Load creates the providers declared in the file at path, whose format is
+// guessed from its extension.
+func Load(path string) ([]goth.Provider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	configs, err := Parse(data, strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return nil, err
+	}
+	return Build(configs...)
+}
+
+// MustLoad is like Load but panics if the providers cannot be created.
+func MustLoad(path string) []goth.Provider {
+	providers, err := Load(path)
+	if err != nil {
+		panic(err)
+	}
+	return providers
+}
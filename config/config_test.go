@@ -0,0 +1,210 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/config"
+	"github.com/markbates/goth/providers/auth0"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/markbates/goth/providers/github"
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlConfig = `
+providers:
+  - name: github
+    key: ${CONFIG_TEST_GITHUB_KEY}
+    secret: github-secret
+    callback: http://localhost:3000/auth/github/callback
+    scopes: [read:user, user:email]
+  - name: github-enterprise
+    type: github
+    key: ghe-key
+    secret: ghe-secret
+    callback: http://localhost:3000/auth/github-enterprise/callback
+    urls:
+      auth_url: https://github.example.com/login/oauth/authorize
+      token_url: https://github.example.com/login/oauth/access_token
+      profile_url: https://github.example.com/api/v3/user
+      email_url: https://github.example.com/api/v3/user/emails
+`
+
+func Test_Load(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("CONFIG_TEST_GITHUB_KEY", "github-key")
+
+	path := filepath.Join(t.TempDir(), "providers.yaml")
+	a.NoError(os.WriteFile(path, []byte(yamlConfig), 0o600))
+
+	providers, err := config.Load(path)
+	a.NoError(err)
+	a.Len(providers, 2)
+
+	p := providers[0].(*github.Provider)
+	a.Equal("github", p.Name())
+	a.Equal("github-key", p.ClientKey)
+	a.Equal("github-secret", p.Secret)
+	a.Equal("http://localhost:3000/auth/github/callback", p.CallbackURL)
+
+	ghe := providers[1].(*github.Provider)
+	a.Equal("github-enterprise", ghe.Name())
+	session, err := ghe.BeginAuth("state")
+	a.NoError(err)
+	authURL, err := session.GetAuthURL()
+	a.NoError(err)
+	a.Contains(authURL, "https://github.example.com/login/oauth/authorize")
+}
+
+func Test_Parse_JSON(t *testing.T) {
+	a := assert.New(t)
+
+	configs, err := config.Parse([]byte(`{"providers": [{"name": "okta", "key": "k", "secret": "s", "callback": "/cb", "urls": {"org_url": "https://example.okta.com"}}]}`), "json")
+	a.NoError(err)
+	a.Equal([]config.ProviderConfig{{
+		Name:     "okta",
+		Key:      "k",
+		Secret:   "s",
+		Callback: "/cb",
+		URLs:     map[string]string{"org_url": "https://example.okta.com"},
+	}}, configs)
+
+	_, err = config.Parse([]byte("providers: []"), "toml")
+	a.Error(err)
+}
+
+func Test_ParseExpandsOnlyReferences(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("CONFIG_TEST_KEY", "key")
+	t.Setenv("CONFIG_TEST_SECRET", "secret")
+	t.Setenv("cd", "expanded")
+
+	configs, err := config.Parse([]byte(`
+providers:
+  - name: github
+    key: ${CONFIG_TEST_KEY}
+    secret: ab$cd
+    callback: https://example.com/$$path/${cd}/$cd
+    auth_params:
+      audience: ${CONFIG_TEST_SECRET}
+      prompt: x${cd}
+  - name: gitlab
+    key: key
+    secret: ${CONFIG_TEST_SECRET}
+    callback: /cb
+`), "yaml")
+	a.NoError(err)
+	a.Len(configs, 2)
+
+	a.Equal("key", configs[0].Key)
+	// secrets are used as written unless they are a reference as a whole
+	a.Equal("ab$cd", configs[0].Secret)
+	a.Equal("https://example.com/$path/expanded/$cd", configs[0].Callback)
+	a.Equal(map[string]string{"audience": "secret", "prompt": "x${cd}"}, configs[0].AuthParams)
+	a.Equal("secret", configs[1].Secret)
+}
+
+func Test_Build(t *testing.T) {
+	a := assert.New(t)
+
+	config.Register("config-test", func(c config.ProviderConfig) (goth.Provider, error) {
+		return &faux.Provider{}, nil
+	})
+	providers, err := config.Build(config.ProviderConfig{Name: "custom", Type: "config-test"})
+	a.NoError(err)
+	a.Equal("custom", providers[0].Name())
+	a.Contains(config.Types(), "config-test")
+
+	providers, err = config.Build(config.ProviderConfig{
+		Name:        "auth0",
+		Key:         "k",
+		URLs:        map[string]string{"domain": "example.auth0.com"},
+		TokenParams: map[string]string{"audience": "https://api.example.com"},
+	})
+	a.NoError(err)
+	a.Equal("https://api.example.com", providers[0].(*auth0.Provider).AdditionalTokenParams.Get("audience"))
+
+	_, err = config.Build(config.ProviderConfig{Name: "github", TokenParams: map[string]string{"audience": "api"}})
+	a.Error(err)
+
+	_, err = config.Build(config.ProviderConfig{Name: "unknown"})
+	a.Error(err)
+
+	_, err = config.Build(config.ProviderConfig{Name: "okta", Key: "k"})
+	a.Error(err)
+}
+
+func Test_BuildRejectsMisdeclaredURLs(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// providers taking no URLs
+	_, err := config.Build(config.ProviderConfig{Name: "google", Key: "k", URLs: map[string]string{"auth_url": "https://example.com/auth"}})
+	a.ErrorContains(err, "unknown URLs: auth_url")
+	_, err = config.Build(config.ProviderConfig{Name: "twitter", Key: "k", URLs: map[string]string{"token_url": "https://example.com/token"}})
+	a.ErrorContains(err, "unknown URLs: token_url")
+
+	// misspelled keys
+	_, err = config.Build(config.ProviderConfig{Name: "okta", Key: "k", URLs: map[string]string{"org_url": "https://example.okta.com", "orgurl": "x"}})
+	a.ErrorContains(err, "unknown URLs: orgurl")
+
+	// incomplete sets of custom endpoints would mix in the default ones
+	_, err = config.Build(config.ProviderConfig{Name: "github", Key: "k", URLs: map[string]string{"auth_url": "https://github.example.com/login/oauth/authorize"}})
+	a.ErrorContains(err, "the token_url, profile_url, email_url URLs are required")
+
+	// the optional URLs of OpenID Connect
+	_, err = config.Build(config.ProviderConfig{Name: "openid-connect", Key: "k", URLs: map[string]string{
+		"auth_url":   "https://idp.example.com/auth",
+		"token_url":  "https://idp.example.com/token",
+		"issuer_url": "https://idp.example.com",
+	}})
+	a.NoError(err)
+	_, err = config.Build(config.ProviderConfig{Name: "openid-connect", Key: "k", URLs: map[string]string{
+		"discovery_url":   "https://idp.example.com/.well-known/openid-configuration",
+		"end_session_url": "https://idp.example.com/logout",
+	}})
+	a.ErrorContains(err, "unknown URLs: end_session_url")
+}
+
+func Test_LoadEnv(t *testing.T) {
+	a := assert.New(t)
+	t.Setenv("CONFIGTEST_GITHUB_KEY", "github-key")
+	t.Setenv("CONFIGTEST_GITHUB_SECRET", "github-secret")
+	t.Setenv("CONFIGTEST_GITHUB_CALLBACK", "/auth/github/callback")
+	t.Setenv("CONFIGTEST_GITHUB_SCOPES", "read:user, user:email")
+	t.Setenv("CONFIGTEST_GITHUB_ENTERPRISE_TYPE", "github")
+	t.Setenv("CONFIGTEST_GITHUB_ENTERPRISE_KEY", "ghe-key")
+	t.Setenv("CONFIGTEST_GITHUB_ENTERPRISE_AUTH_URL", "https://github.example.com/login/oauth/authorize")
+	t.Setenv("CONFIGTEST_GITHUB_ENTERPRISE_TOKEN_URL", "https://github.example.com/login/oauth/access_token")
+	t.Setenv("CONFIGTEST_GITHUB_ENTERPRISE_PROFILE_URL", "https://github.example.com/api/v3/user")
+	t.Setenv("CONFIGTEST_GITHUB_ENTERPRISE_EMAIL_URL", "https://github.example.com/api/v3/user/emails")
+
+	configs := config.FromEnv("configtest")
+	a.Equal([]config.ProviderConfig{
+		{
+			Name:     "github",
+			Key:      "github-key",
+			Secret:   "github-secret",
+			Callback: "/auth/github/callback",
+			Scopes:   []string{"read:user", "user:email"},
+		},
+		{
+			Name: "github-enterprise",
+			Type: "github",
+			Key:  "ghe-key",
+			URLs: map[string]string{
+				"auth_url":    "https://github.example.com/login/oauth/authorize",
+				"token_url":   "https://github.example.com/login/oauth/access_token",
+				"profile_url": "https://github.example.com/api/v3/user",
+				"email_url":   "https://github.example.com/api/v3/user/emails",
+			},
+		},
+	}, configs)
+
+	providers, err := config.LoadEnv("configtest")
+	a.NoError(err)
+	a.Len(providers, 2)
+	a.Equal("github-enterprise", providers[1].Name())
+}
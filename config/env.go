@@ -0,0 +1,96 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/markbates/goth"
+)
+
+// FromEnv returns the providers declared by the environment variables
+// starting with prefix followed by an underscore. Each provider has a
+// PREFIX_NAME_KEY variable, and optionally PREFIX_NAME_SECRET,
+// PREFIX_NAME_CALLBACK, PREFIX_NAME_SCOPES (comma or space separated) and
+// PREFIX_NAME_TYPE ones. The other PREFIX_NAME_*_URL variables are the
+// custom URLs, e.g. PREFIX_NAME_AUTH_URL sets the "auth_url" one. NAME is
+// the name of the provider in upper case, with underscores instead of dashes:
+//
+//	GOTH_GITHUB_KEY=...
+//	GOTH_GITHUB_SECRET=...
+//	GOTH_GITHUB_CALLBACK=http://localhost:3000/auth/github/callback
+//	GOTH_OPENID_CONNECT_KEY=...
+//	GOTH_OPENID_CONNECT_DISCOVERY_URL=https://accounts.example.com/.well-known/openid-configuration
+//
+// The providers are sorted by name.
+func FromEnv(prefix string) []ProviderConfig {
+	prefix = strings.ToUpper(prefix) + "_"
+	env := map[string]string{}
+	var names []string
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		k = strings.TrimPrefix(k, prefix)
+		env[k] = v
+		if name := strings.TrimSuffix(k, "_KEY"); name != k && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	configs := make([]ProviderConfig, 0, len(names))
+	for _, name := range names {
+		c := ProviderConfig{
+			Name:     strings.ToLower(strings.ReplaceAll(name, "_", "-")),
+			Type:     env[name+"_TYPE"],
+			Key:      env[name+"_KEY"],
+			Secret:   env[name+"_SECRET"],
+			Callback: env[name+"_CALLBACK"],
+		}
+		if scopes := env[name+"_SCOPES"]; scopes != "" {
+			c.Scopes = strings.FieldsFunc(scopes, func(r rune) bool {
+				return r == ',' || r == ' '
+			})
+		}
+		for k, v := range env {
+			field := strings.TrimPrefix(k, name+"_")
+			if field == k || !strings.HasSuffix(field, "_URL") || longerName(names, name, k) {
+				continue
+			}
+			if c.URLs == nil {
+				c.URLs = map[string]string{}
+			}
+			c.URLs[strings.ToLower(field)] = v
+		}
+		configs = append(configs, c)
+	}
+	return configs
+}
+
+// longerName reports whether the variable k belongs to another provider
+// whose name starts with name, e.g. GITHUB_ENTERPRISE for GITHUB.
+func longerName(names []string, name, k string) bool {
+	for _, other := range names {
+		if len(other) > len(name) && strings.HasPrefix(other, name+"_") && strings.HasPrefix(k, other+"_") {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadEnv creates the providers declared by the environment variables
+// starting with prefix, as described by FromEnv.
+func LoadEnv(prefix string) ([]goth.Provider, error) {
+	return Build(FromEnv(prefix)...)
+}
+
+// MustLoadEnv is like LoadEnv but panics if the providers cannot be created.
+func MustLoadEnv(prefix string) []goth.Provider {
+	providers, err := LoadEnv(prefix)
+	if err != nil {
+		panic(err)
+	}
+	return providers
+}
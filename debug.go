@@ -0,0 +1,104 @@
+package goth
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DebugSession is implemented by the sessions of the providers able to capture
+// their exchanges with the identity provider once Debug(true) is called on
+// the provider, currently github, google, okta, auth0 and openidConnect.
+type DebugSession interface {
+	// DebugInfo returns what was captured while authorizing the session, or
+	// nil if the provider wasn't in debug mode.
+	DebugInfo() *DebugInfo
+}
+
+// DebugInfo is what a session captured of the requests made to authorize it,
+// e.g. to see why a token exchange failed or which scopes were granted. It is
+// kept in the marshaled session, so tokens, secrets and codes are redacted.
+type DebugInfo struct {
+	// TokenResponse is the body of the last response of the token endpoint.
+	TokenResponse string `json:",omitempty"`
+	// Traces are the requests made, in order.
+	Traces []HTTPTrace `json:",omitempty"`
+
+	mu sync.Mutex
+}
+
+// HTTPTrace describes a request made to an identity provider.
+type HTTPTrace struct {
+	Method string
+	URL    string
+	// StatusCode is the status of the response, 0 if the request failed, in
+	// which case Error is set.
+	StatusCode int    `json:",omitempty"`
+	Error      string `json:",omitempty"`
+	Start      time.Time
+	Duration   time.Duration
+}
+
+// DebugClient returns a copy of h recording its requests, and the responses
+// of token endpoints, in info. Providers use it to authorize their sessions
+// when in debug mode.
+func DebugClient(h *http.Client, info *DebugInfo) *http.Client {
+	c := *h
+	c.Transport = &debugTransport{base: h.Transport, info: info}
+	return &c
+}
+
+type debugTransport struct {
+	base http.RoundTripper
+	info *DebugInfo
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// requests to the token endpoint are the ones with a grant_type
+	tokenRequest := false
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(body)
+			body.Close()
+			tokenRequest = bytes.Contains(b, []byte("grant_type="))
+		}
+	}
+
+	trace := HTTPTrace{Method: req.Method, URL: redactURL(req.URL), Start: time.Now()}
+	res, err := base.RoundTrip(req)
+	trace.Duration = time.Since(trace.Start)
+	if err != nil {
+		trace.Error = err.Error()
+		t.info.record(trace, "", false)
+		return res, err
+	}
+	trace.StatusCode = res.StatusCode
+
+	var resBody []byte
+	if tokenRequest {
+		resBody, err = io.ReadAll(res.Body)
+		res.Body.Close()
+		res.Body = io.NopCloser(bytes.NewReader(resBody))
+		if err != nil {
+			trace.Error = err.Error()
+		}
+	}
+	t.info.record(trace, Redact(string(resBody)), tokenRequest)
+	return res, nil
+}
+
+func (i *DebugInfo) record(trace HTTPTrace, tokenResponse string, isTokenResponse bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.Traces = append(i.Traces, trace)
+	if isTokenResponse {
+		i.TokenResponse = tokenResponse
+	}
+}
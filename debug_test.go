@@ -0,0 +1,52 @@
+package goth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_DebugClient(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/token":
+			w.Write([]byte(`{"access_token":"secret-token","token_type":"bearer","scope":"read"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	info := &goth.DebugInfo{}
+	client := goth.DebugClient(http.DefaultClient, info)
+	config := &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: ts.URL + "/token"}}
+	_, err := config.Exchange(goth.ContextWithClient(context.Background(), client), "code")
+	a.NoError(err)
+
+	res, err := client.Get(ts.URL + "/user?access_token=secret-token")
+	a.NoError(err)
+	res.Body.Close()
+
+	a.Equal(`{"access_token":"REDACTED","token_type":"bearer","scope":"read"}`, info.TokenResponse)
+	a.Len(info.Traces, 2)
+	a.Equal("POST", info.Traces[0].Method)
+	a.Equal(ts.URL+"/token", info.Traces[0].URL)
+	a.Equal(http.StatusOK, info.Traces[0].StatusCode)
+	a.False(info.Traces[0].Start.IsZero())
+	a.Equal(ts.URL+"/user?access_token=REDACTED", info.Traces[1].URL)
+	a.Equal(http.StatusNotFound, info.Traces[1].StatusCode)
+
+	_, err = client.Get("http://127.0.0.1:0/unreachable")
+	a.Error(err)
+	a.Len(info.Traces, 3)
+	a.NotEmpty(info.Traces[2].Error)
+}
@@ -0,0 +1,401 @@
+/*
+Package doctor checks the configuration of Goth providers and explains what is
+wrong with it: empty keys, malformed callback URLs, unreachable endpoints or a
+clock too far off from the provider's, mistakes which otherwise only show up
+once a user tries to sign in. It can be run when the application starts:
+
+	for _, d := range doctor.Check(ctx, doctor.Options{}) {
+		log.Println(d)
+	}
+
+or from the command line with cmd/gothdoctor.
+*/
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/openidConnect"
+)
+
+// Severity tells how bad the problem reported by a Diagnostic is.
+type Severity int
+
+const (
+	// OK reports a check which passed.
+	OK Severity = iota
+	// Warning reports something which works but likely shouldn't be left as is.
+	Warning
+	// Error reports something which prevents users from signing in.
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic is the result of a check of a provider.
+type Diagnostic struct {
+	Provider string
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("[%s] %s: %s", d.Severity, d.Provider, d.Message)
+}
+
+// Options configure Check.
+type Options struct {
+	// Offline skips the checks making requests, to the providers or to
+	// their discovery documents. OAuth1 providers request a token to begin
+	// the authentication, so their authorization URL isn't checked either.
+	Offline bool
+	// HTTPClient makes the requests. goth.HTTPClientWithFallBack(nil) is used
+	// if nil.
+	HTTPClient *http.Client
+	// MaxClockSkew is the difference between the local clock and the one of
+	// the providers above which a warning is reported. It defaults to 30
+	// seconds.
+	MaxClockSkew time.Duration
+}
+
+// Check checks providers, or every provider registered with goth.UseProviders
+// if none is given, and returns the diagnostics, ordered by provider.
+func Check(ctx context.Context, opts Options, providers ...goth.Provider) []Diagnostic {
+	if len(providers) == 0 {
+		for _, p := range goth.GetProviders() {
+			providers = append(providers, p)
+		}
+		sort.Slice(providers, func(i, j int) bool { return providers[i].Name() < providers[j].Name() })
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = goth.HTTPClientWithFallBack(nil)
+	}
+	if opts.MaxClockSkew == 0 {
+		opts.MaxClockSkew = 30 * time.Second
+	}
+
+	var diagnostics []Diagnostic
+	for _, p := range providers {
+		c := &checker{ctx: ctx, opts: opts, provider: p}
+		c.run()
+		diagnostics = append(diagnostics, c.diagnostics...)
+	}
+	return diagnostics
+}
+
+// HasErrors tells whether some of diagnostics have the Error severity.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+type checker struct {
+	ctx         context.Context
+	opts        Options
+	provider    goth.Provider
+	diagnostics []Diagnostic
+	skewChecked bool
+}
+
+func (c *checker) report(severity Severity, format string, args ...interface{}) {
+	c.diagnostics = append(c.diagnostics, Diagnostic{
+		Provider: c.provider.Name(),
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *checker) run() {
+	c.checkCredentials()
+
+	var authURL *url.URL
+	if !c.opts.Offline || !isOAuth1(c.provider) {
+		authURL = c.checkAuthURL()
+	}
+	c.checkCallbackURL(authURL)
+
+	if c.opts.Offline {
+		return
+	}
+	if authURL != nil {
+		if res := c.checkReachable("authorization endpoint", http.MethodGet, authURL.String()); res != nil {
+			res.Body.Close()
+		}
+	}
+	if config := openIDConfig(c.provider); config != nil {
+		c.checkDiscovery(config)
+	}
+}
+
+func (c *checker) checkCredentials() {
+	if key, ok := stringField(c.provider, "ClientKey", "ClientID", "ClientId"); ok {
+		if strings.TrimSpace(key) == "" {
+			c.report(Error, "the client key is empty")
+		} else if key != strings.TrimSpace(key) {
+			c.report(Error, "the client key has leading or trailing spaces")
+		}
+	}
+	if secret, ok := stringField(c.provider, "Secret", "ClientSecret"); ok {
+		if secret == "" {
+			c.report(Warning, "the secret is empty, which only works for public clients using PKCE")
+		} else if secret != strings.TrimSpace(secret) {
+			c.report(Error, "the secret has leading or trailing spaces")
+		}
+	}
+}
+
+func (c *checker) checkAuthURL() *url.URL {
+	sess, err := c.provider.BeginAuth("gothdoctor")
+	if err != nil {
+		c.report(Error, "cannot begin the authentication: %v", err)
+		return nil
+	}
+	raw, err := sess.GetAuthURL()
+	if err != nil {
+		c.report(Error, "cannot get the authorization URL: %v", err)
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		c.report(Error, "the authorization URL %q isn't an absolute URL", raw)
+		return nil
+	}
+	if u.Scheme != "https" && !isLocal(u.Hostname()) {
+		c.report(Warning, "the authorization URL %s doesn't use https", redact(u))
+	}
+	return u
+}
+
+func (c *checker) checkCallbackURL(authURL *url.URL) {
+	callback, ok := stringField(c.provider, "CallbackURL", "RedirectURL", "RedirectURI")
+	if !ok && authURL != nil {
+		callback, ok = authURL.Query().Get("redirect_uri"), authURL.Query().Has("redirect_uri")
+	}
+	if !ok {
+		return
+	}
+	if callback == "" {
+		c.report(Error, "the callback URL is empty")
+		return
+	}
+
+	u, err := url.Parse(callback)
+	switch {
+	case err != nil:
+		c.report(Error, "the callback URL %q cannot be parsed: %v", callback, err)
+	case !u.IsAbs() || u.Host == "":
+		c.report(Error, "the callback URL %q isn't an absolute URL", callback)
+	case u.Scheme != "http" && u.Scheme != "https":
+		c.report(Warning, "the callback URL %q doesn't use http or https, which only native applications can", callback)
+	case u.Fragment != "":
+		c.report(Error, "the callback URL %q has a fragment, which redirect URIs can't have", callback)
+	case u.Scheme == "http" && !isLocal(u.Hostname()):
+		c.report(Warning, "the callback URL %q doesn't use https, most providers only accept http for localhost", callback)
+	default:
+		c.report(OK, "the callback URL %s is well formed", callback)
+	}
+}
+
+func (c *checker) checkDiscovery(config *openidConnect.OpenIDConfig) {
+	if config.Issuer == "" {
+		c.report(Warning, "the OpenID Connect configuration has no issuer, so the discovery document isn't checked")
+	} else {
+		c.checkDiscoveryDocument(config)
+	}
+	if config.TokenEndpoint == "" {
+		c.report(Error, "the OpenID Connect configuration has no token endpoint")
+	} else {
+		// an empty token request is rejected, but tells that the endpoint is there
+		c.checkReachable("token endpoint", http.MethodPost, config.TokenEndpoint)
+	}
+}
+
+func (c *checker) checkDiscoveryDocument(config *openidConnect.OpenIDConfig) {
+	discoveryURL := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	res := c.checkReachable("discovery document", http.MethodGet, discoveryURL)
+	if res == nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		c.report(Error, "the discovery document %s responded with a %d", discoveryURL, res.StatusCode)
+		return
+	}
+
+	var published openidConnect.OpenIDConfig
+	if err := json.NewDecoder(res.Body).Decode(&published); err != nil {
+		c.report(Error, "the discovery document %s cannot be decoded: %v", discoveryURL, err)
+		return
+	}
+	if published.Issuer != config.Issuer {
+		c.report(Error, "the discovery document %s has the issuer %q instead of %q, id_tokens will be rejected", discoveryURL, published.Issuer, config.Issuer)
+	}
+	if published.TokenEndpoint != "" && published.TokenEndpoint != config.TokenEndpoint {
+		c.report(Warning, "the token endpoint %s isn't the one of the discovery document, %s", config.TokenEndpoint, published.TokenEndpoint)
+	}
+}
+
+// checkReachable requests rawURL, reporting an error if no response is
+// received, and checks the clock skew with the Date of the response. The
+// response is returned for GET requests, and has to be closed then.
+func (c *checker) checkReachable(name, method, rawURL string) *http.Response {
+	ctx, cancel := context.WithTimeout(c.ctx, 10*time.Second)
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		cancel()
+		c.report(Error, "the %s %q is invalid: %v", name, rawURL, err)
+		return nil
+	}
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	client := *c.opts.HTTPClient
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	res, err := client.Do(req)
+	u, _ := url.Parse(rawURL)
+	if err != nil {
+		cancel()
+		c.report(Error, "cannot reach the %s %s: %v", name, redact(u), err)
+		return nil
+	}
+	c.report(OK, "the %s %s is reachable", name, redact(u))
+	c.checkClockSkew(res)
+
+	if method != http.MethodGet {
+		res.Body.Close()
+		cancel()
+		return nil
+	}
+	res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+	return res
+}
+
+func (c *checker) checkClockSkew(res *http.Response) {
+	if c.skewChecked {
+		return
+	}
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	c.skewChecked = true
+
+	// Date has a resolution of a second
+	skew := goth.Now().Truncate(time.Second).Sub(date)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > c.opts.MaxClockSkew {
+		c.report(Warning, "the local clock is %s off from the provider's, tokens may be considered expired or not yet valid", skew)
+	}
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// stringField returns the first of the exported string fields named names of
+// the struct p points to.
+func stringField(p goth.Provider, names ...string) (string, bool) {
+	v := reflect.Indirect(reflect.ValueOf(p))
+	if v.Kind() != reflect.Struct {
+		return "", false
+	}
+	for _, name := range names {
+		f, ok := v.Type().FieldByName(name)
+		if !ok || !f.IsExported() || f.Type.Kind() != reflect.String {
+			continue
+		}
+		if fv, err := v.FieldByIndexErr(f.Index); err == nil {
+			return fv.String(), true
+		}
+	}
+	return "", false
+}
+
+// openIDConfig returns the OpenID Connect configuration of the openidConnect
+// provider p is or embeds.
+func openIDConfig(p goth.Provider) *openidConnect.OpenIDConfig {
+	v := reflect.Indirect(reflect.ValueOf(p))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	f, ok := v.Type().FieldByName("OpenIDConfig")
+	if !ok || !f.IsExported() {
+		return nil
+	}
+	fv, err := v.FieldByIndexErr(f.Index)
+	if err != nil {
+		return nil
+	}
+	config, _ := fv.Interface().(*openidConnect.OpenIDConfig)
+	return config
+}
+
+func isOAuth1(p goth.Provider) bool {
+	_, ok := stringField(p, "ConsumerKey")
+	if ok {
+		return true
+	}
+	v := reflect.Indirect(reflect.ValueOf(p))
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if strings.Contains(v.Type().Field(i).Type.String(), "oauth1.Consumer") {
+			return true
+		}
+	}
+	return false
+}
+
+func isLocal(host string) bool {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// redact returns u without its query, which holds the state and client ID.
+func redact(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	r := *u
+	r.RawQuery = ""
+	r.Fragment = ""
+	return r.String()
+}
@@ -0,0 +1,85 @@
+package doctor_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/doctor"
+	"github.com/markbates/goth/internal/testidp"
+	"github.com/markbates/goth/providers/github"
+	"github.com/markbates/goth/providers/openidConnect"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Check_Offline(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := github.New("", "secret", "http://example.com/auth/github/callback#top")
+	diagnostics := doctor.Check(context.Background(), doctor.Options{Offline: true}, p)
+	a.True(doctor.HasErrors(diagnostics))
+	a.Contains(diagnostics, doctor.Diagnostic{Provider: "github", Severity: doctor.Error, Message: "the client key is empty"})
+	a.Contains(diagnostics, doctor.Diagnostic{
+		Provider: "github",
+		Severity: doctor.Error,
+		Message:  `the callback URL "http://example.com/auth/github/callback#top" has a fragment, which redirect URIs can't have`,
+	})
+
+	p = github.New("key", "secret", "http://example.com/auth/github/callback")
+	diagnostics = doctor.Check(context.Background(), doctor.Options{Offline: true}, p)
+	a.False(doctor.HasErrors(diagnostics))
+	a.Equal([]doctor.Diagnostic{{
+		Provider: "github",
+		Severity: doctor.Warning,
+		Message:  `the callback URL "http://example.com/auth/github/callback" doesn't use https, most providers only accept http for localhost`,
+	}}, diagnostics)
+	a.Equal(`[warning] github: the callback URL "http://example.com/auth/github/callback" doesn't use https, most providers only accept http for localhost`, diagnostics[0].String())
+}
+
+func Test_Check_Unreachable(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(nil)
+	ts.Close()
+
+	p := github.NewCustomisedURL("key", "secret", "https://example.com/callback", ts.URL+"/authorize", ts.URL+"/token", ts.URL+"/user", ts.URL+"/emails")
+	diagnostics := doctor.Check(context.Background(), doctor.Options{}, p)
+	a.True(doctor.HasErrors(diagnostics))
+	a.Contains(diagnostics[len(diagnostics)-1].Message, "cannot reach the authorization endpoint "+ts.URL+"/authorize")
+}
+
+func Test_Check_OpenIDConnect(t *testing.T) {
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+
+	p, err := openidConnect.New("id", "secret", "http://localhost/callback", idp.DiscoveryURL())
+	a.NoError(err)
+
+	diagnostics := doctor.Check(context.Background(), doctor.Options{}, p)
+	a.False(doctor.HasErrors(diagnostics), "%v", diagnostics)
+	for _, d := range diagnostics {
+		a.Equal(doctor.OK, d.Severity, d.String())
+	}
+	a.Contains(diagnostics, doctor.Diagnostic{Provider: "openid-connect", Severity: doctor.OK, Message: "the discovery document " + idp.DiscoveryURL() + " is reachable"})
+	a.Contains(diagnostics, doctor.Diagnostic{Provider: "openid-connect", Severity: doctor.OK, Message: "the token endpoint " + idp.TokenURL() + " is reachable"})
+
+	goth.SetClock(func() time.Time { return time.Now().Add(5 * time.Minute) })
+	defer goth.SetClock(nil)
+	diagnostics = doctor.Check(context.Background(), doctor.Options{}, p)
+	a.False(doctor.HasErrors(diagnostics))
+	var warnings []string
+	for _, d := range diagnostics {
+		if d.Severity == doctor.Warning {
+			warnings = append(warnings, d.Message)
+		}
+	}
+	// the Date header has a resolution of a second
+	a.Len(warnings, 1)
+	a.Regexp(`^the local clock is 5m[01]s off from the provider's`, warnings[0])
+}
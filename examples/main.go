@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"html/template"
 	"log"
 	"net/http"
@@ -11,10 +10,14 @@ import (
 	"github.com/gorilla/pat"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/providers/adfs"
 	"github.com/markbates/goth/providers/amazon"
 	"github.com/markbates/goth/providers/apple"
 	"github.com/markbates/goth/providers/auth0"
+	"github.com/markbates/goth/providers/authelia"
+	"github.com/markbates/goth/providers/authentik"
 	"github.com/markbates/goth/providers/azuread"
+	"github.com/markbates/goth/providers/basecamp"
 	"github.com/markbates/goth/providers/battlenet"
 	"github.com/markbates/goth/providers/bitbucket"
 	"github.com/markbates/goth/providers/box"
@@ -23,8 +26,10 @@ import (
 	"github.com/markbates/goth/providers/digitalocean"
 	"github.com/markbates/goth/providers/discord"
 	"github.com/markbates/goth/providers/dropbox"
+	"github.com/markbates/goth/providers/entraid"
 	"github.com/markbates/goth/providers/eveonline"
 	"github.com/markbates/goth/providers/facebook"
+	"github.com/markbates/goth/providers/firebase"
 	"github.com/markbates/goth/providers/fitbit"
 	"github.com/markbates/goth/providers/gitea"
 	"github.com/markbates/goth/providers/github"
@@ -34,10 +39,13 @@ import (
 	"github.com/markbates/goth/providers/heroku"
 	"github.com/markbates/goth/providers/instagram"
 	"github.com/markbates/goth/providers/intercom"
+	"github.com/markbates/goth/providers/intuit"
+	"github.com/markbates/goth/providers/jellyfin"
 	"github.com/markbates/goth/providers/kakao"
 	"github.com/markbates/goth/providers/lastfm"
 	"github.com/markbates/goth/providers/line"
 	"github.com/markbates/goth/providers/linkedin"
+	"github.com/markbates/goth/providers/linode"
 	"github.com/markbates/goth/providers/mastodon"
 	"github.com/markbates/goth/providers/meetup"
 	"github.com/markbates/goth/providers/microsoftonline"
@@ -46,11 +54,14 @@ import (
 	"github.com/markbates/goth/providers/okta"
 	"github.com/markbates/goth/providers/onedrive"
 	"github.com/markbates/goth/providers/openidConnect"
+	"github.com/markbates/goth/providers/ory"
 	"github.com/markbates/goth/providers/patreon"
 	"github.com/markbates/goth/providers/paypal"
+	"github.com/markbates/goth/providers/plex"
 	"github.com/markbates/goth/providers/salesforce"
 	"github.com/markbates/goth/providers/seatalk"
 	"github.com/markbates/goth/providers/shopify"
+	"github.com/markbates/goth/providers/siwe"
 	"github.com/markbates/goth/providers/slack"
 	"github.com/markbates/goth/providers/soundcloud"
 	"github.com/markbates/goth/providers/spotify"
@@ -66,6 +77,8 @@ import (
 	"github.com/markbates/goth/providers/vk"
 	"github.com/markbates/goth/providers/wecom"
 	"github.com/markbates/goth/providers/wepay"
+	"github.com/markbates/goth/providers/workos"
+	"github.com/markbates/goth/providers/xbox"
 	"github.com/markbates/goth/providers/xero"
 	"github.com/markbates/goth/providers/yahoo"
 	"github.com/markbates/goth/providers/yammer"
@@ -80,6 +93,8 @@ func main() {
 		twitterv2.New(os.Getenv("TWITTER_KEY"), os.Getenv("TWITTER_SECRET"), "http://localhost:3000/auth/twitterv2/callback"),
 		// If you'd like to use authenticate instead of authorize in TwitterV2 provider, use this instead.
 		// twitterv2.NewAuthenticate(os.Getenv("TWITTER_KEY"), os.Getenv("TWITTER_SECRET"), "http://localhost:3000/auth/twitterv2/callback"),
+		// If you'd like to use OAuth 2.0 with PKCE in TwitterV2 provider, use this instead with the OAuth 2.0 client ID and secret.
+		// twitterv2.NewOAuth2(os.Getenv("TWITTER_CLIENT_ID"), os.Getenv("TWITTER_CLIENT_SECRET"), "http://localhost:3000/auth/twitterv2/callback", twitterv2.ScopeTweetRead, twitterv2.ScopeUsersRead, twitterv2.ScopeOfflineAccess),
 
 		twitter.New(os.Getenv("TWITTER_KEY"), os.Getenv("TWITTER_SECRET"), "http://localhost:3000/auth/twitter/callback"),
 		// If you'd like to use authenticate instead of authorize in Twitter provider, use this instead.
@@ -93,14 +108,17 @@ func main() {
 		github.New(os.Getenv("GITHUB_KEY"), os.Getenv("GITHUB_SECRET"), "http://localhost:3000/auth/github/callback"),
 		spotify.New(os.Getenv("SPOTIFY_KEY"), os.Getenv("SPOTIFY_SECRET"), "http://localhost:3000/auth/spotify/callback"),
 		linkedin.New(os.Getenv("LINKEDIN_KEY"), os.Getenv("LINKEDIN_SECRET"), "http://localhost:3000/auth/linkedin/callback"),
+		linode.New(os.Getenv("LINODE_KEY"), os.Getenv("LINODE_SECRET"), "http://localhost:3000/auth/linode/callback"),
 		line.New(os.Getenv("LINE_KEY"), os.Getenv("LINE_SECRET"), "http://localhost:3000/auth/line/callback", "profile", "openid", "email"),
 		lastfm.New(os.Getenv("LASTFM_KEY"), os.Getenv("LASTFM_SECRET"), "http://localhost:3000/auth/lastfm/callback"),
 		twitch.New(os.Getenv("TWITCH_KEY"), os.Getenv("TWITCH_SECRET"), "http://localhost:3000/auth/twitch/callback"),
 		dropbox.New(os.Getenv("DROPBOX_KEY"), os.Getenv("DROPBOX_SECRET"), "http://localhost:3000/auth/dropbox/callback"),
 		digitalocean.New(os.Getenv("DIGITALOCEAN_KEY"), os.Getenv("DIGITALOCEAN_SECRET"), "http://localhost:3000/auth/digitalocean/callback", "read"),
+		basecamp.New(os.Getenv("BASECAMP_KEY"), os.Getenv("BASECAMP_SECRET"), "http://localhost:3000/auth/basecamp/callback"),
 		bitbucket.New(os.Getenv("BITBUCKET_KEY"), os.Getenv("BITBUCKET_SECRET"), "http://localhost:3000/auth/bitbucket/callback"),
 		instagram.New(os.Getenv("INSTAGRAM_KEY"), os.Getenv("INSTAGRAM_SECRET"), "http://localhost:3000/auth/instagram/callback"),
 		intercom.New(os.Getenv("INTERCOM_KEY"), os.Getenv("INTERCOM_SECRET"), "http://localhost:3000/auth/intercom/callback"),
+		intuit.New(os.Getenv("INTUIT_KEY"), os.Getenv("INTUIT_SECRET"), "http://localhost:3000/auth/intuit/callback"),
 		box.New(os.Getenv("BOX_KEY"), os.Getenv("BOX_SECRET"), "http://localhost:3000/auth/box/callback"),
 		salesforce.New(os.Getenv("SALESFORCE_KEY"), os.Getenv("SALESFORCE_SECRET"), "http://localhost:3000/auth/salesforce/callback"),
 		seatalk.New(os.Getenv("SEATALK_KEY"), os.Getenv("SEATALK_SECRET"), "http://localhost:3000/auth/seatalk/callback"),
@@ -108,7 +126,11 @@ func main() {
 		yammer.New(os.Getenv("YAMMER_KEY"), os.Getenv("YAMMER_SECRET"), "http://localhost:3000/auth/yammer/callback"),
 		onedrive.New(os.Getenv("ONEDRIVE_KEY"), os.Getenv("ONEDRIVE_SECRET"), "http://localhost:3000/auth/onedrive/callback"),
 		azuread.New(os.Getenv("AZUREAD_KEY"), os.Getenv("AZUREAD_SECRET"), "http://localhost:3000/auth/azuread/callback", nil),
+		// AD FS is hosted on premises, the URL of the farm must be provided
+		adfs.New(os.Getenv("ADFS_KEY"), os.Getenv("ADFS_SECRET"), "http://localhost:3000/auth/adfs/callback", os.Getenv("ADFS_URL"), adfs.Options{Resource: os.Getenv("ADFS_RESOURCE")}),
+		entraid.New(os.Getenv("ENTRAID_KEY"), os.Getenv("ENTRAID_SECRET"), "http://localhost:3000/auth/entraid/callback", entraid.Options{Tenant: os.Getenv("ENTRAID_TENANT")}),
 		microsoftonline.New(os.Getenv("MICROSOFTONLINE_KEY"), os.Getenv("MICROSOFTONLINE_SECRET"), "http://localhost:3000/auth/microsoftonline/callback"),
+		xbox.New(os.Getenv("XBOX_KEY"), os.Getenv("XBOX_SECRET"), "http://localhost:3000/auth/xbox/callback"),
 		battlenet.New(os.Getenv("BATTLENET_KEY"), os.Getenv("BATTLENET_SECRET"), "http://localhost:3000/auth/battlenet/callback"),
 		eveonline.New(os.Getenv("EVEONLINE_KEY"), os.Getenv("EVEONLINE_SECRET"), "http://localhost:3000/auth/eveonline/callback"),
 		kakao.New(os.Getenv("KAKAO_KEY"), os.Getenv("KAKAO_SECRET"), "http://localhost:3000/auth/kakao/callback"),
@@ -120,8 +142,7 @@ func main() {
 		slack.New(os.Getenv("SLACK_KEY"), os.Getenv("SLACK_SECRET"), "http://localhost:3000/auth/slack/callback"),
 		stripe.New(os.Getenv("STRIPE_KEY"), os.Getenv("STRIPE_SECRET"), "http://localhost:3000/auth/stripe/callback"),
 		wepay.New(os.Getenv("WEPAY_KEY"), os.Getenv("WEPAY_SECRET"), "http://localhost:3000/auth/wepay/callback", "view_user"),
-		// By default paypal production auth urls will be used, please set PAYPAL_ENV=sandbox as environment variable for testing
-		// in sandbox environment
+		// Use paypal.NewSandbox instead with the credentials of a sandbox application
 		paypal.New(os.Getenv("PAYPAL_KEY"), os.Getenv("PAYPAL_SECRET"), "http://localhost:3000/auth/paypal/callback"),
 		steam.New(os.Getenv("STEAM_KEY"), "http://localhost:3000/auth/steam/callback"),
 		heroku.New(os.Getenv("HEROKU_KEY"), os.Getenv("HEROKU_SECRET"), "http://localhost:3000/auth/heroku/callback"),
@@ -136,7 +157,7 @@ func main() {
 		// Auth0 allocates domain per customer, a domain must be provided for auth0 to work
 		auth0.New(os.Getenv("AUTH0_KEY"), os.Getenv("AUTH0_SECRET"), "http://localhost:3000/auth/auth0/callback", os.Getenv("AUTH0_DOMAIN")),
 		xero.New(os.Getenv("XERO_KEY"), os.Getenv("XERO_SECRET"), "http://localhost:3000/auth/xero/callback"),
-		vk.New(os.Getenv("VK_KEY"), os.Getenv("VK_SECRET"), "http://localhost:3000/auth/vk/callback"),
+		vk.NewVKID(os.Getenv("VK_KEY"), os.Getenv("VK_SECRET"), "http://localhost:3000/auth/vk/callback"),
 		naver.New(os.Getenv("NAVER_KEY"), os.Getenv("NAVER_SECRET"), "http://localhost:3000/auth/naver/callback"),
 		yandex.New(os.Getenv("YANDEX_KEY"), os.Getenv("YANDEX_SECRET"), "http://localhost:3000/auth/yandex/callback"),
 		nextcloud.NewCustomisedDNS(os.Getenv("NEXTCLOUD_KEY"), os.Getenv("NEXTCLOUD_SECRET"), "http://localhost:3000/auth/nextcloud/callback", os.Getenv("NEXTCLOUD_URL")),
@@ -149,22 +170,48 @@ func main() {
 		wecom.New(os.Getenv("WECOM_CORP_ID"), os.Getenv("WECOM_SECRET"), os.Getenv("WECOM_AGENT_ID"), "http://localhost:3000/auth/wecom/callback"),
 		zoom.New(os.Getenv("ZOOM_KEY"), os.Getenv("ZOOM_SECRET"), "http://localhost:3000/auth/zoom/callback", "read:user"),
 		patreon.New(os.Getenv("PATREON_KEY"), os.Getenv("PATREON_SECRET"), "http://localhost:3000/auth/patreon/callback"),
+		// Sign-In with Ethereum needs a frontend signing the message with the wallet and posting it to the callback
+		siwe.New("localhost:3000", "http://localhost:3000/auth/siwe/callback"),
+		// Firebase needs a frontend signing in with the Firebase SDK and posting the ID token to the callback
+		firebase.New(os.Getenv("FIREBASE_PROJECT_ID"), "http://localhost:3000/auth/firebase/callback"),
+		plex.New(os.Getenv("PLEX_CLIENT_IDENTIFIER"), "http://localhost:3000/auth/plex/callback"),
+		// Jellyfin needs a sign in form posting the username and password to the callback
+		jellyfin.New(os.Getenv("JELLYFIN_URL"), "http://localhost:3000/auth/jellyfin/callback"),
 	)
 
+	// WorkOS needs to know which connection to use, e.g. the one of an organization
+	workOS := workos.New(os.Getenv("WORKOS_KEY"), os.Getenv("WORKOS_SECRET"), "http://localhost:3000/auth/workos/callback")
+	workOS.SetOrganization(os.Getenv("WORKOS_ORGANIZATION_ID"))
+	goth.UseProviders(workOS)
+
 	// OpenID Connect is based on OpenID Connect Auto Discovery URL (https://openid.net/specs/openid-connect-discovery-1_0-17.html)
-	// because the OpenID Connect provider initialize itself in the New(), it can return an error which should be handled or ignored
-	// ignore the error for now
-	openidConnect, _ := openidConnect.New(os.Getenv("OPENID_CONNECT_KEY"), os.Getenv("OPENID_CONNECT_SECRET"), "http://localhost:3000/auth/openid-connect/callback", os.Getenv("OPENID_CONNECT_DISCOVERY_URL"))
-	if openidConnect != nil {
-		goth.UseProviders(openidConnect)
-	}
+	// because the OpenID Connect provider initialize itself in the New(), it can return an error if the discovery URL can't be reached.
+	// Registering it lazily creates it on first use instead, and tries again later if it fails.
+	goth.UseLazyProvider("openid-connect", func() (goth.Provider, error) {
+		return openidConnect.New(os.Getenv("OPENID_CONNECT_KEY"), os.Getenv("OPENID_CONNECT_SECRET"), "http://localhost:3000/auth/openid-connect/callback", os.Getenv("OPENID_CONNECT_DISCOVERY_URL"))
+	})
+	// Ory discovers its endpoints the same way, from the URL of the Ory Network project or Hydra instance.
+	goth.UseLazyProvider("ory", func() (goth.Provider, error) {
+		return ory.New(os.Getenv("ORY_KEY"), os.Getenv("ORY_SECRET"), "http://localhost:3000/auth/ory/callback", os.Getenv("ORY_URL"))
+	})
+
+	goth.UseLazyProvider("authentik", func() (goth.Provider, error) {
+		return authentik.New(os.Getenv("AUTHENTIK_KEY"), os.Getenv("AUTHENTIK_SECRET"), "http://localhost:3000/auth/authentik/callback", os.Getenv("AUTHENTIK_URL"), os.Getenv("AUTHENTIK_APPLICATION_SLUG"))
+	})
+	goth.UseLazyProvider("authelia", func() (goth.Provider, error) {
+		return authelia.New(os.Getenv("AUTHELIA_KEY"), os.Getenv("AUTHELIA_SECRET"), "http://localhost:3000/auth/authelia/callback", os.Getenv("AUTHELIA_URL"))
+	})
 
 	m := map[string]string{
+		"adfs":            "AD FS",
 		"amazon":          "Amazon",
 		"apple":           "Apple",
 		"auth0":           "Auth0",
+		"authelia":        "Authelia",
+		"authentik":       "authentik",
 		"azuread":         "Azure AD",
 		"battlenet":       "Battle.net",
+		"basecamp":        "Basecamp",
 		"bitbucket":       "Bitbucket",
 		"box":             "Box",
 		"dailymotion":     "Dailymotion",
@@ -172,8 +219,10 @@ func main() {
 		"digitalocean":    "Digital Ocean",
 		"discord":         "Discord",
 		"dropbox":         "Dropbox",
+		"entraid":         "Entra ID",
 		"eveonline":       "Eve Online",
 		"facebook":        "Facebook",
+		"firebase":        "Firebase",
 		"fitbit":          "Fitbit",
 		"gitea":           "Gitea",
 		"github":          "Github",
@@ -183,10 +232,13 @@ func main() {
 		"heroku":          "Heroku",
 		"instagram":       "Instagram",
 		"intercom":        "Intercom",
+		"intuit":          "Intuit",
+		"jellyfin":        "Jellyfin",
 		"kakao":           "Kakao",
 		"lastfm":          "Last FM",
 		"line":            "LINE",
 		"linkedin":        "LinkedIn",
+		"linode":          "Linode",
 		"mastodon":        "Mastodon",
 		"meetup":          "Meetup.com",
 		"microsoftonline": "Microsoft Online",
@@ -195,11 +247,14 @@ func main() {
 		"okta":            "Okta",
 		"onedrive":        "Onedrive",
 		"openid-connect":  "OpenID Connect",
+		"ory":             "Ory",
 		"patreon":         "Patreon",
 		"paypal":          "Paypal",
+		"plex":            "Plex",
 		"salesforce":      "Salesforce",
 		"seatalk":         "SeaTalk",
 		"shopify":         "Shopify",
+		"siwe":            "Sign-In with Ethereum",
 		"slack":           "Slack",
 		"soundcloud":      "SoundCloud",
 		"spotify":         "Spotify",
@@ -215,6 +270,8 @@ func main() {
 		"vk":              "VK",
 		"wecom":           "WeCom",
 		"wepay":           "Wepay",
+		"workos":          "WorkOS",
+		"xbox":            "Xbox Live",
 		"xero":            "Xero",
 		"yahoo":           "Yahoo",
 		"yammer":          "Yammer",
@@ -234,7 +291,9 @@ func main() {
 
 		user, err := gothic.CompleteUserAuth(res, req)
 		if err != nil {
-			fmt.Fprintln(res, err)
+			// never write err to the browser, it may quote the request
+			log.Printf("authentication failed: %v", err)
+			http.Error(res, "authentication failed", http.StatusUnauthorized)
 			return
 		}
 		t, _ := template.New("foo").Parse(userTemplate)
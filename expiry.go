@@ -0,0 +1,44 @@
+package goth
+
+import (
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ExpiryFromExpiresIn returns the expiry of a token the provider said expires
+// in expiresIn seconds, or the zero time if expiresIn isn't positive. Providers
+// decoding token responses themselves use it so that ExpiresAt is computed
+// the same way everywhere, with the clock set with SetClock.
+func ExpiryFromExpiresIn(expiresIn int64) time.Time {
+	if expiresIn <= 0 {
+		return time.Time{}
+	}
+	return Now().Add(time.Duration(expiresIn) * time.Second)
+}
+
+// TokenExpired reports whether token has expired, or expires within skew.
+// Tokens without an expiry never expire, while a nil token always has.
+func TokenExpired(token *oauth2.Token, skew time.Duration) bool {
+	if token == nil {
+		return true
+	}
+	return expired(token.Expiry, skew)
+}
+
+// SessionTokenExpired is like TokenExpired for the token held by sess, which
+// never expires unless sess implements TokenSession.
+func SessionTokenExpired(sess Session, skew time.Duration) bool {
+	ts, ok := sess.(TokenSession)
+	if !ok {
+		return false
+	}
+	return TokenExpired(ts.Token(), skew)
+}
+
+func expired(expiry time.Time, skew time.Duration) bool {
+	if expiry.IsZero() {
+		return false
+	}
+	return !Now().Add(skew).Before(expiry)
+}
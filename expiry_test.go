@@ -0,0 +1,37 @@
+package goth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/markbates/goth/providers/google"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_TokenExpired(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	goth.SetClock(func() time.Time { return now })
+	defer goth.SetClock(nil)
+
+	a.Equal(now.Add(time.Hour), goth.ExpiryFromExpiresIn(3600))
+	a.True(goth.ExpiryFromExpiresIn(0).IsZero())
+
+	user := goth.User{ExpiresAt: now.Add(time.Minute)}
+	a.False(user.TokenExpired(0))
+	a.False(user.TokenExpired(59 * time.Second))
+	a.True(user.TokenExpired(time.Minute))
+	a.False(goth.User{}.TokenExpired(time.Hour), "tokens without expiry never expire")
+
+	a.True(goth.TokenExpired(&oauth2.Token{Expiry: now}, 0))
+	a.False(goth.TokenExpired(&oauth2.Token{AccessToken: "token"}, 0))
+	a.True(goth.TokenExpired(nil, 0))
+
+	a.True(goth.SessionTokenExpired(&google.Session{AccessToken: "token", ExpiresAt: now.Add(-time.Second)}, 0))
+	a.False(goth.SessionTokenExpired(&google.Session{AccessToken: "token", ExpiresAt: now.Add(time.Hour)}, 5*time.Minute))
+	a.False(goth.SessionTokenExpired(&faux.Session{}, time.Hour))
+}
@@ -0,0 +1,99 @@
+package gothic
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MaxAuthAttempts is the number of authentication processes a session can
+// have in progress at once, e.g. when a user opens the login page in several
+// tabs and picks a different provider, or the same one, in each of them.
+// Starting one more discards the provider session of the oldest, whose
+// callback then fails.
+var MaxAuthAttempts = 5
+
+// attemptsKey is the key of the list of the keys of the provider sessions
+// of the authentication processes in progress, oldest first.
+const attemptsKey = "auth-attempts"
+
+// attemptKeyPrefix is the prefix of the keys of provider sessions stored by
+// state, so that they never collide with the names of providers.
+const attemptKeyPrefix = "auth:"
+
+// attemptKey returns the key the provider session of an authentication
+// process with the given state is stored under. Every process has its own,
+// so that starting one doesn't overwrite the session of another one still in
+// progress. Processes without a state, like OAuth1 ones, are stored under
+// the name of the provider.
+func attemptKey(providerName, state string) string {
+	if state == "" {
+		return providerName
+	}
+	sum := sha256.Sum256([]byte(state))
+	return attemptKeyPrefix + providerName + ":" + base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+// stateOf returns the state parameter of an authorization URL.
+func stateOf(authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("state")
+}
+
+// pendingAttempts returns the keys of the provider sessions of the
+// authentication processes in progress in storage, oldest first.
+func pendingAttempts(req *http.Request, storage SessionStorage) []string {
+	value, err := storage.Get(req, attemptsKey)
+	if err != nil || value == "" {
+		return nil
+	}
+	return strings.Split(value, "\n")
+}
+
+// addAttempt records that the provider session stored under key belongs to
+// an authentication process in progress, discarding the sessions of the
+// oldest processes beyond MaxAuthAttempts.
+func addAttempt(req *http.Request, res http.ResponseWriter, key string) error {
+	keys := append(without(pendingAttempts(req, Storage), key), key)
+	for MaxAuthAttempts > 0 && len(keys) > MaxAuthAttempts {
+		if err := Storage.Delete(req, res, keys[0]); err != nil {
+			return err
+		}
+		keys = keys[1:]
+	}
+	return Storage.Set(req, res, attemptsKey, strings.Join(keys, "\n"))
+}
+
+// finishAttempt ends the authentication process whose provider session is
+// stored under key. It removes that provider session, but keeps the rest of
+// the session: the processes still in progress, and the users kept by
+// CompleteUserAuth or CompleteLink.
+func finishAttempt(res http.ResponseWriter, req *http.Request, key string) error {
+	if err := clearFormPostSession(req, res); err != nil {
+		return err
+	}
+	if err := Storage.Delete(req, res, key); err != nil {
+		return err
+	}
+
+	keys := without(pendingAttempts(req, Storage), key)
+	if len(keys) == 0 {
+		return Storage.Delete(req, res, attemptsKey)
+	}
+	return Storage.Set(req, res, attemptsKey, strings.Join(keys, "\n"))
+}
+
+func without(keys []string, key string) []string {
+	result := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if k != key {
+			result = append(result, k)
+		}
+	}
+	return result
+}
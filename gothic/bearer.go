@@ -0,0 +1,54 @@
+package gothic
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/markbates/goth"
+)
+
+// ErrNoBearerToken is returned by UserFromBearerToken when the request has no
+// "Authorization: Bearer" header.
+var ErrNoBearerToken = errors.New("gothic: the request has no bearer token")
+
+// UserFromBearerToken authenticates an API request carrying the access token
+// of a provider in its "Authorization: Bearer" header, e.g. one sent by a
+// mobile app which completed the authorization on the device. The user the
+// token was issued for is fetched from the provider selected with
+// GetProviderName, which must implement goth.TokenUserFetcher.
+//
+// Nothing is stored in the session, so the provider is contacted on every
+// call unless a user cache is set with goth.SetUserCache.
+//
+// WARNING: a bearer token only authenticates the user to the application
+// it was issued to; any other application the user signed in to with the same
+// identity provider could send its own token to impersonate them. The provider
+// must therefore confirm the token was issued to its client key, which the
+// providers implementing goth.TokenUserFetcher do, failing with an error
+// wrapping goth.ErrTokenAudience. Never accept a bearer token by fetching the
+// profile of a provider which can't check it.
+func UserFromBearerToken(req *http.Request) (goth.User, error) {
+	token := bearerToken(req)
+	if token == "" {
+		return goth.User{}, ErrNoBearerToken
+	}
+
+	providerName, err := GetProviderName(req)
+	if err != nil {
+		return goth.User{}, err
+	}
+	provider, err := getProvider(req, providerName)
+	if err != nil {
+		return goth.User{}, err
+	}
+	return goth.UserFromAccessToken(req.Context(), provider, token)
+}
+
+func bearerToken(req *http.Request) string {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,79 @@
+package gothic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/markbates/goth"
+)
+
+// ErrHostNotAllowed is returned when the callback URL of a request is resolved
+// with CallbackURLForHosts and the host of the request isn't allowed.
+var ErrHostNotAllowed = errors.New("gothic: the host of the request is not allowed")
+
+// CallbackURL, if set, resolves the callback URL providers send users back to
+// for each request, instead of the one they were created with, e.g. so that
+// users return to the domain they started on when an application is served on
+// several domains. It is called both when the authentication process starts
+// and when it completes, and must return the same URL then. An empty URL
+// keeps the one of the provider. The URLs must be registered with the
+// providers, and custom providers must implement goth.CallbackURLProvider, as
+// the ones of goth do.
+var CallbackURL func(req *http.Request, providerName string) (string, error)
+
+/*
+CallbackURLForHosts returns a resolver for CallbackURL filling template with
+the host of the request and the name of the provider, e.g.
+
+	gothic.CallbackURL = gothic.CallbackURLForHosts(
+		"https://{host}/auth/{provider}/callback",
+		"example.com", "www.example.com", "*.example.org",
+	)
+
+The host, including its port if any, must be one of hosts, or a subdomain of
+those starting with "*.", or ErrHostNotAllowed is returned. Behind a proxy,
+the proxy has to pass the Host header of the original request along.
+*/
+func CallbackURLForHosts(template string, hosts ...string) func(req *http.Request, providerName string) (string, error) {
+	return func(req *http.Request, providerName string) (string, error) {
+		host := strings.ToLower(req.Host)
+		if !hostAllowed(host, hosts) {
+			return "", fmt.Errorf("%w: %q", ErrHostNotAllowed, req.Host)
+		}
+		return strings.NewReplacer("{host}", host, "{provider}", providerName).Replace(template), nil
+	}
+}
+
+func hostAllowed(host string, hosts []string) bool {
+	for _, h := range hosts {
+		h = strings.ToLower(h)
+		if host == h {
+			return true
+		}
+		if strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// authProvider returns the provider authenticating req, sending users back to
+// the callback URL resolved by CallbackURL if set.
+func authProvider(req *http.Request, providerName string) (goth.Provider, error) {
+	provider, err := getProvider(req, providerName)
+	if err != nil || CallbackURL == nil {
+		return provider, err
+	}
+
+	callbackURL, err := CallbackURL(req, providerName)
+	if err != nil || callbackURL == "" {
+		return provider, err
+	}
+	p, ok := provider.(goth.CallbackURLProvider)
+	if !ok {
+		return nil, fmt.Errorf("gothic: the %s provider cannot use another callback URL", providerName)
+	}
+	return p.WithCallbackURL(callbackURL), nil
+}
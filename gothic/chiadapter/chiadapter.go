@@ -0,0 +1,53 @@
+/*
+Package chiadapter wires gothic into go-chi routers. The provider name is read
+from a route parameter, ProviderParam, instead of the query string:
+
+	r := chi.NewRouter()
+	r.Get("/auth/{provider}", chiadapter.BeginAuthHandler)
+	r.Get("/auth/{provider}/callback", func(w http.ResponseWriter, r *http.Request) {
+		user, err := chiadapter.CompleteUserAuth(w, r)
+		...
+	})
+	r.Get("/logout/{provider}", func(w http.ResponseWriter, r *http.Request) {
+		chiadapter.Logout(w, r)
+		...
+	})
+*/
+package chiadapter
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+)
+
+// ProviderParam is the name of the route parameter holding the provider name.
+var ProviderParam = "provider"
+
+// BeginAuthHandler starts the authentication process with the provider of the
+// route, like gothic.BeginAuthHandler.
+func BeginAuthHandler(w http.ResponseWriter, r *http.Request) {
+	gothic.BeginAuthHandler(w, withProvider(r))
+}
+
+// CompleteUserAuth completes the authentication process with the provider of
+// the route, like gothic.CompleteUserAuth.
+func CompleteUserAuth(w http.ResponseWriter, r *http.Request) (goth.User, error) {
+	return gothic.CompleteUserAuth(w, withProvider(r))
+}
+
+// Logout invalidates the user session, like gothic.Logout.
+func Logout(w http.ResponseWriter, r *http.Request) error {
+	return gothic.Logout(w, withProvider(r))
+}
+
+// withProvider returns r carrying the provider of the route for gothic, or r
+// itself if the route has none.
+func withProvider(r *http.Request) *http.Request {
+	if provider := chi.URLParam(r, ProviderParam); provider != "" {
+		return gothic.GetContextWithProvider(r, provider)
+	}
+	return r
+}
@@ -0,0 +1,49 @@
+package chiadapter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/gothic/chiadapter"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RoundTrip(t *testing.T) {
+	a := assert.New(t)
+
+	goth.UseProviders(&faux.Provider{})
+	gothic.Store = sessions.NewCookieStore([]byte("secret"))
+	chiadapter.ProviderParam = "idp"
+	defer func() { chiadapter.ProviderParam = "provider" }()
+
+	r := chi.NewRouter()
+	r.Get("/auth/{idp}", chiadapter.BeginAuthHandler)
+	r.Get("/auth/{idp}/callback", func(w http.ResponseWriter, r *http.Request) {
+		user, err := chiadapter.CompleteUserAuth(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(user.Provider))
+	})
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest("GET", "/auth/faux?state=state", nil))
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+	a.Contains(res.Header().Get("Location"), "example.com/auth")
+
+	req := httptest.NewRequest("GET", "/auth/faux/callback?state=state", nil)
+	for _, c := range res.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	a.Equal(http.StatusOK, res.Code)
+	a.Equal("faux", res.Body.String())
+}
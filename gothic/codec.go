@@ -0,0 +1,76 @@
+package gothic
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// SessionCodec encodes the marshalled provider session before it is handed to
+// Storage, and decodes it when it is read back. Assign one to Codec to protect
+// the tokens contained in provider sessions independently of the storage
+// backend, e.g. with the AES-GCM codec returned by NewAESGCMCodec.
+type SessionCodec interface {
+	Encode(value string) (string, error)
+	Decode(value string) (string, error)
+}
+
+// Codec is the SessionCodec applied to values stored by StoreInSession and read
+// by GetFromSession. It is nil by default, which stores values as they are.
+var Codec SessionCodec
+
+// errInvalidCiphertext is returned when a value can't be decrypted with any of the keys.
+var errInvalidCiphertext = errors.New("gothic: could not decrypt session value")
+
+type aesGCMCodec struct {
+	aeads []cipher.AEAD
+}
+
+// NewAESGCMCodec returns a SessionCodec that encrypts and authenticates values
+// with AES-GCM. Each key must be 16, 24 or 32 bytes long to select AES-128,
+// AES-192 or AES-256. Values are encrypted with the first key and decrypted
+// with whichever key works, so keys can be rotated by prepending a new one.
+func NewAESGCMCodec(keys ...[]byte) (SessionCodec, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("gothic: at least one key is required")
+	}
+
+	c := &aesGCMCodec{}
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.aeads = append(c.aeads, aead)
+	}
+	return c, nil
+}
+
+func (c *aesGCMCodec) Encode(value string) (string, error) {
+	aead := c.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return string(aead.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+func (c *aesGCMCodec) Decode(value string) (string, error) {
+	for _, aead := range c.aeads {
+		if len(value) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := value[:aead.NonceSize()], value[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, []byte(nonce), []byte(ciphertext), nil)
+		if err == nil {
+			return string(plaintext), nil
+		}
+	}
+	return "", errInvalidCiphertext
+}
@@ -0,0 +1,63 @@
+/*
+Package echoadapter wires gothic into Echo. The provider name is read from a
+path parameter, ProviderParam, instead of the query string:
+
+	e := echo.New()
+	e.GET("/auth/:provider", echoadapter.BeginAuthHandler)
+	e.GET("/auth/:provider/callback", func(c echo.Context) error {
+		user, err := echoadapter.CompleteUserAuth(c)
+		if err != nil {
+			// errors may quote the request, never send them to the client
+			c.Logger().Error(err)
+			return echo.NewHTTPError(http.StatusUnauthorized)
+		}
+		return c.JSON(http.StatusOK, user)
+	})
+	e.GET("/logout/:provider", func(c echo.Context) error {
+		if err := echoadapter.Logout(c); err != nil {
+			return err
+		}
+		return c.Redirect(http.StatusTemporaryRedirect, "/")
+	})
+
+It is a module of its own, so that Goth doesn't depend on Echo.
+*/
+package echoadapter
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+)
+
+// ProviderParam is the name of the path parameter holding the provider name.
+var ProviderParam = "provider"
+
+// BeginAuthHandler starts the authentication process with the provider of the
+// route, like gothic.BeginAuthHandler.
+func BeginAuthHandler(c echo.Context) error {
+	gothic.BeginAuthHandler(c.Response(), withProvider(c))
+	return nil
+}
+
+// CompleteUserAuth completes the authentication process with the provider of
+// the route, like gothic.CompleteUserAuth.
+func CompleteUserAuth(c echo.Context) (goth.User, error) {
+	return gothic.CompleteUserAuth(c.Response(), withProvider(c))
+}
+
+// Logout invalidates the user session, like gothic.Logout.
+func Logout(c echo.Context) error {
+	return gothic.Logout(c.Response(), withProvider(c))
+}
+
+// withProvider makes the request of c carry the provider of the route for
+// gothic, and returns it.
+func withProvider(c echo.Context) *http.Request {
+	if provider := c.Param(ProviderParam); provider != "" {
+		c.SetRequest(gothic.GetContextWithProvider(c.Request(), provider))
+	}
+	return c.Request()
+}
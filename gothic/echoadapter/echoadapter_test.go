@@ -0,0 +1,46 @@
+package echoadapter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/labstack/echo/v4"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/gothic/echoadapter"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RoundTrip(t *testing.T) {
+	a := assert.New(t)
+
+	goth.UseProviders(&faux.Provider{})
+	gothic.Store = sessions.NewCookieStore([]byte("secret"))
+
+	e := echo.New()
+	e.GET("/auth/:provider", echoadapter.BeginAuthHandler)
+	e.GET("/auth/:provider/callback", func(c echo.Context) error {
+		user, err := echoadapter.CompleteUserAuth(c)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		}
+		return c.String(http.StatusOK, user.Provider)
+	})
+
+	res := httptest.NewRecorder()
+	e.ServeHTTP(res, httptest.NewRequest("GET", "/auth/faux?state=state", nil))
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+	a.Contains(res.Header().Get("Location"), "example.com/auth")
+
+	req := httptest.NewRequest("GET", "/auth/faux/callback?state=state", nil)
+	for _, c := range res.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	res = httptest.NewRecorder()
+	e.ServeHTTP(res, req)
+	a.Equal(http.StatusOK, res.Code)
+	a.Equal("faux", res.Body.String())
+}
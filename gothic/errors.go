@@ -0,0 +1,43 @@
+package gothic
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+/*
+ErrorHandler writes the response of BeginAuthHandler and BeginLink when they
+fail. The errors of gothic and of the providers may quote values taken from
+the request, e.g. the parameters of a callback, so the default handler never
+writes them: it answers with a generic message and logs the error instead.
+
+Assign your own function to render errors the way your application does, e.g.
+
+	gothic.ErrorHandler = func(res http.ResponseWriter, req *http.Request, err error) {
+		log.Printf("authentication failed: %v", err)
+		http.Redirect(res, req, "/login?failed=1", http.StatusFound)
+	}
+
+The handler returned by Mount reports its errors to the handler set with
+WithFailureHandler instead.
+*/
+var ErrorHandler = DefaultErrorHandler
+
+// DefaultErrorHandler logs err and responds with a generic message, with a
+// 403 if the state of the request didn't match the one of the session and a
+// 400 otherwise.
+func DefaultErrorHandler(res http.ResponseWriter, req *http.Request, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, ErrStateMismatch) {
+		status = http.StatusForbidden
+	}
+	writeError(res, req, status, err)
+}
+
+// writeError logs err and responds with a generic message, so that the
+// content of err is never reflected in the response.
+func writeError(res http.ResponseWriter, req *http.Request, status int, err error) {
+	log.Printf("gothic: %s %s: %v", req.Method, req.URL.Path, err)
+	http.Error(res, "authentication failed", status)
+}
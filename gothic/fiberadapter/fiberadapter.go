@@ -0,0 +1,90 @@
+/*
+Package fiberadapter wires gothic into Fiber. The provider name is read from a
+route parameter, ProviderParam, instead of the query string:
+
+	app := fiber.New()
+	app.Get("/auth/:provider", fiberadapter.BeginAuthHandler)
+	app.Get("/auth/:provider/callback", func(c *fiber.Ctx) error {
+		user, err := fiberadapter.CompleteUserAuth(c)
+		if err != nil {
+			// errors may quote the request, never send them to the client
+			log.Println(err)
+			return fiber.NewError(fiber.StatusUnauthorized)
+		}
+		return c.JSON(user)
+	})
+	app.Get("/logout/:provider", func(c *fiber.Ctx) error {
+		if err := fiberadapter.Logout(c); err != nil {
+			return err
+		}
+		return c.Redirect("/")
+	})
+
+Fiber isn't built on net/http, so gothic runs on a net/http copy of the
+request, and the headers it sets, including the session cookies, are copied
+to the Fiber response. Handlers may respond as they like afterwards.
+
+It is a module of its own, so that Goth doesn't depend on Fiber.
+*/
+package fiberadapter
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+)
+
+// ProviderParam is the name of the route parameter holding the provider name.
+var ProviderParam = "provider"
+
+// BeginAuthHandler starts the authentication process with the provider of the
+// route, like gothic.BeginAuthHandler.
+func BeginAuthHandler(c *fiber.Ctx) error {
+	provider := providerOf(c)
+	return adaptor.HTTPHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gothic.BeginAuthHandler(w, withProvider(r, provider))
+	})(c)
+}
+
+// CompleteUserAuth completes the authentication process with the provider of
+// the route, like gothic.CompleteUserAuth.
+func CompleteUserAuth(c *fiber.Ctx) (goth.User, error) {
+	provider := providerOf(c)
+	var user goth.User
+	var err error
+	if herr := adaptor.HTTPHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err = gothic.CompleteUserAuth(w, withProvider(r, provider))
+	})(c); herr != nil {
+		return goth.User{}, herr
+	}
+	return user, err
+}
+
+// Logout invalidates the user session, like gothic.Logout.
+func Logout(c *fiber.Ctx) error {
+	provider := providerOf(c)
+	var err error
+	if herr := adaptor.HTTPHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err = gothic.Logout(w, withProvider(r, provider))
+	})(c); herr != nil {
+		return herr
+	}
+	return err
+}
+
+// providerOf returns the provider of the route. The value of c.Params is only
+// valid until the handler returns, so it is copied.
+func providerOf(c *fiber.Ctx) string {
+	return utils.CopyString(c.Params(ProviderParam))
+}
+
+func withProvider(r *http.Request, provider string) *http.Request {
+	if provider != "" {
+		return gothic.GetContextWithProvider(r, provider)
+	}
+	return r
+}
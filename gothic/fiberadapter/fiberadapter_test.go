@@ -0,0 +1,48 @@
+package fiberadapter_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/gothic/fiberadapter"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RoundTrip(t *testing.T) {
+	a := assert.New(t)
+
+	goth.UseProviders(&faux.Provider{})
+	gothic.Store = sessions.NewCookieStore([]byte("secret"))
+
+	app := fiber.New()
+	app.Get("/auth/:provider", fiberadapter.BeginAuthHandler)
+	app.Get("/auth/:provider/callback", func(c *fiber.Ctx) error {
+		user, err := fiberadapter.CompleteUserAuth(c)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+		return c.SendString(user.Provider)
+	})
+
+	res, err := app.Test(httptest.NewRequest("GET", "/auth/faux?state=state", nil))
+	a.NoError(err)
+	a.Equal(http.StatusTemporaryRedirect, res.StatusCode)
+	a.Contains(res.Header.Get("Location"), "example.com/auth")
+
+	req := httptest.NewRequest("GET", "/auth/faux/callback?state=state", nil)
+	for _, c := range res.Cookies() {
+		req.AddCookie(c)
+	}
+	res, err = app.Test(req)
+	a.NoError(err)
+	body, _ := io.ReadAll(res.Body)
+	a.Equal(http.StatusOK, res.StatusCode)
+	a.Equal("faux", string(body))
+}
@@ -0,0 +1,117 @@
+package gothic
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/sessions"
+)
+
+// FormPostSessionName returns the name of the session carrying the provider
+// session through callbacks made with response_mode=form_post. It is derived
+// from GetSessionName, so applications using different session names don't
+// share it.
+func FormPostSessionName(req *http.Request) string {
+	return GetSessionName(req) + "_form_post"
+}
+
+// FormPostCookie makes GetAuthURL keep a copy of the provider session in the
+// FormPostSessionName session when the authorization URL asks for a form_post
+// response, as Apple's does. The identity provider then POSTs the callback
+// from its own site, and browsers don't send SameSite=Lax cookies along with
+// such requests, so the regular session is missing from the callback.
+//
+// The copy is stored with SameSite=None and Secure, which requires the
+// callback to be served over https, and expires after FormPostMaxAge seconds
+// or once the authentication completes. It is only used with the default
+// GorillaStorage; custom SessionStorage implementations have to handle
+// cross-site callbacks themselves.
+var FormPostCookie = true
+
+// FormPostMaxAge is the lifetime, in seconds, of the FormPostSessionName session.
+var FormPostMaxAge = 600
+
+func formPostStore() (sessions.Store, bool) {
+	if !FormPostCookie {
+		return nil, false
+	}
+	switch g := Storage.(type) {
+	case GorillaStorage:
+		return g.store(), true
+	case *GorillaStorage:
+		return g.store(), true
+	}
+	return nil, false
+}
+
+func isFormPost(authURL string) bool {
+	u, err := url.Parse(authURL)
+	return err == nil && u.Query().Get("response_mode") == "form_post"
+}
+
+// storeFormPostValue replaces the content of the form_post session with the
+// encoded value stored under key.
+func storeFormPostValue(req *http.Request, res http.ResponseWriter, key, value string) error {
+	store, ok := formPostStore()
+	if !ok {
+		return nil
+	}
+
+	// a cookie that can't be decoded anymore is simply replaced
+	session, err := store.New(req, FormPostSessionName(req))
+	if session == nil {
+		return err
+	}
+
+	options := sessions.Options{Path: "/"}
+	if session.Options != nil {
+		options = *session.Options
+	}
+	options.MaxAge = FormPostMaxAge
+	options.Secure = true
+	options.HttpOnly = true
+	session.Options = &options
+	session.Values = map[interface{}]interface{}{key: value}
+	if err := session.Save(req, res); err != nil {
+		return err
+	}
+	addSameSite(res, FormPostSessionName(req), http.SameSiteNoneMode)
+	return nil
+}
+
+// getFromFormPostSession is the counterpart of GetFromSession for the
+// form_post session.
+func getFromFormPostSession(key string, req *http.Request) (string, error) {
+	store, ok := formPostStore()
+	if !ok || req.Method != http.MethodPost {
+		return "", errors.New("could not find a matching session for this request")
+	}
+
+	session, err := store.Get(req, FormPostSessionName(req))
+	if err != nil {
+		return "", err
+	}
+	value, ok := session.Values[key].(string)
+	if !ok {
+		return "", errors.New("could not find a matching session for this request")
+	}
+	return decodeValue(value)
+}
+
+// clearFormPostSession expires the form_post session, if the request has one.
+func clearFormPostSession(req *http.Request, res http.ResponseWriter) error {
+	store, ok := formPostStore()
+	if !ok {
+		return nil
+	}
+	if _, err := req.Cookie(FormPostSessionName(req)); err != nil {
+		return nil
+	}
+
+	session, err := store.Get(req, FormPostSessionName(req))
+	if session == nil {
+		return err
+	}
+	return expire(req, res, session)
+}
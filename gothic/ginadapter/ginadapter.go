@@ -0,0 +1,59 @@
+/*
+Package ginadapter wires gothic into Gin. The provider name is read from a path
+parameter, ProviderParam, instead of the query string:
+
+	r := gin.Default()
+	r.GET("/auth/:provider", ginadapter.BeginAuthHandler)
+	r.GET("/auth/:provider/callback", func(c *gin.Context) {
+		user, err := ginadapter.CompleteUserAuth(c)
+		if err != nil {
+			c.AbortWithError(http.StatusUnauthorized, err)
+			return
+		}
+		c.JSON(http.StatusOK, user)
+	})
+	r.GET("/logout/:provider", func(c *gin.Context) {
+		ginadapter.Logout(c)
+		c.Redirect(http.StatusTemporaryRedirect, "/")
+	})
+
+It is a module of its own, so that Goth doesn't depend on Gin.
+*/
+package ginadapter
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+)
+
+// ProviderParam is the name of the path parameter holding the provider name.
+var ProviderParam = "provider"
+
+// BeginAuthHandler starts the authentication process with the provider of the
+// route, like gothic.BeginAuthHandler.
+func BeginAuthHandler(c *gin.Context) {
+	gothic.BeginAuthHandler(c.Writer, withProvider(c))
+}
+
+// CompleteUserAuth completes the authentication process with the provider of
+// the route, like gothic.CompleteUserAuth.
+func CompleteUserAuth(c *gin.Context) (goth.User, error) {
+	return gothic.CompleteUserAuth(c.Writer, withProvider(c))
+}
+
+// Logout invalidates the user session, like gothic.Logout.
+func Logout(c *gin.Context) error {
+	return gothic.Logout(c.Writer, withProvider(c))
+}
+
+// withProvider makes the request of c carry the provider of the route for
+// gothic, and returns it.
+func withProvider(c *gin.Context) *http.Request {
+	if provider := c.Param(ProviderParam); provider != "" {
+		c.Request = gothic.GetContextWithProvider(c.Request, provider)
+	}
+	return c.Request
+}
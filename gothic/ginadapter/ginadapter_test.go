@@ -0,0 +1,48 @@
+package ginadapter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/gothic/ginadapter"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RoundTrip(t *testing.T) {
+	a := assert.New(t)
+
+	goth.UseProviders(&faux.Provider{})
+	gothic.Store = sessions.NewCookieStore([]byte("secret"))
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/auth/:provider", ginadapter.BeginAuthHandler)
+	r.GET("/auth/:provider/callback", func(c *gin.Context) {
+		user, err := ginadapter.CompleteUserAuth(c)
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+		c.String(http.StatusOK, user.Provider)
+	})
+
+	res := httptest.NewRecorder()
+	r.ServeHTTP(res, httptest.NewRequest("GET", "/auth/faux?state=state", nil))
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+	a.Contains(res.Header().Get("Location"), "example.com/auth")
+
+	req := httptest.NewRequest("GET", "/auth/faux/callback?state=state", nil)
+	for _, c := range res.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	res = httptest.NewRecorder()
+	r.ServeHTTP(res, req)
+	a.Equal(http.StatusOK, res.Code)
+	a.Equal("faux", res.Body.String())
+}
@@ -12,6 +12,7 @@ import (
 	"compress/gzip"
 	"context"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -21,6 +22,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/mux"
@@ -28,10 +30,14 @@ import (
 	"github.com/markbates/goth"
 )
 
-// SessionName is the key used to access the session store.
+// SessionName is the key used to access the session store, unless another
+// name is set for the request with RequestWithSessionName or for a Mount
+// handler with WithSessionName.
 const SessionName = "_gothic_session"
 
 // Store can/should be set by applications using gothic. The default is a cookie store.
+// It is used by the default Storage; applications that assign their own
+// SessionStorage to Storage don't need to set it.
 var Store sessions.Store
 var defaultStore sessions.Store
 
@@ -42,6 +48,12 @@ type key int
 // ProviderParamKey can be used as a key in context when passing in a provider
 const ProviderParamKey key = iota
 
+// providerInstanceKey is the context key of the provider set by WithProviderInstance.
+const providerInstanceKey key = 1
+
+// sessionNameKey is the context key of the name set by RequestWithSessionName.
+const sessionNameKey key = 2
+
 func init() {
 	key := []byte(os.Getenv("SESSION_SECRET"))
 	keySet = len(key) != 0
@@ -58,15 +70,20 @@ It expects to be able to get the name of the provider from the query parameters
 as either "provider" or ":provider".
 
 BeginAuthHandler will redirect the user to the appropriate authentication end-point
-for the requested provider.
+for the requested provider. Requests accepting application/json are answered
+like GetAuthURLJSON does instead. Failures are reported to ErrorHandler.
 
 See https://github.com/markbates/goth/blob/master/examples/main.go to see this in action.
 */
 func BeginAuthHandler(res http.ResponseWriter, req *http.Request) {
+	if acceptsJSON(req) {
+		GetAuthURLJSON(res, req)
+		return
+	}
+
 	url, err := GetAuthURL(res, req)
 	if err != nil {
-		res.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintln(res, err)
+		ErrorHandler(res, req, err)
 		return
 	}
 
@@ -96,15 +113,37 @@ var SetState = func(req *http.Request) string {
 	return base64.URLEncoding.EncodeToString(nonceBytes)
 }
 
+// StateStore, if set, generates the states of the authentication processes
+// GetAuthURL and BeginLink start, in place of SetState, and the callbacks are
+// then only accepted if the store validates their state, which is consumed:
+//
+//	gothic.StateStore = goth.NewMemoryStateStore(10*time.Minute, 0)
+//
+// Applications running several instances need a store shared by all of them,
+// e.g. backed by their database.
+var StateStore goth.StateStore
+
+// newState returns the state of a new authentication process with the named
+// provider.
+func newState(req *http.Request, providerName string) (string, error) {
+	if StateStore == nil {
+		return SetState(req), nil
+	}
+	return StateStore.Generate(req.Context(), providerName)
+}
+
 // GetState gets the state returned by the provider during the callback.
 // This is used to prevent CSRF attacks, see
 // http://tools.ietf.org/html/rfc6749#section-10.12
+// Callbacks made with response_mode=form_post carry the state in the body,
+// as do JSON callbacks if JSONCallbacks is set.
 var GetState = func(req *http.Request) string {
-	params := req.URL.Query()
-	if params.Encode() == "" && req.Method == http.MethodPost {
+	state := req.URL.Query().Get("state")
+	if state == "" && req.Method == http.MethodPost {
+		parseCallbackForm(req)
 		return req.FormValue("state")
 	}
-	return params.Get("state")
+	return state
 }
 
 /*
@@ -127,11 +166,15 @@ func GetAuthURL(res http.ResponseWriter, req *http.Request) (string, error) {
 		return "", err
 	}
 
-	provider, err := goth.GetProvider(providerName)
+	provider, err := authProvider(req, providerName)
 	if err != nil {
 		return "", err
 	}
-	sess, err := provider.BeginAuth(SetState(req))
+	state, err := newState(req, providerName)
+	if err != nil {
+		return "", err
+	}
+	sess, err := beginAuth(provider, state, req)
 	if err != nil {
 		return "", err
 	}
@@ -141,15 +184,57 @@ func GetAuthURL(res http.ResponseWriter, req *http.Request) (string, error) {
 		return "", err
 	}
 
-	err = StoreInSession(providerName, sess.Marshal(), req, res)
+	key := attemptKey(providerName, stateOf(url))
+	err = StoreInSession(key, sess.Marshal(), req, res)
 
 	if err != nil {
 		return "", err
 	}
+	if err := addAttempt(req, res, key); err != nil {
+		return "", err
+	}
+	goth.GetMetrics().AuthBegun(providerName)
+
+	if isFormPost(url) {
+		value, err := encodeValue(sess.Marshal())
+		if err != nil {
+			return "", err
+		}
+		if err := storeFormPostValue(req, res, key, value); err != nil {
+			return "", err
+		}
+	}
 
 	return url, err
 }
 
+// ForwardedAuthURLParams are the query parameters of the request starting the
+// authentication process that GetAuthURL passes on to the authorization URL,
+// for providers implementing goth.AuthURLParamsProvider. Providers also
+// implementing goth.ForwardedParamsProvider get the parameters they name as
+// well, e.g. the shop of Shopify. Other parameters are never forwarded.
+var ForwardedAuthURLParams = []string{"login_hint", "prompt", "domain_hint", "connection", "ui_locales"}
+
+func beginAuth(provider goth.Provider, state string, req *http.Request) (goth.Session, error) {
+	if _, ok := provider.(goth.AuthURLParamsProvider); !ok {
+		return provider.BeginAuth(state)
+	}
+
+	keys := ForwardedAuthURLParams
+	if p, ok := provider.(goth.ForwardedParamsProvider); ok {
+		keys = append(keys[:len(keys):len(keys)], p.ForwardedAuthURLParams()...)
+	}
+
+	query := req.URL.Query()
+	params := url.Values{}
+	for _, key := range keys {
+		if v := query.Get(key); v != "" {
+			params.Set(key, v)
+		}
+	}
+	return goth.BeginAuthWithOptions(provider, state, params)
+}
+
 /*
 CompleteUserAuth does what it says on the tin. It completes the authentication
 process and fetches all the basic information about the user from the provider.
@@ -157,6 +242,12 @@ process and fetches all the basic information about the user from the provider.
 It expects to be able to get the name of the provider from the query parameters
 as either "provider" or ":provider".
 
+The user is checked with goth.ValidateUser before being returned, and the
+hooks registered with OnAuthSuccess and OnAuthFailure are called with the outcome,
+which is reported to the Metrics set with goth.SetMetrics as well.
+Its tokens are saved in TokenStore, if set. If KeepUserInSession is set, the
+user is then kept in the session for GetUserFromSession.
+
 See https://github.com/markbates/goth/blob/master/examples/main.go to see this in action.
 */
 var CompleteUserAuth = func(res http.ResponseWriter, req *http.Request) (goth.User, error) {
@@ -164,40 +255,75 @@ var CompleteUserAuth = func(res http.ResponseWriter, req *http.Request) (goth.Us
 		fmt.Println("goth/gothic: no SESSION_SECRET environment variable is set. The default cookie store is not available and any calls will fail. Ignore this warning if you are using a different store.")
 	}
 
+	start := time.Now()
 	providerName, err := GetProviderName(req)
 	if err != nil {
+		runAuthHooks(req, "", goth.User{}, err)
+		recordCompletion(req, "", start, err)
 		return goth.User{}, err
 	}
 
-	provider, err := goth.GetProvider(providerName)
+	user, err := completeUserAuth(res, req, providerName)
+	if err == nil {
+		if err = goth.ValidateUser(user); err != nil {
+			user = goth.User{}
+		}
+	}
+	if err == nil {
+		if err = saveToken(req, providerName, user); err != nil {
+			user = goth.User{}
+		}
+	}
+	if err == nil && KeepUserInSession {
+		if err = keepUserInSession(res, req, providerName, user); err != nil {
+			user = goth.User{}
+		}
+	}
+	runAuthHooks(req, providerName, user, err)
+	recordCompletion(req, providerName, start, err)
+	return user, err
+}
+
+func completeUserAuth(res http.ResponseWriter, req *http.Request, providerName string) (goth.User, error) {
+
+	provider, err := authProvider(req, providerName)
 	if err != nil {
 		return goth.User{}, err
 	}
 
-	value, err := GetFromSession(providerName, req)
+	value, key, err := getAuthSession(req, providerName)
 	if err != nil {
 		return goth.User{}, err
 	}
-	defer Logout(res, req)
+	defer finishAttempt(res, req, key)
 	sess, err := provider.UnmarshalSession(value)
 	if err != nil {
 		return goth.User{}, err
 	}
 
-	err = validateState(req, sess)
+	err = validateState(req, providerName, sess)
 	if err != nil {
 		return goth.User{}, err
 	}
 
-	user, err := provider.FetchUser(sess)
+	if RefreshExpiredTokens {
+		if err := refreshExpiredToken(res, req, key, provider, sess); err != nil {
+			return goth.User{}, err
+		}
+	}
+
+	user, err := goth.FetchUser(provider, sess)
 	if err == nil {
 		// user can be found with existing session data
 		return user, err
 	}
 
 	params := req.URL.Query()
-	if params.Encode() == "" && req.Method == "POST" {
-		req.ParseForm()
+	if req.Method == "POST" {
+		// req.Form holds the query parameters as well
+		if err := parseCallbackForm(req); err != nil {
+			return goth.User{}, err
+		}
 		params = req.Form
 	}
 
@@ -207,19 +333,83 @@ var CompleteUserAuth = func(res http.ResponseWriter, req *http.Request) (goth.Us
 		return goth.User{}, err
 	}
 
-	err = StoreInSession(providerName, sess.Marshal(), req, res)
+	err = StoreInSession(key, sess.Marshal(), req, res)
 
 	if err != nil {
 		return goth.User{}, err
 	}
 
-	gu, err := provider.FetchUser(sess)
+	gu, err := goth.FetchUser(provider, sess)
 	return gu, err
 }
 
+// RefreshExpiredTokens makes CompleteUserAuth refresh the access token of the
+// stored session when it has expired, instead of failing to fetch the user. It
+// only applies to providers offering refresh tokens and whose session implements
+// goth.TokenSession. goth.TokenRotated is called if the refresh token changes.
+var RefreshExpiredTokens = false
+
+func refreshExpiredToken(res http.ResponseWriter, req *http.Request, key string, provider goth.Provider, sess goth.Session) error {
+	ts, ok := sess.(goth.TokenSession)
+	if !ok || !provider.RefreshTokenAvailable() {
+		return nil
+	}
+
+	token := ts.Token()
+	if token.AccessToken == "" || token.RefreshToken == "" || !goth.TokenExpired(token, 0) {
+		return nil
+	}
+
+	newToken, err := goth.RefreshToken(req.Context(), provider, token.RefreshToken)
+	if err != nil {
+		var revoked *goth.GrantRevokedError
+		if errors.As(err, &revoked) {
+			GrantRevoked(req, revoked)
+		}
+		return err
+	}
+	ts.SetToken(newToken)
+
+	return StoreInSession(key, sess.Marshal(), req, res)
+}
+
+// getAuthSession returns the provider session of the authentication process
+// the callback req belongs to, and the key it is stored under.
+func getAuthSession(req *http.Request, providerName string) (value, key string, err error) {
+	keys := []string{attemptKey(providerName, GetState(req))}
+	if keys[0] != providerName {
+		// sessions stored under the name of the provider, as they were
+		// before being stored by state
+		keys = append(keys, providerName)
+	}
+
+	for _, key := range keys {
+		if value, err = GetFromSession(key, req); err == nil {
+			return value, key, nil
+		}
+	}
+	// form_post callbacks come without the regular session cookie
+	for _, key := range keys {
+		if value, err = getFromFormPostSession(key, req); err == nil {
+			return value, key, nil
+		}
+	}
+
+	// the state of the callback is not the one of any process in progress
+	// with the provider
+	prefix := attemptKeyPrefix + providerName + ":"
+	for _, k := range pendingAttempts(req, Storage) {
+		if strings.HasPrefix(k, prefix) {
+			return "", "", ErrStateMismatch
+		}
+	}
+	return "", "", err
+}
+
 // validateState ensures that the state token param from the original
-// AuthURL matches the one included in the current (callback) request.
-func validateState(req *http.Request, sess goth.Session) error {
+// AuthURL matches the one included in the current (callback) request, and
+// consumes it if a StateStore is set.
+func validateState(req *http.Request, providerName string, sess goth.Session) error {
 	rawAuthURL, err := sess.GetAuthURL()
 	if err != nil {
 		return err
@@ -234,23 +424,111 @@ func validateState(req *http.Request, sess goth.Session) error {
 
 	originalState := authURL.Query().Get("state")
 	if originalState != "" && (originalState != reqState) {
-		return errors.New("state token mismatch")
+		return ErrStateMismatch
+	}
+	if originalState != "" && StateStore != nil {
+		if err := consumeState(req, providerName, originalState); err != nil {
+			return err
+		}
+	}
+
+	// OAuth1 flows have no state, the request token ties the callback to
+	// the session instead
+	if s, ok := sess.(goth.OAuth1Session); ok {
+		if reqToken := req.URL.Query().Get("oauth_token"); reqToken != "" {
+			token, _ := s.GetRequestToken()
+			if subtle.ConstantTimeCompare([]byte(reqToken), []byte(token)) != 1 {
+				return ErrStateMismatch
+			}
+		}
 	}
 	return nil
 }
 
+// consumeState validates state with StateStore and consumes it, reporting the
+// states the store doesn't accept as ErrStateMismatch.
+func consumeState(req *http.Request, providerName, state string) error {
+	err := StateStore.Validate(req.Context(), providerName, state)
+	if err == nil {
+		err = StateStore.Consume(req.Context(), providerName, state)
+	}
+	if errors.Is(err, goth.ErrInvalidState) {
+		return ErrStateMismatch
+	}
+	return err
+}
+
 // Logout invalidates a user session.
 func Logout(res http.ResponseWriter, req *http.Request) error {
-	session, err := Store.Get(req, SessionName)
+	if err := clearFormPostSession(req, res); err != nil {
+		return err
+	}
+	return Storage.Clear(req, res)
+}
+
+// LogoutAndRevoke invalidates the user session and revokes token, usually
+// goth.User.AccessToken or goth.User.RefreshToken, if the provider supports
+// it (see goth.TokenRevoker). The session is invalidated even if revoking the
+// token fails.
+func LogoutAndRevoke(res http.ResponseWriter, req *http.Request, token string) error {
+	providerName, err := GetProviderName(req)
 	if err != nil {
 		return err
 	}
-	session.Options.MaxAge = -1
-	session.Values = make(map[interface{}]interface{})
-	err = session.Save(req, res)
+
+	provider, err := getProvider(req, providerName)
 	if err != nil {
-		return errors.New("Could not delete user session ")
+		return err
+	}
+
+	if err := Logout(res, req); err != nil {
+		return err
+	}
+
+	err = goth.RevokeToken(req.Context(), provider, token)
+	if err == goth.ErrRevocationNotSupported {
+		return nil
+	}
+	return err
+}
+
+/*
+LogoutWithProvider invalidates the user session and, if the provider supports
+OpenID Connect RP-Initiated Logout (see goth.EndSessionProvider), redirects the
+browser to the provider's end session endpoint so the user is logged out there
+as well. Providers without such an endpoint redirect straight to
+postLogoutRedirect.
+
+idTokenHint is the id_token of the user, usually goth.User.IDToken, and may be
+empty. postLogoutRedirect usually has to be registered with the provider.
+*/
+func LogoutWithProvider(res http.ResponseWriter, req *http.Request, idTokenHint, postLogoutRedirect string) error {
+	providerName, err := GetProviderName(req)
+	if err != nil {
+		return err
+	}
+
+	provider, err := getProvider(req, providerName)
+	if err != nil {
+		return err
+	}
+
+	if err := Logout(res, req); err != nil {
+		return err
+	}
+
+	redirect := postLogoutRedirect
+	if p, ok := provider.(goth.EndSessionProvider); ok {
+		redirect, err = p.LogoutURL(idTokenHint, postLogoutRedirect)
+		if err != nil {
+			return err
+		}
+	}
+	if redirect == "" {
+		return nil
 	}
+
+	http.Redirect(res, req, redirect, http.StatusFound)
 	return nil
 }
 
@@ -294,12 +572,16 @@ func getProviderName(req *http.Request) (string, error) {
 	}
 
 	// As a fallback, loop over the used providers, if we already have a valid session for any provider (ie. user has already begun authentication with a provider), then return that provider name
-	providers := goth.GetProviders()
-	session, _ := Store.Get(req, SessionName)
-	for _, provider := range providers {
-		p := provider.Name()
-		value := session.Values[p]
-		if _, ok := value.(string); ok {
+	providers := goth.ProviderNames()
+	if state := GetState(req); state != "" {
+		for _, p := range providers {
+			if _, err := Storage.Get(req, attemptKey(p, state)); err == nil {
+				return p, nil
+			}
+		}
+	}
+	for _, p := range providers {
+		if _, err := Storage.Get(req, p); err == nil {
 			return p, nil
 		}
 	}
@@ -313,36 +595,94 @@ func GetContextWithProvider(req *http.Request, provider string) *http.Request {
 	return req.WithContext(context.WithValue(req.Context(), ProviderParamKey, provider))
 }
 
+// WithProviderInstance returns a copy of req for which gothic uses provider
+// instead of the provider registered with goth.UseProviders under the same
+// name. This lets multi-tenant applications use e.g. different client IDs per
+// tenant. provider must be injected both when the authentication process is
+// started and when it is completed.
+func WithProviderInstance(req *http.Request, provider goth.Provider) *http.Request {
+	ctx := context.WithValue(req.Context(), providerInstanceKey, provider)
+	ctx = context.WithValue(ctx, ProviderParamKey, provider.Name())
+	return req.WithContext(ctx)
+}
+
+// RequestWithSessionName returns a copy of req for which the default Storage
+// keeps the values of gothic in the session named name instead of
+// SessionName, e.g. so that applications sharing a domain, or tests running
+// in parallel, don't read each other's cookies. A GorillaStorage whose Name is
+// set keeps using that name.
+func RequestWithSessionName(req *http.Request, name string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), sessionNameKey, name))
+}
+
+// GetSessionName returns the name of the session used for req, as set with
+// RequestWithSessionName, or SessionName. SessionStorage implementations
+// naming their sessions can use it to follow the same configuration.
+func GetSessionName(req *http.Request) string {
+	if name, ok := req.Context().Value(sessionNameKey).(string); ok && name != "" {
+		return name
+	}
+	return SessionName
+}
+
+// getProvider returns the provider injected into req with WithProviderInstance
+// if it has the given name, or the registered one otherwise.
+func getProvider(req *http.Request, name string) (goth.Provider, error) {
+	if p, ok := req.Context().Value(providerInstanceKey).(goth.Provider); ok && p.Name() == name {
+		return p, nil
+	}
+	return goth.GetProvider(name)
+}
+
 // StoreInSession stores a specified key/value pair in the session.
 func StoreInSession(key string, value string, req *http.Request, res http.ResponseWriter) error {
-	session, _ := Store.New(req, SessionName)
-
-	if err := updateSessionValue(session, key, value); err != nil {
+	encoded, err := encodeValue(value)
+	if err != nil {
 		return err
 	}
 
-	return session.Save(req, res)
+	return Storage.Set(req, res, key, encoded)
 }
 
 // GetFromSession retrieves a previously-stored value from the session.
 // If no value has previously been stored at the specified key, it will return an error.
 func GetFromSession(key string, req *http.Request) (string, error) {
-	session, _ := Store.Get(req, SessionName)
-	value, err := getSessionValue(session, key)
+	value, err := Storage.Get(req, key)
 	if err != nil {
 		return "", errors.New("could not find a matching session for this request")
 	}
 
-	return value, nil
+	return decodeValue(value)
 }
 
-func getSessionValue(session *sessions.Session, key string) (string, error) {
-	value := session.Values[key]
-	if value == nil {
-		return "", fmt.Errorf("could not find a matching session for this request")
+// encodeValue compresses value and applies the Codec, if any.
+func encodeValue(value string) (string, error) {
+	compressed, err := compressValue(value)
+	if err != nil {
+		return "", err
 	}
 
-	rdata := strings.NewReader(value.(string))
+	if Codec != nil {
+		return Codec.Encode(compressed)
+	}
+	return compressed, nil
+}
+
+// decodeValue reverts encodeValue.
+func decodeValue(value string) (string, error) {
+	if Codec != nil {
+		var err error
+		value, err = Codec.Decode(value)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return decompressValue(value)
+}
+
+func decompressValue(value string) (string, error) {
+	rdata := strings.NewReader(value)
 	r, err := gzip.NewReader(rdata)
 	if err != nil {
 		return "", err
@@ -355,19 +695,18 @@ func getSessionValue(session *sessions.Session, key string) (string, error) {
 	return string(s), nil
 }
 
-func updateSessionValue(session *sessions.Session, key, value string) error {
+func compressValue(value string) (string, error) {
 	var b bytes.Buffer
 	gz := gzip.NewWriter(&b)
 	if _, err := gz.Write([]byte(value)); err != nil {
-		return err
+		return "", err
 	}
 	if err := gz.Flush(); err != nil {
-		return err
+		return "", err
 	}
 	if err := gz.Close(); err != nil {
-		return err
+		return "", err
 	}
 
-	session.Values[key] = b.String()
-	return nil
+	return b.String(), nil
 }
@@ -3,6 +3,11 @@ package gothic_test
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"io/ioutil"
@@ -11,12 +16,17 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/sessions"
 	"github.com/markbates/goth"
 	. "github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/internal/testidp"
 	"github.com/markbates/goth/providers/faux"
+	"github.com/markbates/goth/providers/github"
+	"github.com/markbates/goth/providers/openidConnect"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
 )
 
 type mapKey struct {
@@ -78,10 +88,15 @@ func Test_BeginAuthHandler(t *testing.T) {
 		t.Fatalf("error getting faux Gothic session: %v", err)
 	}
 
-	sessStr, ok := sess.Values["faux"].(string)
-	if !ok {
-		t.Fatalf("Gothic session not stored as marshalled string; was %T (value %v)",
-			sess.Values["faux"], sess.Values["faux"])
+	// the provider session is stored under a key derived from the state
+	var sessStr string
+	for k, v := range sess.Values {
+		if name, _ := k.(string); strings.HasPrefix(name, "auth:faux:") {
+			sessStr, _ = v.(string)
+		}
+	}
+	if sessStr == "" {
+		t.Fatalf("Gothic session not stored as marshalled string; values were %v", sess.Values)
 	}
 	gothSession, err := fauxProvider.UnmarshalSession(ungzipString(sessStr))
 	if err != nil {
@@ -94,6 +109,136 @@ func Test_BeginAuthHandler(t *testing.T) {
 		fmt.Sprintf(`<a href="%s">Temporary Redirect</a>`, html.EscapeString(au)))
 }
 
+func Test_BeginAuthHandlerError(t *testing.T) {
+	a := assert.New(t)
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=%3Cscript%3Ealert(1)%3C%2Fscript%3E", nil)
+	a.NoError(err)
+
+	BeginAuthHandler(res, req)
+	a.Equal(http.StatusBadRequest, res.Code)
+	a.NotContains(res.Body.String(), "<script>")
+
+	defer func(h func(http.ResponseWriter, *http.Request, error)) { ErrorHandler = h }(ErrorHandler)
+	var handled error
+	ErrorHandler = func(res http.ResponseWriter, req *http.Request, err error) {
+		handled = err
+		res.WriteHeader(http.StatusTeapot)
+	}
+
+	res = httptest.NewRecorder()
+	BeginAuthHandler(res, req)
+	a.Equal(http.StatusTeapot, res.Code)
+	a.Error(handled)
+}
+
+func Test_GetAuthURLForwardsParams(t *testing.T) {
+	a := assert.New(t)
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux&login_hint=homer%40example.com&prompt=consent&redirect_uri=http%3A%2F%2Fevil.com&foo=bar&shop=acme", nil)
+	a.NoError(err)
+
+	authURL, err := GetAuthURL(res, req)
+	a.NoError(err)
+
+	u, err := url.Parse(authURL)
+	a.NoError(err)
+	a.Equal("homer@example.com", u.Query().Get("login_hint"))
+	a.Equal("consent", u.Query().Get("prompt"))
+	a.Empty(u.Query().Get("redirect_uri"))
+	a.Empty(u.Query().Get("foo"))
+	a.Empty(u.Query().Get("shop"))
+
+	// the parameters of a provider are only forwarded to it
+	goth.UseProviders(&shopProvider{})
+
+	req, err = http.NewRequest("GET", "/auth?provider=faux-shop&prompt=consent&shop=acme", nil)
+	a.NoError(err)
+	authURL, err = GetAuthURL(httptest.NewRecorder(), req)
+	a.NoError(err)
+
+	u, err = url.Parse(authURL)
+	a.NoError(err)
+	a.Equal("consent", u.Query().Get("prompt"))
+	a.Equal("acme", u.Query().Get("shop"))
+}
+
+// shopProvider takes a shop parameter, like Shopify.
+type shopProvider struct {
+	faux.Provider
+}
+
+func (p *shopProvider) Name() string {
+	return "faux-shop"
+}
+
+func (p *shopProvider) ForwardedAuthURLParams() []string {
+	return []string{"shop"}
+}
+
+func Test_GetAuthURLJSON(t *testing.T) {
+	a := assert.New(t)
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux&state=state_REAL", nil)
+	a.NoError(err)
+	req.Header.Set("Accept", "application/json")
+
+	BeginAuthHandler(res, req)
+
+	a.Equal(http.StatusOK, res.Code)
+	a.Equal("application/json", res.Header().Get("Content-Type"))
+	body := map[string]string{}
+	a.NoError(json.NewDecoder(res.Body).Decode(&body))
+	a.Equal("state_REAL", body["state"])
+	a.Contains(body["url"], "http://example.com/auth")
+
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/auth?provider=unknown", nil)
+	a.NoError(err)
+	GetAuthURLJSON(res, req)
+	a.Equal(http.StatusBadRequest, res.Code)
+	a.Contains(res.Body.String(), `"error":"invalid_request"`)
+	a.NotContains(res.Body.String(), "unknown")
+}
+
+func Test_CompleteUserAuthJSON(t *testing.T) {
+	a := assert.New(t)
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+
+	sess := faux.Session{Name: "Homer Simpson", Email: "homer@example.com"}
+	session, _ := Store.Get(req, SessionName)
+	session.Values["faux"] = gzipString(sess.Marshal())
+	a.NoError(session.Save(req, res))
+
+	CompleteUserAuthJSON(res, req)
+	a.Equal(http.StatusOK, res.Code)
+	user := goth.User{}
+	a.NoError(json.NewDecoder(res.Body).Decode(&user))
+	a.Equal("homer@example.com", user.Email)
+	a.Empty(user.AccessToken)
+
+	// the session was cleared by the previous call
+	res = httptest.NewRecorder()
+	CompleteUserAuthJSON(res, req)
+	a.Equal(http.StatusUnauthorized, res.Code)
+	a.Contains(res.Body.String(), `"error":"authentication_failed"`)
+	a.Contains(res.Body.String(), `"message":"authentication failed"`)
+
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/auth/callback", nil)
+	a.NoError(err)
+	CompleteUserAuthJSON(res, req)
+	a.Equal(http.StatusBadRequest, res.Code)
+	a.Contains(res.Body.String(), `"error":"invalid_request"`)
+	a.NotContains(res.Body.String(), "provider")
+}
+
 func Test_GetAuthURL(t *testing.T) {
 	a := assert.New(t)
 
@@ -145,6 +290,190 @@ func Test_CompleteUserAuth(t *testing.T) {
 	a.Equal(user.Email, "homer@example.com")
 }
 
+func Test_CompleteUserAuthValidatesUser(t *testing.T) {
+	a := assert.New(t)
+	goth.AddUserValidator(goth.AllowEmailDomains("springfield.com"))
+	defer goth.ClearUserValidators()
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+
+	sess := faux.Session{Name: "Homer Simpson", Email: "homer@example.com"}
+	session, _ := Store.Get(req, SessionName)
+	session.Values["faux"] = gzipString(sess.Marshal())
+	a.NoError(session.Save(req, res))
+
+	user, err := CompleteUserAuth(res, req)
+	a.ErrorIs(err, goth.ErrUserNotAllowed)
+	a.Empty(user.Email)
+}
+
+func Test_AuthHooks(t *testing.T) {
+	a := assert.New(t)
+	defer ClearAuthHooks()
+
+	var succeeded []string
+	var failed []error
+	OnAuthSuccess(func(req *http.Request, providerName string, user goth.User) {
+		succeeded = append(succeeded, providerName+":"+user.Email)
+	})
+	OnAuthFailure(func(req *http.Request, providerName string, err error) {
+		a.Equal("faux", providerName)
+		failed = append(failed, err)
+	})
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+
+	sess := faux.Session{Name: "Homer Simpson", Email: "homer@example.com"}
+	session, _ := Store.Get(req, SessionName)
+	session.Values["faux"] = gzipString(sess.Marshal())
+	a.NoError(session.Save(req, res))
+
+	_, err = CompleteUserAuth(res, req)
+	a.NoError(err)
+	a.Equal([]string{"faux:homer@example.com"}, succeeded)
+	a.Empty(failed)
+
+	// the session was cleared by the first call
+	req, err = http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+	_, err = CompleteUserAuth(httptest.NewRecorder(), req)
+	a.Error(err)
+	a.Len(succeeded, 1)
+	a.Equal([]error{err}, failed)
+}
+
+// funnelMetrics records the authentication processes reported to it.
+type funnelMetrics struct {
+	begun     []string
+	completed []string
+}
+
+func (m *funnelMetrics) AuthBegun(provider string) {
+	m.begun = append(m.begun, provider)
+}
+
+func (m *funnelMetrics) AuthCompleted(provider, outcome string, duration time.Duration) {
+	m.completed = append(m.completed, provider+":"+outcome)
+}
+
+func (m *funnelMetrics) ProviderRequest(host string, status int, duration time.Duration) {}
+
+func Test_Metrics(t *testing.T) {
+	a := assert.New(t)
+
+	m := &funnelMetrics{}
+	goth.SetMetrics(m)
+	defer goth.SetMetrics(nil)
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux", nil)
+	a.NoError(err)
+	BeginAuthHandler(res, req)
+	a.Equal([]string{"faux"}, m.begun)
+
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+	sess := faux.Session{Name: "Homer Simpson", Email: "homer@example.com"}
+	a.NoError(StoreInSession("faux", sess.Marshal(), req, res))
+	_, err = CompleteUserAuth(res, req)
+	a.NoError(err)
+
+	// the session was cleared by the previous call
+	req, err = http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+	_, err = CompleteUserAuth(httptest.NewRecorder(), req)
+	a.Error(err)
+
+	// the names of unregistered providers, chosen by the client, aren't reported
+	req, err = http.NewRequest("GET", "/auth/callback?provider=random-1234", nil)
+	a.NoError(err)
+	_, err = CompleteUserAuth(httptest.NewRecorder(), req)
+	a.Error(err)
+
+	a.Equal([]string{
+		"faux:" + goth.OutcomeSuccess,
+		"faux:" + goth.OutcomeFailure,
+		goth.UnknownProvider + ":" + goth.OutcomeFailure,
+	}, m.completed)
+}
+
+func Test_RoundTripWithTestIdP(t *testing.T) {
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+	idp.Claims["email"] = "homer@example.com"
+
+	provider, err := openidConnect.New("id", "secret", "http://localhost/auth/callback?provider=openid-connect", idp.DiscoveryURL())
+	a.NoError(err)
+	goth.UseProviders(provider)
+
+	defer func(s sessions.Store) { Store = s }(Store)
+	Store = sessions.NewCookieStore([]byte("secret"))
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=openid-connect", nil)
+	a.NoError(err)
+	BeginAuthHandler(res, req)
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+
+	callback, err := idp.Authorize(res.Header().Get("Location"))
+	a.NoError(err)
+
+	req, err = http.NewRequest("GET", callback.String(), nil)
+	a.NoError(err)
+	for _, c := range res.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	user, err := CompleteUserAuth(httptest.NewRecorder(), req)
+	a.NoError(err)
+	a.Equal("user", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+}
+
+func Test_WithProviderInstance(t *testing.T) {
+	a := assert.New(t)
+
+	idp := testidp.New("tenant-id", "tenant-secret")
+	defer idp.Close()
+
+	// the instance is only known to the request, not registered with goth
+	provider, err := openidConnect.NewNamed("tenant", "tenant-id", "tenant-secret", "http://localhost/auth/callback", idp.DiscoveryURL())
+	a.NoError(err)
+	_, err = goth.GetProvider("tenant-oidc")
+	a.Error(err)
+
+	defer func(s sessions.Store) { Store = s }(Store)
+	Store = sessions.NewCookieStore([]byte("secret"))
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth", nil)
+	a.NoError(err)
+	BeginAuthHandler(res, WithProviderInstance(req, provider))
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+	a.Contains(res.Header().Get("Location"), "client_id=tenant-id")
+
+	callback, err := idp.Authorize(res.Header().Get("Location"))
+	a.NoError(err)
+
+	req, err = http.NewRequest("GET", callback.String(), nil)
+	a.NoError(err)
+	for _, c := range res.Result().Cookies() {
+		req.AddCookie(c)
+	}
+
+	user, err := CompleteUserAuth(httptest.NewRecorder(), WithProviderInstance(req, provider))
+	a.NoError(err)
+	a.Equal("tenant-oidc", user.Provider)
+	a.Equal("user", user.UserID)
+}
+
 func Test_CompleteUserAuthWithSessionDeducedProvider(t *testing.T) {
 	a := assert.New(t)
 
@@ -213,68 +542,1287 @@ func Test_Logout(t *testing.T) {
 	a.Equal(session.Options.MaxAge, -1)
 }
 
-func Test_SetState(t *testing.T) {
+func Test_LogoutWithProvider(t *testing.T) {
 	a := assert.New(t)
 
-	req, _ := http.NewRequest("GET", "/auth?state=state", nil)
-	a.Equal(SetState(req), "state")
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/logout?provider=faux", nil)
+	a.NoError(err)
+
+	err = LogoutWithProvider(res, req, "id-token", "http://localhost/bye")
+	a.NoError(err)
+	a.Equal(http.StatusFound, res.Code)
+	a.Equal("http://example.com/logout?id_token_hint=id-token&post_logout_redirect_uri=http%3A%2F%2Flocalhost%2Fbye", res.Header().Get("Location"))
 }
 
-func Test_GetState(t *testing.T) {
+func Test_LogoutAndRevoke(t *testing.T) {
 	a := assert.New(t)
 
-	req, _ := http.NewRequest("GET", "/auth?state=state", nil)
-	a.Equal(GetState(req), "state")
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/logout?provider=faux", nil)
+	a.NoError(err)
+
+	err = LogoutAndRevoke(res, req, "1234567890")
+	a.NoError(err)
+	session, _ := Store.Get(req, SessionName)
+	a.Equal(session.Options.MaxAge, -1)
 }
 
-func Test_StateValidation(t *testing.T) {
+type mapStorage map[string]string
+
+func (m mapStorage) Get(req *http.Request, key string) (string, error) {
+	v, ok := m[key]
+	if !ok {
+		return "", fmt.Errorf("no value for %s", key)
+	}
+	return v, nil
+}
+
+func (m mapStorage) Set(req *http.Request, res http.ResponseWriter, key, value string) error {
+	m[key] = value
+	return nil
+}
+
+func (m mapStorage) Delete(req *http.Request, res http.ResponseWriter, key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m mapStorage) Clear(req *http.Request, res http.ResponseWriter) error {
+	for k := range m {
+		delete(m, k)
+	}
+	return nil
+}
+
+func Test_MigratingStorage(t *testing.T) {
 	a := assert.New(t)
 
-	Store = NewProviderStore()
+	from := mapStorage{"faux": "old session", "other": "value"}
+	to := mapStorage{}
+	Storage = MigratingStorage{From: from, To: to}
+	defer func() { Storage = GorillaStorage{} }()
+
+	req, _ := http.NewRequest("GET", "/", nil)
 	res := httptest.NewRecorder()
-	req, err := http.NewRequest("GET", "/auth?provider=faux&state=state_REAL", nil)
+
+	value, err := Storage.Get(req, "faux")
 	a.NoError(err)
+	a.Equal("old session", value)
 
-	BeginAuthHandler(res, req)
-	session, _ := Store.Get(req, SessionName)
+	a.NoError(Storage.Set(req, res, "faux", "new session"))
+	a.Equal(mapStorage{"faux": "new session"}, to)
+	a.Equal(mapStorage{"other": "value"}, from)
 
-	// Assert that matching states will return a nil error
-	req, _ = http.NewRequest("GET", "/auth/callback?provider=faux&state=state_REAL", nil)
-	session.Save(req, res)
-	_, err = CompleteUserAuth(res, req)
+	_, err = Storage.Get(req, "missing")
+	a.Error(err)
+
+	a.NoError(Storage.Clear(req, res))
+	a.Empty(from)
+	a.Empty(to)
+}
+
+func Test_MigrateSession(t *testing.T) {
+	a := assert.New(t)
+
+	from := mapStorage{"faux": "session", "user:faux": "user", "custom": "value"}
+	to := mapStorage{}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	res := httptest.NewRecorder()
+	a.NoError(MigrateSession(req, res, from, to))
+	a.Equal(mapStorage{"faux": "session", "user:faux": "user"}, to)
+	a.Equal(mapStorage{"custom": "value"}, from)
+
+	a.NoError(MigrateSession(req, res, from, to, "custom"))
+	a.Empty(from)
+	a.Equal("value", to["custom"])
+}
+
+func Test_GorillaStorageName(t *testing.T) {
+	a := assert.New(t)
+
+	store := sessions.NewCookieStore([]byte("secret"))
+	storage := GorillaStorage{Store: store, Name: "_custom_session"}
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	res := httptest.NewRecorder()
+	a.NoError(storage.Set(req, res, "faux", "value"))
+	a.Contains(res.Header().Get("Set-Cookie"), "_custom_session=")
+
+	value, err := storage.Get(withCookies(req, res), "faux")
 	a.NoError(err)
+	a.Equal("value", value)
+}
 
-	// Assert that mismatched states will return an error
-	req, _ = http.NewRequest("GET", "/auth/callback?provider=faux&state=state_FAKE", nil)
-	session.Save(req, res)
+func Test_CustomStorage(t *testing.T) {
+	a := assert.New(t)
+
+	storage := mapStorage{}
+	Storage = storage
+	defer func() { Storage = GorillaStorage{} }()
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux", nil)
+	a.NoError(err)
+
+	u, err := GetAuthURL(res, req)
+	a.NoError(err)
+	// the provider session and the list of the processes in progress
+	a.Len(storage, 2)
+
+	a.NoError(Logout(res, req))
+	a.Empty(storage)
+
+	parsed, err := url.Parse(u)
+	a.NoError(err)
+	req, err = http.NewRequest("GET", "/auth/callback?provider=faux&state="+url.QueryEscape(parsed.Query().Get("state")), nil)
+	a.NoError(err)
 	_, err = CompleteUserAuth(res, req)
 	a.Error(err)
 }
 
-func Test_AppleStateValidation(t *testing.T) {
+func Test_ConcurrentAuthAttempts(t *testing.T) {
 	a := assert.New(t)
-	appleStateValue := "xyz123-#"
-	form := url.Values{}
-	form.Add("state", appleStateValue)
-	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
-	req.Form = form
-	a.Equal(appleStateValue, GetState(req))
-}
 
-func gzipString(value string) string {
-	var b bytes.Buffer
-	gz := gzip.NewWriter(&b)
-	if _, err := gz.Write([]byte(value)); err != nil {
-		return "err"
-	}
-	if err := gz.Flush(); err != nil {
-		return "err"
+	defer func(s sessions.Store, max int) {
+		Store = s
+		MaxAuthAttempts = max
+	}(Store, MaxAuthAttempts)
+	Store = sessions.NewCookieStore([]byte("secret"))
+
+	// begin returns the request of a browser which started an authentication
+	// with the given state in another tab
+	begin := func(req *http.Request, state string) *http.Request {
+		res := httptest.NewRecorder()
+		next, err := http.NewRequest("GET", "/auth?provider=faux&state="+state, nil)
+		a.NoError(err)
+		for _, c := range req.Cookies() {
+			next.AddCookie(c)
+		}
+		_, err = GetAuthURL(res, next)
+		a.NoError(err)
+		return withCookies(next, res)
 	}
-	if err := gz.Close(); err != nil {
-		return "err"
+	complete := func(req *http.Request, state string) (*http.Request, error) {
+		res := httptest.NewRecorder()
+		callback, _ := http.NewRequest("GET", "/auth/callback?state="+state, nil)
+		for _, c := range req.Cookies() {
+			callback.AddCookie(c)
+		}
+		_, err := CompleteUserAuth(res, callback)
+		return withCookies(callback, res), err
 	}
 
-	return b.String()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req = begin(begin(req, "first"), "second")
+
+	// the callbacks come back in any order, and find their provider by state
+	req, err := complete(req, "second")
+	a.NoError(err)
+	req, err = complete(req, "first")
+	a.NoError(err)
+	_, err = complete(req, "first")
+	a.Error(err, "a completed process can't be completed again")
+
+	// beyond MaxAuthAttempts, the oldest process is discarded
+	MaxAuthAttempts = 1
+	req = begin(begin(req, "first"), "second")
+	_, err = complete(req, "first")
+	a.Error(err)
+	_, err = complete(req, "second")
+	a.NoError(err)
+}
+
+func Test_Codec(t *testing.T) {
+	a := assert.New(t)
+
+	storage := mapStorage{}
+	Storage = storage
+	defer func() { Storage = GorillaStorage{} }()
+
+	oldKey := bytes.Repeat([]byte("o"), 32)
+	codec, err := NewAESGCMCodec(oldKey)
+	a.NoError(err)
+	Codec = codec
+	defer func() { Codec = nil }()
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux", nil)
+	a.NoError(err)
+
+	a.NoError(StoreInSession("faux", `{"AccessToken":"1234567890"}`, req, res))
+	_, err = gzip.NewReader(strings.NewReader(storage["faux"]))
+	a.Error(err, "the stored value must be encrypted")
+
+	value, err := GetFromSession("faux", req)
+	a.NoError(err)
+	a.Equal(`{"AccessToken":"1234567890"}`, value)
+
+	// values encrypted with a rotated key can still be read
+	Codec, err = NewAESGCMCodec(bytes.Repeat([]byte("n"), 32), oldKey)
+	a.NoError(err)
+	value, err = GetFromSession("faux", req)
+	a.NoError(err)
+	a.Equal(`{"AccessToken":"1234567890"}`, value)
+
+	// tampered values are rejected
+	tampered := []byte(storage["faux"])
+	tampered[len(tampered)-1] ^= 1
+	storage["faux"] = string(tampered)
+	_, err = GetFromSession("faux", req)
+	a.Error(err)
+
+	_, err = NewAESGCMCodec([]byte("short"))
+	a.Error(err)
+}
+
+func Test_CompleteUserAuthWithIDToken(t *testing.T) {
+	a := assert.New(t)
+
+	site := "cross-site"
+	post := func(form url.Values, csrfCookie string) (goth.User, error) {
+		req, err := http.NewRequest("POST", "/auth/onetap?provider=faux", strings.NewReader(form.Encode()))
+		a.NoError(err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if site != "" {
+			req.Header.Set("Sec-Fetch-Site", site)
+		}
+		if csrfCookie != "" {
+			req.AddCookie(&http.Cookie{Name: "g_csrf_token", Value: csrfCookie})
+		}
+		return CompleteUserAuthWithIDToken(httptest.NewRecorder(), req)
+	}
+
+	user, err := post(url.Values{"credential": {"token"}, "g_csrf_token": {"csrf"}}, "csrf")
+	a.NoError(err)
+	a.Equal("token", user.UserID)
+	a.Equal("faux", user.Provider)
+
+	user, err = post(url.Values{"id_token": {"token"}, "g_csrf_token": {"csrf"}}, "csrf")
+	a.NoError(err)
+	a.Equal("token", user.UserID)
+
+	_, err = post(url.Values{"credential": {"token"}, "g_csrf_token": {"csrf"}}, "other")
+	a.ErrorIs(err, ErrCSRFTokenMismatch)
+
+	_, err = post(url.Values{"credential": {"token"}}, "csrf")
+	a.ErrorIs(err, ErrCSRFTokenMismatch)
+
+	_, err = post(url.Values{"credential": {"token"}, "g_csrf_token": {"csrf"}}, "")
+	a.ErrorIs(err, ErrCSRFTokenMismatch)
+
+	// a cross-site form posting the attacker's token carries neither
+	_, err = post(url.Values{"credential": {"token"}}, "")
+	a.ErrorIs(err, ErrCSRFTokenMismatch)
+
+	_, err = post(url.Values{"credential": {"invalid"}, "g_csrf_token": {"csrf"}}, "csrf")
+	a.Error(err)
+
+	_, err = post(url.Values{"g_csrf_token": {"csrf"}}, "csrf")
+	a.Error(err)
+
+	// an id_token posted without g_csrf_token, e.g. after a Firebase sign in,
+	// is only refused when posted from another site
+	_, err = post(url.Values{"id_token": {"token"}}, "")
+	a.ErrorIs(err, ErrCrossSiteIDToken)
+	_, err = post(url.Values{"id_token": {"token"}, "g_csrf_token": {"csrf"}}, "other")
+	a.ErrorIs(err, ErrCSRFTokenMismatch)
+
+	site = "same-origin"
+	user, err = post(url.Values{"id_token": {"token"}}, "")
+	a.NoError(err)
+	a.Equal("token", user.UserID)
+	_, err = post(url.Values{"credential": {"token"}}, "")
+	a.ErrorIs(err, ErrCSRFTokenMismatch)
+
+	// native clients don't send Sec-Fetch-Site
+	site = ""
+	user, err = post(url.Values{"id_token": {"token"}}, "")
+	a.NoError(err)
+	a.Equal("token", user.UserID)
+}
+
+func Test_UserFromBearerToken(t *testing.T) {
+	a := assert.New(t)
+
+	get := func(authorization string) (goth.User, error) {
+		req, err := http.NewRequest("GET", "/api/me?provider=faux", nil)
+		a.NoError(err)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		return UserFromBearerToken(req)
+	}
+
+	user, err := get("Bearer token")
+	a.NoError(err)
+	a.Equal("token", user.UserID)
+	a.Equal("faux", user.Provider)
+
+	user, err = get("bearer token")
+	a.NoError(err)
+	a.Equal("token", user.UserID)
+
+	_, err = get("")
+	a.ErrorIs(err, ErrNoBearerToken)
+
+	_, err = get("Basic dXNlcjpwYXNz")
+	a.ErrorIs(err, ErrNoBearerToken)
+
+	_, err = get("Bearer invalid")
+	a.Error(err)
+
+	_, err = get("Bearer foreign")
+	a.ErrorIs(err, goth.ErrTokenAudience)
+}
+
+func Test_GorillaStorageChunksLargeValues(t *testing.T) {
+	a := assert.New(t)
+
+	defer func(s sessions.Store) { Store = s }(Store)
+	Store = sessions.NewCookieStore([]byte("secret"), bytes.Repeat([]byte("k"), 32))
+
+	// random data doesn't compress, so the stored value stays this large
+	large := make([]byte, 10000)
+	_, err := rand.Read(large)
+	a.NoError(err)
+	value := base64.StdEncoding.EncodeToString(large)
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+	a.NoError(StoreInSession("faux", value, req, res))
+
+	cookies := res.Result().Cookies()
+	a.Greater(len(cookies), 1)
+	for _, c := range cookies {
+		a.Less(len(c.String()), 4096)
+	}
+
+	req, err = http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	stored, err := GetFromSession("faux", req)
+	a.NoError(err)
+	a.Equal(value, stored)
+
+	// a smaller value replaces the split one
+	res = httptest.NewRecorder()
+	a.NoError(StoreInSession("faux", "small", req, res))
+	stored, err = GetFromSession("faux", withCookies(req, res))
+	a.NoError(err)
+	a.Equal("small", stored)
+
+	res = httptest.NewRecorder()
+	a.NoError(Logout(res, req))
+	_, err = GetFromSession("faux", withCookies(req, res))
+	a.Error(err)
+}
+
+func Test_FormPostSession(t *testing.T) {
+	a := assert.New(t)
+
+	defer func(s sessions.Store, params []string) {
+		Store = s
+		ForwardedAuthURLParams = params
+	}(Store, ForwardedAuthURLParams)
+	Store = sessions.NewCookieStore([]byte("secret"))
+	ForwardedAuthURLParams = append(ForwardedAuthURLParams, "response_mode")
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux&state=abc&response_mode=form_post", nil)
+	a.NoError(err)
+	_, err = GetAuthURL(res, req)
+	a.NoError(err)
+
+	var formPost *http.Cookie
+	for _, c := range res.Result().Cookies() {
+		if c.Name == FormPostSessionName(req) {
+			formPost = c
+		}
+	}
+	if !a.NotNil(formPost) {
+		return
+	}
+	a.True(formPost.Secure)
+	a.True(formPost.HttpOnly)
+	a.Equal(http.SameSiteNoneMode, formPost.SameSite)
+	a.Equal(FormPostMaxAge, formPost.MaxAge)
+
+	// the identity provider POSTs the callback cross-site, so only the
+	// SameSite=None cookie comes along
+	form := url.Values{"state": {"abc"}, "code": {"code"}}
+	req, err = http.NewRequest("POST", "/auth/callback?provider=faux", strings.NewReader(form.Encode()))
+	a.NoError(err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(formPost)
+
+	res = httptest.NewRecorder()
+	user, err := CompleteUserAuth(res, req)
+	a.NoError(err)
+	a.Equal("id", user.UserID)
+
+	expired := false
+	for _, c := range res.Result().Cookies() {
+		if c.Name == FormPostSessionName(req) {
+			expired = c.MaxAge < 0
+		}
+	}
+	a.True(expired)
+
+	// without form_post no extra cookie is set
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/auth?provider=faux&state=abc", nil)
+	a.NoError(err)
+	_, err = GetAuthURL(res, req)
+	a.NoError(err)
+	for _, c := range res.Result().Cookies() {
+		a.NotEqual(FormPostSessionName(req), c.Name)
+	}
+
+	// the name follows the one of the regular session
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/auth?provider=faux&state=abc&response_mode=form_post", nil)
+	a.NoError(err)
+	req = RequestWithSessionName(req, "_app_session")
+	a.Equal("_app_session_form_post", FormPostSessionName(req))
+	_, err = GetAuthURL(res, req)
+	a.NoError(err)
+	var names []string
+	for _, c := range res.Result().Cookies() {
+		names = append(names, c.Name)
+	}
+	a.Contains(names, "_app_session_form_post")
+	a.NotContains(names, SessionName+"_form_post")
+}
+
+func Test_SetSessionOptions(t *testing.T) {
+	a := assert.New(t)
+
+	defer func(s sessions.Store) {
+		Store = s
+		SetSessionOptions(SessionOptions{})
+	}(Store)
+	Store = sessions.NewCookieStore([]byte("secret"))
+	SetSessionOptions(SessionOptions{
+		Path:     "/auth",
+		Domain:   "example.com",
+		MaxAge:   600,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux", nil)
+	a.NoError(err)
+	a.NoError(StoreInSession("faux", "value", req, res))
+
+	cookies := res.Result().Cookies()
+	if !a.Len(cookies, 1) {
+		return
+	}
+	c := cookies[0]
+	a.Equal(SessionName, c.Name)
+	a.Equal("/auth", c.Path)
+	a.Equal("example.com", c.Domain)
+	a.Equal(600, c.MaxAge)
+	a.True(c.Secure)
+	a.True(c.HttpOnly)
+	a.Equal(http.SameSiteStrictMode, c.SameSite)
+
+	value, err := GetFromSession("faux", withCookies(req, res))
+	a.NoError(err)
+	a.Equal("value", value)
+}
+
+func Test_KeepUserInSession(t *testing.T) {
+	a := assert.New(t)
+
+	defer func(s sessions.Store) {
+		Store = s
+		KeepUserInSession = false
+	}(Store)
+	Store = sessions.NewCookieStore([]byte("secret"))
+	KeepUserInSession = true
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux&state=abc", nil)
+	a.NoError(err)
+	_, err = GetAuthURL(res, req)
+	a.NoError(err)
+
+	req, err = http.NewRequest("GET", "/auth/callback?provider=faux&state=abc", nil)
+	a.NoError(err)
+	req = withCookies(req, res)
+	a.False(IsAuthenticated(req, "faux"))
+
+	res = httptest.NewRecorder()
+	user, err := CompleteUserAuth(res, req)
+	a.NoError(err)
+	a.Equal("id", user.UserID)
+
+	req = withCookies(req, res)
+	a.True(IsAuthenticated(req, "faux"))
+	a.False(IsAuthenticated(req, "google"))
+	kept, err := GetUserFromSession(req, "faux")
+	a.NoError(err)
+	a.Equal(user, kept)
+
+	// the provider session itself is gone
+	_, err = GetFromSession("faux", req)
+	a.Error(err)
+
+	res = httptest.NewRecorder()
+	a.NoError(Logout(res, req))
+	a.False(IsAuthenticated(withCookies(req, res), "faux"))
+}
+
+// withCookies returns a copy of req carrying the cookies set on res. Like
+// browsers, it keeps the last of several cookies set with the same name.
+func Test_GorillaStorageBoundsEachCookie(t *testing.T) {
+	a := assert.New(t)
+
+	store := sessions.NewCookieStore([]byte("secret"), bytes.Repeat([]byte("k"), 32))
+	storage := GorillaStorage{Store: store}
+
+	// several concurrent attempts, each holding a provider session just
+	// below the chunk size, along with small values
+	values := map[string]string{"user:faux": "homer", "linked:faux": "marge"}
+	for i := 0; i < 5; i++ {
+		large := make([]byte, 1400)
+		_, err := rand.Read(large)
+		a.NoError(err)
+		values[fmt.Sprintf("attempt:%d", i)] = base64.StdEncoding.EncodeToString(large)
+	}
+
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+	for key, value := range values {
+		res := httptest.NewRecorder()
+		a.NoError(storage.Set(req, res, key, value))
+		req = withCookies(req, res)
+	}
+
+	for _, c := range req.Cookies() {
+		a.Less(len(c.String()), 4096, c.Name)
+	}
+	for key, value := range values {
+		stored, err := storage.Get(req, key)
+		a.NoError(err)
+		a.Equal(value, stored, key)
+	}
+
+	res := httptest.NewRecorder()
+	a.NoError(storage.Delete(req, res, "attempt:0"))
+	req = withCookies(req, res)
+	_, err = storage.Get(req, "attempt:0")
+	a.Error(err)
+	stored, err := storage.Get(req, "attempt:1")
+	a.NoError(err)
+	a.Equal(values["attempt:1"], stored)
+}
+
+// jsonStore is a sessions.Store serializing the values of its sessions as
+// JSON, which reads numbers back as float64.
+type jsonStore struct {
+	data map[string][]byte
+}
+
+func (s *jsonStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return s.New(r, name)
+}
+
+func (s *jsonStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	session.Options = &sessions.Options{Path: "/"}
+	values := map[string]interface{}{}
+	if data, ok := s.data[name]; ok {
+		if err := json.Unmarshal(data, &values); err != nil {
+			return session, err
+		}
+		session.IsNew = false
+	}
+	for k, v := range values {
+		session.Values[k] = v
+	}
+	return session, nil
+}
+
+func (s *jsonStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	values := map[string]interface{}{}
+	for k, v := range session.Values {
+		values[k.(string)] = v
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	s.data[session.Name()] = data
+	return nil
+}
+
+func Test_GorillaStorageWithJSONSerializer(t *testing.T) {
+	a := assert.New(t)
+
+	store := &jsonStore{data: map[string][]byte{}}
+	storage := GorillaStorage{Store: store, ChunkSize: 10}
+	req, err := http.NewRequest("GET", "/auth/callback?provider=faux", nil)
+	a.NoError(err)
+
+	value := strings.Repeat("0123456789", 5)
+	a.NoError(storage.Set(req, httptest.NewRecorder(), "faux", value))
+	stored, err := storage.Get(req, "faux")
+	a.NoError(err)
+	a.Equal(value, stored)
+
+	// a number of parts that can't be read is an error, not a truncated value
+	store.data[SessionName] = []byte(`{"faux#chunks":"five"}`)
+	_, err = storage.Get(req, "faux")
+	a.Error(err)
+}
+
+func withCookies(req *http.Request, res *httptest.ResponseRecorder) *http.Request {
+	next, _ := http.NewRequest(req.Method, req.URL.String(), nil)
+	set := map[string]*http.Cookie{}
+	var names []string
+	for _, c := range res.Result().Cookies() {
+		if _, ok := set[c.Name]; !ok {
+			names = append(names, c.Name)
+		}
+		set[c.Name] = c
+	}
+	for _, name := range names {
+		if c := set[name]; c.MaxAge >= 0 {
+			next.AddCookie(c)
+		}
+	}
+	for _, c := range req.Cookies() {
+		if _, ok := set[c.Name]; !ok {
+			next.AddCookie(c)
+		}
+	}
+	return next
+}
+
+func Test_Link(t *testing.T) {
+	a := assert.New(t)
+
+	storage := mapStorage{"faux": "existing session"}
+	Storage = storage
+	defer func() { Storage = GorillaStorage{} }()
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/link?provider=faux&state=state", nil)
+	a.NoError(err)
+
+	BeginLink(res, req)
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+
+	users, err := LinkedUsers(req)
+	a.NoError(err)
+	a.Empty(users)
+
+	user, err := CompleteLink(res, req)
+	a.NoError(err)
+	a.Equal("access", user.AccessToken)
+	a.Equal("existing session", storage["faux"])
+
+	users, err = LinkedUsers(req)
+	a.NoError(err)
+	a.Len(users, 1)
+	a.Equal("faux", users[0].Provider)
+	a.Equal("access", users[0].AccessToken)
+
+	a.NoError(Unlink(res, req, "faux"))
+	users, err = LinkedUsers(req)
+	a.NoError(err)
+	a.Empty(users)
+	a.Equal("existing session", storage["faux"])
+}
+
+func Test_LinkWithCookieStore(t *testing.T) {
+	defer func(s sessions.Store) { Store = s }(Store)
+	Store = sessions.NewCookieStore([]byte("secret"))
+	defer func() { Storage = GorillaStorage{} }()
+
+	t.Run("GorillaStorage", func(t *testing.T) {
+		Storage = GorillaStorage{}
+		req := testLinkWithCookies(t)
+
+		// the link can't be completed twice
+		_, err := CompleteLink(httptest.NewRecorder(), req)
+		assert.Error(t, err)
+	})
+	t.Run("unsharedStorage", func(t *testing.T) {
+		Storage = unsharedStorage{}
+		testLinkWithCookies(t)
+	})
+}
+
+func Test_LinkedUsersSurviveLogin(t *testing.T) {
+	a := assert.New(t)
+
+	defer func(s sessions.Store, providers goth.Providers) {
+		Store = s
+		KeepUserInSession = false
+		goth.ClearProviders()
+		for _, p := range providers {
+			goth.UseProviders(p)
+		}
+	}(Store, goth.GetProviders())
+	Store = sessions.NewCookieStore([]byte("secret"))
+	KeepUserInSession = true
+
+	// lazy providers can be linked too
+	goth.UseLazyProvider("faux-lazy", func() (goth.Provider, error) {
+		p := &faux.Provider{}
+		p.SetName("faux-lazy")
+		return p, nil
+	})
+
+	req, err := http.NewRequest("GET", "/link?provider=faux-lazy&state=link", nil)
+	a.NoError(err)
+	res := httptest.NewRecorder()
+	BeginLink(res, req)
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+	req = withCookies(req, res)
+	res = httptest.NewRecorder()
+	_, err = CompleteLink(res, req)
+	a.NoError(err)
+
+	// signing in, twice, keeps the linked user and the user signed in before
+	// visit returns a request to target carrying the cookies the browser keeps
+	visit := func(target string) *http.Request {
+		next, err := http.NewRequest("GET", target, nil)
+		a.NoError(err)
+		for _, c := range withCookies(req, res).Cookies() {
+			next.AddCookie(c)
+		}
+		res = httptest.NewRecorder()
+		return next
+	}
+	for _, state := range []string{"first", "second"} {
+		req = visit("/auth?provider=faux&state=" + state)
+		_, err = GetAuthURL(res, req)
+		a.NoError(err)
+		req = visit("/auth/callback?provider=faux&state=" + state)
+		_, err = CompleteUserAuth(res, req)
+		a.NoError(err)
+
+		req = visit("/")
+		a.True(IsAuthenticated(req, "faux"))
+		users, err := LinkedUsers(req)
+		a.NoError(err)
+		a.Len(users, 1)
+		a.Equal("faux-lazy", users[0].Provider)
+	}
+}
+
+func Test_LinkWithFormPost(t *testing.T) {
+	a := assert.New(t)
+
+	defer func(s sessions.Store, params []string) {
+		Store = s
+		ForwardedAuthURLParams = params
+	}(Store, ForwardedAuthURLParams)
+	Store = sessions.NewCookieStore([]byte("secret"))
+	ForwardedAuthURLParams = append(ForwardedAuthURLParams, "response_mode")
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/link?provider=faux&state=abc&response_mode=form_post", nil)
+	a.NoError(err)
+	BeginLink(res, req)
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+
+	var formPost *http.Cookie
+	for _, c := range res.Result().Cookies() {
+		if c.Name == FormPostSessionName(req) {
+			formPost = c
+		}
+	}
+	if !a.NotNil(formPost) {
+		return
+	}
+
+	// only the SameSite=None cookie comes along with the cross-site POST
+	form := url.Values{"state": {"abc"}, "code": {"code"}}
+	req, err = http.NewRequest("POST", "/link/callback?provider=faux", strings.NewReader(form.Encode()))
+	a.NoError(err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(formPost)
+
+	res = httptest.NewRecorder()
+	user, err := CompleteLink(res, req)
+	a.NoError(err)
+	a.Equal("id", user.UserID)
+}
+
+// unsharedStorage saves new instances of the session of the request on Set,
+// which don't see the values changed earlier while handling the request.
+type unsharedStorage struct{}
+
+func (unsharedStorage) Get(req *http.Request, key string) (string, error) {
+	return GorillaStorage{ChunkSize: -1}.Get(req, key)
+}
+
+func (unsharedStorage) Set(req *http.Request, res http.ResponseWriter, key, value string) error {
+	session, _ := Store.New(req, GetSessionName(req))
+	session.Values[key] = value
+	return session.Save(req, res)
+}
+
+func (unsharedStorage) Delete(req *http.Request, res http.ResponseWriter, key string) error {
+	return GorillaStorage{ChunkSize: -1}.Delete(req, res, key)
+}
+
+func (unsharedStorage) Clear(req *http.Request, res http.ResponseWriter) error {
+	return GorillaStorage{ChunkSize: -1}.Clear(req, res)
+}
+
+// testLinkWithCookies links faux to a visitor already signed in with it, and
+// returns a request carrying the cookies the browser would keep.
+func testLinkWithCookies(t *testing.T) *http.Request {
+	a := assert.New(t)
+
+	req, err := http.NewRequest("GET", "/link?provider=faux&state=state", nil)
+	a.NoError(err)
+	res := httptest.NewRecorder()
+	a.NoError(StoreInSession("faux", "existing session", req, res))
+
+	req = withCookies(req, res)
+	res = httptest.NewRecorder()
+	BeginLink(res, req)
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+
+	req = withCookies(req, res)
+	res = httptest.NewRecorder()
+	user, err := CompleteLink(res, req)
+	a.NoError(err)
+	a.Equal("access", user.AccessToken)
+
+	// the browser keeps the last cookie set for the session
+	req = withCookies(req, res)
+	users, err := LinkedUsers(req)
+	a.NoError(err)
+	a.Len(users, 1)
+	a.Equal("access", users[0].AccessToken)
+
+	value, err := GetFromSession("faux", req)
+	a.NoError(err)
+	a.Equal("existing session", value)
+	return req
+}
+
+type refreshingProvider struct {
+	faux.Provider
+}
+
+type refreshingSession struct {
+	faux.Session
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+func (p *refreshingProvider) Name() string {
+	return "refreshing"
+}
+
+func (p *refreshingProvider) RefreshTokenAvailable() bool {
+	return true
+}
+
+func (p *refreshingProvider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	if refreshToken == "revoked" {
+		return nil, &oauth2.RetrieveError{ErrorCode: "invalid_grant"}
+	}
+	return &oauth2.Token{AccessToken: "refreshed", Expiry: time.Now().Add(time.Hour)}, nil
+}
+
+func (p *refreshingProvider) UnmarshalSession(data string) (goth.Session, error) {
+	sess := &refreshingSession{}
+	err := json.Unmarshal([]byte(data), sess)
+	return sess, err
+}
+
+func (p *refreshingProvider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*refreshingSession)
+	if time.Now().After(sess.ExpiresAt) {
+		return goth.User{}, errors.New("token expired")
+	}
+	return goth.User{Provider: p.Name(), UserID: "homer", AccessToken: sess.AccessToken, RefreshToken: sess.RefreshToken, ExpiresAt: sess.ExpiresAt}, nil
+}
+
+func (s *refreshingSession) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s *refreshingSession) Token() *oauth2.Token {
+	return &oauth2.Token{AccessToken: s.AccessToken, RefreshToken: s.RefreshToken, Expiry: s.ExpiresAt}
+}
+
+func (s *refreshingSession) SetToken(token *oauth2.Token) {
+	s.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		s.RefreshToken = token.RefreshToken
+	}
+	s.ExpiresAt = token.Expiry
+}
+
+func Test_CompleteUserAuthRefreshesExpiredTokens(t *testing.T) {
+	a := assert.New(t)
+
+	goth.UseProviders(&refreshingProvider{})
+	Storage = mapStorage{}
+	defer func() { Storage = GorillaStorage{} }()
+
+	RefreshExpiredTokens = true
+	defer func() { RefreshExpiredTokens = false }()
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=refreshing", nil)
+	a.NoError(err)
+
+	sess := &refreshingSession{RefreshToken: "refresh", ExpiresAt: time.Now().Add(-time.Minute)}
+	sess.AccessToken = "expired"
+	a.NoError(StoreInSession("refreshing", sess.Marshal(), req, res))
+
+	user, err := CompleteUserAuth(res, req)
+	a.NoError(err)
+	a.Equal("refreshed", user.AccessToken)
+	a.Equal("refresh", user.RefreshToken)
+}
+
+func Test_OnGrantRevoked(t *testing.T) {
+	a := assert.New(t)
+
+	goth.UseProviders(&refreshingProvider{})
+	Storage = mapStorage{}
+	defer func() { Storage = GorillaStorage{} }()
+
+	RefreshExpiredTokens = true
+	defer func() { RefreshExpiredTokens = false }()
+
+	var revoked *goth.GrantRevokedError
+	OnGrantRevoked(func(req *http.Request, err *goth.GrantRevokedError) {
+		revoked = err
+	})
+	defer ClearAuthHooks()
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=refreshing", nil)
+	a.NoError(err)
+
+	sess := &refreshingSession{RefreshToken: "revoked", ExpiresAt: time.Now().Add(-time.Minute)}
+	sess.AccessToken = "expired"
+	a.NoError(StoreInSession("refreshing", sess.Marshal(), req, res))
+
+	_, err = CompleteUserAuth(res, req)
+	a.ErrorAs(err, &revoked)
+	a.NotNil(revoked)
+	a.Equal("refreshing", revoked.Provider)
+	a.Equal("revoked", revoked.RefreshToken)
+}
+
+func Test_TokenStore(t *testing.T) {
+	a := assert.New(t)
+
+	goth.UseProviders(&refreshingProvider{})
+	Storage = mapStorage{}
+	defer func() { Storage = GorillaStorage{} }()
+	TokenStore = goth.NewMemoryTokenStore()
+	defer func() { TokenStore = nil }()
+
+	ctx := context.Background()
+	a.NoError(TokenStore.Save(ctx, "refreshing", "homer", &oauth2.Token{AccessToken: "old", RefreshToken: "first"}))
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth/callback?provider=refreshing", nil)
+	a.NoError(err)
+	sess := &refreshingSession{ExpiresAt: time.Now().Add(time.Hour)}
+	sess.AccessToken = "access"
+	a.NoError(StoreInSession("refreshing", sess.Marshal(), req, res))
+
+	_, err = CompleteUserAuth(res, req)
+	a.NoError(err)
+	token, err := TokenStore.Load(ctx, "refreshing", "homer")
+	a.NoError(err)
+	a.Equal("access", token.AccessToken)
+	a.Equal("first", token.RefreshToken, "the stored refresh token must be kept")
+
+	token, err = StoredToken(req, "refreshing", "homer")
+	a.NoError(err)
+	a.Equal("access", token.AccessToken)
+
+	a.NoError(TokenStore.Save(ctx, "refreshing", "homer", &oauth2.Token{AccessToken: "expired", RefreshToken: "first", Expiry: time.Now().Add(-time.Minute)}))
+	token, err = StoredToken(req, "refreshing", "homer")
+	a.NoError(err)
+	a.Equal("refreshed", token.AccessToken)
+	a.Equal("first", token.RefreshToken)
+	token, err = TokenStore.Load(ctx, "refreshing", "homer")
+	a.NoError(err)
+	a.Equal("refreshed", token.AccessToken)
+
+	var revoked *goth.GrantRevokedError
+	OnGrantRevoked(func(req *http.Request, err *goth.GrantRevokedError) {
+		revoked = err
+	})
+	defer ClearAuthHooks()
+	a.NoError(TokenStore.Save(ctx, "refreshing", "homer", &oauth2.Token{AccessToken: "expired", RefreshToken: "revoked", Expiry: time.Now().Add(-time.Minute)}))
+	_, err = StoredToken(req, "refreshing", "homer")
+	a.ErrorAs(err, &revoked)
+	a.NotNil(revoked)
+	_, err = TokenStore.Load(ctx, "refreshing", "homer")
+	a.ErrorIs(err, goth.ErrTokenNotFound)
+}
+
+func Test_SessionName(t *testing.T) {
+	a := assert.New(t)
+
+	defer func(s sessions.Store) { Store = s }(Store)
+	Store = sessions.NewCookieStore([]byte("secret"))
+
+	req, err := http.NewRequest("GET", "/", nil)
+	a.NoError(err)
+	a.Equal(SessionName, GetSessionName(req))
+
+	res := httptest.NewRecorder()
+	a.NoError(StoreInSession("faux", "value", RequestWithSessionName(req, "_app_session"), res))
+	cookies := res.Result().Cookies()
+	a.Len(cookies, 1)
+	a.Equal("_app_session", cookies[0].Name)
+
+	req.AddCookie(cookies[0])
+	_, err = GetFromSession("faux", req)
+	a.Error(err, "the value must not be found in the default session")
+	value, err := GetFromSession("faux", RequestWithSessionName(req, "_app_session"))
+	a.NoError(err)
+	a.Equal("value", value)
+
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/sso/auth/faux", nil)
+	a.NoError(err)
+	Mount("/sso", WithSessionName("_sso_session")).ServeHTTP(res, req)
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+	cookies = res.Result().Cookies()
+	a.NotEmpty(cookies)
+	for _, c := range cookies {
+		a.Equal("_sso_session", c.Name)
+	}
+}
+
+func Test_Mount(t *testing.T) {
+	a := assert.New(t)
+
+	// the test ProviderStore keys sessions by request, which Mount rewrites
+	Storage = mapStorage{}
+	defer func() { Storage = GorillaStorage{} }()
+
+	var authenticated goth.User
+	handler := Mount("/sso", WithSuccessHandler(func(res http.ResponseWriter, req *http.Request, user goth.User) {
+		authenticated = user
+	}))
+
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/sso/auth/faux", nil)
+	a.NoError(err)
+	handler.ServeHTTP(res, req)
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+	a.Contains(res.Header().Get("Location"), "http://example.com/auth")
+
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/sso/auth/faux/callback", nil)
+	a.NoError(err)
+	sess := faux.Session{Name: "Homer Simpson", Email: "homer@example.com"}
+	a.NoError(StoreInSession("faux", sess.Marshal(), req, res))
+	handler.ServeHTTP(res, req)
+	a.Equal("Homer Simpson", authenticated.Name)
+
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/sso/auth/unknown/callback", nil)
+	a.NoError(err)
+	handler.ServeHTTP(res, req)
+	a.Equal(http.StatusUnauthorized, res.Code)
+
+	// the provider of the path wins over the query parameters
+	authenticated = goth.User{}
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/sso/auth/faux/callback?provider=unknown&:provider=unknown", nil)
+	a.NoError(err)
+	a.NoError(StoreInSession("faux", sess.Marshal(), req, res))
+	handler.ServeHTTP(res, req)
+	a.Equal("Homer Simpson", authenticated.Name)
+
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/sso/auth/unknown?provider=faux", nil)
+	a.NoError(err)
+	handler.ServeHTTP(res, req)
+	a.Equal(http.StatusUnauthorized, res.Code)
+
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/sso/logout/faux", nil)
+	a.NoError(err)
+	handler.ServeHTTP(res, req)
+	a.Equal(http.StatusTemporaryRedirect, res.Code)
+
+	res = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/other/auth/faux", nil)
+	a.NoError(err)
+	handler.ServeHTTP(res, req)
+	a.Equal(http.StatusNotFound, res.Code)
+}
+
+func Test_SetState(t *testing.T) {
+	a := assert.New(t)
+
+	req, _ := http.NewRequest("GET", "/auth?state=state", nil)
+	a.Equal(SetState(req), "state")
+}
+
+func Test_GetState(t *testing.T) {
+	a := assert.New(t)
+
+	req, _ := http.NewRequest("GET", "/auth?state=state", nil)
+	a.Equal(GetState(req), "state")
+}
+
+func Test_StateValidation(t *testing.T) {
+	a := assert.New(t)
+
+	Store = NewProviderStore()
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux&state=state_REAL", nil)
+	a.NoError(err)
+
+	BeginAuthHandler(res, req)
+	session, _ := Store.Get(req, SessionName)
+
+	// Assert that matching states will return a nil error
+	req, _ = http.NewRequest("GET", "/auth/callback?provider=faux&state=state_REAL", nil)
+	session.Save(req, res)
+	_, err = CompleteUserAuth(res, req)
+	a.NoError(err)
+
+	// Assert that mismatched states will return an error
+	req, _ = http.NewRequest("GET", "/auth?provider=faux&state=state_REAL", nil)
+	BeginAuthHandler(res, req)
+	session, _ = Store.Get(req, SessionName)
+	req, _ = http.NewRequest("GET", "/auth/callback?provider=faux&state=state_FAKE", nil)
+	session.Save(req, res)
+	_, err = CompleteUserAuth(res, req)
+	a.ErrorIs(err, ErrStateMismatch)
+}
+
+func Test_StateStore(t *testing.T) {
+	a := assert.New(t)
+
+	StateStore = goth.NewMemoryStateStore(0, 0)
+	defer func() { StateStore = nil }()
+
+	begin := func() (*sessions.Session, string) {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/auth?provider=faux&state=chosen_by_client", nil)
+		BeginAuthHandler(res, req)
+		a.Equal(http.StatusTemporaryRedirect, res.Code)
+		u, err := url.Parse(res.Header().Get("Location"))
+		a.NoError(err)
+		session, _ := Store.Get(req, SessionName)
+		return session, u.Query().Get("state")
+	}
+	callback := func(session *sessions.Session, state string) error {
+		res := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/auth/callback?provider=faux&state="+url.QueryEscape(state), nil)
+		session.Save(req, res)
+		_, err := CompleteUserAuth(res, req)
+		return err
+	}
+
+	Store = NewProviderStore()
+	session, state := begin()
+	a.NotEqual("chosen_by_client", state)
+	a.NoError(StateStore.Validate(context.Background(), "faux", state))
+	a.NoError(callback(session, state))
+	a.ErrorIs(StateStore.Validate(context.Background(), "faux", state), goth.ErrInvalidState)
+
+	// the state expired, or was used by another callback
+	session, state = begin()
+	a.NoError(StateStore.Consume(context.Background(), "faux", state))
+	a.ErrorIs(callback(session, state), ErrStateMismatch)
+}
+
+func Test_AppleStateValidation(t *testing.T) {
+	a := assert.New(t)
+	appleStateValue := "xyz123-#"
+	form := url.Values{}
+	form.Add("state", appleStateValue)
+	req, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Form = form
+	a.Equal(appleStateValue, GetState(req))
+}
+
+func Test_JSONCallbacks(t *testing.T) {
+	a := assert.New(t)
+
+	Store = NewProviderStore()
+	res := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/auth?provider=faux&state=state_REAL", nil)
+	a.NoError(err)
+
+	BeginAuthHandler(res, req)
+	session, _ := Store.Get(req, SessionName)
+
+	callback := func(body string) *http.Request {
+		req, _ := http.NewRequest("POST", "/auth/callback?provider=faux", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		session.Save(req, res)
+		return req
+	}
+
+	JSONCallbacks = true
+	defer func() { JSONCallbacks = false }()
+
+	req = callback(`{"code":"abc","state":"state_REAL","scope":["a","b"],"expires_in":3600}`)
+	a.Equal("state_REAL", GetState(req))
+	_, err = CompleteUserAuth(res, req)
+	a.NoError(err)
+	a.Equal("abc", req.Form.Get("code"))
+	a.Equal([]string{"a", "b"}, req.Form["scope"])
+	a.Equal("3600", req.Form.Get("expires_in"))
+	a.Equal("faux", req.Form.Get("provider"))
+
+	req, _ = http.NewRequest("GET", "/auth?provider=faux&state=state_REAL", nil)
+	BeginAuthHandler(res, req)
+	session, _ = Store.Get(req, SessionName)
+	_, err = CompleteUserAuth(res, callback(`{"code":"abc","state":"state_FAKE"}`))
+	a.ErrorIs(err, ErrStateMismatch)
+
+	// JSON bodies are ignored unless JSONCallbacks is set
+	JSONCallbacks = false
+	req, _ = http.NewRequest("GET", "/auth?provider=faux&state=state_REAL", nil)
+	BeginAuthHandler(res, req)
+	session, _ = Store.Get(req, SessionName)
+	_, err = CompleteUserAuth(res, callback(`{"code":"abc","state":"state_REAL"}`))
+	a.ErrorIs(err, ErrStateMismatch)
+}
+
+func gzipString(value string) string {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	if _, err := gz.Write([]byte(value)); err != nil {
+		return "err"
+	}
+	if err := gz.Flush(); err != nil {
+		return "err"
+	}
+	if err := gz.Close(); err != nil {
+		return "err"
+	}
+
+	return b.String()
 }
 
 func ungzipString(value string) string {
@@ -290,3 +1838,32 @@ func ungzipString(value string) string {
 
 	return string(s)
 }
+
+func Test_CallbackURL(t *testing.T) {
+	a := assert.New(t)
+
+	CallbackURL = CallbackURLForHosts("https://{host}/auth/{provider}/callback", "example.com", "*.example.org")
+	defer func() { CallbackURL = nil }()
+
+	provider := github.New("key", "secret", "https://example.com/auth/github/callback")
+
+	req, err := http.NewRequest("GET", "/auth?provider=github", nil)
+	a.NoError(err)
+	req.Host = "Shop.Example.org"
+	authURL, err := GetAuthURL(httptest.NewRecorder(), WithProviderInstance(req, provider))
+	a.NoError(err)
+	u, err := url.Parse(authURL)
+	a.NoError(err)
+	a.Equal("https://shop.example.org/auth/github/callback", u.Query().Get("redirect_uri"))
+
+	req.Host = "example.net"
+	_, err = GetAuthURL(httptest.NewRecorder(), WithProviderInstance(req, provider))
+	a.ErrorIs(err, ErrHostNotAllowed)
+
+	// providers unable to change their callback URL are refused
+	req, err = http.NewRequest("GET", "/auth?provider=faux", nil)
+	a.NoError(err)
+	req.Host = "example.com"
+	_, err = GetAuthURL(httptest.NewRecorder(), req)
+	a.Error(err)
+}
@@ -0,0 +1,97 @@
+package gothic
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/markbates/goth"
+)
+
+// AuthSuccessHook is called by CompleteUserAuth once a user has been authenticated.
+type AuthSuccessHook func(req *http.Request, providerName string, user goth.User)
+
+// AuthFailureHook is called by CompleteUserAuth when the authentication failed.
+// providerName is empty if the provider couldn't be determined from the request.
+type AuthFailureHook func(req *http.Request, providerName string, err error)
+
+// GrantRevokedHook is called when a provider reported that the grant of a user
+// has been revoked, see OnGrantRevoked.
+type GrantRevokedHook func(req *http.Request, err *goth.GrantRevokedError)
+
+var (
+	hooksMu           sync.RWMutex
+	successHooks      []AuthSuccessHook
+	failureHooks      []AuthFailureHook
+	grantRevokedHooks []GrantRevokedHook
+)
+
+// OnAuthSuccess registers a hook called every time CompleteUserAuth succeeds,
+// e.g. for audit logging or provisioning users. Hooks are called in the order
+// they were registered, before CompleteUserAuth returns.
+func OnAuthSuccess(hook AuthSuccessHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	successHooks = append(successHooks, hook)
+}
+
+// OnAuthFailure registers a hook called every time CompleteUserAuth fails.
+func OnAuthFailure(hook AuthFailureHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	failureHooks = append(failureHooks, hook)
+}
+
+// OnGrantRevoked registers a hook called every time a provider refuses to
+// refresh the token of a user, as CompleteUserAuth does when
+// RefreshExpiredTokens is set, or when GrantRevoked is called, e.g. to
+// deactivate the accounts linked to the grant in a single place.
+func OnGrantRevoked(hook GrantRevokedHook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	grantRevokedHooks = append(grantRevokedHooks, hook)
+}
+
+// GrantRevoked calls the hooks registered with OnGrantRevoked. Applications
+// call it when they learn about a revoked grant by other means than
+// refreshing a token, e.g. from the revocation webhook of a provider or from
+// their own goth.RefreshToken calls:
+//
+//	token, err := goth.RefreshToken(ctx, provider, refreshToken)
+//	var revoked *goth.GrantRevokedError
+//	if errors.As(err, &revoked) {
+//		gothic.GrantRevoked(req, revoked)
+//	}
+func GrantRevoked(req *http.Request, err *goth.GrantRevokedError) {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+
+	for _, hook := range grantRevokedHooks {
+		hook(req, err)
+	}
+}
+
+// ClearAuthHooks removes all the hooks registered with OnAuthSuccess,
+// OnAuthFailure and OnGrantRevoked. This is useful, mostly, for testing
+// purposes.
+func ClearAuthHooks() {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	successHooks = nil
+	failureHooks = nil
+	grantRevokedHooks = nil
+}
+
+func runAuthHooks(req *http.Request, providerName string, user goth.User, err error) {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+
+	if err != nil {
+		for _, hook := range failureHooks {
+			hook(req, providerName, err)
+		}
+		return
+	}
+	for _, hook := range successHooks {
+		hook(req, providerName, user)
+	}
+}
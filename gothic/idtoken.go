@@ -0,0 +1,98 @@
+package gothic
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/markbates/goth"
+)
+
+// ErrCSRFTokenMismatch is returned by CompleteUserAuthWithIDToken when the
+// g_csrf_token posted by Google Identity Services doesn't match its cookie.
+var ErrCSRFTokenMismatch = errors.New("g_csrf_token mismatch")
+
+// ErrCrossSiteIDToken is returned by CompleteUserAuthWithIDToken when an
+// id_token is posted by a browser from another site.
+var ErrCrossSiteIDToken = errors.New("id_token posted from another site")
+
+/*
+CompleteUserAuthWithIDToken authenticates the user an ID token was issued for,
+instead of completing the authorization code flow. This is how Google One Tap
+and the Sign In With Google button work: the browser gets an ID token from the
+provider and posts it to the application, which only has to validate it.
+
+It expects to be able to get the name of the provider like CompleteUserAuth,
+and the ID token from the "credential" form value, which Google Identity
+Services posts, or the "id_token" one. The provider must implement
+goth.IDTokenAuthenticator.
+
+Google Identity Services posts the token from its own site, along with a
+g_csrf_token cookie and form value, which must be present and match when the
+token is posted as "credential" or a g_csrf_token is posted. Tokens posted as
+"id_token", e.g. by native clients or by the JavaScript of the application
+after a Firebase or Facebook Limited Login sign in, don't need it, but are
+refused if the browser reports them as posted from another site (see
+ErrCrossSiteIDToken): an attacker posting their own ID token would sign the
+victim in to the attacker's account. Browsers which don't send the
+Sec-Fetch-Site header aren't covered by this check, so such endpoints should
+also require something a cross-site form can't send, like a custom header.
+*/
+func CompleteUserAuthWithIDToken(res http.ResponseWriter, req *http.Request) (goth.User, error) {
+	start := time.Now()
+	providerName, err := GetProviderName(req)
+	if err != nil {
+		runAuthHooks(req, "", goth.User{}, err)
+		recordCompletion(req, "", start, err)
+		return goth.User{}, err
+	}
+
+	user, err := completeIDTokenAuth(req, providerName)
+	if err == nil {
+		if err = goth.ValidateUser(user); err != nil {
+			user = goth.User{}
+		}
+	}
+	runAuthHooks(req, providerName, user, err)
+	recordCompletion(req, providerName, start, err)
+	return user, err
+}
+
+func completeIDTokenAuth(req *http.Request, providerName string) (goth.User, error) {
+	provider, err := getProvider(req, providerName)
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	idToken := req.FormValue("credential")
+	if idToken != "" || req.PostFormValue("g_csrf_token") != "" {
+		// posted by Google Identity Services
+		if err := validateCSRFToken(req); err != nil {
+			return goth.User{}, err
+		}
+	} else if req.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return goth.User{}, ErrCrossSiteIDToken
+	}
+
+	if idToken == "" {
+		idToken = req.FormValue("id_token")
+	}
+	if idToken == "" {
+		return goth.User{}, errors.New("no ID token was posted")
+	}
+
+	return goth.FetchUserFromIDToken(req.Context(), provider, idToken)
+}
+
+// validateCSRFToken implements the double submit cookie check of Google
+// Identity Services.
+// See https://developers.google.com/identity/gsi/web/guides/verify-google-id-token
+func validateCSRFToken(req *http.Request) error {
+	posted := req.PostFormValue("g_csrf_token")
+	cookie, err := req.Cookie("g_csrf_token")
+	if posted == "" || err != nil || subtle.ConstantTimeCompare([]byte(posted), []byte(cookie.Value)) != 1 {
+		return ErrCSRFTokenMismatch
+	}
+	return nil
+}
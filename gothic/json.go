@@ -0,0 +1,193 @@
+package gothic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrStateMismatch is returned by CompleteUserAuth when the state returned by
+// the provider isn't the one the authentication process was started with.
+var ErrStateMismatch = errors.New("state token mismatch")
+
+// The codes of the errors reported by GetAuthURLJSON and CompleteUserAuthJSON.
+// Unlike the errors themselves, which are only logged, they are stable and
+// safe to show to the client.
+const (
+	ErrorCodeInvalidRequest       = "invalid_request"
+	ErrorCodeStateMismatch        = "state_mismatch"
+	ErrorCodeAuthenticationFailed = "authentication_failed"
+)
+
+// errorMessages are the generic messages sent along with the error codes.
+var errorMessages = map[string]string{
+	ErrorCodeInvalidRequest:       "the authentication request is invalid",
+	ErrorCodeStateMismatch:        "the authentication state doesn't match",
+	ErrorCodeAuthenticationFailed: "authentication failed",
+}
+
+/*
+GetAuthURLJSON is the counterpart of BeginAuthHandler for single page
+applications starting the authentication process with fetch(). Instead of
+redirecting, it responds with the URL to send the user to and the state:
+
+	{"url": "https://provider/authorize?...", "state": "..."}
+
+Errors are logged, and reported as
+
+	{"error": "invalid_request", "message": "..."}
+
+with a 400 status. The message is generic, the error itself is never sent.
+
+BeginAuthHandler responds the same way to requests accepting application/json.
+*/
+func GetAuthURLJSON(res http.ResponseWriter, req *http.Request) {
+	authURL, err := GetAuthURL(res, req)
+	if err != nil {
+		writeJSONError(res, req, http.StatusBadRequest, ErrorCodeInvalidRequest, err)
+		return
+	}
+
+	state := ""
+	if u, err := url.Parse(authURL); err == nil {
+		state = u.Query().Get("state")
+	}
+
+	writeJSON(res, http.StatusOK, map[string]string{
+		"url":   authURL,
+		"state": state,
+	})
+}
+
+/*
+CompleteUserAuthJSON completes the authentication process like CompleteUserAuth
+and responds with the user as JSON. The tokens are left out, as they are meant
+to stay on the server; use CompleteUserAuth directly if the client needs them.
+
+Errors are logged, and reported like those of GetAuthURLJSON with the code
+and status:
+
+	invalid_request (400) if the provider is missing or unknown
+	state_mismatch (403) if the state doesn't match (ErrStateMismatch)
+	authentication_failed (401) if the authentication failed otherwise
+*/
+func CompleteUserAuthJSON(res http.ResponseWriter, req *http.Request) {
+	providerName, err := GetProviderName(req)
+	if err == nil {
+		_, err = getProvider(req, providerName)
+	}
+	if err != nil {
+		writeJSONError(res, req, http.StatusBadRequest, ErrorCodeInvalidRequest, err)
+		return
+	}
+
+	user, err := CompleteUserAuth(res, req)
+	if err != nil {
+		if errors.Is(err, ErrStateMismatch) {
+			writeJSONError(res, req, http.StatusForbidden, ErrorCodeStateMismatch, err)
+		} else {
+			writeJSONError(res, req, http.StatusUnauthorized, ErrorCodeAuthenticationFailed, err)
+		}
+		return
+	}
+
+	user.AccessToken = ""
+	user.AccessTokenSecret = ""
+	user.RefreshToken = ""
+	user.IDToken = ""
+	writeJSON(res, http.StatusOK, user)
+}
+
+// acceptsJSON reports whether the client asked for a JSON response.
+func acceptsJSON(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Accept"), "application/json")
+}
+
+func writeJSON(res http.ResponseWriter, status int, v interface{}) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(v)
+}
+
+// writeJSONError logs err and responds with code and its generic message, so
+// that, like with writeError, the content of err is never reflected in the
+// response.
+func writeJSONError(res http.ResponseWriter, req *http.Request, status int, code string, err error) {
+	log.Printf("gothic: %s %s: %v", req.Method, req.URL.Path, err)
+	writeJSON(res, status, map[string]string{
+		"error":   code,
+		"message": errorMessages[code],
+	})
+}
+
+// JSONCallbacks makes CompleteUserAuth and GetState read the parameters of
+// POST callbacks with an application/json body, e.g.
+//
+//	{"code": "...", "state": "..."}
+//
+// as some identity providers and mobile SDK bridges send, like those of form
+// encoded bodies. The body must be a JSON object; arrays of strings give
+// several values, and other values that aren't strings are passed on in their
+// JSON encoding.
+var JSONCallbacks = false
+
+// maxJSONCallbackSize limits the size of the JSON callback bodies read.
+const maxJSONCallbackSize = 1 << 20
+
+// parseCallbackForm parses the parameters of a callback into req.Form, along
+// with those of a JSON body if JSONCallbacks is set. Only the errors of the
+// JSON body are reported, the body itself is left for others to read.
+func parseCallbackForm(req *http.Request) error {
+	if JSONCallbacks && req.Method == http.MethodPost && len(req.PostForm) == 0 && isJSONBody(req) {
+		body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxJSONCallbackSize))
+		if err != nil {
+			return err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		values, err := jsonCallbackValues(body)
+		if err != nil {
+			return err
+		}
+		req.PostForm = values
+		req.Form = nil
+	}
+	req.ParseForm()
+	return nil
+}
+
+func isJSONBody(req *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+func jsonCallbackValues(body []byte) (url.Values, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("gothic: invalid JSON callback body: %w", err)
+	}
+
+	values := url.Values{}
+	for k, raw := range fields {
+		var s string
+		var list []string
+		switch {
+		case json.Unmarshal(raw, &s) == nil:
+			values.Set(k, s)
+		case json.Unmarshal(raw, &list) == nil:
+			values[k] = list
+		case string(raw) != "null":
+			values.Set(k, string(raw))
+		}
+	}
+	return values, nil
+}
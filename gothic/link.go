@@ -0,0 +1,191 @@
+package gothic
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/markbates/goth"
+)
+
+// The linking flow keeps its values under these prefixes so that the session
+// of a link in progress and the linked users never overwrite the regular
+// provider sessions, nor each other.
+const (
+	linkSessionPrefix = "link:"
+	linkedUserPrefix  = "linked:"
+)
+
+/*
+BeginLink starts linking the provider of the request to the current visitor,
+in addition to the providers they have already linked. It works like
+BeginAuthHandler and redirects the user to the provider, but leaves the
+existing sessions and linked users alone.
+
+Finish the flow by calling CompleteLink from the callback handler.
+*/
+func BeginLink(res http.ResponseWriter, req *http.Request) {
+	url, err := getLinkURL(res, req)
+	if err != nil {
+		ErrorHandler(res, req, err)
+		return
+	}
+
+	http.Redirect(res, req, url, http.StatusTemporaryRedirect)
+}
+
+func getLinkURL(res http.ResponseWriter, req *http.Request) (string, error) {
+	providerName, err := GetProviderName(req)
+	if err != nil {
+		return "", err
+	}
+
+	provider, err := authProvider(req, providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := newState(req, providerName)
+	if err != nil {
+		return "", err
+	}
+	sess, err := beginAuth(provider, state, req)
+	if err != nil {
+		return "", err
+	}
+
+	url, err := sess.GetAuthURL()
+	if err != nil {
+		return "", err
+	}
+
+	key := linkSessionPrefix + providerName
+	if err := StoreInSession(key, sess.Marshal(), req, res); err != nil {
+		return "", err
+	}
+
+	if isFormPost(url) {
+		value, err := encodeValue(sess.Marshal())
+		if err != nil {
+			return "", err
+		}
+		if err := storeFormPostValue(req, res, key, value); err != nil {
+			return "", err
+		}
+	}
+	return url, nil
+}
+
+/*
+CompleteLink completes a flow started with BeginLink, fetches the user from the
+provider and adds them to the linked users returned by LinkedUsers. Linking a
+provider again replaces the user previously linked for it.
+
+The linked users, tokens included, are kept in the gothic session, so a
+SessionStorage able to hold several users (and a Codec to encrypt them) is
+recommended. Logout clears them along with the rest of the session.
+*/
+func CompleteLink(res http.ResponseWriter, req *http.Request) (goth.User, error) {
+	providerName, err := GetProviderName(req)
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	provider, err := authProvider(req, providerName)
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	key := linkSessionPrefix + providerName
+	value, err := GetFromSession(key, req)
+	if err != nil {
+		// form_post callbacks come without the regular session cookie
+		var formPostErr error
+		if value, formPostErr = getFromFormPostSession(key, req); formPostErr != nil {
+			return goth.User{}, err
+		}
+	}
+
+	user, err := fetchLinkedUser(req, provider, providerName, value)
+	if clearErr := clearFormPostSession(req, res); err == nil {
+		err = clearErr
+	}
+	// The session of the link is deleted before the linked user is stored:
+	// only the last of the Set-Cookie headers of a cookie session is kept by
+	// the browser, and it must hold the linked user.
+	if deleteErr := Storage.Delete(req, res, key); err == nil {
+		err = deleteErr
+	}
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	data, err := json.Marshal(user)
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	if err := StoreInSession(linkedUserPrefix+providerName, string(data), req, res); err != nil {
+		return goth.User{}, err
+	}
+	return user, nil
+}
+
+// fetchLinkedUser authorizes the session of a link stored as value and
+// fetches its user.
+func fetchLinkedUser(req *http.Request, provider goth.Provider, providerName, value string) (goth.User, error) {
+	sess, err := provider.UnmarshalSession(value)
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	if err := validateState(req, providerName, sess); err != nil {
+		return goth.User{}, err
+	}
+
+	params := req.URL.Query()
+	if req.Method == "POST" {
+		// req.Form holds the query parameters as well
+		if err := parseCallbackForm(req); err != nil {
+			return goth.User{}, err
+		}
+		params = req.Form
+	}
+
+	if _, err := sess.Authorize(provider, params); err != nil {
+		return goth.User{}, err
+	}
+
+	user, err := goth.FetchUser(provider, sess)
+	if err != nil {
+		return goth.User{}, err
+	}
+	if err := goth.ValidateUser(user); err != nil {
+		return goth.User{}, err
+	}
+	return user, nil
+}
+
+// LinkedUsers returns the users linked with CompleteLink for the registered
+// providers, lazy ones included, ordered by provider name.
+func LinkedUsers(req *http.Request) ([]goth.User, error) {
+	users := make([]goth.User, 0)
+	for _, name := range goth.ProviderNames() {
+		value, err := GetFromSession(linkedUserPrefix+name, req)
+		if err != nil {
+			// nothing linked for this provider
+			continue
+		}
+
+		user := goth.User{}
+		if err := json.Unmarshal([]byte(value), &user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// Unlink removes the user linked for the named provider.
+func Unlink(res http.ResponseWriter, req *http.Request, providerName string) error {
+	return Storage.Delete(req, res, linkedUserPrefix+providerName)
+}
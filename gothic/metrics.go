@@ -0,0 +1,23 @@
+package gothic
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/markbates/goth"
+)
+
+// recordCompletion reports the outcome of an authentication process to the
+// Metrics set with goth.SetMetrics. The provider name comes from the request,
+// so it is only reported if it names a provider, and as goth.UnknownProvider
+// otherwise.
+func recordCompletion(req *http.Request, providerName string, start time.Time, err error) {
+	outcome := goth.OutcomeSuccess
+	if err != nil {
+		outcome = goth.OutcomeFailure
+	}
+	if _, perr := getProvider(req, providerName); perr != nil {
+		providerName = goth.UnknownProvider
+	}
+	goth.GetMetrics().AuthCompleted(providerName, outcome, time.Since(start))
+}
@@ -0,0 +1,101 @@
+package gothic
+
+import (
+	"net/http"
+
+	"github.com/markbates/goth"
+)
+
+// MigratingStorage is a SessionStorage for the transition window while
+// switching from a storage to another, e.g. from a cookie store to a Redis
+// backed one, without invalidating the authentication processes in flight:
+//
+//	old := gothic.GorillaStorage{Store: cookieStore}
+//	new := gothic.GorillaStorage{Store: redisStore, Name: "_gothic_redis"}
+//	gothic.Storage = gothic.MigratingStorage{From: old, To: new}
+//
+// Values are read from To, then from From if they aren't found there, and
+// written to To only, which also removes them from From. Once the sessions
+// in From have expired, To can be assigned to Storage directly.
+//
+// Removing values from From is done on a best effort basis: its errors are
+// ignored, so that an old session which can't be decoded anymore doesn't
+// break the new one.
+type MigratingStorage struct {
+	From SessionStorage
+	To   SessionStorage
+}
+
+// Get returns the value stored under key in To, or else in From.
+func (m MigratingStorage) Get(req *http.Request, key string) (string, error) {
+	value, err := m.To.Get(req, key)
+	if err == nil {
+		return value, nil
+	}
+	if old, fromErr := m.From.Get(req, key); fromErr == nil {
+		return old, nil
+	}
+	return "", err
+}
+
+// Set stores value under key in To and removes it from From.
+func (m MigratingStorage) Set(req *http.Request, res http.ResponseWriter, key, value string) error {
+	if err := m.To.Set(req, res, key, value); err != nil {
+		return err
+	}
+	if _, err := m.From.Get(req, key); err == nil {
+		m.From.Delete(req, res, key)
+	}
+	return nil
+}
+
+// Delete removes the value stored under key from both storages.
+func (m MigratingStorage) Delete(req *http.Request, res http.ResponseWriter, key string) error {
+	m.From.Delete(req, res, key)
+	return m.To.Delete(req, res, key)
+}
+
+// Clear removes every value stored for the request from both storages.
+func (m MigratingStorage) Clear(req *http.Request, res http.ResponseWriter) error {
+	m.From.Clear(req, res)
+	return m.To.Clear(req, res)
+}
+
+// MigrateSession moves the values gothic stored for the request from one
+// storage to another, e.g. from a middleware run on every request during
+// the transition to a new storage.
+//
+// The values moved are the ones stored under keys or, if none are given,
+// the provider sessions of the authentication processes in progress, the
+// linking sessions and the users kept because of KeepUserInSession of all the
+// registered providers, lazy ones included.
+func MigrateSession(req *http.Request, res http.ResponseWriter, from, to SessionStorage, keys ...string) error {
+	if len(keys) == 0 {
+		keys = append(sessionKeys(), pendingAttempts(req, from)...)
+		keys = append(keys, attemptsKey)
+	}
+
+	for _, key := range keys {
+		value, err := from.Get(req, key)
+		if err != nil {
+			continue
+		}
+		if err := to.Set(req, res, key, value); err != nil {
+			return err
+		}
+		if err := from.Delete(req, res, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sessionKeys returns the keys gothic stores values under for the
+// registered providers.
+func sessionKeys() []string {
+	var keys []string
+	for _, name := range goth.ProviderNames() {
+		keys = append(keys, name, linkSessionPrefix+name, linkedUserPrefix+name, userKeyPrefix+name)
+	}
+	return keys
+}
@@ -0,0 +1,154 @@
+package gothic
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/markbates/goth"
+)
+
+// Option configures the handler returned by Mount.
+type Option func(*mounter)
+
+// WithSuccessHandler sets the function called once a user has completed the
+// authentication process. The default writes a plain "authenticated as" message.
+func WithSuccessHandler(fn func(res http.ResponseWriter, req *http.Request, user goth.User)) Option {
+	return func(m *mounter) {
+		m.success = fn
+	}
+}
+
+// WithFailureHandler sets the function called when starting or completing the
+// authentication process fails. The default logs the error and responds with a
+// generic 401, without the error message.
+func WithFailureHandler(fn func(res http.ResponseWriter, req *http.Request, err error)) Option {
+	return func(m *mounter) {
+		m.failure = fn
+	}
+}
+
+// WithLogoutHandler sets the function called after the user session has been
+// invalidated. The default redirects to "/".
+func WithLogoutHandler(fn func(res http.ResponseWriter, req *http.Request)) Option {
+	return func(m *mounter) {
+		m.logout = fn
+	}
+}
+
+// WithSessionName sets the name of the session the handler keeps its values
+// in, instead of SessionName, so that several handlers, e.g. of applications
+// served from the same domain, don't share it. See RequestWithSessionName.
+func WithSessionName(name string) Option {
+	return func(m *mounter) {
+		m.sessionName = name
+	}
+}
+
+/*
+Mount returns an http.Handler serving the usual gothic routes below prefix:
+
+	{prefix}/auth/{provider}           starts the authentication process (see GetAuthURLJSON for SPAs)
+	{prefix}/auth/{provider}/callback  completes it and calls the success handler
+	{prefix}/logout/{provider}         invalidates the user session
+
+Register it with your router for every path below prefix, e.g.
+
+	http.Handle("/sso/", gothic.Mount("/sso", gothic.WithSuccessHandler(welcome)))
+*/
+func Mount(prefix string, opts ...Option) http.Handler {
+	m := &mounter{
+		prefix:  strings.TrimSuffix(prefix, "/"),
+		success: defaultSuccessHandler,
+		failure: defaultFailureHandler,
+		logout:  defaultLogoutHandler,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+type mounter struct {
+	prefix      string
+	sessionName string
+	success     func(http.ResponseWriter, *http.Request, goth.User)
+	failure     func(http.ResponseWriter, *http.Request, error)
+	logout      func(http.ResponseWriter, *http.Request)
+}
+
+func (m *mounter) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, m.prefix)
+	if len(path) == len(req.URL.Path) && m.prefix != "" {
+		http.NotFound(res, req)
+		return
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if m.sessionName != "" {
+		req = RequestWithSessionName(req, m.sessionName)
+	}
+
+	switch {
+	case len(parts) == 2 && parts[0] == "auth":
+		req = withPathProvider(req, parts[1])
+		if acceptsJSON(req) {
+			GetAuthURLJSON(res, req)
+			return
+		}
+		url, err := GetAuthURL(res, req)
+		if err != nil {
+			m.failure(res, req, err)
+			return
+		}
+		http.Redirect(res, req, url, http.StatusTemporaryRedirect)
+	case len(parts) == 3 && parts[0] == "auth" && parts[2] == "callback":
+		req = withPathProvider(req, parts[1])
+		user, err := CompleteUserAuth(res, req)
+		if err != nil {
+			m.failure(res, req, err)
+			return
+		}
+		m.success(res, req, user)
+	case len(parts) == 2 && parts[0] == "logout":
+		req = withPathProvider(req, parts[1])
+		if err := Logout(res, req); err != nil {
+			m.failure(res, req, err)
+			return
+		}
+		m.logout(res, req)
+	default:
+		http.NotFound(res, req)
+	}
+}
+
+// withPathProvider returns a copy of req for the provider named in its path.
+// The provider query parameters are removed, as GetProviderName would prefer
+// them to the path.
+func withPathProvider(req *http.Request, provider string) *http.Request {
+	req = GetContextWithProvider(req, provider)
+	query := req.URL.Query()
+	if _, ok := query["provider"]; !ok {
+		if _, ok := query[":provider"]; !ok {
+			return req
+		}
+	}
+
+	query.Del("provider")
+	query.Del(":provider")
+	u := *req.URL
+	u.RawQuery = query.Encode()
+	req.URL = &u
+	return req
+}
+
+func defaultSuccessHandler(res http.ResponseWriter, req *http.Request, user goth.User) {
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.Write([]byte("authenticated as " + user.UserID + " with " + user.Provider + "\n"))
+}
+
+func defaultFailureHandler(res http.ResponseWriter, req *http.Request, err error) {
+	writeError(res, req, http.StatusUnauthorized, err)
+}
+
+func defaultLogoutHandler(res http.ResponseWriter, req *http.Request) {
+	http.Redirect(res, req, "/", http.StatusTemporaryRedirect)
+}
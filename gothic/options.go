@@ -0,0 +1,88 @@
+package gothic
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// SessionOptions are the attributes of the cookies gothic keeps its session
+// in, see SetSessionOptions.
+type SessionOptions struct {
+	Path   string
+	Domain string
+	// MaxAge is the lifetime of the cookies in seconds. With 0 they are
+	// deleted when the browser is closed.
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	// SameSite is left out of the cookies if http.SameSiteDefaultMode or 0,
+	// in which case browsers treat them as SameSite=Lax. http.SameSiteNoneMode
+	// requires Secure.
+	SameSite http.SameSite
+}
+
+// sessionSameSite is the SameSite attribute set by SetSessionOptions.
+var sessionSameSite http.SameSite
+
+/*
+SetSessionOptions sets the attributes of the cookies of the gothic session,
+instead of creating a store for that, e.g.
+
+	gothic.SetSessionOptions(gothic.SessionOptions{
+		Path:     "/",
+		MaxAge:   600,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+The options are applied to Store if it is a sessions.CookieStore, as the
+default one is, or a sessions.FilesystemStore, so it has to be called after
+assigning Store. SameSite is added to the cookies saved by GorillaStorage
+whatever their store is.
+*/
+func SetSessionOptions(opts SessionOptions) {
+	sessionSameSite = opts.SameSite
+
+	options := &sessions.Options{
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		MaxAge:   opts.MaxAge,
+		Secure:   opts.Secure,
+		HttpOnly: opts.HttpOnly,
+	}
+	switch s := Store.(type) {
+	case *sessions.CookieStore:
+		s.Options = options
+		s.MaxAge(opts.MaxAge)
+	case *sessions.FilesystemStore:
+		s.Options = options
+		s.MaxAge(opts.MaxAge)
+	}
+}
+
+// addSameSite adds the SameSite attribute to the cookie named name set on
+// res. The sessions.Options of the gorilla/sessions version in use have no
+// SameSite field yet, so the attribute is added to the cookie directly.
+func addSameSite(res http.ResponseWriter, name string, mode http.SameSite) {
+	var attr string
+	switch mode {
+	case http.SameSiteLaxMode:
+		attr = "; SameSite=Lax"
+	case http.SameSiteStrictMode:
+		attr = "; SameSite=Strict"
+	case http.SameSiteNoneMode:
+		attr = "; SameSite=None"
+	default:
+		return
+	}
+
+	cookies := res.Header()["Set-Cookie"]
+	for i, c := range cookies {
+		if strings.HasPrefix(c, name+"=") && !strings.Contains(c, "SameSite=") {
+			cookies[i] = c + attr
+		}
+	}
+}
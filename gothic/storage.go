@@ -0,0 +1,309 @@
+package gothic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// SessionStorage is used by gothic to persist values between the start and
+// the end of the authentication process. Implement it to plug in your own
+// backend (Redis, JWT cookies, a database, ...) and assign it to Storage.
+type SessionStorage interface {
+	// Get returns the value stored under key for the given request. It must
+	// return an error if no value has been stored under that key.
+	Get(req *http.Request, key string) (string, error)
+	// Set stores value under key for the given request.
+	Set(req *http.Request, res http.ResponseWriter, key, value string) error
+	// Delete removes the value stored under key for the given request.
+	Delete(req *http.Request, res http.ResponseWriter, key string) error
+	// Clear removes every value stored for the given request.
+	Clear(req *http.Request, res http.ResponseWriter) error
+}
+
+// Storage is the SessionStorage used by gothic. The default is backed by the
+// gorilla/sessions Store configured in the Store variable.
+var Storage SessionStorage = GorillaStorage{}
+
+// DefaultChunkSize is the size above which GorillaStorage stores values apart
+// and splits them, if its ChunkSize isn't set. It keeps each session within
+// the 4KB cookie limit once encoded by a sessions.CookieStore, even with
+// encryption enabled.
+const DefaultChunkSize = 2000
+
+// chunkCountSuffix is appended to a key to store the number of parts its
+// value was split into.
+const chunkCountSuffix = "#chunks"
+
+// GorillaStorage is a SessionStorage backed by a gorilla/sessions Store.
+// If Store is nil, the package level Store variable is used.
+//
+// Values are kept in the session named Name as long as the values it holds
+// add up to at most ChunkSize. Other values are stored apart, split into parts
+// of at most ChunkSize held by sessions of their own, named after Name and the
+// key, and reassembled when read. With a sessions.CookieStore each of them is
+// a separate cookie, so large provider sessions, even of several concurrent
+// authentications, don't push any cookie past the size limit.
+type GorillaStorage struct {
+	Store sessions.Store
+	// Name is the name of the session, the one returned by GetSessionName
+	// if empty. Two storages used for the same requests, e.g. by a
+	// MigratingStorage, need different names.
+	Name string
+	// ChunkSize is the maximum length of the values held by a single
+	// session. DefaultChunkSize is used if it is zero, and values are never
+	// stored apart if it is negative.
+	ChunkSize int
+}
+
+func (g GorillaStorage) store() sessions.Store {
+	if g.Store != nil {
+		return g.Store
+	}
+	return Store
+}
+
+func (g GorillaStorage) chunkSize() int {
+	if g.ChunkSize == 0 {
+		return DefaultChunkSize
+	}
+	return g.ChunkSize
+}
+
+func (g GorillaStorage) sessionName(req *http.Request) string {
+	if g.Name != "" {
+		return g.Name
+	}
+	return GetSessionName(req)
+}
+
+// partSessionName returns the name of the session holding the part i of the
+// value stored apart under key. Keys are hashed, as they may hold characters
+// cookie names can't.
+func (g GorillaStorage) partSessionName(req *http.Request, key string, i int) string {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return fmt.Sprintf("%s_%08x_%d", g.sessionName(req), h.Sum32(), i)
+}
+
+// chunkCount returns the number of parts the value stored under key was split
+// into, or 0 if it is held by session itself. Serializers other than gob,
+// e.g. JSON ones, may read the number back as another type.
+func chunkCount(session *sessions.Session, key string) (int, error) {
+	v, ok := session.Values[key+chunkCountSuffix]
+	if !ok {
+		return 0, nil
+	}
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int32:
+		return int(n), nil
+	case int64:
+		return int(n), nil
+	case float64:
+		if n == math.Trunc(n) {
+			return int(n), nil
+		}
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return int(i), nil
+		}
+	}
+	return 0, fmt.Errorf("could not read the number of parts of the session value %q: %v", key, v)
+}
+
+// inlineSize returns the size of the values held by session, leaving out the
+// one stored under key.
+func inlineSize(session *sessions.Session, key string) int {
+	size := 0
+	for k, v := range session.Values {
+		name, _ := k.(string)
+		if name == key || name == key+chunkCountSuffix {
+			continue
+		}
+		size += len(name)
+		if s, ok := v.(string); ok {
+			size += len(s)
+		}
+	}
+	return size
+}
+
+// Get returns the value stored under key in the gorilla session.
+func (g GorillaStorage) Get(req *http.Request, key string) (string, error) {
+	session, _ := g.store().Get(req, g.sessionName(req))
+	chunks, err := chunkCount(session, key)
+	if err != nil {
+		return "", err
+	}
+	if chunks == 0 {
+		value, ok := session.Values[key].(string)
+		if !ok {
+			return "", errors.New("could not find a matching session for this request")
+		}
+		return value, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < chunks; i++ {
+		part, _ := g.store().Get(req, g.partSessionName(req, key, i))
+		value, ok := part.Values[key].(string)
+		if !ok {
+			return "", fmt.Errorf("could not find part %d of %d of the session for this request", i+1, chunks)
+		}
+		b.WriteString(value)
+	}
+	return b.String(), nil
+}
+
+// Set stores value under key in the gorilla session and saves it.
+func (g GorillaStorage) Set(req *http.Request, res http.ResponseWriter, key, value string) error {
+	session := g.session(req, g.sessionName(req))
+	previous, err := chunkCount(session, key)
+	if err != nil {
+		return err
+	}
+
+	var parts []string
+	size := g.chunkSize()
+	if size > 0 && inlineSize(session, key)+len(key)+len(value) > size {
+		for len(value) > size {
+			parts = append(parts, value[:size])
+			value = value[size:]
+		}
+		parts = append(parts, value)
+	}
+
+	// remove the parts of a previous value that aren't overwritten
+	for i := len(parts); i < previous; i++ {
+		if err := g.deleteFrom(req, res, g.partSessionName(req, key, i), key); err != nil {
+			return err
+		}
+	}
+
+	for i, part := range parts {
+		chunk := g.session(req, g.partSessionName(req, key, i))
+		chunk.Values[key] = part
+		if err := g.save(req, res, chunk); err != nil {
+			return err
+		}
+	}
+
+	if len(parts) == 0 {
+		session.Values[key] = value
+		delete(session.Values, key+chunkCountSuffix)
+	} else {
+		delete(session.Values, key)
+		session.Values[key+chunkCountSuffix] = len(parts)
+	}
+	return g.save(req, res, session)
+}
+
+// save saves session, with the SameSite attribute set by SetSessionOptions.
+func (g GorillaStorage) save(req *http.Request, res http.ResponseWriter, session *sessions.Session) error {
+	if err := session.Save(req, res); err != nil {
+		return err
+	}
+	addSameSite(res, session.Name(), sessionSameSite)
+	return nil
+}
+
+// session returns a new instance of the named session of the request to be
+// saved by Set. It shares its values with the session the store returns for
+// the request, so that values set earlier while handling the request are
+// kept. A session expired earlier while handling the request, e.g. by Clear,
+// starts over empty instead of coming back with the values the request was
+// sent with.
+func (g GorillaStorage) session(req *http.Request, name string) *sessions.Session {
+	current, err := g.store().Get(req, name)
+	session, _ := g.store().New(req, name)
+	if err != nil || current == nil || session == nil {
+		return session
+	}
+
+	if current.Options != nil && current.Options.MaxAge < 0 {
+		current.Values = make(map[interface{}]interface{})
+		current.Options = session.Options
+	}
+	session.Values = current.Values
+	return session
+}
+
+// Delete removes the value stored under key from the gorilla session and saves
+// it. The value is removed even if the number of its parts can't be read, in
+// which case the error is returned and the parts are left to expire.
+func (g GorillaStorage) Delete(req *http.Request, res http.ResponseWriter, key string) error {
+	session, err := g.store().Get(req, g.sessionName(req))
+	if err != nil {
+		return err
+	}
+	chunks, countErr := chunkCount(session, key)
+	for i := 0; i < chunks; i++ {
+		if err := g.deleteFrom(req, res, g.partSessionName(req, key, i), key); err != nil {
+			return err
+		}
+	}
+	delete(session.Values, key)
+	delete(session.Values, key+chunkCountSuffix)
+	if err := g.save(req, res, session); err != nil {
+		return err
+	}
+	return countErr
+}
+
+// deleteFrom removes the value stored under key from the named session, and
+// expires the session if it doesn't hold any other value.
+func (g GorillaStorage) deleteFrom(req *http.Request, res http.ResponseWriter, name, key string) error {
+	session, _ := g.store().Get(req, name)
+	if _, ok := session.Values[key]; !ok {
+		return nil
+	}
+	delete(session.Values, key)
+	if len(session.Values) == 0 {
+		return expire(req, res, session)
+	}
+	return g.save(req, res, session)
+}
+
+// Clear empties and expires the gorilla session, along with the sessions
+// holding the parts of the values stored apart.
+func (g GorillaStorage) Clear(req *http.Request, res http.ResponseWriter) error {
+	session, err := g.store().Get(req, g.sessionName(req))
+	if err != nil {
+		return err
+	}
+
+	for k := range session.Values {
+		name, ok := k.(string)
+		if !ok || !strings.HasSuffix(name, chunkCountSuffix) {
+			continue
+		}
+		key := strings.TrimSuffix(name, chunkCountSuffix)
+		chunks, _ := chunkCount(session, key)
+		for i := 0; i < chunks; i++ {
+			part, _ := g.store().Get(req, g.partSessionName(req, key, i))
+			if err := expire(req, res, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	return expire(req, res, session)
+}
+
+func expire(req *http.Request, res http.ResponseWriter, session *sessions.Session) error {
+	session.Options.MaxAge = -1
+	session.Values = make(map[interface{}]interface{})
+	err := session.Save(req, res)
+	if err != nil {
+		return errors.New("Could not delete user session ")
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package redisstore
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultTimeout is the timeout of the commands of SimpleClient, and of the
+// connections to Redis, unless set with ClientOptions.Timeout.
+const defaultTimeout = 5 * time.Second
+
+// maxBulkSize bounds the size of the values read from Redis, sessions being
+// small.
+const maxBulkSize = 16 << 20
+
+// ClientOptions configure the client returned by NewClient.
+type ClientOptions struct {
+	// Username and Password authenticate the connections with the AUTH
+	// command, if Password is set. Username is only needed with the ACLs of
+	// Redis 6.
+	Username string
+	Password string
+	// DB is the database selected with the SELECT command, if not 0.
+	DB int
+	// TLSConfig, if set, makes the connections use TLS.
+	TLSConfig *tls.Config
+	// Timeout bounds the time taken to connect and to run a command, 5
+	// seconds if zero, unless the context of the request has an earlier
+	// deadline.
+	Timeout time.Duration
+	// MaxIdleConns is the number of idle connections kept, 4 if zero.
+	MaxIdleConns int
+}
+
+// SimpleClient is a minimal Redis client, implementing Client with the GET,
+// SET and DEL commands over a small pool of connections. It is safe for
+// concurrent use.
+type SimpleClient struct {
+	addr string
+	opts ClientOptions
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+var _ Client = &SimpleClient{}
+
+// NewClient returns a client for the Redis server at addr, e.g.
+// "localhost:6379". Connections are made as needed.
+func NewClient(addr string, opts ClientOptions) *SimpleClient {
+	if opts.Timeout == 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxIdleConns == 0 {
+		opts.MaxIdleConns = 4
+	}
+	return &SimpleClient{addr: addr, opts: opts}
+}
+
+// Get implements Client.
+func (c *SimpleClient) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrNotFound
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("redisstore: unexpected reply %v to GET", reply)
+	}
+	return value, nil
+}
+
+// Set implements Client.
+func (c *SimpleClient) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := c.do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// Del implements Client.
+func (c *SimpleClient) Del(ctx context.Context, key string) error {
+	_, err := c.do(ctx, "DEL", key)
+	return err
+}
+
+// Close closes the idle connections. Commands can't be run afterwards.
+func (c *SimpleClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, cn := range c.idle {
+		cn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+// redisError is an error reply of Redis.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redisstore: " + string(e)
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// do runs a command on an idle connection, or a new one, and returns its
+// reply: nil, a string, an int64, a []byte or a []interface{}.
+func (c *SimpleClient) do(ctx context.Context, args ...string) (interface{}, error) {
+	cn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(ctx, c.opts.Timeout, args...)
+	if err != nil {
+		// the connection is in an unknown state
+		cn.Close()
+		return nil, err
+	}
+	c.release(cn)
+
+	if e, ok := reply.(redisError); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// conn returns an idle connection, or a new one.
+func (c *SimpleClient) conn(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errors.New("redisstore: the client is closed")
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+	var nc net.Conn
+	var err error
+	if c.opts.TLSConfig != nil {
+		d := &tls.Dialer{Config: c.opts.TLSConfig}
+		nc, err = d.DialContext(ctx, "tcp", c.addr)
+	} else {
+		var d net.Dialer
+		nc, err = d.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+
+	if c.opts.Password != "" {
+		args := []string{"AUTH", c.opts.Password}
+		if c.opts.Username != "" {
+			args = []string{"AUTH", c.opts.Username, c.opts.Password}
+		}
+		if err := cn.setup(ctx, c.opts.Timeout, args...); err != nil {
+			return nil, err
+		}
+	}
+	if c.opts.DB != 0 {
+		if err := cn.setup(ctx, c.opts.Timeout, "SELECT", strconv.Itoa(c.opts.DB)); err != nil {
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// release puts cn back in the idle connections, or closes it if there are
+// enough of them.
+func (c *SimpleClient) release(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.idle) >= c.opts.MaxIdleConns {
+		cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+// setup runs a command setting up a new connection, closing it if it fails.
+func (cn *conn) setup(ctx context.Context, timeout time.Duration, args ...string) error {
+	reply, err := cn.do(ctx, timeout, args...)
+	if err == nil {
+		if e, ok := reply.(redisError); ok {
+			err = e
+		}
+	}
+	if err != nil {
+		cn.Close()
+	}
+	return err
+}
+
+func (cn *conn) do(ctx context.Context, timeout time.Duration, args ...string) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	// commands are sent as arrays of bulk strings
+	w := bufio.NewWriter(cn)
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(cn.r)
+}
+
+// readReply reads a reply of the RESP protocol.
+// See https://redis.io/docs/reference/protocol-spec/
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redisstore: malformed reply %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < -1 || n > maxBulkSize {
+			return nil, fmt.Errorf("redisstore: malformed reply %q", line)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < -1 || n > maxBulkSize {
+			return nil, fmt.Errorf("redisstore: malformed reply %q", line)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("redisstore: malformed reply %q", line)
+}
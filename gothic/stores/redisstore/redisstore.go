@@ -0,0 +1,184 @@
+/*
+Package redisstore is a gorilla/sessions Store keeping the sessions in Redis,
+so that the authentication processes gothic starts on one instance of an
+application can complete on another one:
+
+	client := redisstore.NewClient("localhost:6379", redisstore.ClientOptions{})
+	gothic.Store = redisstore.New(client, []byte(os.Getenv("SESSION_SECRET")))
+
+The cookie only holds the ID of the session, signed with the keys given to
+New, and the values are stored under KeyPrefix followed by the ID. The keys
+expire with the sessions, after DefaultMaxAge unless Options.MaxAge is
+changed, which is long enough for an authentication process to complete;
+each save extends the lifetime of the session.
+
+NewClient returns a minimal Redis client, speaking just the commands the store
+needs. Applications already using a Redis library can adapt it to the Client
+interface instead.
+*/
+package redisstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/gob"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// DefaultMaxAge is the lifetime of sessions, in seconds, unless
+// Options.MaxAge is changed.
+const DefaultMaxAge = 60 * 60
+
+// DefaultKeyPrefix is the prefix of the Redis keys of the sessions unless
+// KeyPrefix is changed.
+const DefaultKeyPrefix = "goth:session:"
+
+// ErrNotFound is returned by Client.Get when the key doesn't exist.
+var ErrNotFound = errors.New("redisstore: key not found")
+
+// Client is the subset of the Redis commands Store uses.
+type Client interface {
+	// Get returns the value of key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set sets the value of key, which expires after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Del deletes key.
+	Del(ctx context.Context, key string) error
+}
+
+// Store is a sessions.Store keeping the values of the sessions in Redis.
+type Store struct {
+	Client  Client
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+	// KeyPrefix is prepended to the IDs of the sessions to get their Redis
+	// keys, e.g. to share a Redis database with other applications.
+	KeyPrefix string
+}
+
+var _ sessions.Store = &Store{}
+
+// New returns a Store keeping the sessions in Redis through client. The
+// cookies holding the IDs of the sessions are signed, and optionally
+// encrypted, with keyPairs, as described by sessions.NewCookieStore.
+func New(client Client, keyPairs ...[]byte) *Store {
+	s := &Store{
+		Client: client,
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:     "/",
+			MaxAge:   DefaultMaxAge,
+			HttpOnly: true,
+		},
+		KeyPrefix: DefaultKeyPrefix,
+	}
+	s.MaxAge(s.Options.MaxAge)
+	return s
+}
+
+// MaxAge sets the lifetime of the sessions, in seconds.
+func (s *Store) MaxAge(age int) {
+	s.Options.MaxAge = age
+	for _, codec := range s.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(age)
+		}
+	}
+}
+
+// Get returns the session with the given name after adding it to the
+// registry of the request.
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns the session with the given name, loaded from Redis if the
+// request has its cookie, without adding it to the registry of the request.
+// A session whose key expired is returned as a new session.
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+
+	c, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	if err := securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...); err != nil {
+		return session, err
+	}
+
+	data, err := s.Client.Get(r.Context(), s.KeyPrefix+session.ID)
+	if errors.Is(err, ErrNotFound) {
+		session.ID = ""
+		return session, nil
+	}
+	if err != nil {
+		return session, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&session.Values); err != nil {
+		return session, err
+	}
+	session.IsNew = false
+	return session, nil
+}
+
+// Save stores the values of session in Redis and sets its cookie. Sessions
+// whose Options.MaxAge is negative are deleted.
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.Client.Del(r.Context(), s.KeyPrefix+session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		session.ID = id
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(session.Values); err != nil {
+		return err
+	}
+	ttl := time.Duration(session.Options.MaxAge) * time.Second
+	if ttl == 0 {
+		// a browser session cookie, the key can't live forever though
+		ttl = DefaultMaxAge * time.Second
+	}
+	if err := s.Client.Set(r.Context(), s.KeyPrefix+session.ID, buf.Bytes(), ttl); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// newID returns a random session ID.
+func newID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(b), "="), nil
+}
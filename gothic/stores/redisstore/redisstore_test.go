@@ -0,0 +1,237 @@
+package redisstore_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/gothic/stores/redisstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Store(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	srv := newFakeRedis(t, "")
+	client := redisstore.NewClient(srv.addr, redisstore.ClientOptions{})
+	defer client.Close()
+
+	store := redisstore.New(client, []byte("0123456789abcdef0123456789abcdef"))
+	store.KeyPrefix = "app:"
+	storage := gothic.GorillaStorage{Store: store, Name: "_gothic_session"}
+
+	req := httptest.NewRequest("GET", "/auth?provider=faux", nil)
+	res := httptest.NewRecorder()
+	a.NoError(storage.Set(req, res, "faux", `{"AuthURL":"http://example.com/auth"}`))
+
+	cookies := res.Result().Cookies()
+	a.Len(cookies, 1)
+	a.True(cookies[0].HttpOnly)
+	a.Equal(redisstore.DefaultMaxAge, cookies[0].MaxAge)
+	a.NotContains(cookies[0].Value, "example.com")
+
+	keys := srv.keys()
+	a.Len(keys, 1)
+	a.True(strings.HasPrefix(keys[0], "app:"), keys[0])
+	a.Equal(time.Duration(redisstore.DefaultMaxAge)*time.Second, srv.ttl(keys[0]))
+
+	// another instance of the application reads the session
+	req = httptest.NewRequest("GET", "/auth/callback", nil)
+	req.AddCookie(cookies[0])
+	value, err := storage.Get(req, "faux")
+	a.NoError(err)
+	a.Equal(`{"AuthURL":"http://example.com/auth"}`, value)
+
+	a.NoError(storage.Clear(req, httptest.NewRecorder()))
+	a.Empty(srv.keys())
+
+	// the key expired, the session is a new one
+	req = httptest.NewRequest("GET", "/auth/callback", nil)
+	req.AddCookie(cookies[0])
+	_, err = storage.Get(req, "faux")
+	a.Error(err)
+	session, err := store.New(req, "_gothic_session")
+	a.NoError(err)
+	a.True(session.IsNew)
+
+	// cookies not signed with the keys are rejected
+	req = httptest.NewRequest("GET", "/auth/callback", nil)
+	req.AddCookie(&http.Cookie{Name: "_gothic_session", Value: "forged"})
+	_, err = store.New(req, "_gothic_session")
+	a.Error(err)
+}
+
+func Test_Client(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	srv := newFakeRedis(t, "secret")
+	ctx := context.Background()
+
+	client := redisstore.NewClient(srv.addr, redisstore.ClientOptions{})
+	_, err := client.Get(ctx, "key")
+	a.Error(err)
+	client.Close()
+
+	client = redisstore.NewClient(srv.addr, redisstore.ClientOptions{Password: "secret", DB: 2})
+	defer client.Close()
+
+	_, err = client.Get(ctx, "key")
+	a.ErrorIs(err, redisstore.ErrNotFound)
+
+	value := []byte("binary\r\n\x00value")
+	a.NoError(client.Set(ctx, "key", value, time.Minute))
+	got, err := client.Get(ctx, "key")
+	a.NoError(err)
+	a.Equal(value, got)
+	a.Equal(time.Minute, srv.ttl("key"))
+
+	a.NoError(client.Del(ctx, "key"))
+	_, err = client.Get(ctx, "key")
+	a.ErrorIs(err, redisstore.ErrNotFound)
+
+	// the connection was reused
+	a.Equal(2, srv.connections())
+
+	_, err = client.Get(ctx, "error")
+	a.EqualError(err, "redisstore: ERR forced error")
+}
+
+// fakeRedis is a Redis server supporting the few commands SimpleClient uses.
+type fakeRedis struct {
+	addr     string
+	password string
+
+	mu     sync.Mutex
+	values map[string][]byte
+	ttls   map[string]time.Duration
+	conns  int
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	srv := &fakeRedis{
+		addr:     l.Addr().String(),
+		password: password,
+		values:   map[string][]byte{},
+		ttls:     map[string]time.Duration{},
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			srv.mu.Lock()
+			srv.conns++
+			srv.mu.Unlock()
+			go srv.serve(c)
+		}
+	}()
+	return srv
+}
+
+func (s *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	authenticated := s.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		reply := "+OK\r\n"
+		s.mu.Lock()
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			authenticated = args[len(args)-1] == s.password
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case cmd == "SELECT":
+		case cmd == "GET" && args[1] == "error":
+			reply = "-ERR forced error\r\n"
+		case cmd == "GET":
+			if v, ok := s.values[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case cmd == "SET":
+			s.values[args[1]] = []byte(args[2])
+			ms, _ := strconv.Atoi(args[4])
+			s.ttls[args[1]] = time.Duration(ms) * time.Millisecond
+		case cmd == "DEL":
+			delete(s.values, args[1])
+			reply = ":1\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		s.mu.Unlock()
+
+		if _, err := io.WriteString(c, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	var n int
+	if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		var size int
+		if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func (s *fakeRedis) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func (s *fakeRedis) ttl(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ttls[key]
+}
+
+func (s *fakeRedis) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
@@ -0,0 +1,87 @@
+package gothic
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+// TokenStore, if set, is where CompleteUserAuth saves the tokens of the users
+// it authenticated, under their provider and user ID, and where StoredToken
+// finds them later on:
+//
+//	gothic.TokenStore = goth.NewMemoryTokenStore()
+//
+// Providers often only hand out a refresh token the first time a user grants
+// access, e.g. Google, so the refresh token already stored is kept when the
+// new token has none.
+var TokenStore goth.TokenStore
+
+// StoredToken returns the token of the user userID of the named provider saved
+// in TokenStore, refreshing it first if it has expired and the provider
+// offers refresh tokens, in which case the new token is saved. If the
+// provider revoked the grant, the token is deleted and the hooks registered
+// with OnGrantRevoked are called.
+func StoredToken(req *http.Request, providerName, userID string) (*oauth2.Token, error) {
+	if TokenStore == nil {
+		return nil, errors.New("gothic: no TokenStore is set")
+	}
+	ctx := req.Context()
+	token, err := TokenStore.Load(ctx, providerName, userID)
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" || !goth.TokenExpired(token, 0) {
+		return token, nil
+	}
+
+	provider, err := getProvider(req, providerName)
+	if err != nil {
+		return nil, err
+	}
+	if !provider.RefreshTokenAvailable() {
+		return token, nil
+	}
+
+	newToken, err := goth.RefreshToken(ctx, provider, token.RefreshToken)
+	if err != nil {
+		var revoked *goth.GrantRevokedError
+		if errors.As(err, &revoked) {
+			if derr := TokenStore.Delete(ctx, providerName, userID); derr != nil {
+				return nil, derr
+			}
+			GrantRevoked(req, revoked)
+		}
+		return nil, err
+	}
+	if newToken.RefreshToken == "" {
+		newToken.RefreshToken = token.RefreshToken
+	}
+	if err := TokenStore.Save(ctx, providerName, userID, newToken); err != nil {
+		return nil, err
+	}
+	return newToken, nil
+}
+
+// saveToken saves the token of user in TokenStore, if set. Users without an
+// ID or an access token are skipped, as there is nothing to store them under
+// or nothing worth storing.
+func saveToken(req *http.Request, providerName string, user goth.User) error {
+	if TokenStore == nil || user.UserID == "" || user.AccessToken == "" {
+		return nil
+	}
+	ctx := req.Context()
+	token := goth.TokenFromUser(user)
+	if token.RefreshToken == "" {
+		stored, err := TokenStore.Load(ctx, providerName, user.UserID)
+		switch {
+		case err == nil:
+			token.RefreshToken = stored.RefreshToken
+		case !errors.Is(err, goth.ErrTokenNotFound):
+			return err
+		}
+	}
+	return TokenStore.Save(ctx, providerName, user.UserID, token)
+}
@@ -0,0 +1,50 @@
+package gothic
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/markbates/goth"
+)
+
+// userKeyPrefix is the prefix of the keys the users kept by CompleteUserAuth
+// are stored under, so that they never overwrite the provider sessions.
+const userKeyPrefix = "user:"
+
+// KeepUserInSession makes CompleteUserAuth keep the authenticated user in the
+// session once the authentication completes, where GetUserFromSession and
+// IsAuthenticated find it until Logout. The user is kept with its tokens, so
+// setting a Codec to encrypt them is recommended.
+var KeepUserInSession = false
+
+// GetUserFromSession returns the user kept in the session by CompleteUserAuth
+// for the named provider, see KeepUserInSession. The provider isn't contacted,
+// so the user is returned as it was when the authentication completed, even if
+// its access token has expired since.
+func GetUserFromSession(req *http.Request, providerName string) (goth.User, error) {
+	value, err := GetFromSession(userKeyPrefix+providerName, req)
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	user := goth.User{}
+	if err := json.Unmarshal([]byte(value), &user); err != nil {
+		return goth.User{}, err
+	}
+	return user, nil
+}
+
+// IsAuthenticated reports whether a user authenticated with the named
+// provider is kept in the session, see KeepUserInSession.
+func IsAuthenticated(req *http.Request, providerName string) bool {
+	_, err := GetUserFromSession(req, providerName)
+	return err == nil
+}
+
+func keepUserInSession(res http.ResponseWriter, req *http.Request, providerName string, user goth.User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	return StoreInSession(userKeyPrefix+providerName, string(data), req, res)
+}
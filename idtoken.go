@@ -0,0 +1,30 @@
+package goth
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrIDTokenNotSupported is returned by FetchUserFromIDToken for providers
+// that don't implement IDTokenAuthenticator.
+var ErrIDTokenNotSupported = errors.New("the provider does not support signing in with an ID token")
+
+// IDTokenAuthenticator is implemented by providers able to authenticate a user
+// from an ID token the client obtained directly, e.g. through Google One Tap,
+// without going through the authorization code flow.
+type IDTokenAuthenticator interface {
+	// FetchUserFromIDToken validates idToken and returns the user it was
+	// issued for. The returned user has no access token.
+	FetchUserFromIDToken(ctx context.Context, idToken string) (User, error)
+}
+
+// FetchUserFromIDToken authenticates the user idToken was issued for with
+// provider, or returns ErrIDTokenNotSupported if the provider can't validate
+// ID tokens on their own.
+func FetchUserFromIDToken(ctx context.Context, provider Provider, idToken string) (User, error) {
+	p, ok := provider.(IDTokenAuthenticator)
+	if !ok {
+		return User{}, ErrIDTokenNotSupported
+	}
+	return p.FetchUserFromIDToken(ctx, idToken)
+}
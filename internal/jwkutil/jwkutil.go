@@ -0,0 +1,39 @@
+// Package jwkutil caches the JSON Web Key Sets the providers verify the
+// signatures of tokens with.
+package jwkutil
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// KeySet is a JSON Web Key Set refreshed in the background once first
+// fetched. Providers hold it through a pointer, so that their copies, e.g.
+// the ones made by WithCallbackURL, share it instead of each starting a
+// refresh loop.
+type KeySet struct {
+	// MinRefreshInterval is the minimum time the set is cached for,
+	// regardless of the cache headers it is served with.
+	MinRefreshInterval time.Duration
+
+	once sync.Once
+	keys *jwk.AutoRefresh
+}
+
+// Fetch returns the key set published at url. The first call starts the
+// refresh loop, whose requests are made with client, and later calls must
+// use the same url.
+func (k *KeySet) Fetch(ctx context.Context, url string, client *http.Client) (jwk.Set, error) {
+	k.once.Do(func() {
+		k.keys = jwk.NewAutoRefresh(context.Background())
+		k.keys.Configure(url,
+			jwk.WithHTTPClient(client),
+			jwk.WithMinRefreshInterval(k.MinRefreshInterval),
+		)
+	})
+	return k.keys.Fetch(ctx, url)
+}
@@ -0,0 +1,35 @@
+package jwkutil_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth/internal/jwkutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_KeySetIsCached(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{"keys":[{"kty":"oct","kid":"test","k":"c2VjcmV0"}]}`))
+	}))
+	defer ts.Close()
+
+	keys := &jwkutil.KeySet{MinRefreshInterval: time.Hour}
+	for i := 0; i < 3; i++ {
+		set, err := keys.Fetch(context.Background(), ts.URL, ts.Client())
+		a.NoError(err)
+		_, ok := set.LookupKeyID("test")
+		a.True(ok)
+	}
+	a.Equal(int32(1), atomic.LoadInt32(&requests))
+}
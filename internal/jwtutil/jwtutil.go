@@ -0,0 +1,196 @@
+// Package jwtutil decodes the claims of JSON Web Tokens and validates their
+// registered claims, for the providers reading id_tokens and access tokens.
+// It doesn't verify signatures: the providers do it with the keys of the
+// identity provider, or rely on the token having been received from the token
+// endpoint over TLS.
+package jwtutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+)
+
+var (
+	// ErrMalformed is returned by Decode for tokens which aren't JWTs.
+	ErrMalformed = errors.New("malformed JWT")
+	// ErrMissingClaim is returned by Validate when a required claim is
+	// missing or has the wrong type.
+	ErrMissingClaim = errors.New("required claim missing")
+	// ErrInvalidIssuer, ErrInvalidAudience, ErrExpired and ErrNotYetValid
+	// are returned by Validate when the corresponding claims don't match
+	// what's expected.
+	ErrInvalidIssuer   = errors.New("issuer in token does not match")
+	ErrInvalidAudience = errors.New("audience in token does not match")
+	ErrExpired         = errors.New("token is expired")
+	ErrNotYetValid     = errors.New("token is not valid yet")
+)
+
+// maxTokenSize bounds the size of the tokens decoded, tokens being received
+// from the network.
+const maxTokenSize = 64 << 10
+
+// Decode returns the claims of the compact serialized JWT token, without
+// verifying its signature. The parts of the token are base64url encoded
+// without padding, as required by RFC 7515, though padded parts are accepted
+// too. Numbers are decoded as float64, like encoding/json does.
+func Decode(token string) (map[string]interface{}, error) {
+	if len(token) > maxTokenSize {
+		return nil, fmt.Errorf("%w: token is too large", ErrMalformed)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: expected 3 parts, got %d", ErrMalformed, len(parts))
+	}
+
+	header, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+	if _, ok := header["alg"].(string); !ok {
+		return nil, fmt.Errorf("%w: header has no alg", ErrMalformed)
+	}
+
+	claims, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrMalformed, err)
+	}
+	return claims, nil
+}
+
+// decodeSegment decodes a base64url encoded JSON object.
+func decodeSegment(segment string) (map[string]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	var object map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&object); err != nil {
+		return nil, err
+	}
+	if object == nil {
+		return nil, errors.New("not a JSON object")
+	}
+	if dec.More() {
+		return nil, errors.New("trailing data after the JSON object")
+	}
+	return object, nil
+}
+
+// String returns the claim name if it is a string, or "".
+func String(claims map[string]interface{}, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+// Time returns the NumericDate claim name, the number of seconds since the
+// epoch, and whether the claim is a number. Some identity providers send it
+// as a string, which is accepted too.
+func Time(claims map[string]interface{}, name string) (time.Time, bool) {
+	var seconds float64
+	switch v := claims[name].(type) {
+	case float64:
+		seconds = v
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		seconds = f
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		seconds = f
+	default:
+		return time.Time{}, false
+	}
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return time.Time{}, false
+	}
+	sec, frac := int64(seconds), seconds-float64(int64(seconds))
+	return time.Unix(sec, int64(frac*float64(time.Second))), true
+}
+
+// Audience returns the aud claim, which is either a string or an array of
+// strings.
+func Audience(claims map[string]interface{}) []string {
+	switch v := claims["aud"].(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		audience := make([]string, 0, len(v))
+		for _, a := range v {
+			if s, ok := a.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	}
+	return nil
+}
+
+// Expected is what Validate checks the claims of a token against.
+type Expected struct {
+	// Issuer, if not empty, is the issuer the iss claim must be.
+	Issuer string
+	// Audience, if not empty, must be one of the audiences of the token.
+	Audience string
+	// RequireExpiry makes the exp claim required, as it is in id_tokens.
+	RequireExpiry bool
+	// Leeway is the clock skew tolerated when checking exp and nbf.
+	Leeway time.Duration
+}
+
+// Validate checks the iss, aud, exp and nbf claims of a token against e, at
+// the time returned by goth.Now, and returns its expiry, the zero time if it
+// has none.
+func Validate(claims map[string]interface{}, e Expected) (time.Time, error) {
+	if e.Audience != "" && !contains(Audience(claims), e.Audience) {
+		return time.Time{}, ErrInvalidAudience
+	}
+	if e.Issuer != "" && String(claims, "iss") != e.Issuer {
+		return time.Time{}, ErrInvalidIssuer
+	}
+
+	now := goth.Now()
+	if nbf, ok := Time(claims, "nbf"); ok && now.Add(e.Leeway).Before(nbf) {
+		return time.Time{}, ErrNotYetValid
+	}
+	expiry, ok := Time(claims, "exp")
+	if !ok {
+		if _, present := claims["exp"]; present || e.RequireExpiry {
+			return time.Time{}, fmt.Errorf("%w: exp", ErrMissingClaim)
+		}
+		return time.Time{}, nil
+	}
+	if expiry.Add(e.Leeway).Before(now) {
+		return time.Time{}, ErrExpired
+	}
+	return expiry, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
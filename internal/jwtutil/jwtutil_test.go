@@ -0,0 +1,118 @@
+package jwtutil_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth/internal/jwtutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func token(header, payload string, encoding *base64.Encoding) string {
+	return encoding.EncodeToString([]byte(header)) + "." + encoding.EncodeToString([]byte(payload)) + ".sig"
+}
+
+func Test_Decode(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// ~~ and ?? encode to characters which differ between the base64
+	// alphabets, and the lengths need padding
+	payload := `{"sub":"homer","name":"~~??","exp":1700000000}`
+	for _, encoding := range []*base64.Encoding{base64.RawURLEncoding, base64.URLEncoding} {
+		claims, err := jwtutil.Decode(token(`{"alg":"RS256"}`, payload, encoding))
+		a.NoError(err)
+		a.Equal("homer", jwtutil.String(claims, "sub"))
+		a.Equal("~~??", jwtutil.String(claims, "name"))
+		exp, ok := jwtutil.Time(claims, "exp")
+		a.True(ok)
+		a.Equal(int64(1700000000), exp.Unix())
+	}
+
+	for _, tok := range []string{
+		"",
+		"a.b",
+		"a.b.c.d",
+		token(`{"alg":"RS256"}`, payload, base64.StdEncoding),
+		token(`{"typ":"JWT"}`, payload, base64.RawURLEncoding),
+		token(`{"alg":"RS256"}`, `null`, base64.RawURLEncoding),
+		token(`{"alg":"RS256"}`, `["homer"]`, base64.RawURLEncoding),
+		token(`{"alg":"RS256"}`, `{"sub":"homer"}{}`, base64.RawURLEncoding),
+		token(`{"alg":"RS256"}`, `{"sub":"`+strings.Repeat("h", 64<<10)+`"}`, base64.RawURLEncoding),
+	} {
+		_, err := jwtutil.Decode(tok)
+		a.ErrorIs(err, jwtutil.ErrMalformed, tok)
+	}
+}
+
+func Test_Claims(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	claims := map[string]interface{}{
+		"aud":   []interface{}{"a", 1, "b"},
+		"iat":   "1700000000",
+		"nbf":   1700000000.5,
+		"email": 1,
+	}
+	a.Equal([]string{"a", "b"}, jwtutil.Audience(claims))
+	a.Equal("", jwtutil.String(claims, "email"))
+
+	iat, ok := jwtutil.Time(claims, "iat")
+	a.True(ok)
+	a.Equal(int64(1700000000), iat.Unix())
+	nbf, ok := jwtutil.Time(claims, "nbf")
+	a.True(ok)
+	a.Equal(500*time.Millisecond, time.Duration(nbf.Nanosecond()))
+	_, ok = jwtutil.Time(claims, "exp")
+	a.False(ok)
+
+	a.Equal([]string{"a"}, jwtutil.Audience(map[string]interface{}{"aud": "a"}))
+	a.Nil(jwtutil.Audience(map[string]interface{}{"aud": ""}))
+}
+
+func Test_Validate(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": "https://idp.example.com",
+		"aud": []interface{}{"other", "client"},
+		"exp": float64(now.Add(time.Hour).Unix()),
+	}
+	expected := jwtutil.Expected{Issuer: "https://idp.example.com", Audience: "client", RequireExpiry: true}
+
+	expiry, err := jwtutil.Validate(claims, expected)
+	a.NoError(err)
+	a.Equal(now.Add(time.Hour).Unix(), expiry.Unix())
+
+	_, err = jwtutil.Validate(claims, jwtutil.Expected{Audience: "someone-else"})
+	a.ErrorIs(err, jwtutil.ErrInvalidAudience)
+	_, err = jwtutil.Validate(claims, jwtutil.Expected{Issuer: "https://evil.example.com"})
+	a.ErrorIs(err, jwtutil.ErrInvalidIssuer)
+
+	claims["exp"] = float64(now.Add(-time.Minute).Unix())
+	_, err = jwtutil.Validate(claims, expected)
+	a.ErrorIs(err, jwtutil.ErrExpired)
+	expected.Leeway = 2 * time.Minute
+	_, err = jwtutil.Validate(claims, expected)
+	a.NoError(err)
+
+	claims["nbf"] = float64(now.Add(time.Hour).Unix())
+	_, err = jwtutil.Validate(claims, expected)
+	a.ErrorIs(err, jwtutil.ErrNotYetValid)
+	delete(claims, "nbf")
+
+	claims["exp"] = true
+	_, err = jwtutil.Validate(claims, jwtutil.Expected{})
+	a.ErrorIs(err, jwtutil.ErrMissingClaim)
+	delete(claims, "exp")
+	_, err = jwtutil.Validate(claims, expected)
+	a.ErrorIs(err, jwtutil.ErrMissingClaim)
+	expiry, err = jwtutil.Validate(claims, jwtutil.Expected{})
+	a.NoError(err)
+	a.True(expiry.IsZero())
+}
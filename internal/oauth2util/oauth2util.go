@@ -0,0 +1,52 @@
+// Package oauth2util holds the OAuth2 plumbing the providers have in common:
+// refreshing tokens, and reading and replacing the token held by a session.
+// Providers whose endpoints behave differently keep their own code.
+package oauth2util
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+// Refresh gets a new token from the token endpoint of config based on
+// refreshToken. The request is made with client and bound to ctx.
+func Refresh(ctx context.Context, config *oauth2.Config, client *http.Client, refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	return config.TokenSource(goth.ContextWithClient(ctx, client), token).Token()
+}
+
+// Token returns the token of a session keeping its access token, refresh
+// token and expiry in separate fields.
+func Token(accessToken, refreshToken string, expiry time.Time) *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Expiry:       expiry,
+	}
+}
+
+// SetToken is the counterpart of Token: it stores token in the fields of a
+// session, keeping the refresh token if token doesn't carry a new one.
+func SetToken(token *oauth2.Token, accessToken, refreshToken *string, expiry *time.Time) {
+	*accessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		*refreshToken = token.RefreshToken
+	}
+	*expiry = token.Expiry
+}
+
+// WithRedirectURL returns a copy of config sending users back to redirectURL,
+// for the WithCallbackURL methods of the providers. It returns nil if config
+// is nil.
+func WithRedirectURL(config *oauth2.Config, redirectURL string) *oauth2.Config {
+	if config == nil {
+		return nil
+	}
+	c := *config
+	c.RedirectURL = redirectURL
+	return &c
+}
@@ -0,0 +1,69 @@
+package oauth2util_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth/internal/oauth2util"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_Refresh(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		a.NoError(req.ParseForm())
+		a.Equal("refresh_token", req.Form.Get("grant_type"))
+		a.Equal("old-refresh", req.Form.Get("refresh_token"))
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{"access_token":"new-access","refresh_token":"new-refresh","token_type":"bearer","expires_in":3600}`))
+	}))
+	defer ts.Close()
+
+	config := &oauth2.Config{ClientID: "id", ClientSecret: "secret", Endpoint: oauth2.Endpoint{TokenURL: ts.URL}}
+	token, err := oauth2util.Refresh(context.Background(), config, ts.Client(), "old-refresh")
+	a.NoError(err)
+	a.Equal("new-access", token.AccessToken)
+	a.Equal("new-refresh", token.RefreshToken)
+	a.False(token.Expiry.IsZero())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = oauth2util.Refresh(ctx, config, ts.Client(), "old-refresh")
+	a.Error(err)
+}
+
+func Test_SetToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	var accessToken, refreshToken string
+	var expiresAt time.Time
+	oauth2util.SetToken(oauth2util.Token("access", "refresh", expiry), &accessToken, &refreshToken, &expiresAt)
+	a.Equal(oauth2util.Token("access", "refresh", expiry), oauth2util.Token(accessToken, refreshToken, expiresAt))
+
+	// a token without a refresh token keeps the previous one
+	oauth2util.SetToken(&oauth2.Token{AccessToken: "new-access"}, &accessToken, &refreshToken, &expiresAt)
+	a.Equal("new-access", accessToken)
+	a.Equal("refresh", refreshToken)
+	a.True(expiresAt.IsZero())
+}
+
+func Test_WithRedirectURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	config := &oauth2.Config{ClientID: "id", RedirectURL: "https://example.com/callback"}
+	c := oauth2util.WithRedirectURL(config, "https://example.org/callback")
+	a.Equal("https://example.org/callback", c.RedirectURL)
+	a.Equal("id", c.ClientID)
+	a.Equal("https://example.com/callback", config.RedirectURL)
+
+	a.Nil(oauth2util.WithRedirectURL(nil, "https://example.org/callback"))
+}
@@ -0,0 +1,406 @@
+// Package testidp provides a minimal OAuth2 and OpenID Connect identity
+// provider running on an httptest.Server, so that providers and gothic can be
+// tested through complete authentication round-trips without network access.
+//
+//	idp := testidp.New("client-id", "client-secret")
+//	defer idp.Close()
+//	idp.Claims["email"] = "homer@example.com"
+//
+//	p := openidConnect.New("client-id", "client-secret", "http://localhost/callback", idp.DiscoveryURL())
+//	sess, _ := p.BeginAuth("state")
+//	authURL, _ := sess.GetAuthURL()
+//	callback, _ := idp.Authorize(authURL) // the user consents
+//	code := callback.Query().Get("code")
+//
+// It implements the authorization code flow, with PKCE and refresh tokens,
+// token revocation (RFC 7009), and signs id_tokens with RS256. The client may authenticate with HTTP basic
+// authentication or with the client_id and client_secret parameters.
+package testidp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// keyID is the kid of the signing key, as published in the key set.
+const keyID = "testidp"
+
+// Server is the fake identity provider. Its fields may be changed between
+// requests, but not concurrently with them.
+type Server struct {
+	*httptest.Server
+
+	ClientID     string
+	ClientSecret string
+	// Claims are those of the signed in user, returned by the userinfo
+	// endpoint and included in id_tokens. "sub" defaults to "user".
+	Claims map[string]interface{}
+	// UserInfoOnlyClaims are the names of the Claims left out of id_tokens,
+	// only returned by the userinfo endpoint.
+	UserInfoOnlyClaims []string
+	// TokenLifetime is the lifetime of access tokens and id_tokens.
+	TokenLifetime time.Duration
+
+	key *rsa.PrivateKey
+
+	mu            sync.Mutex
+	codes         map[string]authRequest
+	accessTokens  map[string]bool
+	refreshTokens map[string]bool
+}
+
+type authRequest struct {
+	redirectURI   string
+	scope         string
+	nonce         string
+	codeChallenge string
+}
+
+// New starts a fake identity provider accepting the given client credentials.
+// Call Close once done with it.
+func New(clientID, clientSecret string) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic("testidp: cannot generate signing key: " + err.Error())
+	}
+
+	s := &Server{
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		Claims:        map[string]interface{}{"sub": "user"},
+		TokenLifetime: time.Hour,
+		key:           key,
+		codes:         map[string]authRequest{},
+		accessTokens:  map[string]bool{},
+		refreshTokens: map[string]bool{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/userinfo", s.userinfo)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/revoke", s.revoke)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Issuer is the issuer of the id_tokens.
+func (s *Server) Issuer() string { return s.URL }
+
+// DiscoveryURL is the URL of the OpenID Connect discovery document.
+func (s *Server) DiscoveryURL() string { return s.URL + "/.well-known/openid-configuration" }
+
+// AuthURL is the URL of the authorization endpoint.
+func (s *Server) AuthURL() string { return s.URL + "/authorize" }
+
+// TokenURL is the URL of the token endpoint.
+func (s *Server) TokenURL() string { return s.URL + "/token" }
+
+// UserInfoURL is the URL of the userinfo endpoint.
+func (s *Server) UserInfoURL() string { return s.URL + "/userinfo" }
+
+// JWKSURL is the URL of the key set the id_tokens are signed with.
+func (s *Server) JWKSURL() string { return s.URL + "/jwks" }
+
+// RevocationURL is the URL of the token revocation endpoint.
+func (s *Server) RevocationURL() string { return s.URL + "/revoke" }
+
+// EndSessionURL is the URL of the end_session_endpoint. Nothing is served
+// there, it is only published in the discovery document.
+func (s *Server) EndSessionURL() string { return s.URL + "/logout" }
+
+// Authorize plays the part of the user agent: it opens authURL, as built by a
+// provider's BeginAuth, and returns the URL the user is redirected back to,
+// carrying either a code and the state or an error.
+func (s *Server) Authorize(authURL string) (*url.URL, error) {
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	res, err := client.Get(authURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusFound {
+		return nil, fmt.Errorf("testidp: authorize responded with a %d", res.StatusCode)
+	}
+	return url.Parse(res.Header.Get("Location"))
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.Issuer(),
+		"authorization_endpoint":                s.AuthURL(),
+		"token_endpoint":                        s.TokenURL(),
+		"userinfo_endpoint":                     s.UserInfoURL(),
+		"jwks_uri":                              s.JWKSURL(),
+		"revocation_endpoint":                   s.RevocationURL(),
+		"end_session_endpoint":                  s.EndSessionURL(),
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256", "plain"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+	})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || q.Get("redirect_uri") == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if q.Get("client_id") != s.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+
+	params := redirectURI.Query()
+	if state := q.Get("state"); state != "" {
+		params.Set("state", state)
+	}
+
+	if q.Get("response_type") != "code" {
+		params.Set("error", "unsupported_response_type")
+	} else {
+		code := randomString()
+		req := authRequest{
+			redirectURI: q.Get("redirect_uri"),
+			scope:       q.Get("scope"),
+			nonce:       q.Get("nonce"),
+		}
+		if challenge := q.Get("code_challenge"); challenge != "" {
+			req.codeChallenge = challenge
+			if q.Get("code_challenge_method") == "S256" {
+				req.codeChallenge = "S256:" + challenge
+			}
+		}
+		s.mu.Lock()
+		s.codes[code] = req
+		s.mu.Unlock()
+		params.Set("code", code)
+	}
+
+	redirectURI.RawQuery = params.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+
+	if !s.authenticateClient(w, r) {
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		s.mu.Lock()
+		req, ok := s.codes[r.PostForm.Get("code")]
+		delete(s.codes, r.PostForm.Get("code"))
+		s.mu.Unlock()
+
+		if !ok || req.redirectURI != r.PostForm.Get("redirect_uri") || !verifyChallenge(req.codeChallenge, r.PostForm.Get("code_verifier")) {
+			tokenError(w, "invalid_grant")
+			return
+		}
+		s.issueTokens(w, req.scope, req.nonce)
+	case "refresh_token":
+		s.mu.Lock()
+		ok := s.refreshTokens[r.PostForm.Get("refresh_token")]
+		delete(s.refreshTokens, r.PostForm.Get("refresh_token"))
+		s.mu.Unlock()
+
+		if !ok {
+			tokenError(w, "invalid_grant")
+			return
+		}
+		s.issueTokens(w, "", "")
+	default:
+		tokenError(w, "unsupported_grant_type")
+	}
+}
+
+// revoke invalidates the access or refresh token of the request. As required
+// by RFC 7009, unknown tokens are answered with a 200 as well.
+func (s *Server) revoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("token") == "" {
+		tokenError(w, "invalid_request")
+		return
+	}
+	if !s.authenticateClient(w, r) {
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	s.mu.Lock()
+	delete(s.accessTokens, token)
+	delete(s.refreshTokens, token)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusOK)
+}
+
+// authenticateClient checks the client credentials of r, responding with an
+// invalid_client error if they are wrong.
+func (s *Server) authenticateClient(w http.ResponseWriter, r *http.Request) bool {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != s.ClientID || clientSecret != s.ClientSecret {
+		w.Header().Set("WWW-Authenticate", "Basic")
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return false
+	}
+	return true
+}
+
+func (s *Server) issueTokens(w http.ResponseWriter, scope, nonce string) {
+	accessToken, refreshToken := randomString(), randomString()
+	s.mu.Lock()
+	s.accessTokens[accessToken] = true
+	s.refreshTokens[refreshToken] = true
+	s.mu.Unlock()
+
+	res := map[string]interface{}{
+		"access_token":  accessToken,
+		"token_type":    "Bearer",
+		"expires_in":    int(s.TokenLifetime.Seconds()),
+		"refresh_token": refreshToken,
+	}
+	if scope != "" {
+		res["scope"] = scope
+	}
+
+	if scope == "" || strings.Contains(" "+scope+" ", " openid ") {
+		idToken, err := s.IDToken(nonce)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res["id_token"] = idToken
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// IDToken returns an id_token for the user, signed with the key of the server.
+func (s *Server) IDToken(nonce string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{}
+	for k, v := range s.Claims {
+		claims[k] = v
+	}
+	for _, k := range s.UserInfoOnlyClaims {
+		delete(claims, k)
+	}
+	claims["iss"] = s.Issuer()
+	claims["aud"] = s.ClientID
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(s.TokenLifetime).Unix()
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	return token.SignedString(s.key)
+}
+
+func (s *Server) userinfo(w http.ResponseWriter, r *http.Request) {
+	accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if accessToken == "" {
+		accessToken = r.URL.Query().Get("access_token")
+	}
+
+	s.mu.Lock()
+	ok := s.accessTokens[accessToken]
+	s.mu.Unlock()
+
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.Claims)
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	key, err := jwk.New(&s.key.PublicKey)
+	if err == nil {
+		err = key.Set(jwk.KeyIDKey, keyID)
+	}
+	if err == nil {
+		err = key.Set(jwk.AlgorithmKey, "RS256")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	set := jwk.NewSet()
+	set.Add(key)
+	writeJSON(w, http.StatusOK, set)
+}
+
+// verifyChallenge checks verifier against the challenge sent to the
+// authorization endpoint, prefixed with "S256:" if it was hashed.
+func verifyChallenge(challenge, verifier string) bool {
+	if challenge == "" {
+		return true
+	}
+	if strings.HasPrefix(challenge, "S256:") {
+		sum := sha256.Sum256([]byte(verifier))
+		return base64.RawURLEncoding.EncodeToString(sum[:]) == strings.TrimPrefix(challenge, "S256:")
+	}
+	return challenge == verifier
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("testidp: source of randomness unavailable: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
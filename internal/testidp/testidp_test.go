@@ -0,0 +1,80 @@
+package testidp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/markbates/goth/internal/testidp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_RoundTrip(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+	idp.Claims["email"] = "homer@example.com"
+
+	config := &oauth2.Config{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/callback",
+		Endpoint:     oauth2.Endpoint{AuthURL: idp.AuthURL(), TokenURL: idp.TokenURL()},
+		Scopes:       []string{"openid", "email"},
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	callback, err := idp.Authorize(config.AuthCodeURL("state", oauth2.S256ChallengeOption(verifier)))
+	a.NoError(err)
+	a.Equal("localhost", callback.Host)
+	a.Equal("state", callback.Query().Get("state"))
+
+	_, err = config.Exchange(context.Background(), callback.Query().Get("code"), oauth2.VerifierOption("wrong"))
+	a.Error(err, "the code must be bound to the verifier")
+
+	callback, err = idp.Authorize(config.AuthCodeURL("state", oauth2.S256ChallengeOption(verifier)))
+	a.NoError(err)
+	token, err := config.Exchange(context.Background(), callback.Query().Get("code"), oauth2.VerifierOption(verifier))
+	a.NoError(err)
+	a.NotEmpty(token.Extra("id_token"))
+
+	_, err = config.Exchange(context.Background(), callback.Query().Get("code"), oauth2.VerifierOption(verifier))
+	a.Error(err, "codes can only be used once")
+
+	res, err := config.Client(context.Background(), token).Get(idp.UserInfoURL())
+	a.NoError(err)
+	defer res.Body.Close()
+	a.Equal(http.StatusOK, res.StatusCode)
+	claims := map[string]interface{}{}
+	a.NoError(json.NewDecoder(res.Body).Decode(&claims))
+	a.Equal("user", claims["sub"])
+	a.Equal("homer@example.com", claims["email"])
+
+	refreshed, err := config.TokenSource(context.Background(), &oauth2.Token{RefreshToken: token.RefreshToken}).Token()
+	a.NoError(err)
+	a.NotEqual(token.AccessToken, refreshed.AccessToken)
+}
+
+func Test_InvalidClient(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+
+	config := &oauth2.Config{
+		ClientID:     "id",
+		ClientSecret: "wrong",
+		RedirectURL:  "http://localhost/callback",
+		Endpoint:     oauth2.Endpoint{AuthURL: idp.AuthURL(), TokenURL: idp.TokenURL()},
+	}
+	callback, err := idp.Authorize(config.AuthCodeURL("state"))
+	a.NoError(err)
+
+	_, err = config.Exchange(context.Background(), callback.Query().Get("code"))
+	a.ErrorContains(err, "invalid_client")
+}
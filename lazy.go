@@ -0,0 +1,120 @@
+package goth
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ProviderFactory creates a provider, e.g. by calling openidConnect.New,
+// which needs to reach the identity provider.
+type ProviderFactory func() (Provider, error)
+
+// LazyProviderBackoff is how long a lazy provider waits before calling its
+// factory again after it failed. The wait doubles with every consecutive
+// failure, up to LazyProviderMaxBackoff.
+var (
+	LazyProviderBackoff    = time.Second
+	LazyProviderMaxBackoff = 5 * time.Minute
+)
+
+// LazyProviderWait is how long a lazy provider waits for its factory, called
+// for another request, to return before giving up with an error.
+var LazyProviderWait = 30 * time.Second
+
+var lazyProviders = map[string]*lazyProvider{}
+
+type lazyProvider struct {
+	factory ProviderFactory
+
+	mu          sync.Mutex
+	provider    Provider
+	err         error
+	failures    int
+	nextAttempt time.Time
+	// creating is closed once the running call to factory, if any, returns.
+	creating chan struct{}
+}
+
+// UseLazyProvider registers a provider that is only created, by calling
+// factory, the first time it is needed. This keeps an identity provider that
+// is down at startup from breaking the application: the factory is called
+// again on later use, backing off after each failure (see LazyProviderBackoff).
+// The factory is called once at a time, the other uses waiting for it (see
+// LazyProviderWait).
+//
+// Lazy providers aren't returned by GetProviders, even once created, but
+// their names are by ProviderNames.
+//
+// name should be the name the created provider reports with Name().
+func UseLazyProvider(name string, factory ProviderFactory) {
+	lazyProviders[name] = &lazyProvider{factory: factory}
+}
+
+// Healthy reports whether the named provider is ready for use. For a lazy
+// provider that hasn't been created yet, it tries to create it, and returns
+// the error of the last attempt if that isn't possible.
+func Healthy(name string) error {
+	_, err := GetProvider(name)
+	return err
+}
+
+func getLazyProvider(name string) (Provider, bool, error) {
+	lp, ok := lazyProviders[name]
+	if !ok {
+		return nil, false, nil
+	}
+	p, err := lp.get()
+	return p, true, err
+}
+
+func (lp *lazyProvider) get() (Provider, error) {
+	lp.mu.Lock()
+	for lp.creating != nil {
+		// another call is running the factory, which may take long to reach
+		// the identity provider: wait for it without holding the lock
+		creating := lp.creating
+		lp.mu.Unlock()
+		select {
+		case <-creating:
+		case <-time.After(LazyProviderWait):
+			return nil, errors.New("timed out waiting for the factory")
+		}
+		lp.mu.Lock()
+	}
+
+	if lp.provider != nil || (lp.err != nil && Now().Before(lp.nextAttempt)) {
+		defer lp.mu.Unlock()
+		return lp.provider, lp.err
+	}
+	creating := make(chan struct{})
+	lp.creating = creating
+	lp.mu.Unlock()
+
+	p, err := lp.factory()
+	if err == nil && p == nil {
+		err = errors.New("the factory returned no provider")
+	}
+
+	lp.mu.Lock()
+	defer lp.mu.Unlock()
+	lp.creating = nil
+	close(creating)
+	if err != nil {
+		backoff := LazyProviderBackoff
+		for i := 0; i < lp.failures && backoff < LazyProviderMaxBackoff; i++ {
+			backoff *= 2
+		}
+		if backoff > LazyProviderMaxBackoff {
+			backoff = LazyProviderMaxBackoff
+		}
+		lp.failures++
+		lp.err = err
+		lp.nextAttempt = Now().Add(backoff)
+		return nil, err
+	}
+
+	lp.provider = p
+	lp.err = nil
+	return p, nil
+}
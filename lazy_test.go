@@ -0,0 +1,140 @@
+package goth_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UseLazyProvider(t *testing.T) {
+	a := assert.New(t)
+	defer goth.ClearProviders()
+
+	calls := 0
+	provider := &faux.Provider{}
+	goth.UseLazyProvider("faux", func() (goth.Provider, error) {
+		calls++
+		return provider, nil
+	})
+	a.Equal(0, calls)
+
+	p, err := goth.GetProvider("faux")
+	a.NoError(err)
+	a.Equal(provider, p)
+
+	a.NoError(goth.Healthy("faux"))
+	a.Equal(1, calls)
+}
+
+func Test_UseLazyProvider_Backoff(t *testing.T) {
+	a := assert.New(t)
+	defer goth.ClearProviders()
+
+	backoff := goth.LazyProviderBackoff
+	defer func() { goth.LazyProviderBackoff = backoff }()
+
+	calls := 0
+	down := true
+	goth.UseLazyProvider("faux", func() (goth.Provider, error) {
+		calls++
+		if down {
+			return nil, errors.New("discovery failed")
+		}
+		return &faux.Provider{}, nil
+	})
+
+	goth.LazyProviderBackoff = time.Hour
+	_, err := goth.GetProvider("faux")
+	a.Error(err)
+	a.Contains(err.Error(), "discovery failed")
+
+	// the factory isn't called again before the backoff has elapsed
+	down = false
+	a.Error(goth.Healthy("faux"))
+	a.Equal(1, calls)
+
+	goth.ClearProviders()
+	goth.LazyProviderBackoff = 0
+	down = true
+	goth.UseLazyProvider("faux", func() (goth.Provider, error) {
+		calls++
+		if down {
+			return nil, errors.New("discovery failed")
+		}
+		return &faux.Provider{}, nil
+	})
+
+	a.Error(goth.Healthy("faux"))
+	down = false
+	a.NoError(goth.Healthy("faux"))
+	a.Equal(3, calls)
+}
+
+func Test_UseLazyProvider_BackoffUsesClock(t *testing.T) {
+	a := assert.New(t)
+	defer goth.ClearProviders()
+	defer goth.SetClock(nil)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	goth.SetClock(func() time.Time { return now })
+
+	calls := 0
+	goth.UseLazyProvider("faux", func() (goth.Provider, error) {
+		calls++
+		return nil, errors.New("discovery failed")
+	})
+
+	a.Error(goth.Healthy("faux"))
+	a.Error(goth.Healthy("faux"))
+	a.Equal(1, calls)
+
+	now = now.Add(goth.LazyProviderBackoff)
+	a.Error(goth.Healthy("faux"))
+	a.Equal(2, calls)
+}
+
+func Test_UseLazyProvider_SingleFlight(t *testing.T) {
+	a := assert.New(t)
+	defer goth.ClearProviders()
+
+	wait := goth.LazyProviderWait
+	defer func() { goth.LazyProviderWait = wait }()
+
+	var calls int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	provider := &faux.Provider{}
+	goth.UseLazyProvider("faux", func() (goth.Provider, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return provider, nil
+	})
+
+	results := make(chan error, 2)
+	go func() {
+		_, err := goth.GetProvider("faux")
+		results <- err
+	}()
+	<-started
+
+	// a use while the factory runs waits for it, up to LazyProviderWait
+	goth.LazyProviderWait = 10 * time.Millisecond
+	a.Error(goth.Healthy("faux"))
+
+	goth.LazyProviderWait = time.Minute
+	go func() {
+		_, err := goth.GetProvider("faux")
+		results <- err
+	}()
+	close(release)
+	a.NoError(<-results)
+	a.NoError(<-results)
+	a.Equal(int32(1), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,151 @@
+package goth
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger receives the debug output of Goth. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+var (
+	loggerMu sync.RWMutex
+	logger   Logger
+)
+
+// SetLogger enables debug logging of the requests providers make to identity
+// providers, e.g. to exchange a code or fetch the user, and of the responses
+// they get. Tokens, secrets and codes are redacted before being logged.
+// Passing nil disables the logging again.
+//
+//	goth.SetLogger(log.New(os.Stderr, "", log.LstdFlags))
+func SetLogger(l Logger) {
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
+	logger = l
+}
+
+func currentLogger() Logger {
+	loggerMu.RLock()
+	defer loggerMu.RUnlock()
+	return logger
+}
+
+// Logf writes to the logger set with SetLogger, if any. Providers use it for
+// their debug output, which must be passed through Redact first.
+func Logf(format string, v ...interface{}) {
+	if l := currentLogger(); l != nil {
+		l.Printf(format, v...)
+	}
+}
+
+// withLogging returns a copy of h logging its requests, or h itself if no
+// logger is set.
+func withLogging(h *http.Client) *http.Client {
+	l := currentLogger()
+	if l == nil {
+		return h
+	}
+	if _, ok := h.Transport.(*LoggingTransport); ok {
+		return h
+	}
+	c := *h
+	c.Transport = &LoggingTransport{Base: h.Transport, Logger: l}
+	return &c
+}
+
+// LoggingTransport is an http.RoundTripper logging the requests it makes and
+// the responses it gets, with tokens, secrets and codes redacted. The clients
+// returned by HTTPClientWithFallBack use it when a logger is set with
+// SetLogger.
+type LoggingTransport struct {
+	// Base is the RoundTripper making the requests. http.DefaultTransport is
+	// used if nil.
+	Base http.RoundTripper
+	// Logger receives the output. The logger set with SetLogger is used if nil.
+	Logger Logger
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *LoggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	l := t.Logger
+	if l == nil {
+		l = currentLogger()
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if l == nil {
+		return base.RoundTrip(req)
+	}
+
+	var reqBody []byte
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			reqBody, _ = io.ReadAll(body)
+			body.Close()
+		}
+	}
+	l.Printf("goth: %s %s %s", req.Method, redactURL(req.URL), Redact(string(reqBody)))
+
+	start := time.Now()
+	res, err := base.RoundTrip(req)
+	if err != nil {
+		l.Printf("goth: %s %s failed after %s: %v", req.Method, redactURL(req.URL), time.Since(start), err)
+		return res, err
+	}
+
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+	if err != nil {
+		l.Printf("goth: %s %s responded with a %d after %s, reading the body failed: %v", req.Method, redactURL(req.URL), res.StatusCode, time.Since(start), err)
+		return res, nil
+	}
+	l.Printf("goth: %s %s responded with a %d after %s: %s", req.Method, redactURL(req.URL), res.StatusCode, time.Since(start), Redact(string(resBody)))
+	return res, nil
+}
+
+// sensitiveParams are the parameters whose values are never logged. Besides
+// those of OAuth2 and OAuth1, they include the Web API key of Steam ("key"),
+// and the signature and session key of last.fm ("api_sig" and "sk", the latter
+// returned as a <key> element).
+var sensitiveParams = []string{
+	"access_token", "refresh_token", "id_token", "client_secret", "code",
+	"code_verifier", "client_assertion", "password", "oauth_token_secret",
+	"oauth_token", "oauth_signature", "assertion", "subject_token", "key",
+	"api_sig", "sk",
+}
+
+var (
+	redactJSON = regexp.MustCompile(`("(?:` + strings.Join(sensitiveParams, "|") + `)"\s*:\s*)"[^"]*"`)
+	redactForm = regexp.MustCompile(`((?:^|[&?])(?:` + strings.Join(sensitiveParams, "|") + `)=)[^&\s]*`)
+	redactXML  = regexp.MustCompile(`(<(?:` + strings.Join(sensitiveParams, "|") + `)>)[^<]*`)
+)
+
+// Redact replaces the values of tokens, secrets and codes found in s, which
+// may hold JSON, XML or form encoded parameters, with "REDACTED".
+func Redact(s string) string {
+	s = redactJSON.ReplaceAllString(s, `$1"REDACTED"`)
+	s = redactXML.ReplaceAllString(s, "${1}REDACTED")
+	return redactForm.ReplaceAllString(s, "${1}REDACTED")
+}
+
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	c := *u
+	c.User = nil
+	c.RawQuery = Redact(c.RawQuery)
+	return c.String()
+}
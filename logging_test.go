@@ -0,0 +1,103 @@
+package goth_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+type bufferLogger struct {
+	lines []string
+}
+
+func (l *bufferLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func Test_Redact(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Equal(`{"access_token": "REDACTED","token_type":"bearer","refresh_token":"REDACTED"}`,
+		goth.Redact(`{"access_token": "abc","token_type":"bearer","refresh_token":"def"}`))
+	a.Equal("grant_type=authorization_code&code=REDACTED&client_secret=REDACTED&redirect_uri=%2Fcb",
+		goth.Redact("grant_type=authorization_code&code=xyz&client_secret=s3cr3t&redirect_uri=%2Fcb"))
+	a.Equal("access_token=REDACTED&scope=user", goth.Redact("access_token=abc&scope=user"))
+
+	// OAuth1
+	a.Equal("oauth_token=REDACTED&oauth_token_secret=REDACTED&oauth_callback_confirmed=true",
+		goth.Redact("oauth_token=abc&oauth_token_secret=def&oauth_callback_confirmed=true"))
+	// Steam
+	a.Equal("/ISteamUser/GetPlayerSummaries/v0002/?key=REDACTED&steamids=76561197960435530",
+		goth.Redact("/ISteamUser/GetPlayerSummaries/v0002/?key=ABCDEF&steamids=76561197960435530"))
+	// last.fm
+	a.Equal("method=auth.getSession&api_key=key&token=t&api_sig=REDACTED",
+		goth.Redact("method=auth.getSession&api_key=key&token=t&api_sig=0123abcd"))
+	a.Equal("method=user.getInfo&sk=REDACTED&user=rj", goth.Redact("method=user.getInfo&sk=d580d57f&user=rj"))
+	a.Equal(`<lfm status="ok"><session><name>rj</name><key>REDACTED</key><subscriber>0</subscriber></session></lfm>`,
+		goth.Redact(`<lfm status="ok"><session><name>rj</name><key>d580d57f32848f5dcf574d1ce18d78b2</key><subscriber>0</subscriber></session></lfm>`))
+	a.Equal(`{"session":{"name":"rj","key":"REDACTED"}}`, goth.Redact(`{"session":{"name":"rj","key":"d580d57f"}}`))
+}
+
+func Test_LoggingTransport(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"secret-token","expires_in":3600}`))
+	}))
+	defer ts.Close()
+
+	l := &bufferLogger{}
+	client := &http.Client{Transport: &goth.LoggingTransport{Logger: l}}
+	res, err := client.PostForm(ts.URL+"/token", url.Values{"code": {"the-code"}, "grant_type": {"authorization_code"}})
+	a.NoError(err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	a.NoError(err)
+	a.Equal(`{"access_token":"secret-token","expires_in":3600}`, string(body))
+
+	a.Len(l.lines, 2)
+	a.Contains(l.lines[0], "POST "+ts.URL+"/token")
+	a.Contains(l.lines[0], "code=REDACTED")
+	a.Contains(l.lines[1], "responded with a 200")
+	a.Contains(l.lines[1], `"access_token":"REDACTED"`)
+	for _, line := range l.lines {
+		a.False(strings.Contains(line, "secret-token") || strings.Contains(line, "the-code"), line)
+	}
+}
+
+func Test_SetLogger(t *testing.T) {
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	h := &http.Client{}
+	a.Same(h, goth.HTTPClientWithFallBack(h))
+
+	l := &bufferLogger{}
+	goth.SetLogger(l)
+	defer goth.SetLogger(nil)
+
+	client := goth.HTTPClientWithFallBack(h)
+	a.IsType(&goth.LoggingTransport{}, client.Transport)
+	a.Nil(h.Transport)
+
+	res, err := client.Get(ts.URL + "?access_token=abc")
+	a.NoError(err)
+	res.Body.Close()
+	a.Len(l.lines, 2)
+	a.Contains(l.lines[0], "GET "+ts.URL+"?access_token=REDACTED")
+}
@@ -0,0 +1,44 @@
+package goth
+
+import (
+	"errors"
+	"net/url"
+)
+
+// EndSessionProvider is implemented by providers supporting OpenID Connect
+// RP-Initiated Logout, i.e. logging the user out of the identity provider
+// itself rather than only out of the application.
+// See https://openid.net/specs/openid-connect-rpinitiated-1_0.html
+type EndSessionProvider interface {
+	// LogoutURL returns the URL of the provider's end_session_endpoint the
+	// browser should be redirected to. idTokenHint is the id_token previously
+	// issued to the user and postLogoutRedirect is where the provider sends
+	// the user back to; both are optional.
+	LogoutURL(idTokenHint, postLogoutRedirect string) (string, error)
+}
+
+// EndSessionURL builds an RP-Initiated Logout URL for the given end session
+// endpoint. Empty values are left out of the query.
+func EndSessionURL(endpoint, clientID, idTokenHint, postLogoutRedirect string) (string, error) {
+	if endpoint == "" {
+		return "", errors.New("the provider has no end session endpoint")
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	if idTokenHint != "" {
+		q.Set("id_token_hint", idTokenHint)
+	}
+	if clientID != "" {
+		q.Set("client_id", clientID)
+	}
+	if postLogoutRedirect != "" {
+		q.Set("post_logout_redirect_uri", postLogoutRedirect)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
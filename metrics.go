@@ -0,0 +1,122 @@
+package goth
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Outcomes of the authentication processes reported to Metrics.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// UnknownProvider is the provider reported to Metrics for the callbacks naming
+// no provider, or one that isn't registered, so that requests can't create
+// labels at will.
+const UnknownProvider = "unknown"
+
+// Metrics receives the measurements of Goth, to be exported e.g. as
+// Prometheus counters and histograms or OpenTelemetry instruments, labeled by
+// provider and outcome. Implementations must be safe for concurrent use.
+//
+// gothic reports the authentication processes it starts and completes, and
+// the clients returned by HTTPClientWithFallBack report the requests made to
+// identity providers.
+type Metrics interface {
+	// AuthBegun is called when an authentication process with the named
+	// provider starts.
+	AuthBegun(provider string)
+	// AuthCompleted is called when the callback of an authentication process
+	// with the named provider, or UnknownProvider, has been handled, with its
+	// outcome, either OutcomeSuccess or OutcomeFailure, and the time handling
+	// it took.
+	AuthCompleted(provider, outcome string, duration time.Duration)
+	// ProviderRequest is called for every request made to an identity
+	// provider, with the host of the provider, the status of the response, or
+	// 0 if no response was received, and the time the request took.
+	ProviderRequest(host string, status int, duration time.Duration)
+}
+
+var (
+	metricsMu sync.RWMutex
+	metrics   Metrics
+)
+
+// SetMetrics makes Goth report its measurements to m. Passing nil disables
+// the reporting again.
+func SetMetrics(m Metrics) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metrics = m
+}
+
+// GetMetrics returns the Metrics set with SetMetrics, or one discarding the
+// measurements if none is set.
+func GetMetrics() Metrics {
+	if m := currentMetrics(); m != nil {
+		return m
+	}
+	return nopMetrics{}
+}
+
+func currentMetrics() Metrics {
+	metricsMu.RLock()
+	defer metricsMu.RUnlock()
+	return metrics
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) AuthBegun(string)                            {}
+func (nopMetrics) AuthCompleted(string, string, time.Duration) {}
+func (nopMetrics) ProviderRequest(string, int, time.Duration)  {}
+
+// withMetrics returns a copy of h reporting its requests, or h itself if no
+// Metrics is set.
+func withMetrics(h *http.Client) *http.Client {
+	m := currentMetrics()
+	if m == nil {
+		return h
+	}
+	if _, ok := h.Transport.(*MetricsTransport); ok {
+		return h
+	}
+	c := *h
+	c.Transport = &MetricsTransport{Base: h.Transport, Metrics: m}
+	return &c
+}
+
+// MetricsTransport is an http.RoundTripper reporting the requests it makes to
+// Metrics. The clients returned by HTTPClientWithFallBack use it when a
+// Metrics is set with SetMetrics.
+type MetricsTransport struct {
+	// Base is the RoundTripper making the requests. http.DefaultTransport is
+	// used if nil.
+	Base http.RoundTripper
+	// Metrics receives the measurements. The Metrics set with SetMetrics is
+	// used if nil.
+	Metrics Metrics
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *MetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	m := t.Metrics
+	if m == nil {
+		m = GetMetrics()
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	start := time.Now()
+	res, err := base.RoundTrip(req)
+	status := 0
+	if err == nil {
+		status = res.StatusCode
+	}
+	m.ProviderRequest(req.URL.Host, status, time.Since(start))
+	return res, err
+}
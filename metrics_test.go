@@ -0,0 +1,74 @@
+package goth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMetrics records the measurements it receives.
+type recordingMetrics struct {
+	mu        sync.Mutex
+	begun     []string
+	completed []string
+	requests  []string
+	statuses  []int
+}
+
+func (m *recordingMetrics) AuthBegun(provider string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.begun = append(m.begun, provider)
+}
+
+func (m *recordingMetrics) AuthCompleted(provider, outcome string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.completed = append(m.completed, provider+":"+outcome)
+}
+
+func (m *recordingMetrics) ProviderRequest(host string, status int, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, host)
+	m.statuses = append(m.statuses, status)
+}
+
+func Test_SetMetrics(t *testing.T) {
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer ts.Close()
+
+	h := &http.Client{}
+	a.Same(h, goth.HTTPClientWithFallBack(h))
+	a.NotNil(goth.GetMetrics())
+
+	m := &recordingMetrics{}
+	goth.SetMetrics(m)
+	defer goth.SetMetrics(nil)
+	a.Same(m, goth.GetMetrics())
+
+	client := goth.HTTPClientWithFallBack(h)
+	a.IsType(&goth.MetricsTransport{}, client.Transport)
+
+	res, err := client.Get(ts.URL)
+	a.NoError(err)
+	res.Body.Close()
+
+	_, err = client.Get("http://127.0.0.1:0/unreachable")
+	a.Error(err)
+
+	u, err := url.Parse(ts.URL)
+	a.NoError(err)
+	a.Equal([]string{u.Host, "127.0.0.1:0"}, m.requests)
+	a.Equal([]int{http.StatusTeapot, 0}, m.statuses)
+}
@@ -0,0 +1,31 @@
+package goth
+
+import "golang.org/x/oauth2"
+
+// PKCE support, see https://datatracker.ietf.org/doc/html/rfc7636
+//
+// Providers opting into PKCE generate a verifier in BeginAuth, keep it in
+// their Session so it survives the round-trip to the provider, add the
+// challenge to the auth URL and send the verifier back on the token exchange:
+//
+//	verifier := goth.GeneratePKCEVerifier()
+//	url := config.AuthCodeURL(state, goth.PKCEChallengeOption(verifier))
+//	...
+//	token, err := config.Exchange(ctx, code, goth.PKCEVerifierOption(verifier))
+
+// GeneratePKCEVerifier returns a new, random code_verifier.
+func GeneratePKCEVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// PKCEChallengeOption returns the option adding the S256 code_challenge
+// derived from verifier to an authorization URL.
+func PKCEChallengeOption(verifier string) oauth2.AuthCodeOption {
+	return oauth2.S256ChallengeOption(verifier)
+}
+
+// PKCEVerifierOption returns the option sending verifier as the
+// code_verifier on the token exchange.
+func PKCEVerifierOption(verifier string) oauth2.AuthCodeOption {
+	return oauth2.VerifierOption(verifier)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
 
 	"golang.org/x/oauth2"
 )
@@ -21,6 +22,14 @@ type Provider interface {
 	RefreshTokenAvailable() bool                             // Refresh token is provided by auth provider or not
 }
 
+// CallbackURLProvider is implemented by providers able to send users back to
+// another callback URL than the one they were created with, e.g. to each of
+// the domains an application is served on.
+type CallbackURLProvider interface {
+	// WithCallbackURL returns a copy of the provider using callbackURL.
+	WithCallbackURL(callbackURL string) Provider
+}
+
 const NoAuthUrlErrorMessage = "an AuthURL has not been set"
 
 // Providers is list of known/available providers.
@@ -37,16 +46,41 @@ func UseProviders(viders ...Provider) {
 	}
 }
 
-// GetProviders returns a list of all the providers currently in use.
+// GetProviders returns a list of all the providers currently in use. The lazy
+// providers registered with UseLazyProvider aren't included, as listing them
+// would mean creating them; ProviderNames returns their names as well.
 func GetProviders() Providers {
 	return providers
 }
 
+// ProviderNames returns the sorted names of the providers currently in use,
+// including the lazy ones registered with UseLazyProvider, which aren't
+// created to find them.
+func ProviderNames() []string {
+	names := make([]string, 0, len(providers)+len(lazyProviders))
+	for name := range providers {
+		names = append(names, name)
+	}
+	for name := range lazyProviders {
+		if _, ok := providers[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // GetProvider returns a previously created provider. If Goth has not
 // been told to use the named provider it will return an error.
 func GetProvider(name string) (Provider, error) {
 	provider := providers[name]
 	if provider == nil {
+		if p, ok, err := getLazyProvider(name); ok {
+			if err != nil {
+				return nil, fmt.Errorf("provider %s is unavailable: %w", name, err)
+			}
+			return p, nil
+		}
 		return nil, fmt.Errorf("no provider for %s exists", name)
 	}
 	return provider, nil
@@ -56,6 +90,7 @@ func GetProvider(name string) (Provider, error) {
 // This is useful, mostly, for testing purposes.
 func ClearProviders() {
 	providers = Providers{}
+	lazyProviders = map[string]*lazyProvider{}
 }
 
 // ContextForClient provides a context for use with oauth2.
@@ -66,10 +101,24 @@ func ContextForClient(h *http.Client) context.Context {
 	return context.WithValue(oauth2.NoContext, oauth2.HTTPClient, h)
 }
 
-// HTTPClientWithFallBack to be used in all fetch operations.
+// ContextWithClient returns a copy of ctx carrying h for use with oauth2.
+func ContextWithClient(ctx context.Context, h *http.Client) context.Context {
+	if h == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, oauth2.HTTPClient, h)
+}
+
+// HTTPClientWithFallBack to be used in all fetch operations. It returns
+// DefaultClient if h is nil. The requests of the returned client are logged
+// if a logger is set with SetLogger, and reported to the Metrics set with
+// SetMetrics.
 func HTTPClientWithFallBack(h *http.Client) *http.Client {
-	if h != nil {
-		return h
+	if h == nil {
+		h = DefaultClient
+	}
+	if h == nil {
+		h = http.DefaultClient
 	}
-	return http.DefaultClient
+	return withMetrics(withLogging(h))
 }
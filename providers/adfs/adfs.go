@@ -0,0 +1,257 @@
+// Package adfs implements the OAuth2 and OpenID Connect protocols for authenticating
+// users through Active Directory Federation Services (AD FS 2016 and later).
+package adfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/jwtutil"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
+)
+
+// See https://learn.microsoft.com/en-us/windows-server/identity/ad-fs/overview/ad-fs-openid-connect-oauth-concepts
+const (
+	authPath   string = "/adfs/oauth2/authorize"
+	tokenPath  string = "/adfs/oauth2/token"
+	logoutPath string = "/adfs/oauth2/logout"
+)
+
+// Scopes requested by default.
+const (
+	ScopeOpenID      = "openid"
+	ScopeProfile     = "profile"
+	ScopeEmail       = "email"
+	ScopeAllAtClaims = "allatclaims"
+)
+
+// PromptLogin makes AD FS ask the user for their credentials again, instead
+// of signing them in silently with Windows Integrated Authentication or an
+// existing single sign-on session.
+const PromptLogin = "login"
+
+// Options are the optional settings of a Provider.
+type Options struct {
+	// Resource is the identifier of the relying party trust of the API the
+	// access token is requested for, sent as the resource parameter AD FS
+	// expects. AD FS issues a token for its UserInfo endpoint if empty.
+	Resource string
+	// Scopes defaults to openid, profile, email and allatclaims, which makes
+	// AD FS add the claims issued for the access token, e.g. the groups, to
+	// the id_token.
+	Scopes []string
+	// Prompt is sent as the prompt parameter of every authentication, e.g.
+	// PromptLogin. Single sign-on is used if empty. It can also be set per
+	// authentication with BeginAuthWithParams.
+	Prompt string
+}
+
+// Provider is the implementation of `goth.Provider` for accessing AD FS.
+type Provider struct {
+	ClientKey   string
+	Secret      string
+	CallbackURL string
+	HTTPClient  *http.Client
+	goth.AdditionalParams
+	// ServerURL is the URL of the AD FS farm, e.g. https://adfs.example.com.
+	ServerURL    string
+	config       *oauth2.Config
+	providerName string
+	prompt       string
+}
+
+// New creates a new AD FS provider for the farm at serverURL, and sets up
+// important connection details. You should always call `adfs.New` to get a
+// new Provider. Never try to create one manually.
+func New(clientKey, secret, callbackURL, serverURL string, opts Options) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		ServerURL:    strings.TrimSuffix(serverURL, "/"),
+		providerName: "adfs",
+		prompt:       opts.Prompt,
+	}
+	if opts.Resource != "" {
+		resource := url.Values{"resource": {opts.Resource}}
+		p.SetAdditionalParams(resource, resource)
+	}
+	p.config = newConfig(p, opts.Scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
+// Client is HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return p.TokenClient(goth.HTTPClientWithFallBack(p.HTTPClient))
+}
+
+// Debug is a no-op for the adfs package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks AD FS for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.BeginAuthWithParams(state, nil)
+}
+
+// BeginAuthWithParams is like BeginAuth, adding params (e.g. prompt,
+// login_hint or domain_hint) to the authentication end-point.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
+	opts := p.AuthCodeOptions()
+	if p.prompt != "" && params.Get("prompt") == "" {
+		opts = append(opts, oauth2.SetAuthURLParam("prompt", p.prompt))
+	}
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state, append(opts, goth.AuthURLParamOptions(params)...)...),
+	}, nil
+}
+
+// FetchUser returns the user described by the id_token of the session, as
+// the UserInfo endpoint of AD FS only returns the subject. The names of the
+// groups, or their SIDs if AD FS issues the group SID claim instead, are
+// available in User.Groups, the roles in User.Roles, and all the claims in
+// RawData.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+		IDToken:      sess.IDToken,
+		Provider:     p.Name(),
+	}
+
+	if user.AccessToken == "" {
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+	if sess.IDToken == "" {
+		return user, errors.New("adfs: no id_token was issued, the openid scope must be asked for")
+	}
+
+	// the id_token was received from the token endpoint over TLS, so its
+	// signature isn't checked, but it must have been issued to this client
+	claims, err := jwtutil.Decode(sess.IDToken)
+	if err != nil {
+		return user, err
+	}
+	if _, err := jwtutil.Validate(claims, jwtutil.Expected{Audience: p.ClientKey, Leeway: time.Minute}); err != nil {
+		return user, fmt.Errorf("adfs: invalid id_token: %w", err)
+	}
+	userFromClaims(claims, &user)
+	return user, nil
+}
+
+func userFromClaims(claims map[string]interface{}, user *goth.User) {
+	claim := func(names ...string) string {
+		for _, name := range names {
+			if s, ok := claims[name].(string); ok && s != "" {
+				return s
+			}
+		}
+		return ""
+	}
+
+	user.RawData = claims
+	user.UserID = claim("sub")
+	user.NickName = claim("upn", "unique_name")
+	user.Email = claim("email", "upn")
+	user.FirstName = claim("given_name")
+	user.LastName = claim("family_name")
+	user.Name = claim("name", "unique_name")
+	if user.Name == "" {
+		user.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+
+	if groups, ok := claims["group"]; ok {
+		user.Groups = toStrings(groups)
+	} else if sids, ok := claims["groupsid"]; ok {
+		user.Groups = toStrings(sids)
+	}
+	if roles, ok := claims["role"]; ok {
+		user.Roles = toStrings(roles)
+	}
+}
+
+// toStrings returns the values of a claim, which AD FS issues as a string
+// when it has a single value and as an array otherwise.
+func toStrings(v interface{}) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, value := range v {
+			if s, ok := value.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
+}
+
+// LogoutURL returns the URL to redirect the user to in order to sign them out
+// of AD FS.
+func (p *Provider) LogoutURL(idTokenHint, postLogoutRedirect string) (string, error) {
+	return goth.EndSessionURL(p.ServerURL+logoutPath, p.ClientKey, idTokenHint, postLogoutRedirect)
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  provider.ServerURL + authPath,
+			TokenURL: provider.ServerURL + tokenPath,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeAllAtClaims}
+	}
+
+	return c
+}
@@ -0,0 +1,130 @@
+package adfs_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/adfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := adfsProvider()
+
+	a.Equal("adfs", p.Name())
+	a.Equal("client-id", p.ClientKey)
+	a.Equal("secret", p.Secret)
+	a.Equal("/foo", p.CallbackURL)
+	a.Equal("https://adfs.example.com", p.ServerURL)
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), adfsProvider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	session, err := adfsProvider().BeginAuth("test_state")
+	a.NoError(err)
+	u, err := url.Parse(session.(*adfs.Session).AuthURL)
+	a.NoError(err)
+	a.Equal("adfs.example.com", u.Host)
+	a.Equal("/adfs/oauth2/authorize", u.Path)
+	a.Equal("openid profile email allatclaims", u.Query().Get("scope"))
+	a.Equal("urn:api", u.Query().Get("resource"))
+	a.Equal("", u.Query().Get("prompt"))
+
+	p := adfs.New("client-id", "secret", "/foo", "https://adfs.example.com", adfs.Options{Prompt: adfs.PromptLogin})
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*adfs.Session).AuthURL, "prompt=login")
+
+	session, err = p.BeginAuthWithParams("test_state", url.Values{"prompt": {"none"}})
+	a.NoError(err)
+	u, err = url.Parse(session.(*adfs.Session).AuthURL)
+	a.NoError(err)
+	a.Equal([]string{"none"}, u.Query()["prompt"])
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	session, err := adfsProvider().UnmarshalSession(`{"au":"https://adfs.example.com/adfs/oauth2/authorize","at":"1234567890"}`)
+	a.NoError(err)
+	s := session.(*adfs.Session)
+	a.Equal("https://adfs.example.com/adfs/oauth2/authorize", s.AuthURL)
+	a.Equal("1234567890", s.AccessToken)
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"aud":         "client-id",
+		"exp":         time.Now().Add(time.Hour).Unix(),
+		"sub":         "Jz9cWMdKfIlYnKCN2hQ2ZA==",
+		"upn":         "homer@example.com",
+		"unique_name": `EXAMPLE\homer`,
+		"given_name":  "Homer",
+		"family_name": "Simpson",
+		"groupsid":    "S-1-5-21-1004336348-1177238915-682003330-513",
+		"role":        []string{"Admins", "Users"},
+	}).SignedString([]byte("key"))
+	a.NoError(err)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/adfs/oauth2/token", r.URL.Path)
+		a.NoError(r.ParseForm())
+		a.Equal("urn:api", r.PostForm.Get("resource"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","token_type":"bearer","expires_in":3600,"id_token":"` + idToken + `"}`))
+	}))
+	defer ts.Close()
+
+	p := adfs.New("client-id", "secret", "/foo", ts.URL+"/", adfs.Options{Resource: "urn:api"})
+	s := &adfs.Session{}
+	_, err = s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Equal(idToken, s.IDToken)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("Jz9cWMdKfIlYnKCN2hQ2ZA==", user.UserID)
+	a.Equal("homer@example.com", user.NickName)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal(`EXAMPLE\homer`, user.Name)
+	a.Equal("Homer", user.FirstName)
+	a.Equal([]string{"S-1-5-21-1004336348-1177238915-682003330-513"}, user.Groups)
+	a.Equal([]string{"Admins", "Users"}, user.Roles)
+	a.Equal("refresh", user.RefreshToken)
+
+	_, err = p.FetchUser(&adfs.Session{AccessToken: "access"})
+	a.Error(err)
+}
+
+func Test_LogoutURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	logoutURL, err := adfsProvider().LogoutURL("id-token", "https://example.com/")
+	a.NoError(err)
+	a.Contains(logoutURL, "https://adfs.example.com/adfs/oauth2/logout?")
+	a.Contains(logoutURL, "id_token_hint=id-token")
+}
+
+func adfsProvider() *adfs.Provider {
+	return adfs.New("client-id", "secret", "/foo", "https://adfs.example.com/", adfs.Options{Resource: "urn:api"})
+}
@@ -0,0 +1,80 @@
+package adfs
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
+)
+
+// Session is the implementation of `goth.Session`
+type Session struct {
+	AuthURL      string    `json:"au"`
+	AccessToken  string    `json:"at"`
+	RefreshToken string    `json:"rt"`
+	ExpiresAt    time.Time `json:"exp"`
+	IDToken      string    `json:"it,omitempty"`
+}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` func
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+
+	return s.AuthURL, nil
+}
+
+// Authorize the session with AD FS and return the access token to be stored for future use.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
+	if err != nil {
+		return "", err
+	}
+
+	if !token.Valid() {
+		return "", errors.New("invalid token received from provider")
+	}
+
+	s.AccessToken = token.AccessToken
+	s.RefreshToken = token.RefreshToken
+	s.ExpiresAt = token.Expiry
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		s.IDToken = idToken
+	}
+
+	return token.AccessToken, err
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return s.Marshal()
+}
+
+// UnmarshalSession wil unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	session := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(session)
+	return session, err
+}
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
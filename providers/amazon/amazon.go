@@ -4,6 +4,7 @@ package amazon
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,15 +13,36 @@ import (
 	"net/url"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
 const (
-	authURL         string = "https://www.amazon.com/ap/oa"
-	tokenURL        string = "https://api.amazon.com/auth/o2/token"
-	endpointProfile string = "https://api.amazon.com/user/profile"
+	authURL           string = "https://www.amazon.com/ap/oa"
+	tokenURL          string = "https://api.amazon.com/auth/o2/token"
+	endpointProfile   string = "https://api.amazon.com/user/profile"
+	endpointTokenInfo string = "https://api.amazon.com/auth/o2/tokeninfo"
 )
 
+// Scopes of Login with Amazon. New requests ScopeProfile and ScopePostalCode
+// if no scope is given.
+// See https://developer.amazon.com/docs/login-with-amazon/customer-profile.html
+const (
+	// ScopeProfile gives access to the name, email address and user ID.
+	ScopeProfile string = "profile"
+	// ScopeProfileUserID only gives access to the user ID.
+	ScopeProfileUserID string = "profile:user_id"
+	// ScopePostalCode gives access to the postal code of the user's
+	// primary address, set as User.Location.
+	ScopePostalCode string = "postal_code"
+)
+
+// ErrTokenAudience is wrapped by the error of FetchUser when the access token
+// wasn't issued to the application, in which case it must not be used to sign
+// the user in, as another application could have obtained it. It is
+// goth.ErrTokenAudience.
+var ErrTokenAudience = goth.ErrTokenAudience
+
 // Provider is the implementation of `goth.Provider` for accessing Amazon.
 type Provider struct {
 	ClientKey    string
@@ -59,6 +81,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 // Debug is a no-op for the amazon package.
 func (p *Provider) Debug(debug bool) {}
 
@@ -84,7 +114,12 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	response, err := goth.HTTPClientWithFallBack(p.Client()).Get(endpointProfile + "?access_token=" + url.QueryEscape(sess.AccessToken))
+	// Amazon requires checking that the token was issued to the application
+	if err := p.verifyToken(sess.AccessToken); err != nil {
+		return user, err
+	}
+
+	response, err := p.Client().Get(endpointProfile + "?access_token=" + url.QueryEscape(sess.AccessToken))
 
 	if err != nil {
 		return user, err
@@ -109,6 +144,32 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	return user, err
 }
 
+// verifyToken checks with the tokeninfo endpoint that accessToken was issued
+// to the application.
+// See https://developer.amazon.com/docs/login-with-amazon/obtain-customer-profile.html
+func (p *Provider) verifyToken(accessToken string) error {
+	response, err := p.Client().Get(endpointTokenInfo + "?access_token=" + url.QueryEscape(accessToken))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to verify the access token", p.providerName, response.StatusCode)
+	}
+
+	info := struct {
+		Audience string `json:"aud"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return err
+	}
+	if info.Audience != p.ClientKey {
+		return fmt.Errorf("%s: %w: %q", p.providerName, ErrTokenAudience, info.Audience)
+	}
+	return nil
+}
+
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
@@ -126,7 +187,7 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 			c.Scopes = append(c.Scopes, scope)
 		}
 	} else {
-		c.Scopes = append(c.Scopes, "profile", "postal_code")
+		c.Scopes = append(c.Scopes, ScopeProfile, ScopePostalCode)
 	}
 	return c
 }
@@ -157,11 +218,10 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
@@ -1,7 +1,10 @@
 package amazon_test
 
 import (
+	"io"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -33,6 +36,7 @@ func Test_BeginAuth(t *testing.T) {
 	s := session.(*amazon.Session)
 	a.NoError(err)
 	a.Contains(s.AuthURL, "www.amazon.com/ap/oa")
+	a.Contains(s.AuthURL, "scope=profile+postal_code")
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -48,6 +52,43 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AccessToken, "1234567890")
 }
 
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := amazon.New("amzn1.application-oa2-client.ours", "secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		var body string
+		switch req.URL.Path {
+		case "/auth/o2/tokeninfo":
+			aud := "amzn1.application-oa2-client.ours"
+			if req.URL.Query().Get("access_token") == "stolen" {
+				aud = "amzn1.application-oa2-client.theirs"
+			}
+			body = `{"iss":"https://www.amazon.com","user_id":"amzn1.account.K2LI23KL2LK2","aud":"` + aud + `","exp":3597}`
+		case "/user/profile":
+			body = `{"user_id":"amzn1.account.K2LI23KL2LK2","name":"Homer Simpson","email":"homer@example.com","postal_code":"49007"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	user, err := p.FetchUser(&amazon.Session{AccessToken: "token"})
+	a.NoError(err)
+	a.Equal("amzn1.account.K2LI23KL2LK2", user.UserID)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("49007", user.Location)
+
+	_, err = p.FetchUser(&amazon.Session{AccessToken: "stolen"})
+	a.ErrorIs(err, amazon.ErrTokenAudience)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 func provider() *amazon.Provider {
 	return amazon.New(os.Getenv("AMAZON_KEY"), os.Getenv("AMAZON_SECRET"), "/foo")
 }
@@ -3,7 +3,10 @@
 package apple
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
 	"errors"
@@ -15,12 +18,15 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/jwkutil"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
 const (
-	authEndpoint  = "https://appleid.apple.com/auth/authorize"
-	tokenEndpoint = "https://appleid.apple.com/auth/token"
+	authEndpoint   = "https://appleid.apple.com/auth/authorize"
+	tokenEndpoint  = "https://appleid.apple.com/auth/token"
+	revokeEndpoint = "https://appleid.apple.com/auth/revoke"
 
 	ScopeEmail = "email"
 	ScopeName  = "name"
@@ -37,6 +43,8 @@ type Provider struct {
 	httpClient           *http.Client
 	formPostResponseMode bool
 	timeNowFn            func() time.Time
+	keysURL              string
+	keys                 *jwkutil.KeySet
 }
 
 func New(clientId, secret, redirectURL string, httpClient *http.Client, scopes ...string) *Provider {
@@ -45,6 +53,8 @@ func New(clientId, secret, redirectURL string, httpClient *http.Client, scopes .
 		secret:       secret,
 		redirectURL:  redirectURL,
 		providerName: "apple",
+		keysURL:      idTokenVerificationKeyEndpoint,
+		keys:         &jwkutil.KeySet{MinRefreshInterval: jwksMinRefreshInterval},
 	}
 	p.configure(scopes)
 	p.httpClient = httpClient
@@ -59,6 +69,13 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	p.redirectURL = callbackURL
+	p.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &p
+}
+
 func (p Provider) ClientId() string {
 	return p.clientId
 }
@@ -98,7 +115,12 @@ func (p Provider) RedirectURL() string {
 }
 
 func (p Provider) BeginAuth(state string) (goth.Session, error) {
-	opts := make([]oauth2.AuthCodeOption, 0, 1)
+	// the nonce ends up in the id_token, tying it to this authorization request
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+	opts := []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("nonce", nonce)}
 	if p.formPostResponseMode {
 		opts = append(opts, oauth2.SetAuthURLParam("response_mode", "form_post"))
 	}
@@ -112,9 +134,18 @@ func (p Provider) BeginAuth(state string) (goth.Session, error) {
 	}
 	return &Session{
 		AuthURL: authURL,
+		Nonce:   nonce,
 	}, nil
 }
 
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 func (Provider) UnmarshalSession(data string) (goth.Session, error) {
 	s := &Session{}
 	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
@@ -122,12 +153,10 @@ func (Provider) UnmarshalSession(data string) (goth.Session, error) {
 }
 
 // Apple doesn't seem to provide a user profile endpoint like all the other providers do.
-// Therefore this will return a User with the unique identifier obtained through authorization
-// as the only identifying attribute.
-// A full name and email can be obtained from the form post response (parameter 'user')
-// to the redirect page following authentication, if the name and email scopes are requested.
-// Additionally, if the response type is form_post and the email scope is requested, the email
-// will be encoded into the ID token in the email claim.
+// Therefore this will return a User with the unique identifier and email obtained from
+// the ID token. If the name and email scopes are requested, Apple also posts the user's
+// name and email to the redirect page (parameter 'user') on the first authorization only,
+// in which case they are set on the User as well.
 func (p Provider) FetchUser(session goth.Session) (goth.User, error) {
 	s := session.(*Session)
 	if s.AccessToken == "" {
@@ -137,6 +166,9 @@ func (p Provider) FetchUser(session goth.Session) (goth.User, error) {
 		Provider:     p.Name(),
 		UserID:       s.ID.Sub,
 		Email:        s.ID.Email,
+		FirstName:    s.FirstName,
+		LastName:     s.LastName,
+		Name:         strings.TrimSpace(s.FirstName + " " + s.LastName),
 		AccessToken:  s.AccessToken,
 		RefreshToken: s.RefreshToken,
 		ExpiresAt:    s.ExpiresAt,
@@ -151,13 +183,12 @@ func (p Provider) Client() *http.Client {
 }
 
 func (p Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
 
 func (Provider) RefreshTokenAvailable() bool {
@@ -185,3 +216,30 @@ func (p *Provider) configure(scopes []string) {
 
 	p.config = c
 }
+
+// RevokeToken revokes the access or refresh token, which Apple requires apps
+// offering account deletion to do.
+// See https://developer.apple.com/documentation/sign_in_with_apple/revoke_tokens
+func (p Provider) RevokeToken(ctx context.Context, token string) error {
+	form := url.Values{
+		"client_id":     {p.clientId},
+		"client_secret": {p.secret},
+		"token":         {token},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to revoke the token", p.providerName, response.StatusCode)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package apple
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"testing"
@@ -115,5 +116,49 @@ func TestBeginAuth(t *testing.T) {
 	s := session.(*Session)
 
 	// Apple requires spaces to be encoded as %20 instead of +
-	a.Equal(s.AuthURL, "https://appleid.apple.com/auth/authorize?client_id=%3CclientId%3E&redirect_uri=https%3A%2F%2Fexample-app.com%2Fredirect&response_mode=form_post&response_type=code&scope=name%20email&state=test_state")
+	a.NotEmpty(s.Nonce)
+	a.Equal(s.AuthURL, "https://appleid.apple.com/auth/authorize?client_id=%3CclientId%3E&nonce="+s.Nonce+"&redirect_uri=https%3A%2F%2Fexample-app.com%2Fredirect&response_mode=form_post&response_type=code&scope=name%20email&state=test_state")
+}
+
+func Test_KeySetIsShared(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// providers not created with New don't get a key set of their own
+	a.Same(defaultKeys, Provider{}.keySet())
+
+	p := provider()
+	a.NotSame(defaultKeys, p.keySet())
+	a.Same(p.keySet(), (*p).keySet())
+}
+
+func Test_WithCallbackURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.NoError(r.ParseForm())
+		a.Equal("https://example.org/auth/apple/callback", r.PostForm.Get("redirect_uri"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer ts.Close()
+
+	p := New("client-id", "secret", "https://example.com/auth/apple/callback", nil, ScopeEmail)
+	p.config.Endpoint.TokenURL = ts.URL
+
+	c := p.WithCallbackURL("https://example.org/auth/apple/callback").(*Provider)
+	a.Equal("https://example.com/auth/apple/callback", p.RedirectURL())
+	a.Equal("https://example.org/auth/apple/callback", c.RedirectURL())
+	// the copy shares the key set of the provider
+	a.Same(p.keySet(), c.keySet())
+
+	session, err := c.BeginAuth("state")
+	a.NoError(err)
+	authURL, err := session.GetAuthURL()
+	a.NoError(err)
+	a.Contains(authURL, "redirect_uri="+url.QueryEscape("https://example.org/auth/apple/callback"))
+
+	_, err = session.Authorize(c, url.Values{"code": {"code"}})
+	a.NoError(err)
 }
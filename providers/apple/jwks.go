@@ -0,0 +1,92 @@
+package apple
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/jwkutil"
+)
+
+const (
+	idTokenVerificationKeyEndpoint = "https://appleid.apple.com/auth/keys"
+
+	// jwksMinRefreshInterval is the minimum time Apple's key set is cached
+	// for, regardless of the cache headers it is served with.
+	jwksMinRefreshInterval = 15 * time.Minute
+)
+
+// defaultKeys is used by Providers that weren't created with New, so that they
+// don't start a new refresh loop for every token they verify.
+var defaultKeys = &jwkutil.KeySet{MinRefreshInterval: jwksMinRefreshInterval}
+
+// ValidateIDToken verifies the signature of idToken against Apple's public
+// keys, checks that it was issued by Apple for this client, hasn't expired and
+// carries nonce, and returns its claims. An empty nonce skips the nonce check.
+// See https://developer.apple.com/documentation/sign_in_with_apple/sign_in_with_apple_rest_api/verifying_a_user
+func (p Provider) ValidateIDToken(idToken, nonce string) (*IDTokenClaims, error) {
+	if idToken == "" {
+		return nil, errors.New("no id_token to validate")
+	}
+
+	claims := &IDTokenClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, p.verificationKey,
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(AppleAudOrIss),
+		jwt.WithAudience(p.clientId),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if nonce != "" && claims.Nonce != nonce {
+		return nil, errors.New("id_token nonce does not match the one sent in the authorization request")
+	}
+	return claims, nil
+}
+
+func (p Provider) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	set, err := p.keySet().Fetch(context.Background(), p.keysEndpoint(), p.Client())
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := set.LookupKeyID(kid)
+	if !ok {
+		return nil, fmt.Errorf("could not find key %q to verify id_token", kid)
+	}
+
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+func (p Provider) keySet() *jwkutil.KeySet {
+	if p.keys == nil {
+		return defaultKeys
+	}
+	return p.keys
+}
+
+func (p Provider) keysEndpoint() string {
+	if p.keysURL == "" {
+		return idTokenVerificationKeyEndpoint
+	}
+	return p.keysURL
+}
+
+func (p Provider) now() time.Time {
+	if p.timeNowFn != nil {
+		return p.timeNowFn()
+	}
+	return goth.Now()
+}
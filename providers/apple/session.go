@@ -1,8 +1,6 @@
 package apple
 
 import (
-	"context"
-	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
@@ -11,15 +9,11 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
-const (
-	idTokenVerificationKeyEndpoint = "https://appleid.apple.com/auth/keys"
-)
-
 type ID struct {
 	Sub            string `json:"sub"`
 	Email          string `json:"email"`
@@ -32,6 +26,9 @@ type Session struct {
 	AccessToken  string
 	RefreshToken string
 	ExpiresAt    time.Time
+	Nonce        string `json:",omitempty"`
+	FirstName    string `json:",omitempty"`
+	LastName     string `json:",omitempty"`
 	ID
 }
 
@@ -54,6 +51,7 @@ type IDTokenClaims struct {
 	Email           string     `json:"email"`
 	IsPrivateEmail  BoolString `json:"is_private_email"`
 	EmailVerified   BoolString `json:"email_verified,omitempty"`
+	Nonce           string     `json:"nonce,omitempty"`
 }
 
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
@@ -63,7 +61,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 		oauth2.SetAuthURLParam("client_id", p.clientId),
 		oauth2.SetAuthURLParam("client_secret", p.secret),
 	}
-	token, err := p.config.Exchange(context.Background(), params.Get("code"), opts...)
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"), opts...)
 	if err != nil {
 		return "", err
 	}
@@ -76,48 +74,44 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
 
-	if idToken := token.Extra("id_token"); idToken != nil {
-		idToken, err := jwt.ParseWithClaims(idToken.(string), &IDTokenClaims{}, func(t *jwt.Token) (interface{}, error) {
-			kid := t.Header["kid"].(string)
-			claims := t.Claims.(*IDTokenClaims)
-			validator := jwt.NewValidator(jwt.WithAudience(p.clientId), jwt.WithIssuer(AppleAudOrIss))
-			err := validator.Validate(claims)
-			if err != nil {
-				return nil, err
-			}
-
-			// per OpenID Connect Core 1.0 §3.2.2.9, Access Token Validation
-			hash := sha256.Sum256([]byte(s.AccessToken))
-			halfHash := hash[0:(len(hash) / 2)]
-			encodedHalfHash := base64.RawURLEncoding.EncodeToString(halfHash)
-			if encodedHalfHash != claims.AccessTokenHash {
-				return nil, fmt.Errorf(`identity token invalid`)
-			}
-
-			// get the public key for verifying the identity token signature
-			set, err := jwk.Fetch(context.Background(), idTokenVerificationKeyEndpoint, jwk.WithHTTPClient(p.Client()))
-			if err != nil {
-				return nil, err
-			}
-			selectedKey, found := set.LookupKeyID(kid)
-			if !found {
-				return nil, errors.New("could not find matching public key")
-			}
-			pubKey := &rsa.PublicKey{}
-			err = selectedKey.Raw(pubKey)
-			if err != nil {
-				return nil, err
-			}
-			return pubKey, nil
-		})
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		claims, err := p.ValidateIDToken(idToken, s.Nonce)
 		if err != nil {
 			return "", err
 		}
+
+		// per OpenID Connect Core 1.0 §3.2.2.9, Access Token Validation
+		hash := sha256.Sum256([]byte(s.AccessToken))
+		halfHash := hash[0:(len(hash) / 2)]
+		if base64.RawURLEncoding.EncodeToString(halfHash) != claims.AccessTokenHash {
+			return "", errors.New("identity token invalid")
+		}
+
 		s.ID = ID{
-			Sub:            idToken.Claims.(*IDTokenClaims).Subject,
-			Email:          idToken.Claims.(*IDTokenClaims).Email,
-			IsPrivateEmail: idToken.Claims.(*IDTokenClaims).IsPrivateEmail.Value(),
-			EmailVerified:  idToken.Claims.(*IDTokenClaims).EmailVerified.Value(),
+			Sub:            claims.Subject,
+			Email:          claims.Email,
+			IsPrivateEmail: claims.IsPrivateEmail.Value(),
+			EmailVerified:  claims.EmailVerified.Value(),
+		}
+	}
+
+	// Apple only posts the user's name and email on the first authorization
+	// of the app, as a JSON document in the user form parameter.
+	if raw := params.Get("user"); raw != "" {
+		u := struct {
+			Name struct {
+				FirstName string `json:"firstName"`
+				LastName  string `json:"lastName"`
+			} `json:"name"`
+			Email string `json:"email"`
+		}{}
+		if err := json.Unmarshal([]byte(raw), &u); err != nil {
+			return "", fmt.Errorf("cannot parse user form parameter: %w", err)
+		}
+		s.FirstName = u.Name.FirstName
+		s.LastName = u.Name.LastName
+		if s.Email == "" {
+			s.Email = u.Email
 		}
 	}
 
@@ -160,3 +154,13 @@ func (bs *BoolString) Value() bool {
 	}
 	return bs.StringValue == "true"
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
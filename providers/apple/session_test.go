@@ -1,11 +1,21 @@
 package apple
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/markbates/goth"
 )
 
@@ -96,3 +106,90 @@ func TestIDTokenClaimsUnmarshal(t *testing.T) {
 		})
 	}
 }
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	pub, err := jwk.New(&key.PublicKey)
+	a.NoError(err)
+	a.NoError(pub.Set(jwk.KeyIDKey, "kid1"))
+	set := jwk.NewSet()
+	set.Add(pub)
+
+	accessToken := "access-token"
+	hash := sha256.Sum256([]byte(accessToken))
+	var idToken string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/keys":
+			json.NewEncoder(w).Encode(set)
+		case "/auth/token":
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": accessToken,
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+				"id_token":     idToken,
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	p := New("client-id", "secret", "/foo", nil, ScopeName, ScopeEmail)
+	p.keysURL = ts.URL + "/auth/keys"
+	p.config.Endpoint.TokenURL = ts.URL + "/auth/token"
+
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "kid1"
+		s, err := token.SignedString(key)
+		a.NoError(err)
+		return s
+	}
+	claims := func(nonce string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":     AppleAudOrIss,
+			"aud":     "client-id",
+			"sub":     "001234.abcd",
+			"exp":     time.Now().Add(time.Hour).Unix(),
+			"nonce":   nonce,
+			"at_hash": base64.RawURLEncoding.EncodeToString(hash[:len(hash)/2]),
+			"email":   "relay@privaterelay.appleid.com",
+		}
+	}
+
+	session, err := p.BeginAuth("state")
+	a.NoError(err)
+	s := session.(*Session)
+
+	idToken = sign(claims(s.Nonce))
+	_, err = s.Authorize(p, url.Values{
+		"code": {"code"},
+		"user": {`{"name":{"firstName":"Jane","lastName":"Appleseed"},"email":"jane@example.com"}`},
+	})
+	a.NoError(err)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("001234.abcd", user.UserID)
+	a.Equal("relay@privaterelay.appleid.com", user.Email)
+	a.Equal("Jane", user.FirstName)
+	a.Equal("Appleseed", user.LastName)
+	a.Equal("Jane Appleseed", user.Name)
+
+	idToken = sign(claims("another-nonce"))
+	_, err = s.Authorize(p, url.Values{"code": {"code"}})
+	a.Error(err)
+
+	wrongAudience := claims(s.Nonce)
+	wrongAudience["aud"] = "someone-else"
+	idToken = sign(wrongAudience)
+	_, err = s.Authorize(p, url.Values{"code": {"code"}})
+	a.Error(err)
+}
@@ -4,12 +4,15 @@ package auth0
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -17,18 +20,22 @@ const (
 	authEndpoint    string = "/authorize"
 	tokenEndpoint   string = "/oauth/token"
 	endpointProfile string = "/userinfo"
+	endpointLogout  string = "/oidc/logout"
 	protocol        string = "https://"
 )
 
 // Provider is the implementation of `goth.Provider` for accessing Auth0.
 type Provider struct {
-	ClientKey    string
-	Secret       string
-	CallbackURL  string
-	Domain       string
-	HTTPClient   *http.Client
+	ClientKey   string
+	Secret      string
+	CallbackURL string
+	Domain      string
+	HTTPClient  *http.Client
+	goth.AdditionalParams
 	config       *oauth2.Config
 	providerName string
+	debug        bool
+	pkce         bool
 }
 
 type auth0UserResp struct {
@@ -49,6 +56,7 @@ func New(clientKey, secret, callbackURL string, auth0Domain string, scopes ...st
 		CallbackURL:  callbackURL,
 		Domain:       auth0Domain,
 		providerName: "auth0",
+		pkce:         true,
 	}
 	p.config = newConfig(p, scopes)
 	return p
@@ -64,18 +72,47 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return p.TokenClient(goth.HTTPClientWithFallBack(p.HTTPClient))
+}
+
+// Debug makes the sessions keep the responses of the token endpoint and
+// traces of the requests made to authorize them, returned by their
+// DebugInfo method.
+func (p *Provider) Debug(debug bool) {
+	p.debug = debug
 }
 
-// Debug is a no-op for the auth0 package.
-func (p *Provider) Debug(debug bool) {}
+// SetPKCE enables or disables Proof Key for Code Exchange (PKCE) for the
+// authorization code flow. It is enabled by default for Auth0.
+func (p *Provider) SetPKCE(enabled bool) {
+	p.pkce = enabled
+}
 
 // BeginAuth asks Auth0 for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
-	}, nil
+	return p.BeginAuthWithParams(state, nil)
+}
+
+// BeginAuthWithParams is like BeginAuth, adding params (e.g. login_hint or prompt)
+// to the authentication end-point.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
+	session := &Session{}
+	opts := append(p.AuthCodeOptions(), goth.AuthURLParamOptions(params)...)
+	if p.pkce {
+		session.CodeVerifier = goth.GeneratePKCEVerifier()
+		opts = append(opts, goth.PKCEChallengeOption(session.CodeVerifier))
+	}
+	session.AuthURL = p.config.AuthCodeURL(state, opts...)
+	return session, nil
 }
 
 // FetchUser will go to Auth0 and access basic information about the user.
@@ -89,6 +126,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		Provider:     p.Name(),
 		RefreshToken: s.RefreshToken,
 		ExpiresAt:    s.ExpiresAt,
+		IDToken:      s.IDToken,
 	}
 
 	if user.AccessToken == "" {
@@ -119,6 +157,14 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	return user, err
 }
 
+// LogoutURL returns the URL to redirect the user to in order to end their
+// Auth0 session. postLogoutRedirect must be listed in the allowed logout URLs
+// of the application.
+// See https://auth0.com/docs/authenticate/login/logout/log-users-out-of-auth0
+func (p *Provider) LogoutURL(idTokenHint, postLogoutRedirect string) (string, error) {
+	return goth.EndSessionURL(protocol+p.Domain+endpointLogout, p.ClientKey, idTokenHint, postLogoutRedirect)
+}
+
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
@@ -173,11 +219,18 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(oauth2.NoContext, token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
+}
+
+// ExchangeToken implements goth.TokenExchanger with the token exchange of
+// RFC 8693, as used by the Custom Token Exchange of Auth0, whose action
+// decides which user the issued token is for.
+// See https://auth0.com/docs/authenticate/custom-token-exchange
+func (p *Provider) ExchangeToken(ctx context.Context, r goth.TokenExchangeRequest) (*oauth2.Token, error) {
+	return goth.ExchangeToken(ctx, p.Client(), p.config, r)
 }
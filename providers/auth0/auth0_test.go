@@ -1,7 +1,11 @@
 package auth0_test
 
 import (
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/jarcoal/httpmock"
@@ -25,6 +29,7 @@ func Test_Implements_Provider(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
 	a.Implements((*goth.Provider)(nil), provider())
+	a.Implements((*goth.TokenExchanger)(nil), provider())
 }
 
 func Test_BeginAuth(t *testing.T) {
@@ -36,6 +41,20 @@ func Test_BeginAuth(t *testing.T) {
 	a.NoError(err)
 	expectedAuthURL := "https://" + os.Getenv("AUTH0_DOMAIN") + "/authorize"
 	a.Contains(s.AuthURL, expectedAuthURL)
+	a.NotEmpty(s.CodeVerifier)
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+}
+
+func Test_BeginAuthWithoutPKCE(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	p.SetPKCE(false)
+	session, err := p.BeginAuth("test_state")
+	s := session.(*auth0.Session)
+	a.NoError(err)
+	a.Empty(s.CodeVerifier)
+	a.NotContains(s.AuthURL, "code_challenge")
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -96,6 +115,43 @@ func Test_FetchUser(t *testing.T) {
 
 }
 
+func Test_AdditionalParams(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var form url.Values
+	p := provider()
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.NoError(req.ParseForm())
+		form = req.PostForm
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"access_token":"token","token_type":"Bearer"}`)),
+		}, nil
+	})}
+	p.SetAdditionalParams(
+		url.Values{"audience": {"https://api.example.com"}},
+		url.Values{"audience": {"https://api.example.com"}},
+	)
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*auth0.Session)
+	a.Contains(s.AuthURL, "audience=https%3A%2F%2Fapi.example.com")
+
+	_, err = s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Equal("authorization_code", form.Get("grant_type"))
+	a.Equal("https://api.example.com", form.Get("audience"))
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 func provider() *auth0.Provider {
 	return auth0.New(os.Getenv("AUTH0_KEY"), os.Getenv("AUTH0_SECRET"), "/foo", os.Getenv("AUTH0_DOMAIN"))
 }
@@ -0,0 +1,112 @@
+// Package authelia implements the OpenID Connect protocol for authenticating
+// users through a self-hosted Authelia instance.
+// See https://www.authelia.com/configuration/identity-providers/openid-connect/provider/
+package authelia
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/openidConnect"
+)
+
+// Scopes supported by Authelia. ScopeGroups adds the groups of the user to
+// the claims, and ScopeOfflineAccess has to be asked for to get a refresh
+// token.
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeGroups        = "groups"
+	ScopeOfflineAccess = "offline_access"
+)
+
+// logoutPath is the logout page of the Authelia portal, used by LogoutURL
+// when the instance doesn't publish an end_session_endpoint.
+const logoutPath = "/logout"
+
+// Provider is the implementation of `goth.Provider` for accessing Authelia.
+// It is an openidConnect.Provider with PKCE enabled, preferring the username
+// of the user as their nickname. The groups of the user are in User.Groups.
+type Provider struct {
+	*openidConnect.Provider
+	baseURL string
+}
+
+// New creates a new Authelia provider for the instance at baseURL, e.g.
+// https://auth.example.com, whose endpoints are discovered from its OpenID
+// Connect discovery document. The openid, profile, email and groups scopes
+// are asked for if none are given. You should always call `authelia.New` to
+// get a new Provider. Never try to create one manually.
+func New(clientKey, secret, callbackURL, baseURL string, scopes ...string) (*Provider, error) {
+	if len(scopes) == 0 {
+		scopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeGroups}
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	p, err := openidConnect.New(clientKey, secret, callbackURL, baseURL+"/.well-known/openid-configuration", scopes...)
+	if err != nil {
+		return nil, err
+	}
+	p.SetName("authelia")
+	p.SetPKCE(true)
+	p.NickNameClaims = []string{openidConnect.PreferredUsernameClaim, openidConnect.NicknameClaim}
+	return &Provider{Provider: p, baseURL: baseURL}, nil
+}
+
+// WithCallbackURL returns a copy of the provider sending users back to
+// callbackURL.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	return &Provider{Provider: p.Provider.WithCallbackURL(callbackURL).(*openidConnect.Provider), baseURL: p.baseURL}
+}
+
+// BeginAuth asks Authelia for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.BeginAuthWithParams(state, nil)
+}
+
+// BeginAuthWithParams is like BeginAuth, adding params (e.g. login_hint or
+// prompt) to the authentication end-point.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
+	session, err := p.Provider.BeginAuthWithParams(state, params)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{Session: *session.(*openidConnect.Session)}, nil
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	session, err := p.Provider.UnmarshalSession(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{Session: *session.(*openidConnect.Session)}, nil
+}
+
+// FetchUser verifies the id_token of the session and merges it with the
+// response of the userinfo endpoint.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user, err := p.Provider.FetchUser(&sess.Session)
+	if err != nil {
+		return user, err
+	}
+	user.Provider = p.Name()
+	return user, nil
+}
+
+// LogoutURL returns the URL to redirect the user to in order to end their
+// session with Authelia: the end_session_endpoint of the instance if it has
+// one, or else the logout page of its portal, which sends the user on to
+// postLogoutRedirect, provided it is on a domain Authelia protects.
+func (p *Provider) LogoutURL(idTokenHint, postLogoutRedirect string) (string, error) {
+	if p.CurrentOpenIDConfig().EndSessionEndpoint != "" {
+		return p.Provider.LogoutURL(idTokenHint, postLogoutRedirect)
+	}
+	u := p.baseURL + logoutPath
+	if postLogoutRedirect != "" {
+		u += "?" + url.Values{"rd": {postLogoutRedirect}}.Encode()
+	}
+	return u, nil
+}
@@ -0,0 +1,101 @@
+package authelia_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/testidp"
+	"github.com/markbates/goth/providers/authelia"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+
+	p, err := authelia.New("id", "secret", "http://localhost/foo", idp.URL+"/")
+	a.NoError(err)
+	a.Equal("authelia", p.Name())
+	a.Equal(idp.Issuer(), p.OpenIDConfig.Issuer)
+	a.Implements((*goth.Provider)(nil), p)
+	a.Implements((*goth.EndSessionProvider)(nil), p)
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+	p, err := authelia.New("id", "secret", "http://localhost/foo", idp.URL)
+	a.NoError(err)
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*authelia.Session)
+	a.NotEmpty(s.CodeVerifier)
+	u, err := url.Parse(s.AuthURL)
+	a.NoError(err)
+	a.Equal([]string{"openid", "profile", "email", "groups"}, strings.Fields(u.Query().Get("scope")))
+
+	session, err = p.UnmarshalSession(s.Marshal())
+	a.NoError(err)
+	a.Equal(s, session.(*authelia.Session))
+}
+
+func Test_RoundTrip(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+	idp.Claims["preferred_username"] = "marge"
+	idp.Claims["email"] = "marge@example.com"
+	idp.Claims["groups"] = []string{"admins", "family"}
+
+	p, err := authelia.New("id", "secret", "http://localhost/foo", idp.URL)
+	a.NoError(err)
+	p = p.WithCallbackURL("http://localhost/bar").(*authelia.Provider)
+
+	session, err := p.BeginAuth("state")
+	a.NoError(err)
+	authURL, err := session.GetAuthURL()
+	a.NoError(err)
+	a.Contains(authURL, url.QueryEscape("http://localhost/bar"))
+	callback, err := idp.Authorize(authURL)
+	a.NoError(err)
+	_, err = session.Authorize(p, callback.Query())
+	a.NoError(err)
+
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("authelia", user.Provider)
+	a.Equal("marge", user.NickName)
+	a.Equal("marge@example.com", user.Email)
+	a.Equal([]string{"admins", "family"}, user.Groups)
+}
+
+func Test_LogoutURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+	p, err := authelia.New("id", "secret", "http://localhost/foo", idp.URL)
+	a.NoError(err)
+
+	logoutURL, err := p.LogoutURL("token", "http://localhost/bye")
+	a.NoError(err)
+	a.Contains(logoutURL, idp.EndSessionURL()+"?")
+
+	// instances without an end_session_endpoint log out through the portal
+	p.OpenIDConfig.EndSessionEndpoint = ""
+	logoutURL, err = p.LogoutURL("token", "http://localhost/bye")
+	a.NoError(err)
+	a.Equal(idp.URL+"/logout?rd="+url.QueryEscape("http://localhost/bye"), logoutURL)
+}
@@ -0,0 +1,92 @@
+// Package authentik implements the OpenID Connect protocol for authenticating
+// users through a self-hosted authentik instance.
+// See https://docs.goauthentik.io/docs/add-secure-apps/providers/oauth2/
+package authentik
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/openidConnect"
+)
+
+// Scopes of the default property mappings of authentik. ScopeOfflineAccess
+// has to be asked for to get a refresh token.
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access"
+)
+
+// Provider is the implementation of `goth.Provider` for accessing authentik.
+// It is an openidConnect.Provider with PKCE enabled, preferring the username
+// of the user as their nickname. The groups of the user, part of the profile
+// scope, are in User.Groups.
+type Provider struct {
+	*openidConnect.Provider
+}
+
+// New creates a new authentik provider for the application with the given
+// slug on the instance at baseURL, e.g. https://authentik.example.com. The
+// endpoints are discovered from the OpenID Connect discovery document of the
+// application, and the openid, profile and email scopes are asked for if
+// none are given. You should always call `authentik.New` to get a new
+// Provider. Never try to create one manually.
+func New(clientKey, secret, callbackURL, baseURL, applicationSlug string, scopes ...string) (*Provider, error) {
+	if len(scopes) == 0 {
+		scopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+	}
+	discoveryURL := strings.TrimSuffix(baseURL, "/") + "/application/o/" + url.PathEscape(applicationSlug) + "/.well-known/openid-configuration"
+	p, err := openidConnect.New(clientKey, secret, callbackURL, discoveryURL, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	p.SetName("authentik")
+	p.SetPKCE(true)
+	p.NickNameClaims = []string{openidConnect.PreferredUsernameClaim, openidConnect.NicknameClaim}
+	return &Provider{Provider: p}, nil
+}
+
+// WithCallbackURL returns a copy of the provider sending users back to
+// callbackURL.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	return &Provider{Provider: p.Provider.WithCallbackURL(callbackURL).(*openidConnect.Provider)}
+}
+
+// BeginAuth asks authentik for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.BeginAuthWithParams(state, nil)
+}
+
+// BeginAuthWithParams is like BeginAuth, adding params (e.g. login_hint or
+// prompt) to the authentication end-point.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
+	session, err := p.Provider.BeginAuthWithParams(state, params)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{Session: *session.(*openidConnect.Session)}, nil
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	session, err := p.Provider.UnmarshalSession(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{Session: *session.(*openidConnect.Session)}, nil
+}
+
+// FetchUser verifies the id_token of the session and merges it with the
+// response of the userinfo endpoint.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user, err := p.Provider.FetchUser(&sess.Session)
+	if err != nil {
+		return user, err
+	}
+	user.Provider = p.Name()
+	return user, nil
+}
@@ -0,0 +1,104 @@
+package authentik_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/testidp"
+	"github.com/markbates/goth/providers/authentik"
+	"github.com/stretchr/testify/assert"
+)
+
+// newInstance returns an identity provider whose discovery document is served
+// at the path authentik serves it at for the application "app".
+func newInstance(t *testing.T) (*testidp.Server, string) {
+	idp := testidp.New("id", "secret")
+	t.Cleanup(idp.Close)
+	instance := httptest.NewServer(http.StripPrefix("/application/o/app", idp.Config.Handler))
+	t.Cleanup(instance.Close)
+	return idp, instance.URL
+}
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp, baseURL := newInstance(t)
+
+	p, err := authentik.New("id", "secret", "http://localhost/foo", baseURL+"/", "app")
+	a.NoError(err)
+	a.Equal("authentik", p.Name())
+	a.Equal(idp.Issuer(), p.OpenIDConfig.Issuer)
+	a.Implements((*goth.Provider)(nil), p)
+	a.Implements((*goth.EndSessionProvider)(nil), p)
+
+	_, err = authentik.New("id", "secret", "http://localhost/foo", baseURL, "other-app")
+	a.Error(err)
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp, baseURL := newInstance(t)
+	p, err := authentik.New("id", "secret", "http://localhost/foo", baseURL, "app")
+	a.NoError(err)
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*authentik.Session)
+	a.NotEmpty(s.CodeVerifier)
+	u, err := url.Parse(s.AuthURL)
+	a.NoError(err)
+	a.Equal(idp.AuthURL(), u.Scheme+"://"+u.Host+u.Path)
+	a.Equal([]string{"openid", "profile", "email"}, strings.Fields(u.Query().Get("scope")))
+
+	session, err = p.UnmarshalSession(s.Marshal())
+	a.NoError(err)
+	a.Equal(s, session.(*authentik.Session))
+
+	p, err = authentik.New("id", "secret", "http://localhost/foo", baseURL, "app", authentik.ScopeOpenID, authentik.ScopeOfflineAccess)
+	a.NoError(err)
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*authentik.Session).AuthURL, "scope=openid+offline_access")
+}
+
+func Test_RoundTrip(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp, baseURL := newInstance(t)
+	idp.Claims["preferred_username"] = "homer"
+	idp.Claims["nickname"] = "Homie"
+	idp.Claims["email"] = "homer@example.com"
+	idp.Claims["groups"] = []string{"authentik Admins", "family"}
+
+	p, err := authentik.New("id", "secret", "http://localhost/foo", baseURL, "app")
+	a.NoError(err)
+
+	session, err := p.BeginAuth("state")
+	a.NoError(err)
+	authURL, err := session.GetAuthURL()
+	a.NoError(err)
+	callback, err := idp.Authorize(authURL)
+	a.NoError(err)
+	_, err = session.Authorize(p, callback.Query())
+	a.NoError(err)
+
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("authentik", user.Provider)
+	a.Equal("user", user.UserID)
+	a.Equal("homer", user.NickName)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal([]string{"authentik Admins", "family"}, user.Groups)
+
+	logoutURL, err := p.LogoutURL(user.IDToken, "http://localhost/bye")
+	a.NoError(err)
+	a.Contains(logoutURL, idp.EndSessionURL()+"?")
+}
@@ -4,6 +4,7 @@
 package azuread
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -62,6 +64,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 // Client is HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
@@ -123,13 +133,12 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
 
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
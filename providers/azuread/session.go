@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
 )
 
 // Session is the implementation of `goth.Session` for accessing AzureAD.
@@ -61,3 +63,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(session)
 	return session, err
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
@@ -1,13 +1,16 @@
 package azureadv2
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -28,10 +31,11 @@ type (
 
 	// Provider is the implementation of `goth.Provider` for accessing AzureAD V2.
 	Provider struct {
-		ClientKey    string
-		Secret       string
-		CallbackURL  string
-		HTTPClient   *http.Client
+		ClientKey   string
+		Secret      string
+		CallbackURL string
+		HTTPClient  *http.Client
+		goth.AdditionalParams
 		config       *oauth2.Config
 		providerName string
 	}
@@ -110,9 +114,17 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 // Client is HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return p.TokenClient(goth.HTTPClientWithFallBack(p.HTTPClient))
 }
 
 // Debug is a no-op for the package
@@ -120,10 +132,14 @@ func (p *Provider) Debug(debug bool) {}
 
 // BeginAuth asks for an authentication end-point for AzureAD.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	authURL := p.config.AuthCodeURL(state)
+	return p.BeginAuthWithParams(state, nil)
+}
 
+// BeginAuthWithParams is like BeginAuth, adding params (e.g. domain_hint or
+// login_hint) to the authentication end-point.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
 	return &Session{
-		AuthURL: authURL,
+		AuthURL: p.config.AuthCodeURL(state, append(p.AuthCodeOptions(), goth.AuthURLParamOptions(params)...)...),
 	}, nil
 }
 
@@ -171,13 +187,12 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
 
 func authorizationHeader(session *Session) (string, string) {
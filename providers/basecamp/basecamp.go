@@ -0,0 +1,263 @@
+// Package basecamp implements the OAuth2 protocol for authenticating users
+// through their 37signals ID, used by Basecamp.
+// See https://github.com/basecamp/api/blob/master/sections/authentication.md
+package basecamp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
+)
+
+const (
+	authURL               string = "https://launchpad.37signals.com/authorization/new"
+	tokenURL              string = "https://launchpad.37signals.com/authorization/token"
+	endpointAuthorization string = "https://launchpad.37signals.com/authorization.json"
+)
+
+// New creates a new Basecamp provider, and sets up important connection details.
+// You should always call `basecamp.New` to get a new Provider. Never try to create
+// one manually. Basecamp has no scopes, the application gets access to all the
+// accounts of the user.
+func New(clientKey, secret, callbackURL string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "basecamp",
+	}
+	p.config = newConfig(p)
+	return p
+}
+
+// Provider is the implementation of `goth.Provider` for accessing Basecamp.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
+// Client is HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the basecamp package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks Basecamp for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state, oauth2.SetAuthURLParam("type", "web_server")),
+	}, nil
+}
+
+// Account is a Basecamp account the user has access to.
+type Account struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	Product string `json:"product"`
+	// Href is the base URL of the API of the account, e.g.
+	// https://3.basecampapi.com/999999999.
+	Href    string `json:"href"`
+	AppHref string `json:"app_href"`
+}
+
+// AccountHrefsKey is the key of User.RawData holding the API URLs of the
+// accounts the user has access to, as a []string, so that applications can
+// pick the account to work with. The accounts themselves are under
+// "accounts", see Accounts.
+const AccountHrefsKey = "account_hrefs"
+
+// Accounts returns the Basecamp accounts user, returned by FetchUser, has
+// access to.
+func Accounts(user goth.User) ([]Account, error) {
+	b, err := json.Marshal(user.RawData["accounts"])
+	if err != nil {
+		return nil, err
+	}
+	var accounts []Account
+	err = json.Unmarshal(b, &accounts)
+	return accounts, err
+}
+
+// FetchUser will go to Basecamp and access the identity of the user and the
+// accounts they have access to.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+		Provider:     p.Name(),
+	}
+
+	if user.AccessToken == "" {
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", endpointAuthorization, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
+	}
+
+	bits, err := io.ReadAll(response.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	u := struct {
+		Identity struct {
+			ID           int64  `json:"id"`
+			FirstName    string `json:"first_name"`
+			LastName     string `json:"last_name"`
+			EmailAddress string `json:"email_address"`
+		} `json:"identity"`
+		Accounts []Account `json:"accounts"`
+	}{}
+
+	err := json.NewDecoder(r).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = strconv.FormatInt(u.Identity.ID, 10)
+	user.Email = u.Identity.EmailAddress
+	user.FirstName = u.Identity.FirstName
+	user.LastName = u.Identity.LastName
+	user.Name = strings.TrimSpace(u.Identity.FirstName + " " + u.Identity.LastName)
+
+	hrefs := make([]string, 0, len(u.Accounts))
+	for _, a := range u.Accounts {
+		hrefs = append(hrefs, a.Href)
+	}
+	user.RawData[AccountHrefsKey] = hrefs
+	return nil
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+// Basecamp expects a type parameter rather than a grant_type one, so the
+// request is made without the oauth2 package.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	if refreshToken == "" {
+		return nil, errors.New("No refresh token provided")
+	}
+
+	form := url.Values{
+		"type":          {"refresh"},
+		"refresh_token": {refreshToken},
+		"client_id":     {p.ClientKey},
+		"client_secret": {p.Secret},
+		"redirect_uri":  {p.CallbackURL},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to refresh the token", p.providerName, response.StatusCode)
+	}
+
+	var body struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.AccessToken == "" {
+		return nil, errors.New("Invalid token received from provider")
+	}
+
+	return &oauth2.Token{
+		AccessToken:  body.AccessToken,
+		TokenType:    "Bearer",
+		RefreshToken: body.RefreshToken,
+		Expiry:       goth.ExpiryFromExpiresIn(body.ExpiresIn),
+	}, nil
+}
+
+func newConfig(provider *Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   authURL,
+			TokenURL:  tokenURL,
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+}
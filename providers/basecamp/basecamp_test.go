@@ -0,0 +1,128 @@
+package basecamp_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/basecamp"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := basecampProvider(nil)
+	a.Equal(provider.ClientKey, "basecamp_key")
+	a.Equal(provider.Secret, "basecamp_secret")
+	a.Equal(provider.CallbackURL, "/foo")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Implements((*goth.Provider)(nil), basecampProvider(nil))
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	session, err := basecampProvider(nil).BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*basecamp.Session)
+	a.Contains(s.AuthURL, "https://launchpad.37signals.com/authorization/new")
+	a.Contains(s.AuthURL, "type=web_server")
+	a.Contains(s.AuthURL, "state=test_state")
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	session, err := basecampProvider(nil).UnmarshalSession(`{"AuthURL":"https://launchpad.37signals.com/authorization/new","AccessToken":"1234567890"}`)
+	a.NoError(err)
+	s := session.(*basecamp.Session)
+	a.Equal(s.AuthURL, "https://launchpad.37signals.com/authorization/new")
+	a.Equal(s.AccessToken, "1234567890")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := basecampProvider(func(req *http.Request) (*http.Response, error) {
+		a.Equal("https://launchpad.37signals.com/authorization.json", req.URL.String())
+		a.Equal("Bearer 1234567890", req.Header.Get("Authorization"))
+		return response(http.StatusOK, `{
+			"expires_at": "2030-01-01T00:00:00Z",
+			"identity": {"id": 9999999, "first_name": "Jason", "last_name": "Fried", "email_address": "jason@example.com"},
+			"accounts": [
+				{"product": "bc3", "id": 99999999, "name": "Wayne Enterprises, Ltd.", "href": "https://3.basecampapi.com/99999999", "app_href": "https://3.basecamp.com/99999999"},
+				{"product": "bc3", "id": 88888888, "name": "Acme", "href": "https://3.basecampapi.com/88888888", "app_href": "https://3.basecamp.com/88888888"}
+			]
+		}`), nil
+	})
+
+	user, err := provider.FetchUser(&basecamp.Session{AccessToken: "1234567890", RefreshToken: "refresh"})
+	a.NoError(err)
+	a.Equal("9999999", user.UserID)
+	a.Equal("Jason Fried", user.Name)
+	a.Equal("Jason", user.FirstName)
+	a.Equal("Fried", user.LastName)
+	a.Equal("jason@example.com", user.Email)
+	a.Equal("refresh", user.RefreshToken)
+	a.Equal([]string{"https://3.basecampapi.com/99999999", "https://3.basecampapi.com/88888888"}, user.RawData[basecamp.AccountHrefsKey])
+
+	accounts, err := basecamp.Accounts(user)
+	a.NoError(err)
+	a.Len(accounts, 2)
+	a.Equal(int64(88888888), accounts[1].ID)
+	a.Equal("Acme", accounts[1].Name)
+	a.Equal("https://3.basecamp.com/88888888", accounts[1].AppHref)
+}
+
+func Test_RefreshToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := basecampProvider(func(req *http.Request) (*http.Response, error) {
+		a.Equal("https://launchpad.37signals.com/authorization/token", req.URL.String())
+		a.NoError(req.ParseForm())
+		a.Equal("refresh", req.PostForm.Get("type"))
+		a.Equal("refresh_token", req.PostForm.Get("refresh_token"))
+		a.Equal("basecamp_key", req.PostForm.Get("client_id"))
+		a.Equal("basecamp_secret", req.PostForm.Get("client_secret"))
+		return response(http.StatusOK, `{"access_token":"new_token","expires_in":1209600}`), nil
+	})
+
+	token, err := provider.RefreshToken("refresh_token")
+	a.NoError(err)
+	a.Equal("new_token", token.AccessToken)
+	a.False(token.Expiry.IsZero())
+
+	_, err = provider.RefreshToken("")
+	a.Error(err)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func response(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func basecampProvider(rt roundTripper) *basecamp.Provider {
+	p := basecamp.New("basecamp_key", "basecamp_secret", "/foo")
+	if rt != nil {
+		p.HTTPClient = &http.Client{Transport: rt}
+	}
+	return p
+}
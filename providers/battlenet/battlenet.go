@@ -5,20 +5,66 @@ package battlenet
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
+// Region is a Battle.net region. Accounts, and so tokens, belong to a region,
+// and the game data and profile APIs are served by a host per region.
+type Region string
+
+// Regions of Battle.net.
 const (
-	authURL      string = "https://us.battle.net/oauth/authorize"
-	tokenURL     string = "https://us.battle.net/oauth/token"
-	endpointUser string = "https://us.battle.net/oauth/userinfo"
+	RegionUS Region = "us"
+	RegionEU Region = "eu"
+	RegionKR Region = "kr"
+	RegionTW Region = "tw"
+	// RegionCN is served by separate endpoints, from mainland China.
+	RegionCN Region = "cn"
 )
 
+// RawDataBattleTag is the key of User.RawData holding the BattleTag of the
+// user, e.g. "Homer#1234", which is also their NickName.
+const RawDataBattleTag = "battletag"
+
+// ErrRegionMismatch is returned when a session authorized in a region is used
+// with a provider configured for another one.
+var ErrRegionMismatch = errors.New("battlenet: the session belongs to another region")
+
+type endpoints struct {
+	authURL  string
+	tokenURL string
+	userURL  string
+	apiURL   string
+}
+
+func regionEndpoints(region Region) (endpoints, bool) {
+	switch region {
+	case RegionUS, RegionEU, RegionKR, RegionTW:
+		return endpoints{
+			authURL:  "https://" + string(region) + ".battle.net/oauth/authorize",
+			tokenURL: "https://" + string(region) + ".battle.net/oauth/token",
+			userURL:  "https://" + string(region) + ".battle.net/oauth/userinfo",
+			apiURL:   "https://" + string(region) + ".api.blizzard.com",
+		}, true
+	case RegionCN:
+		return endpoints{
+			authURL:  "https://oauth.battlenet.com.cn/authorize",
+			tokenURL: "https://oauth.battlenet.com.cn/token",
+			userURL:  "https://oauth.battlenet.com.cn/oauth/userinfo",
+			apiURL:   "https://gateway.battlenet.com.cn",
+		}, true
+	}
+	return endpoints{}, false
+}
+
 // Provider is the implementation of `goth.Provider` for accessing Battle.net.
 type Provider struct {
 	ClientKey    string
@@ -27,20 +73,50 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+	region       Region
+	endpoints    endpoints
 }
 
-// New creates a new Battle.net provider and sets up important connection details.
-// You should always call `battlenet.New` to get a new provider.  Never try to
-// create one manually.
+// New creates a new Battle.net provider for the US region and sets up
+// important connection details. You should always call `battlenet.New` or
+// `battlenet.NewWithRegion` to get a new provider.  Never try to create one
+// manually.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p, _ := NewWithRegion(RegionUS, clientKey, secret, callbackURL, scopes...)
+	return p
+}
+
+// NewWithRegion creates a new Battle.net provider for the accounts of the
+// given region. Users of other regions can't sign in with it, so
+// applications serving several regions need a provider per region, with a
+// different name set with SetName.
+func NewWithRegion(region Region, clientKey, secret, callbackURL string, scopes ...string) (*Provider, error) {
+	e, ok := regionEndpoints(region)
+	if !ok {
+		return nil, fmt.Errorf("battlenet: unknown region %q", region)
+	}
 	p := &Provider{
 		ClientKey:    clientKey,
 		Secret:       secret,
 		CallbackURL:  callbackURL,
 		providerName: "battlenet",
+		region:       region,
+		endpoints:    e,
 	}
 	p.config = newConfig(p, scopes)
-	return p
+	return p, nil
+}
+
+// Region returns the region the provider signs users in.
+func (p *Provider) Region() Region {
+	return p.region
+}
+
+// APIURL returns the base URL of the Battle.net APIs of the region of the
+// provider, e.g. https://eu.api.blizzard.com, or https://gateway.battlenet.com.cn
+// in China, to call with the access token of the users.
+func (p *Provider) APIURL() string {
+	return p.endpoints.apiURL
 }
 
 // Name is the name used to retrieve this provider later.
@@ -53,6 +129,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -64,9 +148,20 @@ func (p *Provider) Debug(debug bool) {}
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 	return &Session{
 		AuthURL: p.config.AuthCodeURL(state),
+		Region:  p.region,
 	}, nil
 }
 
+// checkRegion returns an error wrapping ErrRegionMismatch if sess was
+// authorized in another region than the one of p. Sessions created before
+// regions were recorded are assumed to be of the region of p.
+func (p *Provider) checkRegion(sess *Session) error {
+	if sess.Region != "" && sess.Region != p.region {
+		return fmt.Errorf("%w: %s instead of %s", ErrRegionMismatch, sess.Region, p.region)
+	}
+	return nil
+}
+
 // FetchUser will go to Battle.net and access basic information about the user.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	sess := session.(*Session)
@@ -81,10 +176,13 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		// data is not yet retrieved since accessToken is still empty
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
+	if err := p.checkRegion(sess); err != nil {
+		return user, err
+	}
 
 	// Get the userID, battlenet needs userID in order to get user profile info
 	c := p.Client()
-	req, err := http.NewRequest("GET", endpointUser, nil)
+	req, err := http.NewRequest("GET", p.endpoints.userURL, nil)
 	if err != nil {
 		return user, err
 	}
@@ -99,6 +197,8 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, err
 	}
 
+	defer response.Body.Close()
+
 	if response.StatusCode != http.StatusOK {
 		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
 	}
@@ -118,18 +218,34 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	user.NickName = u.Battletag
+	user.Name, _ = SplitBattleTag(u.Battletag)
 	user.UserID = fmt.Sprintf("%d", u.ID)
+	user.RawData = map[string]interface{}{
+		"id":             u.ID,
+		RawDataBattleTag: u.Battletag,
+		"region":         string(p.region),
+	}
 	return user, err
 }
 
+// SplitBattleTag splits a BattleTag, e.g. "Homer#1234", into the name chosen
+// by the user and the code telling apart the users who chose the same name.
+// The code is empty if tag has none.
+func SplitBattleTag(tag string) (name, code string) {
+	if i := strings.LastIndex(tag, "#"); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
 		ClientSecret: provider.Secret,
 		RedirectURL:  provider.CallbackURL,
 		Endpoint: oauth2.Endpoint{
-			AuthURL:  authURL,
-			TokenURL: tokenURL,
+			AuthURL:  provider.endpoints.authURL,
+			TokenURL: provider.endpoints.tokenURL,
 		},
 		Scopes: []string{},
 	}
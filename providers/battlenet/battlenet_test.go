@@ -1,7 +1,10 @@
 package battlenet_test
 
 import (
+	"io"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -48,6 +51,76 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AccessToken, "1234567890")
 }
 
+func Test_NewWithRegion(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p, err := battlenet.NewWithRegion(battlenet.RegionEU, "key", "secret", "/foo")
+	a.NoError(err)
+	a.Equal(battlenet.RegionEU, p.Region())
+	a.Equal("https://eu.api.blizzard.com", p.APIURL())
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*battlenet.Session).AuthURL, "https://eu.battle.net/oauth/authorize")
+	a.Equal(battlenet.RegionEU, session.(*battlenet.Session).Region)
+
+	p, err = battlenet.NewWithRegion(battlenet.RegionCN, "key", "secret", "/foo")
+	a.NoError(err)
+	a.Equal("https://gateway.battlenet.com.cn", p.APIURL())
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*battlenet.Session).AuthURL, "https://oauth.battlenet.com.cn/authorize")
+
+	_, err = battlenet.NewWithRegion("xx", "key", "secret", "/foo")
+	a.Error(err)
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p, err := battlenet.NewWithRegion(battlenet.RegionKR, "key", "secret", "/foo")
+	a.NoError(err)
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("https://kr.battle.net/oauth/userinfo", req.URL.String())
+		a.Equal("Bearer token", req.Header.Get("Authorization"))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"sub":"123","id":123,"battletag":"Homer#1234"}`)),
+		}, nil
+	})}
+
+	user, err := p.FetchUser(&battlenet.Session{AccessToken: "token", Region: battlenet.RegionKR})
+	a.NoError(err)
+	a.Equal("123", user.UserID)
+	a.Equal("Homer#1234", user.NickName)
+	a.Equal("Homer", user.Name)
+	a.Equal("Homer#1234", user.RawData[battlenet.RawDataBattleTag])
+	a.Equal("kr", user.RawData["region"])
+
+	_, err = p.FetchUser(&battlenet.Session{AccessToken: "token", Region: battlenet.RegionUS})
+	a.ErrorIs(err, battlenet.ErrRegionMismatch)
+}
+
+func Test_SplitBattleTag(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	name, code := battlenet.SplitBattleTag("Homer#1234")
+	a.Equal("Homer", name)
+	a.Equal("1234", code)
+
+	name, code = battlenet.SplitBattleTag("Homer")
+	a.Equal("Homer", name)
+	a.Equal("", code)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 func provider() *battlenet.Provider {
 	return battlenet.New(os.Getenv("BATTLENET_KEY"), os.Getenv("BATTLENET_SECRET"), "/foo")
 }
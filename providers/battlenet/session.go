@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Battle.net.
@@ -15,6 +17,8 @@ type Session struct {
 	AccessToken  string
 	RefreshToken string
 	ExpiresAt    time.Time
+	// Region is the region of the provider the session was created by.
+	Region Region `json:",omitempty"`
 }
 
 var _ goth.Session = &Session{}
@@ -30,6 +34,9 @@ func (s Session) GetAuthURL() (string, error) {
 // Authorize the session with Battle.net and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
+	if err := p.checkRegion(s); err != nil {
+		return "", err
+	}
 	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
 	if err != nil {
 		return "", err
@@ -61,3 +68,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
 	return s, err
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
@@ -3,22 +3,45 @@ package bitbucket
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
 const (
-	authURL         string = "https://bitbucket.org/site/oauth2/authorize"
-	tokenURL        string = "https://bitbucket.org/site/oauth2/access_token"
-	endpointProfile string = "https://api.bitbucket.org/2.0/user"
-	endpointEmail   string = "https://api.bitbucket.org/2.0/user/emails"
+	authURL            string = "https://bitbucket.org/site/oauth2/authorize"
+	tokenURL           string = "https://bitbucket.org/site/oauth2/access_token"
+	endpointProfile    string = "https://api.bitbucket.org/2.0/user"
+	endpointEmail      string = "https://api.bitbucket.org/2.0/user/emails"
+	endpointWorkspaces string = "https://api.bitbucket.org/2.0/workspaces"
 )
 
+// Keys of User.RawData set by FetchUser, besides the fields of the user
+// returned by Bitbucket.
+const (
+	// RawDataAccountStatus holds the status of the account, e.g. "active".
+	RawDataAccountStatus = "account_status"
+	// RawDataWorkspaces holds the workspaces the user is a member of, as a
+	// []Workspace, when SetFetchWorkspaces is enabled.
+	RawDataWorkspaces = "workspaces"
+)
+
+// Workspace is a workspace the user is a member of.
+// See https://developer.atlassian.com/cloud/bitbucket/rest/api-group-workspaces/
+type Workspace struct {
+	UUID      string `json:"uuid"`
+	Slug      string `json:"slug"`
+	Name      string `json:"name"`
+	IsPrivate bool   `json:"is_private"`
+}
+
 type EmailAddress struct {
 	Type        string `json:"type"`
 	Links       Links  `json:"links"`
@@ -40,6 +63,8 @@ type MailList struct {
 	Pagelen int            `json:"pagelen"`
 	Size    int            `json:"size"`
 	Page    int            `json:"page"`
+	// Next is the URL of the next page, empty on the last one.
+	Next string `json:"next"`
 }
 
 // New creates a new Bitbucket provider, and sets up important connection details.
@@ -58,12 +83,13 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 
 // Provider is the implementation of `goth.Provider` for accessing Bitbucket.
 type Provider struct {
-	ClientKey    string
-	Secret       string
-	CallbackURL  string
-	HTTPClient   *http.Client
-	config       *oauth2.Config
-	providerName string
+	ClientKey       string
+	Secret          string
+	CallbackURL     string
+	HTTPClient      *http.Client
+	config          *oauth2.Config
+	providerName    string
+	fetchWorkspaces bool
 }
 
 // Name is the name used to retrieve this provider later.
@@ -76,6 +102,21 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
+// SetFetchWorkspaces makes FetchUser also fetch the workspaces the user is a
+// member of into User.RawData, see RawDataWorkspaces and IsMemberOfWorkspace.
+// The workspace slugs are set as User.Groups as well.
+func (p *Provider) SetFetchWorkspaces(enabled bool) {
+	p.fetchWorkspaces = enabled
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -115,6 +156,18 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, err
 	}
 
+	if p.fetchWorkspaces {
+		workspaces, err := p.getWorkspaces(sess)
+		if err != nil {
+			return user, err
+		}
+		user.RawData[RawDataWorkspaces] = workspaces
+		user.Groups = make([]string, len(workspaces))
+		for i, w := range workspaces {
+			user.Groups[i] = w.Slug
+		}
+	}
+
 	return user, nil
 }
 
@@ -152,6 +205,7 @@ func (p *Provider) getUserInfo(user *goth.User, sess *Session) error {
 			} `json:"avatar"`
 		} `json:"links"`
 		Username string `json:"username"`
+		Nickname string `json:"nickname"`
 		Name     string `json:"display_name"`
 		Location string `json:"location"`
 	}{}
@@ -161,7 +215,11 @@ func (p *Provider) getUserInfo(user *goth.User, sess *Session) error {
 	}
 
 	user.Name = u.Name
+	// usernames are no longer returned for most users, nicknames are
 	user.NickName = u.Username
+	if user.NickName == "" {
+		user.NickName = u.Nickname
+	}
 	user.AvatarURL = u.Links.Avatar.URL
 	user.UserID = u.ID
 	user.Location = u.Location
@@ -169,8 +227,47 @@ func (p *Provider) getUserInfo(user *goth.User, sess *Session) error {
 	return nil
 }
 
+// getEmail sets the primary email address of the user, going through the
+// pages of their addresses until it is found.
 func (p *Provider) getEmail(user *goth.User, sess *Session) error {
-	req, err := http.NewRequest("GET", endpointEmail, nil)
+	for next := endpointEmail; next != ""; {
+		var mailList MailList
+		if err := p.getPage(next, sess, "email addresses", &mailList); err != nil {
+			return err
+		}
+
+		for _, emailAddress := range mailList.Values {
+			if emailAddress.IsPrimary && emailAddress.IsConfirmed {
+				user.Email = emailAddress.Email
+				return nil
+			}
+		}
+		next = mailList.Next
+	}
+
+	return fmt.Errorf("%s did not return any confirmed, primary email address", p.providerName)
+}
+
+// getWorkspaces returns all the workspaces of the user, a page at a time.
+func (p *Provider) getWorkspaces(sess *Session) ([]Workspace, error) {
+	workspaces := []Workspace{}
+	for next := endpointWorkspaces + "?pagelen=100"; next != ""; {
+		page := struct {
+			Values []Workspace `json:"values"`
+			Next   string      `json:"next"`
+		}{}
+		if err := p.getPage(next, sess, "workspaces", &page); err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, page.Values...)
+		next = page.Next
+	}
+	return workspaces, nil
+}
+
+// getPage decodes the page of a paginated endpoint at pageURL into v.
+func (p *Provider) getPage(pageURL string, sess *Session, what string, v interface{}) error {
+	req, err := http.NewRequest("GET", pageURL, nil)
 	if err != nil {
 		return err
 	}
@@ -182,23 +279,38 @@ func (p *Provider) getEmail(user *goth.User, sess *Session) error {
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("%s responded with a %d trying to fetch email addresses", p.providerName, response.StatusCode)
+		return fmt.Errorf("%s responded with a %d trying to fetch %s", p.providerName, response.StatusCode, what)
 	}
+	return json.NewDecoder(response.Body).Decode(v)
+}
 
-	var mailList MailList
-	err = json.NewDecoder(response.Body).Decode(&mailList)
-	if err != nil {
-		return err
+// IsMemberOfWorkspace tells whether user, fetched with SetFetchWorkspaces
+// enabled, is a member of the workspace with the given slug or UUID, with or
+// without the braces Bitbucket wraps UUIDs in. It also works on users whose
+// RawData went through JSON, e.g. when kept in a session.
+func IsMemberOfWorkspace(user goth.User, workspace string) bool {
+	matches := func(slug, uuid string) bool {
+		return slug == workspace || (uuid != "" && strings.Trim(uuid, "{}") == strings.Trim(workspace, "{}"))
 	}
-
-	for _, emailAddress := range mailList.Values {
-		if emailAddress.IsPrimary && emailAddress.IsConfirmed {
-			user.Email = emailAddress.Email
-			return nil
+	switch workspaces := user.RawData[RawDataWorkspaces].(type) {
+	case []Workspace:
+		for _, w := range workspaces {
+			if matches(w.Slug, w.UUID) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, w := range workspaces {
+			if m, ok := w.(map[string]interface{}); ok {
+				slug, _ := m["slug"].(string)
+				uuid, _ := m["uuid"].(string)
+				if matches(slug, uuid) {
+					return true
+				}
+			}
 		}
 	}
-
-	return fmt.Errorf("%s did not return any confirmed, primary email address", p.providerName)
+	return false
 }
 
 func authenticateRequest(req *http.Request, sess *Session) {
@@ -231,11 +343,10 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
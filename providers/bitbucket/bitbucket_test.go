@@ -2,7 +2,10 @@ package bitbucket_test
 
 import (
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -54,6 +57,61 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(session.AccessToken, "1234567890")
 }
 
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var requested []string
+	provider := bitbucketProvider()
+	provider.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req.URL.String())
+		var body string
+		switch req.URL.String() {
+		case "https://api.bitbucket.org/2.0/user":
+			body = `{"uuid":"{42}","display_name":"Homer Simpson","nickname":"homer","account_status":"active"}`
+		case "https://api.bitbucket.org/2.0/user/emails":
+			body = `{"values":[{"email":"old@example.com","is_confirmed":true}],"next":"https://api.bitbucket.org/2.0/user/emails?page=2"}`
+		case "https://api.bitbucket.org/2.0/user/emails?page=2":
+			body = `{"values":[{"email":"homer@example.com","is_primary":true,"is_confirmed":true}]}`
+		case "https://api.bitbucket.org/2.0/workspaces?pagelen=100":
+			body = `{"values":[{"uuid":"{1}","slug":"springfield","name":"Springfield"}],"next":"https://api.bitbucket.org/2.0/workspaces?pagelen=100&page=2"}`
+		case "https://api.bitbucket.org/2.0/workspaces?pagelen=100&page=2":
+			body = `{"values":[{"uuid":"{2}","slug":"plant","name":"Power Plant"}]}`
+		default:
+			return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	user, err := provider.FetchUser(&bitbucket.Session{AccessToken: "token"})
+	a.NoError(err)
+	a.Equal("{42}", user.UserID)
+	a.Equal("homer", user.NickName)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("active", user.RawData[bitbucket.RawDataAccountStatus])
+	a.Nil(user.RawData[bitbucket.RawDataWorkspaces])
+	a.Len(requested, 3)
+
+	provider.SetFetchWorkspaces(true)
+	user, err = provider.FetchUser(&bitbucket.Session{AccessToken: "token"})
+	a.NoError(err)
+	a.Equal([]string{"springfield", "plant"}, user.Groups)
+	a.True(bitbucket.IsMemberOfWorkspace(user, "plant"))
+	a.True(bitbucket.IsMemberOfWorkspace(user, "1"))
+	a.True(bitbucket.IsMemberOfWorkspace(user, "{2}"))
+	a.False(bitbucket.IsMemberOfWorkspace(user, "shelbyville"))
+
+	user.RawData[bitbucket.RawDataWorkspaces] = []interface{}{map[string]interface{}{"uuid": "{1}", "slug": "springfield"}}
+	a.True(bitbucket.IsMemberOfWorkspace(user, "springfield"))
+	a.True(bitbucket.IsMemberOfWorkspace(user, "{1}"))
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 func bitbucketProvider() *bitbucket.Provider {
 	return bitbucket.New(os.Getenv("BITBUCKET_KEY"), os.Getenv("BITBUCKET_SECRET"), "/foo", "user")
 }
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Bitbucket.
@@ -59,3 +61,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 func (s Session) String() string {
 	return s.Marshal()
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
@@ -12,6 +12,7 @@ import (
 	"net/http"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -57,6 +58,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -79,6 +88,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	u := goth.User{
 		Provider:    p.Name(),
 		AccessToken: s.AccessToken,
+		ExpiresAt:   s.ExpiresAt,
 	}
 
 	if u.AccessToken == "" {
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/markbates/goth"
 )
@@ -12,6 +13,7 @@ import (
 type Session struct {
 	AuthURL     string
 	AccessToken string
+	ExpiresAt   time.Time
 }
 
 // Ensure `bitly.Session` implements `goth.Session`.
@@ -38,6 +40,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	}
 
 	s.AccessToken = token.AccessToken
+	s.ExpiresAt = token.Expiry
 	return token.AccessToken, err
 }
 
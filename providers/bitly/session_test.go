@@ -29,5 +29,5 @@ func Test_ToJSON(t *testing.T) {
 		AuthURL:     "https://bitly.com/oauth/authorize",
 		AccessToken: "access_token",
 	}
-	a.Equal(s.Marshal(), `{"AuthURL":"https://bitly.com/oauth/authorize","AccessToken":"access_token"}`)
+	a.Equal(s.Marshal(), `{"AuthURL":"https://bitly.com/oauth/authorize","AccessToken":"access_token","ExpiresAt":"0001-01-01T00:00:00Z"}`)
 }
@@ -1,7 +1,12 @@
 package box_test
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -48,6 +53,71 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AccessToken, "1234567890")
 }
 
+func Test_ClientCredentialsToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := box.New("key", "secret", "/foo")
+	var form url.Values
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		req.ParseForm()
+		form = req.PostForm
+		return jsonResponse(`{"access_token":"service-token","token_type":"bearer","expires_in":3600}`), nil
+	})}
+
+	token, err := p.ClientCredentialsToken(context.Background(), box.SubjectUser, "42")
+	a.NoError(err)
+	a.Equal("service-token", token.AccessToken)
+	a.Equal("client_credentials", form.Get("grant_type"))
+	a.Equal("key", form.Get("client_id"))
+	a.Equal("secret", form.Get("client_secret"))
+	a.Equal("user", form.Get("box_subject_type"))
+	a.Equal("42", form.Get("box_subject_id"))
+
+	_, err = p.ClientCredentialsToken(context.Background(), "group", "42")
+	a.Error(err)
+}
+
+func Test_TokenExchange(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := box.New("key", "secret", "/foo")
+	var form url.Values
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		req.ParseForm()
+		form = req.PostForm
+		return jsonResponse(`{"access_token":"downscoped","token_type":"bearer","expires_in":3600,"issued_token_type":"urn:ietf:params:oauth:token-type:access_token"}`), nil
+	})}
+
+	token, err := p.TokenExchange(context.Background(), "user-token", []string{"item_preview", "item_download"}, "https://api.box.com/2.0/files/123")
+	a.NoError(err)
+	a.Equal("downscoped", token.AccessToken)
+	a.False(token.Expiry.IsZero())
+	a.Equal("urn:ietf:params:oauth:grant-type:token-exchange", form.Get("grant_type"))
+	a.Equal("user-token", form.Get("subject_token"))
+	a.Equal("urn:ietf:params:oauth:token-type:access_token", form.Get("subject_token_type"))
+	a.Equal("item_preview item_download", form.Get("scope"))
+	a.Equal("https://api.box.com/2.0/files/123", form.Get("resource"))
+
+	_, err = p.TokenExchange(context.Background(), "user-token", nil, "")
+	a.Error(err)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func jsonResponse(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
 func provider() *box.Provider {
 	return box.New(os.Getenv("BOX_KEY"), os.Getenv("BOX_SECRET"), "/foo")
 }
@@ -0,0 +1,113 @@
+package box
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// Subject types of the Client Credentials Grant, see ClientCredentialsToken.
+const (
+	// SubjectEnterprise gets a token for the service account of the
+	// application in the enterprise whose ID is given.
+	SubjectEnterprise = "enterprise"
+	// SubjectUser gets a token acting as the managed or app user whose ID is
+	// given.
+	SubjectUser = "user"
+)
+
+const (
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	accessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// ClientCredentialsToken gets a token with the Client Credentials Grant of
+// applications using server authentication, without a user signing in. The
+// token is issued for the service account of the application when
+// subjectType is SubjectEnterprise and subjectID the ID of the enterprise, or
+// as the user whose ID is subjectID when subjectType is SubjectUser, which the
+// application must be allowed to do in the Box admin console.
+// See https://developer.box.com/guides/authentication/client-credentials/
+func (p *Provider) ClientCredentialsToken(ctx context.Context, subjectType, subjectID string) (*oauth2.Token, error) {
+	if subjectType != SubjectEnterprise && subjectType != SubjectUser {
+		return nil, fmt.Errorf("box: unknown subject type %q", subjectType)
+	}
+
+	c := &clientcredentials.Config{
+		ClientID:     p.ClientKey,
+		ClientSecret: p.Secret,
+		TokenURL:     p.config.Endpoint.TokenURL,
+		EndpointParams: url.Values{
+			"box_subject_type": {subjectType},
+			"box_subject_id":   {subjectID},
+		},
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+	return c.Token(goth.ContextWithClient(ctx, p.Client()))
+}
+
+// TokenExchange trades accessToken for a downscoped token, restricted to the
+// given scopes, e.g. "item_preview" or "item_upload", and, if resource is
+// set, to the file or folder at that URL, e.g.
+// https://api.box.com/2.0/files/123456. Such tokens can safely be handed to
+// a browser, e.g. for Box UI Elements. Downscoped tokens can't be refreshed.
+// See https://developer.box.com/guides/authentication/tokens/downscope/
+func (p *Provider) TokenExchange(ctx context.Context, accessToken string, scopes []string, resource string) (*oauth2.Token, error) {
+	if len(scopes) == 0 {
+		return nil, errors.New("box: downscoping a token requires at least one scope")
+	}
+
+	form := url.Values{
+		"grant_type":         {tokenExchangeGrantType},
+		"subject_token":      {accessToken},
+		"subject_token_type": {accessTokenType},
+		"scope":              {strings.Join(scopes, " ")},
+	}
+	if resource != "" {
+		form.Set("resource", resource)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.Endpoint.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to downscope the token", p.providerName, response.StatusCode)
+	}
+
+	tr := struct {
+		AccessToken     string `json:"access_token"`
+		TokenType       string `json:"token_type"`
+		ExpiresIn       int64  `json:"expires_in"`
+		IssuedTokenType string `json:"issued_token_type"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&tr); err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{
+		AccessToken: tr.AccessToken,
+		TokenType:   tr.TokenType,
+	}
+	if tr.ExpiresIn > 0 {
+		token.Expiry = goth.Now().Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return token.WithExtra(map[string]interface{}{"issued_token_type": tr.IssuedTokenType}), nil
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -46,6 +47,13 @@ func (p Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	p.CallbackURL = callbackURL
+	p.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &p
+}
+
 func (p Provider) BeginAuth(state string) (goth.Session, error) {
 	url := p.config.AuthCodeURL(state)
 	return &Session{
@@ -2,13 +2,19 @@ package cognito
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/jwkutil"
+	"github.com/markbates/goth/internal/jwtutil"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -22,21 +28,28 @@ import (
 // If you do not perform a full logout their existing token will be used on a login and the user won't be prompted to login until after expiry.
 // To perform a logout
 // - Destroy your session (or however else you handle the logout internally)
-// - redirect to https://CUSTOM_DOMAIN.auth.us-east-1.amazoncognito.com/logout?client_id=clinet_id&logout_uri=http://localhost:8080/
+// - redirect to the URL returned by LogoutURL, e.g. p.LogoutURL("http://localhost:8080/")
 //        (or whatever your login/start page is).
 // - Note that this page needs to be white-labeled as a logout page in the cognito console as well.
+//
+// Call SetUserPool to have the id_token validated against the keys of the user
+// pool, and its groups and custom attributes added to the user.
 
 // This is based upon the implementation for okta
 
 type Provider struct {
-	ClientKey    string
-	Secret       string
-	CallbackURL  string
-	HTTPClient   *http.Client
+	ClientKey   string
+	Secret      string
+	CallbackURL string
+	HTTPClient  *http.Client
+	goth.AdditionalParams
 	config       *oauth2.Config
 	providerName string
 	issuerURL    string
 	profileURL   string
+	logoutURL    string
+	poolIssuer   string
+	jwks         *jwkutil.KeySet
 }
 
 // New creates a new AWS Cognito provider and sets up important connection details.
@@ -59,6 +72,8 @@ func NewCustomisedURL(clientID, secret, callbackURL, authURL, tokenURL, issuerUR
 		providerName: "cognito",
 		issuerURL:    issuerURL,
 		profileURL:   profileURL,
+		logoutURL:    strings.TrimSuffix(authURL, "/oauth2/authorize") + "/logout",
+		jwks:         &jwkutil.KeySet{MinRefreshInterval: jwksMinRefreshInterval},
 	}
 	p.config = newConfig(p, authURL, tokenURL, scopes)
 	return p
@@ -74,8 +89,16 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return p.TokenClient(goth.HTTPClientWithFallBack(p.HTTPClient))
 }
 
 // Debug is a no-op for the aws package.
@@ -88,7 +111,7 @@ func (p *Provider) Debug(debug bool) {
 // BeginAuth asks AWS for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
+		AuthURL: p.config.AuthCodeURL(state, p.AuthCodeOptions()...),
 	}, nil
 }
 
@@ -101,6 +124,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		RefreshToken: sess.RefreshToken,
 		ExpiresAt:    sess.ExpiresAt,
 		UserID:       sess.UserID,
+		IDToken:      sess.IDToken,
 	}
 
 	if user.AccessToken == "" {
@@ -133,14 +157,73 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, err
 	}
 
-	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	userInfo := map[string]interface{}{}
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&userInfo)
 	if err != nil {
 		return user, err
 	}
 
 	err = userFromReader(bytes.NewReader(bits), &user)
+	if err != nil {
+		return user, err
+	}
+	addCustomAttributes(user.RawData, userInfo)
+
+	if sess.IDToken != "" && p.poolIssuer != "" {
+		claims, err := p.ValidateIDToken(sess.IDToken)
+		if err != nil {
+			return user, err
+		}
+		addCustomAttributes(user.RawData, claims)
+		if groups := goth.Claims(claims).Strings("cognito:groups"); groups != nil {
+			user.Groups = groups
+			user.RawData["cognito:groups"] = groups
+		}
+		if username, ok := claims["cognito:username"].(string); ok {
+			user.RawData["cognito:username"] = username
+		}
+	}
 
-	return user, err
+	// the userinfo endpoint doesn't return the groups, but the access token,
+	// which it just accepted, carries them
+	if user.Groups == nil {
+		if groups := accessTokenGroups(sess.AccessToken); groups != nil {
+			user.Groups = groups
+			user.RawData["cognito:groups"] = groups
+		}
+	}
+
+	return user, nil
+}
+
+// LogoutURL returns the URL of the hosted UI logout endpoint, which signs the
+// user out of Cognito and then redirects them to logoutRedirectURL. The URL
+// must be one of the sign out URLs of the app client.
+// See https://docs.aws.amazon.com/cognito/latest/developerguide/logout-endpoint.html
+func (p *Provider) LogoutURL(logoutRedirectURL string) string {
+	v := url.Values{}
+	v.Set("client_id", p.ClientKey)
+	v.Set("logout_uri", logoutRedirectURL)
+	return p.logoutURL + "?" + v.Encode()
+}
+
+// addCustomAttributes copies the custom attributes, whose names start with
+// "custom:", found in claims to rawData.
+func addCustomAttributes(rawData map[string]interface{}, claims map[string]interface{}) {
+	for k, v := range claims {
+		if strings.HasPrefix(k, "custom:") {
+			rawData[k] = v
+		}
+	}
+}
+
+// accessTokenGroups returns the "cognito:groups" claim of the access token.
+func accessTokenGroups(accessToken string) []string {
+	claims, err := jwtutil.Decode(accessToken)
+	if err != nil {
+		return nil
+	}
+	return goth.Claims(claims).Strings("cognito:groups")
 }
 
 func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *oauth2.Config {
@@ -167,7 +250,7 @@ func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *o
 // These are the standard cognito attributes
 // from: https://docs.aws.amazon.com/cognito/latest/developerguide/user-pool-settings-attributes.html
 // all attributes are optional
-// it is possible for there to be custom attributes in cognito, but they don't seem to be passed as in the claims
+// custom attributes are added to the raw data under their "custom:" names by FetchUser
 // all the standard claims are mapped into the raw data
 func userFromReader(r io.Reader, user *goth.User) error {
 	u := struct {
@@ -229,11 +312,10 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
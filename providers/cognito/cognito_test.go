@@ -1,9 +1,17 @@
 package cognito
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/okta"
 	"github.com/stretchr/testify/assert"
@@ -65,3 +73,118 @@ func provider() *okta.Provider {
 func urlCustomisedURLProvider() *okta.Provider {
 	return okta.NewCustomisedURL(os.Getenv("CLIENT_ID"), os.Getenv("CLIENT_SECRET"), "/foo", "http://authURL", "http://tokenURL", "http://issuerURL", "http://profileURL")
 }
+
+func Test_FetchUserGroups(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"sub":"abc-123","email":"homer@example.com","preferred_username":"homer"}`))
+	}))
+	defer ts.Close()
+
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":            "abc-123",
+		"cognito:groups": []string{"admins", "editors"},
+	}).SignedString([]byte("key"))
+	a.NoError(err)
+
+	p := NewCustomisedURL("id", "secret", "/foo", ts.URL+"/oauth2/authorize", ts.URL+"/oauth2/token", ts.URL, ts.URL+"/oauth2/userInfo")
+	user, err := p.FetchUser(&Session{AccessToken: accessToken})
+	a.NoError(err)
+	a.Equal("abc-123", user.UserID)
+	a.Equal([]string{"admins", "editors"}, user.Groups)
+	a.Equal([]string{"admins", "editors"}, user.Claims().Groups())
+}
+
+func Test_FetchUserIDToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	pub, err := jwk.New(&key.PublicKey)
+	a.NoError(err)
+	a.NoError(pub.Set(jwk.KeyIDKey, "kid1"))
+	set := jwk.NewSet()
+	set.Add(pub)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/us-east-1_pool/.well-known/jwks.json":
+			json.NewEncoder(w).Encode(set)
+		case "/oauth2/userInfo":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"sub":"abc-123","email":"homer@example.com","custom:plan":"gold"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	p := NewCustomisedURL("id", "secret", "/foo", ts.URL+"/oauth2/authorize", ts.URL+"/oauth2/token", ts.URL, ts.URL+"/oauth2/userInfo")
+	p.poolIssuer = ts.URL + "/us-east-1_pool"
+
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "kid1"
+		s, err := token.SignedString(key)
+		a.NoError(err)
+		return s
+	}
+
+	idToken := sign(jwt.MapClaims{
+		"iss":              ts.URL + "/us-east-1_pool",
+		"aud":              "id",
+		"sub":              "abc-123",
+		"token_use":        "id",
+		"exp":              time.Now().Add(time.Hour).Unix(),
+		"cognito:groups":   []string{"admins"},
+		"cognito:username": "homer",
+		"custom:team":      "springfield",
+	})
+	user, err := p.FetchUser(&Session{AccessToken: "token", IDToken: idToken})
+	a.NoError(err)
+	a.Equal(idToken, user.IDToken)
+	a.Equal([]string{"admins"}, user.Groups)
+	a.Equal("homer", user.RawData["cognito:username"])
+	a.Equal("springfield", user.RawData["custom:team"])
+	a.Equal("gold", user.RawData["custom:plan"])
+
+	_, err = p.FetchUser(&Session{AccessToken: "token", IDToken: sign(jwt.MapClaims{
+		"iss":       ts.URL + "/us-east-1_pool",
+		"aud":       "someone-else",
+		"token_use": "id",
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	})})
+	a.Error(err)
+
+	_, err = p.ValidateIDToken(sign(jwt.MapClaims{
+		"iss":       ts.URL + "/us-east-1_pool",
+		"aud":       "id",
+		"token_use": "access",
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}))
+	a.Error(err)
+}
+
+func Test_SetUserPool(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := New("id", "secret", "https://example.auth.us-east-1.amazoncognito.com", "/foo")
+	_, err := p.ValidateIDToken("token")
+	a.Error(err)
+
+	p.SetUserPool("us-east-1", "us-east-1_AbCdEfGhI")
+	a.Equal("https://cognito-idp.us-east-1.amazonaws.com/us-east-1_AbCdEfGhI/.well-known/jwks.json", p.jwksURL())
+}
+
+func Test_LogoutURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := New("id", "secret", "https://example.auth.us-east-1.amazoncognito.com", "/foo")
+	a.Equal("https://example.auth.us-east-1.amazoncognito.com/logout?client_id=id&logout_uri=http%3A%2F%2Flocalhost%3A8080%2F", p.LogoutURL("http://localhost:8080/"))
+}
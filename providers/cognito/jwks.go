@@ -0,0 +1,76 @@
+package cognito
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/markbates/goth"
+)
+
+// jwksMinRefreshInterval is the minimum time the key set of the user pool is
+// cached for, regardless of the cache headers it is served with.
+const jwksMinRefreshInterval = 15 * time.Minute
+
+// SetUserPool sets the region and the ID of the user pool, e.g. "us-east-1"
+// and "us-east-1_AbCdEfGhI", which the id_token is issued by. FetchUser only
+// uses the id_token once the user pool is known, as its signature can't be
+// verified otherwise.
+func (p *Provider) SetUserPool(region, userPoolID string) {
+	p.poolIssuer = fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, userPoolID)
+}
+
+// ValidateIDToken verifies the signature of idToken against the keys published
+// by the user pool, checks that it is an id token issued by the user pool for
+// this client and hasn't expired, and returns its claims.
+// See https://docs.aws.amazon.com/cognito/latest/developerguide/amazon-cognito-user-pools-using-tokens-verifying-a-jwt.html
+func (p *Provider) ValidateIDToken(idToken string) (jwt.MapClaims, error) {
+	if p.poolIssuer == "" {
+		return nil, errors.New("cognito: the user pool must be set with SetUserPool to validate id_tokens")
+	}
+	if idToken == "" {
+		return nil, errors.New("no id_token to validate")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, p.verificationKey,
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(p.poolIssuer),
+		jwt.WithAudience(p.ClientKey),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(goth.Now),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if use, _ := claims["token_use"].(string); use != "id" {
+		return nil, fmt.Errorf("cognito: expected an id token, got token_use %q", use)
+	}
+	return claims, nil
+}
+
+func (p *Provider) jwksURL() string {
+	return p.poolIssuer + "/.well-known/jwks.json"
+}
+
+func (p *Provider) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	set, err := p.jwks.Fetch(context.Background(), p.jwksURL(), p.Client())
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := set.LookupKeyID(kid)
+	if !ok {
+		return nil, fmt.Errorf("could not find key %q to verify id_token", kid)
+	}
+
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with AWS Cognito.
@@ -16,6 +18,7 @@ type Session struct {
 	RefreshToken string
 	ExpiresAt    time.Time
 	UserID       string
+	IDToken      string `json:",omitempty"`
 }
 
 var _ goth.Session = &Session{}
@@ -43,6 +46,9 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		s.IDToken = idToken
+	}
 	return token.AccessToken, err
 }
 
@@ -62,3 +68,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
 	return s, err
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
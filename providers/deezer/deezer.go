@@ -14,6 +14,7 @@ import (
 	"strconv"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -23,14 +24,19 @@ const (
 	endpointProfile string = "https://api.deezer.com/user/me"
 )
 
+// ScopeSeparator is the separator Deezer expects between scopes.
+const ScopeSeparator = ","
+
 // Provider is the implementation of `goth.Provider` for accessing Deezer.
 type Provider struct {
-	ClientKey    string
-	Secret       string
-	CallbackURL  string
-	HTTPClient   *http.Client
-	config       *oauth2.Config
-	providerName string
+	ClientKey      string
+	Secret         string
+	CallbackURL    string
+	HTTPClient     *http.Client
+	config         *oauth2.Config
+	providerName   string
+	scopes         []string
+	scopeSeparator string
 }
 
 // New creates a new Deezer provider and sets up important connection details.
@@ -38,10 +44,11 @@ type Provider struct {
 // create one manually.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 	p := &Provider{
-		ClientKey:    clientKey,
-		Secret:       secret,
-		CallbackURL:  callbackURL,
-		providerName: "deezer",
+		ClientKey:      clientKey,
+		Secret:         secret,
+		CallbackURL:    callbackURL,
+		providerName:   "deezer",
+		scopeSeparator: ScopeSeparator,
 	}
 	p.config = newConfig(p, scopes)
 	return p
@@ -57,6 +64,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -64,6 +79,13 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the deezer package.
 func (p *Provider) Debug(debug bool) {}
 
+// SetScopeSeparator overrides the separator used to join the requested scopes,
+// which defaults to ScopeSeparator for Deezer.
+func (p *Provider) SetScopeSeparator(sep string) {
+	p.scopeSeparator = sep
+	p.config.Scopes = goth.JoinScopes(sep, p.scopes...)
+}
+
 // BeginAuth asks Deezer for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 	return &Session{
@@ -166,6 +188,8 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 		}
 	}
 
+	provider.scopes = c.Scopes
+	c.Scopes = goth.JoinScopes(provider.scopeSeparator, c.Scopes...)
 	return c
 }
 
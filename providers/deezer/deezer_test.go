@@ -35,6 +35,16 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "https://connect.deezer.com/oauth/auth.php")
 }
 
+func Test_BeginAuthWithScopes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := deezer.New(os.Getenv("DEEZER_KEY"), os.Getenv("DEEZER_SECRET"), "/foo", "email", "offline_access")
+	session, err := p.BeginAuth("test_state")
+	s := session.(*deezer.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "scope=email%2Coffline_access")
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -44,7 +44,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	}
 
 	s.AccessToken = token.AccessToken
-	s.ExpiresAt = time.Now().Add(time.Second * time.Duration(expires))
+	s.ExpiresAt = goth.Now().Add(time.Second * time.Duration(expires))
 	return token.AccessToken, err
 }
 
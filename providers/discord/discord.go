@@ -4,20 +4,27 @@ package discord
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
 const (
 	authURL      string = "https://discord.com/api/oauth2/authorize"
 	tokenURL     string = "https://discord.com/api/oauth2/token"
+	revokeURL    string = "https://discord.com/api/oauth2/token/revoke"
 	userEndpoint string = "https://discord.com/api/users/@me"
+	authEndpoint string = "https://discord.com/api/oauth2/@me"
 )
 
 const (
@@ -39,8 +46,38 @@ const (
 	ScopeWebhook string = "webhook.incoming"
 	// ScopeReadGuilds	allows /users/@me/guilds/{guild.id}/member to return a user's member information in a guild
 	ScopeReadGuilds string = "guilds.members.read"
+	// ScopeOpenID adds an id_token to the token response
+	ScopeOpenID string = "openid"
+	// ScopeApplicationsCommands allows your app to use commands in a guild
+	ScopeApplicationsCommands string = "applications.commands"
+	// ScopeRoleConnectionsWrite allows your app to update a user's connection and metadata for the app
+	ScopeRoleConnectionsWrite string = "role_connections.write"
+	// ScopeActivitiesRead allows your app to fetch data from a user's "Now Playing/Recently Played" list
+	ScopeActivitiesRead string = "activities.read"
+	// ScopeDMChannelsRead allows your app to see information about the user's DMs and group DMs
+	ScopeDMChannelsRead string = "dm_channels.read"
 )
 
+// RawDataGuilds is the key of User.RawData holding the guilds of the user, as
+// a []Guild, when SetFetchGuilds is enabled.
+const RawDataGuilds = "guilds"
+
+// guildsPageSize is the maximum number of guilds Discord returns at once.
+const guildsPageSize = 200
+
+// Guild is a guild the user is a member of.
+// See https://discord.com/developers/docs/resources/user#get-current-user-guilds
+type Guild struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Icon  string `json:"icon"`
+	Owner bool   `json:"owner"`
+	// Permissions are the permissions of the user in the guild, as a
+	// bitwise value serialized as a string.
+	Permissions string   `json:"permissions"`
+	Features    []string `json:"features"`
+}
+
 // New creates a new Discord provider, and sets up important connection details.
 // You should always call `discord.New` to get a new Provider. Never try to create
 // one manually.
@@ -64,6 +101,7 @@ type Provider struct {
 	config       *oauth2.Config
 	providerName string
 	permissions  string
+	maxGuilds    int
 }
 
 // Name gets the name used to retrieve this provider.
@@ -76,11 +114,26 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 // SetPermissions is to update the bot permissions (used for when ScopeBot is set)
 func (p *Provider) SetPermissions(permissions string) {
 	p.permissions = permissions
 }
 
+// SetFetchGuilds makes FetchUser also fetch up to max of the guilds the user
+// is a member of into User.RawData, see RawDataGuilds and IsMemberOfGuild. It
+// requires ScopeGuilds. A max of 0 disables it.
+func (p *Provider) SetFetchGuilds(max int) {
+	p.maxGuilds = max
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -110,7 +163,59 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 
 // FetchUser will go to Discord and access basic info about the user.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
-	s := session.(*Session)
+	return p.fetchUser(context.Background(), session.(*Session))
+}
+
+// FetchUserWithToken fetches the user an access token obtained outside of
+// Goth was issued for, e.g. by a mobile app, from Discord. Tokens issued to
+// another application fail with an error wrapping goth.ErrTokenAudience.
+func (p *Provider) FetchUserWithToken(ctx context.Context, accessToken string) (goth.User, error) {
+	if accessToken != "" {
+		if err := p.verifyToken(ctx, accessToken); err != nil {
+			return goth.User{Provider: p.Name()}, err
+		}
+	}
+	return p.fetchUser(ctx, &Session{AccessToken: accessToken})
+}
+
+// verifyToken checks that accessToken was issued to the application with the
+// current authorization information of the token.
+// See https://discord.com/developers/docs/topics/oauth2#get-current-authorization-information
+func (p *Provider) verifyToken(ctx context.Context, accessToken string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", authEndpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := goth.CheckRateLimit(p.providerName, resp); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to verify the access token", p.providerName, resp.StatusCode)
+	}
+
+	info := struct {
+		Application struct {
+			ID string `json:"id"`
+		} `json:"application"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return err
+	}
+	if info.Application.ID != p.ClientKey {
+		return fmt.Errorf("%s: %w: %q", p.providerName, goth.ErrTokenAudience, info.Application.ID)
+	}
+	return nil
+}
+
+func (p *Provider) fetchUser(ctx context.Context, s *Session) (goth.User, error) {
 
 	user := goth.User{
 		AccessToken:  s.AccessToken,
@@ -124,7 +229,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	req, err := http.NewRequest("GET", userEndpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", userEndpoint, nil)
 	if err != nil {
 		return user, err
 	}
@@ -139,6 +244,9 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 	defer resp.Body.Close()
 
+	if err := goth.CheckRateLimit(p.providerName, resp); err != nil {
+		return user, err
+	}
 	if resp.StatusCode != http.StatusOK {
 		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
 	}
@@ -158,9 +266,90 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, err
 	}
 
+	if p.maxGuilds > 0 {
+		guilds, err := p.fetchGuilds(ctx, s.AccessToken)
+		if err != nil {
+			return user, err
+		}
+		user.RawData[RawDataGuilds] = guilds
+	}
 	return user, err
 }
 
+// fetchGuilds fetches up to p.maxGuilds guilds of the user, a page at a time.
+func (p *Provider) fetchGuilds(ctx context.Context, accessToken string) ([]Guild, error) {
+	guilds := []Guild{}
+	after := ""
+	for len(guilds) < p.maxGuilds {
+		limit := p.maxGuilds - len(guilds)
+		if limit > guildsPageSize {
+			limit = guildsPageSize
+		}
+		query := url.Values{"limit": {strconv.Itoa(limit)}}
+		if after != "" {
+			query.Set("after", after)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", userEndpoint+"/guilds?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		page, err := p.fetchGuildsPage(req)
+		if err != nil {
+			return nil, err
+		}
+		guilds = append(guilds, page...)
+		if len(page) < limit {
+			break
+		}
+		after = page[len(page)-1].ID
+	}
+	return guilds, nil
+}
+
+func (p *Provider) fetchGuildsPage(req *http.Request) ([]Guild, error) {
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := goth.CheckRateLimit(p.providerName, resp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to fetch guilds", p.providerName, resp.StatusCode)
+	}
+
+	var page []Guild
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	return page, err
+}
+
+// IsMemberOfGuild tells whether user, fetched with SetFetchGuilds enabled, is
+// a member of the guild with the given ID. It also works on users whose
+// RawData went through JSON, e.g. when kept in a session.
+func IsMemberOfGuild(user goth.User, guildID string) bool {
+	switch guilds := user.RawData[RawDataGuilds].(type) {
+	case []Guild:
+		for _, g := range guilds {
+			if g.ID == guildID {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, g := range guilds {
+			if m, ok := g.(map[string]interface{}); ok && m["id"] == guildID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func userFromReader(r io.Reader, user *goth.User) error {
 	u := struct {
 		Name          string `json:"username"`
@@ -188,6 +377,8 @@ func userFromReader(r io.Reader, user *goth.User) error {
 			avatarExtension = ".gif"
 		}
 		user.AvatarURL = "https://media.discordapp.net/avatars/" + u.ID + "/" + u.AvatarID + avatarExtension
+		// the CDN resizes avatars to any power of two between 16 and 4096
+		user.AvatarURLs = goth.NewAvatarURLs(user.AvatarURL+"?size=64", user.AvatarURL+"?size=256", user.AvatarURL+"?size=1024", user.AvatarURL)
 	}
 
 	user.Name = u.Name
@@ -227,11 +418,33 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(oauth2.NoContext, token)
-	newToken, err := ts.Token()
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
+}
+
+// RevokeToken revokes the access or refresh token.
+// See https://discord.com/developers/docs/topics/oauth2#authorization-code-grant-token-revocation-example
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL, strings.NewReader(form.Encode()))
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return newToken, err
+	req.SetBasicAuth(p.ClientKey, p.Secret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to revoke the token", p.providerName, response.StatusCode)
+	}
+	return nil
 }
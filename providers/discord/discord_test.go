@@ -1,8 +1,16 @@
 package discord
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/markbates/goth"
 	"github.com/stretchr/testify/assert"
@@ -52,3 +60,112 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AuthURL, "https://discord.com/api/oauth2/authorize")
 	a.Equal(s.AccessToken, "1234567890")
 }
+
+func Test_FetchUserRateLimited(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusTooManyRequests,
+			Header:     http.Header{"Retry-After": {"2"}},
+			Body:       io.NopCloser(strings.NewReader(`{"message":"You are being rate limited.","retry_after":2,"global":false}`)),
+		}, nil
+	})}
+	_, err := p.FetchUser(&Session{AccessToken: "token"})
+	var rl *goth.RateLimitError
+	a.True(errors.As(err, &rl))
+	a.Equal(2*time.Second, rl.RetryAfter)
+}
+
+func Test_FetchUserWithToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	applicationID := "key"
+	p := New("key", "secret", "/foo", ScopeIdentify)
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("Bearer mobile", req.Header.Get("Authorization"))
+		body := `{"id":"80351110224678912","username":"Nelly","avatar":""}`
+		if req.URL.String() == authEndpoint {
+			body = fmt.Sprintf(`{"application":{"id":%q},"scopes":["identify"]}`, applicationID)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	user, err := p.FetchUserWithToken(context.Background(), "mobile")
+	a.NoError(err)
+	a.Equal("80351110224678912", user.UserID)
+
+	applicationID = "another-application"
+	_, err = p.FetchUserWithToken(context.Background(), "mobile")
+	a.ErrorIs(err, goth.ErrTokenAudience)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func Test_AvatarURLs(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	user := goth.User{}
+	err := userFromReader(strings.NewReader(`{"id":"1","username":"homer","avatar":"a_abc"}`), &user)
+	a.NoError(err)
+	a.Equal("https://media.discordapp.net/avatars/1/a_abc.gif", user.AvatarURL)
+	a.Equal("https://media.discordapp.net/avatars/1/a_abc.gif?size=64", user.Avatar(goth.AvatarSmall))
+	a.Equal("https://media.discordapp.net/avatars/1/a_abc.gif", user.Avatar(goth.AvatarOriginal))
+
+	user = goth.User{}
+	a.NoError(userFromReader(strings.NewReader(`{"id":"1","username":"homer"}`), &user))
+	a.Nil(user.AvatarURLs)
+}
+
+func Test_FetchUserGuilds(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// the user is a member of 250 guilds, whose IDs are 1 to 250
+	var queries []string
+	p := provider()
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		body := `{"id":"80351110224678912","username":"Nelly"}`
+		if strings.HasSuffix(req.URL.Path, "/guilds") {
+			queries = append(queries, req.URL.RawQuery)
+			after, _ := strconv.Atoi(req.URL.Query().Get("after"))
+			limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+			var page []string
+			for id := after + 1; id <= 250 && len(page) < limit; id++ {
+				page = append(page, fmt.Sprintf(`{"id":"%d","name":"Guild %d","owner":%t}`, id, id, id == 1))
+			}
+			body = "[" + strings.Join(page, ",") + "]"
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	p.SetFetchGuilds(300)
+	user, err := p.FetchUser(&Session{AccessToken: "token"})
+	a.NoError(err)
+	guilds := user.RawData[RawDataGuilds].([]Guild)
+	a.Len(guilds, 250)
+	a.Equal("Guild 1", guilds[0].Name)
+	a.True(guilds[0].Owner)
+	a.Equal([]string{"limit=200", "after=200&limit=100"}, queries)
+	a.True(IsMemberOfGuild(user, "250"))
+	a.False(IsMemberOfGuild(user, "251"))
+
+	queries = nil
+	p.SetFetchGuilds(10)
+	user, err = p.FetchUser(&Session{AccessToken: "token"})
+	a.NoError(err)
+	a.Len(user.RawData[RawDataGuilds], 10)
+	a.Equal([]string{"limit=10"}, queries)
+
+	// as when the user went through JSON
+	user.RawData[RawDataGuilds] = []interface{}{map[string]interface{}{"id": "1"}}
+	a.True(IsMemberOfGuild(user, "1"))
+}
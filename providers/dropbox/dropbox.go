@@ -3,6 +3,7 @@ package dropbox
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,15 +11,17 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
 const (
 	authURL    = "https://www.dropbox.com/oauth2/authorize"
-	tokenURL   = "https://api.dropbox.com/oauth2/token"
-	accountURL = "https://api.dropbox.com/2/users/get_current_account"
+	tokenURL   = "https://api.dropboxapi.com/oauth2/token"
+	accountURL = "https://api.dropboxapi.com/2/users/get_current_account"
 )
 
 // Provider is the implementation of `goth.Provider` for accessing Dropbox.
@@ -34,8 +37,10 @@ type Provider struct {
 
 // Session stores data during the auth process with Dropbox.
 type Session struct {
-	AuthURL string
-	Token   string
+	AuthURL      string
+	Token        string
+	RefreshToken string     `json:",omitempty"`
+	ExpiresAt    *time.Time `json:",omitempty"`
 }
 
 // New creates a new Dropbox provider and sets up important connection details.
@@ -63,6 +68,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -70,10 +83,11 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the dropbox package.
 func (p *Provider) Debug(debug bool) {}
 
-// BeginAuth asks Dropbox for an authentication end-point.
+// BeginAuth asks Dropbox for an authentication end-point. Dropbox only issues
+// short-lived access tokens, so a refresh token is requested along with them.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
+		AuthURL: p.config.AuthCodeURL(state, oauth2.SetAuthURLParam("token_access_type", "offline")),
 	}, nil
 }
 
@@ -81,8 +95,12 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	s := session.(*Session)
 	user := goth.User{
-		AccessToken: s.Token,
-		Provider:    p.Name(),
+		AccessToken:  s.Token,
+		RefreshToken: s.RefreshToken,
+		Provider:     p.Name(),
+	}
+	if s.ExpiresAt != nil {
+		user.ExpiresAt = *s.ExpiresAt
 	}
 
 	if user.AccessToken == "" {
@@ -146,10 +164,25 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 		return "", errors.New("Invalid token received from provider")
 	}
 
-	s.Token = token.AccessToken
+	s.UpdateToken(token)
 	return token.AccessToken, nil
 }
 
+// UpdateToken replaces the token held by the session, e.g. with one obtained
+// from RefreshToken. Dropbox doesn't rotate refresh tokens, so the current one
+// is kept if token doesn't carry any.
+func (s *Session) UpdateToken(token *oauth2.Token) {
+	s.Token = token.AccessToken
+	if token.RefreshToken != "" {
+		s.RefreshToken = token.RefreshToken
+	}
+	s.ExpiresAt = nil
+	if !token.Expiry.IsZero() {
+		expiry := token.Expiry
+		s.ExpiresAt = &expiry
+	}
+}
+
 // Marshal the session into a string
 func (s *Session) Marshal() string {
 	b, _ := json.Marshal(s)
@@ -201,12 +234,17 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	return nil
 }
 
-// RefreshToken refresh token is not provided by dropbox
+// RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	return nil, errors.New("Refresh token is not provided by dropbox")
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
 
-// RefreshTokenAvailable refresh token is not provided by dropbox
+// RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
-	return false
+	return true
 }
@@ -1,9 +1,12 @@
 package dropbox
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -45,6 +48,66 @@ func Test_BeginAuth(t *testing.T) {
 	s := session.(*Session)
 	a.NoError(err)
 	a.Contains(s.AuthURL, "www.dropbox.com/oauth2/authorize")
+	a.Contains(s.AuthURL, "token_access_type=offline")
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("https://api.dropboxapi.com/oauth2/token", req.URL.String())
+		a.NoError(req.ParseForm())
+		a.Equal("authorization_code", req.PostForm.Get("grant_type"))
+		a.Equal("CODE", req.PostForm.Get("code"))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"access_token":"sl.ACCESS","refresh_token":"REFRESH","token_type":"bearer","expires_in":14400,"account_id":"dbid:1"}`)),
+		}, nil
+	})}
+
+	s := &Session{}
+	token, err := s.Authorize(p, url.Values{"code": {"CODE"}})
+	a.NoError(err)
+	a.Equal("sl.ACCESS", token)
+	a.Equal("sl.ACCESS", s.Token)
+	a.Equal("REFRESH", s.RefreshToken)
+	a.NotNil(s.ExpiresAt)
+
+	session, err := p.UnmarshalSession(s.Marshal())
+	a.NoError(err)
+	a.Equal("REFRESH", session.(*Session).RefreshToken)
+	a.True(session.(*Session).ExpiresAt.Equal(*s.ExpiresAt))
+}
+
+func Test_RefreshToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	a.True(p.RefreshTokenAvailable())
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.NoError(req.ParseForm())
+		a.Equal("refresh_token", req.PostForm.Get("grant_type"))
+		a.Equal("REFRESH", req.PostForm.Get("refresh_token"))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"access_token":"sl.NEW","token_type":"bearer","expires_in":14400}`)),
+		}, nil
+	})}
+
+	token, err := p.RefreshToken("REFRESH")
+	a.NoError(err)
+	a.Equal("sl.NEW", token.AccessToken)
+	a.False(token.Expiry.IsZero())
+
+	s := &Session{Token: "sl.OLD", RefreshToken: "REFRESH"}
+	s.UpdateToken(token)
+	a.Equal("sl.NEW", s.Token)
+	a.Equal("REFRESH", s.RefreshToken)
 }
 
 func Test_FetchUser(t *testing.T) {
@@ -117,6 +180,12 @@ func Test_GetAuthURL(t *testing.T) {
 	a.Equal(url, "/foo")
 }
 
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 var testAccountResponse = `
 {
     "account_id": "dbid:AAH4f99T0taONIb-OurWxbNQ6ywGRopQngc",
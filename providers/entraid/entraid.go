@@ -0,0 +1,457 @@
+// Package entraid implements the OAuth2 and OpenID Connect protocols for authenticating
+// users through Microsoft Entra ID (formerly Azure Active Directory) using the v2.0 endpoints.
+package entraid
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/jwtutil"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
+)
+
+// See https://learn.microsoft.com/en-us/entra/identity-platform/v2-protocols#endpoints
+const (
+	authURLTemplate   string = "https://login.microsoftonline.com/%s/oauth2/v2.0/authorize"
+	tokenURLTemplate  string = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+	logoutURLTemplate string = "https://login.microsoftonline.com/%s/oauth2/v2.0/logout"
+	issuerTemplate    string = "https://login.microsoftonline.com/%s/v2.0"
+)
+
+// GraphURL is the Microsoft Graph endpoint used to fetch the user profile and
+// to expand group and role memberships.
+var GraphURL = "https://graph.microsoft.com/v1.0/"
+
+// These are the well known tenants. Any tenant ID or verified domain name of
+// a tenant can be used as well.
+const (
+	// CommonTenant allows both personal Microsoft accounts and work/school accounts to sign in.
+	CommonTenant = "common"
+	// OrganizationsTenant allows only work/school accounts to sign in.
+	OrganizationsTenant = "organizations"
+	// ConsumersTenant allows only personal Microsoft accounts to sign in.
+	ConsumersTenant = "consumers"
+)
+
+// Scopes requested by default.
+const (
+	ScopeOpenID        = "openid"
+	ScopeProfile       = "profile"
+	ScopeEmail         = "email"
+	ScopeOfflineAccess = "offline_access"
+	ScopeUserRead      = "User.Read"
+)
+
+// Options are the optional settings of a Provider.
+type Options struct {
+	// Tenant is a tenant ID, a verified domain name or one of the well known
+	// tenants. It defaults to CommonTenant.
+	Tenant string
+	// Scopes defaults to openid, profile, email, offline_access and User.Read.
+	Scopes []string
+
+	// Certificate and PrivateKey enable certificate based client credentials.
+	// When set, a signed client assertion is sent to the token endpoint
+	// instead of the client secret.
+	Certificate *x509.Certificate
+	PrivateKey  *rsa.PrivateKey
+
+	// ExpandGroups resolves the user's groups through the Graph API when the
+	// id_token contains a groups overage claim instead of the groups.
+	ExpandGroups bool
+	// ExpandRoles fetches the user's app role assignments through the Graph
+	// API when the id_token does not contain a roles claim.
+	ExpandRoles bool
+
+	// AllowedTenants restricts sign in to users of the given tenant IDs,
+	// checked against the tid claim of the id_token. This matters for multi
+	// tenant apps using CommonTenant or OrganizationsTenant, which any
+	// tenant's users can sign in to.
+	AllowedTenants []string
+}
+
+// Provider is the implementation of `goth.Provider` for accessing Microsoft Entra ID.
+type Provider struct {
+	ClientKey   string
+	Secret      string
+	CallbackURL string
+	HTTPClient  *http.Client
+	goth.AdditionalParams
+	Tenant         string
+	config         *oauth2.Config
+	providerName   string
+	certificate    *x509.Certificate
+	privateKey     *rsa.PrivateKey
+	expandGroups   bool
+	expandRoles    bool
+	allowedTenants []string
+}
+
+// New creates a new Entra ID provider, and sets up important connection details.
+// You should always call `entraid.New` to get a new Provider. Never try to create
+// one manually.
+func New(clientKey, secret, callbackURL string, opts Options) *Provider {
+	tenant := opts.Tenant
+	if tenant == "" {
+		tenant = CommonTenant
+	}
+
+	p := &Provider{
+		ClientKey:      clientKey,
+		Secret:         secret,
+		CallbackURL:    callbackURL,
+		Tenant:         tenant,
+		providerName:   "entraid",
+		certificate:    opts.Certificate,
+		privateKey:     opts.PrivateKey,
+		expandGroups:   opts.ExpandGroups,
+		expandRoles:    opts.ExpandRoles,
+		allowedTenants: opts.AllowedTenants,
+	}
+	p.config = newConfig(p, opts.Scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
+// Client is HTTP client to be used in all fetch operations. With certificate
+// credentials, it adds a client assertion to the requests made to the token
+// endpoint.
+// See https://learn.microsoft.com/en-us/entra/identity-platform/certificate-credentials
+func (p *Provider) Client() *http.Client {
+	client := goth.HTTPClientWithFallBack(p.HTTPClient)
+	if p.certificate != nil {
+		auth := goth.ClientAuth{Method: goth.AuthMethodPrivateKeyJWT, Certificate: p.certificate}
+		if p.privateKey != nil {
+			auth.Key = p.privateKey
+		}
+		client = auth.Client(client, p.ClientKey, p.config.Endpoint.TokenURL)
+	}
+	return p.TokenClient(client)
+}
+
+// Debug is a no-op for the entraid package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks Entra ID for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.BeginAuthWithParams(state, nil)
+}
+
+// BeginAuthWithParams is like BeginAuth, adding params (e.g. domain_hint or
+// login_hint) to the authentication end-point.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state, append(p.AuthCodeOptions(), goth.AuthURLParamOptions(params)...)...),
+	}, nil
+}
+
+// FetchUser will go to the Graph API and access basic information about the user.
+// The tenant of the user is available in RawData under "tid", the groups and
+// roles in User.Groups and User.Roles as well as in RawData.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+		IDToken:      sess.IDToken,
+		Provider:     p.Name(),
+	}
+
+	if user.AccessToken == "" {
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	bits, err := p.graphRequest(http.MethodGet, "me", nil, sess.AccessToken)
+	if err != nil {
+		return user, err
+	}
+
+	if err := userFromReader(bytes.NewReader(bits), &user); err != nil {
+		return user, err
+	}
+
+	if sess.IDToken == "" {
+		if len(p.allowedTenants) > 0 {
+			return user, fmt.Errorf("%w: no id_token to check the tenant of the user", goth.ErrUserNotAllowed)
+		}
+		return user, nil
+	}
+
+	claims, err := p.idTokenClaims(sess.IDToken)
+	if err != nil {
+		return user, err
+	}
+
+	tid, _ := claims["tid"].(string)
+	if tid != "" {
+		user.RawData["tid"] = tid
+	}
+	if !p.tenantAllowed(tid) {
+		return user, fmt.Errorf("%w: tenant %q is not allowed", goth.ErrUserNotAllowed, tid)
+	}
+
+	groups, err := p.groups(claims, sess.AccessToken)
+	if err != nil {
+		return user, err
+	}
+	if groups != nil {
+		user.RawData["groups"] = groups
+		user.Groups = groups
+	}
+
+	roles, err := p.roles(claims, sess.AccessToken)
+	if err != nil {
+		return user, err
+	}
+	if roles != nil {
+		user.RawData["roles"] = roles
+		user.Roles = roles
+	}
+
+	return user, nil
+}
+
+// idTokenClaims returns the claims of idToken once checked to be issued for
+// this application by the tenant of the user, or by the tenant of the
+// provider if it was created with a tenant ID. The token was received directly
+// from the token endpoint over TLS, so its signature is not checked.
+func (p *Provider) idTokenClaims(idToken string) (map[string]interface{}, error) {
+	claims, err := jwtutil.Decode(idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := p.Tenant
+	switch {
+	case tenant == CommonTenant, tenant == OrganizationsTenant, tenant == ConsumersTenant, strings.Contains(tenant, "."):
+		// the issuer is the tenant of the user, whose ID isn't known here
+		tenant = jwtutil.String(claims, "tid")
+	}
+	expected := jwtutil.Expected{
+		Issuer:        fmt.Sprintf(issuerTemplate, tenant),
+		Audience:      p.ClientKey,
+		RequireExpiry: true,
+		Leeway:        time.Minute,
+	}
+	if _, err := jwtutil.Validate(claims, expected); err != nil {
+		return nil, fmt.Errorf("entraid: invalid id_token: %w", err)
+	}
+	return claims, nil
+}
+
+func (p *Provider) tenantAllowed(tid string) bool {
+	if len(p.allowedTenants) == 0 {
+		return true
+	}
+	for _, t := range p.allowedTenants {
+		if strings.EqualFold(t, tid) {
+			return true
+		}
+	}
+	return false
+}
+
+// groups returns the groups from the id_token, or from the Graph API if the
+// id_token carries the groups overage claim instead.
+// See https://learn.microsoft.com/en-us/entra/identity-platform/id-token-claims-reference#groups-overage-claim
+func (p *Provider) groups(claims map[string]interface{}, accessToken string) ([]string, error) {
+	if groups, ok := claims["groups"]; ok {
+		return toStrings(groups), nil
+	}
+
+	claimNames, _ := claims["_claim_names"].(map[string]interface{})
+	if _, overage := claimNames["groups"]; !overage || !p.expandGroups {
+		return nil, nil
+	}
+
+	bits, err := p.graphRequest(http.MethodPost, "me/getMemberObjects", strings.NewReader(`{"securityEnabledOnly":false}`), accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	result := struct {
+		Value []string `json:"value"`
+	}{}
+	if err := json.Unmarshal(bits, &result); err != nil {
+		return nil, err
+	}
+	return result.Value, nil
+}
+
+// roles returns the app roles from the id_token, or the ids of the user's app
+// role assignments from the Graph API if requested.
+func (p *Provider) roles(claims map[string]interface{}, accessToken string) ([]string, error) {
+	if roles, ok := claims["roles"]; ok {
+		return toStrings(roles), nil
+	}
+
+	if !p.expandRoles {
+		return nil, nil
+	}
+
+	bits, err := p.graphRequest(http.MethodGet, "me/appRoleAssignments?$select=appRoleId", nil, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	result := struct {
+		Value []struct {
+			AppRoleID string `json:"appRoleId"`
+		} `json:"value"`
+	}{}
+	if err := json.Unmarshal(bits, &result); err != nil {
+		return nil, err
+	}
+
+	roles := make([]string, 0, len(result.Value))
+	for _, v := range result.Value {
+		roles = append(roles, v.AppRoleID)
+	}
+	return roles, nil
+}
+
+func (p *Provider) graphRequest(method, path string, body io.Reader, accessToken string) ([]byte, error) {
+	req, err := http.NewRequest(method, GraphURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to fetch %s", p.providerName, response.StatusCode, path)
+	}
+
+	return ioutil.ReadAll(response.Body)
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
+}
+
+// LogoutURL returns the URL to redirect the user to in order to sign them out
+// of Entra ID.
+// See https://learn.microsoft.com/en-us/entra/identity-platform/v2-protocols-oidc#send-a-sign-out-request
+func (p *Provider) LogoutURL(idTokenHint, postLogoutRedirect string) (string, error) {
+	return goth.EndSessionURL(fmt.Sprintf(logoutURLTemplate, p.Tenant), p.ClientKey, idTokenHint, postLogoutRedirect)
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  fmt.Sprintf(authURLTemplate, provider.Tenant),
+			TokenURL: fmt.Sprintf(tokenURLTemplate, provider.Tenant),
+		},
+		Scopes: []string{},
+	}
+
+	if provider.certificate != nil {
+		// the client assertion added by Client replaces the secret, which
+		// must not be sent
+		c.ClientSecret = ""
+		c.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeOfflineAccess, ScopeUserRead}
+	}
+
+	return c
+}
+
+func userFromReader(r *bytes.Reader, user *goth.User) error {
+	u := struct {
+		ID                string `json:"id"`
+		DisplayName       string `json:"displayName"`
+		FirstName         string `json:"givenName"`
+		LastName          string `json:"surname"`
+		Email             string `json:"mail"`
+		OfficeLocation    string `json:"officeLocation"`
+		UserPrincipalName string `json:"userPrincipalName"`
+	}{}
+
+	if err := json.NewDecoder(r).Decode(&u); err != nil {
+		return err
+	}
+
+	user.UserID = u.ID
+	user.Name = u.DisplayName
+	user.FirstName = u.FirstName
+	user.LastName = u.LastName
+	user.NickName = u.UserPrincipalName
+	user.Email = u.Email
+	if user.Email == "" {
+		user.Email = u.UserPrincipalName
+	}
+	user.Location = u.OfficeLocation
+	user.AvatarURL = GraphURL + fmt.Sprintf("users/%s/photo/$value", u.ID)
+
+	r.Seek(0, 0)
+	return json.NewDecoder(r).Decode(&user.RawData)
+}
+
+func toStrings(v interface{}) []string {
+	values, _ := v.([]interface{})
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
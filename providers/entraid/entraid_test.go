@@ -0,0 +1,256 @@
+package entraid_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/entraid"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	applicationID = "6731de76-14a6-49ae-97bc-6eba6914391e"
+	secret        = "foo"
+	redirectUri   = "https://localhost:3000"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	provider := entraidProvider()
+
+	a.Equal(provider.Name(), "entraid")
+	a.Equal(provider.ClientKey, applicationID)
+	a.Equal(provider.Secret, secret)
+	a.Equal(provider.CallbackURL, redirectUri)
+	a.Equal(provider.Tenant, entraid.CommonTenant)
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := entraidProvider()
+	a.Implements((*goth.Provider)(nil), p)
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	provider := entraidProvider()
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*entraid.Session)
+	a.Contains(s.AuthURL, "login.microsoftonline.com/common/oauth2/v2.0/authorize")
+	a.Contains(s.AuthURL, "redirect_uri=https%3A%2F%2Flocalhost%3A3000")
+	a.Contains(s.AuthURL, "scope=openid+profile+email+offline_access+User.Read")
+}
+
+func Test_BeginAuth_Tenant(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	provider := entraid.New(applicationID, secret, redirectUri, entraid.Options{
+		Tenant: "contoso.onmicrosoft.com",
+		Scopes: []string{entraid.ScopeOpenID, "Mail.Read"},
+	})
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*entraid.Session)
+	a.Contains(s.AuthURL, "login.microsoftonline.com/contoso.onmicrosoft.com/oauth2/v2.0/authorize")
+	a.Contains(s.AuthURL, "scope=openid+Mail.Read")
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := entraidProvider()
+	session, err := provider.UnmarshalSession(`{"au":"http://foo","at":"1234567890","it":"abc"}`)
+	a.NoError(err)
+
+	s := session.(*entraid.Session)
+	a.Equal(s.AuthURL, "http://foo")
+	a.Equal(s.AccessToken, "1234567890")
+	a.Equal(s.IDToken, "abc")
+}
+
+func Test_FetchUser_GroupsOverage(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idToken := signedIDToken(jwt.MapClaims{
+		"roles":        []string{"Admin"},
+		"_claim_names": map[string]string{"groups": "src1"},
+	})
+
+	provider := entraid.New(applicationID, secret, redirectUri, entraid.Options{ExpandGroups: true})
+	provider.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) string {
+		a.Equal("Bearer access", req.Header.Get("Authorization"))
+		switch req.URL.Path {
+		case "/v1.0/me":
+			return `{"id":"1","displayName":"Jane Doe","givenName":"Jane","surname":"Doe","userPrincipalName":"jane@contoso.com"}`
+		case "/v1.0/me/getMemberObjects":
+			a.Equal(http.MethodPost, req.Method)
+			return `{"value":["group-1","group-2"]}`
+		}
+		t.Errorf("unexpected request to %s", req.URL)
+		return ""
+	})}
+
+	user, err := provider.FetchUser(&entraid.Session{AccessToken: "access", IDToken: idToken})
+	a.NoError(err)
+	a.Equal("1", user.UserID)
+	a.Equal("jane@contoso.com", user.Email)
+	a.Equal("tenant-id", user.RawData["tid"])
+	a.Equal([]string{"group-1", "group-2"}, user.RawData["groups"])
+	a.Equal([]string{"Admin"}, user.RawData["roles"])
+	a.Equal([]string{"group-1", "group-2"}, user.Groups)
+	a.Equal([]string{"Admin"}, user.Roles)
+}
+
+func Test_FetchUser_AllowedTenants(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idToken := signedIDToken(jwt.MapClaims{})
+
+	client := &http.Client{Transport: roundTripper(func(req *http.Request) string {
+		return `{"id":"1","displayName":"Jane Doe","userPrincipalName":"jane@contoso.com"}`
+	})}
+
+	provider := entraid.New(applicationID, secret, redirectUri, entraid.Options{AllowedTenants: []string{"TENANT-ID"}})
+	provider.HTTPClient = client
+	user, err := provider.FetchUser(&entraid.Session{AccessToken: "access", IDToken: idToken})
+	a.NoError(err)
+	a.Equal("1", user.UserID)
+
+	_, err = provider.FetchUser(&entraid.Session{AccessToken: "access"})
+	a.ErrorIs(err, goth.ErrUserNotAllowed)
+
+	provider = entraid.New(applicationID, secret, redirectUri, entraid.Options{AllowedTenants: []string{"other-tenant"}})
+	provider.HTTPClient = client
+	_, err = provider.FetchUser(&entraid.Session{AccessToken: "access", IDToken: idToken})
+	a.ErrorIs(err, goth.ErrUserNotAllowed)
+}
+
+func Test_FetchUser_ValidatesIDToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	client := &http.Client{Transport: roundTripper(func(req *http.Request) string {
+		return `{"id":"1","displayName":"Jane Doe","userPrincipalName":"jane@contoso.com"}`
+	})}
+	fetch := func(tenant string, claims jwt.MapClaims) error {
+		provider := entraid.New(applicationID, secret, redirectUri, entraid.Options{Tenant: tenant})
+		provider.HTTPClient = client
+		_, err := provider.FetchUser(&entraid.Session{AccessToken: "access", IDToken: signedIDToken(claims)})
+		return err
+	}
+
+	a.NoError(fetch("", jwt.MapClaims{}))
+	a.NoError(fetch("tenant-id", jwt.MapClaims{}))
+	a.NoError(fetch("contoso.com", jwt.MapClaims{}))
+	a.Error(fetch("", jwt.MapClaims{"aud": "other-application"}))
+	a.Error(fetch("", jwt.MapClaims{"iss": "https://login.microsoftonline.com/other-tenant/v2.0"}))
+	a.Error(fetch("other-tenant", jwt.MapClaims{}))
+	a.Error(fetch("", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}))
+	a.Error(fetch("", jwt.MapClaims{"exp": nil}))
+}
+
+func Test_Authorize_Certificate(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "goth"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	a.NoError(err)
+	cert, err := x509.ParseCertificate(der)
+	a.NoError(err)
+
+	provider := entraid.New(applicationID, "", redirectUri, entraid.Options{
+		Tenant:      "tenant-id",
+		Certificate: cert,
+		PrivateKey:  key,
+	})
+	provider.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) string {
+		a.Equal("https://login.microsoftonline.com/tenant-id/oauth2/v2.0/token", req.URL.String())
+		body, _ := ioutil.ReadAll(req.Body)
+		form, _ := url.ParseQuery(string(body))
+		a.Empty(form.Get("client_secret"))
+		a.Equal("urn:ietf:params:oauth:client-assertion-type:jwt-bearer", form.Get("client_assertion_type"))
+
+		token, err := jwt.Parse(form.Get("client_assertion"), func(*jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		}, jwt.WithAudience(req.URL.String()), jwt.WithIssuer(applicationID))
+		a.NoError(err)
+		a.NotEmpty(token.Header["x5t"])
+		_, err = base64.RawURLEncoding.DecodeString(token.Header["x5t"].(string))
+		a.NoError(err)
+
+		return `{"access_token":"access","token_type":"Bearer","expires_in":3600,"refresh_token":"refresh","id_token":"id"}`
+	})}
+
+	s := &entraid.Session{}
+	accessToken, err := s.Authorize(provider, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Equal("access", accessToken)
+	a.Equal("refresh", s.RefreshToken)
+	a.Equal("id", s.IDToken)
+
+	token, err := provider.RefreshToken("refresh")
+	a.NoError(err)
+	a.Equal("access", token.AccessToken)
+}
+
+// signedIDToken returns an id_token of tenant-id for the application, with
+// claims added or, when nil, removed.
+func signedIDToken(claims jwt.MapClaims) string {
+	token := jwt.MapClaims{
+		"iss": "https://login.microsoftonline.com/tenant-id/v2.0",
+		"aud": applicationID,
+		"tid": "tenant-id",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(token, k)
+		} else {
+			token[k] = v
+		}
+	}
+	s, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, token).SignedString([]byte("test"))
+	return s
+}
+
+func entraidProvider() *entraid.Provider {
+	return entraid.New(applicationID, secret, redirectUri, entraid.Options{})
+}
+
+type roundTripper func(req *http.Request) string
+
+func (fn roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(fn(req))),
+		Request:    req,
+	}, nil
+}
@@ -4,6 +4,7 @@ package facebook
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -17,6 +18,8 @@ import (
 	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/jwkutil"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -24,6 +27,7 @@ const (
 	authURL         string = "https://www.facebook.com/dialog/oauth"
 	tokenURL        string = "https://graph.facebook.com/oauth/access_token"
 	endpointProfile string = "https://graph.facebook.com/me?fields="
+	endpointDebug   string = "https://graph.facebook.com/debug_token"
 )
 
 // New creates a new Facebook provider, and sets up important connection details.
@@ -35,6 +39,7 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 		Secret:       secret,
 		CallbackURL:  callbackURL,
 		providerName: "facebook",
+		keys:         &jwkutil.KeySet{MinRefreshInterval: limitedLoginKeysMinRefreshInterval},
 	}
 	p.config = newConfig(p, scopes)
 	p.Fields = "email,first_name,last_name,link,about,id,name,picture,location"
@@ -50,8 +55,14 @@ type Provider struct {
 	Fields       string
 	config       *oauth2.Config
 	providerName string
+	keys         *jwkutil.KeySet
 }
 
+var (
+	_ goth.Provider             = &Provider{}
+	_ goth.IDTokenAuthenticator = &Provider{}
+)
+
 // Name is the name used to retrieve this provider later.
 func (p *Provider) Name() string {
 	return p.providerName
@@ -62,6 +73,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 // SetCustomFields sets the fields used to return information
 // for a user.
 //
@@ -89,8 +108,69 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 }
 
 // FetchUser will go to Facebook and access basic information about the user.
+// Sessions holding the token of a Limited Login instead of an access token
+// are authenticated with FetchUserFromIDToken.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	sess := session.(*Session)
+	if sess.AccessToken == "" && sess.IDToken != "" {
+		return p.FetchUserFromIDToken(context.Background(), sess.IDToken)
+	}
+	return p.fetchUser(context.Background(), sess)
+}
+
+// FetchUserWithToken fetches the user an access token obtained outside of
+// Goth was issued for, e.g. by a mobile app, from Facebook. Tokens issued to
+// another app fail with an error wrapping goth.ErrTokenAudience.
+func (p *Provider) FetchUserWithToken(ctx context.Context, accessToken string) (goth.User, error) {
+	if accessToken != "" {
+		if err := p.verifyToken(ctx, accessToken); err != nil {
+			return goth.User{Provider: p.Name()}, err
+		}
+	}
+	return p.fetchUser(ctx, &Session{AccessToken: accessToken})
+}
+
+// verifyToken checks with the debug_token endpoint, authenticated with the
+// app access token, that accessToken is valid and was issued to the app.
+// See https://developers.facebook.com/docs/graph-api/reference/debug_token
+func (p *Provider) verifyToken(ctx context.Context, accessToken string) error {
+	query := url.Values{
+		"input_token":  {accessToken},
+		"access_token": {p.ClientKey + "|" + p.Secret},
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", endpointDebug+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to verify the access token", p.providerName, response.StatusCode)
+	}
+
+	info := struct {
+		Data struct {
+			AppID   string `json:"app_id"`
+			IsValid bool   `json:"is_valid"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return err
+	}
+	if !info.Data.IsValid {
+		return fmt.Errorf("%s: the access token is not valid", p.providerName)
+	}
+	if info.Data.AppID != p.ClientKey {
+		return fmt.Errorf("%s: %w: %q", p.providerName, goth.ErrTokenAudience, info.Data.AppID)
+	}
+	return nil
+}
+
+func (p *Provider) fetchUser(ctx context.Context, sess *Session) (goth.User, error) {
 	user := goth.User{
 		AccessToken: sess.AccessToken,
 		Provider:    p.Name(),
@@ -117,7 +197,11 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		"&appsecret_proof=",
 		appsecretProof,
 	)
-	response, err := p.Client().Get(reqUrl)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqUrl, nil)
+	if err != nil {
+		return user, err
+	}
+	response, err := p.Client().Do(req)
 	if err != nil {
 		return user, err
 	}
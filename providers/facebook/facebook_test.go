@@ -1,11 +1,21 @@
 package facebook_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/facebook"
 	"github.com/stretchr/testify/assert"
@@ -67,6 +77,130 @@ func Test_SetCustomFields(t *testing.T) {
 	a.Equal(provider.Fields, strings.Join(cf, ","))
 }
 
+func Test_FetchUserFromIDToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	pub, err := jwk.New(&key.PublicKey)
+	a.NoError(err)
+	a.NoError(pub.Set(jwk.KeyIDKey, "kid1"))
+	set := jwk.NewSet()
+	set.Add(pub)
+	keys, err := json.Marshal(set)
+	a.NoError(err)
+
+	p := facebook.New("app-id", "secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		if req.URL.String() != "https://limited.facebook.com/.well-known/oauth/openid/jwks/" {
+			return nil, fmt.Errorf("unexpected request to %s", req.URL)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(keys)),
+		}, nil
+	})}
+
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "kid1"
+		s, err := token.SignedString(key)
+		a.NoError(err)
+		return s
+	}
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":           "https://www.facebook.com",
+			"aud":           "app-id",
+			"sub":           "1234",
+			"nonce":         "nonce",
+			"email":         "homer@example.com",
+			"name":          "Homer Simpson",
+			"given_name":    "Homer",
+			"family_name":   "Simpson",
+			"picture":       "https://platform-lookaside.fbsbx.com/homer.jpg",
+			"user_location": map[string]interface{}{"id": "1", "name": "Springfield"},
+			"exp":           time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	idToken := sign(claims())
+	user, err := p.FetchUserFromIDToken(context.Background(), idToken)
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("Homer", user.FirstName)
+	a.Equal("Simpson", user.LastName)
+	a.Equal("Springfield", user.Location)
+	a.Equal("nonce", user.RawData["nonce"])
+	a.Equal(idToken, user.IDToken)
+	a.Empty(user.AccessToken)
+
+	// sessions holding a Limited Login token go through the same validation
+	user, err = p.FetchUser(&facebook.Session{IDToken: idToken})
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+
+	wrongIssuer := claims()
+	wrongIssuer["iss"] = "https://evil.example.com"
+	_, err = p.FetchUserFromIDToken(context.Background(), sign(wrongIssuer))
+	a.Error(err)
+
+	wrongAudience := claims()
+	wrongAudience["aud"] = "someone-else"
+	_, err = p.FetchUserFromIDToken(context.Background(), sign(wrongAudience))
+	a.Error(err)
+
+	expired := claims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, err = p.FetchUserFromIDToken(context.Background(), sign(expired))
+	a.Error(err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, claims())
+	forged.Header["kid"] = "kid1"
+	forgedToken, err := forged.SignedString(otherKey)
+	a.NoError(err)
+	_, err = p.FetchUser(&facebook.Session{IDToken: forgedToken})
+	a.Error(err)
+}
+
+func Test_FetchUserWithToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	appID := "app-id"
+	p := facebook.New("app-id", "secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		body := `{"id":"1234","email":"homer@example.com","name":"Homer Simpson"}`
+		if req.URL.Path == "/debug_token" {
+			a.Equal("mobile", req.URL.Query().Get("input_token"))
+			a.Equal("app-id|secret", req.URL.Query().Get("access_token"))
+			body = fmt.Sprintf(`{"data":{"app_id":%q,"is_valid":true,"user_id":"1234"}}`, appID)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	user, err := p.FetchUserWithToken(context.Background(), "mobile")
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+
+	appID = "another-app-id"
+	_, err = p.FetchUserWithToken(context.Background(), "mobile")
+	a.ErrorIs(err, goth.ErrTokenAudience)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 func facebookProvider() *facebook.Provider {
 	return facebook.New(os.Getenv("FACEBOOK_KEY"), os.Getenv("FACEBOOK_SECRET"), "/foo", "email")
 }
@@ -14,6 +14,9 @@ type Session struct {
 	AuthURL     string
 	AccessToken string
 	ExpiresAt   time.Time
+	// IDToken is the authentication token of Facebook Limited Login, set by
+	// applications authenticating users of their iOS app with it.
+	IDToken string `json:",omitempty"`
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the Facebook provider.
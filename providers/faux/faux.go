@@ -3,9 +3,11 @@
 package faux
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/markbates/goth"
@@ -29,7 +31,10 @@ type Session struct {
 
 // Name is used only for testing.
 func (p *Provider) Name() string {
-	return "faux"
+	if p.providerName == "" {
+		return "faux"
+	}
+	return p.providerName
 }
 
 // SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
@@ -39,15 +44,20 @@ func (p *Provider) SetName(name string) {
 
 // BeginAuth is used only for testing.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.BeginAuthWithParams(state, nil)
+}
+
+// BeginAuthWithParams is used only for testing.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
 	c := &oauth2.Config{
 		Endpoint: oauth2.Endpoint{
 			AuthURL: "http://example.com/auth",
 		},
 	}
-	url := c.AuthCodeURL(state)
+	authURL := c.AuthCodeURL(state, goth.AuthURLParamOptions(params)...)
 	return &Session{
 		ID:      "id",
-		AuthURL: url,
+		AuthURL: authURL,
 	}, nil
 }
 
@@ -92,6 +102,46 @@ func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	return nil, nil
 }
 
+// LogoutURL is used only for testing.
+func (p *Provider) LogoutURL(idTokenHint, postLogoutRedirect string) (string, error) {
+	return goth.EndSessionURL("http://example.com/logout", "", idTokenHint, postLogoutRedirect)
+}
+
+// RevokeToken is used only for testing.
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	return nil
+}
+
+// FetchUserFromIDToken is used only for testing. Any ID token but "invalid" is
+// accepted, and used as the user ID.
+func (p *Provider) FetchUserFromIDToken(ctx context.Context, idToken string) (goth.User, error) {
+	if idToken == "invalid" {
+		return goth.User{}, fmt.Errorf("%s rejected the id_token", p.providerName)
+	}
+	return goth.User{
+		UserID:   idToken,
+		Provider: p.Name(),
+		IDToken:  idToken,
+	}, nil
+}
+
+// FetchUserWithToken is used only for testing. Any access token but "invalid"
+// and "foreign", which is issued to another application, is accepted, and
+// used as the user ID.
+func (p *Provider) FetchUserWithToken(ctx context.Context, accessToken string) (goth.User, error) {
+	switch accessToken {
+	case "invalid":
+		return goth.User{}, fmt.Errorf("%s rejected the access token", p.providerName)
+	case "foreign":
+		return goth.User{}, fmt.Errorf("%s: %w", p.providerName, goth.ErrTokenAudience)
+	}
+	return goth.User{
+		UserID:      accessToken,
+		Provider:    p.Name(),
+		AccessToken: accessToken,
+	}, nil
+}
+
 // Authorize is used only for testing.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	s.AccessToken = "access"
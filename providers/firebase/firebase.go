@@ -0,0 +1,216 @@
+// Package firebase implements authenticating users with the ID tokens issued
+// by Firebase Authentication and Google Identity Platform.
+//
+// The sign in itself happens in the Firebase client SDK. BeginAuth returns the
+// callback URL carrying the state as the authorization URL, and the frontend
+// POSTs the ID token of the signed in user, from getIdToken(), as "id_token"
+// along with the "state" to the callback, where it is validated. Backends
+// receiving ID tokens from mobile apps can use FetchUserFromIDToken directly.
+package firebase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/jwkutil"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// endpointKeys serves the keys Firebase signs ID tokens with.
+	endpointKeys = "https://www.googleapis.com/service_accounts/v1/jwk/securetoken@system.gserviceaccount.com"
+
+	// keysMinRefreshInterval is the minimum time the signing keys are cached
+	// for, regardless of the cache headers they are served with.
+	keysMinRefreshInterval = 15 * time.Minute
+)
+
+// New creates a new Firebase provider for the Firebase project projectID.
+// callbackURL is the URL ID tokens are posted to.
+func New(projectID, callbackURL string) *Provider {
+	return &Provider{
+		ProjectID:    projectID,
+		CallbackURL:  callbackURL,
+		providerName: "firebase",
+		keysURL:      endpointKeys,
+		keys:         &jwkutil.KeySet{MinRefreshInterval: keysMinRefreshInterval},
+	}
+}
+
+// Provider is the implementation of `goth.Provider` for Firebase Authentication.
+type Provider struct {
+	ProjectID   string
+	CallbackURL string
+	// TenantID, if set, only accepts ID tokens of users of that Identity
+	// Platform tenant.
+	TenantID     string
+	HTTPClient   *http.Client
+	providerName string
+	keysURL      string
+	keys         *jwkutil.KeySet
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	return &c
+}
+
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the firebase package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth returns a session whose auth URL is the callback URL with the
+// "state" query parameter, for the frontend to post the ID token to.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	u, err := url.Parse(p.CallbackURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("state", state)
+	u.RawQuery = q.Encode()
+
+	return &Session{
+		AuthURL: u.String(),
+	}, nil
+}
+
+// FetchUser returns the user the validated ID token of the session was issued
+// for. No request is made, all the data comes from the ID token.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	if sess.IDToken == "" {
+		// the session is not authorized yet since the ID token is still empty
+		return goth.User{Provider: p.Name()}, fmt.Errorf("%s cannot get user information without an ID token", p.providerName)
+	}
+	return p.FetchUserFromIDToken(context.Background(), sess.IDToken)
+}
+
+// ValidateIDToken verifies the signature of a Firebase ID token against the
+// keys of securetoken@system.gserviceaccount.com, checks that it was issued
+// for the project, and the tenant if one is set, to a user who has signed in
+// and that it hasn't expired, and returns its claims.
+// See https://firebase.google.com/docs/auth/admin/verify-id-tokens#verify_id_tokens_using_a_third-party_jwt_library
+func (p *Provider) ValidateIDToken(ctx context.Context, idToken string) (jwt.MapClaims, error) {
+	if idToken == "" {
+		return nil, errors.New("no id_token to validate")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return p.verificationKey(ctx, token)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer("https://securetoken.google.com/"+p.ProjectID),
+		jwt.WithAudience(p.ProjectID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithTimeFunc(goth.Now),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if sub, _ := claims.GetSubject(); sub == "" {
+		return nil, errors.New("firebase: the id_token has no subject")
+	}
+	authTime, ok := claims["auth_time"].(float64)
+	if !ok || time.Unix(int64(authTime), 0).After(goth.Now()) {
+		return nil, errors.New("firebase: the id_token has no valid auth_time")
+	}
+	if p.TenantID != "" && firebaseClaim(claims, "tenant") != p.TenantID {
+		return nil, fmt.Errorf("firebase: the id_token wasn't issued for tenant %q", p.TenantID)
+	}
+	return claims, nil
+}
+
+// FetchUserFromIDToken validates idToken with ValidateIDToken and returns the
+// Firebase user it was issued for. The identity provider the user signed in
+// with, e.g. "password" or "google.com", is available in RawData as
+// "sign_in_provider".
+func (p *Provider) FetchUserFromIDToken(ctx context.Context, idToken string) (goth.User, error) {
+	claims, err := p.ValidateIDToken(ctx, idToken)
+	if err != nil {
+		return goth.User{}, err
+	}
+
+	str := func(name string) string {
+		s, _ := claims[name].(string)
+		return s
+	}
+	user := goth.User{
+		Provider:  p.Name(),
+		UserID:    str("sub"),
+		Email:     str("email"),
+		Name:      str("name"),
+		AvatarURL: str("picture"),
+		IDToken:   idToken,
+		RawData:   claims,
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		user.ExpiresAt = exp.Time
+	}
+	if signInProvider := firebaseClaim(claims, "sign_in_provider"); signInProvider != "" {
+		user.RawData["sign_in_provider"] = signInProvider
+	}
+	return user, nil
+}
+
+// firebaseClaim returns the string name of the "firebase" claim.
+func firebaseClaim(claims jwt.MapClaims, name string) string {
+	f, _ := claims["firebase"].(map[string]interface{})
+	s, _ := f[name].(string)
+	return s
+}
+
+func (p *Provider) verificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	set, err := p.keys.Fetch(ctx, p.keysURL, p.Client())
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := set.LookupKeyID(kid)
+	if !ok {
+		return nil, fmt.Errorf("could not find key %q to verify id_token", kid)
+	}
+
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// RefreshTokenAvailable refresh token is not provided by firebase
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+// RefreshToken refresh token is not provided by firebase, the client SDK
+// refreshes the ID token itself
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by firebase")
+}
@@ -0,0 +1,152 @@
+package firebase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := New("my-project", "/foo")
+
+	a.Equal("my-project", p.ProjectID)
+	a.Equal("/foo", p.CallbackURL)
+	a.Equal("firebase", p.Name())
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := New("my-project", "/foo")
+
+	a.Implements((*goth.Provider)(nil), p)
+	a.Implements((*goth.IDTokenAuthenticator)(nil), p)
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := New("my-project", "http://localhost:3000/auth/firebase/callback")
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Equal("http://localhost:3000/auth/firebase/callback?state=test_state", session.(*Session).AuthURL)
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p, sign := testProvider(t)
+	now := time.Now()
+	idToken := sign(jwt.MapClaims{
+		"iss":       "https://securetoken.google.com/my-project",
+		"aud":       "my-project",
+		"sub":       "uid123",
+		"user_id":   "uid123",
+		"email":     "homer@example.com",
+		"name":      "Homer Simpson",
+		"picture":   "https://example.com/homer.png",
+		"iat":       now.Unix(),
+		"auth_time": now.Add(-time.Minute).Unix(),
+		"exp":       now.Add(time.Hour).Unix(),
+		"firebase":  map[string]interface{}{"sign_in_provider": "google.com"},
+	})
+
+	s := &Session{}
+	_, err := s.Authorize(p, url.Values{"id_token": {idToken}})
+	a.NoError(err)
+	a.Equal(idToken, s.IDToken)
+	a.False(s.ExpiresAt.IsZero())
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("uid123", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("https://example.com/homer.png", user.AvatarURL)
+	a.Equal("google.com", user.RawData["sign_in_provider"])
+	a.Equal(idToken, user.IDToken)
+
+	_, err = p.FetchUser(&Session{})
+	a.Error(err)
+}
+
+func Test_ValidateIDToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p, sign := testProvider(t)
+	now := time.Now()
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":       "https://securetoken.google.com/my-project",
+			"aud":       "my-project",
+			"sub":       "uid123",
+			"iat":       now.Unix(),
+			"auth_time": now.Unix(),
+			"exp":       now.Add(time.Hour).Unix(),
+			"firebase":  map[string]interface{}{"tenant": "tenant-1"},
+		}
+	}
+
+	_, err := p.ValidateIDToken(context.Background(), sign(valid()))
+	a.NoError(err)
+
+	for name, change := range map[string]func(jwt.MapClaims){
+		"audience":  func(c jwt.MapClaims) { c["aud"] = "other-project" },
+		"issuer":    func(c jwt.MapClaims) { c["iss"] = "https://securetoken.google.com/other-project" },
+		"expired":   func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Minute).Unix() },
+		"subject":   func(c jwt.MapClaims) { delete(c, "sub") },
+		"auth_time": func(c jwt.MapClaims) { c["auth_time"] = now.Add(time.Hour).Unix() },
+	} {
+		claims := valid()
+		change(claims)
+		_, err := p.ValidateIDToken(context.Background(), sign(claims))
+		a.Error(err, name)
+	}
+
+	p.TenantID = "tenant-2"
+	_, err = p.ValidateIDToken(context.Background(), sign(valid()))
+	a.Error(err)
+}
+
+// testProvider returns a provider fetching its keys from a test server, and a
+// function signing ID tokens with them.
+func testProvider(t *testing.T) (*Provider, func(jwt.MapClaims) string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	pub, err := jwk.New(&key.PublicKey)
+	assert.NoError(t, err)
+	assert.NoError(t, pub.Set(jwk.KeyIDKey, "kid1"))
+	set := jwk.NewSet()
+	set.Add(pub)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(ts.Close)
+
+	p := New("my-project", "/foo")
+	p.keysURL = ts.URL
+	return p, func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "kid1"
+		s, err := token.SignedString(key)
+		assert.NoError(t, err)
+		return s
+	}
+}
@@ -0,0 +1,62 @@
+package firebase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+)
+
+// Session stores data during the auth process with Firebase.
+type Session struct {
+	AuthURL   string
+	IDToken   string    `json:",omitempty"`
+	ExpiresAt time.Time `json:",omitempty"`
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the Firebase provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize validates the ID token passed as the "id_token" param and returns
+// it to be stored for future use.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	idToken := params.Get("id_token")
+	claims, err := p.ValidateIDToken(context.Background(), idToken)
+	if err != nil {
+		return "", err
+	}
+
+	s.IDToken = idToken
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		s.ExpiresAt = exp.Time
+	}
+	return idToken, nil
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return s.Marshal()
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	sess := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
+	return sess, err
+}
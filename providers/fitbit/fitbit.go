@@ -3,12 +3,14 @@
 package fitbit
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -37,6 +39,18 @@ const (
 	ScopeSocial = "social"
 	// ScopeWeight includes weight and related information, such as body mass index, body fat percentage, and goals
 	ScopeWeight = "weight"
+	// ScopeCardioFitness includes the cardio fitness score (VO2 Max) data
+	ScopeCardioFitness = "cardio_fitness"
+	// ScopeElectrocardiogram includes the readings of the ECG app
+	ScopeElectrocardiogram = "electrocardiogram"
+	// ScopeIrregularRhythmNotifications includes the alerts of the irregular rhythm notifications feature
+	ScopeIrregularRhythmNotifications = "irregular_rhythm_notifications"
+	// ScopeOxygenSaturation includes the SpO2 data
+	ScopeOxygenSaturation = "oxygen_saturation"
+	// ScopeRespiratoryRate includes the breathing rate data
+	ScopeRespiratoryRate = "respiratory_rate"
+	// ScopeTemperature includes the skin and core temperature data
+	ScopeTemperature = "temperature"
 )
 
 // New creates a new Fitbit provider, and sets up important connection details.
@@ -48,6 +62,7 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 		Secret:       secret,
 		CallbackURL:  callbackURL,
 		providerName: "fitbit",
+		pkce:         true,
 	}
 	p.config = newConfig(p, scopes)
 	return p
@@ -61,6 +76,7 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+	pkce         bool
 }
 
 // Name is the name used to retrieve this provider later.
@@ -73,6 +89,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -80,12 +104,21 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the fitbit package.
 func (p *Provider) Debug(debug bool) {}
 
+// SetPKCE enables or disables Proof Key for Code Exchange (PKCE) for the
+// authorization code flow. It is enabled by default for Fitbit.
+func (p *Provider) SetPKCE(enabled bool) {
+	p.pkce = enabled
+}
+
 // BeginAuth asks Fitbit for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	url := p.config.AuthCodeURL(state)
-	session := &Session{
-		AuthURL: url,
+	session := &Session{}
+	var opts []oauth2.AuthCodeOption
+	if p.pkce {
+		session.CodeVerifier = goth.GeneratePKCEVerifier()
+		opts = append(opts, goth.PKCEChallengeOption(session.CodeVerifier))
 	}
+	session.AuthURL = p.config.AuthCodeURL(state, opts...)
 	return session, nil
 }
 
@@ -180,16 +213,31 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(oauth2.NoContext, token)
-	newToken, err := ts.Token()
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
+}
+
+// RefreshSession refreshes the token held by session and stores the new one,
+// along with its refresh token, in it. Fitbit invalidates a refresh token as
+// soon as it has been used, so the session has to be persisted again
+// afterwards. goth.TokenRotated is called with the new token as well.
+func (p *Provider) RefreshSession(ctx context.Context, session *Session) error {
+	token, err := goth.RefreshToken(ctx, p, session.RefreshToken)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	session.SetToken(token)
+	if userID, ok := token.Extra("user_id").(string); ok && userID != "" {
+		session.UserID = userID
 	}
-	return newToken, err
+	return nil
 }
 
-// RefreshTokenAvailable refresh token is not provided by fitbit
+// RefreshTokenAvailable refresh token is provided by fitbit
 func (p *Provider) RefreshTokenAvailable() bool {
 	return true
 }
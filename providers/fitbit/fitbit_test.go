@@ -1,8 +1,13 @@
 package fitbit_test
 
 import (
+	"context"
+	"io"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/fitbit"
@@ -38,6 +43,9 @@ func Test_BeginAuth(t *testing.T) {
 	s := session.(*fitbit.Session)
 	a.NoError(err)
 	a.Contains(s.AuthURL, "www.fitbit.com/oauth2/authorize")
+	a.NotEmpty(s.CodeVerifier)
+	a.Contains(s.AuthURL, "code_challenge=")
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -53,3 +61,34 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AccessToken, "1234567890")
 	a.Equal(s.UserID, "abc")
 }
+
+func Test_RefreshSession(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("https://api.fitbit.com/oauth2/token", req.URL.String())
+		a.NoError(req.ParseForm())
+		a.Equal("old-refresh", req.PostForm.Get("refresh_token"))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body: io.NopCloser(strings.NewReader(`{"access_token":"new-access","refresh_token":"new-refresh",` +
+				`"token_type":"Bearer","expires_in":28800,"user_id":"26FWFL"}`)),
+		}, nil
+	})}
+
+	session := &fitbit.Session{AccessToken: "old-access", RefreshToken: "old-refresh"}
+	a.NoError(p.RefreshSession(context.Background(), session))
+	a.Equal("new-access", session.AccessToken)
+	a.Equal("new-refresh", session.RefreshToken)
+	a.Equal("26FWFL", session.UserID)
+	a.WithinDuration(time.Now().Add(8*time.Hour), session.ExpiresAt, time.Minute)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -16,6 +17,7 @@ type Session struct {
 	RefreshToken string
 	ExpiresAt    time.Time
 	UserID       string
+	CodeVerifier string `json:",omitempty"`
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the
@@ -31,14 +33,19 @@ func (s Session) GetAuthURL() (string, error) {
 // token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, err := p.config.Exchange(oauth2.NoContext, params.Get("code"), oauth2.SetAuthURLParam("code_verifier", params.Get("code_verifier")))
+	// a code_verifier passed as param takes precedence over the one generated in BeginAuth
+	codeVerifier := params.Get("code_verifier")
+	if codeVerifier == "" {
+		codeVerifier = s.CodeVerifier
+	}
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"), goth.PKCEVerifierOption(codeVerifier))
 	if err != nil {
 		return "", err
 	}
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
-	s.UserID = token.Extra("user_id").(string)
+	s.UserID, _ = token.Extra("user_id").(string)
 	return token.AccessToken, err
 }
 
@@ -59,3 +66,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.Unmarshal([]byte(data), &s)
 	return &s, err
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
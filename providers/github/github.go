@@ -4,16 +4,19 @@ package github
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -33,11 +36,65 @@ var (
 	EmailURL   = "https://api.github.com/user/emails"
 )
 
+// Scopes of GitHub OAuth apps. Without any, the application gets read-only
+// access to the public information of the user.
+// See https://docs.github.com/en/apps/oauth-apps/building-oauth-apps/scopes-for-oauth-apps
+const (
+	// ScopeRepo grants full access to public and private repositories.
+	ScopeRepo = "repo"
+	// ScopePublicRepo grants full access to public repositories only.
+	ScopePublicRepo = "public_repo"
+	// ScopeUser grants read/write access to the profile of the user, and
+	// ScopeReadUser read-only access.
+	ScopeUser     = "user"
+	ScopeReadUser = "read:user"
+	// ScopeUserEmail grants read access to the email addresses of the user,
+	// needed to fetch a private primary email.
+	ScopeUserEmail = "user:email"
+	// ScopeUserFollow grants access to follow or unfollow other users.
+	ScopeUserFollow = "user:follow"
+	// ScopeReadOrg grants read-only access to the organization and team
+	// memberships of the user.
+	ScopeReadOrg = "read:org"
+	// ScopeGist grants write access to gists.
+	ScopeGist = "gist"
+	// ScopeNotifications grants read access to the notifications of the user.
+	ScopeNotifications = "notifications"
+	// ScopeWorkflow grants updating GitHub Actions workflow files.
+	ScopeWorkflow = "workflow"
+	// ScopeReadPackages grants downloading packages from GitHub Packages.
+	ScopeReadPackages = "read:packages"
+)
+
 var (
 	// ErrNoVerifiedGitHubPrimaryEmail user doesn't have verified primary email on GitHub
 	ErrNoVerifiedGitHubPrimaryEmail = errors.New("The user does not have a verified, primary email address on GitHub")
 )
 
+// MembershipError is returned by FetchUser when the user isn't an active
+// member of the organization, or of any of the teams, required with
+// RequireMembership.
+type MembershipError struct {
+	Org string
+	// Teams are the slugs of the teams the user had to be a member of, if
+	// any.
+	Teams []string
+	// State is "pending" if the user was invited but didn't accept yet, or
+	// empty if they aren't a member at all.
+	State string
+}
+
+func (e *MembershipError) Error() string {
+	member := "a member of the GitHub organization " + e.Org
+	if len(e.Teams) > 0 {
+		member = "a member of any of the teams " + strings.Join(e.Teams, ", ") + " of the GitHub organization " + e.Org
+	}
+	if e.State == "pending" {
+		return "the invitation of the user to become " + member + " is pending"
+	}
+	return "the user is not " + member
+}
+
 // New creates a new Github provider, and sets up important connection details.
 // You should always call `github.New` to get a new Provider. Never try to create
 // one manually.
@@ -67,8 +124,12 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+	debug        bool
 	profileURL   string
 	emailURL     string
+	app          bool
+	org          string
+	teams        []string
 }
 
 // Name is the name used to retrieve this provider later.
@@ -81,12 +142,49 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
 
-// Debug is a no-op for the github package.
-func (p *Provider) Debug(debug bool) {}
+// Debug makes the sessions keep the responses of the token endpoint and
+// traces of the requests made to authorize them, returned by their
+// DebugInfo method.
+func (p *Provider) Debug(debug bool) {
+	p.debug = debug
+}
+
+// SetAppMode is to be enabled when the client credentials belong to a GitHub
+// App rather than to an OAuth App. GitHub Apps may issue user access tokens
+// expiring after 8 hours, which come with a refresh token, so
+// RefreshTokenAvailable reports true in this mode. If the user installed the
+// app during the authorization, the installation id is available in RawData
+// under "installation_id", once GitHub confirmed the user can access that
+// installation of the app.
+// See https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/refreshing-user-access-tokens
+func (p *Provider) SetAppMode(enabled bool) {
+	p.app = enabled
+}
+
+// RequireMembership makes FetchUser fail with a *MembershipError unless the
+// user is an active member of the organization org and, if any slugs of
+// teams are given, of one of those teams. It needs the read:org scope
+// (ScopeReadOrg), and the organization may have to approve the application.
+// The role of the user in the organization, "admin" or "member", is added
+// to User.Roles, and the teams they are members of to User.Groups as
+// "org/team".
+// See https://docs.github.com/en/rest/orgs/members#get-an-organization-membership-for-the-authenticated-user
+func (p *Provider) RequireMembership(org string, teams ...string) {
+	p.org = org
+	p.teams = teams
+}
 
 // BeginAuth asks Github for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
@@ -99,10 +197,62 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 
 // FetchUser will go to Github and access basic information about the user.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
-	sess := session.(*Session)
+	return p.fetchUser(context.Background(), session.(*Session))
+}
+
+// FetchUserWithToken fetches the user an access token obtained outside of
+// Goth was issued for, e.g. by a mobile app, from GitHub. Tokens issued to
+// another application fail with an error wrapping goth.ErrTokenAudience.
+func (p *Provider) FetchUserWithToken(ctx context.Context, accessToken string) (goth.User, error) {
+	if accessToken != "" {
+		if err := p.verifyToken(ctx, accessToken); err != nil {
+			return goth.User{Provider: p.Name()}, err
+		}
+	}
+	return p.fetchUser(ctx, &Session{AccessToken: accessToken})
+}
+
+// verifyToken checks that accessToken was issued to the application through
+// its OAuth Authorizations API, which only knows the tokens of the
+// application authenticating the request.
+// See https://docs.github.com/en/rest/apps/oauth-applications#check-a-token
+func (p *Provider) verifyToken(ctx context.Context, accessToken string) error {
+	body, err := json.Marshal(map[string]string{"access_token": accessToken})
+	if err != nil {
+		return err
+	}
+
+	checkURL := strings.TrimSuffix(p.profileURL, "/user") + "/applications/" + p.ClientKey + "/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, checkURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.ClientKey, p.Secret)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%s: %w", p.providerName, goth.ErrTokenAudience)
+	default:
+		return fmt.Errorf("%s responded with a %d trying to verify the access token", p.providerName, response.StatusCode)
+	}
+}
+
+func (p *Provider) fetchUser(ctx context.Context, sess *Session) (goth.User, error) {
 	user := goth.User{
-		AccessToken: sess.AccessToken,
-		Provider:    p.Name(),
+		AccessToken:  sess.AccessToken,
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.expiry(),
+		Provider:     p.Name(),
 	}
 
 	if user.AccessToken == "" {
@@ -110,7 +260,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	req, err := http.NewRequest("GET", p.profileURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", p.profileURL, nil)
 	if err != nil {
 		return user, err
 	}
@@ -122,6 +272,9 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 	defer response.Body.Close()
 
+	if err := goth.CheckRateLimit(p.providerName, response); err != nil {
+		return user, err
+	}
 	if response.StatusCode != http.StatusOK {
 		return user, fmt.Errorf("GitHub API responded with a %d trying to fetch user information", response.StatusCode)
 	}
@@ -141,10 +294,28 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, err
 	}
 
+	if sess.InstallationID != "" {
+		// the id comes from the callback, which anyone can forge
+		ok, err := hasInstallation(ctx, p, sess)
+		if err != nil {
+			return user, err
+		}
+		if ok {
+			user.RawData["installation_id"] = sess.InstallationID
+		}
+	}
+	goth.SetTokenExtras(&user, sess.TokenExtras)
+
+	if p.org != "" {
+		if err := checkMembership(ctx, p, sess, &user); err != nil {
+			return user, err
+		}
+	}
+
 	if user.Email == "" {
 		for _, scope := range p.config.Scopes {
 			if strings.TrimSpace(scope) == "user" || strings.TrimSpace(scope) == "user:email" {
-				user.Email, err = getPrivateMail(p, sess)
+				user.Email, err = getPrivateMail(ctx, p, sess)
 				if err != nil {
 					return user, err
 				}
@@ -155,6 +326,23 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	return user, err
 }
 
+// avatarURLs returns the sizes of an avatar, which GitHub resizes to the
+// width given by the s parameter, up to 460 pixels.
+func avatarURLs(avatarURL string) map[string]string {
+	u, err := url.Parse(avatarURL)
+	if avatarURL == "" || err != nil {
+		return nil
+	}
+	sized := func(size string) string {
+		q := u.Query()
+		q.Set("s", size)
+		v := *u
+		v.RawQuery = q.Encode()
+		return v.String()
+	}
+	return goth.NewAvatarURLs(sized("48"), sized("200"), sized("460"), avatarURL)
+}
+
 func userFromReader(reader io.Reader, user *goth.User) error {
 	u := struct {
 		ID       int    `json:"id"`
@@ -176,14 +364,15 @@ func userFromReader(reader io.Reader, user *goth.User) error {
 	user.Email = u.Email
 	user.Description = u.Bio
 	user.AvatarURL = u.Picture
+	user.AvatarURLs = avatarURLs(u.Picture)
 	user.UserID = strconv.Itoa(u.ID)
 	user.Location = u.Location
 
 	return err
 }
 
-func getPrivateMail(p *Provider, sess *Session) (email string, err error) {
-	req, err := http.NewRequest("GET", p.emailURL, nil)
+func getPrivateMail(ctx context.Context, p *Provider, sess *Session) (email string, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.emailURL, nil)
 	req.Header.Add("Authorization", "Bearer "+sess.AccessToken)
 	response, err := p.Client().Do(req)
 	if err != nil {
@@ -215,6 +404,117 @@ func getPrivateMail(p *Provider, sess *Session) (email string, err error) {
 	return email, ErrNoVerifiedGitHubPrimaryEmail
 }
 
+type membership struct {
+	State string `json:"state"`
+	Role  string `json:"role"`
+}
+
+// checkMembership checks that the user is an active member of the
+// organization and teams required with RequireMembership, adding their role
+// and teams to user.
+func checkMembership(ctx context.Context, p *Provider, sess *Session, user *goth.User) error {
+	apiURL := strings.TrimSuffix(p.profileURL, "/user")
+
+	org, err := getMembership(ctx, p, sess, apiURL+"/user/memberships/orgs/"+url.PathEscape(p.org))
+	if err != nil {
+		return err
+	}
+	if org.State != "active" {
+		return &MembershipError{Org: p.org, Teams: p.teams, State: org.State}
+	}
+	user.Roles = append(user.Roles, org.Role)
+	user.RawData["org_role"] = org.Role
+
+	if len(p.teams) == 0 {
+		return nil
+	}
+	state := ""
+	for _, team := range p.teams {
+		m, err := getMembership(ctx, p, sess, apiURL+"/orgs/"+url.PathEscape(p.org)+"/teams/"+url.PathEscape(team)+"/memberships/"+url.PathEscape(user.NickName))
+		if err != nil {
+			return err
+		}
+		if m.State == "active" {
+			user.Groups = append(user.Groups, p.org+"/"+team)
+		} else if m.State != "" {
+			state = m.State
+		}
+	}
+	if len(user.Groups) == 0 {
+		return &MembershipError{Org: p.org, Teams: p.teams, State: state}
+	}
+	return nil
+}
+
+// hasInstallation reports whether the installation of the session is one of
+// the installations of the app the user can access.
+// See https://docs.github.com/en/rest/apps/installations#list-app-installations-accessible-to-the-user-access-token
+func hasInstallation(ctx context.Context, p *Provider, sess *Session) (bool, error) {
+	installationsURL := strings.TrimSuffix(p.profileURL, "/user") + "/user/installations?per_page=100&page="
+	for page := 1; ; page++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", installationsURL+strconv.Itoa(page), nil)
+		if err != nil {
+			return false, err
+		}
+		req.Header.Add("Authorization", "Bearer "+sess.AccessToken)
+		req.Header.Add("Accept", "application/vnd.github+json")
+		response, err := p.Client().Do(req)
+		if err != nil {
+			return false, err
+		}
+
+		var installations struct {
+			Installations []struct {
+				ID int64 `json:"id"`
+			} `json:"installations"`
+		}
+		if response.StatusCode == http.StatusOK {
+			err = json.NewDecoder(response.Body).Decode(&installations)
+		} else {
+			err = fmt.Errorf("GitHub API responded with a %d trying to fetch the installations of the user", response.StatusCode)
+		}
+		response.Body.Close()
+		if err != nil {
+			return false, err
+		}
+
+		for _, installation := range installations.Installations {
+			if strconv.FormatInt(installation.ID, 10) == sess.InstallationID {
+				return true, nil
+			}
+		}
+		if len(installations.Installations) < 100 {
+			return false, nil
+		}
+	}
+}
+
+// getMembership fetches a membership of the user, whose State is empty if
+// they aren't a member.
+func getMembership(ctx context.Context, p *Provider, sess *Session, membershipURL string) (membership, error) {
+	var m membership
+	req, err := http.NewRequestWithContext(ctx, "GET", membershipURL, nil)
+	if err != nil {
+		return m, err
+	}
+	req.Header.Add("Authorization", "Bearer "+sess.AccessToken)
+	req.Header.Add("Accept", "application/vnd.github+json")
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return m, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return m, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return m, fmt.Errorf("GitHub API responded with a %d trying to fetch the membership of the user", response.StatusCode)
+	}
+	err = json.NewDecoder(response.Body).Decode(&m)
+	return m, err
+}
+
 func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
@@ -234,12 +534,52 @@ func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *o
 	return c
 }
 
-// RefreshToken refresh token is not provided by github
+// RefreshToken get new access token based on the refresh token. Refresh
+// tokens are only provided to GitHub Apps, see SetAppMode.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	return nil, errors.New("Refresh token is not provided by github")
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	if !p.app {
+		return nil, errors.New("Refresh token is not provided by github")
+	}
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
 
-// RefreshTokenAvailable refresh token is not provided by github
+// RefreshTokenAvailable refresh token is only provided by github to GitHub Apps
 func (p *Provider) RefreshTokenAvailable() bool {
-	return false
+	return p.app
+}
+
+// RevokeToken deletes the access token through the OAuth Authorizations API
+// of the application. The API URL is derived from the profile URL, so it
+// works with GitHub Enterprise as well.
+// See https://docs.github.com/en/rest/apps/oauth-applications#delete-an-app-token
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	body, err := json.Marshal(map[string]string{"access_token": token})
+	if err != nil {
+		return err
+	}
+
+	revokeURL := strings.TrimSuffix(p.profileURL, "/user") + "/applications/" + p.ClientKey + "/token"
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, revokeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.ClientKey, p.Secret)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("%s responded with a %d trying to revoke the token", p.providerName, response.StatusCode)
+	}
+	return nil
 }
@@ -1,12 +1,19 @@
 package github_test
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/github"
+	"github.com/markbates/goth/providertest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -68,6 +75,231 @@ func githubProvider() *github.Provider {
 	return github.New(os.Getenv("GITHUB_KEY"), os.Getenv("GITHUB_SECRET"), "/foo", "user")
 }
 
+func Test_RevokeToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("DELETE", r.Method)
+		a.Equal("/api/v3/applications/key/token", r.URL.Path)
+		user, pass, _ := r.BasicAuth()
+		a.Equal("key", user)
+		a.Equal("secret", pass)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	p := github.NewCustomisedURL("key", "secret", "/foo", ts.URL+"/login/oauth/authorize", ts.URL+"/login/oauth/access_token", ts.URL+"/api/v3/user", ts.URL+"/api/v3/user/emails")
+	a.NoError(p.RevokeToken(context.Background(), "1234567890"))
+}
+
+func Test_FetchUserWithToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v3/applications/key/token" {
+			key, secret, _ := r.BasicAuth()
+			a.Equal("key", key)
+			a.Equal("secret", secret)
+			a.Equal(http.MethodPost, r.Method)
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != `{"access_token":"gho_mobile"}` {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"token":"gho_mobile","user":{"id":1,"login":"octocat"}}`))
+			return
+		}
+		a.Equal("Bearer gho_mobile", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/api/v3/user":
+			w.Write([]byte(`{"id":1,"login":"octocat","avatar_url":"https://avatars.githubusercontent.com/u/1?v=4"}`))
+		case "/api/v3/user/emails":
+			w.Write([]byte(`[{"email":"octocat@example.com","primary":true,"verified":true}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	p := github.NewCustomisedURL("key", "secret", "/foo", ts.URL+"/login/oauth/authorize", ts.URL+"/login/oauth/access_token", ts.URL+"/api/v3/user", ts.URL+"/api/v3/user/emails", "user:email")
+	user, err := goth.UserFromAccessToken(context.Background(), p, "gho_mobile")
+	a.NoError(err)
+	a.Equal("1", user.UserID)
+	a.Equal("octocat", user.NickName)
+	a.Equal("octocat@example.com", user.Email)
+	a.Equal("gho_mobile", user.AccessToken)
+	a.Equal("https://avatars.githubusercontent.com/u/1?v=4", user.AvatarURL)
+	a.Equal("https://avatars.githubusercontent.com/u/1?s=48&v=4", user.Avatar(goth.AvatarSmall))
+	a.Equal("https://avatars.githubusercontent.com/u/1?s=460&v=4", user.Avatar(goth.AvatarLarge))
+
+	_, err = p.FetchUserWithToken(context.Background(), "gho_other_app")
+	a.ErrorIs(err, goth.ErrTokenAudience)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = p.FetchUserWithToken(ctx, "gho_mobile")
+	a.ErrorIs(err, context.Canceled)
+}
+
+func Test_RequireMembership(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/api/v3/user":
+			w.Write([]byte(`{"id":1,"login":"octocat"}`))
+		case "/api/v3/user/memberships/orgs/acme":
+			switch token {
+			case "Bearer member", "Bearer maintainer":
+				w.Write([]byte(`{"state":"active","role":"admin"}`))
+			case "Bearer invited":
+				w.Write([]byte(`{"state":"pending","role":"member"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case "/api/v3/orgs/acme/teams/core/memberships/octocat":
+			if token == "Bearer maintainer" {
+				w.Write([]byte(`{"state":"active","role":"maintainer"}`))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	p := github.NewCustomisedURL("key", "secret", "/foo", ts.URL+"/login/oauth/authorize", ts.URL+"/login/oauth/access_token", ts.URL+"/api/v3/user", ts.URL+"/api/v3/user/emails", github.ScopeReadOrg)
+	p.RequireMembership("acme")
+
+	user, err := p.FetchUser(&github.Session{AccessToken: "member"})
+	a.NoError(err)
+	a.Equal([]string{"admin"}, user.Roles)
+	a.Equal("admin", user.RawData["org_role"])
+
+	_, err = p.FetchUser(&github.Session{AccessToken: "invited"})
+	var membershipErr *github.MembershipError
+	a.ErrorAs(err, &membershipErr)
+	a.Equal("acme", membershipErr.Org)
+	a.Equal("pending", membershipErr.State)
+
+	_, err = p.FetchUser(&github.Session{AccessToken: "outsider"})
+	a.ErrorAs(err, &membershipErr)
+	a.Empty(membershipErr.State)
+	a.EqualError(err, "the user is not a member of the GitHub organization acme")
+
+	p.RequireMembership("acme", "core")
+	user, err = p.FetchUser(&github.Session{AccessToken: "maintainer"})
+	a.NoError(err)
+	a.Equal([]string{"acme/core"}, user.Groups)
+
+	_, err = p.FetchUser(&github.Session{AccessToken: "member"})
+	a.ErrorAs(err, &membershipErr)
+	a.Equal([]string{"core"}, membershipErr.Teams)
+}
+
+func Test_Debug(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"gho_token","token_type":"bearer","scope":"user:email"}`))
+	}))
+	defer ts.Close()
+
+	p := github.NewCustomisedURL("key", "secret", "/foo", ts.URL+"/login/oauth/authorize", ts.URL+"/login/oauth/access_token", ts.URL+"/api/v3/user", ts.URL+"/api/v3/user/emails")
+	s := &github.Session{}
+	_, err := s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Nil(s.DebugInfo())
+
+	p.Debug(true)
+	_, err = s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Implements((*goth.DebugSession)(nil), s)
+	info := s.DebugInfo()
+	a.NotNil(info)
+	a.Equal(`{"access_token":"REDACTED","token_type":"bearer","scope":"user:email"}`, info.TokenResponse)
+	a.Len(info.Traces, 1)
+	a.Equal(ts.URL+"/login/oauth/access_token", info.Traces[0].URL)
+
+	// the debug info is kept in the marshaled session
+	restored, err := p.UnmarshalSession(s.Marshal())
+	a.NoError(err)
+	a.Equal(info.TokenResponse, restored.(*github.Session).DebugInfo().TokenResponse)
+}
+
+func Test_AppMode(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login/oauth/access_token":
+			a.NoError(r.ParseForm())
+			w.Header().Set("Content-Type", "application/json")
+			if r.PostForm.Get("grant_type") == "refresh_token" {
+				a.Equal("ghr_old", r.PostForm.Get("refresh_token"))
+				w.Write([]byte(`{"access_token":"ghu_new","refresh_token":"ghr_new","expires_in":28800,"token_type":"bearer"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token":"ghu_old","refresh_token":"ghr_old","expires_in":28800,` +
+				`"refresh_token_expires_in":15811200,"token_type":"bearer","scope":"repo,gist"}`))
+		case "/api/v3/user":
+			w.Write([]byte(`{"id":1,"login":"octocat","email":"octocat@example.com"}`))
+		case "/api/v3/user/installations":
+			a.Equal("Bearer ghu_old", r.Header.Get("Authorization"))
+			a.Equal("1", r.URL.Query().Get("page"))
+			w.Write([]byte(`{"total_count":2,"installations":[{"id":7},{"id":42}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	p := github.NewCustomisedURL("key", "secret", "/foo", ts.URL+"/login/oauth/authorize", ts.URL+"/login/oauth/access_token", ts.URL+"/api/v3/user", ts.URL+"/api/v3/user/emails")
+	a.False(p.RefreshTokenAvailable())
+	_, err := p.RefreshToken("ghr_old")
+	a.Error(err)
+
+	p.SetAppMode(true)
+	a.True(p.RefreshTokenAvailable())
+
+	session := &github.Session{}
+	_, err = session.Authorize(p, url.Values{"code": {"code"}, "installation_id": {"42"}, "setup_action": {"install"}})
+	a.NoError(err)
+	a.Equal("ghr_old", session.RefreshToken)
+	a.Equal("42", session.InstallationID)
+
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("octocat", user.NickName)
+	a.Equal("ghr_old", user.RefreshToken)
+	a.WithinDuration(time.Now().Add(8*time.Hour), user.ExpiresAt, time.Minute)
+	a.Equal("42", user.RawData["installation_id"])
+	a.Equal(map[string]interface{}{"scope": "repo,gist", "refresh_token_expires_in": float64(15811200)}, user.RawData[goth.TokenExtrasKey])
+
+	// an installation the user can't access isn't trusted
+	forged := &github.Session{AccessToken: "ghu_old", InstallationID: "666"}
+	forgedUser, err := p.FetchUser(forged)
+	a.NoError(err)
+	a.NotContains(forgedUser.RawData, "installation_id")
+
+	token, err := p.RefreshToken(user.RefreshToken)
+	a.NoError(err)
+	a.Equal("ghu_new", token.AccessToken)
+	a.Equal("ghr_new", token.RefreshToken)
+}
+
 func urlCustomisedURLProvider() *github.Provider {
 	return github.NewCustomisedURL(os.Getenv("GITHUB_KEY"), os.Getenv("GITHUB_SECRET"), "/foo", "http://authURL", "http://tokenURL", "http://profileURL", "http://emailURL")
 }
+
+func Test_Conformance(t *testing.T) {
+	providertest.RunConformance(t, github.New("key", "secret", "/foo"))
+}
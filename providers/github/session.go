@@ -3,17 +3,46 @@ package github
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with GitHub.
 type Session struct {
-	AuthURL     string
-	AccessToken string
+	AuthURL        string
+	AccessToken    string
+	RefreshToken   string                 `json:",omitempty"`
+	ExpiresAt      *time.Time             `json:",omitempty"` // only set for expiring GitHub App tokens
+	InstallationID string                 `json:",omitempty"`
+	TokenExtras    map[string]interface{} `json:",omitempty"`
+	// Debug is what was captured while authorizing the session, if the
+	// provider was in debug mode.
+	Debug *goth.DebugInfo `json:",omitempty"`
 }
 
+// DebugInfo implements goth.DebugSession.
+func (s Session) DebugInfo() *goth.DebugInfo {
+	return s.Debug
+}
+
+// client returns the client authorizing the session, which records its
+// requests in Debug if the provider is in debug mode.
+func (s *Session) client(p *Provider) *http.Client {
+	if !p.debug {
+		return p.Client()
+	}
+	s.Debug = &goth.DebugInfo{}
+	return goth.DebugClient(p.Client(), s.Debug)
+}
+
+// tokenExtras are the fields of the token response kept in the session.
+var tokenExtras = []string{"scope", "refresh_token_expires_in"}
+
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the GitHub provider.
 func (s Session) GetAuthURL() (string, error) {
 	if s.AuthURL == "" {
@@ -25,7 +54,7 @@ func (s Session) GetAuthURL() (string, error) {
 // Authorize the session with GitHub and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
+	token, err := p.config.Exchange(goth.ContextForClient(s.client(p)), params.Get("code"))
 	if err != nil {
 		return "", err
 	}
@@ -35,6 +64,12 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	}
 
 	s.AccessToken = token.AccessToken
+	s.RefreshToken = token.RefreshToken
+	s.setExpiry(token.Expiry)
+	// set when the user installed the GitHub App during the authorization,
+	// verified by FetchUser as it is a parameter of the callback
+	s.InstallationID = params.Get("installation_id")
+	s.TokenExtras = goth.TokenExtras(token, tokenExtras...)
 	return token.AccessToken, err
 }
 
@@ -54,3 +89,29 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
 	return sess, err
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.expiry())
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	var expiry time.Time
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &expiry)
+	s.setExpiry(expiry)
+}
+
+func (s *Session) expiry() time.Time {
+	if s.ExpiresAt == nil {
+		return time.Time{}
+	}
+	return *s.ExpiresAt
+}
+
+func (s *Session) setExpiry(expiry time.Time) {
+	s.ExpiresAt = nil
+	if !expiry.IsZero() {
+		s.ExpiresAt = &expiry
+	}
+}
@@ -4,6 +4,7 @@ package gitlab
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,8 +12,10 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -30,6 +33,31 @@ var (
 	ProfileURL = "https://gitlab.com/api/v3/user"
 )
 
+// Scopes of GitLab applications.
+// See https://docs.gitlab.com/ee/integration/oauth_provider.html#view-all-authorized-applications
+const (
+	// ScopeReadUser grants read-only access to the profile of the user.
+	ScopeReadUser = "read_user"
+	// ScopeOpenID, ScopeProfile and ScopeEmail use GitLab as an OpenID
+	// Connect provider, whose userinfo lists the groups of the user.
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	// ScopeAPI grants full read/write access to the API, and ScopeReadAPI
+	// read-only access, e.g. to the groups of the user.
+	ScopeAPI     = "api"
+	ScopeReadAPI = "read_api"
+	// ScopeSudo allows administrators to call the API as other users, and
+	// ScopeAdminMode to call it in Admin Mode, on self-managed instances.
+	ScopeSudo      = "sudo"
+	ScopeAdminMode = "admin_mode"
+)
+
+// RawDataGroups is the key of User.RawData holding the full paths of the
+// groups of the user, e.g. "acme/backend", when SetFetchGroups is enabled.
+// They are also set as User.Groups.
+const RawDataGroups = "groups"
+
 // Provider is the implementation of `goth.Provider` for accessing Gitlab.
 type Provider struct {
 	ClientKey    string
@@ -41,6 +69,7 @@ type Provider struct {
 	authURL      string
 	tokenURL     string
 	profileURL   string
+	fetchGroups  bool
 }
 
 // New creates a new Gitlab provider and sets up important connection details.
@@ -73,6 +102,21 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
+// SetFetchGroups makes FetchUser also fetch the groups the user is a member
+// of. They are taken from /api/v4/groups with the ScopeReadAPI or ScopeAPI
+// scope, and from the userinfo of OpenID Connect with ScopeOpenID otherwise.
+func (p *Provider) SetFetchGroups(enabled bool) {
+	p.fetchGroups = enabled
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -127,8 +171,110 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	err = userFromReader(bytes.NewReader(bits), &user)
+	if err != nil || !p.fetchGroups {
+		return user, err
+	}
+
+	user.Groups, err = p.getGroups(sess.AccessToken)
+	if err != nil {
+		return user, err
+	}
+	user.RawData[RawDataGroups] = user.Groups
+	return user, nil
+}
+
+// getGroups returns the full paths of the groups of the user, using the API
+// when the scopes allow it and the userinfo of OpenID Connect otherwise.
+func (p *Provider) getGroups(accessToken string) ([]string, error) {
+	hasScope := func(scope string) bool {
+		for _, s := range p.config.Scopes {
+			if s == scope {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case hasScope(ScopeReadAPI) || hasScope(ScopeAPI):
+		return p.getAPIGroups(accessToken)
+	case hasScope(ScopeOpenID):
+		return p.getUserInfoGroups(accessToken)
+	}
+	return nil, fmt.Errorf("%s cannot fetch groups without the %s, %s or %s scope", p.providerName, ScopeReadAPI, ScopeAPI, ScopeOpenID)
+}
+
+// getAPIGroups lists the groups of the user, following the pages of the
+// response. The groups endpoint is next to the profile one.
+// See https://docs.gitlab.com/ee/api/groups.html#list-groups
+func (p *Provider) getAPIGroups(accessToken string) ([]string, error) {
+	groupsURL := strings.TrimSuffix(p.profileURL, "/user") + "/groups"
+	groups := []string{}
+	for page := "1"; page != ""; {
+		query := url.Values{"min_access_level": {"10"}, "per_page": {"100"}, "page": {page}}
+		req, err := http.NewRequest("GET", groupsURL+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+
+		response, err := p.Client().Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var list []struct {
+			FullPath string `json:"full_path"`
+		}
+		if response.StatusCode == http.StatusOK {
+			err = json.NewDecoder(response.Body).Decode(&list)
+		} else {
+			err = fmt.Errorf("%s responded with a %d trying to fetch groups", p.providerName, response.StatusCode)
+		}
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, g := range list {
+			groups = append(groups, g.FullPath)
+		}
+		page = response.Header.Get("X-Next-Page")
+	}
+	return groups, nil
+}
 
-	return user, err
+// getUserInfoGroups returns the groups claim of the OpenID Connect userinfo,
+// whose endpoint is next to the token one.
+// See https://docs.gitlab.com/ee/integration/openid_connect_provider.html#shared-information
+func (p *Provider) getUserInfoGroups(accessToken string) ([]string, error) {
+	userInfoURL := strings.TrimSuffix(p.config.Endpoint.TokenURL, "/token") + "/userinfo"
+	req, err := http.NewRequest("GET", userInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to fetch groups", p.providerName, response.StatusCode)
+	}
+
+	info := struct {
+		Groups []string `json:"groups"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return nil, err
+	}
+	if info.Groups == nil {
+		info.Groups = []string{}
+	}
+	return info.Groups, nil
 }
 
 func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *oauth2.Config {
@@ -178,11 +324,38 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
+}
+
+// RevokeToken revokes the access or refresh token. The revocation endpoint is
+// derived from the token URL, so it works with self-managed instances as well.
+// See https://docs.gitlab.com/ee/api/oauth2.html#revoke-a-token
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	form := url.Values{
+		"client_id":     {p.ClientKey},
+		"client_secret": {p.Secret},
+		"token":         {token},
+	}
+	revokeURL := strings.TrimSuffix(p.config.Endpoint.TokenURL, "/token") + "/revoke"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL, strings.NewReader(form.Encode()))
 	if err != nil {
-		return nil, err
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to revoke the token", p.providerName, response.StatusCode)
 	}
-	return newToken, err
+	return nil
 }
@@ -1,6 +1,10 @@
 package gitlab_test
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -58,6 +62,68 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AccessToken, "1234567890")
 }
 
+func Test_RevokeToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("POST", r.Method)
+		a.Equal("/oauth/revoke", r.URL.Path)
+		a.NoError(r.ParseForm())
+		a.Equal("1234567890", r.PostForm.Get("token"))
+		a.Equal("key", r.PostForm.Get("client_id"))
+	}))
+	defer ts.Close()
+
+	p := gitlab.NewCustomisedURL("key", "secret", "/foo", ts.URL+"/oauth/authorize", ts.URL+"/oauth/token", ts.URL+"/api/v4/user")
+	a.NoError(p.RevokeToken(context.Background(), "1234567890"))
+}
+
+func Test_FetchUser_Groups(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/user":
+			fmt.Fprint(w, `{"id":1,"username":"homer","name":"Homer Simpson"}`)
+		case "/api/v4/groups":
+			a.Equal("Bearer token", r.Header.Get("Authorization"))
+			if r.URL.Query().Get("page") == "1" {
+				w.Header().Set("X-Next-Page", "2")
+				fmt.Fprint(w, `[{"full_path":"springfield"}]`)
+			} else {
+				fmt.Fprint(w, `[{"full_path":"springfield/plant"}]`)
+			}
+		case "/oauth/userinfo":
+			fmt.Fprint(w, `{"sub":"1","groups":["springfield","springfield/school"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	newProvider := func(scopes ...string) *gitlab.Provider {
+		p := gitlab.NewCustomisedURL("key", "secret", "/foo", ts.URL+"/oauth/authorize", ts.URL+"/oauth/token", ts.URL+"/api/v4/user", scopes...)
+		p.SetFetchGroups(true)
+		return p
+	}
+	session := &gitlab.Session{AccessToken: "token"}
+
+	user, err := newProvider(gitlab.ScopeReadUser, gitlab.ScopeReadAPI).FetchUser(session)
+	a.NoError(err)
+	a.Equal("homer", user.NickName)
+	a.Equal([]string{"springfield", "springfield/plant"}, user.Groups)
+	a.Equal(user.Groups, user.RawData[gitlab.RawDataGroups])
+
+	user, err = newProvider(gitlab.ScopeReadUser, gitlab.ScopeOpenID).FetchUser(session)
+	a.NoError(err)
+	a.Equal([]string{"springfield", "springfield/school"}, user.Groups)
+
+	_, err = newProvider(gitlab.ScopeReadUser).FetchUser(session)
+	a.Error(err)
+}
+
 func provider() *gitlab.Provider {
 	return gitlab.New(os.Getenv("GITLAB_KEY"), os.Getenv("GITLAB_SECRET"), "/foo")
 }
@@ -3,18 +3,26 @@
 package google
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/jwkutil"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
-const endpointProfile string = "https://www.googleapis.com/oauth2/v2/userinfo"
+const (
+	endpointProfile   string = "https://www.googleapis.com/oauth2/v2/userinfo"
+	endpointRevoke    string = "https://oauth2.googleapis.com/revoke"
+	endpointTokenInfo string = "https://oauth2.googleapis.com/tokeninfo"
+)
 
 // New creates a new Google provider, and sets up important connection details.
 // You should always call `google.New` to get a new Provider. Never try to create
@@ -25,6 +33,7 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 		Secret:       secret,
 		CallbackURL:  callbackURL,
 		providerName: "google",
+		certs:        &jwkutil.KeySet{MinRefreshInterval: certsMinRefreshInterval},
 
 		// We can get a refresh token from Google by this option.
 		// See https://developers.google.com/identity/protocols/oauth2/openid-connect#access-type-param
@@ -45,6 +54,10 @@ type Provider struct {
 	config          *oauth2.Config
 	authCodeOptions []oauth2.AuthCodeOption
 	providerName    string
+	debug           bool
+	pkce            bool
+	hostedDomain    string
+	certs           *jwkutil.KeySet
 }
 
 // Name is the name used to retrieve this provider later.
@@ -57,20 +70,48 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 // Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
 
-// Debug is a no-op for the google package.
-func (p *Provider) Debug(debug bool) {}
+// Debug makes the sessions keep the responses of the token endpoint and
+// traces of the requests made to authorize them, returned by their
+// DebugInfo method.
+func (p *Provider) Debug(debug bool) {
+	p.debug = debug
+}
+
+// SetPKCE enables or disables Proof Key for Code Exchange (PKCE) for the
+// authorization code flow. It is disabled by default for Google.
+func (p *Provider) SetPKCE(enabled bool) {
+	p.pkce = enabled
+}
 
 // BeginAuth asks Google for an authentication endpoint.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	url := p.config.AuthCodeURL(state, p.authCodeOptions...)
-	session := &Session{
-		AuthURL: url,
+	return p.BeginAuthWithParams(state, nil)
+}
+
+// BeginAuthWithParams is like BeginAuth, adding params (e.g. login_hint or prompt)
+// to the authentication end-point.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
+	session := &Session{}
+	opts := append([]oauth2.AuthCodeOption{}, p.authCodeOptions...)
+	opts = append(opts, goth.AuthURLParamOptions(params)...)
+	if p.pkce {
+		session.CodeVerifier = goth.GeneratePKCEVerifier()
+		opts = append(opts, goth.PKCEChallengeOption(session.CodeVerifier))
 	}
+	session.AuthURL = p.config.AuthCodeURL(state, opts...)
 	return session, nil
 }
 
@@ -82,11 +123,58 @@ type googleUser struct {
 	LastName  string `json:"family_name"`
 	Link      string `json:"link"`
 	Picture   string `json:"picture"`
+	HD        string `json:"hd"`
 }
 
 // FetchUser will go to Google and access basic information about the user.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
-	sess := session.(*Session)
+	return p.fetchUser(context.Background(), session.(*Session))
+}
+
+// FetchUserWithToken fetches the user an access token obtained outside of
+// Goth was issued for, e.g. by a mobile app, from Google. Tokens issued to
+// another client fail with an error wrapping goth.ErrTokenAudience.
+func (p *Provider) FetchUserWithToken(ctx context.Context, accessToken string) (goth.User, error) {
+	if accessToken != "" {
+		if err := p.verifyToken(ctx, accessToken); err != nil {
+			return goth.User{Provider: p.Name()}, err
+		}
+	}
+	return p.fetchUser(ctx, &Session{AccessToken: accessToken})
+}
+
+// verifyToken checks with the tokeninfo endpoint that accessToken was issued
+// to the client of the provider.
+// See https://developers.google.com/identity/protocols/oauth2/native-app#tokeninfo
+func (p *Provider) verifyToken(ctx context.Context, accessToken string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpointTokenInfo+"?access_token="+url.QueryEscape(accessToken), nil)
+	if err != nil {
+		return err
+	}
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to verify the access token", p.providerName, response.StatusCode)
+	}
+
+	info := struct {
+		Audience        string `json:"aud"`
+		AuthorizedParty string `json:"azp"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&info); err != nil {
+		return err
+	}
+	if info.Audience != p.ClientKey && info.AuthorizedParty != p.ClientKey {
+		return fmt.Errorf("%s: %w: %q", p.providerName, goth.ErrTokenAudience, info.Audience)
+	}
+	return nil
+}
+
+func (p *Provider) fetchUser(ctx context.Context, sess *Session) (goth.User, error) {
 	user := goth.User{
 		AccessToken:  sess.AccessToken,
 		Provider:     p.Name(),
@@ -100,7 +188,11 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	response, err := p.Client().Get(endpointProfile + "?access_token=" + url.QueryEscape(sess.AccessToken))
+	req, err := http.NewRequestWithContext(ctx, "GET", endpointProfile+"?access_token="+url.QueryEscape(sess.AccessToken), nil)
+	if err != nil {
+		return user, err
+	}
+	response, err := p.Client().Do(req)
 	if err != nil {
 		return user, err
 	}
@@ -127,13 +219,30 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	user.NickName = u.Name
 	user.Email = u.Email
 	user.AvatarURL = u.Picture
+	user.AvatarURLs = avatarURLs(u.Picture)
 	user.UserID = u.ID
 	// Google provides other useful fields such as 'hd'; get them from RawData
 	if err := json.Unmarshal(responseBytes, &user.RawData); err != nil {
 		return user, err
 	}
 
-	return user, nil
+	return user, p.checkHostedDomain(u.HD)
+}
+
+// pictureSize matches the size option ending the URLs of Google profile
+// pictures, e.g. "=s96-c".
+var pictureSize = regexp.MustCompile(`=s\d+(-c)?$`)
+
+// avatarURLs returns the sizes of a profile picture, which Google resizes to
+// the width given by its size option, or serves as uploaded with "=s0".
+func avatarURLs(picture string) map[string]string {
+	if !pictureSize.MatchString(picture) {
+		return nil
+	}
+	sized := func(option string) string {
+		return pictureSize.ReplaceAllString(picture, option)
+	}
+	return goth.NewAvatarURLs(sized("=s48-c"), sized("=s200-c"), sized("=s512-c"), sized("=s0"))
 }
 
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
@@ -160,13 +269,12 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
 
 // SetPrompt sets the prompt values for the google OAuth call. Use this to
@@ -182,14 +290,28 @@ func (p *Provider) SetPrompt(prompt ...string) {
 
 // SetHostedDomain sets the hd parameter for google OAuth call.
 // Use this to force user to pick user from specific hosted domain.
+// As the parameter can be removed from the URL by the user, FetchUser also
+// rejects users of other domains with goth.ErrUserNotAllowed. "*" allows any
+// Google Workspace domain, but no consumer accounts.
 // See https://developers.google.com/identity/protocols/oauth2/openid-connect#hd-param
 func (p *Provider) SetHostedDomain(hd string) {
 	if hd == "" {
 		return
 	}
+	p.hostedDomain = hd
 	p.authCodeOptions = append(p.authCodeOptions, oauth2.SetAuthURLParam("hd", hd))
 }
 
+func (p *Provider) checkHostedDomain(hd string) error {
+	if p.hostedDomain == "" || (hd != "" && (p.hostedDomain == "*" || strings.EqualFold(hd, p.hostedDomain))) {
+		return nil
+	}
+	if hd == "" {
+		return fmt.Errorf("%w: the account doesn't belong to a Google Workspace domain", goth.ErrUserNotAllowed)
+	}
+	return fmt.Errorf("%w: the account belongs to the %q domain", goth.ErrUserNotAllowed, hd)
+}
+
 // SetLoginHint sets the login_hint parameter for the Google OAuth call.
 // Use this to prompt the user to log in with a specific account.
 // See https://developers.google.com/identity/protocols/oauth2/openid-connect#login-hint
@@ -209,3 +331,26 @@ func (p *Provider) SetAccessType(at string) {
 	}
 	p.authCodeOptions = append(p.authCodeOptions, oauth2.SetAuthURLParam("access_type", at))
 }
+
+// RevokeToken revokes the access or refresh token. Revoking either one revokes
+// the whole grant, along with the tokens issued for it.
+// See https://developers.google.com/identity/protocols/oauth2/web-server#tokenrevoke
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointRevoke, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to revoke the token", p.providerName, response.StatusCode)
+	}
+	return nil
+}
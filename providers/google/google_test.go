@@ -1,12 +1,25 @@
 package google_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/google"
+	"github.com/markbates/goth/providertest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,6 +46,21 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "state=test_state")
 	a.Contains(s.AuthURL, "scope=email")
 	a.Contains(s.AuthURL, "access_type=offline")
+	a.NotContains(s.AuthURL, "code_challenge")
+}
+
+func Test_BeginAuthWithPKCE(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := googleProvider()
+	provider.SetPKCE(true)
+	session, err := provider.BeginAuth("test_state")
+	s := session.(*google.Session)
+	a.NoError(err)
+	a.NotEmpty(s.CodeVerifier)
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+	a.Contains(s.AuthURL, "access_type=offline")
 }
 
 func Test_BeginAuthWithPrompt(t *testing.T) {
@@ -115,6 +143,181 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(session.AccessToken, "1234567890")
 }
 
+func Test_FetchUserFromIDToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	pub, err := jwk.New(&key.PublicKey)
+	a.NoError(err)
+	a.NoError(pub.Set(jwk.KeyIDKey, "kid1"))
+	set := jwk.NewSet()
+	set.Add(pub)
+	certs, err := json.Marshal(set)
+	a.NoError(err)
+
+	p := google.New("client-id", "secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		if req.URL.String() != "https://www.googleapis.com/oauth2/v3/certs" {
+			return nil, fmt.Errorf("unexpected request to %s", req.URL)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(certs)),
+		}, nil
+	})}
+
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "kid1"
+		s, err := token.SignedString(key)
+		a.NoError(err)
+		return s
+	}
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":         "https://accounts.google.com",
+			"aud":         "client-id",
+			"sub":         "1234",
+			"email":       "homer@example.com",
+			"name":        "Homer Simpson",
+			"given_name":  "Homer",
+			"family_name": "Simpson",
+			"exp":         time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	idToken := sign(claims())
+	user, err := p.FetchUserFromIDToken(context.Background(), idToken)
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("Homer", user.FirstName)
+	a.Equal("Simpson", user.LastName)
+	a.Equal(idToken, user.IDToken)
+	a.Empty(user.AccessToken)
+
+	wrongIssuer := claims()
+	wrongIssuer["iss"] = "https://evil.example.com"
+	_, err = p.FetchUserFromIDToken(context.Background(), sign(wrongIssuer))
+	a.Error(err)
+
+	wrongAudience := claims()
+	wrongAudience["aud"] = "someone-else"
+	_, err = p.FetchUserFromIDToken(context.Background(), sign(wrongAudience))
+	a.Error(err)
+
+	expired := claims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, err = p.FetchUserFromIDToken(context.Background(), sign(expired))
+	a.Error(err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, claims())
+	forged.Header["kid"] = "kid1"
+	forgedToken, err := forged.SignedString(otherKey)
+	a.NoError(err)
+	_, err = p.FetchUserFromIDToken(context.Background(), forgedToken)
+	a.Error(err)
+}
+
+func Test_FetchUserHostedDomain(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	hd := ""
+	p := google.New("client-id", "secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		body := fmt.Sprintf(`{"id":"1234","email":"homer@example.com","hd":%q}`, hd)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+	session := &google.Session{AccessToken: "token"}
+
+	_, err := p.FetchUser(session)
+	a.NoError(err)
+
+	p.SetHostedDomain("example.com")
+	_, err = p.FetchUser(session)
+	a.ErrorIs(err, goth.ErrUserNotAllowed)
+
+	hd = "example.com"
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+
+	hd = "evil.com"
+	_, err = p.FetchUser(session)
+	a.ErrorIs(err, goth.ErrUserNotAllowed)
+}
+
+func Test_FetchUserWithToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	audience := "client-id"
+	p := google.New("client-id", "secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("mobile", req.URL.Query().Get("access_token"))
+		body := `{"id":"1234","email":"homer@example.com"}`
+		if req.URL.Path == "/tokeninfo" {
+			body = fmt.Sprintf(`{"aud":%q,"azp":%q,"sub":"1234"}`, audience, audience)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	user, err := p.FetchUserWithToken(context.Background(), "mobile")
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+
+	audience = "another-client-id"
+	_, err = p.FetchUserWithToken(context.Background(), "mobile")
+	a.ErrorIs(err, goth.ErrTokenAudience)
+}
+
+func Test_WithCallbackURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := google.New("client-id", "secret", "https://example.com/auth/google/callback")
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.NoError(req.ParseForm())
+		a.Equal("https://example.org/auth/google/callback", req.PostForm.Get("redirect_uri"))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"access_token":"token","token_type":"Bearer","expires_in":3600}`)),
+		}, nil
+	})}
+
+	c := p.WithCallbackURL("https://example.org/auth/google/callback").(*google.Provider)
+	a.Equal("https://example.com/auth/google/callback", p.CallbackURL)
+	a.Equal("https://example.org/auth/google/callback", c.CallbackURL)
+
+	session, err := c.BeginAuth("state")
+	a.NoError(err)
+	authURL, err := session.GetAuthURL()
+	a.NoError(err)
+	a.Contains(authURL, "redirect_uri="+url.QueryEscape("https://example.org/auth/google/callback"))
+
+	_, err = session.Authorize(c, url.Values{"code": {"code"}})
+	a.NoError(err)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 func googleProvider() *google.Provider {
 	return google.New(os.Getenv("GOOGLE_KEY"), os.Getenv("GOOGEL_SECRET"), "/foo")
 }
+
+func Test_Conformance(t *testing.T) {
+	providertest.RunConformance(t, google.New("key", "secret", "/foo"))
+}
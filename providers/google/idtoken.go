@@ -0,0 +1,106 @@
+package google
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/markbates/goth"
+)
+
+const (
+	endpointCerts string = "https://www.googleapis.com/oauth2/v3/certs"
+
+	// certsMinRefreshInterval is the minimum time Google's keys are cached
+	// for, regardless of the cache headers they are served with.
+	certsMinRefreshInterval = 15 * time.Minute
+)
+
+// issuers are the values Google sets the iss claim of ID tokens to.
+var issuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// ValidateIDToken verifies the signature of an ID token issued by Google, e.g.
+// the credential Google One Tap or the Sign In With Google button hand to the
+// page, against Google's public keys. It checks that the token was issued by
+// Google for this client and hasn't expired, and returns its claims.
+// See https://developers.google.com/identity/gsi/web/guides/verify-google-id-token
+func (p *Provider) ValidateIDToken(ctx context.Context, idToken string) (jwt.MapClaims, error) {
+	if idToken == "" {
+		return nil, errors.New("no id_token to validate")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return p.verificationKey(ctx, token)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithAudience(p.ClientKey),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(goth.Now),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	iss, _ := claims.GetIssuer()
+	if iss != issuers[0] && iss != issuers[1] {
+		return nil, fmt.Errorf("id_token was issued by %q, not Google", iss)
+	}
+	return claims, nil
+}
+
+// FetchUserFromIDToken validates idToken with ValidateIDToken and returns the
+// user it was issued for, without going through the OAuth2 flow. The user has
+// no access token, so the Google APIs can't be called on their behalf.
+func (p *Provider) FetchUserFromIDToken(ctx context.Context, idToken string) (goth.User, error) {
+	claims, err := p.ValidateIDToken(ctx, idToken)
+	if err != nil {
+		return goth.User{}, err
+	}
+	hd, _ := claims["hd"].(string)
+	if err := p.checkHostedDomain(hd); err != nil {
+		return goth.User{}, err
+	}
+
+	str := func(name string) string {
+		s, _ := claims[name].(string)
+		return s
+	}
+	user := goth.User{
+		Provider:  p.Name(),
+		UserID:    str("sub"),
+		Email:     str("email"),
+		Name:      str("name"),
+		FirstName: str("given_name"),
+		LastName:  str("family_name"),
+		AvatarURL: str("picture"),
+		IDToken:   idToken,
+		RawData:   claims,
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		user.ExpiresAt = exp.Time
+	}
+	return user, nil
+}
+
+func (p *Provider) verificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	set, err := p.certs.Fetch(ctx, endpointCerts, p.Client())
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := set.LookupKeyID(kid)
+	if !ok {
+		return nil, fmt.Errorf("could not find key %q to verify id_token", kid)
+	}
+
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
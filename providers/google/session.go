@@ -3,10 +3,13 @@ package google
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Google.
@@ -16,6 +19,25 @@ type Session struct {
 	RefreshToken string
 	ExpiresAt    time.Time
 	IDToken      string
+	CodeVerifier string `json:",omitempty"`
+	// Debug is what was captured while authorizing the session, if the
+	// provider was in debug mode.
+	Debug *goth.DebugInfo `json:",omitempty"`
+}
+
+// DebugInfo implements goth.DebugSession.
+func (s Session) DebugInfo() *goth.DebugInfo {
+	return s.Debug
+}
+
+// client returns the client authorizing the session, which records its
+// requests in Debug if the provider is in debug mode.
+func (s *Session) client(p *Provider) *http.Client {
+	if !p.debug {
+		return p.Client()
+	}
+	s.Debug = &goth.DebugInfo{}
+	return goth.DebugClient(p.Client(), s.Debug)
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the Google provider.
@@ -29,7 +51,11 @@ func (s Session) GetAuthURL() (string, error) {
 // Authorize the session with Google and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
+	var opts []oauth2.AuthCodeOption
+	if s.CodeVerifier != "" {
+		opts = append(opts, goth.PKCEVerifierOption(s.CodeVerifier))
+	}
+	token, err := p.config.Exchange(goth.ContextForClient(s.client(p)), params.Get("code"), opts...)
 	if err != nil {
 		return "", err
 	}
@@ -63,3 +89,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
 	return sess, err
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
@@ -14,6 +14,7 @@ func Test_Implements_Session(t *testing.T) {
 	s := &google.Session{}
 
 	a.Implements((*goth.Session)(nil), s)
+	a.Implements((*goth.TokenSession)(nil), s)
 }
 
 func Test_GetAuthURL(t *testing.T) {
@@ -1,6 +1,7 @@
 package hubspot
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/markbates/goth"
@@ -10,6 +11,8 @@ import (
 	"net/url"
 	"strconv"
 	"time"
+
+	"github.com/markbates/goth/internal/oauth2util"
 )
 
 // These vars define the Authentication and Token URLS for Hubspot.
@@ -71,6 +74,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -92,6 +103,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		AccessToken:  s.AccessToken,
 		Provider:     p.Name(),
 		RefreshToken: s.RefreshToken,
+		ExpiresAt:    s.ExpiresAt,
 	}
 
 	if user.AccessToken == "" {
@@ -122,13 +134,16 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	// Extract the user data we got from Google into our goth.User.
 	user.Email = u.User
 	user.UserID = strconv.Itoa(u.UserID)
-	accessTokenExpiration := time.Now()
-	if u.ExpiresIn > 0 {
-		accessTokenExpiration = accessTokenExpiration.Add(time.Duration(u.ExpiresIn) * time.Second)
-	} else {
-		accessTokenExpiration = accessTokenExpiration.Add(30 * time.Minute)
+	// The expiry of the token response wins over the one of the token info.
+	if user.ExpiresAt.IsZero() {
+		accessTokenExpiration := goth.Now()
+		if u.ExpiresIn > 0 {
+			accessTokenExpiration = accessTokenExpiration.Add(time.Duration(u.ExpiresIn) * time.Second)
+		} else {
+			accessTokenExpiration = accessTokenExpiration.Add(30 * time.Minute)
+		}
+		user.ExpiresAt = accessTokenExpiration
 	}
-	user.ExpiresAt = accessTokenExpiration
 	// Google provides other useful fields such as 'hd'; get them from RawData
 	if err := json.Unmarshal(responseBytes, &user.RawData); err != nil {
 		return user, err
@@ -163,11 +178,10 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
@@ -2,8 +2,13 @@ package hubspot_test
 
 import (
 	"github.com/markbates/goth/providers/hubspot"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/markbates/goth"
 	"github.com/stretchr/testify/assert"
@@ -48,6 +53,40 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AccessToken, "1234567890")
 }
 
+func Test_ExpiresAt(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		body := `{"user":"homer@example.com","user_id":42,"expires_in":60}`
+		if req.URL.String() == hubspot.TokenURL {
+			body = `{"access_token":"token","token_type":"bearer","expires_in":1800}`
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	})}
+
+	s := &hubspot.Session{}
+	_, err := s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.WithinDuration(time.Now().Add(30*time.Minute), s.ExpiresAt, time.Minute)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("42", user.UserID)
+	a.Equal(s.ExpiresAt, user.ExpiresAt)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 func provider() *hubspot.Provider {
 	return hubspot.New(os.Getenv("HUBSPOT_KEY"), os.Getenv("HUBSPOT_SECRET"), "/foo")
 }
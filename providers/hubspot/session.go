@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/markbates/goth"
 	"strings"
+	"time"
 )
 
 // Session stores data during the auth process with Hubspot.
@@ -12,6 +13,7 @@ type Session struct {
 	AuthURL      string
 	AccessToken  string
 	RefreshToken string
+	ExpiresAt    time.Time
 }
 
 var _ goth.Session = &Session{}
@@ -39,6 +41,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
+	s.ExpiresAt = token.Expiry
 	return token.AccessToken, err
 }
 
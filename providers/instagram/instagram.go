@@ -1,27 +1,47 @@
-// Package instagram implements the OAuth2 protocol for authenticating users through Instagram.
-// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+// Package instagram implements the OAuth2 protocol for authenticating users through Instagram,
+// using the Instagram API with Instagram Login.
+// See https://developers.facebook.com/docs/instagram-platform/instagram-api-with-instagram-login
+//
+// The short-lived access token obtained from the authorization code is exchanged
+// for a long-lived one, valid for 60 days. Instagram has no refresh tokens: the
+// long-lived access token itself is refreshed, so RefreshToken expects it and
+// goth.User.RefreshToken holds it as well.
 package instagram
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
 var (
-	authURL         = "https://api.instagram.com/oauth/authorize/"
+	authURL         = "https://www.instagram.com/oauth/authorize"
 	tokenURL        = "https://api.instagram.com/oauth/access_token"
-	endPointProfile = "https://api.instagram.com/v1/users/self/"
+	longLivedURL    = "https://graph.instagram.com/access_token"
+	refreshURL      = "https://graph.instagram.com/refresh_access_token"
+	endPointProfile = "https://graph.instagram.com/me"
 )
 
+// profileFields are the fields of the user fetched from the profile endpoint.
+const profileFields = "id,username,account_type"
+
+// ScopeBusinessBasic is required to sign in and is always requested.
+const ScopeBusinessBasic = "instagram_business_basic"
+
+// ScopeSeparator is the separator Instagram expects between scopes.
+const ScopeSeparator = ","
+
 // New creates a new Instagram provider, and sets up important connection details.
 // You should always call `instagram.New` to get a new Provider. Never try to craete
 // one manually.
@@ -57,6 +77,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -77,8 +105,10 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	sess := session.(*Session)
 	user := goth.User{
-		AccessToken: sess.AccessToken,
-		Provider:    p.Name(),
+		AccessToken:  sess.AccessToken,
+		RefreshToken: sess.AccessToken,
+		ExpiresAt:    sess.ExpiresAt,
+		Provider:     p.Name(),
 	}
 
 	if user.AccessToken == "" {
@@ -86,8 +116,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	response, err := p.Client().Get(endPointProfile + "?access_token=" + url.QueryEscape(sess.AccessToken))
-
+	response, err := p.Client().Get(endPointProfile + "?fields=" + profileFields + "&access_token=" + url.QueryEscape(sess.AccessToken))
 	if err != nil {
 		return user, err
 	}
@@ -111,29 +140,15 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 
 func userFromReader(reader io.Reader, user *goth.User) error {
 	u := struct {
-		Data struct {
-			ID             string `json:"id"`
-			UserName       string `json:"username"`
-			FullName       string `json:"full_name"`
-			ProfilePicture string `json:"profile_picture"`
-			Bio            string `json:"bio"`
-			Website        string `json:"website"`
-			Counts         struct {
-				Media      int `json:"media"`
-				Follows    int `json:"follows"`
-				FollowedBy int `json:"followed_by"`
-			} `json:"counts"`
-		} `json:"data"`
+		ID       string `json:"id"`
+		UserName string `json:"username"`
 	}{}
 	err := json.NewDecoder(reader).Decode(&u)
 	if err != nil {
 		return err
 	}
-	user.UserID = u.Data.ID
-	user.Name = u.Data.FullName
-	user.NickName = u.Data.UserName
-	user.AvatarURL = u.Data.ProfilePicture
-	user.Description = u.Data.Bio
+	user.UserID = u.ID
+	user.NickName = u.UserName
 	return err
 }
 
@@ -143,15 +158,16 @@ func newConfig(p *Provider, scopes []string) *oauth2.Config {
 		ClientSecret: p.Secret,
 		RedirectURL:  p.CallbackURL,
 		Endpoint: oauth2.Endpoint{
-			AuthURL:  authURL,
-			TokenURL: tokenURL,
+			AuthURL:   authURL,
+			TokenURL:  tokenURL,
+			AuthStyle: oauth2.AuthStyleInParams,
 		},
 		Scopes: []string{
-			"basic",
+			ScopeBusinessBasic,
 		},
 	}
 	defaultScopes := map[string]struct{}{
-		"basic": {},
+		ScopeBusinessBasic: {},
 	}
 
 	for _, scope := range scopes {
@@ -160,15 +176,109 @@ func newConfig(p *Provider, scopes []string) *oauth2.Config {
 		}
 	}
 
+	c.Scopes = goth.JoinScopes(ScopeSeparator, c.Scopes...)
 	return c
 }
 
-// RefreshToken refresh token is not provided by instagram
+// exchange trades the authorization code for a short-lived access token.
+// Depending on the API version, the token is either at the top level of the
+// response or wrapped in a data array.
+func (p *Provider) exchange(ctx context.Context, code string) (string, error) {
+	form := url.Values{
+		"client_id":     {p.ClientKey},
+		"client_secret": {p.Secret},
+		"grant_type":    {"authorization_code"},
+		"redirect_uri":  {p.CallbackURL},
+		"code":          {code},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s responded with a %d trying to exchange the authorization code", p.providerName, response.StatusCode)
+	}
+
+	type shortLivedToken struct {
+		AccessToken string `json:"access_token"`
+	}
+	t := struct {
+		shortLivedToken
+		Data []shortLivedToken `json:"data"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.AccessToken == "" && len(t.Data) > 0 {
+		t.AccessToken = t.Data[0].AccessToken
+	}
+	if t.AccessToken == "" {
+		return "", fmt.Errorf("%s did not return an access token", p.providerName)
+	}
+	return t.AccessToken, nil
+}
+
+// longLivedToken calls endpoint, which returns a long-lived access token
+// either in exchange of a short-lived one or of a long-lived one to refresh.
+func (p *Provider) longLivedToken(ctx context.Context, endpoint string, params url.Values) (*oauth2.Token, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to get a long-lived access token", p.providerName, response.StatusCode)
+	}
+
+	t := struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&t); err != nil {
+		return nil, err
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.AccessToken,
+	}
+	if t.ExpiresIn > 0 {
+		token.Expiry = goth.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// RefreshToken refreshes a long-lived access token, which must be at least
+// 24 hours old and not expired. The new token is valid for another 60 days.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	return nil, errors.New("Refresh token is not provided by instagram")
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return p.longLivedToken(ctx, refreshURL, url.Values{
+		"grant_type":   {"ig_refresh_token"},
+		"access_token": {refreshToken},
+	})
 }
 
-// RefreshTokenAvailable refresh token is not provided by instagram
+// RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
-	return false
+	return true
 }
@@ -2,8 +2,13 @@ package instagram_test
 
 import (
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/instagram"
@@ -32,10 +37,68 @@ func Test_BeginAuth(t *testing.T) {
 	session, err := provider.BeginAuth("test_state")
 	s := session.(*instagram.Session)
 	a.NoError(err)
-	a.Contains(s.AuthURL, "api.instagram.com/oauth/authorize/")
+	a.Contains(s.AuthURL, "www.instagram.com/oauth/authorize")
 	a.Contains(s.AuthURL, fmt.Sprintf("client_id=%s", os.Getenv("INSTAGRAM_KEY")))
 	a.Contains(s.AuthURL, "state=test_state")
-	a.Contains(s.AuthURL, "scope=basic")
+	a.Contains(s.AuthURL, "scope=instagram_business_basic%2Cinstagram_business_content_publish")
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := instagram.New("key", "secret", "/foo")
+	provider.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Host + req.URL.Path {
+		case "api.instagram.com/oauth/access_token":
+			a.NoError(req.ParseForm())
+			a.Equal("authorization_code", req.PostForm.Get("grant_type"))
+			a.Equal("code", req.PostForm.Get("code"))
+			return response(`{"data":[{"access_token":"short","user_id":"1","permissions":"instagram_business_basic"}]}`), nil
+		case "graph.instagram.com/access_token":
+			a.Equal("ig_exchange_token", req.URL.Query().Get("grant_type"))
+			a.Equal("short", req.URL.Query().Get("access_token"))
+			return response(`{"access_token":"long","token_type":"bearer","expires_in":5183944}`), nil
+		case "graph.instagram.com/me":
+			a.Equal("id,username,account_type", req.URL.Query().Get("fields"))
+			a.Equal("long", req.URL.Query().Get("access_token"))
+			return response(`{"id":"1","username":"homer","account_type":"BUSINESS"}`), nil
+		}
+		t.Fatalf("unexpected request to %s", req.URL)
+		return nil, nil
+	})}
+
+	session := &instagram.Session{}
+	token, err := session.Authorize(provider, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Equal("long", token)
+	a.WithinDuration(time.Now().Add(60*24*time.Hour), session.ExpiresAt, 24*time.Hour)
+
+	user, err := provider.FetchUser(session)
+	a.NoError(err)
+	a.Equal("1", user.UserID)
+	a.Equal("homer", user.NickName)
+	a.Equal("BUSINESS", user.RawData["account_type"])
+	a.Equal("long", user.RefreshToken)
+}
+
+func Test_RefreshToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := instagram.New("key", "secret", "/foo")
+	provider.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("graph.instagram.com/refresh_access_token", req.URL.Host+req.URL.Path)
+		a.Equal("ig_refresh_token", req.URL.Query().Get("grant_type"))
+		a.Equal("long", req.URL.Query().Get("access_token"))
+		return response(`{"access_token":"refreshed","token_type":"bearer","expires_in":5183944}`), nil
+	})}
+
+	a.True(provider.RefreshTokenAvailable())
+	token, err := provider.RefreshToken("long")
+	a.NoError(err)
+	a.Equal("refreshed", token.AccessToken)
+	a.Equal("refreshed", token.RefreshToken)
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -44,13 +107,23 @@ func Test_SessionFromJSON(t *testing.T) {
 
 	provider := instagramProvider()
 
-	s, err := provider.UnmarshalSession(`{"AuthURL":"https://api.instagram.com/oauth/authorize/","AccessToken":"1234567890"}`)
+	s, err := provider.UnmarshalSession(`{"AuthURL":"https://www.instagram.com/oauth/authorize","AccessToken":"1234567890"}`)
 	a.NoError(err)
 	session := s.(*instagram.Session)
-	a.Equal(session.AuthURL, "https://api.instagram.com/oauth/authorize/")
+	a.Equal(session.AuthURL, "https://www.instagram.com/oauth/authorize")
 	a.Equal(session.AccessToken, "1234567890")
 }
 
 func instagramProvider() *instagram.Provider {
-	return instagram.New(os.Getenv("INSTAGRAM_KEY"), os.Getenv("INSTAGRAM_SECRET"), "/foo", "basic")
+	return instagram.New(os.Getenv("INSTAGRAM_KEY"), os.Getenv("INSTAGRAM_SECRET"), "/foo", "instagram_business_content_publish")
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func response(body string) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}
 }
@@ -1,17 +1,22 @@
 package instagram
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Instagram
 type Session struct {
 	AuthURL     string
 	AccessToken string
+	ExpiresAt   time.Time
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the Instagram provider.
@@ -23,9 +28,21 @@ func (s Session) GetAuthURL() (string, error) {
 }
 
 // Authorize the session with Instagram and return the access token to be stored for future use.
+// The short-lived access token is exchanged for a long-lived one right away.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
+	ctx := context.Background()
+
+	shortLived, err := p.exchange(ctx, params.Get("code"))
+	if err != nil {
+		return "", err
+	}
+
+	token, err := p.longLivedToken(ctx, longLivedURL, url.Values{
+		"grant_type":    {"ig_exchange_token"},
+		"client_secret": {p.Secret},
+		"access_token":  {shortLived},
+	})
 	if err != nil {
 		return "", err
 	}
@@ -35,9 +52,25 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	}
 
 	s.AccessToken = token.AccessToken
+	s.ExpiresAt = token.Expiry
 	return token.AccessToken, err
 }
 
+// Token returns the long-lived access token, which is also used to refresh it.
+func (s *Session) Token() *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  s.AccessToken,
+		RefreshToken: s.AccessToken,
+		Expiry:       s.ExpiresAt,
+	}
+}
+
+// SetToken replaces the token held by the session.
+func (s *Session) SetToken(token *oauth2.Token) {
+	s.AccessToken = token.AccessToken
+	s.ExpiresAt = token.Expiry
+}
+
 // Marshal the session into a string
 func (s Session) Marshal() string {
 	b, _ := json.Marshal(s)
@@ -0,0 +1,406 @@
+// Package oauth1 implements the consumer side of OAuth 1.0a (RFC 5849) shared
+// by the OAuth1 providers: obtaining a request token, building the
+// authorization URL, exchanging the verifier for an access token and signing
+// API requests with HMAC-SHA1 or RSA-SHA1.
+//
+// Tokens are the types of github.com/mrjones/oauth, which the providers'
+// sessions have always been serialized with.
+package oauth1
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/mrjones/oauth"
+)
+
+// Endpoint holds the URLs of an OAuth 1.0a service provider.
+type Endpoint struct {
+	RequestTokenURL string
+	AuthorizeURL    string
+	AccessTokenURL  string
+}
+
+// Consumer talks to an OAuth 1.0a service provider on behalf of an application.
+type Consumer struct {
+	Key    string
+	Secret string
+	// PrivateKey switches the signature method to RSA-SHA1 when set,
+	// otherwise requests are signed with HMAC-SHA1 using Secret.
+	PrivateKey *rsa.PrivateKey
+	Endpoint   Endpoint
+	// TokenMethod is the HTTP method of the request and access token
+	// requests. It defaults to GET.
+	TokenMethod string
+	// Header is added to every request made by the consumer.
+	Header http.Header
+	// Client returns the HTTP client to use, typically the provider's Client
+	// method. http.DefaultClient is used if it is nil.
+	Client func() *http.Client
+	// Debug logs the responses of the token endpoints and the signature base
+	// strings to the logger set with goth.SetLogger, with their tokens and
+	// secrets redacted.
+	Debug bool
+
+	// now and nonce are replaced in tests.
+	now   func() time.Time
+	nonce func() (string, error)
+}
+
+// NewConsumer returns a Consumer signing with HMAC-SHA1.
+func NewConsumer(key, secret string, endpoint Endpoint, client func() *http.Client) *Consumer {
+	return &Consumer{
+		Key:      key,
+		Secret:   secret,
+		Endpoint: endpoint,
+		Client:   client,
+	}
+}
+
+// NewRSAConsumer returns a Consumer signing with RSA-SHA1.
+func NewRSAConsumer(key string, privateKey *rsa.PrivateKey, endpoint Endpoint, client func() *http.Client) *Consumer {
+	return &Consumer{
+		Key:        key,
+		PrivateKey: privateKey,
+		Endpoint:   endpoint,
+		Client:     client,
+	}
+}
+
+// RequestToken obtains a request token for callbackURL and returns it along
+// with the URL to send the user to for authorizing it.
+func (c *Consumer) RequestToken(ctx context.Context, callbackURL string) (*oauth.RequestToken, string, error) {
+	values, err := c.tokenRequest(ctx, c.Endpoint.RequestTokenURL, map[string]string{"oauth_callback": callbackURL}, "")
+	if err != nil {
+		return nil, "", err
+	}
+	// without the confirmation, the provider implements OAuth 1.0 which is
+	// open to session fixation, see https://oauth.net/advisories/2009-1/
+	if values.Get("oauth_callback_confirmed") != "true" {
+		return nil, "", errors.New("oauth1: the provider did not confirm the callback URL")
+	}
+
+	token := &oauth.RequestToken{
+		Token:  values.Get("oauth_token"),
+		Secret: values.Get("oauth_token_secret"),
+	}
+	return token, c.AuthorizeURL(token), nil
+}
+
+// AuthorizeURL returns the URL to send the user to for authorizing token.
+func (c *Consumer) AuthorizeURL(token *oauth.RequestToken) string {
+	u, err := url.Parse(c.Endpoint.AuthorizeURL)
+	if err != nil {
+		return c.Endpoint.AuthorizeURL + "?oauth_token=" + url.QueryEscape(token.Token)
+	}
+	q := u.Query()
+	q.Set("oauth_token", token.Token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Verifier returns the oauth_verifier the provider redirected the user back
+// with, after checking that the redirect is for token.
+func Verifier(params goth.Params, token *oauth.RequestToken) (string, error) {
+	if token == nil {
+		return "", errors.New("oauth1: no request token in session")
+	}
+	if t := params.Get("oauth_token"); t != "" && !hmac.Equal([]byte(t), []byte(token.Token)) {
+		return "", errors.New("oauth1: oauth_token does not match the request token")
+	}
+	verifier := params.Get("oauth_verifier")
+	if verifier == "" {
+		return "", errors.New("oauth1: missing oauth_verifier")
+	}
+	return verifier, nil
+}
+
+// AccessToken exchanges the authorized request token and verifier for an
+// access token. Parameters of the response other than the token and its
+// secret are kept in AdditionalData.
+func (c *Consumer) AccessToken(ctx context.Context, token *oauth.RequestToken, verifier string) (*oauth.AccessToken, error) {
+	values, err := c.tokenRequest(ctx, c.Endpoint.AccessTokenURL, map[string]string{
+		"oauth_token":    token.Token,
+		"oauth_verifier": verifier,
+	}, token.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return accessToken(values), nil
+}
+
+// RefreshToken renews an expiring access token using the session handle the
+// provider returned with it, as some providers like Xero require.
+func (c *Consumer) RefreshToken(ctx context.Context, token *oauth.AccessToken) (*oauth.AccessToken, error) {
+	handle, ok := token.AdditionalData["oauth_session_handle"]
+	if !ok {
+		return nil, errors.New("oauth1: missing oauth_session_handle in access token")
+	}
+	values, err := c.tokenRequest(ctx, c.Endpoint.AccessTokenURL, map[string]string{
+		"oauth_token":          token.Token,
+		"oauth_session_handle": handle,
+	}, token.Secret)
+	if err != nil {
+		return nil, err
+	}
+	return accessToken(values), nil
+}
+
+// Get makes a GET request to rawURL with the query params, signed with token.
+func (c *Consumer) Get(ctx context.Context, rawURL string, params map[string]string, token *oauth.AccessToken) (*http.Response, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req, token)
+}
+
+// Do signs req with token and sends it. Form encoded bodies are included in
+// the signature, so they are read and replaced.
+func (c *Consumer) Do(req *http.Request, token *oauth.AccessToken) (*http.Response, error) {
+	if err := c.sign(req, map[string]string{"oauth_token": token.Token}, token.Secret); err != nil {
+		return nil, err
+	}
+	return c.client().Do(req)
+}
+
+func (c *Consumer) client() *http.Client {
+	if c.Client != nil {
+		return goth.HTTPClientWithFallBack(c.Client())
+	}
+	return goth.HTTPClientWithFallBack(nil)
+}
+
+func (c *Consumer) tokenRequest(ctx context.Context, tokenURL string, oauthParams map[string]string, tokenSecret string) (url.Values, error) {
+	method := c.TokenMethod
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, tokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.sign(req, oauthParams, tokenSecret); err != nil {
+		return nil, err
+	}
+
+	res, err := c.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<16))
+	if err != nil {
+		return nil, err
+	}
+	if c.Debug {
+		goth.Logf("oauth1: %s %s responded with a %d: %s", method, tokenURL, res.StatusCode, goth.Redact(string(body)))
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth1: %s responded with a %d", tokenURL, res.StatusCode)
+	}
+
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	if values.Get("oauth_token") == "" {
+		return nil, fmt.Errorf("oauth1: %s did not return an oauth_token", tokenURL)
+	}
+	return values, nil
+}
+
+func accessToken(values url.Values) *oauth.AccessToken {
+	token := &oauth.AccessToken{
+		Token:          values.Get("oauth_token"),
+		Secret:         values.Get("oauth_token_secret"),
+		AdditionalData: map[string]string{},
+	}
+	for k := range values {
+		if k != "oauth_token" && k != "oauth_token_secret" {
+			token.AdditionalData[k] = values.Get(k)
+		}
+	}
+	return token
+}
+
+// sign adds the Authorization header to req, signing the oauth parameters,
+// the query and a form encoded body.
+// See https://tools.ietf.org/html/rfc5849#section-3.4
+func (c *Consumer) sign(req *http.Request, oauthParams map[string]string, tokenSecret string) error {
+	nonce, err := c.newNonce()
+	if err != nil {
+		return err
+	}
+
+	params := map[string]string{
+		"oauth_consumer_key":     c.Key,
+		"oauth_nonce":            nonce,
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        strconv.FormatInt(c.timeNow().Unix(), 10),
+		"oauth_version":          "1.0",
+	}
+	if c.PrivateKey != nil {
+		params["oauth_signature_method"] = "RSA-SHA1"
+	}
+	for k, v := range oauthParams {
+		params[k] = v
+	}
+
+	signed := url.Values{}
+	for k, v := range params {
+		signed.Set(k, v)
+	}
+	for k, vs := range req.URL.Query() {
+		signed[k] = append(signed[k], vs...)
+	}
+	if req.Body != nil && req.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(strings.NewReader(string(body)))
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return err
+		}
+		for k, vs := range form {
+			signed[k] = append(signed[k], vs...)
+		}
+	}
+
+	base := signatureBase(req.Method, req.URL, signed)
+	if c.Debug {
+		goth.Logf("oauth1: signature base string: %s", redactSignatureBase(base))
+	}
+	signature, err := c.signature(base, tokenSecret)
+	if err != nil {
+		return err
+	}
+	params["oauth_signature"] = signature
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	header := make([]string, 0, len(keys))
+	for _, k := range keys {
+		header = append(header, fmt.Sprintf(`%s="%s"`, escape(k), escape(params[k])))
+	}
+
+	for k, vs := range c.Header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Authorization", "OAuth "+strings.Join(header, ", "))
+	return nil
+}
+
+func (c *Consumer) signature(base, tokenSecret string) (string, error) {
+	if c.PrivateKey != nil {
+		hash := sha1.Sum([]byte(base))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, c.PrivateKey, crypto.SHA1, hash[:])
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(sig), nil
+	}
+
+	mac := hmac.New(sha1.New, []byte(escape(c.Secret)+"&"+escape(tokenSecret)))
+	mac.Write([]byte(base))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// signatureBase builds the signature base string.
+// See https://tools.ietf.org/html/rfc5849#section-3.4.1
+func signatureBase(method string, u *url.URL, params url.Values) string {
+	pairs := make([]string, 0, len(params))
+	for k, vs := range params {
+		for _, v := range vs {
+			pairs = append(pairs, escape(k)+"="+escape(v))
+		}
+	}
+	sort.Strings(pairs)
+
+	baseURL := strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + u.EscapedPath()
+	if (u.Scheme == "http" && strings.HasSuffix(u.Host, ":80")) || (u.Scheme == "https" && strings.HasSuffix(u.Host, ":443")) {
+		baseURL = strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Hostname()) + u.EscapedPath()
+	}
+
+	return strings.ToUpper(method) + "&" + escape(baseURL) + "&" + escape(strings.Join(pairs, "&"))
+}
+
+// escape percent-encodes s as required by OAuth, leaving only the unreserved
+// characters as they are.
+// See https://tools.ietf.org/html/rfc5849#section-3.6
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ('A' <= ch && ch <= 'Z') || ('a' <= ch && ch <= 'z') || ('0' <= ch && ch <= '9') ||
+			ch == '-' || ch == '.' || ch == '_' || ch == '~' {
+			b.WriteByte(ch)
+			continue
+		}
+		b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{ch})))
+	}
+	return b.String()
+}
+
+func (c *Consumer) timeNow() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *Consumer) newNonce() (string, error) {
+	if c.nonce != nil {
+		return c.nonce()
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// redactSignatureBase decodes the parameters of a signature base string once,
+// for goth.Redact to find the tokens among them.
+func redactSignatureBase(base string) string {
+	decoded, err := url.PathUnescape(base)
+	if err != nil {
+		return "REDACTED"
+	}
+	return goth.Redact(decoded)
+}
@@ -0,0 +1,150 @@
+package oauth1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/mrjones/oauth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Sign(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// the example of appendix A.5 of the OAuth Core 1.0 specification
+	c := NewConsumer("dpf43f3p2l4k3l03", "kd94hf93k423kf44", Endpoint{}, nil)
+	c.now = func() time.Time { return time.Unix(1191242096, 0) }
+	c.nonce = func() (string, error) { return "kllo9940pd9333jh", nil }
+
+	req, err := http.NewRequest("GET", "http://photos.example.net/photos?file=vacation.jpg&size=original", nil)
+	a.NoError(err)
+	a.NoError(c.sign(req, map[string]string{"oauth_token": "nnch734d00sl2jdk"}, "pfkkdhi9sl3r4s00"))
+	a.Contains(req.Header.Get("Authorization"), `oauth_signature="tR3%2BTy81lMeYAr%2FFid0kMTYa%2FWM%3D"`)
+}
+
+func Test_Escape(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Equal("abcABC123-._~", escape("abcABC123-._~"))
+	a.Equal("%25%20%2B%2F%3D%26%E2%82%AC", escape("% +/=&€"))
+}
+
+func Test_Flow(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	confirmed := "true"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		a.True(strings.HasPrefix(auth, "OAuth "))
+		a.Equal("goth", r.Header.Get("User-Agent"))
+		switch r.URL.Path {
+		case "/request_token":
+			a.Contains(auth, `oauth_callback="http%3A%2F%2Flocalhost%2Fcallback"`)
+			fmt.Fprintf(w, "oauth_token=REQUEST&oauth_token_secret=RSECRET&oauth_callback_confirmed=%s", confirmed)
+		case "/access_token":
+			a.Contains(auth, `oauth_token="REQUEST"`)
+			a.Contains(auth, `oauth_verifier="VERIFIER"`)
+			fmt.Fprint(w, "oauth_token=ACCESS&oauth_token_secret=ASECRET&user_id=1234")
+		case "/me":
+			a.Contains(auth, `oauth_token="ACCESS"`)
+			a.Equal("true", r.URL.Query().Get("include_email"))
+			fmt.Fprint(w, `{"id":"1234"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewConsumer("key", "secret", Endpoint{
+		RequestTokenURL: ts.URL + "/request_token",
+		AuthorizeURL:    ts.URL + "/authorize",
+		AccessTokenURL:  ts.URL + "/access_token",
+	}, ts.Client)
+	c.Header = http.Header{"User-Agent": {"goth"}}
+
+	requestToken, authURL, err := c.RequestToken(context.Background(), "http://localhost/callback")
+	a.NoError(err)
+	a.Equal(&oauth.RequestToken{Token: "REQUEST", Secret: "RSECRET"}, requestToken)
+	a.Equal(ts.URL+"/authorize?oauth_token=REQUEST", authURL)
+
+	_, err = Verifier(url.Values{"oauth_token": {"OTHER"}, "oauth_verifier": {"VERIFIER"}}, requestToken)
+	a.Error(err)
+	verifier, err := Verifier(url.Values{"oauth_token": {"REQUEST"}, "oauth_verifier": {"VERIFIER"}}, requestToken)
+	a.NoError(err)
+
+	accessToken, err := c.AccessToken(context.Background(), requestToken, verifier)
+	a.NoError(err)
+	a.Equal("ACCESS", accessToken.Token)
+	a.Equal("ASECRET", accessToken.Secret)
+	a.Equal(map[string]string{"user_id": "1234"}, accessToken.AdditionalData)
+
+	res, err := c.Get(context.Background(), ts.URL+"/me", map[string]string{"include_email": "true"}, accessToken)
+	a.NoError(err)
+	res.Body.Close()
+	a.Equal(http.StatusOK, res.StatusCode)
+
+	_, err = c.RefreshToken(context.Background(), accessToken)
+	a.Error(err, "refreshing requires a session handle")
+
+	confirmed = "false"
+	_, _, err = c.RequestToken(context.Background(), "http://localhost/callback")
+	a.Error(err)
+}
+
+type bufferLogger struct {
+	lines []string
+}
+
+func (l *bufferLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func Test_DebugIsRedacted(t *testing.T) {
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/request_token":
+			fmt.Fprint(w, "oauth_token=REQUEST&oauth_token_secret=RSECRET&oauth_callback_confirmed=true")
+		case "/access_token":
+			fmt.Fprint(w, "oauth_token=ACCESS&oauth_token_secret=ASECRET&user_id=1234")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	l := &bufferLogger{}
+	goth.SetLogger(l)
+	defer goth.SetLogger(nil)
+
+	c := NewConsumer("key", "secret", Endpoint{
+		RequestTokenURL: ts.URL + "/request_token",
+		AuthorizeURL:    ts.URL + "/authorize",
+		AccessTokenURL:  ts.URL + "/access_token",
+	}, ts.Client)
+	c.Debug = true
+
+	requestToken, _, err := c.RequestToken(context.Background(), "http://localhost/callback")
+	a.NoError(err)
+	_, err = c.AccessToken(context.Background(), requestToken, "VERIFIER")
+	a.NoError(err)
+
+	a.NotEmpty(l.lines)
+	output := strings.Join(l.lines, "\n")
+	a.Contains(output, "signature base string")
+	a.Contains(output, "user_id=1234")
+	for _, secret := range []string{"REQUEST", "RSECRET", "ACCESS", "ASECRET"} {
+		a.NotContains(output, secret)
+	}
+}
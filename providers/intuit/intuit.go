@@ -0,0 +1,259 @@
+// Package intuit implements the OAuth2 and OpenID Connect protocols for
+// authenticating users through Intuit, and for accessing the QuickBooks
+// Online APIs of their companies.
+package intuit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
+)
+
+// See https://developer.intuit.com/app/developer/qbo/docs/develop/authentication-and-authorization/oauth-2.0
+const (
+	authURL           string = "https://appcenter.intuit.com/connect/oauth2"
+	tokenURL          string = "https://oauth.platform.intuit.com/oauth2/v1/tokens/bearer"
+	revokeURL         string = "https://developer.api.intuit.com/v2/oauth2/tokens/revoke"
+	profileURL        string = "https://accounts.platform.intuit.com/v1/openid_connect/userinfo"
+	sandboxProfileURL string = "https://sandbox-accounts.platform.intuit.com/v1/openid_connect/userinfo"
+)
+
+// Scopes of Intuit. ScopeOpenID is required to fetch the user.
+const (
+	ScopeAccounting = "com.intuit.quickbooks.accounting"
+	ScopePayment    = "com.intuit.quickbooks.payment"
+	ScopeOpenID     = "openid"
+	ScopeProfile    = "profile"
+	ScopeEmail      = "email"
+	ScopePhone      = "phone"
+	ScopeAddress    = "address"
+)
+
+// RawDataRealmID is the key of the ID of the QuickBooks company the user
+// connected in User.RawData. It is required by every call to the QuickBooks
+// Online API.
+const RawDataRealmID = "realmId"
+
+// Provider is the implementation of `goth.Provider` for accessing Intuit.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+	profileURL   string
+	revokeURL    string
+}
+
+// New creates a new Intuit provider and sets up important connection details.
+// The scopes default to ScopeAccounting, ScopeOpenID, ScopeProfile and
+// ScopeEmail. You should always call `intuit.New` to get a new Provider. Never
+// try to create one manually.
+func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	return NewCustomisedURL(clientKey, secret, callbackURL, authURL, tokenURL, profileURL, scopes...)
+}
+
+// NewCustomisedURL is similar to New(...) but can be used to set custom URLs to connect to
+func NewCustomisedURL(clientKey, secret, callbackURL, authURL, tokenURL, profileURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "intuit",
+		profileURL:   profileURL,
+		revokeURL:    revokeURL,
+	}
+	p.config = newConfig(p, authURL, tokenURL, scopes)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
+// Client is HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the intuit package.
+func (p *Provider) Debug(debug bool) {}
+
+// SetSandbox makes FetchUser use the UserInfo endpoint of the sandbox, which
+// apps must use with the development keys of the Intuit developer portal.
+func (p *Provider) SetSandbox(sandbox bool) {
+	if sandbox {
+		p.profileURL = sandboxProfileURL
+	} else {
+		p.profileURL = profileURL
+	}
+}
+
+// BeginAuth asks Intuit for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state),
+	}, nil
+}
+
+// FetchUser will go to Intuit and access basic information about the user.
+// The ID of the QuickBooks company the user connected is available in
+// RawData under RawDataRealmID. The user is only fetched from Intuit if the
+// openid scope was requested.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		Provider:     p.Name(),
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+		IDToken:      sess.IDToken,
+		RawData:      map[string]interface{}{},
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	if !p.hasScope(ScopeOpenID) {
+		user.RawData[RawDataRealmID] = sess.RealmID
+		return user, nil
+	}
+
+	req, err := http.NewRequest("GET", p.profileURL, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
+	}
+
+	if err := userFromReader(response.Body, &user); err != nil {
+		return user, err
+	}
+	user.RawData[RawDataRealmID] = sess.RealmID
+	return user, nil
+}
+
+func userFromReader(r io.Reader, user *goth.User) error {
+	if err := json.NewDecoder(r).Decode(&user.RawData); err != nil {
+		return err
+	}
+
+	claim := func(name string) string {
+		s, _ := user.RawData[name].(string)
+		return s
+	}
+	user.UserID = claim("sub")
+	user.Email = claim("email")
+	user.FirstName = claim("givenName")
+	user.LastName = claim("familyName")
+	user.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if address, ok := user.RawData["address"].(map[string]interface{}); ok {
+		user.Location, _ = address["locality"].(string)
+	}
+	return nil
+}
+
+func (p *Provider) hasScope(scope string) bool {
+	for _, s := range p.config.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   authURL,
+			TokenURL:  tokenURL,
+			AuthStyle: oauth2.AuthStyleInHeader,
+		},
+		Scopes: []string{},
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = []string{ScopeAccounting, ScopeOpenID, ScopeProfile, ScopeEmail}
+	}
+	return c
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token. Intuit
+// rotates refresh tokens, so the one of the returned token must be stored
+// in place of the previous one.
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
+}
+
+// RevokeToken revokes the access or refresh token, which disconnects the app
+// from the QuickBooks company.
+// See https://developer.intuit.com/app/developer/qbo/docs/develop/authentication-and-authorization/oauth-2.0#revoke-token-disconnect
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	body, _ := json.Marshal(map[string]string{"token": token})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.revokeURL, strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.ClientKey, p.Secret)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to revoke the token", p.providerName, response.StatusCode)
+	}
+	return nil
+}
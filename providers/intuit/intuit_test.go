@@ -0,0 +1,106 @@
+package intuit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/intuit"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, os.Getenv("INTUIT_KEY"))
+	a.Equal(p.Secret, os.Getenv("INTUIT_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+	a.Equal("intuit", p.Name())
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+	a.Implements((*goth.TokenRevoker)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+	session, err := p.BeginAuth("test_state")
+	s := session.(*intuit.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "appcenter.intuit.com/connect/oauth2")
+	u, err := url.Parse(s.AuthURL)
+	a.NoError(err)
+	a.Equal("com.intuit.quickbooks.accounting openid profile email", u.Query().Get("scope"))
+	a.Equal("test_state", u.Query().Get("state"))
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://appcenter.intuit.com/connect/oauth2","AccessToken":"1234567890","RealmID":"4620816365"}`)
+	a.NoError(err)
+
+	s := session.(*intuit.Session)
+	a.Equal(s.AuthURL, "https://appcenter.intuit.com/connect/oauth2")
+	a.Equal(s.AccessToken, "1234567890")
+	a.Equal(s.RealmID, "4620816365")
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			user, _, _ := r.BasicAuth()
+			a.Equal("key", user)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","token_type":"bearer","expires_in":3600,"x_refresh_token_expires_in":8726400}`))
+		case "/userinfo":
+			a.Equal("Bearer access", r.Header.Get("Authorization"))
+			w.Write([]byte(`{"sub":"1182d6ec","email":"homer@example.com","emailVerified":true,"givenName":"Homer","familyName":"Simpson","address":{"locality":"Springfield"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	p := intuit.NewCustomisedURL("key", "secret", "/foo", ts.URL+"/authorize", ts.URL+"/token", ts.URL+"/userinfo")
+	session, err := p.BeginAuth("state")
+	a.NoError(err)
+	_, err = session.Authorize(p, url.Values{"code": {"code"}, "state": {"state"}, "realmId": {"4620816365"}})
+	a.NoError(err)
+
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("1182d6ec", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("Springfield", user.Location)
+	a.Equal("refresh", user.RefreshToken)
+	a.Equal("4620816365", user.RawData[intuit.RawDataRealmID])
+
+	// without the openid scope only the company is known
+	p = intuit.NewCustomisedURL("key", "secret", "/foo", ts.URL+"/authorize", ts.URL+"/token", ts.URL+"/userinfo", intuit.ScopeAccounting)
+	user, err = p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("", user.UserID)
+	a.Equal("4620816365", user.RawData[intuit.RawDataRealmID])
+}
+
+func provider() *intuit.Provider {
+	return intuit.New(os.Getenv("INTUIT_KEY"), os.Getenv("INTUIT_SECRET"), "/foo")
+}
@@ -0,0 +1,246 @@
+// Package jellyfin implements the username and password authentication of
+// self-hosted Jellyfin media servers.
+//
+// Jellyfin has no authorization server to redirect the user to. BeginAuth
+// returns a session whose auth URL is the callback URL carrying the state as
+// a query parameter, where the application shows a sign in form posting
+// "username", "password" and "state" back to the callback. Authorize then
+// authenticates the user with the server, getting an access token for the
+// Jellyfin API.
+// See https://api.jellyfin.org
+package jellyfin
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+const (
+	endpointAuthenticate string = "/Users/AuthenticateByName"
+	endpointProfile      string = "/Users/Me"
+)
+
+// clientVersion is the version reported to the server, which requires one.
+const clientVersion = "1.0.0"
+
+// New creates a new Jellyfin provider for the server at serverURL, e.g.
+// "https://jellyfin.example.com".
+func New(serverURL, callbackURL string) *Provider {
+	return &Provider{
+		ServerURL:    strings.TrimSuffix(serverURL, "/"),
+		CallbackURL:  callbackURL,
+		ClientName:   "goth",
+		providerName: "jellyfin",
+	}
+}
+
+// Provider is the implementation of `goth.Provider` for accessing Jellyfin.
+type Provider struct {
+	ServerURL   string
+	CallbackURL string
+	// ClientName is the name of the application the server lists the
+	// sessions of the users under.
+	ClientName   string
+	HTTPClient   *http.Client
+	providerName string
+}
+
+var _ goth.Provider = &Provider{}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	return &c
+}
+
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the jellyfin package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth returns a session whose auth URL is the callback URL with the
+// "state" query parameter. Each session gets its own device ID, as the server
+// revokes the previous token of a user signing in again from the same
+// device.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	u, err := url.Parse(p.CallbackURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("state", state)
+	u.RawQuery = q.Encode()
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		AuthURL:  u.String(),
+		DeviceID: hex.EncodeToString(b),
+	}, nil
+}
+
+// authenticate signs the user in with their username and password, and
+// returns their access token.
+func (p *Provider) authenticate(deviceID, username, password string) (string, error) {
+	body, err := json.Marshal(map[string]string{"Username": username, "Pw": password})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", p.ServerURL+endpointAuthenticate, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", p.authorization(deviceID, ""))
+
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return "", errors.New("jellyfin: invalid username or password")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s responded with a %d trying to authenticate the user", p.providerName, resp.StatusCode)
+	}
+
+	result := struct {
+		AccessToken string `json:"AccessToken"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.AccessToken == "" {
+		return "", errors.New("jellyfin: no access token in the authentication response")
+	}
+	return result.AccessToken, nil
+}
+
+// FetchUser will go to the Jellyfin server and access basic information about the user.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken: sess.AccessToken,
+		Provider:    p.Name(),
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", p.ServerURL+endpointProfile, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", p.authorization(sess.DeviceID, sess.AccessToken))
+
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	err = p.userFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
+
+func (p *Provider) userFromReader(reader io.Reader, user *goth.User) error {
+	u := struct {
+		ID              string `json:"Id"`
+		Name            string `json:"Name"`
+		PrimaryImageTag string `json:"PrimaryImageTag"`
+		Policy          struct {
+			IsAdministrator bool `json:"IsAdministrator"`
+		} `json:"Policy"`
+	}{}
+
+	err := json.NewDecoder(reader).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = u.ID
+	user.NickName = u.Name
+	user.Name = u.Name
+	if u.PrimaryImageTag != "" {
+		user.AvatarURL = p.ServerURL + "/Users/" + url.PathEscape(u.ID) + "/Images/Primary?tag=" + url.QueryEscape(u.PrimaryImageTag)
+	}
+	if u.Policy.IsAdministrator {
+		user.Roles = []string{"administrator"}
+	}
+
+	return err
+}
+
+// authorization returns the Authorization header identifying the application
+// and, if token is set, the user.
+func (p *Provider) authorization(deviceID, token string) string {
+	if deviceID == "" {
+		deviceID = p.ClientName
+	}
+	fields := []string{
+		fmt.Sprintf("Client=%q", p.ClientName),
+		fmt.Sprintf("Device=%q", p.ClientName),
+		fmt.Sprintf("DeviceId=%q", deviceID),
+		fmt.Sprintf("Version=%q", clientVersion),
+	}
+	if token != "" {
+		fields = append(fields, fmt.Sprintf("Token=%q", token))
+	}
+	return "MediaBrowser " + strings.Join(fields, ", ")
+}
+
+// RefreshTokenAvailable refresh token is not provided by jellyfin
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+// RefreshToken refresh token is not provided by jellyfin
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by jellyfin")
+}
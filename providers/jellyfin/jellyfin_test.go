@@ -0,0 +1,110 @@
+package jellyfin_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/jellyfin"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := jellyfin.New("https://jellyfin.example.com/", "/foo")
+	a.Equal(provider.ServerURL, "https://jellyfin.example.com")
+	a.Equal(provider.CallbackURL, "/foo")
+	a.Equal(provider.ClientName, "goth")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Implements((*goth.Provider)(nil), jellyfin.New("https://jellyfin.example.com", "/foo"))
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := jellyfin.New("https://jellyfin.example.com", "http://localhost/auth/jellyfin/callback")
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*jellyfin.Session)
+	a.Equal("http://localhost/auth/jellyfin/callback?state=test_state", s.AuthURL)
+	a.Len(s.DeviceID, 32)
+
+	other, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	a.NotEqual(s.DeviceID, other.(*jellyfin.Session).DeviceID)
+}
+
+func Test_AuthorizeAndFetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/jellyfin/Users/AuthenticateByName":
+			a.Equal(`MediaBrowser Client="goth", Device="goth", DeviceId="device", Version="1.0.0"`, r.Header.Get("Authorization"))
+			credentials := map[string]string{}
+			a.NoError(json.NewDecoder(r.Body).Decode(&credentials))
+			if credentials["Username"] != "homer" || credentials["Pw"] != "donuts" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"User":{"Id":"abc123","Name":"homer"},"AccessToken":"token","ServerId":"server"}`))
+		case "/jellyfin/Users/Me":
+			a.Equal(`MediaBrowser Client="goth", Device="goth", DeviceId="device", Version="1.0.0", Token="token"`, r.Header.Get("Authorization"))
+			w.Write([]byte(`{"Id":"abc123","Name":"homer","ServerId":"server","PrimaryImageTag":"tag","Policy":{"IsAdministrator":true}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	provider := jellyfin.New(ts.URL+"/jellyfin", "/foo")
+
+	s := &jellyfin.Session{DeviceID: "device"}
+	_, err := s.Authorize(provider, url.Values{"username": {"homer"}, "password": {"beer"}})
+	a.Error(err)
+	_, err = s.Authorize(provider, url.Values{})
+	a.Error(err)
+
+	token, err := s.Authorize(provider, url.Values{"username": {"homer"}, "password": {"donuts"}})
+	a.NoError(err)
+	a.Equal("token", token)
+	a.Equal("token", s.AccessToken)
+
+	user, err := provider.FetchUser(s)
+	a.NoError(err)
+	a.Equal("abc123", user.UserID)
+	a.Equal("homer", user.NickName)
+	a.Equal(ts.URL+"/jellyfin/Users/abc123/Images/Primary?tag=tag", user.AvatarURL)
+	a.Equal([]string{"administrator"}, user.Roles)
+	a.Equal("server", user.RawData["ServerId"])
+
+	_, err = provider.FetchUser(&jellyfin.Session{})
+	a.Error(err)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := jellyfin.New("https://jellyfin.example.com", "/foo")
+
+	s, err := provider.UnmarshalSession(`{"AuthURL":"/foo?state=test_state","DeviceID":"device","AccessToken":"1234567890"}`)
+	a.NoError(err)
+	session := s.(*jellyfin.Session)
+	a.Equal(session.AuthURL, "/foo?state=test_state")
+	a.Equal(session.DeviceID, "device")
+	a.Equal(session.AccessToken, "1234567890")
+}
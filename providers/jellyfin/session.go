@@ -0,0 +1,59 @@
+package jellyfin
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/markbates/goth"
+)
+
+// Session stores data during the auth process with Jellyfin.
+type Session struct {
+	AuthURL     string
+	DeviceID    string
+	AccessToken string
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the Jellyfin provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize signs the user in with the "username" and "password" params, and
+// returns the access token of the user.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	if params.Get("username") == "" {
+		return "", errors.New("jellyfin: no username was given")
+	}
+
+	token, err := p.authenticate(s.DeviceID, params.Get("username"), params.Get("password"))
+	if err != nil {
+		return "", err
+	}
+	s.AccessToken = token
+	return token, nil
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return s.Marshal()
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	sess := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
+	return sess, err
+}
@@ -0,0 +1,39 @@
+package jellyfin_test
+
+import (
+	"testing"
+
+	"github.com/markbates/goth/providers/jellyfin"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetAuthURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	s := &jellyfin.Session{}
+
+	_, err := s.GetAuthURL()
+	a.Error(err)
+
+	s.AuthURL = "/foo"
+
+	url, _ := s.GetAuthURL()
+	a.Equal(url, "/foo")
+}
+
+func Test_ToJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	s := &jellyfin.Session{}
+
+	data := s.Marshal()
+	a.Equal(data, `{"AuthURL":"","DeviceID":"","AccessToken":""}`)
+}
+
+func Test_String(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	s := &jellyfin.Session{}
+
+	a.Equal(s.String(), s.Marshal())
+}
@@ -1,7 +1,13 @@
 // Package linkedin implements the OAuth2 protocol for authenticating users through Linkedin.
+//
+// Apps using "Sign In with LinkedIn using OpenID Connect" should request the
+// openid, profile and email scopes, in which case the user is fetched from the
+// userinfo endpoint. Without the openid scope, the legacy r_liteprofile and
+// r_emailaddress APIs are used, as before.
 package linkedin
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +16,7 @@ import (
 	"net/url"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -25,6 +32,16 @@ const (
 	userEndpoint string = "//api.linkedin.com/v2/me?projection=(id,firstName,lastName,profilePicture(displayImage~:playableStreams))"
 	// emailEndpoint requires scope "r_emailaddress"
 	emailEndpoint string = "//api.linkedin.com/v2/emailAddress?q=members&projection=(elements*(handle~))"
+	// userInfoEndpoint requires scope "openid"
+	// See https://learn.microsoft.com/en-us/linkedin/consumer/integrations/self-serve/sign-in-with-linkedin-v2
+	userInfoEndpoint string = "https://api.linkedin.com/v2/userinfo"
+)
+
+// Scopes of Sign In with LinkedIn using OpenID Connect.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
 )
 
 // New creates a new linkedin provider, and sets up important connection details.
@@ -49,6 +66,7 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+	oidc         bool
 }
 
 // Name is the name used to retrieve this provider later.
@@ -61,6 +79,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 // Client returns an HTTPClientWithFallback
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
@@ -85,6 +111,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		AccessToken: s.AccessToken,
 		Provider:    p.Name(),
 		ExpiresAt:   s.ExpiresAt,
+		IDToken:     s.IDToken,
 	}
 
 	if user.AccessToken == "" {
@@ -92,6 +119,10 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
+	if p.oidc {
+		return p.fetchUserInfo(s.AccessToken, user)
+	}
+
 	// create request for user r_liteprofile
 	req, err := http.NewRequest("GET", "", nil)
 	if err != nil {
@@ -152,6 +183,61 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	return user, err
 }
 
+// fetchUserInfo fetches the user from the OpenID Connect userinfo endpoint.
+func (p *Provider) fetchUserInfo(accessToken string, user goth.User) (goth.User, error) {
+	req, err := http.NewRequest("GET", userInfoEndpoint, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
+	}
+
+	bits, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+
+	if err := json.Unmarshal(bits, &user.RawData); err != nil {
+		return user, err
+	}
+
+	err = userInfoFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
+
+func userInfoFromReader(reader io.Reader, user *goth.User) error {
+	u := struct {
+		Sub        string `json:"sub"`
+		Name       string `json:"name"`
+		GivenName  string `json:"given_name"`
+		FamilyName string `json:"family_name"`
+		Picture    string `json:"picture"`
+		Email      string `json:"email"`
+	}{}
+
+	if err := json.NewDecoder(reader).Decode(&u); err != nil {
+		return err
+	}
+
+	user.UserID = u.Sub
+	user.Name = u.Name
+	user.FirstName = u.GivenName
+	user.LastName = u.FamilyName
+	user.NickName = u.GivenName
+	user.AvatarURL = u.Picture
+	user.Email = u.Email
+	return nil
+}
+
 func userFromReader(reader io.Reader, user *goth.User) error {
 	u := struct {
 		ID        string `json:"id"`
@@ -262,6 +348,9 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 
 	for _, scope := range scopes {
 		c.Scopes = append(c.Scopes, scope)
+		if scope == ScopeOpenID {
+			provider.oidc = true
+		}
 	}
 
 	return c
@@ -2,7 +2,10 @@ package linkedin_test
 
 import (
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -41,6 +44,30 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "scope=r_liteprofile+r_emailaddress&state")
 }
 
+func Test_FetchUser_OpenIDConnect(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := linkedin.New("key", "secret", "/foo", linkedin.ScopeOpenID, linkedin.ScopeProfile, linkedin.ScopeEmail)
+	provider.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("https://api.linkedin.com/v2/userinfo", req.URL.String())
+		a.Equal("Bearer 1234567890", req.Header.Get("Authorization"))
+		body := `{"sub":"782bbtaQ","name":"John Doe","given_name":"John","family_name":"Doe","picture":"https://media.licdn.com/pic.jpg","locale":{"country":"US","language":"en"},"email":"doe@email.com","email_verified":true}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	user, err := provider.FetchUser(&linkedin.Session{AccessToken: "1234567890", IDToken: "id-token"})
+	a.NoError(err)
+	a.Equal("782bbtaQ", user.UserID)
+	a.Equal("John Doe", user.Name)
+	a.Equal("John", user.FirstName)
+	a.Equal("Doe", user.LastName)
+	a.Equal("https://media.licdn.com/pic.jpg", user.AvatarURL)
+	a.Equal("doe@email.com", user.Email)
+	a.Equal("id-token", user.IDToken)
+	a.Equal(true, user.RawData["email_verified"])
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -57,3 +84,9 @@ func Test_SessionFromJSON(t *testing.T) {
 func linkedinProvider() *linkedin.Provider {
 	return linkedin.New(os.Getenv("LINKEDIN_KEY"), os.Getenv("LINKEDIN_SECRET"), "/foo", "r_liteprofile", "r_emailaddress")
 }
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -13,6 +13,7 @@ type Session struct {
 	AuthURL     string
 	AccessToken string
 	ExpiresAt   time.Time
+	IDToken     string `json:",omitempty"`
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the LinkedIn provider.
@@ -37,6 +38,9 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 
 	s.AccessToken = token.AccessToken
 	s.ExpiresAt = token.Expiry
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		s.IDToken = idToken
+	}
 	return token.AccessToken, err
 }
 
@@ -0,0 +1,80 @@
+package linode_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/markbates/goth/providers/linode"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := linodeProvider()
+	a.Equal(provider.ClientKey, "linode_key")
+	a.Equal(provider.Secret, "linode_secret")
+	a.Equal(provider.CallbackURL, "/foo")
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := linodeProvider()
+	session, err := provider.BeginAuth("test_state")
+	s := session.(*linode.Session)
+
+	a.NoError(err)
+	a.Contains(s.AuthURL, "login.linode.com/oauth/authorize")
+	a.Contains(s.AuthURL, fmt.Sprintf("client_id=%s", "linode_key"))
+	a.Contains(s.AuthURL, "state=test_state")
+	a.Contains(s.AuthURL, "scope=linodes%3Aread_only")
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := linodeProvider()
+
+	s, err := provider.UnmarshalSession(`{"AuthURL":"http://github.com/auth_url","AccessToken":"1234567890"}`)
+	a.NoError(err)
+	session := s.(*linode.Session)
+	a.Equal(session.AuthURL, "http://github.com/auth_url")
+	a.Equal(session.AccessToken, "1234567890")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := linodeProvider()
+	provider.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("https://api.linode.com/v4/profile", req.URL.String())
+		a.Equal("Bearer token", req.Header.Get("Authorization"))
+		body := `{"uid":1234,"username":"homer","email":"homer@example.com","timezone":"US/Eastern","restricted":false}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	user, err := provider.FetchUser(&linode.Session{AccessToken: "token"})
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal("homer", user.NickName)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("US/Eastern", user.RawData["timezone"])
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func linodeProvider() *linode.Provider {
+	return linode.New("linode_key", "linode_secret", "/foo", linode.ScopeLinodesReadOnly)
+}
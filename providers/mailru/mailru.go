@@ -3,12 +3,14 @@
 package mailru
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -58,6 +60,13 @@ func (p *Provider) SetName(name string) {
 	p.name = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.oauthConfig = oauth2util.WithRedirectURL(p.oauthConfig, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.httpClient)
 }
@@ -126,10 +135,12 @@ func (p *Provider) Debug(debug bool) {}
 
 // RefreshToken refresh token is not provided by mailru.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	t := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.oauthConfig.TokenSource(goth.ContextForClient(p.Client()), t)
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
 
-	return ts.Token()
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.oauthConfig, p.Client(), refreshToken)
 }
 
 // RefreshTokenAvailable refresh token is not provided by mailru
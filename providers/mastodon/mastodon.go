@@ -1,9 +1,14 @@
 // Package mastodon implements the OAuth2 protocol for authenticating users through Mastodon.
 // This package can be used as a reference implementation of an OAuth2 provider for Goth.
+//
+// Use a Registrar to let users sign in from any Mastodon server: it registers
+// the application on each instance the first time it is used. Note that account
+// IDs are only unique within an instance; RawData["instance_url"] tells them apart.
 package mastodon
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,6 +17,7 @@ import (
 	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -31,6 +37,7 @@ type Provider struct {
 	authURL      string
 	tokenURL     string
 	profileURL   string
+	instanceURL  string
 }
 
 // New creates a new Mastodon provider and sets up important connection details.
@@ -52,6 +59,7 @@ func NewCustomisedURL(clientKey, secret, callbackURL, instanceURL string, scopes
 		CallbackURL:  callbackURL,
 		providerName: "mastodon",
 		profileURL:   profileURL,
+		instanceURL:  strings.TrimSuffix(instanceURL, "/"),
 	}
 	p.config = newConfig(p, authURL, tokenURL, scopes)
 	return p
@@ -67,6 +75,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -123,6 +139,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	err = userFromReader(bytes.NewReader(bits), &user)
+	user.RawData["instance_url"] = p.instanceURL
 
 	return user, err
 }
@@ -151,8 +168,10 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	u := struct {
 		Name      string `json:"display_name"`
 		NickName  string `json:"username"`
+		Acct      string `json:"acct"`
 		ID        string `json:"id"`
 		AvatarURL string `json:"avatar"`
+		Note      string `json:"note"`
 	}{}
 	err := json.NewDecoder(r).Decode(&u)
 	if err != nil {
@@ -162,9 +181,13 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	if len(user.Name) == 0 {
 		user.Name = u.NickName
 	}
-	user.NickName = u.NickName
+	user.NickName = u.Acct
+	if len(user.NickName) == 0 {
+		user.NickName = u.NickName
+	}
 	user.UserID = u.ID
 	user.AvatarURL = u.AvatarURL
+	user.Description = u.Note
 	return nil
 }
 
@@ -175,11 +198,10 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
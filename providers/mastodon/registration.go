@@ -0,0 +1,161 @@
+package mastodon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/markbates/goth"
+)
+
+// App is a client application registered on a Mastodon instance.
+type App struct {
+	InstanceURL  string
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+// RegisterApp registers a client application on the instance, which lets users
+// of any Mastodon server sign in without setting up the application there
+// beforehand. The credentials of the returned App should be kept and reused
+// for the instance.
+// See https://docs.joinmastodon.org/methods/apps/#create
+func RegisterApp(ctx context.Context, client *http.Client, instanceURL, clientName, callbackURL, website string, scopes ...string) (App, error) {
+	instanceURL = strings.TrimSuffix(instanceURL, "/")
+	form := url.Values{
+		"client_name":   {clientName},
+		"redirect_uris": {callbackURL},
+		"scopes":        {strings.Join(scopes, " ")},
+	}
+	if website != "" {
+		form.Set("website", website)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, instanceURL+"/api/v1/apps", strings.NewReader(form.Encode()))
+	if err != nil {
+		return App{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := goth.HTTPClientWithFallBack(client).Do(req)
+	if err != nil {
+		return App{}, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return App{}, fmt.Errorf("mastodon responded with a %d trying to register an app on %s", response.StatusCode, instanceURL)
+	}
+
+	app := App{InstanceURL: instanceURL}
+	if err := json.NewDecoder(response.Body).Decode(&app); err != nil {
+		return App{}, err
+	}
+	if app.ClientID == "" || app.ClientSecret == "" {
+		return App{}, fmt.Errorf("mastodon did not return client credentials registering an app on %s", instanceURL)
+	}
+	return app, nil
+}
+
+// NewWithApp creates a provider for the instance an App was registered on.
+func NewWithApp(app App, callbackURL string, scopes ...string) *Provider {
+	return NewCustomisedURL(app.ClientID, app.ClientSecret, callbackURL, app.InstanceURL, scopes...)
+}
+
+// Registrar creates providers for any instance, registering an app the first
+// time an instance is used. Registered apps are kept in memory; set Load and
+// Save to persist them, as instances don't like being asked to register the
+// same application over and over.
+type Registrar struct {
+	ClientName  string
+	CallbackURL string
+	Website     string
+	Scopes      []string
+	HTTPClient  *http.Client
+
+	// Load, if set, returns the app registered on an instance earlier, or
+	// false if there is none.
+	Load func(ctx context.Context, instanceURL string) (App, bool, error)
+	// Save, if set, is called with every newly registered app.
+	Save func(ctx context.Context, app App) error
+
+	mu   sync.Mutex
+	apps map[string]App
+}
+
+// Provider returns a provider for the instance, registering an app on it if
+// needed. The instance may be given as a URL or a bare host name.
+func (r *Registrar) Provider(ctx context.Context, instance string) (*Provider, error) {
+	instanceURL, err := normalizeInstance(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := r.app(ctx, instanceURL)
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewWithApp(app, r.CallbackURL, r.Scopes...)
+	p.HTTPClient = r.HTTPClient
+	return p, nil
+}
+
+func (r *Registrar) app(ctx context.Context, instanceURL string) (App, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if app, ok := r.apps[instanceURL]; ok {
+		return app, nil
+	}
+
+	if r.Load != nil {
+		app, ok, err := r.Load(ctx, instanceURL)
+		if err != nil {
+			return App{}, err
+		}
+		if ok {
+			r.remember(app)
+			return app, nil
+		}
+	}
+
+	app, err := RegisterApp(ctx, r.HTTPClient, instanceURL, r.ClientName, r.CallbackURL, r.Website, r.Scopes...)
+	if err != nil {
+		return App{}, err
+	}
+	if r.Save != nil {
+		if err := r.Save(ctx, app); err != nil {
+			return App{}, err
+		}
+	}
+	r.remember(app)
+	return app, nil
+}
+
+func (r *Registrar) remember(app App) {
+	if r.apps == nil {
+		r.apps = map[string]App{}
+	}
+	r.apps[app.InstanceURL] = app
+}
+
+// normalizeInstance turns "mastodon.social" or "https://mastodon.social/" into
+// "https://mastodon.social".
+func normalizeInstance(instance string) (string, error) {
+	if !strings.Contains(instance, "://") {
+		instance = "https://" + instance
+	}
+	u, err := url.Parse(instance)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return "", fmt.Errorf("mastodon: invalid instance %q", instance)
+	}
+	return u.Scheme + "://" + strings.ToLower(u.Host), nil
+}
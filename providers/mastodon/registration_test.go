@@ -0,0 +1,88 @@
+package mastodon_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/markbates/goth/providers/mastodon"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Registrar(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	registrations := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/apps":
+			registrations++
+			a.Equal("goth", r.FormValue("client_name"))
+			a.Equal("http://localhost/callback", r.FormValue("redirect_uris"))
+			a.Equal("read:accounts", r.FormValue("scopes"))
+			fmt.Fprint(w, `{"id":"1","name":"goth","client_id":"cid","client_secret":"csecret"}`)
+		case "/api/v1/accounts/verify_credentials":
+			a.Equal("Bearer token", r.Header.Get("Authorization"))
+			fmt.Fprint(w, `{"id":"42","username":"homer","acct":"homer","display_name":"Homer Simpson","avatar":"https://example.com/a.png","note":"<p>D'oh!</p>"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	var saved []mastodon.App
+	r := &mastodon.Registrar{
+		ClientName:  "goth",
+		CallbackURL: "http://localhost/callback",
+		Scopes:      []string{"read:accounts"},
+		Save: func(ctx context.Context, app mastodon.App) error {
+			saved = append(saved, app)
+			return nil
+		},
+	}
+
+	p, err := r.Provider(context.Background(), ts.URL+"/")
+	a.NoError(err)
+	a.Equal("cid", p.ClientKey)
+	a.Equal("csecret", p.Secret)
+
+	_, err = r.Provider(context.Background(), ts.URL)
+	a.NoError(err)
+	a.Equal(1, registrations)
+	a.Equal([]mastodon.App{{InstanceURL: ts.URL, ClientID: "cid", ClientSecret: "csecret"}}, saved)
+
+	session, err := p.BeginAuth("state")
+	a.NoError(err)
+	a.Contains(session.(*mastodon.Session).AuthURL, ts.URL+"/oauth/authorize?client_id=cid")
+
+	user, err := p.FetchUser(&mastodon.Session{AccessToken: "token"})
+	a.NoError(err)
+	a.Equal("42", user.UserID)
+	a.Equal("homer", user.NickName)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("<p>D'oh!</p>", user.Description)
+	a.Equal(ts.URL, user.RawData["instance_url"])
+}
+
+func Test_Registrar_Load(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	r := &mastodon.Registrar{
+		CallbackURL: "http://localhost/callback",
+		Load: func(ctx context.Context, instanceURL string) (mastodon.App, bool, error) {
+			a.Equal("https://mastodon.example", instanceURL)
+			return mastodon.App{InstanceURL: instanceURL, ClientID: "stored", ClientSecret: "secret"}, true, nil
+		},
+	}
+
+	p, err := r.Provider(context.Background(), "Mastodon.Example")
+	a.NoError(err)
+	a.Equal("stored", p.ClientKey)
+
+	_, err = r.Provider(context.Background(), "https://mastodon.example/@homer")
+	a.Error(err)
+}
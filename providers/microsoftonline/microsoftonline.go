@@ -5,13 +5,16 @@ package microsoftonline
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/markbates/going/defaults"
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -40,10 +43,11 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 
 // Provider is the implementation of `goth.Provider` for accessing microsoftonline.
 type Provider struct {
-	ClientKey    string
-	Secret       string
-	CallbackURL  string
-	HTTPClient   *http.Client
+	ClientKey   string
+	Secret      string
+	CallbackURL string
+	HTTPClient  *http.Client
+	goth.AdditionalParams
 	config       *oauth2.Config
 	providerName string
 	tenant       string
@@ -59,9 +63,17 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 // Client is HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return p.TokenClient(goth.HTTPClientWithFallBack(p.HTTPClient))
 }
 
 // Debug is a no-op for the facebook package.
@@ -69,9 +81,14 @@ func (p *Provider) Debug(debug bool) {}
 
 // BeginAuth asks MicrosoftOnline for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	authURL := p.config.AuthCodeURL(state)
+	return p.BeginAuthWithParams(state, nil)
+}
+
+// BeginAuthWithParams is like BeginAuth, adding params (e.g. domain_hint or
+// login_hint) to the authentication end-point.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
 	return &Session{
-		AuthURL: authURL,
+		AuthURL: p.config.AuthCodeURL(state, append(p.AuthCodeOptions(), goth.AuthURLParamOptions(params)...)...),
 	}, nil
 }
 
@@ -119,17 +136,16 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
 	if refreshToken == "" {
 		return nil, fmt.Errorf("No refresh token provided")
 	}
 
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
 
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
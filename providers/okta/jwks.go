@@ -0,0 +1,65 @@
+package okta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/markbates/goth"
+)
+
+// jwksMinRefreshInterval is the minimum time the key set of the authorization
+// server is cached for, regardless of the cache headers it is served with.
+const jwksMinRefreshInterval = 15 * time.Minute
+
+// ValidateIDToken verifies the signature of idToken against the keys published
+// by the authorization server, checks that it was issued by the authorization
+// server for this client and hasn't expired, and returns its claims.
+// See https://developer.okta.com/docs/guides/validate-id-tokens/main/
+func (p *Provider) ValidateIDToken(idToken string) (jwt.MapClaims, error) {
+	if idToken == "" {
+		return nil, errors.New("no id_token to validate")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, p.verificationKey,
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(p.issuerURL),
+		jwt.WithAudience(p.ClientKey),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(goth.Now),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// jwksURL returns the URL of the key set, which sits next to the token endpoint
+// for both the org and the custom authorization servers.
+func (p *Provider) jwksURL() string {
+	return strings.TrimSuffix(p.config.Endpoint.TokenURL, "/token") + "/keys"
+}
+
+func (p *Provider) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	set, err := p.jwks.Fetch(context.Background(), p.jwksURL(), p.Client())
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := set.LookupKeyID(kid)
+	if !ok {
+		return nil, fmt.Errorf("could not find key %q to verify id_token", kid)
+	}
+
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
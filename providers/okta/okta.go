@@ -4,36 +4,73 @@ package okta
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/jwkutil"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
+// Authorization servers, see https://developer.okta.com/docs/concepts/auth-servers/
+const (
+	// DefaultAuthServer is the ID of the default custom authorization server.
+	DefaultAuthServer = "default"
+	// OrgAuthServer selects the org authorization server, which is built into
+	// every Okta org and issues tokens for Okta itself.
+	OrgAuthServer = ""
+)
+
+// ScopeGroups requests the groups claim, which the authorization server
+// must be configured to include.
+const ScopeGroups = "groups"
+
 // Provider is the implementation of `goth.Provider` for accessing okta.
 type Provider struct {
-	ClientKey    string
-	Secret       string
-	CallbackURL  string
-	HTTPClient   *http.Client
+	ClientKey   string
+	Secret      string
+	CallbackURL string
+	HTTPClient  *http.Client
+	goth.AdditionalParams
 	config       *oauth2.Config
 	providerName string
+	debug        bool
 	issuerURL    string
 	profileURL   string
+	pkce         bool
+	apiToken     string
+	allowGroups  []string
+	jwks         *jwkutil.KeySet
 }
 
-// New creates a new Okta provider and sets up important connection details.
+// New creates a new Okta provider using the default custom authorization
+// server of the org, and sets up important connection details.
 // You should always call `okta.New` to get a new provider.  Never try to
 // create one manually.
 func New(clientID, secret, orgURL, callbackURL string, scopes ...string) *Provider {
-	issuerURL := orgURL + "/oauth2/default"
-	authURL := issuerURL + "/v1/authorize"
-	tokenURL := issuerURL + "/v1/token"
-	profileURL := issuerURL + "/v1/userinfo"
+	return NewWithAuthServer(clientID, secret, orgURL, DefaultAuthServer, callbackURL, scopes...)
+}
+
+// NewWithAuthServer is similar to New(...) but targets the custom authorization
+// server with the given ID, or the org authorization server if authServerID
+// is OrgAuthServer.
+func NewWithAuthServer(clientID, secret, orgURL, authServerID, callbackURL string, scopes ...string) *Provider {
+	issuerURL := orgURL
+	endpointsURL := orgURL + "/oauth2"
+	if authServerID != OrgAuthServer {
+		issuerURL = orgURL + "/oauth2/" + authServerID
+		endpointsURL = issuerURL
+	}
+	authURL := endpointsURL + "/v1/authorize"
+	tokenURL := endpointsURL + "/v1/token"
+	profileURL := endpointsURL + "/v1/userinfo"
 	return NewCustomisedURL(clientID, secret, callbackURL, authURL, tokenURL, issuerURL, profileURL, scopes...)
 }
 
@@ -46,6 +83,8 @@ func NewCustomisedURL(clientID, secret, callbackURL, authURL, tokenURL, issuerUR
 		providerName: "okta",
 		issuerURL:    issuerURL,
 		profileURL:   profileURL,
+		pkce:         true,
+		jwks:         &jwkutil.KeySet{MinRefreshInterval: jwksMinRefreshInterval},
 	}
 	p.config = newConfig(p, authURL, tokenURL, scopes)
 	return p
@@ -61,18 +100,61 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	return p.TokenClient(goth.HTTPClientWithFallBack(p.HTTPClient))
 }
 
-// Debug is a no-op for the okta package.
-func (p *Provider) Debug(debug bool) {}
+// Debug makes the sessions keep the responses of the token endpoint and
+// traces of the requests made to authorize them, returned by their
+// DebugInfo method.
+func (p *Provider) Debug(debug bool) {
+	p.debug = debug
+}
+
+// SetPKCE enables or disables Proof Key for Code Exchange (PKCE) for the
+// authorization code flow. It is enabled by default for Okta.
+func (p *Provider) SetPKCE(enabled bool) {
+	p.pkce = enabled
+}
+
+// SetAPIToken sets an Okta API token, which is used to fetch the groups of the
+// user from the Okta API when the userinfo response has no groups claim.
+func (p *Provider) SetAPIToken(token string) {
+	p.apiToken = token
+}
+
+// SetAllowedGroups restricts sign in to members of at least one of groups.
+// FetchUser rejects other users with goth.ErrUserNotAllowed. The groups are
+// read from the groups claim, so request ScopeGroups or set an API token with
+// SetAPIToken.
+func (p *Provider) SetAllowedGroups(groups ...string) {
+	p.allowGroups = groups
+}
 
 // BeginAuth asks okta for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
-	}, nil
+	return p.BeginAuthWithParams(state, nil)
+}
+
+// BeginAuthWithParams is like BeginAuth, adding params (e.g. login_hint or prompt)
+// to the authentication end-point.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
+	session := &Session{}
+	opts := append(p.AuthCodeOptions(), goth.AuthURLParamOptions(params)...)
+	if p.pkce {
+		session.CodeVerifier = goth.GeneratePKCEVerifier()
+		opts = append(opts, goth.PKCEChallengeOption(session.CodeVerifier))
+	}
+	session.AuthURL = p.config.AuthCodeURL(state, opts...)
+	return session, nil
 }
 
 // FetchUser will go to okta and access basic information about the user.
@@ -84,6 +166,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		RefreshToken: sess.RefreshToken,
 		ExpiresAt:    sess.ExpiresAt,
 		UserID:       sess.UserID,
+		IDToken:      sess.IDToken,
 	}
 
 	if user.AccessToken == "" {
@@ -120,10 +203,84 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	err = userFromReader(bytes.NewReader(bits), &user)
+	if err != nil {
+		return user, err
+	}
+
+	if _, ok := user.RawData["groups"]; !ok && p.apiToken != "" {
+		groups, err := p.fetchGroups(user.UserID)
+		if err != nil {
+			return user, err
+		}
+		user.RawData["groups"] = groups
+	}
+	user.Groups = goth.Claims(user.RawData).Groups()
+
+	if !p.groupAllowed(user.Groups) {
+		return user, fmt.Errorf("%w: the user is not a member of any allowed group", goth.ErrUserNotAllowed)
+	}
 
 	return user, err
 }
 
+func (p *Provider) groupAllowed(groups []string) bool {
+	if len(p.allowGroups) == 0 {
+		return true
+	}
+	for _, g := range groups {
+		for _, allowed := range p.allowGroups {
+			if g == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fetchGroups returns the names of the groups of the user from the Okta API.
+// See https://developer.okta.com/docs/api/openapi/okta-management/management/tag/User/#tag/User/operation/listUserGroups
+func (p *Provider) fetchGroups(userID string) ([]string, error) {
+	req, err := http.NewRequest("GET", p.orgURL()+"/api/v1/users/"+url.PathEscape(userID)+"/groups", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "SSWS "+p.apiToken)
+	req.Header.Set("Accept", "application/json")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to fetch user groups", p.providerName, response.StatusCode)
+	}
+
+	groups := []struct {
+		Profile struct {
+			Name string `json:"name"`
+		} `json:"profile"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&groups); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(groups))
+	for _, g := range groups {
+		names = append(names, g.Profile.Name)
+	}
+	return names, nil
+}
+
+// orgURL returns the URL of the Okta org the authorization server belongs to.
+func (p *Provider) orgURL() string {
+	if i := strings.Index(p.issuerURL, "/oauth2"); i >= 0 {
+		return p.issuerURL[:i]
+	}
+	return p.issuerURL
+}
+
 func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
@@ -146,16 +303,17 @@ func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *o
 
 func userFromReader(r io.Reader, user *goth.User) error {
 	u := struct {
-		Name       string `json:"name"`
-		Email      string `json:"email"`
-		FirstName  string `json:"given_name"`
-		LastName   string `json:"family_name"`
-		NickName   string `json:"nickname"`
-		ID         string `json:"sub"`
-		Locale     string `json:"locale"`
-		ProfileURL string `json:"profile"`
-		Username   string `json:"preferred_username"`
-		Zoneinfo   string `json:"zoneinfo"`
+		Name       string   `json:"name"`
+		Email      string   `json:"email"`
+		FirstName  string   `json:"given_name"`
+		LastName   string   `json:"family_name"`
+		NickName   string   `json:"nickname"`
+		ID         string   `json:"sub"`
+		Locale     string   `json:"locale"`
+		ProfileURL string   `json:"profile"`
+		Username   string   `json:"preferred_username"`
+		Zoneinfo   string   `json:"zoneinfo"`
+		Groups     []string `json:"groups"`
 	}{}
 
 	err := json.NewDecoder(r).Decode(&u)
@@ -168,6 +326,9 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	rd["Locale"] = u.Locale
 	rd["Username"] = u.Username
 	rd["Zoneinfo"] = u.Zoneinfo
+	if u.Groups != nil {
+		rd["groups"] = u.Groups
+	}
 
 	user.UserID = u.ID
 	user.Email = u.Email
@@ -188,11 +349,18 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
+}
+
+// ExchangeToken implements goth.TokenExchanger with the token exchange of
+// RFC 8693, which custom authorization servers support for on-behalf-of
+// flows, the token of the user being exchanged for one of another audience.
+// See https://developer.okta.com/docs/guides/set-up-token-exchange/main/
+func (p *Provider) ExchangeToken(ctx context.Context, r goth.TokenExchangeRequest) (*oauth2.Token, error) {
+	return goth.ExchangeToken(ctx, p.Client(), p.config, r)
 }
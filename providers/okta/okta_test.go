@@ -1,9 +1,18 @@
 package okta_test
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/okta"
 	"github.com/stretchr/testify/assert"
@@ -33,6 +42,7 @@ func Test_Implements_Provider(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
 	a.Implements((*goth.Provider)(nil), provider())
+	a.Implements((*goth.TokenExchanger)(nil), provider())
 }
 
 func Test_BeginAuth(t *testing.T) {
@@ -58,6 +68,117 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AccessToken, "1234567890")
 }
 
+func Test_NewWithAuthServer(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := okta.NewWithAuthServer("id", "secret", "https://acme.okta.com", okta.OrgAuthServer, "/foo")
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*okta.Session).AuthURL, "https://acme.okta.com/oauth2/v1/authorize")
+
+	p = okta.NewWithAuthServer("id", "secret", "https://acme.okta.com", "aus123", "/foo")
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*okta.Session).AuthURL, "https://acme.okta.com/oauth2/aus123/v1/authorize")
+}
+
+func Test_FetchUser_Groups(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/default/v1/userinfo":
+			fmt.Fprint(w, `{"sub":"00u1","name":"Jane Doe","email":"jane@example.com"}`)
+		case "/api/v1/users/00u1/groups":
+			if r.Header.Get("Authorization") != "SSWS api-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `[{"id":"00g1","profile":{"name":"Everyone"}},{"id":"00g2","profile":{"name":"Admins"}}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	p := okta.New("id", "secret", ts.URL, "/foo")
+	user, err := p.FetchUser(&okta.Session{AccessToken: "token"})
+	a.NoError(err)
+	a.Equal("00u1", user.UserID)
+	a.NotContains(user.RawData, "groups")
+
+	p.SetAPIToken("api-token")
+	user, err = p.FetchUser(&okta.Session{AccessToken: "token"})
+	a.NoError(err)
+	a.Equal([]string{"Everyone", "Admins"}, user.RawData["groups"])
+	a.Equal([]string{"Everyone", "Admins"}, user.Groups)
+
+	p.SetAllowedGroups("Admins", "Ops")
+	_, err = p.FetchUser(&okta.Session{AccessToken: "token"})
+	a.NoError(err)
+
+	p.SetAllowedGroups("Ops")
+	_, err = p.FetchUser(&okta.Session{AccessToken: "token"})
+	a.ErrorIs(err, goth.ErrUserNotAllowed)
+}
+
+func Test_ValidateIDToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	pub, err := jwk.New(&key.PublicKey)
+	a.NoError(err)
+	a.NoError(pub.Set(jwk.KeyIDKey, "kid1"))
+	set := jwk.NewSet()
+	set.Add(pub)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth2/aus123/v1/keys" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	defer ts.Close()
+
+	p := okta.NewWithAuthServer("id", "secret", ts.URL, "aus123", "/foo")
+
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "kid1"
+		s, err := token.SignedString(key)
+		a.NoError(err)
+		return s
+	}
+
+	claims, err := p.ValidateIDToken(sign(jwt.MapClaims{
+		"iss": ts.URL + "/oauth2/aus123",
+		"aud": "id",
+		"sub": "00u1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}))
+	a.NoError(err)
+	a.Equal("00u1", claims["sub"])
+
+	_, err = p.ValidateIDToken(sign(jwt.MapClaims{
+		"iss": ts.URL + "/oauth2/aus123",
+		"aud": "someone-else",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}))
+	a.Error(err)
+
+	_, err = p.ValidateIDToken(sign(jwt.MapClaims{
+		"iss": ts.URL + "/oauth2/aus123",
+		"aud": "id",
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	}))
+	a.Error(err)
+}
+
 func provider() *okta.Provider {
 	return okta.New(os.Getenv("OKTA_ID"), os.Getenv("OKTA_SECRET"), os.Getenv("OKTA_ORG_URL"), "/foo")
 }
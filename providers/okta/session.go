@@ -3,10 +3,13 @@ package okta
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Okta.
@@ -16,6 +19,26 @@ type Session struct {
 	RefreshToken string
 	ExpiresAt    time.Time
 	UserID       string
+	IDToken      string `json:",omitempty"`
+	CodeVerifier string `json:",omitempty"`
+	// Debug is what was captured while authorizing the session, if the
+	// provider was in debug mode.
+	Debug *goth.DebugInfo `json:",omitempty"`
+}
+
+// DebugInfo implements goth.DebugSession.
+func (s Session) DebugInfo() *goth.DebugInfo {
+	return s.Debug
+}
+
+// client returns the client authorizing the session, which records its
+// requests in Debug if the provider is in debug mode.
+func (s *Session) client(p *Provider) *http.Client {
+	if !p.debug {
+		return p.Client()
+	}
+	s.Debug = &goth.DebugInfo{}
+	return goth.DebugClient(p.Client(), s.Debug)
 }
 
 var _ goth.Session = &Session{}
@@ -31,7 +54,11 @@ func (s Session) GetAuthURL() (string, error) {
 // Authorize the session with Okta and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
+	var opts []oauth2.AuthCodeOption
+	if s.CodeVerifier != "" {
+		opts = append(opts, goth.PKCEVerifierOption(s.CodeVerifier))
+	}
+	token, err := p.config.Exchange(goth.ContextForClient(s.client(p)), params.Get("code"), opts...)
 	if err != nil {
 		return "", err
 	}
@@ -43,6 +70,9 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		s.IDToken = idToken
+	}
 	return token.AccessToken, err
 }
 
@@ -62,3 +92,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
 	return s, err
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
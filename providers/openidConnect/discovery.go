@@ -0,0 +1,194 @@
+package openidConnect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// discoveryDefaultTTL is how long the discovery document is cached when
+	// its response has no cache headers, unless set with SetDiscoveryTTL.
+	discoveryDefaultTTL = 24 * time.Hour
+	// discoveryMinTTL is the minimum time the discovery document is cached
+	// for, so that no-cache responses don't make every request refetch it.
+	discoveryMinTTL = 5 * time.Minute
+	// discoveryRetryInterval is how long a failed refresh of the discovery
+	// document is waited for before trying again, the stale document being
+	// used meanwhile.
+	discoveryRetryInterval = time.Minute
+)
+
+// discoveryState holds the discovery document of a provider created with New,
+// shared with the copies made by WithCallbackURL. Once the document expires,
+// the next use of the provider refetches it in the background while the
+// expired one keeps being used.
+type discoveryState struct {
+	url string
+	ttl time.Duration
+
+	mu         sync.Mutex
+	config     *OpenIDConfig
+	expires    time.Time
+	refreshing bool
+	lastForced time.Time
+}
+
+// SetDiscoveryTTL sets how long the discovery document of a provider created
+// with New is cached for when the response doesn't say, with Cache-Control or
+// Expires headers, how long it may be cached. It defaults to a day. A negative
+// ttl disables the refresh: the document fetched by New is kept for good.
+func (p *Provider) SetDiscoveryTTL(ttl time.Duration) {
+	if p.discovery == nil {
+		return
+	}
+	p.discovery.mu.Lock()
+	defer p.discovery.mu.Unlock()
+	if ttl == 0 {
+		ttl = discoveryDefaultTTL
+	}
+	p.discovery.ttl = ttl
+}
+
+// CurrentOpenIDConfig returns the configuration the provider uses. For
+// providers created with New, it is the latest discovery document fetched,
+// which replaces OpenIDConfig once the document is refreshed; changes made to
+// OpenIDConfig are lost then. It is OpenIDConfig otherwise.
+func (p *Provider) CurrentOpenIDConfig() *OpenIDConfig {
+	d := p.discovery
+	if d == nil {
+		return p.OpenIDConfig
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.ttl > 0 && !d.refreshing && !goth.Now().Before(d.expires) {
+		d.refreshing = true
+		go func() {
+			// the document expired, fetch it without holding up the request
+			d.refresh(p)
+		}()
+	}
+	return d.config
+}
+
+// forceRefresh refetches the discovery document right away, e.g. because an
+// id_token couldn't be verified with the keys it points to, and tells whether
+// it could. It refetches it at most once per discoveryRetryInterval.
+func (d *discoveryState) forceRefresh(p *Provider) bool {
+	d.mu.Lock()
+	if d.ttl < 0 || d.refreshing || goth.Now().Sub(d.lastForced) < discoveryRetryInterval {
+		d.mu.Unlock()
+		return false
+	}
+	d.refreshing = true
+	d.lastForced = goth.Now()
+	d.mu.Unlock()
+
+	return d.refresh(p)
+}
+
+func (d *discoveryState) refresh(p *Provider) bool {
+	config, maxAge, err := fetchOpenIDConfig(p.Client(), d.url)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.refreshing = false
+	if err == nil && config.Issuer != d.config.Issuer {
+		// id_tokens are validated against the issuer, which can't change
+		err = fmt.Errorf("the issuer changed from %q to %q", d.config.Issuer, config.Issuer)
+	}
+	if err != nil {
+		d.expires = goth.Now().Add(discoveryRetryInterval)
+		return false
+	}
+
+	d.config = config
+	d.expires = goth.Now().Add(d.expiry(maxAge))
+	return true
+}
+
+// expiry returns how long a document with the given max age is cached for.
+func (d *discoveryState) expiry(maxAge time.Duration) time.Duration {
+	switch {
+	case maxAge < 0:
+		return d.ttl
+	case maxAge < discoveryMinTTL:
+		return discoveryMinTTL
+	}
+	return maxAge
+}
+
+// oauth2Config returns p.config with the endpoints of the current
+// configuration, in case the discovery document changed them.
+func (p *Provider) oauth2Config() *oauth2.Config {
+	current := p.CurrentOpenIDConfig()
+	if current.AuthEndpoint == p.config.Endpoint.AuthURL && current.TokenEndpoint == p.config.Endpoint.TokenURL {
+		return p.config
+	}
+	c := *p.config
+	c.Endpoint.AuthURL = current.AuthEndpoint
+	c.Endpoint.TokenURL = current.TokenEndpoint
+	return &c
+}
+
+// fetchOpenIDConfig fetches the discovery document at discoveryURL, and
+// returns how long it may be cached according to the response, or -1 if it
+// doesn't say.
+func fetchOpenIDConfig(client *http.Client, discoveryURL string) (*OpenIDConfig, time.Duration, error) {
+	res, err := client.Get(discoveryURL)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, 0, fmt.Errorf("Non-success code for Discovery URL: %d", res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	openIDConfig := &OpenIDConfig{}
+	err = json.Unmarshal(body, openIDConfig)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return openIDConfig, cacheMaxAge(res.Header), nil
+}
+
+// cacheMaxAge returns how long a response may be cached according to its
+// Cache-Control or Expires headers, or -1 if they don't say.
+func cacheMaxAge(h http.Header) time.Duration {
+	if cc := h.Get("Cache-Control"); cc != "" {
+		for _, directive := range strings.Split(cc, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "no-store", "no-cache":
+				return 0
+			case "max-age":
+				if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds >= 0 {
+					return time.Duration(seconds) * time.Second
+				}
+			}
+		}
+	}
+	if expires, err := http.ParseTime(h.Get("Expires")); err == nil {
+		if maxAge := expires.Sub(goth.Now()); maxAge > 0 {
+			return maxAge
+		}
+		return 0
+	}
+	return -1
+}
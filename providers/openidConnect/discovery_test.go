@@ -0,0 +1,102 @@
+package openidConnect
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CacheMaxAge(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Equal(time.Duration(-1), cacheMaxAge(http.Header{}))
+	a.Equal(10*time.Minute, cacheMaxAge(http.Header{"Cache-Control": {"public, max-age=600"}}))
+	a.Equal(time.Duration(0), cacheMaxAge(http.Header{"Cache-Control": {"no-cache"}}))
+	a.Equal(time.Duration(0), cacheMaxAge(http.Header{"Expires": {"Thu, 01 Jan 1970 00:00:00 GMT"}}))
+	a.InDelta(time.Hour, cacheMaxAge(http.Header{"Expires": {goth.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}), float64(2*time.Second))
+}
+
+func Test_DiscoveryRefresh(t *testing.T) {
+	a := assert.New(t)
+
+	var mu sync.Mutex
+	version := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Cache-Control", "max-age=600")
+		fmt.Fprintf(w, `{"issuer":"https://issuer.example.com","authorization_endpoint":"https://issuer.example.com/auth/%d","token_endpoint":"https://issuer.example.com/token/%d"}`, version, version)
+	}))
+	defer server.Close()
+
+	provider, err := New("key", "secret", "http://localhost/callback", server.URL)
+	a.NoError(err)
+	a.Equal("https://issuer.example.com/token/1", provider.CurrentOpenIDConfig().TokenEndpoint)
+
+	mu.Lock()
+	version = 2
+	mu.Unlock()
+
+	// the document is cached for the max-age of the response
+	a.Equal("https://issuer.example.com/token/1", provider.CurrentOpenIDConfig().TokenEndpoint)
+
+	now := time.Now().Add(11 * time.Minute)
+	goth.SetClock(func() time.Time { return now })
+	defer goth.SetClock(nil)
+
+	// the expired document is used until the new one has been fetched
+	a.Equal("https://issuer.example.com/token/1", provider.CurrentOpenIDConfig().TokenEndpoint)
+	a.Eventually(func() bool {
+		return provider.CurrentOpenIDConfig().TokenEndpoint == "https://issuer.example.com/token/2"
+	}, time.Second, 10*time.Millisecond)
+
+	session, err := provider.BeginAuth("state")
+	a.NoError(err)
+	a.Contains(session.(*Session).AuthURL, "https://issuer.example.com/auth/2?")
+	a.Equal("https://issuer.example.com/token/2", provider.oauth2Config().Endpoint.TokenURL)
+}
+
+func Test_VerifyIDTokenRotatedJWKSURI(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	oldKey, oldPub := signingKeyPair(t, "key-1")
+	newKey, newPub := signingKeyPair(t, "key-2")
+	oldJWKS := jwksServer(t, oldPub)
+	defer oldJWKS.Close()
+	newJWKS := jwksServer(t, newPub)
+	defer newJWKS.Close()
+
+	var mu sync.Mutex
+	jwksURI := oldJWKS.URL
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, `{"issuer":"https://issuer.example.com","jwks_uri":%q}`, jwksURI)
+	}))
+	defer server.Close()
+
+	provider, err := New("key", "secret", "http://localhost/callback", server.URL)
+	a.NoError(err)
+	a.NoError(provider.verifyIDToken(signIDToken(t, oldKey, jwa.RS256)))
+
+	// the provider moved its keys before the document expired
+	mu.Lock()
+	jwksURI = newJWKS.URL
+	mu.Unlock()
+
+	a.NoError(provider.verifyIDToken(signIDToken(t, newKey, jwa.RS256)))
+	a.Equal(newJWKS.URL, provider.CurrentOpenIDConfig().JWKSURI)
+
+	// tokens signed by unknown keys are still rejected
+	forged, _ := signingKeyPair(t, "key-3")
+	a.Error(provider.verifyIDToken(signIDToken(t, forged, jwa.RS256)))
+}
@@ -0,0 +1,189 @@
+package openidConnect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+)
+
+const (
+	// jwksMinRefreshInterval is the minimum time the provider's key set is cached for,
+	// regardless of the cache headers returned by the jwks_uri.
+	jwksMinRefreshInterval = 15 * time.Minute
+	// jwksForcedRefreshInterval limits how often an unknown key id can force a refetch
+	// of the key set, so forged tokens can't be used to hammer the jwks_uri.
+	jwksForcedRefreshInterval = time.Minute
+)
+
+// defaultIDTokenSigningAlgs is used when the discovery document doesn't list
+// id_token_signing_alg_values_supported. RS256 is the only algorithm every
+// OpenID Provider is required to support.
+var defaultIDTokenSigningAlgs = []string{jwa.RS256.String()}
+
+// keyCache fetches and caches the JSON Web Key Set published by the provider.
+type keyCache struct {
+	mu            sync.Mutex
+	autoRefresh   *jwk.AutoRefresh
+	configured    map[string]bool
+	lastRefreshed time.Time
+}
+
+// verifyIDToken verifies the signature of the id_token against the keys
+// published at the jwks_uri of the OpenID Connect provider.
+//
+// Verification is skipped when SkipIDTokenVerification is set, or when no
+// jwks_uri is known (e.g. the provider was created with NewCustomisedURL and
+// OpenIDConfig.JWKSURI was not set), in which case the TLS connection to the
+// token endpoint is relied upon, see
+// https://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
+//
+// If the verification fails for a provider created with New, the discovery
+// document and the key set are refetched and the verification retried once,
+// in case the provider rotated its keys or moved its jwks_uri.
+func (p *Provider) verifyIDToken(idToken string) error {
+	if p.SkipIDTokenVerification || p.CurrentOpenIDConfig().JWKSURI == "" {
+		return nil
+	}
+
+	err := p.verifyIDTokenSignature(idToken, false)
+	if err != nil && p.discovery != nil && p.discovery.forceRefresh(p) {
+		err = p.verifyIDTokenSignature(idToken, true)
+	}
+	return err
+}
+
+func (p *Provider) verifyIDTokenSignature(idToken string, refreshKeys bool) error {
+	if p.CurrentOpenIDConfig().JWKSURI == "" {
+		return errors.New("the jwks_uri is no longer published")
+	}
+
+	msg, err := jws.ParseString(idToken)
+	if err != nil {
+		return err
+	}
+	signatures := msg.Signatures()
+	if len(signatures) != 1 {
+		return errors.New("id_token must carry exactly one signature")
+	}
+	headers := signatures[0].ProtectedHeaders()
+
+	alg := headers.Algorithm()
+	if !p.idTokenAlgAllowed(alg) {
+		return fmt.Errorf("id_token signed with unexpected algorithm %q", alg)
+	}
+
+	// symmetric signatures use the client secret as key
+	// https://openid.net/specs/openid-connect-core-1_0.html#Signing
+	if strings.HasPrefix(alg.String(), "HS") {
+		_, err = jws.Verify([]byte(idToken), alg, []byte(p.Secret))
+		return err
+	}
+
+	key, err := p.signingKey(headers.KeyID(), refreshKeys)
+	if err != nil {
+		return err
+	}
+
+	_, err = jws.Verify([]byte(idToken), alg, key)
+	return err
+}
+
+func (p *Provider) idTokenAlgAllowed(alg jwa.SignatureAlgorithm) bool {
+	if alg == jwa.NoSignature {
+		return false
+	}
+
+	allowed := p.CurrentOpenIDConfig().IDTokenSigningAlgValuesSupported
+	if len(allowed) == 0 {
+		allowed = defaultIDTokenSigningAlgs
+	}
+	for _, a := range allowed {
+		if a == alg.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// signingKey returns the key matching kid from the provider's key set. If the
+// key can't be found, or refresh is set, the key set is refetched once in case
+// the provider rotated its keys.
+func (p *Provider) signingKey(kid string, refresh bool) (jwk.Key, error) {
+	ctx := context.Background()
+	cache := p.keyCache()
+	url := p.CurrentOpenIDConfig().JWKSURI
+
+	cache.mu.Lock()
+	if !cache.configured[url] {
+		cache.autoRefresh.Configure(url,
+			jwk.WithHTTPClient(p.Client()),
+			jwk.WithMinRefreshInterval(jwksMinRefreshInterval),
+		)
+		cache.configured[url] = true
+	}
+	cache.mu.Unlock()
+
+	set, err := cache.autoRefresh.Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	key, found := lookupKey(set, kid)
+	if found && !refresh {
+		return key, nil
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if time.Since(cache.lastRefreshed) < jwksForcedRefreshInterval {
+		if found {
+			return key, nil
+		}
+		return nil, fmt.Errorf("could not find key %q to verify id_token", kid)
+	}
+	cache.lastRefreshed = time.Now()
+
+	set, err = cache.autoRefresh.Refresh(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := lookupKey(set, kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("could not find key %q to verify id_token", kid)
+}
+
+// jwksState holds the key cache of a provider, created on first use and
+// shared with the copies made by WithCallbackURL.
+type jwksState struct {
+	once  sync.Once
+	cache *keyCache
+}
+
+func (p *Provider) keyCache() *keyCache {
+	p.jwks.once.Do(func() {
+		p.jwks.cache = &keyCache{
+			autoRefresh: jwk.NewAutoRefresh(context.Background()),
+			configured:  map[string]bool{},
+		}
+	})
+	return p.jwks.cache
+}
+
+func lookupKey(set jwk.Set, kid string) (jwk.Key, bool) {
+	if kid != "" {
+		return set.LookupKeyID(kid)
+	}
+	// without a key id, the key can only be selected unambiguously
+	// if the provider publishes exactly one key
+	if set.Len() == 1 {
+		return set.Get(0)
+	}
+	return nil, false
+}
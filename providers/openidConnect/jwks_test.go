@@ -0,0 +1,93 @@
+package openidConnect
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_VerifyIDToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, pub := signingKeyPair(t, "key-1")
+	jwksServer := jwksServer(t, pub)
+	defer jwksServer.Close()
+
+	provider := openidConnectProvider()
+	provider.OpenIDConfig.JWKSURI = jwksServer.URL
+	// keep the discovery document from replacing the jwks_uri set above
+	provider.SetDiscoveryTTL(-1)
+
+	a.NoError(provider.verifyIDToken(signIDToken(t, key, jwa.RS256)))
+
+	// a token signed by an unknown key must be rejected
+	otherKey, _ := signingKeyPair(t, "key-2")
+	a.Error(provider.verifyIDToken(signIDToken(t, otherKey, jwa.RS256)))
+
+	// only algorithms announced by the discovery document are accepted
+	a.Error(provider.verifyIDToken(signIDToken(t, key, jwa.RS512)))
+
+	// unsigned tokens are always rejected
+	a.Error(provider.verifyIDToken("eyJhbGciOiJub25lIn0.eyJzdWIiOiIxIn0."))
+}
+
+func Test_VerifyIDTokenSkipped(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	otherKey, _ := signingKeyPair(t, "key-2")
+
+	provider := openidConnectProvider()
+	provider.OpenIDConfig.JWKSURI = "http://127.0.0.1:0/unreachable"
+	provider.SkipIDTokenVerification = true
+
+	a.NoError(provider.verifyIDToken(signIDToken(t, otherKey, jwa.RS256)))
+}
+
+func signingKeyPair(t *testing.T, kid string) (jwk.Key, jwk.Key) {
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.New(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Set(jwk.KeyIDKey, kid)
+
+	pub, err := key.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub.Set(jwk.AlgorithmKey, jwa.RS256)
+	return key, pub
+}
+
+func jwksServer(t *testing.T, keys ...jwk.Key) *httptest.Server {
+	set := jwk.NewSet()
+	for _, k := range keys {
+		set.Add(k)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(set)
+	}))
+}
+
+func signIDToken(t *testing.T, key jwk.Key, alg jwa.SignatureAlgorithm) string {
+	headers := jws.NewHeaders()
+	headers.Set(jws.KeyIDKey, key.KeyID())
+	signed, err := jws.Sign([]byte(`{"sub":"1234567890"}`), alg, key, jws.WithHeaders(headers))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(signed)
+}
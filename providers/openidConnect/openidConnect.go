@@ -2,9 +2,8 @@ package openidConnect
 
 import (
 	"bytes"
-	"encoding/base64"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -13,16 +12,15 @@ import (
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/jwtutil"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
 const (
 	// Standard Claims http://openid.net/specs/openid-connect-core-1_0.html#StandardClaims
 	// fixed, cannot be changed
-	subjectClaim  = "sub"
-	expiryClaim   = "exp"
-	audienceClaim = "aud"
-	issuerClaim   = "iss"
+	subjectClaim = "sub"
 
 	PreferredUsernameClaim = "preferred_username"
 	EmailClaim             = "email"
@@ -32,6 +30,8 @@ const (
 	GivenNameClaim         = "given_name"
 	FamilyNameClaim        = "family_name"
 	AddressClaim           = "address"
+	GroupsClaim            = "groups"
+	RolesClaim             = "roles"
 
 	// Unused but available to set in Provider claims
 	MiddleNameClaim          = "middle_name"
@@ -51,13 +51,15 @@ const (
 
 // Provider is the implementation of `goth.Provider` for accessing OpenID Connect provider
 type Provider struct {
-	ClientKey    string
-	Secret       string
-	CallbackURL  string
-	HTTPClient   *http.Client
+	ClientKey   string
+	Secret      string
+	CallbackURL string
+	HTTPClient  *http.Client
+	goth.AdditionalParams
 	OpenIDConfig *OpenIDConfig
 	config       *oauth2.Config
 	providerName string
+	debug        bool
 
 	UserIdClaims    []string
 	NameClaims      []string
@@ -67,10 +69,37 @@ type Provider struct {
 	FirstNameClaims []string
 	LastNameClaims  []string
 	LocationClaims  []string
-
+	// GroupsClaims and RolesClaims list the claims holding the groups and
+	// roles of the user. Nested claims are separated by dots, e.g.
+	// "realm_access.roles" for Keycloak.
+	GroupsClaims []string
+	RolesClaims  []string
+
+	// SkipUserInfoRequest builds the user from the id_token alone, unless
+	// some of the RequiredClaims are missing from it, in which case the
+	// userinfo endpoint is requested anyway.
 	SkipUserInfoRequest bool
+
+	// RequiredClaims are the claims the user must have, in the id_token or
+	// the userinfo response, e.g. "email". FetchUser fails with an error
+	// wrapping ErrMissingClaim if any is missing. The sub and exp claims are
+	// always required.
+	RequiredClaims []string
+
+	// SkipIDTokenVerification disables the verification of the id_token
+	// signature against the provider's jwks_uri. Only use this for testing.
+	SkipIDTokenVerification bool
+
+	pkce       bool
+	clientAuth *goth.ClientAuth
+	jwks       *jwksState
+	discovery  *discoveryState
 }
 
+// ErrMissingClaim is wrapped by the errors of FetchUser when a required claim
+// of the user is missing.
+var ErrMissingClaim = jwtutil.ErrMissingClaim
+
 type OpenIDConfig struct {
 	AuthEndpoint     string `json:"authorization_endpoint"`
 	TokenEndpoint    string `json:"token_endpoint"`
@@ -81,6 +110,19 @@ type OpenIDConfig struct {
 	// https://openid.net/specs/openid-connect-session-1_0-17.html#OPMetadata
 	EndSessionEndpoint string `json:"end_session_endpoint,omitempty"`
 	Issuer             string `json:"issuer"`
+
+	// RevocationEndpoint is the token revocation endpoint (RFC 7009), if the
+	// provider publishes one. See https://www.rfc-editor.org/rfc/rfc8414#section-2
+	RevocationEndpoint string `json:"revocation_endpoint,omitempty"`
+
+	// JWKSURI and IDTokenSigningAlgValuesSupported are used to verify the
+	// signature of the id_token.
+	JWKSURI                          string   `json:"jwks_uri,omitempty"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported,omitempty"`
+
+	// TokenEndpointAuthMethodsSupported lists the methods clients can
+	// authenticate with at the token endpoint, see SetClientAuth.
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported,omitempty"`
 }
 
 type RefreshTokenResponse struct {
@@ -90,7 +132,7 @@ type RefreshTokenResponse struct {
 	// refresh token flow. As a result, a new ID token may not be returned in a successful
 	// response.
 	// See more: https://openid.net/specs/openid-connect-core-1_0.html#RefreshingAccessToken
-	IdToken string `json:"id_token, omitempty"`
+	IdToken string `json:"id_token,omitempty"`
 
 	// The OAuth spec defines the refresh token as an optional response field in the
 	// refresh token flow. As a result, a new refresh token may not be returned in a successful
@@ -132,15 +174,20 @@ func NewNamed(name, clientKey, secret, callbackURL, openIDAutoDiscoveryURL strin
 		FirstNameClaims: []string{GivenNameClaim},
 		LastNameClaims:  []string{FamilyNameClaim},
 		LocationClaims:  []string{AddressClaim},
+		GroupsClaims:    []string{GroupsClaim},
+		RolesClaims:     []string{RolesClaim},
 
 		providerName: name,
+		jwks:         &jwksState{},
 	}
 
-	openIDConfig, err := getOpenIDConfig(p, openIDAutoDiscoveryURL)
+	openIDConfig, maxAge, err := fetchOpenIDConfig(p.Client(), openIDAutoDiscoveryURL)
 	if err != nil {
 		return nil, err
 	}
 	p.OpenIDConfig = openIDConfig
+	p.discovery = &discoveryState{url: openIDAutoDiscoveryURL, ttl: discoveryDefaultTTL, config: openIDConfig}
+	p.discovery.expires = goth.Now().Add(p.discovery.expiry(maxAge))
 
 	p.config = newConfig(p, scopes, openIDConfig)
 	return p, nil
@@ -168,8 +215,11 @@ func NewCustomisedURL(clientKey, secret, callbackURL, authURL, tokenURL, issuerU
 		FirstNameClaims: []string{GivenNameClaim},
 		LastNameClaims:  []string{FamilyNameClaim},
 		LocationClaims:  []string{AddressClaim},
+		GroupsClaims:    []string{GroupsClaim},
+		RolesClaims:     []string{RolesClaim},
 
 		providerName: "openid-connect",
+		jwks:         &jwksState{},
 	}
 
 	p.config = newConfig(p, scopes, p.OpenIDConfig)
@@ -187,18 +237,81 @@ func (p *Provider) SetName(name string) {
 }
 
 func (p *Provider) Client() *http.Client {
-	return goth.HTTPClientWithFallBack(p.HTTPClient)
+	c := p.TokenClient(goth.HTTPClientWithFallBack(p.HTTPClient))
+	if p.clientAuth != nil {
+		c = p.clientAuth.Client(c, p.ClientKey, p.oauth2Config().Endpoint.TokenURL)
+	}
+	return c
+}
+
+// WithCallbackURL returns a copy of the provider sending users back to
+// callbackURL. The copy shares the signing keys cached by the provider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
+// SetClientAuth selects how the client authenticates at the token endpoint,
+// e.g. goth.AuthMethodPrivateKeyJWT, instead of sending the secret in the way
+// oauth2 detects. It fails if the discovery document of the provider lists
+// the methods it supports and auth.Method isn't one of them.
+func (p *Provider) SetClientAuth(auth goth.ClientAuth) error {
+	if supported := p.CurrentOpenIDConfig().TokenEndpointAuthMethodsSupported; len(supported) > 0 {
+		found := false
+		for _, method := range supported {
+			if method == auth.Method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s does not support the %s token endpoint auth method", p.providerName, auth.Method)
+		}
+	}
+	if err := auth.Configure(p.config); err != nil {
+		return err
+	}
+	p.clientAuth = &auth
+	return nil
 }
 
-// Debug is a no-op for the openidConnect package.
-func (p *Provider) Debug(debug bool) {}
+// Debug makes the sessions keep the responses of the token endpoint and
+// traces of the requests made to authorize them, returned by their
+// DebugInfo method.
+func (p *Provider) Debug(debug bool) {
+	p.debug = debug
+}
+
+// SetPKCE enables or disables Proof Key for Code Exchange (PKCE) for the
+// authorization code flow. It is disabled by default for OpenID Connect.
+func (p *Provider) SetPKCE(enabled bool) {
+	p.pkce = enabled
+}
 
 // BeginAuth asks the OpenID Connect provider for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	url := p.config.AuthCodeURL(state)
-	session := &Session{
-		AuthURL: url,
-	}
+	return p.BeginAuthWithParams(state, nil)
+}
+
+// BeginAuthWithParams is like BeginAuth, adding params (e.g. login_hint or prompt)
+// to the authentication end-point. A nonce is added as well, unless params
+// has one, and kept in the session with the PKCE verifier, so that the
+// callback can be handled by any instance of the application.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
+	session := &Session{Nonce: params.Get("nonce")}
+	if session.Nonce == "" {
+		// 32 random bytes, base64url encoded
+		session.Nonce = oauth2.GenerateVerifier()
+	}
+	opts := append(p.AuthCodeOptions(), goth.AuthURLParamOptions(params)...)
+	opts = append(opts, oauth2.SetAuthURLParam("nonce", session.Nonce))
+	if p.pkce {
+		session.CodeVerifier = goth.GeneratePKCEVerifier()
+		opts = append(opts, goth.PKCEChallengeOption(session.CodeVerifier))
+	}
+	session.AuthURL = p.oauth2Config().AuthCodeURL(state, opts...)
 	return session, nil
 }
 
@@ -212,8 +325,12 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return goth.User{}, fmt.Errorf("%s cannot get user information without id_token", p.providerName)
 	}
 
+	if err := p.verifyIDToken(sess.IDToken); err != nil {
+		return goth.User{}, fmt.Errorf("oauth2: error verifying JWT token: %v", err)
+	}
+
 	// decode returned id token to get expiry
-	claims, err := decodeJWT(sess.IDToken)
+	claims, err := jwtutil.Decode(sess.IDToken)
 
 	if err != nil {
 		return goth.User{}, fmt.Errorf("oauth2: error decoding JWT token: %v", err)
@@ -221,7 +338,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 
 	expiry, err := p.validateClaims(claims)
 	if err != nil {
-		return goth.User{}, fmt.Errorf("oauth2: error validating JWT token: %v", err)
+		return goth.User{}, fmt.Errorf("oauth2: error validating JWT token: %w", err)
 	}
 
 	if expiry.Before(expiresAt) {
@@ -231,6 +348,9 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	if err := p.getUserInfo(sess.AccessToken, claims); err != nil {
 		return goth.User{}, err
 	}
+	if missing := missingClaims(claims, p.RequiredClaims); len(missing) > 0 {
+		return goth.User{}, fmt.Errorf("%s: %w: %s", p.providerName, ErrMissingClaim, strings.Join(missing, ", "))
+	}
 
 	user := goth.User{
 		AccessToken:  sess.AccessToken,
@@ -252,13 +372,20 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(oauth2.NoContext, token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.oauth2Config(), p.Client(), refreshToken)
+}
+
+// ExchangeToken implements goth.TokenExchanger with the token exchange of
+// RFC 8693, which Keycloak supports, including the impersonation of the user
+// named by RequestedSubject when the client is allowed to.
+// See https://www.keycloak.org/docs/latest/securing_apps/#_token-exchange
+func (p *Provider) ExchangeToken(ctx context.Context, r goth.TokenExchangeRequest) (*oauth2.Token, error) {
+	return goth.ExchangeToken(ctx, p.Client(), p.oauth2Config(), r)
 }
 
 // The ID token is a fundamental part of the OpenID connect refresh token flow but is not part of the OAuth flow.
@@ -270,15 +397,23 @@ func (p *Provider) RefreshTokenWithIDToken(refreshToken string) (*RefreshTokenRe
 	urlValues := url.Values{
 		"grant_type":    {"refresh_token"},
 		"refresh_token": {refreshToken},
-		"client_id":     {p.ClientKey},
-		"client_secret": {p.Secret},
 	}
-	req, err := http.NewRequest("POST", p.OpenIDConfig.TokenEndpoint, strings.NewReader(urlValues.Encode()))
+	basicAuth := p.config.Endpoint.AuthStyle == oauth2.AuthStyleInHeader
+	if !basicAuth {
+		urlValues.Set("client_id", p.ClientKey)
+		if p.clientAuth == nil || p.config.ClientSecret != "" {
+			urlValues.Set("client_secret", p.config.ClientSecret)
+		}
+	}
+	req, err := http.NewRequest("POST", p.CurrentOpenIDConfig().TokenEndpoint, strings.NewReader(urlValues.Encode()))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if basicAuth {
+		req.SetBasicAuth(url.QueryEscape(p.ClientKey), url.QueryEscape(p.config.ClientSecret))
+	}
 
 	resp, err := p.Client().Do(req)
 	if err != nil {
@@ -304,37 +439,27 @@ func (p *Provider) RefreshTokenWithIDToken(refreshToken string) (*RefreshTokenRe
 	return refreshTokenResponse, nil
 }
 
+// LogoutURL returns the URL of the end_session_endpoint to redirect the user to
+// in order to end their session with the OpenID Connect provider.
+// See https://openid.net/specs/openid-connect-rpinitiated-1_0.html
+func (p *Provider) LogoutURL(idTokenHint, postLogoutRedirect string) (string, error) {
+	return goth.EndSessionURL(p.CurrentOpenIDConfig().EndSessionEndpoint, p.ClientKey, idTokenHint, postLogoutRedirect)
+}
+
 // validate according to standard, returns expiry
 // http://openid.net/specs/openid-connect-core-1_0.html#IDTokenValidation
 func (p *Provider) validateClaims(claims map[string]interface{}) (time.Time, error) {
-	audience := getClaimValue(claims, []string{audienceClaim})
-	if audience != p.ClientKey {
-		found := false
-		audiences := getClaimValues(claims, []string{audienceClaim})
-		for _, aud := range audiences {
-			if aud == p.ClientKey {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return time.Time{}, errors.New("audience in token does not match client key")
-		}
-	}
-
-	issuer := getClaimValue(claims, []string{issuerClaim})
-	if issuer != p.OpenIDConfig.Issuer {
-		return time.Time{}, errors.New("issuer in token does not match issuer in OpenIDConfig discovery")
+	if jwtutil.String(claims, subjectClaim) == "" {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrMissingClaim, subjectClaim)
 	}
 
 	// expiry is required for JWT, not for UserInfoResponse
-	// is actually a int64, so force it in to that type
-	expiryClaim := int64(claims[expiryClaim].(float64))
-	expiry := time.Unix(expiryClaim, 0)
-	if expiry.Add(clockSkew).Before(time.Now()) {
-		return time.Time{}, errors.New("user info JWT token is expired")
-	}
-	return expiry, nil
+	return jwtutil.Validate(claims, jwtutil.Expected{
+		Issuer:        p.CurrentOpenIDConfig().Issuer,
+		Audience:      p.ClientKey,
+		RequireExpiry: true,
+		Leeway:        clockSkew,
+	})
 }
 
 func (p *Provider) userFromClaims(claims map[string]interface{}, user *goth.User) {
@@ -348,15 +473,22 @@ func (p *Provider) userFromClaims(claims map[string]interface{}, user *goth.User
 	user.FirstName = getClaimValue(claims, p.FirstNameClaims)
 	user.LastName = getClaimValue(claims, p.LastNameClaims)
 	user.Location = getClaimValue(claims, p.LocationClaims)
+	user.Groups = getClaimValues(claims, p.GroupsClaims)
+	user.Roles = getClaimValues(claims, p.RolesClaims)
 }
 
 func (p *Provider) getUserInfo(accessToken string, claims map[string]interface{}) error {
-	// skip if there is no UserInfoEndpoint or is explicitly disabled
-	if p.OpenIDConfig.UserInfoEndpoint == "" || p.SkipUserInfoRequest {
+	// skip if there is no UserInfoEndpoint, or if it is explicitly disabled
+	// and the id_token has all the required claims
+	userInfoEndpoint := p.CurrentOpenIDConfig().UserInfoEndpoint
+	if userInfoEndpoint == "" {
+		return nil
+	}
+	if p.SkipUserInfoRequest && len(missingClaims(claims, p.RequiredClaims)) == 0 {
 		return nil
 	}
 
-	userInfoClaims, err := p.fetchUserInfo(p.OpenIDConfig.UserInfoEndpoint, accessToken)
+	userInfoClaims, err := p.fetchUserInfo(userInfoEndpoint, accessToken)
 	if err != nil {
 		return err
 	}
@@ -409,31 +541,6 @@ func (p *Provider) fetchUserInfo(url, accessToken string) (map[string]interface{
 	return unMarshal(data)
 }
 
-func getOpenIDConfig(p *Provider, openIDAutoDiscoveryURL string) (*OpenIDConfig, error) {
-	res, err := p.Client().Get(openIDAutoDiscoveryURL)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return nil, fmt.Errorf("Non-success code for Discovery URL: %d", res.StatusCode)
-	}
-
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	openIDConfig := &OpenIDConfig{}
-	err = json.Unmarshal(body, openIDConfig)
-	if err != nil {
-		return nil, err
-	}
-
-	return openIDConfig, nil
-}
-
 func newConfig(provider *Provider, scopes []string, openIDConfig *OpenIDConfig) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
@@ -466,6 +573,19 @@ func newConfig(provider *Provider, scopes []string, openIDConfig *OpenIDConfig)
 	return c
 }
 
+// missingClaims returns the names of the required claims that are missing
+// from claims, or are null or empty.
+func missingClaims(claims map[string]interface{}, required []string) []string {
+	var missing []string
+	for _, name := range required {
+		value, ok := lookupClaim(claims, name)
+		if s, isString := value.(string); !ok || value == nil || (isString && s == "") {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
 func getClaimValue(data map[string]interface{}, claims []string) string {
 	for _, claim := range claims {
 		if value, ok := data[claim]; ok {
@@ -482,7 +602,7 @@ func getClaimValues(data map[string]interface{}, claims []string) []string {
 	var result []string
 
 	for _, claim := range claims {
-		if value, ok := data[claim]; ok {
+		if value, ok := lookupClaim(data, claim); ok {
 			if stringValues, ok := value.([]interface{}); ok {
 				for _, stringValue := range stringValues {
 					if s, ok := stringValue.(string); ok && len(s) > 0 {
@@ -496,21 +616,26 @@ func getClaimValues(data map[string]interface{}, claims []string) []string {
 	return result
 }
 
-// decodeJWT decodes a JSON Web Token into a simple map
-// http://openid.net/specs/draft-jones-json-web-token-07.html
-func decodeJWT(jwt string) (map[string]interface{}, error) {
-	jwtParts := strings.Split(jwt, ".")
-	if len(jwtParts) != 3 {
-		return nil, errors.New("jws: invalid token received, not all parts available")
+// lookupClaim returns the named claim. If there is no such claim and name
+// contains dots, it is looked up as a path into nested objects.
+func lookupClaim(data map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := data[name]; ok {
+		return value, true
 	}
-
-	decodedPayload, err := base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(jwtParts[1])
-
-	if err != nil {
-		return nil, err
+	if !strings.Contains(name, ".") {
+		return nil, false
 	}
-
-	return unMarshal(decodedPayload)
+	var current interface{} = data
+	for _, part := range strings.Split(name, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
 }
 
 func unMarshal(payload []byte) (map[string]interface{}, error) {
@@ -1,13 +1,17 @@
 package openidConnect
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/testidp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -78,11 +82,65 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "scope=openid")
 }
 
+func Test_LogoutURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider, _ := NewCustomisedURL("client", "secret", "http://localhost/foo", "", "", "", "", "https://idp.example.com/logout?ui_locales=en")
+	url, err := provider.LogoutURL("id-token", "http://localhost/bye")
+	a.NoError(err)
+	a.Equal("https://idp.example.com/logout?client_id=client&id_token_hint=id-token&post_logout_redirect_uri=http%3A%2F%2Flocalhost%2Fbye&ui_locales=en", url)
+
+	provider.OpenIDConfig.EndSessionEndpoint = ""
+	_, err = provider.LogoutURL("id-token", "http://localhost/bye")
+	a.Error(err)
+}
+
 func Test_Implements_Provider(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
 
 	a.Implements((*goth.Provider)(nil), openidConnectProvider())
+	a.Implements((*goth.TokenExchanger)(nil), openidConnectProvider())
+}
+
+func Test_RoundTrip(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+	idp.Claims["name"] = "Homer Simpson"
+	idp.Claims["email"] = "homer@example.com"
+	idp.Claims["groups"] = []string{"family", "plant"}
+	idp.Claims["realm_access"] = map[string]interface{}{"roles": []string{"safety-inspector"}}
+
+	provider, err := New("id", "secret", "http://localhost/foo", idp.DiscoveryURL())
+	a.NoError(err)
+	provider.SetPKCE(true)
+	provider.RolesClaims = []string{"realm_access.roles"}
+
+	session, err := provider.BeginAuth("state")
+	a.NoError(err)
+	authURL, err := session.GetAuthURL()
+	a.NoError(err)
+
+	callback, err := idp.Authorize(authURL)
+	a.NoError(err)
+	_, err = session.Authorize(provider, callback.Query())
+	a.NoError(err)
+
+	user, err := provider.FetchUser(session)
+	a.NoError(err)
+	a.Equal("user", user.UserID)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal([]string{"family", "plant"}, user.Groups)
+	a.Equal([]string{"safety-inspector"}, user.Roles)
+
+	token, err := provider.RefreshToken(user.RefreshToken)
+	a.NoError(err)
+	a.NotEqual(user.AccessToken, token.AccessToken)
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -103,3 +161,157 @@ func openidConnectProvider() *Provider {
 	provider, _ := New(os.Getenv("OPENID_CONNECT_KEY"), os.Getenv("OPENID_CONNECT_SECRET"), "http://localhost/foo", server.URL)
 	return provider
 }
+
+func Test_FetchUser_UserInfoFallback(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+	idp.Claims["email"] = "homer@example.com"
+	idp.Claims["name"] = "Homer Simpson"
+	idp.UserInfoOnlyClaims = []string{"email", "name"}
+
+	provider, err := New("id", "secret", "http://localhost/foo", idp.DiscoveryURL())
+	a.NoError(err)
+	provider.SkipUserInfoRequest = true
+
+	fetch := func() (goth.User, error) {
+		session, err := provider.BeginAuth("state")
+		a.NoError(err)
+		authURL, err := session.GetAuthURL()
+		a.NoError(err)
+		callback, err := idp.Authorize(authURL)
+		a.NoError(err)
+		_, err = session.Authorize(provider, callback.Query())
+		a.NoError(err)
+		return provider.FetchUser(session)
+	}
+
+	// the id_token is enough without required claims
+	user, err := fetch()
+	a.NoError(err)
+	a.Equal("user", user.UserID)
+	a.Equal("", user.Email)
+
+	// the userinfo endpoint is requested for the missing ones
+	provider.RequiredClaims = []string{"email"}
+	user, err = fetch()
+	a.NoError(err)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("Homer Simpson", user.Name)
+
+	// and the user is rejected if it lacks them too
+	provider.RequiredClaims = []string{"email", "phone_number"}
+	_, err = fetch()
+	a.ErrorIs(err, ErrMissingClaim)
+	a.Contains(err.Error(), "phone_number")
+}
+
+func Test_ValidateClaims_Missing(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := openidConnectProvider()
+
+	claims := map[string]interface{}{
+		"aud": provider.ClientKey,
+		"iss": provider.OpenIDConfig.Issuer,
+		"sub": "user",
+	}
+	_, err := provider.validateClaims(claims)
+	a.ErrorIs(err, ErrMissingClaim)
+	a.Contains(err.Error(), "exp")
+
+	delete(claims, "sub")
+	claims["exp"] = nil
+	_, err = provider.validateClaims(claims)
+	a.ErrorIs(err, ErrMissingClaim)
+	a.Contains(err.Error(), "sub")
+}
+
+func Test_SetClientAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var form url.Values
+	var user, pass string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		user, pass, _ = r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"access","token_type":"Bearer","refresh_token":"refresh"}`)
+	}))
+	defer ts.Close()
+
+	provider, err := NewCustomisedURL("id", "secret", "http://localhost/foo", ts.URL+"/auth", ts.URL+"/token", ts.URL, "", "")
+	a.NoError(err)
+	provider.OpenIDConfig.TokenEndpointAuthMethodsSupported = []string{goth.AuthMethodClientSecretBasic, goth.AuthMethodPrivateKeyJWT}
+
+	a.Error(provider.SetClientAuth(goth.ClientAuth{Method: goth.AuthMethodClientSecretPost}))
+
+	a.NoError(provider.SetClientAuth(goth.ClientAuth{Method: goth.AuthMethodClientSecretBasic}))
+	_, err = provider.RefreshTokenWithIDToken("refresh")
+	a.NoError(err)
+	a.Equal("id", user)
+	a.Equal("secret", pass)
+	a.Empty(form.Get("client_secret"))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	a.NoError(err)
+	a.NoError(provider.SetClientAuth(goth.ClientAuth{Method: goth.AuthMethodPrivateKeyJWT, Key: key}))
+	_, err = provider.RefreshToken("refresh")
+	a.NoError(err)
+	a.Equal("", user)
+	a.Equal("id", form.Get("client_id"))
+	a.Empty(form.Get("client_secret"))
+	a.NotEmpty(form.Get("client_assertion"))
+}
+
+func Test_RoundTripAcrossInstances(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+
+	newProvider := func() *Provider {
+		provider, err := New("id", "secret", "http://localhost/foo", idp.DiscoveryURL())
+		a.NoError(err)
+		provider.SetPKCE(true)
+		return provider
+	}
+
+	// the callback is handled by another instance than the one which began
+	// the authentication, with nothing but the marshalled session
+	session, err := newProvider().BeginAuth("state")
+	a.NoError(err)
+	authURL, err := session.GetAuthURL()
+	a.NoError(err)
+	u, err := url.Parse(authURL)
+	a.NoError(err)
+	a.Equal(session.(*Session).Nonce, u.Query().Get("nonce"))
+
+	callback, err := idp.Authorize(authURL)
+	a.NoError(err)
+
+	other := newProvider()
+	restored, err := other.UnmarshalSession(session.Marshal())
+	a.NoError(err)
+	_, err = restored.Authorize(other, callback.Query())
+	a.NoError(err)
+	user, err := other.FetchUser(restored)
+	a.NoError(err)
+	a.Equal("user", user.UserID)
+
+	// an id_token issued for another authorization request is refused
+	session, err = newProvider().BeginAuth("state")
+	a.NoError(err)
+	authURL, _ = session.GetAuthURL()
+	callback, err = idp.Authorize(authURL)
+	a.NoError(err)
+	session.(*Session).Nonce = "other"
+	_, err = session.Authorize(other, callback.Query())
+	a.Error(err)
+}
@@ -3,10 +3,13 @@ package openidConnect
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/jwtutil"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -17,6 +20,28 @@ type Session struct {
 	RefreshToken string
 	ExpiresAt    time.Time
 	IDToken      string
+	CodeVerifier string `json:",omitempty"`
+	// Nonce is the nonce sent in the authorization request, which the
+	// id_token received for it must carry.
+	Nonce string `json:",omitempty"`
+	// Debug is what was captured while authorizing the session, if the
+	// provider was in debug mode.
+	Debug *goth.DebugInfo `json:",omitempty"`
+}
+
+// DebugInfo implements goth.DebugSession.
+func (s Session) DebugInfo() *goth.DebugInfo {
+	return s.Debug
+}
+
+// client returns the client authorizing the session, which records its
+// requests in Debug if the provider is in debug mode.
+func (s *Session) client(p *Provider) *http.Client {
+	if !p.debug {
+		return p.Client()
+	}
+	s.Debug = &goth.DebugInfo{}
+	return goth.DebugClient(p.Client(), s.Debug)
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the OpenID Connect provider.
@@ -41,11 +66,14 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 
 	// set code_verifier if passed as param
 	codeVerifier := params.Get("code_verifier")
+	if codeVerifier == "" {
+		codeVerifier = s.CodeVerifier
+	}
 	if codeVerifier != "" {
 		authParams = append(authParams, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
 	}
 
-	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"), authParams...)
+	token, err := p.oauth2Config().Exchange(goth.ContextForClient(s.client(p)), params.Get("code"), authParams...)
 	if err != nil {
 		return "", err
 	}
@@ -57,12 +85,33 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
-	if idToken := token.Extra("id_token"); idToken != nil {
-		s.IDToken = idToken.(string)
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		if err := s.checkNonce(idToken); err != nil {
+			return "", err
+		}
+		s.IDToken = idToken
 	}
 	return token.AccessToken, err
 }
 
+// checkNonce checks that idToken, received in exchange for the authorization
+// code, carries the nonce of the session. Its signature is verified by
+// FetchUser. Sessions without a nonce, created before nonces were sent,
+// aren't checked.
+func (s *Session) checkNonce(idToken string) error {
+	if s.Nonce == "" {
+		return nil
+	}
+	claims, err := jwtutil.Decode(idToken)
+	if err != nil {
+		return err
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != s.Nonce {
+		return errors.New("id_token nonce does not match the one sent in the authorization request")
+	}
+	return nil
+}
+
 // Marshal the session into a string
 func (s Session) Marshal() string {
 	b, _ := json.Marshal(s)
@@ -79,3 +128,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
 	return sess, err
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
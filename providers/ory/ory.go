@@ -0,0 +1,164 @@
+// Package ory implements the OpenID Connect protocol for authenticating users
+// through Ory, either Ory Network or a self-hosted Ory Hydra with Ory Kratos
+// as its identity server.
+package ory
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/openidConnect"
+)
+
+// Claims added by Ory to the id_token and the userinfo response, available in
+// User.RawData.
+const (
+	// ClaimTraits holds the traits of the Kratos identity, as shaped by its
+	// identity schema, when the consent app adds them to the id_token.
+	ClaimTraits = "traits"
+	// ClaimMetadataPublic holds the public metadata of the Kratos identity.
+	ClaimMetadataPublic = "metadata_public"
+	// ClaimSessionID is the ID of the Ory session the user signed in with.
+	ClaimSessionID = "sid"
+	// ClaimAMR lists the methods the user authenticated with, e.g. "pwd" or
+	// "totp", and ClaimACR the resulting authenticator assurance level, e.g.
+	// "aal2".
+	ClaimAMR = "amr"
+	ClaimACR = "acr"
+)
+
+// revocationPath is where Hydra serves token revocation, used if the discovery
+// document doesn't publish a revocation_endpoint.
+const revocationPath = "/oauth2/revoke"
+
+// Provider is the implementation of `goth.Provider` for accessing Ory. It is
+// an openidConnect.Provider with PKCE enabled, which also maps the traits of
+// Kratos identities and can revoke tokens.
+type Provider struct {
+	*openidConnect.Provider
+}
+
+// New creates a new Ory provider for the project or Hydra instance at
+// issuerURL, e.g. https://<project>.projects.oryapis.com, whose endpoints are
+// discovered from its OpenID Connect discovery document. You should always
+// call `ory.New` to get a new Provider. Never try to create one manually.
+func New(clientKey, secret, callbackURL, issuerURL string, scopes ...string) (*Provider, error) {
+	discoveryURL := strings.TrimSuffix(issuerURL, "/") + "/.well-known/openid-configuration"
+	p, err := openidConnect.New(clientKey, secret, callbackURL, discoveryURL, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	p.SetName("ory")
+	p.SetPKCE(true)
+	return &Provider{Provider: p}, nil
+}
+
+// WithCallbackURL returns a copy of the provider sending users back to
+// callbackURL.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	return &Provider{Provider: p.Provider.WithCallbackURL(callbackURL).(*openidConnect.Provider)}
+}
+
+// BeginAuth asks Ory for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.BeginAuthWithParams(state, nil)
+}
+
+// BeginAuthWithParams is like BeginAuth, adding params (e.g. login_hint or
+// prompt) to the authentication end-point.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
+	session, err := p.Provider.BeginAuthWithParams(state, params)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{Session: *session.(*openidConnect.Session)}, nil
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	session, err := p.Provider.UnmarshalSession(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{Session: *session.(*openidConnect.Session)}, nil
+}
+
+// FetchUser verifies the id_token of the session and merges it with the
+// response of the userinfo endpoint. The standard claims are used first, and
+// the email, username, name and picture traits of the Kratos identity fill the
+// fields they left empty. All the claims, including the traits and the public
+// metadata of the identity, are available in RawData.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user, err := p.Provider.FetchUser(&sess.Session)
+	if err != nil {
+		return user, err
+	}
+	user.Provider = p.Name()
+
+	if traits, ok := user.RawData[ClaimTraits].(map[string]interface{}); ok {
+		userFromTraits(traits, &user)
+	}
+	return user, nil
+}
+
+// userFromTraits fills the fields of user left empty by the standard claims
+// from traits, following the shapes of the identity schemas Ory provides.
+func userFromTraits(traits map[string]interface{}, user *goth.User) {
+	trait := func(v interface{}) string {
+		s, _ := v.(string)
+		return s
+	}
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+
+	fill(&user.Email, trait(traits["email"]))
+	fill(&user.NickName, trait(traits["username"]))
+	fill(&user.AvatarURL, trait(traits["picture"]))
+
+	switch name := traits["name"].(type) {
+	case string:
+		fill(&user.Name, name)
+	case map[string]interface{}:
+		fill(&user.FirstName, trait(name["first"]))
+		fill(&user.LastName, trait(name["last"]))
+		fill(&user.Name, strings.TrimSpace(user.FirstName+" "+user.LastName))
+	}
+}
+
+// RevokeToken revokes the access or refresh token. Revoking a refresh token
+// also revokes the access tokens issued with it.
+// See https://www.ory.sh/docs/hydra/guides/oauth2-token-revocation
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	config := p.CurrentOpenIDConfig()
+	revokeURL := config.RevocationEndpoint
+	if revokeURL == "" {
+		revokeURL = strings.TrimSuffix(config.Issuer, "/") + revocationPath
+	}
+
+	form := url.Values{"token": {token}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(p.ClientKey, p.Secret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to revoke the token", p.Name(), response.StatusCode)
+	}
+	return nil
+}
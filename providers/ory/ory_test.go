@@ -0,0 +1,112 @@
+package ory_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/testidp"
+	"github.com/markbates/goth/providers/ory"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+
+	p, err := ory.New("id", "secret", "http://localhost/foo", idp.URL+"/")
+	a.NoError(err)
+	a.Equal("ory", p.Name())
+	a.Equal(idp.Issuer(), p.OpenIDConfig.Issuer)
+	a.Equal(idp.RevocationURL(), p.OpenIDConfig.RevocationEndpoint)
+	a.Implements((*goth.Provider)(nil), p)
+	a.Implements((*goth.TokenRevoker)(nil), p)
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+	p, err := ory.New("id", "secret", "http://localhost/foo", idp.URL)
+	a.NoError(err)
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*ory.Session)
+	a.NotEmpty(s.CodeVerifier)
+	u, err := url.Parse(s.AuthURL)
+	a.NoError(err)
+	a.Equal(idp.AuthURL(), u.Scheme+"://"+u.Host+u.Path)
+	a.Equal("S256", u.Query().Get("code_challenge_method"))
+
+	session, err = p.UnmarshalSession(s.Marshal())
+	a.NoError(err)
+	a.Equal(s, session.(*ory.Session))
+}
+
+func Test_RoundTrip(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+	idp.Claims["sid"] = "session-id"
+	idp.Claims["amr"] = []string{"pwd"}
+	idp.Claims["traits"] = map[string]interface{}{
+		"email":    "homer@example.com",
+		"username": "homer",
+		"name":     map[string]string{"first": "Homer", "last": "Simpson"},
+	}
+	idp.Claims["metadata_public"] = map[string]interface{}{"plan": "pro"}
+
+	p, err := ory.New("id", "secret", "http://localhost/foo", idp.URL)
+	a.NoError(err)
+
+	session, err := p.BeginAuth("state")
+	a.NoError(err)
+	authURL, err := session.GetAuthURL()
+	a.NoError(err)
+	callback, err := idp.Authorize(authURL)
+	a.NoError(err)
+	_, err = session.Authorize(p, callback.Query())
+	a.NoError(err)
+
+	user, err := p.FetchUser(session)
+	a.NoError(err)
+	a.Equal("ory", user.Provider)
+	a.Equal("user", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("homer", user.NickName)
+	a.Equal("Homer", user.FirstName)
+	a.Equal("Simpson", user.LastName)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("session-id", user.RawData[ory.ClaimSessionID])
+	a.Equal(map[string]interface{}{"plan": "pro"}, user.RawData[ory.ClaimMetadataPublic])
+
+	logoutURL, err := p.LogoutURL(user.IDToken, "http://localhost/bye")
+	a.NoError(err)
+	a.Contains(logoutURL, idp.EndSessionURL()+"?")
+
+	a.NoError(goth.RevokeToken(context.Background(), p, user.RefreshToken))
+	_, err = p.RefreshToken(user.RefreshToken)
+	a.Error(err, "the refresh token must have been revoked")
+}
+
+func Test_RevokeTokenError(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	idp := testidp.New("id", "secret")
+	defer idp.Close()
+	p, err := ory.New("id", "wrong", "http://localhost/foo", idp.URL)
+	a.NoError(err)
+
+	err = p.RevokeToken(context.Background(), "token")
+	a.EqualError(err, "ory responded with a 401 trying to revoke the token")
+}
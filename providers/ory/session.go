@@ -0,0 +1,18 @@
+package ory
+
+import (
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/openidConnect"
+)
+
+// Session stores data during the auth process with Ory.
+type Session struct {
+	openidConnect.Session
+}
+
+// Authorize the session with Ory and return the access token to be stored for
+// future use.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	return s.Session.Authorize(p.Provider, params)
+}
@@ -1,34 +1,41 @@
-// Package paypal implements the OAuth2 protocol for authenticating users through paypal.
-// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+// Package paypal implements the OAuth2 protocol for authenticating users through
+// Log in with PayPal.
+// See https://developer.paypal.com/docs/log-in-with-paypal/
 package paypal
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"net/url"
-	"os"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
 const (
-	sandbox string = "sandbox"
-	envKey  string = "PAYPAL_ENV"
-
 	// Endpoints for paypal sandbox env
-	authURLSandbox         string = "https://www.sandbox.paypal.com/webapps/auth/protocol/openidconnect/v1/authorize"
-	tokenURLSandbox        string = "https://www.sandbox.paypal.com/webapps/auth/protocol/openidconnect/v1/tokenservice"
-	endpointProfileSandbox string = "https://www.sandbox.paypal.com/webapps/auth/protocol/openidconnect/v1/userinfo"
+	authURLSandbox         string = "https://www.sandbox.paypal.com/signin/authorize"
+	tokenURLSandbox        string = "https://api-m.sandbox.paypal.com/v1/oauth2/token"
+	endpointProfileSandbox string = "https://api-m.sandbox.paypal.com/v1/identity/openidconnect/userinfo"
 
 	// Endpoints for paypal production env
-	authURLProduction         string = "https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/authorize"
-	tokenURLProduction        string = "https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/tokenservice"
-	endpointProfileProduction string = "https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/userinfo"
+	authURLProduction         string = "https://www.paypal.com/signin/authorize"
+	tokenURLProduction        string = "https://api-m.paypal.com/v1/oauth2/token"
+	endpointProfileProduction string = "https://api-m.paypal.com/v1/identity/openidconnect/userinfo"
+)
+
+// Scopes of Log in with PayPal, which have to be enabled for the application
+// as well.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopeAddress = "address"
 )
 
 // Provider is the implementation of `goth.Provider` for accessing Paypal.
@@ -44,21 +51,16 @@ type Provider struct {
 
 // New creates a new Paypal provider and sets up important connection details.
 // You should always call `paypal.New` to get a new provider.  Never try to
-// create one manually.
+// create one manually. The openid, profile and email scopes are asked for if
+// none are given.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
-	paypalEnv := os.Getenv(envKey)
-
-	authURL := authURLProduction
-	tokenURL := tokenURLProduction
-	profileEndPoint := endpointProfileProduction
-
-	if paypalEnv == sandbox {
-		authURL = authURLSandbox
-		tokenURL = tokenURLSandbox
-		profileEndPoint = endpointProfileSandbox
-	}
+	return NewCustomisedURL(clientKey, secret, callbackURL, authURLProduction, tokenURLProduction, endpointProfileProduction, scopes...)
+}
 
-	return NewCustomisedURL(clientKey, secret, callbackURL, authURL, tokenURL, profileEndPoint, scopes...)
+// NewSandbox is similar to New(...) but signs users in with the accounts of
+// the PayPal sandbox, for the credentials of sandbox applications.
+func NewSandbox(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	return NewCustomisedURL(clientKey, secret, callbackURL, authURLSandbox, tokenURLSandbox, endpointProfileSandbox, scopes...)
 }
 
 // NewCustomisedURL is similar to New(...) but can be used to set custom URLs to connect to
@@ -84,6 +86,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -113,11 +123,13 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	response, err := p.Client().Get(p.profileURL + "?schema=openid&access_token=" + url.QueryEscape(sess.AccessToken))
+	req, err := http.NewRequest("GET", p.profileURL+"?schema=openid", nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+	response, err := p.Client().Do(req)
 	if err != nil {
-		if response != nil {
-			response.Body.Close()
-		}
 		return user, err
 	}
 	defer response.Body.Close()
@@ -158,15 +170,17 @@ func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *o
 			c.Scopes = append(c.Scopes, scope)
 		}
 	} else {
-		c.Scopes = append(c.Scopes, "profile", "email")
+		c.Scopes = append(c.Scopes, ScopeOpenID, ScopeProfile, ScopeEmail)
 	}
 	return c
 }
 
 func userFromReader(r io.Reader, user *goth.User) error {
 	u := struct {
-		Name    string `json:"name"`
-		Address struct {
+		Name       string `json:"name"`
+		GivenName  string `json:"given_name"`
+		FamilyName string `json:"family_name"`
+		Address    struct {
 			Locality string `json:"locality"`
 		} `json:"address"`
 		Email string `json:"email"`
@@ -178,6 +192,8 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	}
 	user.Email = u.Email
 	user.Name = u.Name
+	user.FirstName = u.GivenName
+	user.LastName = u.FamilyName
 	user.UserID = u.ID
 	user.Location = u.Address.Locality
 	return nil
@@ -190,11 +206,10 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
@@ -1,6 +1,8 @@
 package paypal_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -42,7 +44,40 @@ func Test_BeginAuth(t *testing.T) {
 	session, err := p.BeginAuth("test_state")
 	s := session.(*paypal.Session)
 	a.NoError(err)
-	a.Contains(s.AuthURL, "paypal.com/webapps/auth/protocol/openidconnect/v1/authorize")
+	a.Contains(s.AuthURL, "https://www.paypal.com/signin/authorize")
+	a.Contains(s.AuthURL, "scope=openid+profile+email")
+}
+
+func Test_NewSandbox(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := paypal.NewSandbox(os.Getenv("PAYPAL_KEY"), os.Getenv("PAYPAL_SECRET"), "/foo")
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*paypal.Session).AuthURL, "https://www.sandbox.paypal.com/signin/authorize")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/v1/identity/openidconnect/userinfo", r.URL.Path)
+		a.Equal("openid", r.URL.Query().Get("schema"))
+		a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"user_id":"https://www.paypal.com/webapps/auth/identity/user/abc","name":"Homer Simpson","given_name":"Homer","family_name":"Simpson","email":"homer@example.com","address":{"locality":"Springfield"}}`))
+	}))
+	defer ts.Close()
+
+	p := paypal.NewCustomisedURL("key", "secret", "/foo", ts.URL+"/signin/authorize", ts.URL+"/v1/oauth2/token", ts.URL+"/v1/identity/openidconnect/userinfo")
+	user, err := p.FetchUser(&paypal.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("https://www.paypal.com/webapps/auth/identity/user/abc", user.UserID)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("Homer", user.FirstName)
+	a.Equal("Simpson", user.LastName)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("Springfield", user.Location)
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -50,11 +85,11 @@ func Test_SessionFromJSON(t *testing.T) {
 	a := assert.New(t)
 
 	p := provider()
-	session, err := p.UnmarshalSession(`{"AuthURL":"https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/authorize","AccessToken":"1234567890"}`)
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://www.paypal.com/signin/authorize","AccessToken":"1234567890"}`)
 	a.NoError(err)
 
 	s := session.(*paypal.Session)
-	a.Equal(s.AuthURL, "https://www.paypal.com/webapps/auth/protocol/openidconnect/v1/authorize")
+	a.Equal(s.AuthURL, "https://www.paypal.com/signin/authorize")
 	a.Equal(s.AccessToken, "1234567890")
 }
 
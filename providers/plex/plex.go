@@ -0,0 +1,270 @@
+// Package plex implements the PIN based flow of plex.tv for authenticating
+// users through their Plex account.
+//
+// BeginAuth creates a PIN, and the authorization URL is the page of
+// app.plex.tv where the user signs in, linking the PIN to their account,
+// before being sent back to the callback URL. Authorize then polls the PIN
+// until it holds the token of the user.
+// See https://forums.plex.tv/t/authenticating-with-plex/609370
+package plex
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+const (
+	authURL         string = "https://app.plex.tv/auth"
+	endpointPins    string = "https://plex.tv/api/v2/pins"
+	endpointProfile string = "https://plex.tv/api/v2/user"
+)
+
+const (
+	// defaultPollTimeout is how long Authorize waits for the PIN to be linked
+	// to the account of the user, unless set with PollTimeout.
+	defaultPollTimeout = 10 * time.Second
+	pollInterval       = time.Second
+)
+
+// New creates a new Plex provider. clientIdentifier is the unique and stable
+// identifier of the application, e.g. a UUID, which plex.tv lists among the
+// devices of the users.
+func New(clientIdentifier, callbackURL string) *Provider {
+	return &Provider{
+		ClientIdentifier: clientIdentifier,
+		Product:          "goth",
+		CallbackURL:      callbackURL,
+		providerName:     "plex",
+	}
+}
+
+// Provider is the implementation of `goth.Provider` for accessing Plex.
+type Provider struct {
+	ClientIdentifier string
+	// Product is the name of the application shown to the users when they
+	// sign in.
+	Product     string
+	CallbackURL string
+	HTTPClient  *http.Client
+	// PollTimeout is how long Authorize waits for the PIN to be linked to
+	// the account of the user, 10 seconds if zero. The PIN is checked once
+	// if it's negative.
+	PollTimeout  time.Duration
+	providerName string
+}
+
+var _ goth.Provider = &Provider{}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	return &c
+}
+
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the plex package.
+func (p *Provider) Debug(debug bool) {}
+
+// pin is a PIN of plex.tv, whose auth token is set once the user linked it
+// to their account.
+type pin struct {
+	ID        int64     `json:"id"`
+	Code      string    `json:"code"`
+	AuthToken string    `json:"authToken"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// BeginAuth creates a PIN and returns a session whose auth URL is the page
+// of app.plex.tv linking it. The user is sent back to the callback URL, with
+// the state as a query parameter, once they signed in.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	req, err := p.newRequest("POST", endpointPins, "", strings.NewReader(url.Values{"strong": {"true"}}.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	created := &pin{}
+	if err := p.do(req, "create a PIN", created); err != nil {
+		return nil, err
+	}
+
+	forward, err := url.Parse(p.CallbackURL)
+	if err != nil {
+		return nil, err
+	}
+	q := forward.Query()
+	q.Set("state", state)
+	forward.RawQuery = q.Encode()
+
+	// the parameters are read by the JavaScript of app.plex.tv, hence the
+	// fragment
+	params := url.Values{
+		"clientID":                 {p.ClientIdentifier},
+		"code":                     {created.Code},
+		"forwardUrl":               {forward.String()},
+		"context[device][product]": {p.Product},
+	}
+	return &Session{
+		AuthURL: authURL + "#?" + params.Encode(),
+		PinID:   created.ID,
+		PinCode: created.Code,
+	}, nil
+}
+
+// checkPin waits for the PIN to be linked to the account of the user, and
+// returns its auth token.
+func (p *Provider) checkPin(id int64, code string) (string, error) {
+	timeout := p.PollTimeout
+	if timeout == 0 {
+		timeout = defaultPollTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		req, err := p.newRequest("GET", endpointPins+"/"+strconv.FormatInt(id, 10)+"?"+url.Values{"code": {code}}.Encode(), "", nil)
+		if err != nil {
+			return "", err
+		}
+		linked := &pin{}
+		if err := p.do(req, "check the PIN", linked); err != nil {
+			return "", err
+		}
+		if linked.AuthToken != "" {
+			return linked.AuthToken, nil
+		}
+		if !linked.ExpiresAt.IsZero() && linked.ExpiresAt.Before(goth.Now()) {
+			return "", errors.New("plex: the PIN expired before being linked to an account")
+		}
+		if time.Now().Add(pollInterval).After(deadline) {
+			return "", errors.New("plex: the PIN was not linked to an account")
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// FetchUser will go to Plex and access basic information about the user.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken: sess.AuthToken,
+		Provider:    p.Name(),
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := p.newRequest("GET", endpointProfile, sess.AuthToken, nil)
+	if err != nil {
+		return user, err
+	}
+
+	var raw json.RawMessage
+	if err := p.do(req, "fetch user information", &raw); err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(raw)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	err = userFromReader(bytes.NewReader(raw), &user)
+	return user, err
+}
+
+func userFromReader(reader io.Reader, user *goth.User) error {
+	u := struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+		Title    string `json:"title"`
+		Email    string `json:"email"`
+		Thumb    string `json:"thumb"`
+	}{}
+
+	err := json.NewDecoder(reader).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = strconv.FormatInt(u.ID, 10)
+	user.NickName = u.Username
+	user.Name = u.Title
+	user.Email = u.Email
+	user.AvatarURL = u.Thumb
+
+	return err
+}
+
+// newRequest returns a request to plex.tv, identifying the application and,
+// if token is set, the user.
+func (p *Provider) newRequest(method, url, token string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Plex-Product", p.Product)
+	req.Header.Set("X-Plex-Client-Identifier", p.ClientIdentifier)
+	if token != "" {
+		req.Header.Set("X-Plex-Token", token)
+	}
+	return req, nil
+}
+
+// do makes the request and decodes its JSON response into v.
+func (p *Provider) do(req *http.Request, action string, v interface{}) error {
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s responded with a %d trying to %s", p.providerName, resp.StatusCode, action)
+	}
+
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bits, v)
+}
+
+// RefreshTokenAvailable refresh token is not provided by plex
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+// RefreshToken refresh token is not provided by plex
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by plex")
+}
@@ -0,0 +1,142 @@
+package plex_test
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/plex"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := plexProvider(nil)
+	a.Equal(provider.ClientIdentifier, "plex_client")
+	a.Equal(provider.Product, "goth")
+	a.Equal(provider.CallbackURL, "http://localhost/auth/plex/callback")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Implements((*goth.Provider)(nil), plexProvider(nil))
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := plexProvider(func(req *http.Request) (*http.Response, error) {
+		a.Equal("POST", req.Method)
+		a.Equal("https://plex.tv/api/v2/pins", req.URL.String())
+		a.Equal("plex_client", req.Header.Get("X-Plex-Client-Identifier"))
+		a.Equal("goth", req.Header.Get("X-Plex-Product"))
+		a.NoError(req.ParseForm())
+		a.Equal("true", req.PostForm.Get("strong"))
+		return response(http.StatusCreated, `{"id":42,"code":"pincode","authToken":null}`), nil
+	})
+
+	session, err := provider.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*plex.Session)
+	a.Equal(int64(42), s.PinID)
+	a.Equal("pincode", s.PinCode)
+
+	authURL, err := url.Parse(s.AuthURL)
+	a.NoError(err)
+	a.Equal("app.plex.tv", authURL.Host)
+	params, err := url.ParseQuery(strings.TrimPrefix(authURL.Fragment, "?"))
+	a.NoError(err)
+	a.Equal("plex_client", params.Get("clientID"))
+	a.Equal("pincode", params.Get("code"))
+	a.Equal("http://localhost/auth/plex/callback?state=test_state", params.Get("forwardUrl"))
+	a.Equal("goth", params.Get("context[device][product]"))
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := plexProvider(func(req *http.Request) (*http.Response, error) {
+		a.Equal("https://plex.tv/api/v2/pins/42?code=pincode", req.URL.String())
+		return response(http.StatusOK, `{"id":42,"code":"pincode","authToken":"token"}`), nil
+	})
+
+	s := &plex.Session{PinID: 42, PinCode: "pincode"}
+	token, err := s.Authorize(provider, url.Values{"state": {"test_state"}})
+	a.NoError(err)
+	a.Equal("token", token)
+	a.Equal("token", s.AuthToken)
+
+	provider = plexProvider(func(req *http.Request) (*http.Response, error) {
+		return response(http.StatusOK, `{"id":42,"code":"pincode","authToken":null}`), nil
+	})
+	provider.PollTimeout = -1
+	_, err = (&plex.Session{PinID: 42, PinCode: "pincode"}).Authorize(provider, url.Values{})
+	a.Error(err)
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := plexProvider(func(req *http.Request) (*http.Response, error) {
+		a.Equal("https://plex.tv/api/v2/user", req.URL.String())
+		a.Equal("token", req.Header.Get("X-Plex-Token"))
+		a.Equal("application/json", req.Header.Get("Accept"))
+		body := `{"id":1234,"uuid":"abcdef","username":"homer","title":"Homer Simpson","email":"homer@example.com","thumb":"https://plex.tv/users/abcdef/avatar"}`
+		return response(http.StatusOK, body), nil
+	})
+
+	user, err := provider.FetchUser(&plex.Session{AuthToken: "token"})
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal("homer", user.NickName)
+	a.Equal("Homer Simpson", user.Name)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("https://plex.tv/users/abcdef/avatar", user.AvatarURL)
+	a.Equal("abcdef", user.RawData["uuid"])
+	a.Equal("token", user.AccessToken)
+
+	_, err = provider.FetchUser(&plex.Session{})
+	a.Error(err)
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := plexProvider(nil)
+
+	s, err := provider.UnmarshalSession(`{"AuthURL":"https://app.plex.tv/auth#?code=pincode","PinID":42,"PinCode":"pincode","AuthToken":"token"}`)
+	a.NoError(err)
+	session := s.(*plex.Session)
+	a.Equal(session.AuthURL, "https://app.plex.tv/auth#?code=pincode")
+	a.Equal(session.PinID, int64(42))
+	a.Equal(session.AuthToken, "token")
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func response(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func plexProvider(rt roundTripper) *plex.Provider {
+	p := plex.New("plex_client", "http://localhost/auth/plex/callback")
+	if rt != nil {
+		p.HTTPClient = &http.Client{Transport: rt}
+	}
+	return p
+}
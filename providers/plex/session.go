@@ -0,0 +1,61 @@
+package plex
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/markbates/goth"
+)
+
+// Session stores data during the auth process with Plex.
+type Session struct {
+	AuthURL   string
+	PinID     int64
+	PinCode   string
+	AuthToken string
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the Plex provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize waits for the PIN of the session to be linked to the account of
+// the user, and returns the auth token of the user. Plex doesn't pass any
+// parameter to the callback URL besides the state, so params aren't used.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	if s.PinID == 0 {
+		return "", errors.New("plex: the session has no PIN")
+	}
+
+	token, err := p.checkPin(s.PinID, s.PinCode)
+	if err != nil {
+		return "", err
+	}
+	s.AuthToken = token
+	return token, nil
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return s.Marshal()
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	sess := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
+	return sess, err
+}
@@ -0,0 +1,39 @@
+package plex_test
+
+import (
+	"testing"
+
+	"github.com/markbates/goth/providers/plex"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_GetAuthURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	s := &plex.Session{}
+
+	_, err := s.GetAuthURL()
+	a.Error(err)
+
+	s.AuthURL = "/foo"
+
+	url, _ := s.GetAuthURL()
+	a.Equal(url, "/foo")
+}
+
+func Test_ToJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	s := &plex.Session{}
+
+	data := s.Marshal()
+	a.Equal(data, `{"AuthURL":"","PinID":0,"PinCode":"","AuthToken":""}`)
+}
+
+func Test_String(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	s := &plex.Session{}
+
+	a.Equal(s.String(), s.Marshal())
+}
@@ -7,7 +7,6 @@ import (
 	"golang.org/x/oauth2"
 	"io"
 	"net/http"
-	"time"
 )
 
 const (
@@ -51,6 +50,13 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.config.RedirectURL = callbackURL
+	return &c
+}
+
 func (p *Provider) UnmarshalSession(s string) (goth.Session, error) {
 	session := &Session{}
 	err := json.Unmarshal([]byte(s), session)
@@ -124,7 +130,7 @@ func (p *Provider) FetchUser(s goth.Session) (goth.User, error) {
 		UserID:       r.Id,
 		AccessToken:  session.AccessToken,
 		RefreshToken: session.RefreshToken,
-		ExpiresAt:    time.Time{},
+		ExpiresAt:    session.Expiry,
 	}
 
 	err = json.Unmarshal(bits, &gothUser.RawData)
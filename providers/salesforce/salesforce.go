@@ -4,13 +4,16 @@ package salesforce
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -28,6 +31,12 @@ var (
 	// endpointProfile    string = "https://api.salesforce.com/2.0/users/me"
 )
 
+// Login URLs of production orgs and sandboxes.
+const (
+	ProductionURL = "https://login.salesforce.com"
+	SandboxURL    = "https://test.salesforce.com"
+)
+
 // Provider is the implementation of `goth.Provider` for accessing Salesforce.
 type Provider struct {
 	ClientKey    string
@@ -42,13 +51,32 @@ type Provider struct {
 // You should always call `salesforce.New` to get a new provider.  Never try to
 // create one manually.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	return NewCustomisedURL(clientKey, secret, callbackURL, AuthURL, TokenURL, scopes...)
+}
+
+// NewSandbox is similar to New(...) but signs users in to a sandbox through
+// test.salesforce.com.
+func NewSandbox(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	return NewWithDomain(clientKey, secret, callbackURL, SandboxURL, scopes...)
+}
+
+// NewWithDomain is similar to New(...) but uses the OAuth endpoints of the given
+// domain, e.g. a My Domain URL like https://acme.my.salesforce.com or an
+// Experience Cloud site URL.
+func NewWithDomain(clientKey, secret, callbackURL, domainURL string, scopes ...string) *Provider {
+	domainURL = strings.TrimSuffix(domainURL, "/")
+	return NewCustomisedURL(clientKey, secret, callbackURL, domainURL+"/services/oauth2/authorize", domainURL+"/services/oauth2/token", scopes...)
+}
+
+// NewCustomisedURL is similar to New(...) but can be used to set custom URLs to connect to
+func NewCustomisedURL(clientKey, secret, callbackURL, authURL, tokenURL string, scopes ...string) *Provider {
 	p := &Provider{
 		ClientKey:    clientKey,
 		Secret:       secret,
 		CallbackURL:  callbackURL,
 		providerName: "salesforce",
 	}
-	p.config = newConfig(p, scopes)
+	p.config = newConfig(p, authURL, tokenURL, scopes)
 	return p
 }
 
@@ -62,6 +90,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -83,6 +119,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		AccessToken:  s.AccessToken,
 		Provider:     p.Name(),
 		RefreshToken: s.RefreshToken,
+		ExpiresAt:    s.ExpiresAt,
 	}
 
 	if user.AccessToken == "" {
@@ -90,13 +127,16 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	url, err := url.Parse(s.ID)
+	idURL, err := url.Parse(s.ID)
 	if err != nil {
 		return user, err
 	}
+	if !p.trustedHost(idURL.Host, s.InstanceURL) {
+		return user, fmt.Errorf("%s returned an identity URL on the unexpected host %s", p.providerName, idURL.Host)
+	}
 
 	// creating dynamic url to retrieve user information
-	userURL := url.Scheme + "://" + url.Host + "/" + url.Path
+	userURL := idURL.Scheme + "://" + idURL.Host + "/" + idURL.Path
 	req, err := http.NewRequest("GET", userURL, nil)
 	if err != nil {
 		return user, err
@@ -113,17 +153,43 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	err = userFromReader(resp.Body, &user)
-	return user, err
+	if err != nil {
+		return user, err
+	}
+
+	if s.InstanceURL != "" {
+		// the base URL of the REST API for the org of the user
+		user.RawData["instance_url"] = s.InstanceURL
+	}
+	goth.SetTokenExtras(&user, s.TokenExtras)
+	return user, nil
+}
+
+// trustedHost reports whether the identity URL may be on host, which must be
+// the host the tokens were issued by, the instance of the org or one of the
+// Salesforce login hosts. The identity URL is kept in the session, so it must
+// be checked before the access token is sent to it.
+func (p *Provider) trustedHost(host, instanceURL string) bool {
+	trusted := []string{ProductionURL, SandboxURL, p.config.Endpoint.TokenURL}
+	if instanceURL != "" {
+		trusted = append(trusted, instanceURL)
+	}
+	for _, t := range trusted {
+		if u, err := url.Parse(t); err == nil && strings.EqualFold(u.Host, host) {
+			return true
+		}
+	}
+	return false
 }
 
-func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+func newConfig(provider *Provider, authURL, tokenURL string, scopes []string) *oauth2.Config {
 	c := &oauth2.Config{
 		ClientID:     provider.ClientKey,
 		ClientSecret: provider.Secret,
 		RedirectURL:  provider.CallbackURL,
 		Endpoint: oauth2.Endpoint{
-			AuthURL:  AuthURL,
-			TokenURL: TokenURL,
+			AuthURL:  authURL,
+			TokenURL: tokenURL,
 		},
 		Scopes: []string{},
 	}
@@ -181,11 +247,10 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
@@ -1,6 +1,9 @@
 package salesforce_test
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -35,6 +38,53 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "login.salesforce.com/services/oauth2/authorize")
 }
 
+func Test_NewSandbox(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := salesforce.NewSandbox("key", "secret", "/foo")
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*salesforce.Session).AuthURL, "https://test.salesforce.com/services/oauth2/authorize")
+}
+
+func Test_NewWithDomain(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := salesforce.NewWithDomain("key", "secret", "/foo", "https://acme.my.salesforce.com/")
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	a.Contains(session.(*salesforce.Session).AuthURL, "https://acme.my.salesforce.com/services/oauth2/authorize")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+		fmt.Fprint(w, `{"user_id":"005x","display_name":"Homer Simpson","email":"homer@example.com"}`)
+	}))
+	defer ts.Close()
+
+	p := salesforce.NewWithDomain("key", "secret", "/foo", ts.URL)
+	user, err := p.FetchUser(&salesforce.Session{
+		AccessToken: "1234567890",
+		ID:          ts.URL + "/id/00Dx/005x",
+		InstanceURL: "https://acme.my.salesforce.com",
+	})
+	a.NoError(err)
+	a.Equal("005x", user.UserID)
+	a.Equal("homer@example.com", user.Email)
+	a.Equal("https://acme.my.salesforce.com", user.RawData["instance_url"])
+
+	_, err = p.FetchUser(&salesforce.Session{
+		AccessToken: "1234567890",
+		ID:          "https://evil.example.com/id/00Dx/005x",
+		InstanceURL: "https://acme.my.salesforce.com",
+	})
+	a.ErrorContains(err, "unexpected host evil.example.com")
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -4,13 +4,14 @@ import (
 	"encoding/json"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/markbates/goth"
 )
 
 // Session stores data during the auth process with Salesforce.
 // Expiry of access token is not provided by Salesforce, it is just controlled by timeout configured in auth2 settings
-// by individual users
+// by individual users, so ExpiresAt stays zero unless the token response has an expires_in
 // Only way to check whether access token has expired or not is based on the response you receive if you try using
 // access token and get some error
 // Also, For salesforce refresh token to work follow these else remove scopes from here
@@ -22,9 +23,15 @@ type Session struct {
 	AuthURL      string
 	AccessToken  string
 	RefreshToken string
-	ID           string // Required to get the user info from sales force
+	ExpiresAt    time.Time
+	ID           string                 // Required to get the user info from sales force
+	InstanceURL  string                 `json:",omitempty"`
+	TokenExtras  map[string]interface{} `json:",omitempty"`
 }
 
+// tokenExtras are the fields of the token response kept in the session.
+var tokenExtras = []string{"instance_url", "id", "issued_at", "signature", "scope"}
+
 var _ goth.Session = &Session{}
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the Salesforce provider.
@@ -50,7 +57,10 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
-	s.ID = token.Extra("id").(string) // Required to get the user info from sales force
+	s.ExpiresAt = token.Expiry
+	s.ID, _ = token.Extra("id").(string) // Required to get the user info from sales force
+	s.InstanceURL, _ = token.Extra("instance_url").(string)
+	s.TokenExtras = goth.TokenExtras(token, tokenExtras...)
 	return token.AccessToken, err
 }
 
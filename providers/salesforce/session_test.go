@@ -36,7 +36,7 @@ func Test_ToJSON(t *testing.T) {
 	s := &salesforce.Session{}
 
 	data := s.Marshal()
-	a.Equal(data, `{"AuthURL":"","AccessToken":"","RefreshToken":"","ID":""}`)
+	a.Equal(data, `{"AuthURL":"","AccessToken":"","RefreshToken":"","ExpiresAt":"0001-01-01T00:00:00Z","ID":""}`)
 }
 
 func Test_String(t *testing.T) {
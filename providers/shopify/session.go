@@ -7,18 +7,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"regexp"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/markbates/goth"
 )
 
-const (
-	shopifyHostnameRegex = `^(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])\.)*([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\-]*[A-Za-z0-9])$`
-)
-
 // Session stores data during the auth process with Shopify.
 type Session struct {
 	AuthURL     string
@@ -26,6 +22,8 @@ type Session struct {
 	Hostname    string
 	HMAC        string
 	ExpiresAt   time.Time
+	Shop        string `json:",omitempty"`
+	State       string `json:",omitempty"`
 }
 
 var _ goth.Session = &Session{}
@@ -40,35 +38,29 @@ func (s Session) GetAuthURL() (string, error) {
 
 // Authorize the session with Shopify and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+
 	// Validate the incoming HMAC is valid.
-	// See: https://help.shopify.com/en/api/getting-started/authentication/oauth#verification
-	digest := fmt.Sprintf(
-		"code=%s&host=%s&shop=%s&state=%s&timestamp=%s",
-		params.Get("code"),
-		params.Get("host"),
-		params.Get("shop"),
-		params.Get("state"),
-		params.Get("timestamp"),
-	)
-	h := hmac.New(sha256.New, []byte(os.Getenv("SHOPIFY_SECRET")))
-	h.Write([]byte(digest))
-	sha := hex.EncodeToString(h.Sum(nil))
-
-	// Ensure our HMAC hash's match.
-	if sha != params.Get("hmac") {
+	// See: https://shopify.dev/docs/apps/auth/oauth/getting-started#step-2-verify-the-installation-request
+	if !ValidHMAC(params, p.Secret) {
 		return "", errors.New("Invalid HMAC received")
 	}
 
 	// Validate the hostname matches what we're expecting.
 	// See: https://help.shopify.com/en/api/getting-started/authentication/oauth#step-3-confirm-installation
-	re := regexp.MustCompile(shopifyHostnameRegex)
-	if !re.MatchString(params.Get("shop")) {
+	shop := params.Get("shop")
+	if !shopDomainRegex.MatchString(shop) {
 		return "", errors.New("Invalid hostname received")
 	}
+	if s.Shop != "" && shop != s.Shop {
+		return "", fmt.Errorf("shop %q does not match the one authorization was requested for", shop)
+	}
+	if s.State != "" && params.Get("state") != s.State {
+		return "", errors.New("state does not match the one authorization was requested with")
+	}
 
 	// Make the exchange for an access token.
-	p := provider.(*Provider)
-	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
+	token, err := p.configForShop(shop).Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
 	if err != nil {
 		return "", err
 	}
@@ -79,12 +71,49 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	}
 
 	s.AccessToken = token.AccessToken
-	s.Hostname = params.Get("hostname")
+	s.ExpiresAt = token.Expiry
+	s.Shop = shop
+	s.Hostname = shop
 	s.HMAC = params.Get("hmac")
 
 	return token.AccessToken, err
 }
 
+// ValidHMAC reports whether the hmac parameter of a request from Shopify is
+// the signature of the other parameters with the app's secret. All the
+// parameters are checked when params is a url.Values, otherwise only those
+// Shopify sends to the OAuth callback.
+func ValidHMAC(params goth.Params, secret string) bool {
+	values, ok := params.(url.Values)
+	if !ok {
+		values = url.Values{}
+		for _, key := range []string{"code", "host", "shop", "state", "timestamp"} {
+			if v := params.Get(key); v != "" {
+				values.Set(key, v)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if key != "hmac" && key != "signature" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+strings.Join(values[key], ","))
+	}
+
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(strings.Join(pairs, "&")))
+	expected := hex.EncodeToString(h.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(params.Get("hmac")))
+}
+
 // Marshal the session into a string
 func (s Session) Marshal() string {
 	b, _ := json.Marshal(s)
@@ -3,26 +3,37 @@
 package shopify
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
 const (
 	providerName = "shopify"
 
-	// URL protocol and subdomain will be populated by newConfig().
-	authURL         = "myshopify.com/admin/oauth/authorize"
-	tokenURL        = "myshopify.com/admin/oauth/access_token"
-	endpointProfile = "myshopify.com/admin/api/2019-04/shop.json"
+	// The shop's myshopify.com domain is prepended to these paths.
+	authURL         = "/admin/oauth/authorize"
+	tokenURL        = "/admin/oauth/access_token"
+	endpointProfile = "/admin/api/2019-04/shop.json"
+
+	// ShopParam is the authorization URL parameter selecting the shop to
+	// authenticate with, see BeginAuthWithParams.
+	ShopParam = "shop"
 )
 
+var shopDomainRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9\-]*\.myshopify\.com$`)
+
 // Provider is the implementation of `goth.Provider` for accessing Shopify.
 type Provider struct {
 	ClientKey    string
@@ -65,6 +76,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 // SetShopName is to update the shopify shop name, needed when interfacing with different shops.
 func (p *Provider) SetShopName(name string) {
 	p.shopName = name
@@ -76,13 +95,61 @@ func (p *Provider) SetShopName(name string) {
 // Debug is a no-op for the Shopify package.
 func (p *Provider) Debug(debug bool) {}
 
-// BeginAuth asks Shopify for an authentication end-point.
+// BeginAuth asks Shopify for an authentication end-point for the shop set
+// with SetShopName.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return p.BeginAuthWithParams(state, nil)
+}
+
+// BeginAuthWithParams is like BeginAuth, but lets the shop be picked per
+// request with the shop parameter, which accepts either the shop name or its
+// myshopify.com domain as Shopify passes it to the app. The remaining params,
+// e.g. grant_options[], are added to the authorization URL.
+func (p *Provider) BeginAuthWithParams(state string, params url.Values) (goth.Session, error) {
+	shop := p.shopName
+	if v := params.Get(ShopParam); v != "" {
+		shop = v
+	}
+	domain, err := ShopDomain(shop)
+	if err != nil {
+		return nil, err
+	}
+
+	extra := url.Values{}
+	for key, values := range params {
+		if key != ShopParam {
+			extra[key] = values
+		}
+	}
 	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
+		AuthURL: p.configForShop(domain).AuthCodeURL(state, goth.AuthURLParamOptions(extra)...),
+		Shop:    domain,
+		State:   state,
 	}, nil
 }
 
+// ForwardedAuthURLParams implements goth.ForwardedParamsProvider, for gothic
+// to pass the shop parameter of the request on to BeginAuthWithParams.
+func (p *Provider) ForwardedAuthURLParams() []string {
+	return []string{ShopParam}
+}
+
+// ShopDomain normalizes shop, given as a shop name, a myshopify.com domain or
+// a URL on it, to the myshopify.com domain, and checks that it is valid.
+func ShopDomain(shop string) (string, error) {
+	domain := strings.ToLower(strings.TrimSpace(shop))
+	domain = strings.TrimPrefix(domain, "https://")
+	domain = strings.TrimPrefix(domain, "http://")
+	domain = strings.TrimSuffix(domain, "/")
+	if !strings.Contains(domain, ".") {
+		domain += ".myshopify.com"
+	}
+	if !shopDomainRegex.MatchString(domain) {
+		return "", fmt.Errorf("invalid shop %q", shop)
+	}
+	return domain, nil
+}
+
 // RefreshTokenAvailable refresh token is provided by auth provider or not
 func (p *Provider) RefreshTokenAvailable() bool {
 	return false
@@ -99,6 +166,12 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	shop := goth.User{
 		AccessToken: s.AccessToken,
 		Provider:    p.Name(),
+		ExpiresAt:   s.ExpiresAt,
+	}
+
+	domain := s.Shop
+	if domain == "" {
+		domain = p.shopName + ".myshopify.com"
 	}
 
 	if shop.AccessToken == "" {
@@ -107,7 +180,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	// Build the request.
-	req, err := http.NewRequest("GET", fmt.Sprintf("https://%s.%s", p.shopName, endpointProfile), nil)
+	req, err := http.NewRequest("GET", "https://"+domain+endpointProfile, nil)
 	if err != nil {
 		return shop, err
 	}
@@ -128,8 +201,18 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return shop, fmt.Errorf("%s responded with a %d trying to fetch shop information", p.providerName, resp.StatusCode)
 	}
 
+	bits, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return shop, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&shop.RawData)
+	if err != nil {
+		return shop, err
+	}
+
 	// Parse response.
-	return shop, shopFromReader(resp.Body, &shop)
+	return shop, shopFromReader(bytes.NewReader(bits), &shop)
 }
 
 func shopFromReader(r io.Reader, shop *goth.User) error {
@@ -151,13 +234,15 @@ func shopFromReader(r io.Reader, shop *goth.User) error {
 		return err
 	}
 
-	shop.UserID = strconv.Itoa(int(rsp.Shop.ID))
-	shop.Name = rsp.Shop.Name
+	// The app is installed on a shop rather than authorized by a user, so
+	// the shop owner stands in for the user.
+	shop.UserID = strconv.FormatInt(rsp.Shop.ID, 10)
+	shop.Name = rsp.Shop.ShopOwner
+	shop.FirstName, shop.LastName, _ = strings.Cut(rsp.Shop.ShopOwner, " ")
+	shop.NickName = rsp.Shop.Name
 	shop.Email = rsp.Shop.Email
 	shop.Description = fmt.Sprintf("%s (%s)", rsp.Shop.MyShopifyDomain, rsp.Shop.PlanDisplayName)
 	shop.Location = fmt.Sprintf("%s, %s", rsp.Shop.City, rsp.Shop.Country)
-	shop.AvatarURL = "Not provided by the Shopify API"
-	shop.NickName = "Not provided by the Shopify API"
 
 	return nil
 }
@@ -168,25 +253,27 @@ func newConfig(p *Provider, scopes []string) *oauth2.Config {
 		ClientSecret: p.Secret,
 		RedirectURL:  p.CallbackURL,
 		Endpoint: oauth2.Endpoint{
-			AuthURL:  fmt.Sprintf("https://%s.%s", p.shopName, authURL),
-			TokenURL: fmt.Sprintf("https://%s.%s", p.shopName, tokenURL),
+			AuthURL:  fmt.Sprintf("https://%s.myshopify.com%s", p.shopName, authURL),
+			TokenURL: fmt.Sprintf("https://%s.myshopify.com%s", p.shopName, tokenURL),
 		},
-		Scopes: []string{},
 	}
 
-	if len(scopes) > 0 {
-		for i, scope := range scopes {
-			// Shopify require comma separated scopes.
-			s := fmt.Sprintf("%s,", scope)
-			if i == len(scopes)+1 {
-				s = scope
-			}
-			c.Scopes = append(c.Scopes, s)
-		}
-	} else {
+	if len(scopes) == 0 {
 		// Default to a read customers scope.
-		c.Scopes = append(c.Scopes, ScopeReadCustomers)
+		scopes = []string{ScopeReadCustomers}
 	}
+	// Shopify require comma separated scopes.
+	c.Scopes = goth.JoinScopes(",", scopes...)
 
 	return c
 }
+
+// configForShop returns a copy of the config pointing at the given shop domain.
+func (p *Provider) configForShop(domain string) *oauth2.Config {
+	c := *p.config
+	c.Endpoint = oauth2.Endpoint{
+		AuthURL:  "https://" + domain + authURL,
+		TokenURL: "https://" + domain + tokenURL,
+	}
+	return &c
+}
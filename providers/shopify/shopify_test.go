@@ -1,7 +1,15 @@
 package shopify_test
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -23,6 +31,7 @@ func Test_Implements_Provider(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
 	a.Implements((*goth.Provider)(nil), provider())
+	a.Implements((*goth.ForwardedParamsProvider)(nil), provider())
 }
 
 func Test_BeginAuth(t *testing.T) {
@@ -48,6 +57,132 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AccessToken, "1234567890")
 }
 
+func Test_BeginAuthWithParams(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	session, err := p.BeginAuthWithParams("test_state", url.Values{
+		"shop":            {"other-shop.myshopify.com"},
+		"grant_options[]": {"per-user"},
+	})
+	a.NoError(err)
+	s := session.(*shopify.Session)
+	a.Equal("other-shop.myshopify.com", s.Shop)
+	a.Contains(s.AuthURL, "https://other-shop.myshopify.com/admin/oauth/authorize")
+	a.Contains(s.AuthURL, "grant_options%5B%5D=per-user")
+	a.NotContains(s.AuthURL, "shop=")
+
+	_, err = p.BeginAuthWithParams("test_state", url.Values{"shop": {"evil.example.com"}})
+	a.Error(err)
+}
+
+func Test_ShopDomain(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	for _, shop := range []string{"acme", "acme.myshopify.com", "https://ACME.myshopify.com/"} {
+		domain, err := shopify.ShopDomain(shop)
+		a.NoError(err)
+		a.Equal("acme.myshopify.com", domain)
+	}
+
+	for _, shop := range []string{"", "acme.example.com", "acme.myshopify.com.example.com", "-acme"} {
+		_, err := shopify.ShopDomain(shop)
+		a.Error(err, shop)
+	}
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := shopify.New("key", "secret", "/foo")
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.String() {
+		case "https://acme.myshopify.com/admin/oauth/access_token":
+			return response(`{"access_token":"token","scope":"read_customers"}`), nil
+		case "https://acme.myshopify.com/admin/api/2019-04/shop.json":
+			a.Equal("token", req.Header.Get("X-Shopify-Access-Token"))
+			return response(`{"shop":{"id":1234,"name":"Acme","email":"owner@acme.com","shop_owner":"Jane Doe","myshopify_domain":"acme.myshopify.com"}}`), nil
+		}
+		return nil, fmt.Errorf("unexpected request to %s", req.URL)
+	})}
+
+	session, err := p.BeginAuthWithParams("state", url.Values{"shop": {"acme"}})
+	a.NoError(err)
+	s := session.(*shopify.Session)
+
+	params := url.Values{
+		"code":      {"code"},
+		"shop":      {"acme.myshopify.com"},
+		"state":     {"state"},
+		"timestamp": {"1700000000"},
+	}
+	params.Set("hmac", sign(params, "secret"))
+
+	_, err = s.Authorize(p, params)
+	a.NoError(err)
+	a.Equal("token", s.AccessToken)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("1234", user.UserID)
+	a.Equal("Jane Doe", user.Name)
+	a.Equal("Jane", user.FirstName)
+	a.Equal("Doe", user.LastName)
+	a.Equal("Acme", user.NickName)
+	a.Equal("owner@acme.com", user.Email)
+
+	tampered := url.Values{}
+	for k, v := range params {
+		tampered[k] = v
+	}
+	tampered.Set("state", "other")
+	_, err = s.Authorize(p, tampered)
+	a.Error(err)
+
+	otherShop := url.Values{
+		"code":      {"code"},
+		"shop":      {"other.myshopify.com"},
+		"state":     {"state"},
+		"timestamp": {"1700000000"},
+	}
+	otherShop.Set("hmac", sign(otherShop, "secret"))
+	_, err = s.Authorize(p, otherShop)
+	a.Error(err)
+
+	wrongState := url.Values{
+		"code":      {"code"},
+		"shop":      {"acme.myshopify.com"},
+		"state":     {"other"},
+		"timestamp": {"1700000000"},
+	}
+	wrongState.Set("hmac", sign(wrongState, "secret"))
+	_, err = s.Authorize(p, wrongState)
+	a.Error(err)
+}
+
+func sign(params url.Values, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(params.Encode()))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func response(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
 func provider() *shopify.Provider {
 	p := shopify.New(os.Getenv("SHOPIFY_KEY"), os.Getenv("SHOPIFY_SECRET"), "/foo")
 	p.SetShopName("test-shop")
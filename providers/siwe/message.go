@@ -0,0 +1,172 @@
+package siwe
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+const messageHeaderSuffix = " wants you to sign in with your Ethereum account:"
+
+// Message is a parsed Sign-In with Ethereum message.
+// See https://eips.ethereum.org/EIPS/eip-4361#message-format
+type Message struct {
+	Scheme         string
+	Domain         string
+	Address        string
+	Statement      string
+	URI            string
+	Version        string
+	ChainID        int
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime time.Time
+	NotBefore      time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// ParseMessage parses a Sign-In with Ethereum message.
+func ParseMessage(message string) (*Message, error) {
+	lines := strings.Split(strings.ReplaceAll(message, "\r\n", "\n"), "\n")
+	if len(lines) < 2 || !strings.HasSuffix(lines[0], messageHeaderSuffix) {
+		return nil, errors.New("siwe: the message doesn't start with the sign in request")
+	}
+
+	m := &Message{Domain: strings.TrimSuffix(lines[0], messageHeaderSuffix)}
+	if i := strings.Index(m.Domain, "://"); i >= 0 {
+		m.Scheme, m.Domain = m.Domain[:i], m.Domain[i+3:]
+	}
+	m.Address = lines[1]
+
+	inResources := false
+	for _, line := range lines[2:] {
+		if inResources {
+			if strings.HasPrefix(line, "- ") {
+				m.Resources = append(m.Resources, strings.TrimPrefix(line, "- "))
+				continue
+			}
+			inResources = false
+		}
+
+		if line == "" {
+			continue
+		}
+		if line == "Resources:" {
+			inResources = true
+			continue
+		}
+
+		var err error
+		name, value, found := strings.Cut(line, ": ")
+		switch {
+		case !found && m.URI == "" && m.Statement == "":
+			m.Statement = line
+		case !found:
+			return nil, fmt.Errorf("siwe: unexpected line %q", line)
+		case name == "URI":
+			m.URI = value
+		case name == "Version":
+			m.Version = value
+		case name == "Chain ID":
+			m.ChainID, err = strconv.Atoi(value)
+		case name == "Nonce":
+			m.Nonce = value
+		case name == "Issued At":
+			m.IssuedAt, err = time.Parse(time.RFC3339, value)
+		case name == "Expiration Time":
+			m.ExpirationTime, err = time.Parse(time.RFC3339, value)
+		case name == "Not Before":
+			m.NotBefore, err = time.Parse(time.RFC3339, value)
+		case name == "Request ID":
+			m.RequestID = value
+		case m.URI == "" && m.Statement == "":
+			// statements may contain ": " as well
+			m.Statement = line
+		default:
+			return nil, fmt.Errorf("siwe: unexpected field %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("siwe: invalid %s: %w", name, err)
+		}
+	}
+
+	switch {
+	case m.URI == "":
+		return nil, errors.New("siwe: the message has no URI")
+	case m.Version == "":
+		return nil, errors.New("siwe: the message has no version")
+	case m.ChainID == 0:
+		return nil, errors.New("siwe: the message has no chain id")
+	case m.Nonce == "":
+		return nil, errors.New("siwe: the message has no nonce")
+	case m.IssuedAt.IsZero():
+		return nil, errors.New("siwe: the message has no issuance time")
+	}
+	return m, nil
+}
+
+// RecoverAddress returns the checksummed address of the account whose key
+// produced signature, an hex encoded personal_sign (EIP-191) signature of
+// message.
+func RecoverAddress(message, signature string) (string, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil {
+		return "", fmt.Errorf("siwe: invalid signature: %w", err)
+	}
+	if len(sig) != 65 {
+		return "", errors.New("siwe: the signature must be 65 bytes long")
+	}
+
+	// wallets append the recovery id either as 0/1 or as 27/28
+	v := sig[64]
+	if v < 27 {
+		v += 27
+	}
+	if v != 27 && v != 28 {
+		return "", errors.New("siwe: invalid signature recovery id")
+	}
+	compact := append([]byte{v}, sig[:64]...)
+
+	prefixed := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)
+	key, _, err := ecdsa.RecoverCompact(compact, keccak256([]byte(prefixed)))
+	if err != nil {
+		return "", fmt.Errorf("siwe: invalid signature: %w", err)
+	}
+
+	pub := key.SerializeUncompressed()
+	return ChecksumAddress(hex.EncodeToString(keccak256(pub[1:])[12:]))
+}
+
+// ChecksumAddress returns address in its mixed-case checksum encoding.
+// See https://eips.ethereum.org/EIPS/eip-55
+func ChecksumAddress(address string) (string, error) {
+	lower := strings.ToLower(strings.TrimPrefix(address, "0x"))
+	if len(lower) != 40 {
+		return "", fmt.Errorf("siwe: invalid address %q", address)
+	}
+	if _, err := hex.DecodeString(lower); err != nil {
+		return "", fmt.Errorf("siwe: invalid address %q", address)
+	}
+
+	hash := hex.EncodeToString(keccak256([]byte(lower)))
+	result := []byte(lower)
+	for i, c := range result {
+		if c >= 'a' && hash[i] >= '8' {
+			result[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(result), nil
+}
+
+func keccak256(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,77 @@
+package siwe
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+)
+
+// Session stores data during the auth process with Sign-In with Ethereum.
+type Session struct {
+	AuthURL   string
+	Nonce     string
+	Address   string    `json:",omitempty"`
+	Message   string    `json:",omitempty"`
+	Signature string    `json:",omitempty"`
+	ExpiresAt time.Time `json:",omitempty"`
+}
+
+var _ goth.Session = &Session{}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the siwe provider.
+func (s Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize verifies the SIWE message and its signature, passed as the
+// "message" and "signature" params, and returns the checksummed address of the
+// account that signed in. The nonce can't be used again afterwards.
+func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+
+	m, err := ParseMessage(params.Get("message"))
+	if err != nil {
+		return "", err
+	}
+	if err := p.verify(m, s.Nonce); err != nil {
+		return "", err
+	}
+
+	address, err := RecoverAddress(params.Get("message"), params.Get("signature"))
+	if err != nil {
+		return "", err
+	}
+	if address != m.Address {
+		return "", errors.New("siwe: the message wasn't signed by the account it names")
+	}
+
+	s.Nonce = ""
+	s.Address = address
+	s.Message = params.Get("message")
+	s.Signature = params.Get("signature")
+	s.ExpiresAt = m.ExpirationTime
+	return address, nil
+}
+
+// Marshal the session into a string
+func (s Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s Session) String() string {
+	return s.Marshal()
+}
+
+// UnmarshalSession will unmarshal a JSON string into a session.
+func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	sess := &Session{}
+	err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
+	return sess, err
+}
@@ -0,0 +1,43 @@
+package siwe
+
+import (
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Implements_Session(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	s := &Session{}
+
+	a.Implements((*goth.Session)(nil), s)
+}
+
+func Test_GetAuthURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	s := &Session{}
+
+	_, err := s.GetAuthURL()
+	a.Error(err)
+
+	s.AuthURL = "/foo"
+
+	url, _ := s.GetAuthURL()
+	a.Equal(url, "/foo")
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := New("example.com", "/foo")
+	session, err := p.UnmarshalSession(`{"AuthURL":"/foo?nonce=abcdefgh12345678","Nonce":"abcdefgh12345678"}`)
+	a.NoError(err)
+
+	s := session.(*Session)
+	a.Equal("/foo?nonce=abcdefgh12345678", s.AuthURL)
+	a.Equal("abcdefgh12345678", s.Nonce)
+}
@@ -0,0 +1,193 @@
+// Package siwe implements Sign-In with Ethereum (EIP-4361) for authenticating
+// users through their Ethereum account.
+//
+// There is no identity provider to redirect the user to. BeginAuth issues a
+// nonce, and the authorization URL is the callback URL carrying the nonce and
+// the state as query parameters. The frontend reads them, has the wallet sign
+// a SIWE message containing the nonce, and POSTs "message", "signature" and
+// "state" to the callback, where the signature and the message are verified.
+// Only externally owned accounts are supported, not EIP-1271 contract wallets.
+package siwe
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+// nonceAlphabet is the character set of nonces, which EIP-4361 restricts to
+// alphanumeric characters.
+const nonceAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// New creates a new Sign-In with Ethereum provider. domain is the host, and
+// port if any, the messages must be issued for, e.g. "example.com", and
+// callbackURL the URL messages and signatures are posted to.
+func New(domain, callbackURL string) *Provider {
+	return &Provider{
+		Domain:       domain,
+		CallbackURL:  callbackURL,
+		providerName: "siwe",
+	}
+}
+
+// Provider is the implementation of `goth.Provider` for Sign-In with Ethereum.
+type Provider struct {
+	Domain      string
+	CallbackURL string
+	// ChainIDs, if not empty, restricts the chains messages may be signed for.
+	ChainIDs []int
+	// HTTPClient isn't used, as no requests are made, and is only there for
+	// consistency with other providers.
+	HTTPClient   *http.Client
+	providerName string
+	now          func() time.Time
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	return &c
+}
+
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the siwe package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth issues a nonce and returns a session whose auth URL is the
+// callback URL with the "nonce" and "state" query parameters.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(p.CallbackURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("nonce", nonce)
+	q.Set("state", state)
+	u.RawQuery = q.Encode()
+
+	return &Session{
+		AuthURL: u.String(),
+		Nonce:   nonce,
+	}, nil
+}
+
+// FetchUser returns the user of the account that signed the message. No
+// request is made, all the data comes from the verified message.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		Provider:  p.Name(),
+		ExpiresAt: sess.ExpiresAt,
+	}
+
+	if sess.Address == "" {
+		// the message is not yet verified since the address is still empty
+		return user, fmt.Errorf("%s cannot get user information without a verified message", p.providerName)
+	}
+
+	m, err := ParseMessage(sess.Message)
+	if err != nil {
+		return user, err
+	}
+
+	user.UserID = sess.Address
+	user.NickName = sess.Address
+	user.Description = m.Statement
+	user.RawData = map[string]interface{}{
+		"address":   sess.Address,
+		"chain_id":  strconv.Itoa(m.ChainID),
+		"domain":    m.Domain,
+		"uri":       m.URI,
+		"issued_at": m.IssuedAt.Format(time.RFC3339),
+		"message":   sess.Message,
+	}
+	if m.RequestID != "" {
+		user.RawData["request_id"] = m.RequestID
+	}
+	if len(m.Resources) > 0 {
+		user.RawData["resources"] = m.Resources
+	}
+	return user, nil
+}
+
+// verify checks that m is an acceptable sign in request for the session
+// holding nonce.
+func (p *Provider) verify(m *Message, nonce string) error {
+	now := goth.Now()
+	if p.now != nil {
+		now = p.now()
+	}
+
+	if m.Domain != p.Domain {
+		return fmt.Errorf("siwe: the message was issued for %q instead of %q", m.Domain, p.Domain)
+	}
+	if m.Version != "1" {
+		return fmt.Errorf("siwe: unsupported message version %q", m.Version)
+	}
+	if nonce == "" || m.Nonce != nonce {
+		return errors.New("siwe: the nonce of the message doesn't match the session")
+	}
+	if !m.ExpirationTime.IsZero() && !now.Before(m.ExpirationTime) {
+		return errors.New("siwe: the message has expired")
+	}
+	if !m.NotBefore.IsZero() && now.Before(m.NotBefore) {
+		return errors.New("siwe: the message is not valid yet")
+	}
+	if len(p.ChainIDs) > 0 {
+		allowed := false
+		for _, id := range p.ChainIDs {
+			allowed = allowed || id == m.ChainID
+		}
+		if !allowed {
+			return fmt.Errorf("siwe: chain %d is not allowed", m.ChainID)
+		}
+	}
+	return nil
+}
+
+// RefreshTokenAvailable refresh token is not provided by siwe
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+// RefreshToken refresh token is not provided by siwe
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by siwe")
+}
+
+func newNonce() (string, error) {
+	b := make([]byte, 17)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = nonceAlphabet[int(b[i])%len(nonceAlphabet)]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,185 @@
+package siwe
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := New("example.com", "https://example.com/auth/siwe/callback")
+	a.Equal("example.com", p.Domain)
+	a.Equal("https://example.com/auth/siwe/callback", p.CallbackURL)
+	a.Equal("siwe", p.Name())
+	a.False(p.RefreshTokenAvailable())
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), New("example.com", "/foo"))
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := New("example.com", "https://example.com/auth/siwe/callback")
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*Session)
+	a.Len(s.Nonce, 17)
+
+	u, err := url.Parse(s.AuthURL)
+	a.NoError(err)
+	a.Equal("example.com", u.Host)
+	a.Equal(s.Nonce, u.Query().Get("nonce"))
+	a.Equal("test_state", u.Query().Get("state"))
+}
+
+func Test_ChecksumAddress(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	// test vectors from EIP-55
+	for _, address := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+	} {
+		checksummed, err := ChecksumAddress(strings.ToLower(address))
+		a.NoError(err)
+		a.Equal(address, checksummed)
+	}
+
+	_, err := ChecksumAddress("0x1234")
+	a.Error(err)
+}
+
+func Test_ParseMessage(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	m, err := ParseMessage(`https://example.com wants you to sign in with your Ethereum account:
+0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed
+
+I accept the Terms of Service: https://example.com/tos
+
+URI: https://example.com/login
+Version: 1
+Chain ID: 1
+Nonce: 32891756
+Issued At: 2021-09-30T16:25:24Z
+Expiration Time: 2021-10-01T16:25:24Z
+Request ID: abc
+Resources:
+- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/
+- https://example.com/my-web2-claim.json`)
+	a.NoError(err)
+	a.Equal("https", m.Scheme)
+	a.Equal("example.com", m.Domain)
+	a.Equal("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", m.Address)
+	a.Equal("I accept the Terms of Service: https://example.com/tos", m.Statement)
+	a.Equal("https://example.com/login", m.URI)
+	a.Equal(1, m.ChainID)
+	a.Equal("32891756", m.Nonce)
+	a.Equal(time.Date(2021, 10, 1, 16, 25, 24, 0, time.UTC), m.ExpirationTime)
+	a.Equal("abc", m.RequestID)
+	a.Len(m.Resources, 2)
+
+	// the statement is optional
+	m, err = ParseMessage("example.com wants you to sign in with your Ethereum account:\n" +
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed\n\n\n" +
+		"URI: https://example.com\nVersion: 1\nChain ID: 10\nNonce: 32891756\nIssued At: 2021-09-30T16:25:24Z")
+	a.NoError(err)
+	a.Equal("", m.Statement)
+	a.Equal(10, m.ChainID)
+
+	_, err = ParseMessage("example.com wants you to sign in with your Ethereum account:\n0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed")
+	a.Error(err)
+}
+
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	key, err := secp256k1.GeneratePrivateKey()
+	a.NoError(err)
+	other, err := secp256k1.GeneratePrivateKey()
+	a.NoError(err)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := New("example.com", "https://example.com/auth/siwe/callback")
+	p.now = func() time.Time { return now }
+
+	authorize := func(domain, nonce string, expires time.Time, signer *secp256k1.PrivateKey) (*Session, error) {
+		message := testMessage(key, domain, nonce, expires)
+		s := &Session{AuthURL: "https://example.com/auth/siwe/callback", Nonce: "abcdefgh12345678"}
+		_, err := s.Authorize(p, url.Values{"message": {message}, "signature": {sign(signer, message)}})
+		return s, err
+	}
+
+	s, err := authorize("example.com", "abcdefgh12345678", now.Add(time.Hour), key)
+	a.NoError(err)
+	a.Equal(address(key), s.Address)
+	a.Empty(s.Nonce)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal(address(key), user.UserID)
+	a.Equal("1", user.RawData["chain_id"])
+	a.Equal(now.Add(time.Hour), user.ExpiresAt)
+
+	_, err = authorize("evil.com", "abcdefgh12345678", now.Add(time.Hour), key)
+	a.Error(err)
+	_, err = authorize("example.com", "reusedNonce1234", now.Add(time.Hour), key)
+	a.Error(err)
+	_, err = authorize("example.com", "abcdefgh12345678", now.Add(-time.Minute), key)
+	a.Error(err)
+	_, err = authorize("example.com", "abcdefgh12345678", now.Add(time.Hour), other)
+	a.Error(err)
+
+	p.ChainIDs = []int{137}
+	_, err = authorize("example.com", "abcdefgh12345678", now.Add(time.Hour), key)
+	a.Error(err)
+}
+
+func Test_FetchUserWithoutVerifiedMessage(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := New("example.com", "/foo")
+	_, err := p.FetchUser(&Session{AuthURL: "/foo", Nonce: "abcdefgh12345678"})
+	a.Error(err)
+}
+
+func testMessage(key *secp256k1.PrivateKey, domain, nonce string, expires time.Time) string {
+	return fmt.Sprintf("%s wants you to sign in with your Ethereum account:\n%s\n\nSign in to Example.\n\n"+
+		"URI: https://%s/login\nVersion: 1\nChain ID: 1\nNonce: %s\nIssued At: %s\nExpiration Time: %s",
+		domain, address(key), domain, nonce, expires.Add(-2*time.Hour).Format(time.RFC3339), expires.Format(time.RFC3339))
+}
+
+func address(key *secp256k1.PrivateKey) string {
+	pub := key.PubKey().SerializeUncompressed()
+	addr, _ := ChecksumAddress(hex.EncodeToString(keccak256(pub[1:])[12:]))
+	return addr
+}
+
+// sign returns the personal_sign signature of message, as a wallet would.
+func sign(key *secp256k1.PrivateKey, message string) string {
+	prefixed := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)
+	compact := ecdsa.SignCompact(key, keccak256([]byte(prefixed)), false)
+	sig := append(compact[1:], compact[0])
+	return "0x" + hex.EncodeToString(sig)
+}
@@ -1,12 +1,15 @@
 package slack
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"strings"
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Slack.
@@ -15,8 +18,19 @@ type Session struct {
 	AccessToken  string
 	RefreshToken string
 	ExpiresAt    time.Time
+	TokenExtras  map[string]interface{} `json:",omitempty"`
+	// IDToken is the id_token of Sign in with Slack.
+	IDToken string `json:",omitempty"`
+	// BotAccessToken and BotRefreshToken are the tokens of the bot installed
+	// by OAuth v2 apps asking for bot scopes.
+	BotAccessToken  string `json:",omitempty"`
+	BotRefreshToken string `json:",omitempty"`
 }
 
+// tokenExtras are the fields of the token response kept in the session. The
+// tokens of the authed_user field are left out on purpose.
+var tokenExtras = []string{"scope", "team_id", "team_name", "team", "enterprise", "enterprise_id", "app_id", "bot_user_id", "is_enterprise_install"}
+
 var _ goth.Session = &Session{}
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the Slack provider.
@@ -30,6 +44,10 @@ func (s Session) GetAuthURL() (string, error) {
 // Authorize the session with Slack and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
+	if p.flow == flowV2 {
+		return p.authorizeV2(context.Background(), s, params.Get("code"))
+	}
+
 	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
 	if err != nil {
 		return "", err
@@ -42,6 +60,10 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
+	s.TokenExtras = goth.TokenExtras(token, tokenExtras...)
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		s.IDToken = idToken
+	}
 	return token.AccessToken, err
 }
 
@@ -61,3 +83,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
 	return s, err
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
@@ -4,6 +4,7 @@ package slack
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"net/http"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -29,12 +31,16 @@ const (
 
 // Provider is the implementation of `goth.Provider` for accessing Slack.
 type Provider struct {
-	ClientKey    string
-	Secret       string
-	CallbackURL  string
-	HTTPClient   *http.Client
+	ClientKey   string
+	Secret      string
+	CallbackURL string
+	HTTPClient  *http.Client
+	// BotScopes are the scopes of the bot token asked for by providers
+	// created with NewV2.
+	BotScopes    []string
 	config       *oauth2.Config
 	providerName string
+	flow         flow
 }
 
 // New creates a new Slack provider and sets up important connection details.
@@ -61,6 +67,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 // Client returns the http.Client used in the provider.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
@@ -71,6 +85,9 @@ func (p *Provider) Debug(debug bool) {}
 
 // BeginAuth asks Slack for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	if p.flow == flowV2 {
+		return p.beginV2Auth(state)
+	}
 	return &Session{
 		AuthURL: p.config.AuthCodeURL(state),
 	}, nil
@@ -91,6 +108,10 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
+	if p.flow == flowOpenID {
+		return p.fetchOpenIDUser(sess, user)
+	}
+
 	// Get the userID, Slack needs userID in order to get user profile info
 	req, _ := http.NewRequest("GET", endpointUser, nil)
 	req.Header.Add("Authorization", "Bearer "+sess.AccessToken)
@@ -143,6 +164,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		err = userFromReader(bytes.NewReader(bits), &user)
 	}
 
+	goth.SetTokenExtras(&user, sess.TokenExtras)
 	return user, err
 }
 
@@ -204,11 +226,15 @@ func userFromReader(r io.Reader, user *goth.User) error {
 			NickName string `json:"name"`
 			ID       string `json:"id"`
 			Profile  struct {
-				Email     string `json:"email"`
-				Name      string `json:"real_name"`
-				AvatarURL string `json:"image_32"`
-				FirstName string `json:"first_name"`
-				LastName  string `json:"last_name"`
+				Email         string `json:"email"`
+				Name          string `json:"real_name"`
+				Image32       string `json:"image_32"`
+				Image48       string `json:"image_48"`
+				Image192      string `json:"image_192"`
+				Image512      string `json:"image_512"`
+				ImageOriginal string `json:"image_original"`
+				FirstName     string `json:"first_name"`
+				LastName      string `json:"last_name"`
 			} `json:"profile"`
 		} `json:"user"`
 	}{}
@@ -220,18 +246,39 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	user.Name = u.User.Profile.Name
 	user.NickName = u.User.NickName
 	user.UserID = u.User.ID
-	user.AvatarURL = u.User.Profile.AvatarURL
+	profile := u.User.Profile
+	// Slack resizes avatars to 24, 32, 48, 72, 192, 512 and 1024 pixels
+	user.AvatarURLs = goth.NewAvatarURLs(profile.Image48, profile.Image192, profile.Image512, profile.ImageOriginal)
+	user.AvatarURL = firstNonEmpty(profile.Image192, profile.Image48, profile.Image32)
 	user.FirstName = u.User.Profile.FirstName
 	user.LastName = u.User.Profile.LastName
 	return nil
 }
 
-// RefreshTokenAvailable refresh token is provided by auth provider or not
+// RefreshTokenAvailable refresh token is provided by auth provider or not.
+// Only the tokens of OAuth v2 apps with token rotation enabled are refreshed.
 func (p *Provider) RefreshTokenAvailable() bool {
-	return false
+	return p.flow == flowV2
 }
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	return nil, nil
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	if p.flow != flowV2 {
+		return nil, nil
+	}
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
 }
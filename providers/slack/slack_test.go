@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 
@@ -198,6 +199,103 @@ func Test_FetchUser(t *testing.T) {
 	}
 }
 
+func Test_V2(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := slack.NewV2(os.Getenv("SLACK_KEY"), os.Getenv("SLACK_SECRET"), "/foo", slack.ScopeUserRead)
+	p.BotScopes = []string{"chat:write", "commands"}
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*slack.Session)
+	a.Contains(s.AuthURL, "slack.com/oauth/v2/authorize")
+	a.Contains(s.AuthURL, "user_scope=users%3Aread")
+	a.Contains(s.AuthURL, "scope=chat%3Awrite+commands")
+
+	withMockServer(p, http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/oauth.v2.access":
+			a.Equal("code", req.FormValue("code"))
+			res.Write([]byte(`{
+				"ok": true,
+				"access_token": "xoxb-bot",
+				"token_type": "bot",
+				"scope": "chat:write,commands",
+				"bot_user_id": "B123",
+				"app_id": "A123",
+				"team": {"id": "T123", "name": "Team"},
+				"enterprise": null,
+				"authed_user": {"id": "U123", "scope": "users:read", "access_token": "xoxp-user", "token_type": "user"}
+			}`))
+		case "/api/auth.test":
+			json.NewEncoder(res).Encode(testAuthTestResponseData)
+		case "/api/users.info":
+			json.NewEncoder(res).Encode(testUserInfoResponseData)
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}), func(p *slack.Provider) {
+		token, err := s.Authorize(p, url.Values{"code": {"code"}})
+		a.NoError(err)
+		a.Equal("xoxp-user", token)
+		a.Equal("xoxb-bot", s.BotAccessToken)
+		a.Equal("T123", s.TokenExtras["team_id"])
+
+		user, err := p.FetchUser(s)
+		a.NoError(err)
+		a.Equal("user1234", user.UserID)
+		a.Equal("test@example.org", user.Email)
+		a.Equal("B123", user.RawData[goth.TokenExtrasKey].(map[string]interface{})["bot_user_id"])
+	})
+}
+
+func Test_OpenID(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := slack.NewOpenID(os.Getenv("SLACK_KEY"), os.Getenv("SLACK_SECRET"), "/foo")
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*slack.Session)
+	a.Contains(s.AuthURL, "slack.com/openid/connect/authorize")
+	a.Contains(s.AuthURL, "scope=openid+profile+email")
+
+	withMockServer(p, http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/openid.connect.token":
+			res.Header().Set("Content-Type", "application/json")
+			res.Write([]byte(`{"ok": true, "access_token": "xoxp-user", "token_type": "Bearer", "id_token": "id-token"}`))
+		case "/api/openid.connect.userInfo":
+			a.Equal("Bearer xoxp-user", req.Header.Get("Authorization"))
+			res.Write([]byte(`{
+				"ok": true,
+				"sub": "U123",
+				"https://slack.com/user_id": "U123",
+				"https://slack.com/team_id": "T123",
+				"email": "test@example.org",
+				"name": "Test User",
+				"given_name": "Test",
+				"family_name": "User",
+				"picture": "http://example.org/avatar.png"
+			}`))
+		default:
+			res.WriteHeader(http.StatusNotFound)
+		}
+	}), func(p *slack.Provider) {
+		_, err := s.Authorize(p, url.Values{"code": {"code"}})
+		a.NoError(err)
+		a.Equal("id-token", s.IDToken)
+
+		user, err := p.FetchUser(s)
+		a.NoError(err)
+		a.Equal("U123", user.UserID)
+		a.Equal("Test User", user.Name)
+		a.Equal("Test", user.FirstName)
+		a.Equal("test@example.org", user.Email)
+		a.Equal("id-token", user.IDToken)
+	})
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
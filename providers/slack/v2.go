@@ -0,0 +1,212 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+// Scopes of Sign in with Slack
+const (
+	ScopeOpenID  string = "openid"
+	ScopeProfile string = "profile"
+	ScopeEmail   string = "email"
+)
+
+// URLs and endpoints of OAuth v2 and Sign in with Slack
+const (
+	authURLV2              string = "https://slack.com/oauth/v2/authorize"
+	tokenURLV2             string = "https://slack.com/api/oauth.v2.access"
+	authURLOpenID          string = "https://slack.com/openid/connect/authorize"
+	tokenURLOpenID         string = "https://slack.com/api/openid.connect.token"
+	endpointOpenIDUserInfo string = "https://slack.com/api/openid.connect.userInfo"
+)
+
+type flow int
+
+const (
+	flowV1 flow = iota
+	flowV2
+	flowOpenID
+)
+
+// NewV2 creates a new Slack provider using OAuth v2 (oauth/v2/authorize and
+// oauth.v2.access), which issues separate bot and user tokens. scopes are the
+// user scopes, asked for the user token the user is fetched with. The scopes
+// of the bot token, if the app installs one, are set in BotScopes.
+func NewV2(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := New(clientKey, secret, callbackURL, scopes...)
+	p.flow = flowV2
+	p.config.Endpoint = oauth2.Endpoint{
+		AuthURL:  authURLV2,
+		TokenURL: tokenURLV2,
+	}
+	return p
+}
+
+// NewOpenID creates a new Slack provider using Sign in with Slack, Slack's
+// OpenID Connect flow. It asks for the openid, profile and email scopes if
+// none are given.
+func NewOpenID(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	if len(scopes) == 0 {
+		scopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+	}
+	p := New(clientKey, secret, callbackURL, scopes...)
+	p.flow = flowOpenID
+	p.config.Endpoint = oauth2.Endpoint{
+		AuthURL:  authURLOpenID,
+		TokenURL: tokenURLOpenID,
+	}
+	return p
+}
+
+// beginV2Auth asks for the user scopes with user_scope, and for the bot
+// scopes with scope.
+func (p *Provider) beginV2Auth(state string) (goth.Session, error) {
+	c := *p.config
+	c.Scopes = p.BotScopes
+	return &Session{
+		AuthURL: c.AuthCodeURL(state, oauth2.SetAuthURLParam("user_scope", strings.Join(p.config.Scopes, ","))),
+	}, nil
+}
+
+// v2Token is a token of the oauth.v2.access response.
+type v2Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	Scope        string `json:"scope"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func (t v2Token) expiry() time.Time {
+	return goth.ExpiryFromExpiresIn(t.ExpiresIn)
+}
+
+// authorizeV2 exchanges code with oauth.v2.access, whose response holds the
+// user token in authed_user rather than at the top level, where the bot token
+// is, so that oauth2.Config.Exchange can't be used.
+func (p *Provider) authorizeV2(ctx context.Context, s *Session, code string) (string, error) {
+	form := url.Values{
+		"code":          {code},
+		"redirect_uri":  {p.CallbackURL},
+		"client_id":     {p.ClientKey},
+		"client_secret": {p.Secret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.Endpoint.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s responded with a %d trying to exchange the code", p.providerName, response.StatusCode)
+	}
+
+	var tr struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		v2Token
+		BotUserID string `json:"bot_user_id"`
+		AppID     string `json:"app_id"`
+		Team      struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"team"`
+		Enterprise *struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"enterprise"`
+		IsEnterpriseInstall bool    `json:"is_enterprise_install"`
+		AuthedUser          v2Token `json:"authed_user"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+	if !tr.OK {
+		return "", fmt.Errorf("%s: oauth.v2.access failed: %s", p.providerName, tr.Error)
+	}
+	if tr.AuthedUser.AccessToken == "" {
+		return "", errors.New("slack: no user token was issued, user scopes must be asked for")
+	}
+
+	s.AccessToken = tr.AuthedUser.AccessToken
+	s.RefreshToken = tr.AuthedUser.RefreshToken
+	s.ExpiresAt = tr.AuthedUser.expiry()
+	s.BotAccessToken = tr.AccessToken
+	s.BotRefreshToken = tr.RefreshToken
+	s.TokenExtras = map[string]interface{}{
+		"scope":                 tr.AuthedUser.Scope,
+		"team_id":               tr.Team.ID,
+		"team_name":             tr.Team.Name,
+		"app_id":                tr.AppID,
+		"is_enterprise_install": tr.IsEnterpriseInstall,
+	}
+	if tr.BotUserID != "" {
+		s.TokenExtras["bot_user_id"] = tr.BotUserID
+		s.TokenExtras["bot_scope"] = tr.Scope
+	}
+	if tr.Enterprise != nil {
+		s.TokenExtras["enterprise_id"] = tr.Enterprise.ID
+	}
+	return s.AccessToken, nil
+}
+
+// fetchOpenIDUser gets the user from the userInfo endpoint of Sign in with
+// Slack.
+func (p *Provider) fetchOpenIDUser(sess *Session, user goth.User) (goth.User, error) {
+	req, err := http.NewRequest("GET", endpointOpenIDUserInfo, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Add("Authorization", "Bearer "+sess.AccessToken)
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&user.RawData); err != nil {
+		return user, err
+	}
+	if ok, _ := user.RawData["ok"].(bool); !ok {
+		return user, fmt.Errorf("%s: openid.connect.userInfo failed: %v", p.providerName, user.RawData["error"])
+	}
+
+	claim := func(name string) string {
+		s, _ := user.RawData[name].(string)
+		return s
+	}
+	user.UserID = claim("https://slack.com/user_id")
+	if user.UserID == "" {
+		user.UserID = claim("sub")
+	}
+	user.Name = claim("name")
+	user.NickName = claim("name")
+	user.FirstName = claim("given_name")
+	user.LastName = claim("family_name")
+	user.Email = claim("email")
+	user.AvatarURL = claim("picture")
+	user.AvatarURLs = goth.NewAvatarURLs(claim("https://slack.com/user_image_48"), claim("https://slack.com/user_image_192"), claim("https://slack.com/user_image_512"), "")
+	user.IDToken = sess.IDToken
+	goth.SetTokenExtras(&user, sess.TokenExtras)
+	return user, nil
+}
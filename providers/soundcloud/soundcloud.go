@@ -4,21 +4,23 @@ package soundcloud
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"net/url"
 	"strconv"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
+// See https://developers.soundcloud.com/docs/api/guide#authentication
 const (
-	authURL         string = "https://soundcloud.com/connect"
-	tokenURL        string = "https://api.soundcloud.com/oauth2/token"
+	authURL         string = "https://secure.soundcloud.com/authorize"
+	tokenURL        string = "https://secure.soundcloud.com/oauth/token"
 	endpointProfile string = "https://api.soundcloud.com/me"
 )
 
@@ -30,6 +32,7 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+	pkce         bool
 }
 
 // New creates a new Soundcloud provider and sets up important connection details.
@@ -41,6 +44,7 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 		Secret:       secret,
 		CallbackURL:  callbackURL,
 		providerName: "soundcloud",
+		pkce:         true,
 	}
 	p.config = newConfig(p, scopes)
 	return p
@@ -56,6 +60,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -63,11 +75,23 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the soundcloud package.
 func (p *Provider) Debug(debug bool) {}
 
+// SetPKCE enables or disables Proof Key for Code Exchange (PKCE) for the
+// authorization code flow. It is enabled by default, as SoundCloud requires
+// it for new apps.
+func (p *Provider) SetPKCE(enabled bool) {
+	p.pkce = enabled
+}
+
 // BeginAuth asks Soundcloud for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
-	}, nil
+	session := &Session{}
+	var opts []oauth2.AuthCodeOption
+	if p.pkce {
+		session.CodeVerifier = goth.GeneratePKCEVerifier()
+		opts = append(opts, goth.PKCEChallengeOption(session.CodeVerifier))
+	}
+	session.AuthURL = p.config.AuthCodeURL(state, opts...)
+	return session, nil
 }
 
 // FetchUser will go to Soundcloud and access basic information about the user.
@@ -85,7 +109,12 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	response, err := p.Client().Get(endpointProfile + "?oauth_token=" + url.QueryEscape(sess.AccessToken))
+	req, err := http.NewRequest("GET", endpointProfile, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "OAuth "+sess.AccessToken)
+	response, err := p.Client().Do(req)
 	if err != nil {
 		if response != nil {
 			response.Body.Close()
@@ -126,6 +155,12 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 		Scopes: []string{},
 	}
 
+	if provider.Secret == "" {
+		// public clients authenticate with PKCE, sending their client_id
+		// in the body of the token requests
+		c.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+
 	if len(scopes) > 0 {
 		for _, scope := range scopes {
 			c.Scopes = append(c.Scopes, scope)
@@ -160,11 +195,10 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
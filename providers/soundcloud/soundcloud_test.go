@@ -32,7 +32,16 @@ func Test_BeginAuth(t *testing.T) {
 	session, err := p.BeginAuth("test_state")
 	s := session.(*soundcloud.Session)
 	a.NoError(err)
-	a.Contains(s.AuthURL, "soundcloud.com/connect")
+	a.Contains(s.AuthURL, "secure.soundcloud.com/authorize")
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+	a.NotEmpty(s.CodeVerifier)
+
+	p.SetPKCE(false)
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	s = session.(*soundcloud.Session)
+	a.NotContains(s.AuthURL, "code_challenge")
+	a.Empty(s.CodeVerifier)
 }
 
 func Test_SessionFromJSON(t *testing.T) {
@@ -1,14 +1,20 @@
 // Package spotify implements the OAuth protocol for authenticating users through Spotify.
 // This package can be used as a reference implementation of an OAuth provider for Goth.
+//
+// Spotify has no token revocation endpoint, so Provider doesn't implement
+// goth.TokenRevoker. Users remove an app's access from their account page.
 package spotify
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -19,7 +25,7 @@ const (
 )
 
 const (
-	// ScopePlaylistReadPrivate seeks permission to read
+	// ScopePlaylistReadCollaborative seeks permission to read
 	// a user's collaborative playlists.
 	ScopePlaylistReadCollaborative = "playlist-read-collaborative"
 	// ScopePlaylistReadPrivate seeks permission to read
@@ -68,6 +74,20 @@ const (
 	ScopeUserTopRead = "user-top-read"
 	// ScopeUserReadRecentlyPlayed seeks read access to a user’s recently played tracks.
 	ScopeUserReadRecentlyPlayed = "user-read-recently-played"
+	// ScopeUserReadPlaybackPosition seeks read access to a user’s playback
+	// position in episodes and audiobooks.
+	ScopeUserReadPlaybackPosition = "user-read-playback-position"
+)
+
+// Keys of User.RawData holding the subscription details of the user, which
+// Spotify only returns with the ScopeUserReadPrivate scope. The product, e.g.
+// "premium" or "free", and the country, an ISO 3166-1 alpha-2 code, are
+// strings. The explicit content settings are an object holding the booleans
+// "filter_enabled" and "filter_locked".
+const (
+	RawDataProduct         = "product"
+	RawDataCountry         = "country"
+	RawDataExplicitContent = "explicit_content"
 )
 
 // New creates a new Spotify provider and sets up important connection details.
@@ -92,6 +112,7 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+	pkce         bool
 }
 
 // Name gets the name used to retrieve this provider.
@@ -104,6 +125,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -111,18 +140,32 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the spotify package.
 func (p *Provider) Debug(debug bool) {}
 
+// SetPKCE enables or disables Proof Key for Code Exchange (PKCE) for the
+// authorization code flow, which Spotify recommends. It is disabled by
+// default for Spotify, and required for apps that can't keep a secret, which
+// are created with an empty secret.
+func (p *Provider) SetPKCE(enabled bool) {
+	p.pkce = enabled
+}
+
 // BeginAuth asks Spotify for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	url := p.config.AuthCodeURL(state)
-	session := &Session{
-		AuthURL: url,
+	session := &Session{}
+	var opts []oauth2.AuthCodeOption
+	if p.pkce {
+		session.CodeVerifier = goth.GeneratePKCEVerifier()
+		opts = append(opts, goth.PKCEChallengeOption(session.CodeVerifier))
 	}
+	session.AuthURL = p.config.AuthCodeURL(state, opts...)
 	return session, nil
 }
 
 // FetchUser will go to Spotify and access basic information about the user.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
-	s := session.(*Session)
+	return p.fetchUser(context.Background(), session.(*Session))
+}
+
+func (p *Provider) fetchUser(ctx context.Context, s *Session) (goth.User, error) {
 	user := goth.User{
 		AccessToken:  s.AccessToken,
 		Provider:     p.Name(),
@@ -135,7 +178,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	req, err := http.NewRequest("GET", userEndpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", userEndpoint, nil)
 	if err != nil {
 		return user, err
 	}
@@ -150,8 +193,15 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, resp.StatusCode)
 	}
 
-	// err = userFromReader(io.TeeReader(resp.Body, os.Stdout), &user)
-	err = userFromReader(resp.Body, &user)
+	bits, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return user, err
+	}
+	if err := json.Unmarshal(bits, &user.RawData); err != nil {
+		return user, err
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
 	return user, err
 }
 
@@ -193,6 +243,12 @@ func newConfig(p *Provider, scopes []string) *oauth2.Config {
 		Scopes: []string{ScopeUserReadEmail, ScopeUserReadPrivate},
 	}
 
+	if p.Secret == "" {
+		// public clients authenticate with PKCE, sending their client_id in
+		// the body of the token requests
+		c.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+
 	defaultScopes := map[string]struct{}{
 		ScopeUserReadEmail:   {},
 		ScopeUserReadPrivate: {},
@@ -214,11 +270,10 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
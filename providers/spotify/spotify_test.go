@@ -1,7 +1,11 @@
 package spotify_test
 
 import (
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -40,6 +44,50 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "accounts.spotify.com/authorize")
 }
 
+func Test_PKCE(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := spotify.New("client-id", "", "/foo")
+	p.SetPKCE(true)
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*spotify.Session)
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+	a.NotEmpty(s.CodeVerifier)
+
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+		body := `{"id":"wizzler","display_name":"JM Wizzler","country":"SE","product":"premium","explicit_content":{"filter_enabled":false,"filter_locked":false}}`
+		if r.URL.Path == "/api/token" {
+			a.NoError(r.ParseForm())
+			a.Equal(s.CodeVerifier, r.PostForm.Get("code_verifier"))
+			a.Equal("client-id", r.PostForm.Get("client_id"))
+			body = `{"access_token":"access","token_type":"Bearer","expires_in":3600,"refresh_token":"refresh"}`
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	})}
+
+	_, err = s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("wizzler", user.UserID)
+	a.Equal("premium", user.RawData[spotify.RawDataProduct])
+	a.Equal("SE", user.RawData[spotify.RawDataCountry])
+	a.Equal(map[string]interface{}{"filter_enabled": false, "filter_locked": false}, user.RawData[spotify.RawDataExplicitContent])
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -0,0 +1,161 @@
+package steam
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/markbates/goth"
+)
+
+// PersonaState is the online status of a player.
+type PersonaState int
+
+// Persona states, see https://developer.valvesoftware.com/wiki/Steam_Web_API#GetPlayerSummaries_.28v0002.29
+const (
+	PersonaStateOffline PersonaState = iota
+	PersonaStateOnline
+	PersonaStateBusy
+	PersonaStateAway
+	PersonaStateSnooze
+	PersonaStateLookingToTrade
+	PersonaStateLookingToPlay
+)
+
+// CommunityVisibilityState is the visibility of a player's profile. Only the
+// public fields of private and friends only profiles are returned.
+type CommunityVisibilityState int
+
+// Community visibility states as returned by the Web API.
+const (
+	CommunityVisibilityPrivate     CommunityVisibilityState = 1
+	CommunityVisibilityFriendsOnly CommunityVisibilityState = 2
+	CommunityVisibilityPublic      CommunityVisibilityState = 3
+)
+
+// Player is the summary of a player returned by GetPlayerSummaries, which
+// FetchUser stores in the user's RawData. Use PlayerFromUser to get it back.
+type Player struct {
+	SteamID                  string                   `json:"steamid"`
+	PersonaName              string                   `json:"personaname"`
+	RealName                 string                   `json:"realname"`
+	ProfileURL               string                   `json:"profileurl"`
+	Avatar                   string                   `json:"avatar"`
+	AvatarMedium             string                   `json:"avatarmedium"`
+	AvatarFull               string                   `json:"avatarfull"`
+	PersonaState             PersonaState             `json:"personastate"`
+	CommunityVisibilityState CommunityVisibilityState `json:"communityvisibilitystate"`
+	ProfileState             int                      `json:"profilestate"`
+	LastLogoff               int64                    `json:"lastlogoff"`
+	TimeCreated              int64                    `json:"timecreated"`
+	CountryCode              string                   `json:"loccountrycode"`
+	StateCode                string                   `json:"locstatecode"`
+}
+
+// PlayerFromUser returns the player summary stored in the RawData of a user
+// fetched from Steam.
+func PlayerFromUser(user goth.User) (Player, error) {
+	player := Player{}
+	b, err := json.Marshal(user.RawData)
+	if err != nil {
+		return player, err
+	}
+	err = json.Unmarshal(b, &player)
+	return player, err
+}
+
+// VanityName returns the custom name of the profile URL of the player, e.g.
+// "gabelogannewell" for https://steamcommunity.com/id/gabelogannewell/, or ""
+// if the player hasn't set one.
+func (p Player) VanityName() string {
+	u, err := url.Parse(p.ProfileURL)
+	if err != nil || !strings.HasPrefix(u.Path, "/id/") {
+		return ""
+	}
+	return strings.Trim(strings.TrimPrefix(u.Path, "/id/"), "/")
+}
+
+// APICall is a Steam Web API method called by FetchUser for the signed in user.
+// The "response" object it returns is stored in the user's RawData under Key.
+type APICall struct {
+	Key string
+	// Method is the path of the method, e.g. "IPlayerService/GetOwnedGames/v1".
+	Method string
+	// Params are added to the key and steamid parameters of the call.
+	Params url.Values
+}
+
+// Common API calls, see https://partner.steamgames.com/doc/webapi
+var (
+	// OwnedGames lists the games owned by the user, with their names.
+	OwnedGames = APICall{
+		Key:    "owned_games",
+		Method: "IPlayerService/GetOwnedGames/v1",
+		Params: url.Values{"include_appinfo": {"1"}, "include_played_free_games": {"1"}},
+	}
+	// SteamLevel is the Steam level of the user.
+	SteamLevel = APICall{
+		Key:    "steam_level",
+		Method: "IPlayerService/GetSteamLevel/v1",
+	}
+)
+
+func (p *Provider) callAPI(call APICall, steamID string) (interface{}, error) {
+	params := url.Values{}
+	for k, v := range call.Params {
+		params[k] = v
+	}
+	params.Set("steamid", steamID)
+
+	response := struct {
+		Response interface{} `json:"response"`
+	}{}
+	if err := p.getAPI(call.Method, params, &response); err != nil {
+		return nil, err
+	}
+	return response.Response, nil
+}
+
+// ResolveVanityURL returns the Steam ID of the player with the given vanity
+// name, i.e. the custom part of their profile URL.
+func (p *Provider) ResolveVanityURL(vanityName string) (string, error) {
+	response := struct {
+		Response struct {
+			Success int    `json:"success"`
+			SteamID string `json:"steamid"`
+			Message string `json:"message"`
+		} `json:"response"`
+	}{}
+	err := p.getAPI("ISteamUser/ResolveVanityURL/v1", url.Values{"vanityurl": {vanityName}}, &response)
+	if err != nil {
+		return "", err
+	}
+	if response.Response.Success != 1 {
+		return "", fmt.Errorf("%s could not resolve vanity URL %q: %s", p.providerName, vanityName, response.Response.Message)
+	}
+	return response.Response.SteamID, nil
+}
+
+func (p *Provider) getAPI(method string, params url.Values, v interface{}) error {
+	params.Set("key", p.APIKey)
+	params.Set("format", "json")
+
+	req, err := http.NewRequest("GET", apiBaseURL+method+"/?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to call %s", p.providerName, resp.StatusCode, method)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -4,7 +4,9 @@ package steam
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
@@ -28,25 +30,63 @@ func (s Session) GetAuthURL() (string, error) {
 	return s.AuthURL, nil
 }
 
+// requiredSignedFields must all be covered by the signature of a positive
+// assertion, see https://openid.net/specs/openid-authentication-2_0.html#positive_assertions
+var requiredSignedFields = []string{"op_endpoint", "return_to", "response_nonce", "assoc_handle", "claimed_id", "identity"}
+
+// claimedIDPrefix is the only valid prefix of claimed identifiers, which end
+// with the 64 bit Steam ID of the user.
+const claimedIDPrefix = "https://steamcommunity.com/openid/id/"
+
+var steamIDPattern = regexp.MustCompile("^[0-9]{17}$")
+
 // Authorize the session with Steam and return the unique response_nonce by OpenID.
+// The assertion is checked against the expected endpoint and callback, and its
+// signature is verified by Steam.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
 	if params.Get("openid.mode") != "id_res" {
 		return "", errors.New("Mode must equal to \"id_res\".")
 	}
 
+	if params.Get("openid.ns") != openIDNs {
+		return "", errors.New("Wrong ns in the assertion.")
+	}
+
+	if params.Get("openid.op_endpoint") != apiLoginEndpoint {
+		return "", errors.New("The assertion was not issued by Steam.")
+	}
+
 	if params.Get("openid.return_to") != s.CallbackURL {
 		return "", errors.New("The \"return_to url\" must match the url of current request.")
 	}
 
+	signed := map[string]bool{}
+	for _, item := range strings.Split(params.Get("openid.signed"), ",") {
+		signed[item] = true
+	}
+	for _, field := range requiredSignedFields {
+		if !signed[field] {
+			return "", fmt.Errorf("The assertion must sign %q.", field)
+		}
+	}
+
+	claimedID := params.Get("openid.claimed_id")
+	if claimedID != params.Get("openid.identity") {
+		return "", errors.New("The claimed id must match the identity.")
+	}
+	steamID, err := steamIDFromClaimedID(claimedID)
+	if err != nil {
+		return "", err
+	}
+
 	v := make(url.Values)
 	v.Set("openid.assoc_handle", params.Get("openid.assoc_handle"))
 	v.Set("openid.signed", params.Get("openid.signed"))
 	v.Set("openid.sig", params.Get("openid.sig"))
 	v.Set("openid.ns", params.Get("openid.ns"))
 
-	split := strings.Split(params.Get("openid.signed"), ",")
-	for _, item := range split {
+	for item := range signed {
 		v.Set("openid."+item, params.Get("openid."+item))
 	}
 	v.Set("openid.mode", "check_authentication")
@@ -56,32 +96,55 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 		return "", err
 	}
 	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s responded with a %d trying to verify the assertion", p.providerName, resp.StatusCode)
+	}
+
 	content, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return "", err
 	}
 
-	response := strings.Split(string(content), "\n")
-	if response[0] != "ns:"+openIDNs {
-		return "", errors.New("Wrong ns in the response.")
+	response := map[string]string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			response[key] = value
+		}
 	}
 
-	if response[1] == "is_valid:false" {
-		return "", errors.New("Unable validate openId.")
+	if response["ns"] != openIDNs {
+		return "", errors.New("Wrong ns in the response.")
 	}
 
-	openIDURL := params.Get("openid.claimed_id")
-	validationRegExp := regexp.MustCompile("^(http|https)://steamcommunity.com/openid/id/[0-9]{15,25}$")
-	if !validationRegExp.MatchString(openIDURL) {
-		return "", errors.New("Invalid Steam ID pattern.")
+	if response["is_valid"] != "true" {
+		return "", errors.New("Unable validate openId.")
 	}
 
-	s.SteamID = regexp.MustCompile("\\D+").ReplaceAllString(openIDURL, "")
+	s.SteamID = steamID
 	s.ResponseNonce = params.Get("openid.response_nonce")
 
 	return s.ResponseNonce, nil
 }
 
+// steamIDFromClaimedID returns the Steam ID of a claimed identifier, which
+// must be on steamcommunity.com over https.
+func steamIDFromClaimedID(claimedID string) (string, error) {
+	u, err := url.Parse(claimedID)
+	if err != nil || u.Scheme != "https" || u.Host != "steamcommunity.com" || u.RawQuery != "" || u.Fragment != "" {
+		return "", errors.New("Invalid Steam ID pattern.")
+	}
+	if !strings.HasPrefix(claimedID, claimedIDPrefix) {
+		return "", errors.New("Invalid Steam ID pattern.")
+	}
+
+	steamID := strings.TrimPrefix(claimedID, claimedIDPrefix)
+	if !steamIDPattern.MatchString(steamID) {
+		return "", errors.New("Invalid Steam ID pattern.")
+	}
+	return steamID, nil
+}
+
 // Marshal the session into a string
 func (s Session) Marshal() string {
 	b, _ := json.Marshal(s)
@@ -15,7 +15,8 @@ import (
 const (
 	// Steam API Endpoints
 	apiLoginEndpoint       = "https://steamcommunity.com/openid/login"
-	apiUserSummaryEndpoint = "http://api.steampowered.com/ISteamUser/GetPlayerSummaries/v0002/?key=%s&steamids=%s"
+	apiBaseURL             = "https://api.steampowered.com/"
+	apiUserSummaryEndpoint = apiBaseURL + "ISteamUser/GetPlayerSummaries/v0002/?key=%s&steamids=%s"
 
 	// OpenID settings
 	openIDMode       = "checkid_setup"
@@ -41,6 +42,7 @@ type Provider struct {
 	CallbackURL  string
 	HTTPClient   *http.Client
 	providerName string
+	apiCalls     []APICall
 }
 
 // Name gets the name used to retrieve this provider.
@@ -53,10 +55,23 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
 
+// SetAPICalls sets the Web API methods called by FetchUser, in addition to
+// GetPlayerSummaries, to fetch more data about the user, e.g. OwnedGames.
+func (p *Provider) SetAPICalls(calls ...APICall) {
+	p.apiCalls = calls
+}
+
 // Debug is no-op for the Steam package.
 func (p *Provider) Debug(debug bool) {}
 
@@ -114,7 +129,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return u, fmt.Errorf("%s cannot get user information without SteamID", p.providerName)
 	}
 
-	apiURL := fmt.Sprintf(apiUserSummaryEndpoint, p.APIKey, s.SteamID)
+	apiURL := fmt.Sprintf(apiUserSummaryEndpoint, url.QueryEscape(p.APIKey), url.QueryEscape(s.SteamID))
 	req, err := http.NewRequest("GET", apiURL, nil)
 	if err != nil {
 		return u, err
@@ -134,8 +149,19 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	u, err = buildUserObject(resp.Body, u)
+	if err != nil {
+		return u, err
+	}
+
+	for _, call := range p.apiCalls {
+		data, err := p.callAPI(call, s.SteamID)
+		if err != nil {
+			return u, err
+		}
+		u.RawData[call.Key] = data
+	}
 
-	return u, err
+	return u, nil
 }
 
 // buildUserObject is an internal function to build a goth.User object
@@ -144,14 +170,7 @@ func buildUserObject(r io.Reader, u goth.User) (goth.User, error) {
 	// Response object from Steam
 	apiResponse := struct {
 		Response struct {
-			Players []struct {
-				UserID              string `json:"steamid"`
-				NickName            string `json:"personaname"`
-				Name                string `json:"realname"`
-				AvatarURL           string `json:"avatarfull"`
-				LocationCountryCode string `json:"loccountrycode"`
-				LocationStateCode   string `json:"locstatecode"`
-			} `json:"players"`
+			Players []json.RawMessage `json:"players"`
 		} `json:"response"`
 	}{}
 
@@ -164,23 +183,31 @@ func buildUserObject(r io.Reader, u goth.User) (goth.User, error) {
 		return u, fmt.Errorf("Expected one player in API response. Got %d.", l)
 	}
 
-	player := apiResponse.Response.Players[0]
-	u.UserID = player.UserID
-	u.Name = player.Name
-	if len(player.Name) == 0 {
+	raw := apiResponse.Response.Players[0]
+	if err := json.Unmarshal(raw, &u.RawData); err != nil {
+		return u, err
+	}
+	player := Player{}
+	if err := json.Unmarshal(raw, &player); err != nil {
+		return u, err
+	}
+
+	u.UserID = player.SteamID
+	u.Name = player.RealName
+	if len(player.RealName) == 0 {
 		u.Name = "No name is provided by the Steam API"
 	}
-	u.NickName = player.NickName
-	u.AvatarURL = player.AvatarURL
+	u.NickName = player.PersonaName
+	u.AvatarURL = player.AvatarFull
 	u.Email = "No email is provided by the Steam API"
 	u.Description = "No description is provided by the Steam API"
 
-	if len(player.LocationStateCode) > 0 && len(player.LocationCountryCode) > 0 {
-		u.Location = fmt.Sprintf("%s, %s", player.LocationStateCode, player.LocationCountryCode)
-	} else if len(player.LocationCountryCode) > 0 {
-		u.Location = player.LocationCountryCode
-	} else if len(player.LocationStateCode) > 0 {
-		u.Location = player.LocationStateCode
+	if len(player.StateCode) > 0 && len(player.CountryCode) > 0 {
+		u.Location = fmt.Sprintf("%s, %s", player.StateCode, player.CountryCode)
+	} else if len(player.CountryCode) > 0 {
+		u.Location = player.CountryCode
+	} else if len(player.StateCode) > 0 {
+		u.Location = player.StateCode
 	} else {
 		u.Location = "No location is provided by the Steam API"
 	}
@@ -1,7 +1,11 @@
 package steam_test
 
 import (
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -50,6 +54,148 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.ResponseNonce, "2016-03-13T16:56:30ZJ8tlKVquwHi9ZSPV4ElU5PY2dmI=")
 }
 
+func Test_Authorize(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := steam.New("key", "http://localhost/callback")
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("https://steamcommunity.com/openid/login", req.URL.String())
+		a.NoError(req.ParseForm())
+		a.Equal("check_authentication", req.PostForm.Get("openid.mode"))
+		a.Equal("https://steamcommunity.com/openid/id/76561197960435530", req.PostForm.Get("openid.claimed_id"))
+		return response("ns:http://specs.openid.net/auth/2.0\nis_valid:true\n"), nil
+	})}
+
+	assertion := func() url.Values {
+		return url.Values{
+			"openid.ns":             {"http://specs.openid.net/auth/2.0"},
+			"openid.mode":           {"id_res"},
+			"openid.op_endpoint":    {"https://steamcommunity.com/openid/login"},
+			"openid.claimed_id":     {"https://steamcommunity.com/openid/id/76561197960435530"},
+			"openid.identity":       {"https://steamcommunity.com/openid/id/76561197960435530"},
+			"openid.return_to":      {"http://localhost/callback"},
+			"openid.response_nonce": {"2024-01-01T00:00:00Zabc"},
+			"openid.assoc_handle":   {"1234567890"},
+			"openid.signed":         {"signed,op_endpoint,claimed_id,identity,return_to,response_nonce,assoc_handle"},
+			"openid.sig":            {"sig"},
+		}
+	}
+
+	s := &steam.Session{CallbackURL: "http://localhost/callback"}
+	nonce, err := s.Authorize(p, assertion())
+	a.NoError(err)
+	a.Equal("2024-01-01T00:00:00Zabc", nonce)
+	a.Equal("76561197960435530", s.SteamID)
+
+	params := assertion()
+	params.Set("openid.claimed_id", "https://steamcommunity.com.evil.com/openid/id/76561197960435530")
+	params.Set("openid.identity", params.Get("openid.claimed_id"))
+	_, err = (&steam.Session{CallbackURL: "http://localhost/callback"}).Authorize(p, params)
+	a.Error(err)
+
+	params = assertion()
+	params.Set("openid.identity", "https://steamcommunity.com/openid/id/76561197960435531")
+	_, err = (&steam.Session{CallbackURL: "http://localhost/callback"}).Authorize(p, params)
+	a.Error(err)
+
+	params = assertion()
+	params.Set("openid.signed", "signed,op_endpoint,return_to,response_nonce,assoc_handle")
+	_, err = (&steam.Session{CallbackURL: "http://localhost/callback"}).Authorize(p, params)
+	a.Error(err)
+
+	params = assertion()
+	params.Set("openid.op_endpoint", "https://evil.com/openid/login")
+	_, err = (&steam.Session{CallbackURL: "http://localhost/callback"}).Authorize(p, params)
+	a.Error(err)
+}
+
+func Test_Authorize_Invalid(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := steam.New("key", "http://localhost/callback")
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		return response("ns:http://specs.openid.net/auth/2.0\n"), nil
+	})}
+
+	_, err := (&steam.Session{CallbackURL: "http://localhost/callback"}).Authorize(p, url.Values{
+		"openid.ns":          {"http://specs.openid.net/auth/2.0"},
+		"openid.mode":        {"id_res"},
+		"openid.op_endpoint": {"https://steamcommunity.com/openid/login"},
+		"openid.claimed_id":  {"https://steamcommunity.com/openid/id/76561197960435530"},
+		"openid.identity":    {"https://steamcommunity.com/openid/id/76561197960435530"},
+		"openid.return_to":   {"http://localhost/callback"},
+		"openid.signed":      {"op_endpoint,claimed_id,identity,return_to,response_nonce,assoc_handle"},
+	})
+	a.EqualError(err, "Unable validate openId.")
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := steam.New("key", "http://localhost/callback")
+	p.SetAPICalls(steam.OwnedGames)
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("key", req.URL.Query().Get("key"))
+		switch req.URL.Path {
+		case "/ISteamUser/GetPlayerSummaries/v0002/":
+			return response(`{"response":{"players":[{"steamid":"76561197960435530","personaname":"Robin","profileurl":"https://steamcommunity.com/id/robinwalker/","personastate":1,"communityvisibilitystate":3,"loccountrycode":"US"}]}}`), nil
+		case "/IPlayerService/GetOwnedGames/v1/":
+			a.Equal("76561197960435530", req.URL.Query().Get("steamid"))
+			a.Equal("1", req.URL.Query().Get("include_appinfo"))
+			return response(`{"response":{"game_count":1,"games":[{"appid":440,"name":"Team Fortress 2"}]}}`), nil
+		}
+		t.Fatalf("unexpected request to %s", req.URL)
+		return nil, nil
+	})}
+
+	user, err := p.FetchUser(&steam.Session{SteamID: "76561197960435530"})
+	a.NoError(err)
+	a.Equal("76561197960435530", user.UserID)
+	a.Equal("Robin", user.NickName)
+	a.Equal("US", user.Location)
+	a.Equal(float64(1), user.RawData["owned_games"].(map[string]interface{})["game_count"])
+
+	player, err := steam.PlayerFromUser(user)
+	a.NoError(err)
+	a.Equal(steam.PersonaStateOnline, player.PersonaState)
+	a.Equal(steam.CommunityVisibilityPublic, player.CommunityVisibilityState)
+	a.Equal("robinwalker", player.VanityName())
+}
+
+func Test_ResolveVanityURL(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := steam.New("key", "http://localhost/callback")
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("/ISteamUser/ResolveVanityURL/v1/", req.URL.Path)
+		if req.URL.Query().Get("vanityurl") == "robinwalker" {
+			return response(`{"response":{"steamid":"76561197960435530","success":1}}`), nil
+		}
+		return response(`{"response":{"success":42,"message":"No match"}}`), nil
+	})}
+
+	steamID, err := p.ResolveVanityURL("robinwalker")
+	a.NoError(err)
+	a.Equal("76561197960435530", steamID)
+
+	_, err = p.ResolveVanityURL("nobody")
+	a.ErrorContains(err, "No match")
+}
+
 func provider() *steam.Provider {
 	return steam.New(os.Getenv("STEAM_KEY"), "/foo")
 }
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func response(body string) *http.Response {
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}
+}
@@ -4,15 +4,16 @@ package strava
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -22,15 +23,30 @@ const (
 	endpointProfile string = "https://www.strava.com/api/v3/athlete"
 )
 
+// ScopeSeparator is the separator Strava expects between scopes.
+const ScopeSeparator = ","
+
+// Scopes of Strava
+const (
+	ScopeRead            string = "read"
+	ScopeReadAll         string = "read_all"
+	ScopeProfileReadAll  string = "profile:read_all"
+	ScopeProfileWrite    string = "profile:write"
+	ScopeActivityRead    string = "activity:read"
+	ScopeActivityReadAll string = "activity:read_all"
+	ScopeActivityWrite   string = "activity:write"
+)
+
 // New creates a new Strava provider, and sets up important connection details.
 // You should always call `strava.New` to get a new Provider. Never try to create
 // one manually.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 	p := &Provider{
-		ClientKey:    clientKey,
-		Secret:       secret,
-		CallbackURL:  callbackURL,
-		providerName: "strava",
+		ClientKey:      clientKey,
+		Secret:         secret,
+		CallbackURL:    callbackURL,
+		providerName:   "strava",
+		scopeSeparator: ScopeSeparator,
 	}
 	p.config = newConfig(p, scopes)
 	return p
@@ -38,12 +54,14 @@ func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 
 // Provider is the implementation of `goth.Provider` for accessing Strava.
 type Provider struct {
-	ClientKey    string
-	Secret       string
-	CallbackURL  string
-	HTTPClient   *http.Client
-	config       *oauth2.Config
-	providerName string
+	ClientKey      string
+	Secret         string
+	CallbackURL    string
+	HTTPClient     *http.Client
+	config         *oauth2.Config
+	providerName   string
+	scopes         []string
+	scopeSeparator string
 }
 
 // Name is the name used to retrieve this provider later.
@@ -56,6 +74,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 // Client returns an HTTP client to be used in all fetch operations.
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
@@ -64,6 +90,13 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the strava package.
 func (p *Provider) Debug(debug bool) {}
 
+// SetScopeSeparator overrides the separator used to join the requested scopes,
+// which defaults to ScopeSeparator for Strava.
+func (p *Provider) SetScopeSeparator(sep string) {
+	p.scopeSeparator = sep
+	p.config.Scopes = goth.JoinScopes(sep, p.scopes...)
+}
+
 // BeginAuth asks Strava for an authentication endpoint.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 	authUrl := p.config.AuthCodeURL(state)
@@ -74,6 +107,8 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 }
 
 // FetchUser will go to Strava and access basic information about the user.
+// The athlete returned along with the token when the session was authorized
+// is used if the session holds one, without calling the API.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	sess := session.(*Session)
 	user := goth.User{
@@ -88,31 +123,39 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
-	reqUrl := fmt.Sprint(endpointProfile,
-		"?access_token=", url.QueryEscape(sess.AccessToken),
-	)
-	response, err := p.Client().Get(reqUrl)
+	bits := []byte(sess.Athlete)
+	if len(bits) == 0 {
+		var err error
+		bits, err = p.fetchAthlete(sess.AccessToken)
+		if err != nil {
+			return user, err
+		}
+	}
+
+	err := json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
 	if err != nil {
 		return user, err
 	}
-	defer response.Body.Close()
 
-	if response.StatusCode != http.StatusOK {
-		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
-	}
+	err = userFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
 
-	bits, err := ioutil.ReadAll(response.Body)
+func (p *Provider) fetchAthlete(accessToken string) ([]byte, error) {
+	reqUrl := fmt.Sprint(endpointProfile,
+		"?access_token=", url.QueryEscape(accessToken),
+	)
+	response, err := p.Client().Get(reqUrl)
 	if err != nil {
-		return user, err
+		return nil, err
 	}
+	defer response.Body.Close()
 
-	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
-	if err != nil {
-		return user, err
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
 	}
 
-	err = userFromReader(bytes.NewReader(bits), &user)
-	return user, err
+	return ioutil.ReadAll(response.Body)
 }
 
 func userFromReader(reader io.Reader, user *goth.User) error {
@@ -158,26 +201,30 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	}
 
 	if len(scopes) > 0 {
-		c.Scopes = []string{strings.Join(scopes, ",")}
+		c.Scopes = append(c.Scopes, scopes...)
 	} else {
 		c.Scopes = []string{"read"}
 	}
 
+	provider.scopes = c.Scopes
+	c.Scopes = goth.JoinScopes(provider.scopeSeparator, c.Scopes...)
 	return c
 }
 
-// RefreshTokenAvailable refresh token is not provided by Strava
+// RefreshTokenAvailable refresh token is provided by Strava
 func (p *Provider) RefreshTokenAvailable() bool {
 	return true
 }
 
-// RefreshToken refresh token is not provided by Strava
+// RefreshToken get new access token based on the refresh token. Strava may
+// hand out a new refresh token, invalidating the old one: the token returned
+// carries the refresh token to use from now on, and goth.RefreshToken reports
+// it to goth.TokenRotated.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
@@ -2,7 +2,11 @@ package strava_test
 
 import (
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -41,6 +45,23 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "scope=read")
 }
 
+func Test_BeginAuthWithScopes(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := strava.New(os.Getenv("STRAVA_KEY"), os.Getenv("STRAVA_SECRET"), "/foo", "activity:read", "activity:read_all")
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*strava.Session)
+	a.Contains(s.AuthURL, "scope=activity%3Aread%2Cactivity%3Aread_all")
+
+	p.SetScopeSeparator(" ")
+	session, err = p.BeginAuth("test_state")
+	a.NoError(err)
+	s = session.(*strava.Session)
+	a.Contains(s.AuthURL, "scope=activity%3Aread+activity%3Aread_all")
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -54,6 +75,56 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(session.AccessToken, "1234567890")
 }
 
+func Test_AthleteFromTokenResponse(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := stravaProvider()
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(r *http.Request) (*http.Response, error) {
+		a.Equal("/oauth/token", r.URL.Path)
+		a.NoError(r.ParseForm())
+		body := `{"token_type":"Bearer","access_token":"access","refresh_token":"refresh","expires_in":21600,` +
+			`"athlete":{"id":134815,"username":"marianne_t","firstname":"Marianne","lastname":"Teutenberg","city":"San Francisco","state":"CA","country":"US","sex":"F","profile":"https://example.com/large.jpg"}}`
+		if r.PostForm.Get("grant_type") == "refresh_token" {
+			a.Equal("refresh", r.PostForm.Get("refresh_token"))
+			body = `{"token_type":"Bearer","access_token":"new-access","refresh_token":"new-refresh","expires_in":21600}`
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	})}
+
+	s := &strava.Session{}
+	_, err := s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.NotEmpty(s.Athlete)
+
+	// the athlete endpoint isn't called
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("134815", user.UserID)
+	a.Equal("marianne_t", user.NickName)
+	a.Equal("Marianne Teutenberg", user.Name)
+	a.Equal("refresh", user.RefreshToken)
+
+	s2, err := p.UnmarshalSession(s.Marshal())
+	a.NoError(err)
+	a.Equal(s.Athlete, s2.(*strava.Session).Athlete)
+
+	token, err := p.RefreshToken(user.RefreshToken)
+	a.NoError(err)
+	a.Equal("new-access", token.AccessToken)
+	a.Equal("new-refresh", token.RefreshToken)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
 func stravaProvider() *strava.Provider {
 	return strava.New(os.Getenv("STRAVA_KEY"), os.Getenv("STRAVA_SECRET"), "/foo", "read")
 }
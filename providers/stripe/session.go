@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Stripe.
@@ -16,8 +18,12 @@ type Session struct {
 	RefreshToken string
 	ExpiresAt    time.Time
 	ID           string
+	TokenExtras  map[string]interface{} `json:",omitempty"`
 }
 
+// tokenExtras are the fields of the token response kept in the session.
+var tokenExtras = []string{"stripe_user_id", "stripe_publishable_key", "livemode", "scope"}
+
 var _ goth.Session = &Session{}
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the Stripe provider.
@@ -44,6 +50,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
 	s.ID = token.Extra("stripe_user_id").(string) // Required to get the user info from sales force
+	s.TokenExtras = goth.TokenExtras(token, tokenExtras...)
 	return token.AccessToken, err
 }
 
@@ -63,3 +70,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
 	return s, err
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with TikTok
@@ -86,10 +88,10 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 
 	// Create and Bind the Access Token
 	s.AccessToken = tokenResp.Data.AccessToken
-	s.ExpiresAt = time.Now().UTC().Add(time.Second * time.Duration(tokenResp.Data.ExpiresIn))
+	s.ExpiresAt = goth.Now().UTC().Add(time.Second * time.Duration(tokenResp.Data.ExpiresIn))
 	s.OpenID = tokenResp.Data.OpenID
 	s.RefreshToken = tokenResp.Data.RefreshToken
-	s.RefreshExpiresAt = time.Now().UTC().Add(time.Second * time.Duration(tokenResp.Data.RefreshExpiresIn))
+	s.RefreshExpiresAt = goth.Now().UTC().Add(time.Second * time.Duration(tokenResp.Data.RefreshExpiresIn))
 	return s.AccessToken, nil
 }
 
@@ -102,3 +104,13 @@ func (s Session) Marshal() string {
 func (s Session) String() string {
 	return s.Marshal()
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
@@ -4,6 +4,7 @@ package tiktok
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,9 +12,9 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -29,23 +30,28 @@ const (
 	ScopeShareSoundCreate = "share.sound.create"
 )
 
+// ScopeSeparator is the separator TikTok expects between scopes.
+const ScopeSeparator = ","
+
 // Provider is the implementation of `goth.Provider` for accessing TikTok
 type Provider struct {
-	CallbackURL  string
-	Client       *http.Client
-	ClientKey    string
-	ClientSecret string
-	config       *oauth2.Config
-	providerName string
+	CallbackURL    string
+	Client         *http.Client
+	ClientKey      string
+	ClientSecret   string
+	config         *oauth2.Config
+	providerName   string
+	scopeSeparator string
 }
 
 // New creates a new TikTok provider, and sets up connection details.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
 	p := &Provider{
-		ClientKey:    clientKey,
-		ClientSecret: secret,
-		CallbackURL:  callbackURL,
-		providerName: "tiktok",
+		ClientKey:      clientKey,
+		ClientSecret:   secret,
+		CallbackURL:    callbackURL,
+		providerName:   "tiktok",
+		scopeSeparator: ScopeSeparator,
 	}
 	p.config = newConfig(p, scopes)
 	return p
@@ -61,6 +67,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) GetClient() *http.Client {
 	return goth.HTTPClientWithFallBack(p.Client)
 }
@@ -68,6 +82,12 @@ func (p *Provider) GetClient() *http.Client {
 // Debug TODO
 func (p *Provider) Debug(debug bool) {}
 
+// SetScopeSeparator overrides the separator used to join the requested scopes,
+// which defaults to ScopeSeparator for TikTok.
+func (p *Provider) SetScopeSeparator(sep string) {
+	p.scopeSeparator = sep
+}
+
 // BeginAuth asks TikTok for an authentication end-point. Note that we create our own URL string instead
 // of calling oauth2.AuthCodeURL() due to TikTok param name requirements.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
@@ -83,9 +103,8 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 		v.Set("redirect_uri", p.config.RedirectURL)
 	}
 
-	// Note scopes are CSVs
 	if len(p.config.Scopes) > 0 {
-		v.Set("scope", strings.Join(p.config.Scopes, ","))
+		v.Set("scope", strings.Join(p.config.Scopes, p.scopeSeparator))
 	}
 
 	if strings.Contains(p.config.Endpoint.AuthURL, "?") {
@@ -189,7 +208,13 @@ func newConfig(p *Provider, scopes []string) *oauth2.Config {
 
 // RefreshToken will refresh a TikTok access token.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	req, err := http.NewRequest(http.MethodPost, endpointRefresh, nil)
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+// TikTok rotates refresh tokens, the new one is returned in the token.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpointRefresh, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -237,7 +262,7 @@ func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 		AccessToken:  refresh.Data.AccessToken,
 		TokenType:    "Bearer",
 		RefreshToken: refresh.Data.RefreshToken,
-		Expiry:       time.Now().Add(time.Second * time.Duration(refresh.Data.ExpiresIn)),
+		Expiry:       goth.ExpiryFromExpiresIn(refresh.Data.ExpiresIn),
 	}
 
 	tokenExtra := map[string]interface{}{
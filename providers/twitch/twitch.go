@@ -3,19 +3,24 @@
 package twitch
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
 const (
 	authURL      string = "https://id.twitch.tv/oauth2/authorize"
 	tokenURL     string = "https://id.twitch.tv/oauth2/token"
+	revokeURL    string = "https://id.twitch.tv/oauth2/revoke"
 	userEndpoint string = "https://api.twitch.tv/helix/users"
 )
 
@@ -245,6 +250,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 // Client ...
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
@@ -299,6 +312,21 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	return user, err
 }
 
+// avatarURLs returns the sizes of a profile image, which Twitch serves at
+// 300x300 by default and at 70x70 and 600x600 as well.
+func avatarURLs(avatarURL string) map[string]string {
+	const defaultSize = "-300x300."
+	if !strings.Contains(avatarURL, defaultSize) {
+		return nil
+	}
+	return goth.NewAvatarURLs(
+		strings.Replace(avatarURL, defaultSize, "-70x70.", 1),
+		avatarURL,
+		strings.Replace(avatarURL, defaultSize, "-600x600.", 1),
+		"",
+	)
+}
+
 func userFromReader(r io.Reader, user *goth.User) error {
 	var users struct {
 		Data []struct {
@@ -323,6 +351,7 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	user.NickName = u.Nickname
 	user.Location = "No location is provided by the Twitch API"
 	user.AvatarURL = u.AvatarURL
+	user.AvatarURLs = avatarURLs(u.AvatarURL)
 	user.Description = u.Description
 	user.UserID = u.ID
 
@@ -359,11 +388,35 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
+}
+
+// RevokeToken revokes the access token.
+// See https://dev.twitch.tv/docs/authentication/revoke-tokens/
+func (p *Provider) RevokeToken(ctx context.Context, token string) error {
+	form := url.Values{
+		"client_id": {p.ClientKey},
+		"token":     {token},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, revokeURL, strings.NewReader(form.Encode()))
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return newToken, err
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to revoke the token", p.providerName, response.StatusCode)
+	}
+	return nil
 }
@@ -2,6 +2,7 @@ package twitch
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -52,3 +53,18 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(s.AuthURL, "https://id.twitch.tv/oauth2/authorize")
 	a.Equal(s.AccessToken, "1234567890")
 }
+
+func Test_AvatarURLs(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	user := goth.User{}
+	err := userFromReader(strings.NewReader(`{"data":[{"id":"1","login":"homer","profile_image_url":"https://static-cdn.jtvnw.net/jtv_user_pictures/homer-profile_image-300x300.png"}]}`), &user)
+	a.NoError(err)
+	a.Equal("https://static-cdn.jtvnw.net/jtv_user_pictures/homer-profile_image-300x300.png", user.AvatarURL)
+	a.Equal("https://static-cdn.jtvnw.net/jtv_user_pictures/homer-profile_image-70x70.png", user.Avatar(goth.AvatarSmall))
+	a.Equal("https://static-cdn.jtvnw.net/jtv_user_pictures/homer-profile_image-600x600.png", user.Avatar(goth.AvatarLarge))
+	a.Equal(user.AvatarURL, user.Avatar(goth.AvatarOriginal))
+
+	a.Nil(avatarURLs("https://static-cdn.jtvnw.net/user-default-pictures-uv/default.png"))
+}
@@ -1,11 +1,13 @@
 package twitter
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"strings"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/internal/oauth1"
 	"github.com/mrjones/oauth"
 )
 
@@ -16,6 +18,24 @@ type Session struct {
 	RequestToken *oauth.RequestToken
 }
 
+var _ goth.OAuth1Session = &Session{}
+
+// GetRequestToken returns the request token and its secret.
+func (s Session) GetRequestToken() (string, string) {
+	if s.RequestToken == nil {
+		return "", ""
+	}
+	return s.RequestToken.Token, s.RequestToken.Secret
+}
+
+// GetAccessToken returns the access token and its secret.
+func (s Session) GetAccessToken() (string, string) {
+	if s.AccessToken == nil {
+		return "", ""
+	}
+	return s.AccessToken.Token, s.AccessToken.Secret
+}
+
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the Twitter provider.
 func (s Session) GetAuthURL() (string, error) {
 	if s.AuthURL == "" {
@@ -27,7 +47,11 @@ func (s Session) GetAuthURL() (string, error) {
 // Authorize the session with Twitter and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	accessToken, err := p.consumer.AuthorizeToken(s.RequestToken, params.Get("oauth_verifier"))
+	verifier, err := oauth1.Verifier(params, s.RequestToken)
+	if err != nil {
+		return "", err
+	}
+	accessToken, err := p.consumer.AccessToken(context.Background(), s.RequestToken, verifier)
 	if err != nil {
 		return "", err
 	}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/twitter"
+	"github.com/mrjones/oauth"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,6 +17,27 @@ func Test_Implements_Session(t *testing.T) {
 	a.Implements((*goth.Session)(nil), s)
 }
 
+func Test_OAuth1Session(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	s := &twitter.Session{}
+
+	token, secret := s.GetRequestToken()
+	a.Equal("", token)
+	a.Equal("", secret)
+
+	s.RequestToken = &oauth.RequestToken{Token: "request", Secret: "request-secret"}
+	s.AccessToken = &oauth.AccessToken{Token: "access", Secret: "access-secret"}
+
+	var sess goth.OAuth1Session = s
+	token, secret = sess.GetRequestToken()
+	a.Equal("request", token)
+	a.Equal("request-secret", secret)
+	token, secret = sess.GetAccessToken()
+	a.Equal("access", token)
+	a.Equal("access-secret", secret)
+}
+
 func Test_GetAuthURL(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
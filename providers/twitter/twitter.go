@@ -4,6 +4,7 @@ package twitter
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,7 +12,7 @@ import (
 	"net/http"
 
 	"github.com/markbates/goth"
-	"github.com/mrjones/oauth"
+	"github.com/markbates/goth/providers/internal/oauth1"
 	"golang.org/x/oauth2"
 )
 
@@ -59,7 +60,7 @@ type Provider struct {
 	CallbackURL  string
 	HTTPClient   *http.Client
 	debug        bool
-	consumer     *oauth.Consumer
+	consumer     *oauth1.Consumer
 	providerName string
 }
 
@@ -73,19 +74,28 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
 
-// Debug sets the logging of the OAuth client to verbose.
+// Debug sets the logging of the OAuth client to verbose. The output, with
+// its tokens redacted, goes to the logger set with goth.SetLogger.
 func (p *Provider) Debug(debug bool) {
 	p.debug = debug
+	p.consumer.Debug = debug
 }
 
 // BeginAuth asks Twitter for an authentication end-point and a request token for a session.
 // Twitter does not support the "state" variable.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	requestToken, url, err := p.consumer.GetRequestTokenAndUrl(p.CallbackURL)
+	requestToken, url, err := p.consumer.RequestToken(context.Background(), p.CallbackURL)
 	session := &Session{
 		AuthURL:      url,
 		RequestToken: requestToken,
@@ -106,6 +116,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	response, err := p.consumer.Get(
+		context.Background(),
 		endpointProfile,
 		map[string]string{"include_entities": "false", "skip_status": "true", "include_email": "true"},
 		sess.AccessToken)
@@ -142,17 +153,18 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	return user, err
 }
 
-func newConsumer(provider *Provider, authURL string) *oauth.Consumer {
-	c := oauth.NewConsumer(
+func newConsumer(provider *Provider, authURL string) *oauth1.Consumer {
+	c := oauth1.NewConsumer(
 		provider.ClientKey,
 		provider.Secret,
-		oauth.ServiceProvider{
-			RequestTokenUrl:   requestURL,
-			AuthorizeTokenUrl: authURL,
-			AccessTokenUrl:    tokenURL,
-		})
-
-	c.Debug(provider.debug)
+		oauth1.Endpoint{
+			RequestTokenURL: requestURL,
+			AuthorizeURL:    authURL,
+			AccessTokenURL:  tokenURL,
+		},
+		provider.Client)
+
+	c.Debug = provider.debug
 	return c
 }
 
@@ -99,7 +99,7 @@ func twitterProviderAuthenticate() *Provider {
 func init() {
 	p := pat.New()
 	p.Get("/oauth/request_token", func(res http.ResponseWriter, req *http.Request) {
-		fmt.Fprint(res, "oauth_token=TOKEN&oauth_token_secret=SECRET")
+		fmt.Fprint(res, "oauth_token=TOKEN&oauth_token_secret=SECRET&oauth_callback_confirmed=true")
 	})
 	p.Get("/1.1/account/verify_credentials.json", func(res http.ResponseWriter, req *http.Request) {
 		data := map[string]string{
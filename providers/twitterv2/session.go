@@ -1,12 +1,17 @@
 package twitterv2
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"github.com/markbates/goth/providers/internal/oauth1"
 	"github.com/mrjones/oauth"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Twitter.
@@ -16,6 +21,24 @@ type Session struct {
 	RequestToken *oauth.RequestToken
 }
 
+var _ goth.OAuth1Session = &Session{}
+
+// GetRequestToken returns the request token and its secret.
+func (s Session) GetRequestToken() (string, string) {
+	if s.RequestToken == nil {
+		return "", ""
+	}
+	return s.RequestToken.Token, s.RequestToken.Secret
+}
+
+// GetAccessToken returns the access token and its secret.
+func (s Session) GetAccessToken() (string, string) {
+	if s.AccessToken == nil {
+		return "", ""
+	}
+	return s.AccessToken.Token, s.AccessToken.Secret
+}
+
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the Twitter provider.
 func (s Session) GetAuthURL() (string, error) {
 	if s.AuthURL == "" {
@@ -27,7 +50,11 @@ func (s Session) GetAuthURL() (string, error) {
 // Authorize the session with Twitter and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	accessToken, err := p.consumer.AuthorizeToken(s.RequestToken, params.Get("oauth_verifier"))
+	verifier, err := oauth1.Verifier(params, s.RequestToken)
+	if err != nil {
+		return "", err
+	}
+	accessToken, err := p.consumer.AccessToken(context.Background(), s.RequestToken, verifier)
 	if err != nil {
 		return "", err
 	}
@@ -46,9 +73,71 @@ func (s Session) String() string {
 	return s.Marshal()
 }
 
-// UnmarshalSession will unmarshal a JSON string into a session.
+// UnmarshalSession will unmarshal a JSON string into a session. It returns an
+// *OAuth2Session for providers created with NewOAuth2.
 func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
+	if p.config != nil {
+		sess := &OAuth2Session{}
+		err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
+		return sess, err
+	}
+
 	sess := &Session{}
 	err := json.NewDecoder(strings.NewReader(data)).Decode(sess)
 	return sess, err
 }
+
+// OAuth2Session stores data during the OAuth 2.0 auth process with Twitter.
+type OAuth2Session struct {
+	AuthURL      string
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+	CodeVerifier string `json:",omitempty"`
+}
+
+// GetAuthURL will return the URL set by calling the `BeginAuth` function on the Twitter provider.
+func (s OAuth2Session) GetAuthURL() (string, error) {
+	if s.AuthURL == "" {
+		return "", errors.New(goth.NoAuthUrlErrorMessage)
+	}
+	return s.AuthURL, nil
+}
+
+// Authorize the session with Twitter and return the access token to be stored for future use.
+func (s *OAuth2Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
+	p := provider.(*Provider)
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"), goth.PKCEVerifierOption(s.CodeVerifier))
+	if err != nil {
+		return "", err
+	}
+
+	if !token.Valid() {
+		return "", errors.New("Invalid token received from provider")
+	}
+
+	s.AccessToken = token.AccessToken
+	s.RefreshToken = token.RefreshToken
+	s.ExpiresAt = token.Expiry
+	return token.AccessToken, err
+}
+
+// Marshal the session into a string
+func (s OAuth2Session) Marshal() string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func (s OAuth2Session) String() string {
+	return s.Marshal()
+}
+
+// Token implements goth.TokenSession.
+func (s *OAuth2Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *OAuth2Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
@@ -1,17 +1,21 @@
-// Package twitterv2 implements the OAuth protocol for authenticating users through Twitter.
+// Package twitterv2 implements the OAuth protocol for authenticating users through Twitter,
+// using the v2 API. Both OAuth 1.0a (New) and OAuth 2.0 with PKCE (NewOAuth2) are supported.
 // This package can be used as a reference implementation of an OAuth provider for Goth.
 package twitterv2
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 
 	"github.com/markbates/goth"
-	"github.com/mrjones/oauth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"github.com/markbates/goth/providers/internal/oauth1"
 	"golang.org/x/oauth2"
 )
 
@@ -21,6 +25,19 @@ var (
 	authenticateURL = "https://api.twitter.com/oauth/authenticate"
 	tokenURL        = "https://api.twitter.com/oauth/access_token"
 	endpointProfile = "https://api.twitter.com/2/users/me"
+
+	oauth2AuthURL  = "https://twitter.com/i/oauth2/authorize"
+	oauth2TokenURL = "https://api.twitter.com/2/oauth2/token"
+)
+
+// userFields are the fields requested from the /2/users/me endpoint.
+const userFields = "id,name,username,description,profile_image_url,location,verified,verified_type"
+
+// OAuth 2.0 scopes, see https://developer.twitter.com/en/docs/authentication/oauth-2-0/authorization-code
+const (
+	ScopeTweetRead     = "tweet.read"
+	ScopeUsersRead     = "users.read"
+	ScopeOfflineAccess = "offline.access"
 )
 
 // New creates a new Twitter provider, and sets up important connection details.
@@ -52,6 +69,22 @@ func NewAuthenticate(clientKey, secret, callbackURL string) *Provider {
 	return p
 }
 
+// NewOAuth2 creates a new Twitter provider using OAuth 2.0 Authorization Code
+// with PKCE, with the OAuth 2.0 client ID and secret of the app. The secret is
+// empty for public clients. The scopes default to tweet.read and users.read,
+// which the /2/users/me endpoint requires; add ScopeOfflineAccess to get a
+// refresh token.
+func NewOAuth2(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "twitterv2",
+	}
+	p.config = newConfig(p, scopes)
+	return p
+}
+
 // Provider is the implementation of `goth.Provider` for accessing Twitter.
 type Provider struct {
 	ClientKey    string
@@ -59,7 +92,8 @@ type Provider struct {
 	CallbackURL  string
 	HTTPClient   *http.Client
 	debug        bool
-	consumer     *oauth.Consumer
+	consumer     *oauth1.Consumer
+	config       *oauth2.Config
 	providerName string
 }
 
@@ -73,6 +107,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -80,12 +122,23 @@ func (p *Provider) Client() *http.Client {
 // Debug sets the logging of the OAuth client to verbose.
 func (p *Provider) Debug(debug bool) {
 	p.debug = debug
+	if p.consumer != nil {
+		p.consumer.Debug = debug
+	}
 }
 
 // BeginAuth asks Twitter for an authentication end-point and a request token for a session.
-// Twitter does not support the "state" variable.
+// Twitter does not support the "state" variable with OAuth 1.0a.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	requestToken, url, err := p.consumer.GetRequestTokenAndUrl(p.CallbackURL)
+	if p.config != nil {
+		session := &OAuth2Session{
+			CodeVerifier: goth.GeneratePKCEVerifier(),
+		}
+		session.AuthURL = p.config.AuthCodeURL(state, goth.PKCEChallengeOption(session.CodeVerifier))
+		return session, nil
+	}
+
+	requestToken, url, err := p.consumer.RequestToken(context.Background(), p.CallbackURL)
 	session := &Session{
 		AuthURL:      url,
 		RequestToken: requestToken,
@@ -95,6 +148,10 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 
 // FetchUser will go to Twitter and access basic information about the user.
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	if sess, ok := session.(*OAuth2Session); ok {
+		return p.fetchOAuth2User(sess)
+	}
+
 	sess := session.(*Session)
 	user := goth.User{
 		Provider: p.Name(),
@@ -106,21 +163,58 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	response, err := p.consumer.Get(
+		context.Background(),
 		endpointProfile,
-		map[string]string{"user.fields": "id,name,username,description,profile_image_url,location"},
+		map[string]string{"user.fields": userFields},
 		sess.AccessToken)
 	if err != nil {
 		return user, err
 	}
 	defer response.Body.Close()
 
+	user.AccessToken = sess.AccessToken.Token
+	user.AccessTokenSecret = sess.AccessToken.Secret
+	err = p.userFromResponse(response, &user)
+	return user, err
+}
+
+func (p *Provider) fetchOAuth2User(sess *OAuth2Session) (goth.User, error) {
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+		Provider:     p.Name(),
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", endpointProfile+"?user.fields="+url.QueryEscape(userFields), nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer response.Body.Close()
+
+	err = p.userFromResponse(response, &user)
+	return user, err
+}
+
+func (p *Provider) userFromResponse(response *http.Response, user *goth.User) error {
 	if response.StatusCode != http.StatusOK {
-		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
+		return fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
 	}
 
 	bits, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return user, err
+		return err
 	}
 
 	userInfo := struct {
@@ -129,45 +223,76 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 
 	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&userInfo)
 	if err != nil {
-		return user, err
+		return err
 	}
 
 	user.RawData = userInfo.Data
-	user.Name = user.RawData["name"].(string)
-	user.NickName = user.RawData["username"].(string)
-	if user.RawData["description"] != nil {
-		user.Description = user.RawData["description"].(string)
-	}
-	user.AvatarURL = user.RawData["profile_image_url"].(string)
-	user.UserID = user.RawData["id"].(string)
-	if user.RawData["location"] != nil {
-		user.Location = user.RawData["location"].(string)
-	}
-	user.AccessToken = sess.AccessToken.Token
-	user.AccessTokenSecret = sess.AccessToken.Secret
-	return user, err
+	user.Name, _ = user.RawData["name"].(string)
+	user.NickName, _ = user.RawData["username"].(string)
+	user.Description, _ = user.RawData["description"].(string)
+	user.AvatarURL, _ = user.RawData["profile_image_url"].(string)
+	user.UserID, _ = user.RawData["id"].(string)
+	user.Location, _ = user.RawData["location"].(string)
+	return nil
 }
 
-func newConsumer(provider *Provider, authURL string) *oauth.Consumer {
-	c := oauth.NewConsumer(
+func newConsumer(provider *Provider, authURL string) *oauth1.Consumer {
+	c := oauth1.NewConsumer(
 		provider.ClientKey,
 		provider.Secret,
-		oauth.ServiceProvider{
-			RequestTokenUrl:   requestURL,
-			AuthorizeTokenUrl: authURL,
-			AccessTokenUrl:    tokenURL,
-		})
+		oauth1.Endpoint{
+			RequestTokenURL: requestURL,
+			AuthorizeURL:    authURL,
+			AccessTokenURL:  tokenURL,
+		},
+		provider.Client)
 
-	c.Debug(provider.debug)
+	c.Debug = provider.debug
 	return c
 }
 
-// RefreshToken refresh token is not provided by twitter
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   oauth2AuthURL,
+			TokenURL:  oauth2TokenURL,
+			AuthStyle: oauth2.AuthStyleInHeader,
+		},
+		Scopes: []string{},
+	}
+
+	if provider.Secret == "" {
+		// public clients identify themselves with the client_id parameter
+		c.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+	}
+
+	if len(scopes) > 0 {
+		c.Scopes = append(c.Scopes, scopes...)
+	} else {
+		c.Scopes = []string{ScopeTweetRead, ScopeUsersRead}
+	}
+	return c
+}
+
+// RefreshToken get new access token based on the refresh token. Refresh tokens
+// are only provided with OAuth 2.0, when the offline.access scope is requested.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	return nil, errors.New("Refresh token is not provided by twitter")
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	if p.config == nil {
+		return nil, errors.New("Refresh token is not provided by twitter")
+	}
+
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
 
-// RefreshTokenAvailable refresh token is not provided by twitter
+// RefreshTokenAvailable refresh token is only provided by twitter with OAuth 2.0
 func (p *Provider) RefreshTokenAvailable() bool {
-	return false
+	return p.config != nil
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/pat"
@@ -72,6 +73,54 @@ func Test_FetchUser(t *testing.T) {
 	a.Equal("", user.Email)
 }
 
+func Test_BeginAuth_OAuth2(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := twitterOAuth2Provider()
+	session, err := provider.BeginAuth("state")
+	a.NoError(err)
+	s := session.(*OAuth2Session)
+	a.NotEmpty(s.CodeVerifier)
+	a.Contains(s.AuthURL, "twitter.com/i/oauth2/authorize")
+	a.Contains(s.AuthURL, "scope=tweet.read+users.read")
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+	a.Contains(s.AuthURL, "state=state")
+	a.True(provider.RefreshTokenAvailable())
+}
+
+func Test_FetchUser_OAuth2(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := twitterOAuth2Provider()
+	user, err := provider.FetchUser(&OAuth2Session{AccessToken: "TOKEN", RefreshToken: "REFRESH"})
+	a.NoError(err)
+
+	a.Equal("1234", user.UserID)
+	a.Equal("duffman", user.NickName)
+	a.Equal("http://example.com/image.jpg", user.AvatarURL)
+	a.Equal(true, user.RawData["verified"])
+	a.Equal("TOKEN", user.AccessToken)
+	a.Equal("REFRESH", user.RefreshToken)
+	a.Equal("", user.AccessTokenSecret)
+}
+
+func Test_SessionFromJSON_OAuth2(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := twitterOAuth2Provider()
+
+	s, err := provider.UnmarshalSession(`{"AuthURL":"http://com/auth_url","AccessToken":"1234567890","RefreshToken":"0987654321","CodeVerifier":"verifier"}`)
+	a.NoError(err)
+	session := s.(*OAuth2Session)
+	a.Equal(session.AuthURL, "http://com/auth_url")
+	a.Equal(session.AccessToken, "1234567890")
+	a.Equal(session.RefreshToken, "0987654321")
+	a.Equal(session.CodeVerifier, "verifier")
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -92,6 +141,10 @@ func twitterProvider() *Provider {
 	return New(os.Getenv("TWITTER_KEY"), os.Getenv("TWITTER_SECRET"), "/foo")
 }
 
+func twitterOAuth2Provider() *Provider {
+	return NewOAuth2(os.Getenv("TWITTER_CLIENT_ID"), os.Getenv("TWITTER_CLIENT_SECRET"), "/foo")
+}
+
 func twitterProviderAuthenticate() *Provider {
 	return NewAuthenticate(os.Getenv("TWITTER_KEY"), os.Getenv("TWITTER_SECRET"), "/foo")
 }
@@ -99,11 +152,15 @@ func twitterProviderAuthenticate() *Provider {
 func init() {
 	p := pat.New()
 	p.Get("/oauth/request_token", func(res http.ResponseWriter, req *http.Request) {
-		fmt.Fprint(res, "oauth_token=TOKEN&oauth_token_secret=SECRET")
+		fmt.Fprint(res, "oauth_token=TOKEN&oauth_token_secret=SECRET&oauth_callback_confirmed=true")
 	})
 	p.Get("/2/users/me", func(res http.ResponseWriter, req *http.Request) {
+		if auth := req.Header.Get("Authorization"); auth != "Bearer TOKEN" && !strings.HasPrefix(auth, "OAuth ") {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
 		data := map[string]interface{}{
-			"data": map[string]string{
+			"data": map[string]interface{}{
 				"name":              "Homer",
 				"username":          "duffman",
 				"description":       "Duff rules!!",
@@ -111,6 +168,7 @@ func init() {
 				"id":                "1234",
 				"location":          "Springfield",
 				"email":             "duffman@springfield.com",
+				"verified":          true,
 			},
 		}
 		json.NewEncoder(res).Encode(&data)
@@ -11,10 +11,17 @@ import (
 
 // Session stores data during the auth process with VK.
 type Session struct {
-	AuthURL     string
-	AccessToken string
-	ExpiresAt   time.Time
-	email       string
+	AuthURL      string
+	AccessToken  string
+	ExpiresAt    time.Time
+	RefreshToken string `json:",omitempty"`
+	IDToken      string `json:",omitempty"`
+	CodeVerifier string `json:",omitempty"`
+	// DeviceID identifies the device VK ID tokens were issued to, which
+	// refreshing them requires.
+	DeviceID string `json:",omitempty"`
+	UserID   string `json:",omitempty"`
+	email    string
 }
 
 // GetAuthURL returns the URL for the authentication end-point for the provider.
@@ -34,6 +41,10 @@ func (s *Session) Marshal() string {
 // Authorize the session with VK and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
+	if p.vkid {
+		return s.authorizeVKID(p, params)
+	}
+
 	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"))
 	if err != nil {
 		return "", err
@@ -13,6 +13,7 @@ import (
 	"strconv"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -23,7 +24,8 @@ var (
 	apiVersion   = "5.131"
 )
 
-// New creates a new VK provider and sets up important connection details.
+// New creates a new VK provider using the legacy oauth.vk.com dialog, and
+// sets up important connection details. New integrations should use NewVKID.
 // You should always call `vk.New` to get a new provider.  Never try to
 // create one manually.
 func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
@@ -46,6 +48,8 @@ type Provider struct {
 	config       *oauth2.Config
 	providerName string
 	version      string
+	vkid         bool
+	userInfoURL  string
 }
 
 // Name is the name used to retrieve this provider later.
@@ -58,12 +62,24 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
 
 // BeginAuth asks VK for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	if p.vkid {
+		return p.beginVKIDAuth(state)
+	}
+
 	url := p.config.AuthCodeURL(state)
 	session := &Session{
 		AuthURL: url,
@@ -76,16 +92,22 @@ func (p *Provider) BeginAuth(state string) (goth.Session, error) {
 func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	sess := session.(*Session)
 	user := goth.User{
-		AccessToken: sess.AccessToken,
-		Provider:    p.Name(),
-		ExpiresAt:   sess.ExpiresAt,
-		Email:       sess.email,
+		AccessToken:  sess.AccessToken,
+		RefreshToken: sess.RefreshToken,
+		IDToken:      sess.IDToken,
+		Provider:     p.Name(),
+		ExpiresAt:    sess.ExpiresAt,
+		Email:        sess.email,
 	}
 
 	if user.AccessToken == "" {
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
 	}
 
+	if p.vkid {
+		return p.fetchVKIDUser(sess, user)
+	}
+
 	fields := "photo_200,nickname"
 	requestURL := fmt.Sprintf("%s?fields=%s&access_token=%s&v=%s", endpointUser, fields, sess.AccessToken, apiVersion)
 	response, err := p.Client().Get(requestURL)
@@ -146,12 +168,17 @@ func userFromReader(reader io.Reader, user *goth.User) error {
 // Debug is a no-op for the vk package.
 func (p *Provider) Debug(debug bool) {}
 
-// RefreshToken refresh token is not provided by vk
+// RefreshToken refresh token is not provided by the legacy vk dialog. VK ID
+// refresh tokens are bound to the device they were issued to, so they are
+// refreshed with RefreshSession instead.
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	if p.vkid {
+		return nil, errors.New("vk: VK ID tokens need the device_id to be refreshed, use RefreshSession")
+	}
 	return nil, errors.New("Refresh token is not provided by vk")
 }
 
-// RefreshTokenAvailable refresh token is not provided by vk
+// RefreshTokenAvailable refresh token is not provided by vk, see RefreshToken
 func (p *Provider) RefreshTokenAvailable() bool {
 	return false
 }
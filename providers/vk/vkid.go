@@ -0,0 +1,246 @@
+package vk
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/markbates/goth"
+	"golang.org/x/oauth2"
+)
+
+var (
+	vkidAuthURL     = "https://id.vk.com/authorize"
+	vkidTokenURL    = "https://id.vk.com/oauth2/auth"
+	vkidUserInfoURL = "https://id.vk.com/oauth2/user_info"
+)
+
+// NewVKID creates a new VK provider using VK ID, the authorization code flow
+// with PKCE of id.vk.com which replaces the oauth.vk.com dialog. secret may be
+// empty, as VK ID identifies the client by the PKCE verifier.
+// See https://id.vk.com/about/business/go/docs/en/vkid/latest/vk-id/connection/api-integration/api-description
+func NewVKID(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "vk",
+		vkid:         true,
+		userInfoURL:  vkidUserInfoURL,
+	}
+	p.config = newConfig(p, scopes)
+	p.config.Endpoint = oauth2.Endpoint{
+		AuthURL:   vkidAuthURL,
+		TokenURL:  vkidTokenURL,
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+	return p
+}
+
+func (p *Provider) beginVKIDAuth(state string) (goth.Session, error) {
+	verifier := goth.GeneratePKCEVerifier()
+	return &Session{
+		AuthURL:      p.config.AuthCodeURL(state, goth.PKCEChallengeOption(verifier)),
+		CodeVerifier: verifier,
+	}, nil
+}
+
+// authorizeVKID exchanges the code for tokens. VK ID redirects back with the
+// device_id of the user, which it wants along with the code.
+func (s *Session) authorizeVKID(p *Provider, params goth.Params) (string, error) {
+	deviceID := params.Get("device_id")
+	if deviceID == "" {
+		return "", errors.New("vk: the callback has no device_id")
+	}
+
+	opts := []oauth2.AuthCodeOption{
+		goth.PKCEVerifierOption(s.CodeVerifier),
+		oauth2.SetAuthURLParam("device_id", deviceID),
+	}
+	if state := params.Get("state"); state != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("state", state))
+	}
+	token, err := p.config.Exchange(goth.ContextForClient(p.Client()), params.Get("code"), opts...)
+	if err != nil {
+		return "", err
+	}
+
+	if !token.Valid() {
+		return "", errors.New("Invalid token received from provider")
+	}
+
+	s.setVKIDToken(token)
+	s.CodeVerifier = ""
+	s.DeviceID = deviceID
+	return s.AccessToken, nil
+}
+
+func (s *Session) setVKIDToken(token *oauth2.Token) {
+	s.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		s.RefreshToken = token.RefreshToken
+	}
+	s.ExpiresAt = token.Expiry
+	if idToken, ok := token.Extra("id_token").(string); ok && idToken != "" {
+		s.IDToken = idToken
+	}
+	switch id := token.Extra("user_id").(type) {
+	case float64:
+		s.UserID = strconv.FormatInt(int64(id), 10)
+	case string:
+		s.UserID = id
+	}
+}
+
+// RefreshSession refreshes the VK ID tokens held by session, and stores the
+// new ones in it. VK ID rotates refresh tokens, so the session has to be
+// persisted again afterwards. goth.TokenRotated is called with the new token
+// as well.
+func (p *Provider) RefreshSession(ctx context.Context, session *Session) error {
+	if !p.vkid {
+		return errors.New("Refresh token is not provided by vk")
+	}
+	if session.RefreshToken == "" || session.DeviceID == "" {
+		return errors.New("vk: the session has no refresh token or device_id")
+	}
+
+	state, err := randomState()
+	if err != nil {
+		return err
+	}
+	token, err := p.vkidTokenRequest(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {session.RefreshToken},
+		"client_id":     {p.ClientKey},
+		"device_id":     {session.DeviceID},
+		"state":         {state},
+	})
+	if err != nil {
+		return err
+	}
+
+	old := session.RefreshToken
+	session.setVKIDToken(token)
+	if token.RefreshToken != "" && token.RefreshToken != old && goth.TokenRotated != nil {
+		goth.TokenRotated(ctx, p.Name(), old, token)
+	}
+	return nil
+}
+
+// vkidTokenRequest posts params to the token endpoint, which takes device_id,
+// a parameter the oauth2 package can't send along with refresh requests.
+func (p *Provider) vkidTokenRequest(ctx context.Context, params url.Values) (*oauth2.Token, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", p.config.Endpoint.TokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var body map[string]interface{}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%s responded with a %d trying to refresh the token", p.providerName, response.StatusCode)
+	}
+	if e, ok := body["error"].(string); ok {
+		return nil, fmt.Errorf("%s could not refresh the token: %s %v", p.providerName, e, body["error_description"])
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to refresh the token", p.providerName, response.StatusCode)
+	}
+
+	token := &oauth2.Token{}
+	token.AccessToken, _ = body["access_token"].(string)
+	token.RefreshToken, _ = body["refresh_token"].(string)
+	token.TokenType, _ = body["token_type"].(string)
+	if expiresIn, ok := body["expires_in"].(float64); ok {
+		token.Expiry = goth.ExpiryFromExpiresIn(int64(expiresIn))
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("Invalid token received from provider")
+	}
+	return token.WithExtra(body), nil
+}
+
+// fetchVKIDUser fetches the user from the VK ID user info endpoint.
+func (p *Provider) fetchVKIDUser(sess *Session, user goth.User) (goth.User, error) {
+	form := url.Values{
+		"client_id":    {p.ClientKey},
+		"access_token": {sess.AccessToken},
+	}
+	response, err := p.Client().PostForm(p.userInfoURL, form)
+	if err != nil {
+		return user, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return user, err
+	}
+
+	u := struct {
+		User struct {
+			UserID    string `json:"user_id"`
+			FirstName string `json:"first_name"`
+			LastName  string `json:"last_name"`
+			Avatar    string `json:"avatar"`
+			Email     string `json:"email"`
+			Phone     string `json:"phone"`
+		} `json:"user"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	if err := json.NewDecoder(bytes.NewReader(bits)).Decode(&u); err != nil {
+		return user, err
+	}
+	if u.Error != "" {
+		return user, fmt.Errorf("%s cannot get user information: %s %s", p.providerName, u.Error, u.ErrorDescription)
+	}
+
+	raw := struct {
+		User map[string]interface{} `json:"user"`
+	}{}
+	if err := json.NewDecoder(bytes.NewReader(bits)).Decode(&raw); err != nil {
+		return user, err
+	}
+
+	user.RawData = raw.User
+	user.UserID = u.User.UserID
+	if user.UserID == "" {
+		user.UserID = sess.UserID
+	}
+	user.FirstName = u.User.FirstName
+	user.LastName = u.User.LastName
+	user.Name = strings.TrimSpace(u.User.FirstName + " " + u.User.LastName)
+	user.AvatarURL = u.User.Avatar
+	if u.User.Email != "" {
+		user.Email = u.User.Email
+	}
+	return user, nil
+}
+
+// randomState returns a state for requests VK ID wants one along with.
+func randomState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
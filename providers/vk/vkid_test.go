@@ -0,0 +1,89 @@
+package vk_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/markbates/goth/providers/vk"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_VKID(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := vk.NewVKID("51234567", "", "http://localhost/callback")
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.NoError(req.ParseForm())
+		body := ""
+		switch req.URL.String() {
+		case "https://id.vk.com/oauth2/auth":
+			a.Equal("51234567", req.PostForm.Get("client_id"))
+			a.Equal("DEVICE", req.PostForm.Get("device_id"))
+			if req.PostForm.Get("grant_type") == "refresh_token" {
+				a.Equal("vk2.r.1", req.PostForm.Get("refresh_token"))
+				a.NotEmpty(req.PostForm.Get("state"))
+				body = `{"access_token":"vk2.a.2","refresh_token":"vk2.r.2","token_type":"Bearer","expires_in":3600,"user_id":1234567890}`
+			} else {
+				a.Equal("authorization_code", req.PostForm.Get("grant_type"))
+				a.Equal("CODE", req.PostForm.Get("code"))
+				a.Equal("test_state", req.PostForm.Get("state"))
+				a.NotEmpty(req.PostForm.Get("code_verifier"))
+				body = `{"access_token":"vk2.a.1","refresh_token":"vk2.r.1","id_token":"ID","token_type":"Bearer","expires_in":3600,"user_id":1234567890}`
+			}
+		case "https://id.vk.com/oauth2/user_info":
+			a.Equal("vk2.a.1", req.PostForm.Get("access_token"))
+			body = `{"user":{"user_id":"1234567890","first_name":"Ivan","last_name":"Ivanov","avatar":"https://example.com/ivan.jpg","email":"ivan@example.com","phone":"79991234567"}}`
+		default:
+			t.Errorf("unexpected request to %s", req.URL)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	})}
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*vk.Session)
+	a.Contains(s.AuthURL, "https://id.vk.com/authorize")
+	a.Contains(s.AuthURL, "code_challenge_method=S256")
+	a.NotEmpty(s.CodeVerifier)
+
+	_, err = s.Authorize(p, url.Values{"code": {"CODE"}, "state": {"test_state"}})
+	a.Error(err)
+
+	token, err := s.Authorize(p, url.Values{"code": {"CODE"}, "state": {"test_state"}, "device_id": {"DEVICE"}})
+	a.NoError(err)
+	a.Equal("vk2.a.1", token)
+	a.Equal("vk2.r.1", s.RefreshToken)
+	a.Equal("DEVICE", s.DeviceID)
+	a.Equal("1234567890", s.UserID)
+	a.Empty(s.CodeVerifier)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("1234567890", user.UserID)
+	a.Equal("Ivan Ivanov", user.Name)
+	a.Equal("ivan@example.com", user.Email)
+	a.Equal("https://example.com/ivan.jpg", user.AvatarURL)
+	a.Equal("ID", user.IDToken)
+	a.Equal("79991234567", user.RawData["phone"])
+
+	_, err = p.RefreshToken("vk2.r.1")
+	a.Error(err)
+	a.NoError(p.RefreshSession(context.Background(), s))
+	a.Equal("vk2.a.2", s.AccessToken)
+	a.Equal("vk2.r.2", s.RefreshToken)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
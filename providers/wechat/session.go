@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
 )
 
 // Session stores data during the auth process with Wechat.
@@ -17,6 +19,9 @@ type Session struct {
 	ExpiresAt    time.Time
 	Openid       string
 	Unionid      string
+	// SessionKey is the session_key of Mini Programs, used to decrypt the
+	// data they get from WeChat.
+	SessionKey string `json:",omitempty"`
 }
 
 var _ goth.Session = &Session{}
@@ -32,7 +37,18 @@ func (s Session) GetAuthURL() (string, error) {
 // Authorize the session with Wepay and return the access token to be stored for future use.
 func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string, error) {
 	p := provider.(*Provider)
-	token, openid, err := p.fetchToken(params.Get("code"))
+	if p.Mode == ModeMiniProgram {
+		sessionKey, openid, unionid, err := p.code2Session(params.Get("code"))
+		if err != nil {
+			return "", err
+		}
+		s.SessionKey = sessionKey
+		s.Openid = openid
+		s.Unionid = unionid
+		return "", nil
+	}
+
+	token, openid, unionid, err := p.fetchToken(params.Get("code"))
 
 	if err != nil {
 		return "", err
@@ -46,6 +62,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	s.RefreshToken = token.RefreshToken
 	s.ExpiresAt = token.Expiry
 	s.Openid = openid
+	s.Unionid = unionid
 	return token.AccessToken, err
 }
 
@@ -65,3 +82,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
 	return s, err
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -16,11 +17,37 @@ const (
 	AuthURL  = "https://open.weixin.qq.com/connect/qrconnect"
 	TokenURL = "https://api.weixin.qq.com/sns/oauth2/access_token"
 
+	// OfficialAccountAuthURL is the authorization end-point of Official
+	// Accounts, for pages opened in the WeChat browser.
+	OfficialAccountAuthURL = "https://open.weixin.qq.com/connect/oauth2/authorize"
+	// Code2SessionURL exchanges the codes of Mini Programs.
+	Code2SessionURL = "https://api.weixin.qq.com/sns/jscode2session"
+
 	ScopeSnsapiLogin = "snsapi_login"
+	// ScopeSnsapiUserinfo asks the user for their profile in the Official
+	// Account flow.
+	ScopeSnsapiUserinfo = "snsapi_userinfo"
+	// ScopeSnsapiBase silently authorizes the Official Account flow, which
+	// then only identifies the user by their openid.
+	ScopeSnsapiBase = "snsapi_base"
 
 	ProfileURL = "https://api.weixin.qq.com/sns/userinfo"
 )
 
+// Mode is the kind of client a Provider authenticates the users of.
+type Mode int
+
+const (
+	// ModeQRConnect is the QR code login of websites opened outside WeChat.
+	ModeQRConnect Mode = iota
+	// ModeOfficialAccount is the web authorization of Official Accounts, for
+	// pages opened in the WeChat browser.
+	ModeOfficialAccount
+	// ModeMiniProgram exchanges the codes Mini Programs get from wx.login()
+	// with code2Session.
+	ModeMiniProgram
+)
+
 type Provider struct {
 	providerName string
 	config       *oauth2.Config
@@ -29,10 +56,15 @@ type Provider struct {
 	ClientSecret string
 	RedirectURL  string
 	Lang         WechatLangType
-
-	AuthURL    string
-	TokenURL   string
-	ProfileURL string
+	Mode         Mode
+	// Scope is the scope asked for, ScopeSnsapiLogin for ModeQRConnect and
+	// ScopeSnsapiUserinfo or ScopeSnsapiBase for ModeOfficialAccount.
+	Scope string
+
+	AuthURL         string
+	TokenURL        string
+	ProfileURL      string
+	Code2SessionURL string
 }
 
 type WechatLangType string
@@ -52,6 +84,8 @@ func New(clientID, clientSecret, redirectURL string, lang WechatLangType) *Provi
 		ClientSecret: clientSecret,
 		RedirectURL:  redirectURL,
 		Lang:         lang,
+		Mode:         ModeQRConnect,
+		Scope:        ScopeSnsapiLogin,
 		AuthURL:      AuthURL,
 		TokenURL:     TokenURL,
 		ProfileURL:   ProfileURL,
@@ -60,6 +94,32 @@ func New(clientID, clientSecret, redirectURL string, lang WechatLangType) *Provi
 	return p
 }
 
+// NewOfficialAccount creates a new Wechat provider for the web authorization
+// of Official Accounts, used by pages opened in the WeChat browser. scope is
+// either ScopeSnsapiUserinfo or ScopeSnsapiBase.
+func NewOfficialAccount(appID, appSecret, redirectURL string, lang WechatLangType, scope string) *Provider {
+	p := New(appID, appSecret, redirectURL, lang)
+	p.Mode = ModeOfficialAccount
+	p.Scope = scope
+	p.AuthURL = OfficialAccountAuthURL
+	p.config = newConfig(p)
+	return p
+}
+
+// NewMiniProgram creates a new Wechat provider for Mini Programs. There is
+// nothing to redirect the user to: the auth URL is redirectURL carrying the
+// state, which the Mini Program sends back to it along with the code it got
+// from wx.login(). The code is exchanged with code2Session, and the user is
+// only identified by their openid and, if any, unionid.
+func NewMiniProgram(appID, appSecret, redirectURL string) *Provider {
+	p := New(appID, appSecret, redirectURL, WECHAT_LANG_CN)
+	p.Mode = ModeMiniProgram
+	p.Scope = ""
+	p.Code2SessionURL = Code2SessionURL
+	p.config = newConfig(p)
+	return p
+}
+
 // Name is the name used to retrieve this provider later.
 func (p *Provider) Name() string {
 	return p.providerName
@@ -70,6 +130,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.RedirectURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.httpClient)
 }
@@ -79,14 +147,30 @@ func (p *Provider) Debug(debug bool) {}
 
 // BeginAuth asks Wechat for an authentication end-point.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	if p.Mode == ModeMiniProgram {
+		u, err := url.Parse(p.RedirectURL)
+		if err != nil {
+			return nil, err
+		}
+		q := u.Query()
+		q.Set("state", state)
+		u.RawQuery = q.Encode()
+		return &Session{AuthURL: u.String()}, nil
+	}
+
 	params := url.Values{}
 	params.Add("appid", p.ClientID)
 	params.Add("response_type", "code")
 	params.Add("state", state)
-	params.Add("scope", ScopeSnsapiLogin)
+	params.Add("scope", p.Scope)
 	params.Add("redirect_uri", p.RedirectURL)
+	authURL := fmt.Sprintf("%s?%s", p.AuthURL, params.Encode())
+	if p.Mode == ModeOfficialAccount {
+		// WeChat refuses the authorization without this fragment
+		authURL += "#wechat_redirect"
+	}
 	session := &Session{
-		AuthURL: fmt.Sprintf("%s?%s", p.AuthURL, params.Encode()),
+		AuthURL: authURL,
 	}
 	return session, nil
 }
@@ -101,6 +185,15 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		ExpiresAt:    s.ExpiresAt,
 	}
 
+	if p.Mode == ModeMiniProgram || p.Scope == ScopeSnsapiBase {
+		// there is no profile to fetch, the user is only known by their ids
+		if s.Openid == "" {
+			return user, fmt.Errorf("%s cannot get user information without openid", p.providerName)
+		}
+		mapUser(wechatUser{Openid: s.Openid, Unionid: s.Unionid}, &user)
+		return user, nil
+	}
+
 	if user.AccessToken == "" {
 		// data is not yet retrieved since accessToken is still empty
 		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
@@ -146,24 +239,30 @@ func newConfig(provider *Provider) *oauth2.Config {
 		Scopes: []string{},
 	}
 
-	c.Scopes = append(c.Scopes, ScopeSnsapiLogin)
+	if provider.Scope != "" {
+		c.Scopes = append(c.Scopes, provider.Scope)
+	}
 
 	return c
 }
 
+// wechatUser is the profile of a user, of which the Mini Programs and the
+// snsapi_base scope only get the ids.
+type wechatUser struct {
+	Openid    string `json:"openid"`
+	Nickname  string `json:"nickname"`
+	Sex       int    `json:"sex"`
+	Province  string `json:"province"`
+	City      string `json:"city"`
+	Country   string `json:"country"`
+	AvatarURL string `json:"headimgurl"`
+	Unionid   string `json:"unionid"`
+	Code      int    `json:"errcode"`
+	Msg       string `json:"errmsg"`
+}
+
 func userFromReader(r io.Reader, user *goth.User) error {
-	u := struct {
-		Openid    string `json:"openid"`
-		Nickname  string `json:"nickname"`
-		Sex       int    `json:"sex"`
-		Province  string `json:"province"`
-		City      string `json:"city"`
-		Country   string `json:"country"`
-		AvatarURL string `json:"headimgurl"`
-		Unionid   string `json:"unionid"`
-		Code      int    `json:"errcode"`
-		Msg       string `json:"errmsg"`
-	}{}
+	u := wechatUser{}
 	err := json.NewDecoder(r).Decode(&u)
 	if err != nil {
 		return err
@@ -173,6 +272,11 @@ func userFromReader(r io.Reader, user *goth.User) error {
 		return fmt.Errorf("CODE: %d, MSG: %s", u.Code, u.Msg)
 	}
 
+	mapUser(u, user)
+	return nil
+}
+
+func mapUser(u wechatUser, user *goth.User) {
 	user.Email = fmt.Sprintf("%s@wechat.com", u.Openid)
 	user.Name = u.Nickname
 	user.UserID = u.Openid
@@ -182,7 +286,6 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	user.RawData = map[string]interface{}{
 		"Unionid": u.Unionid,
 	}
-	return nil
 }
 
 // RefreshTokenAvailable refresh token is provided by auth provider or not
@@ -196,7 +299,9 @@ func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
 	return nil, nil
 }
 
-func (p *Provider) fetchToken(code string) (*oauth2.Token, string, error) {
+// fetchToken exchanges code for a token, returning the openid and unionid
+// of the user along with it.
+func (p *Provider) fetchToken(code string) (*oauth2.Token, string, string, error) {
 
 	params := url.Values{}
 	params.Add("appid", p.ClientID)
@@ -207,31 +312,71 @@ func (p *Provider) fetchToken(code string) (*oauth2.Token, string, error) {
 	resp, err := p.Client().Get(url)
 
 	if err != nil {
-		return nil, "", err
+		return nil, "", "", err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("wechat /gettoken returns code: %d", resp.StatusCode)
+		return nil, "", "", fmt.Errorf("wechat /gettoken returns code: %d", resp.StatusCode)
 	}
 
 	obj := struct {
-		AccessToken string        `json:"access_token"`
-		ExpiresIn   time.Duration `json:"expires_in"`
-		Openid      string        `json:"openid"`
-		Code        int           `json:"errcode"`
-		Msg         string        `json:"errmsg"`
+		AccessToken  string        `json:"access_token"`
+		RefreshToken string        `json:"refresh_token"`
+		ExpiresIn    time.Duration `json:"expires_in"`
+		Openid       string        `json:"openid"`
+		Unionid      string        `json:"unionid"`
+		Code         int           `json:"errcode"`
+		Msg          string        `json:"errmsg"`
 	}{}
 	if err = json.NewDecoder(resp.Body).Decode(&obj); err != nil {
-		return nil, "", err
+		return nil, "", "", err
 	}
 	if obj.Code != 0 {
-		return nil, "", fmt.Errorf("CODE: %d, MSG: %s", obj.Code, obj.Msg)
+		return nil, "", "", fmt.Errorf("CODE: %d, MSG: %s", obj.Code, obj.Msg)
 	}
 
 	token := &oauth2.Token{
-		AccessToken: obj.AccessToken,
-		Expiry:      time.Now().Add(obj.ExpiresIn * time.Second),
+		AccessToken:  obj.AccessToken,
+		RefreshToken: obj.RefreshToken,
+		Expiry:       goth.Now().Add(obj.ExpiresIn * time.Second),
+	}
+
+	return token, obj.Openid, obj.Unionid, nil
+}
+
+// code2Session exchanges the code of a Mini Program for the session_key,
+// openid and unionid of the user. There is no access token.
+func (p *Provider) code2Session(code string) (string, string, string, error) {
+	params := url.Values{}
+	params.Add("appid", p.ClientID)
+	params.Add("secret", p.ClientSecret)
+	params.Add("js_code", code)
+	params.Add("grant_type", "authorization_code")
+	resp, err := p.Client().Get(fmt.Sprintf("%s?%s", p.Code2SessionURL, params.Encode()))
+	if err != nil {
+		return "", "", "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", "", fmt.Errorf("wechat /jscode2session returns code: %d", resp.StatusCode)
+	}
+
+	obj := struct {
+		SessionKey string `json:"session_key"`
+		Openid     string `json:"openid"`
+		Unionid    string `json:"unionid"`
+		Code       int    `json:"errcode"`
+		Msg        string `json:"errmsg"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(&obj); err != nil {
+		return "", "", "", err
+	}
+	if obj.Code != 0 {
+		return "", "", "", fmt.Errorf("CODE: %d, MSG: %s", obj.Code, obj.Msg)
+	}
+	if obj.Openid == "" {
+		return "", "", "", fmt.Errorf("%s: code2Session returned no openid", p.providerName)
 	}
 
-	return token, obj.Openid, nil
+	return obj.SessionKey, obj.Openid, obj.Unionid, nil
 }
@@ -1,8 +1,13 @@
 package wechat_test
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/providers/wechat"
@@ -35,6 +40,63 @@ func Test_BeginAuth(t *testing.T) {
 	a.Contains(s.AuthURL, "open.weixin.qq.com/connect/qrconnect")
 }
 
+func Test_BeginAuthOfficialAccount(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := wechat.NewOfficialAccount(os.Getenv("WECHAT_KEY"), os.Getenv("WECHAT_SECRET"), "/foo", wechat.WECHAT_LANG_CN, wechat.ScopeSnsapiUserinfo)
+	session, err := p.BeginAuth("test_state")
+	s := session.(*wechat.Session)
+	a.NoError(err)
+	a.Contains(s.AuthURL, "open.weixin.qq.com/connect/oauth2/authorize")
+	a.Contains(s.AuthURL, "scope=snsapi_userinfo")
+	a.True(strings.HasSuffix(s.AuthURL, "#wechat_redirect"))
+}
+
+func Test_FetchUserSnsapiBase(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := wechat.NewOfficialAccount(os.Getenv("WECHAT_KEY"), os.Getenv("WECHAT_SECRET"), "/foo", wechat.WECHAT_LANG_CN, wechat.ScopeSnsapiBase)
+
+	user, err := p.FetchUser(&wechat.Session{AccessToken: "token", Openid: "openid"})
+	a.NoError(err)
+	a.Equal("openid", user.UserID)
+	a.Equal("token", user.AccessToken)
+}
+
+func Test_MiniProgram(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("/sns/jscode2session", r.URL.Path)
+		a.Equal("js-code", r.URL.Query().Get("js_code"))
+		a.Equal("authorization_code", r.URL.Query().Get("grant_type"))
+		w.Write([]byte(`{"openid":"openid","session_key":"session-key","unionid":"unionid"}`))
+	}))
+	defer ts.Close()
+
+	p := wechat.NewMiniProgram("app-id", "app-secret", "https://example.com/auth/wechat/callback")
+	p.Code2SessionURL = ts.URL + "/sns/jscode2session"
+
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*wechat.Session)
+	a.Equal("https://example.com/auth/wechat/callback?state=test_state", s.AuthURL)
+
+	_, err = p.FetchUser(s)
+	a.Error(err)
+
+	_, err = s.Authorize(p, url.Values{"code": {"js-code"}})
+	a.NoError(err)
+	a.Equal("session-key", s.SessionKey)
+
+	user, err := p.FetchUser(s)
+	a.NoError(err)
+	a.Equal("openid", user.UserID)
+	a.Equal("unionid", user.RawData["Unionid"])
+	a.Empty(user.AccessToken)
+}
+
 func Test_SessionFromJSON(t *testing.T) {
 	t.Parallel()
 	a := assert.New(t)
@@ -51,3 +113,24 @@ func Test_SessionFromJSON(t *testing.T) {
 func provider() *wechat.Provider {
 	return wechat.New(os.Getenv("WECHAT_KEY"), os.Getenv("WECHAT_SECRET"), "/foo", wechat.WECHAT_LANG_CN)
 }
+
+func Test_AuthorizeExpiryUsesClock(t *testing.T) {
+	a := assert.New(t)
+
+	// in the future, as oauth2 checks the validity of the token with time.Now
+	now := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	goth.SetClock(func() time.Time { return now })
+	defer goth.SetClock(nil)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"token","expires_in":7200,"openid":"openid"}`))
+	}))
+	defer ts.Close()
+
+	p := provider()
+	p.TokenURL = ts.URL
+	s := &wechat.Session{}
+	_, err := s.Authorize(p, url.Values{"code": {"code"}})
+	a.NoError(err)
+	a.Equal(now.Add(2*time.Hour), s.ExpiresAt)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/markbates/goth"
 )
@@ -13,6 +14,7 @@ type Session struct {
 	AuthURL     string
 	AccessToken string
 	UserID      string
+	ExpiresAt   time.Time
 }
 
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the WeCom provider.
@@ -31,6 +33,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 		return "", err
 	}
 	s.AccessToken = token.AccessToken
+	s.ExpiresAt = token.Expiry
 
 	userID, err := p.fetchUserID(s, params.Get("code"))
 	if err != nil {
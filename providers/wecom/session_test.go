@@ -36,5 +36,5 @@ func Test_Marshal(t *testing.T) {
 	s := &wecom.Session{}
 
 	data := s.Marshal()
-	a.Equal(data, `{"AuthURL":"","AccessToken":"","UserID":""}`)
+	a.Equal(data, `{"AuthURL":"","AccessToken":"","UserID":"","ExpiresAt":"0001-01-01T00:00:00Z"}`)
 }
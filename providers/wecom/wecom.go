@@ -9,7 +9,6 @@ import (
 	"io"
 	"net/http"
 	"net/url"
-	"time"
 
 	"github.com/markbates/goth"
 	"golang.org/x/oauth2"
@@ -59,6 +58,13 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -85,6 +91,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	user := goth.User{
 		AccessToken: sess.AccessToken,
 		Provider:    p.Name(),
+		ExpiresAt:   sess.ExpiresAt,
 	}
 
 	if user.AccessToken == "" {
@@ -138,10 +145,10 @@ func (p *Provider) fetchToken() (*oauth2.Token, error) {
 	}
 
 	obj := struct {
-		AccessToken string        `json:"access_token"`
-		ExpiresIn   time.Duration `json:"expires_in"`
-		Code        int           `json:"errcode"`
-		Msg         string        `json:"errmsg"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Code        int    `json:"errcode"`
+		Msg         string `json:"errmsg"`
 	}{}
 	if err = json.NewDecoder(resp.Body).Decode(&obj); err != nil {
 		return nil, err
@@ -152,7 +159,7 @@ func (p *Provider) fetchToken() (*oauth2.Token, error) {
 
 	p.token = &oauth2.Token{
 		AccessToken: obj.AccessToken,
-		Expiry:      time.Now().Add(obj.ExpiresIn * time.Second),
+		Expiry:      goth.ExpiryFromExpiresIn(obj.ExpiresIn),
 	}
 
 	return p.token, nil
@@ -0,0 +1,229 @@
+// Package workos implements the OAuth2 protocol for authenticating users through WorkOS SSO.
+// This package can be used as a reference implementation of an OAuth2 provider for Goth.
+package workos
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
+)
+
+// These vars define the URLs of the WorkOS SSO API. They are variables so
+// they can be pointed at a test server.
+var (
+	AuthURL    = "https://api.workos.com/sso/authorize"
+	TokenURL   = "https://api.workos.com/sso/token"
+	ProfileURL = "https://api.workos.com/sso/profile"
+)
+
+// Provider is the implementation of `goth.Provider` for accessing WorkOS.
+// WorkOS needs to know which connection to authenticate the user with, so one
+// of SetConnection, SetOrganization or SetProvider must be called before
+// starting the authentication.
+type Provider struct {
+	ClientKey    string
+	Secret       string
+	CallbackURL  string
+	HTTPClient   *http.Client
+	config       *oauth2.Config
+	providerName string
+	connection   string
+	organization string
+	provider     string
+	domainHint   string
+	loginHint    string
+}
+
+// New creates a new WorkOS provider and sets up important connection details.
+// clientKey is the client ID of the WorkOS environment and secret its API key.
+// You should always call `workos.New` to get a new provider. Never try to
+// create one manually.
+func New(clientKey, secret, callbackURL string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "workos",
+	}
+	p.config = newConfig(p)
+	return p
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
+// Client returns an HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return goth.HTTPClientWithFallBack(p.HTTPClient)
+}
+
+// Debug is a no-op for the workos package.
+func (p *Provider) Debug(debug bool) {}
+
+// SetConnection selects the SSO connection, by its ID, users authenticate with.
+func (p *Provider) SetConnection(connectionID string) {
+	p.connection = connectionID
+}
+
+// SetOrganization selects the organization, by its ID, users authenticate with.
+// WorkOS uses the organization's active SSO connection.
+func (p *Provider) SetOrganization(organizationID string) {
+	p.organization = organizationID
+}
+
+// SetProvider selects an OAuth provider users authenticate with, such as
+// "GoogleOAuth" or "MicrosoftOAuth".
+func (p *Provider) SetProvider(provider string) {
+	p.provider = provider
+}
+
+// SetDomainHint pre-fills the domain field of the WorkOS selection page.
+func (p *Provider) SetDomainHint(domain string) {
+	p.domainHint = domain
+}
+
+// SetLoginHint pre-fills the email of the user on the identity provider's
+// sign in page, if it supports it.
+func (p *Provider) SetLoginHint(email string) {
+	p.loginHint = email
+}
+
+// BeginAuth asks WorkOS for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	if p.connection == "" && p.organization == "" && p.provider == "" {
+		return nil, errors.New("workos requires a connection, an organization or a provider to be set")
+	}
+
+	var opts []oauth2.AuthCodeOption
+	params := map[string]string{
+		"connection":   p.connection,
+		"organization": p.organization,
+		"provider":     p.provider,
+		"domain_hint":  p.domainHint,
+		"login_hint":   p.loginHint,
+	}
+	for key, value := range params {
+		if value != "" {
+			opts = append(opts, oauth2.SetAuthURLParam(key, value))
+		}
+	}
+
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state, opts...),
+	}, nil
+}
+
+// FetchUser will go to WorkOS and access the profile of the user.
+// The full profile, including organization_id, connection_id and the raw
+// attributes sent by the identity provider, is available in RawData.
+// See https://workos.com/docs/reference/sso/profile
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken: sess.AccessToken,
+		Provider:    p.Name(),
+		ExpiresAt:   sess.ExpiresAt,
+	}
+
+	if user.AccessToken == "" {
+		// data is not yet retrieved since accessToken is still empty
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	req, err := http.NewRequest("GET", ProfileURL, nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sess.AccessToken)
+
+	response, err := p.Client().Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, response.StatusCode)
+	}
+
+	bits, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return user, err
+	}
+
+	err = json.NewDecoder(bytes.NewReader(bits)).Decode(&user.RawData)
+	if err != nil {
+		return user, err
+	}
+
+	err = userFromReader(bytes.NewReader(bits), &user)
+	return user, err
+}
+
+func userFromReader(reader io.Reader, user *goth.User) error {
+	u := struct {
+		ID        string `json:"id"`
+		Email     string `json:"email"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+	}{}
+
+	err := json.NewDecoder(reader).Decode(&u)
+	if err != nil {
+		return err
+	}
+
+	user.UserID = u.ID
+	user.Email = u.Email
+	user.FirstName = u.FirstName
+	user.LastName = u.LastName
+	user.Name = strings.TrimSpace(u.FirstName + " " + u.LastName)
+	return nil
+}
+
+func newConfig(provider *Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   AuthURL,
+			TokenURL:  TokenURL,
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+}
+
+// RefreshTokenAvailable refresh token is not provided by workos
+func (p *Provider) RefreshTokenAvailable() bool {
+	return false
+}
+
+// RefreshToken refresh token is not provided by workos
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, errors.New("Refresh token is not provided by workos")
+}
@@ -0,0 +1,86 @@
+package workos_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/workos"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	a.Equal(p.ClientKey, os.Getenv("WORKOS_KEY"))
+	a.Equal(p.Secret, os.Getenv("WORKOS_SECRET"))
+	a.Equal(p.CallbackURL, "/foo")
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	a.Implements((*goth.Provider)(nil), provider())
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+	p := provider()
+
+	_, err := p.BeginAuth("test_state")
+	a.Error(err)
+
+	p.SetOrganization("org_01EHZNVPK3SFK441A1RGBFSHRT")
+	p.SetDomainHint("example.com")
+	session, err := p.BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*workos.Session)
+	a.Contains(s.AuthURL, "api.workos.com/sso/authorize")
+	a.Contains(s.AuthURL, "organization=org_01EHZNVPK3SFK441A1RGBFSHRT")
+	a.Contains(s.AuthURL, "domain_hint=example.com")
+	a.Contains(s.AuthURL, "state=test_state")
+	a.NotContains(s.AuthURL, "connection=")
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := provider()
+	session, err := p.UnmarshalSession(`{"AuthURL":"https://api.workos.com/sso/authorize","AccessToken":"1234567890"}`)
+	a.NoError(err)
+
+	s := session.(*workos.Session)
+	a.Equal(s.AuthURL, "https://api.workos.com/sso/authorize")
+	a.Equal(s.AccessToken, "1234567890")
+}
+
+func Test_FetchUser(t *testing.T) {
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.Equal("Bearer 1234567890", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"id":"prof_01DMC79VCBZ0NY2099737PSVF1","connection_id":"conn_01E4ZCR3C56J083X43JQXF3JK5","connection_type":"okta","organization_id":"org_01EHWNCE74X7JSDV0X3SZ3KJNY","email":"todd@example.com","first_name":"Todd","last_name":"Rundgren","idp_id":"00u1a0ufowBJlzPlk357","raw_attributes":{}}`))
+	}))
+	defer ts.Close()
+
+	profileURL := workos.ProfileURL
+	workos.ProfileURL = ts.URL
+	defer func() { workos.ProfileURL = profileURL }()
+
+	user, err := provider().FetchUser(&workos.Session{AccessToken: "1234567890"})
+	a.NoError(err)
+	a.Equal("prof_01DMC79VCBZ0NY2099737PSVF1", user.UserID)
+	a.Equal("todd@example.com", user.Email)
+	a.Equal("Todd Rundgren", user.Name)
+	a.Equal("org_01EHWNCE74X7JSDV0X3SZ3KJNY", user.RawData["organization_id"])
+}
+
+func provider() *workos.Provider {
+	return workos.New(os.Getenv("WORKOS_KEY"), os.Getenv("WORKOS_SECRET"), "/foo")
+}
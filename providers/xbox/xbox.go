@@ -0,0 +1,332 @@
+// Package xbox implements the OAuth2 protocol for authenticating users through
+// their Xbox Live account.
+//
+// The user signs in with their Microsoft account, and the Microsoft access
+// token is then exchanged for an Xbox Live user token and an XSTS token,
+// which carries the gamertag and the XUID of the user. The application has to
+// be registered in the Azure portal for personal Microsoft accounts.
+// See https://learn.microsoft.com/en-us/gaming/gdk/_content/gc/live/features/s2s-auth-calls/service-authentication/live-service-authentication-nav
+package xbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
+	"golang.org/x/oauth2"
+)
+
+const (
+	authURL          string = "https://login.microsoftonline.com/consumers/oauth2/v2.0/authorize"
+	tokenURL         string = "https://login.microsoftonline.com/consumers/oauth2/v2.0/token"
+	endpointUserAuth string = "https://user.auth.xboxlive.com/user/authenticate"
+	endpointXSTS     string = "https://xsts.auth.xboxlive.com/xsts/authorize"
+	endpointProfile  string = "https://profile.xboxlive.com/users/xuid(%s)/profile/settings"
+)
+
+// ScopeXboxLiveSignIn is the scope giving access to Xbox Live.
+const ScopeXboxLiveSignIn = "XboxLive.signin"
+
+// RelyingPartyXboxLive is the relying party of the XSTS tokens used with the
+// Xbox Live APIs, e.g. to fetch the profile of the user.
+const RelyingPartyXboxLive = "http://xboxlive.com"
+
+var defaultScopes = []string{ScopeXboxLiveSignIn, "offline_access"}
+
+// Errors returned when Xbox Live refuses to issue an XSTS token for the user.
+var (
+	ErrNoXboxAccount      = errors.New("xbox: the Microsoft account has no Xbox Live profile")
+	ErrCountryUnavailable = errors.New("xbox: Xbox Live is not available in the country of the account")
+	ErrChildAccount       = errors.New("xbox: the account of a child has to be added to a family by an adult")
+)
+
+// xstsErrors maps the XErr codes of the XSTS endpoint to errors.
+var xstsErrors = map[int64]error{
+	2148916233: ErrNoXboxAccount,
+	2148916235: ErrCountryUnavailable,
+	2148916236: ErrChildAccount,
+	2148916237: ErrChildAccount,
+	2148916238: ErrChildAccount,
+}
+
+// New creates a new Xbox provider, and sets up important connection details.
+// You should always call `xbox.New` to get a new Provider. Never try to create
+// one manually.
+func New(clientKey, secret, callbackURL string, scopes ...string) *Provider {
+	p := &Provider{
+		ClientKey:    clientKey,
+		Secret:       secret,
+		CallbackURL:  callbackURL,
+		providerName: "xbox",
+	}
+	p.config = newConfig(p, scopes)
+	return p
+}
+
+// Provider is the implementation of `goth.Provider` for accessing Xbox Live.
+type Provider struct {
+	ClientKey   string
+	Secret      string
+	CallbackURL string
+	HTTPClient  *http.Client
+	goth.AdditionalParams
+	config       *oauth2.Config
+	providerName string
+}
+
+// Name is the name used to retrieve this provider later.
+func (p *Provider) Name() string {
+	return p.providerName
+}
+
+// SetName is to update the name of the provider (needed in case of multiple providers of 1 type)
+func (p *Provider) SetName(name string) {
+	p.providerName = name
+}
+
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
+// Client is HTTP client to be used in all fetch operations.
+func (p *Provider) Client() *http.Client {
+	return p.TokenClient(goth.HTTPClientWithFallBack(p.HTTPClient))
+}
+
+// Debug is a no-op for the xbox package.
+func (p *Provider) Debug(debug bool) {}
+
+// BeginAuth asks Microsoft for an authentication end-point.
+func (p *Provider) BeginAuth(state string) (goth.Session, error) {
+	return &Session{
+		AuthURL: p.config.AuthCodeURL(state, p.AuthCodeOptions()...),
+	}, nil
+}
+
+// FetchUser exchanges the access token of the session for an XSTS token and
+// fetches the Xbox Live profile of the user. The XUID of the user is their
+// UserID and their gamertag is their NickName.
+func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
+	sess := session.(*Session)
+	user := goth.User{
+		AccessToken:  sess.AccessToken,
+		RefreshToken: sess.RefreshToken,
+		ExpiresAt:    sess.ExpiresAt,
+		Provider:     p.Name(),
+	}
+
+	if user.AccessToken == "" {
+		return user, fmt.Errorf("%s cannot get user information without accessToken", p.providerName)
+	}
+
+	ctx := context.Background()
+	xsts, err := p.XSTSToken(ctx, user.AccessToken, RelyingPartyXboxLive)
+	if err != nil {
+		return user, err
+	}
+
+	settings, err := p.profileSettings(ctx, xsts)
+	if err != nil {
+		return user, err
+	}
+
+	user.UserID = xsts.Claims["xid"]
+	user.NickName = xsts.Claims["gtg"]
+	if gamertag := settings["Gamertag"]; gamertag != "" {
+		user.NickName = gamertag
+	}
+	user.Name = user.NickName
+	user.AvatarURL = settings["GameDisplayPicRaw"]
+
+	user.RawData = map[string]interface{}{}
+	for k, v := range xsts.Claims {
+		user.RawData[k] = v
+	}
+	for k, v := range settings {
+		user.RawData[k] = v
+	}
+	return user, nil
+}
+
+// XSTSToken is a token of the Xbox Secure Token Service, with which the Xbox
+// Live APIs of its relying party are called on behalf of the user.
+type XSTSToken struct {
+	Token    string
+	NotAfter time.Time
+	// Claims are the display claims of the user, e.g. "uhs" (the user hash),
+	// "xid" (the XUID), "gtg" (the gamertag) and "agg" (the age group).
+	Claims map[string]string
+}
+
+// AuthorizationHeader returns the value of the Authorization header of the
+// requests made to Xbox Live with the token.
+func (t *XSTSToken) AuthorizationHeader() string {
+	return "XBL3.0 x=" + t.Claims["uhs"] + ";" + t.Token
+}
+
+type xboxToken struct {
+	Token         string
+	NotAfter      time.Time
+	DisplayClaims struct {
+		XUI []map[string]string `json:"xui"`
+	}
+}
+
+// XSTSToken exchanges the Microsoft access token of a user for a user token of
+// Xbox Live, and then for an XSTS token for relyingParty, e.g.
+// RelyingPartyXboxLive.
+func (p *Provider) XSTSToken(ctx context.Context, accessToken, relyingParty string) (*XSTSToken, error) {
+	var userToken xboxToken
+	err := p.post(ctx, endpointUserAuth, map[string]interface{}{
+		"Properties": map[string]interface{}{
+			"AuthMethod": "RPS",
+			"SiteName":   "user.auth.xboxlive.com",
+			"RpsTicket":  "d=" + accessToken,
+		},
+		"RelyingParty": "http://auth.xboxlive.com",
+		"TokenType":    "JWT",
+	}, &userToken, "get a user token")
+	if err != nil {
+		return nil, err
+	}
+
+	var xsts xboxToken
+	err = p.post(ctx, endpointXSTS, map[string]interface{}{
+		"Properties": map[string]interface{}{
+			"SandboxId":  "RETAIL",
+			"UserTokens": []string{userToken.Token},
+		},
+		"RelyingParty": relyingParty,
+		"TokenType":    "JWT",
+	}, &xsts, "get an XSTS token")
+	if err != nil {
+		return nil, err
+	}
+	if len(xsts.DisplayClaims.XUI) == 0 {
+		return nil, fmt.Errorf("%s returned an XSTS token without display claims", p.providerName)
+	}
+
+	return &XSTSToken{Token: xsts.Token, NotAfter: xsts.NotAfter, Claims: xsts.DisplayClaims.XUI[0]}, nil
+}
+
+func (p *Provider) post(ctx context.Context, endpoint string, body interface{}, v interface{}, action string) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("x-xbl-contract-version", "1")
+
+	res, err := p.Client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		var xerr struct{ XErr int64 }
+		if json.NewDecoder(io.LimitReader(res.Body, 1<<16)).Decode(&xerr) == nil {
+			if err, ok := xstsErrors[xerr.XErr]; ok {
+				return err
+			}
+		}
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with a %d trying to %s", p.providerName, res.StatusCode, action)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// profileSettings returns the gamertag and the gamerpic of the user.
+func (p *Provider) profileSettings(ctx context.Context, xsts *XSTSToken) (map[string]string, error) {
+	u := fmt.Sprintf(endpointProfile, url.PathEscape(xsts.Claims["xid"])) + "?settings=Gamertag,GameDisplayPicRaw"
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", xsts.AuthorizationHeader())
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("x-xbl-contract-version", "2")
+
+	res, err := p.Client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with a %d trying to fetch user information", p.providerName, res.StatusCode)
+	}
+
+	var profile struct {
+		ProfileUsers []struct {
+			Settings []struct {
+				ID    string `json:"id"`
+				Value string `json:"value"`
+			} `json:"settings"`
+		} `json:"profileUsers"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&profile); err != nil {
+		return nil, err
+	}
+
+	settings := map[string]string{}
+	for _, u := range profile.ProfileUsers {
+		for _, s := range u.Settings {
+			settings[s.ID] = s.Value
+		}
+	}
+	return settings, nil
+}
+
+// RefreshTokenAvailable refresh token is provided by auth provider or not
+func (p *Provider) RefreshTokenAvailable() bool {
+	return true
+}
+
+// RefreshToken get new access token based on the refresh token
+func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
+}
+
+func newConfig(provider *Provider, scopes []string) *oauth2.Config {
+	c := &oauth2.Config{
+		ClientID:     provider.ClientKey,
+		ClientSecret: provider.Secret,
+		RedirectURL:  provider.CallbackURL,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  authURL,
+			TokenURL: tokenURL,
+		},
+		Scopes: []string{},
+	}
+
+	c.Scopes = append(c.Scopes, scopes...)
+	if len(scopes) == 0 {
+		c.Scopes = append(c.Scopes, defaultScopes...)
+	}
+
+	return c
+}
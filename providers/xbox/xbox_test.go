@@ -0,0 +1,130 @@
+package xbox_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/xbox"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_New(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := xboxProvider(nil)
+	a.Equal(provider.ClientKey, "xbox_key")
+	a.Equal(provider.Secret, "xbox_secret")
+	a.Equal(provider.CallbackURL, "/foo")
+	a.Equal("xbox", provider.Name())
+}
+
+func Test_Implements_Provider(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Implements((*goth.Provider)(nil), xboxProvider(nil))
+}
+
+func Test_BeginAuth(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	session, err := xboxProvider(nil).BeginAuth("test_state")
+	a.NoError(err)
+	s := session.(*xbox.Session)
+	a.Contains(s.AuthURL, "login.microsoftonline.com/consumers/oauth2/v2.0/authorize")
+	a.Contains(s.AuthURL, "scope=XboxLive.signin+offline_access")
+	a.Contains(s.AuthURL, "state=test_state")
+}
+
+func Test_SessionFromJSON(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	session, err := xboxProvider(nil).UnmarshalSession(`{"AuthURL":"https://login.microsoftonline.com/consumers/oauth2/v2.0/authorize","AccessToken":"1234567890"}`)
+	a.NoError(err)
+	s := session.(*xbox.Session)
+	a.Equal("https://login.microsoftonline.com/consumers/oauth2/v2.0/authorize", s.AuthURL)
+	a.Equal("1234567890", s.AccessToken)
+}
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := xboxProvider(func(req *http.Request) (*http.Response, error) {
+		switch req.URL.Host {
+		case "user.auth.xboxlive.com":
+			var body map[string]interface{}
+			a.NoError(json.NewDecoder(req.Body).Decode(&body))
+			a.Equal("d=access_token", body["Properties"].(map[string]interface{})["RpsTicket"])
+			a.Equal("http://auth.xboxlive.com", body["RelyingParty"])
+			return response(http.StatusOK, `{"Token":"user_token","DisplayClaims":{"xui":[{"uhs":"hash"}]}}`), nil
+		case "xsts.auth.xboxlive.com":
+			var body map[string]interface{}
+			a.NoError(json.NewDecoder(req.Body).Decode(&body))
+			a.Equal([]interface{}{"user_token"}, body["Properties"].(map[string]interface{})["UserTokens"])
+			a.Equal("http://xboxlive.com", body["RelyingParty"])
+			return response(http.StatusOK, `{"Token":"xsts_token","NotAfter":"2030-01-01T00:00:00Z","DisplayClaims":{"xui":[{"uhs":"hash","xid":"2535400000000000","gtg":"Major Nelson","agg":"Adult"}]}}`), nil
+		case "profile.xboxlive.com":
+			a.Equal("/users/xuid(2535400000000000)/profile/settings", req.URL.Path)
+			a.Equal("XBL3.0 x=hash;xsts_token", req.Header.Get("Authorization"))
+			a.Equal("2", req.Header.Get("x-xbl-contract-version"))
+			return response(http.StatusOK, `{"profileUsers":[{"id":"2535400000000000","settings":[{"id":"Gamertag","value":"Major Nelson"},{"id":"GameDisplayPicRaw","value":"https://images.example.com/pic.png"}]}]}`), nil
+		}
+		t.Errorf("unexpected request to %s", req.URL)
+		return response(http.StatusNotFound, ""), nil
+	})
+
+	user, err := provider.FetchUser(&xbox.Session{AccessToken: "access_token", RefreshToken: "refresh_token"})
+	a.NoError(err)
+	a.Equal("xbox", user.Provider)
+	a.Equal("2535400000000000", user.UserID)
+	a.Equal("Major Nelson", user.NickName)
+	a.Equal("Major Nelson", user.Name)
+	a.Equal("https://images.example.com/pic.png", user.AvatarURL)
+	a.Equal("access_token", user.AccessToken)
+	a.Equal("refresh_token", user.RefreshToken)
+	a.Equal("Adult", user.RawData["agg"])
+}
+
+func Test_FetchUser_NoXboxAccount(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	provider := xboxProvider(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "user.auth.xboxlive.com" {
+			return response(http.StatusOK, `{"Token":"user_token"}`), nil
+		}
+		return response(http.StatusUnauthorized, `{"Identity":"0","XErr":2148916233,"Message":"","Redirect":"https://start.ui.xboxlive.com/CreateAccount"}`), nil
+	})
+
+	_, err := provider.FetchUser(&xbox.Session{AccessToken: "access_token"})
+	a.ErrorIs(err, xbox.ErrNoXboxAccount)
+
+	_, err = provider.FetchUser(&xbox.Session{})
+	a.Error(err)
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func response(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func xboxProvider(rt roundTripper) *xbox.Provider {
+	p := xbox.New("xbox_key", "xbox_secret", "/foo")
+	if rt != nil {
+		p.HTTPClient = &http.Client{Transport: rt}
+	}
+	return p
+}
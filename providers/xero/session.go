@@ -1,12 +1,14 @@
 package xero
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"strings"
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/internal/oauth1"
 	"github.com/mrjones/oauth"
 )
 
@@ -18,6 +20,24 @@ type Session struct {
 	AccessTokenExpires time.Time
 }
 
+var _ goth.OAuth1Session = &Session{}
+
+// GetRequestToken returns the request token and its secret.
+func (s Session) GetRequestToken() (string, string) {
+	if s.RequestToken == nil {
+		return "", ""
+	}
+	return s.RequestToken.Token, s.RequestToken.Secret
+}
+
+// GetAccessToken returns the access token and its secret.
+func (s Session) GetAccessToken() (string, string) {
+	if s.AccessToken == nil {
+		return "", ""
+	}
+	return s.AccessToken.Token, s.AccessToken.Secret
+}
+
 // GetAuthURL will return the URL set by calling the `BeginAuth` function on the Xero provider.
 func (s Session) GetAuthURL() (string, error) {
 	if s.AuthURL == "" {
@@ -32,12 +52,16 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 	if p.Method == "private" {
 		return p.ClientKey, nil
 	}
-	accessToken, err := p.consumer.AuthorizeToken(s.RequestToken, params.Get("oauth_verifier"))
+	verifier, err := oauth1.Verifier(params, s.RequestToken)
+	if err != nil {
+		return "", err
+	}
+	accessToken, err := p.consumer.AccessToken(context.Background(), s.RequestToken, verifier)
 	if err != nil {
 		return "", err
 	}
 
-	s.AccessTokenExpires = time.Now().UTC().Add(30 * time.Minute)
+	s.AccessTokenExpires = goth.Now().UTC().Add(30 * time.Minute)
 	s.AccessToken = accessToken
 
 	return accessToken.Token, err
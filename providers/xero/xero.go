@@ -2,6 +2,7 @@
 package xero
 
 import (
+	"context"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
@@ -16,7 +17,7 @@ import (
 	"golang.org/x/oauth2"
 
 	"github.com/markbates/goth"
-	"github.com/mrjones/oauth"
+	"github.com/markbates/goth/providers/internal/oauth1"
 )
 
 // Organisation is the expected response from the Organisation endpoint - this is not a complete schema
@@ -89,7 +90,7 @@ type Provider struct {
 	HTTPClient   *http.Client
 	Method       string
 	debug        bool
-	consumer     *oauth.Consumer
+	consumer     *oauth1.Consumer
 	providerName string
 }
 
@@ -103,20 +104,29 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	return &c
+}
+
 // Client does pretty much everything
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
 
-// Debug sets the logging of the OAuth client to verbose.
+// Debug sets the logging of the OAuth client to verbose. The output, with
+// its tokens redacted, goes to the logger set with goth.SetLogger.
 func (p *Provider) Debug(debug bool) {
 	p.debug = debug
+	p.consumer.Debug = debug
 }
 
 // BeginAuth asks Xero for an authentication end-point and a request token for a session.
 // Xero does not support the "state" variable.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	requestToken, url, err := p.consumer.GetRequestTokenAndUrl(p.CallbackURL)
+	requestToken, url, err := p.consumer.RequestToken(context.Background(), p.CallbackURL)
 	if err != nil {
 		return nil, err
 	}
@@ -140,6 +150,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 	}
 
 	response, err := p.consumer.Get(
+		context.Background(),
 		endpointProfile+"Organisation",
 		nil,
 		sess.AccessToken)
@@ -180,21 +191,22 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 }
 
 // newPublicConsumer creates a consumer capable of communicating with a Public application: https://developer.xero.com/documentation/auth-and-limits/public-applications
-func newPublicConsumer(provider *Provider, authURL string) *oauth.Consumer {
-	c := oauth.NewConsumer(
+func newPublicConsumer(provider *Provider, authURL string) *oauth1.Consumer {
+	c := oauth1.NewConsumer(
 		provider.ClientKey,
 		provider.Secret,
-		oauth.ServiceProvider{
-			RequestTokenUrl:   requestURL,
-			AuthorizeTokenUrl: authURL,
-			AccessTokenUrl:    tokenURL},
+		oauth1.Endpoint{
+			RequestTokenURL: requestURL,
+			AuthorizeURL:    authURL,
+			AccessTokenURL:  tokenURL},
+		provider.Client,
 	)
 
-	c.Debug(provider.debug)
+	c.Debug = provider.debug
 
 	accepttype := []string{"application/json"}
 	useragent := []string{userAgentString}
-	c.AdditionalHeaders = map[string][]string{
+	c.Header = http.Header{
 		"Accept":     accepttype,
 		"User-Agent": useragent,
 	}
@@ -203,7 +215,7 @@ func newPublicConsumer(provider *Provider, authURL string) *oauth.Consumer {
 }
 
 // newPartnerConsumer creates a consumer capable of communicating with a Partner application: https://developer.xero.com/documentation/auth-and-limits/partner-applications
-func newPrivateOrPartnerConsumer(provider *Provider, authURL string) *oauth.Consumer {
+func newPrivateOrPartnerConsumer(provider *Provider, authURL string) *oauth1.Consumer {
 	privateKeyFileContents, err := ioutil.ReadFile(privateKeyFilePath)
 	if err != nil {
 		log.Fatal(err)
@@ -215,20 +227,21 @@ func newPrivateOrPartnerConsumer(provider *Provider, authURL string) *oauth.Cons
 	if err != nil {
 		log.Fatal(err)
 	}
-	c := oauth.NewRSAConsumer(
+	c := oauth1.NewRSAConsumer(
 		provider.ClientKey,
 		privateKey,
-		oauth.ServiceProvider{
-			RequestTokenUrl:   requestURL,
-			AuthorizeTokenUrl: authURL,
-			AccessTokenUrl:    tokenURL},
+		oauth1.Endpoint{
+			RequestTokenURL: requestURL,
+			AuthorizeURL:    authURL,
+			AccessTokenURL:  tokenURL},
+		provider.Client,
 	)
 
-	c.Debug(provider.debug)
+	c.Debug = provider.debug
 
 	accepttype := []string{"application/json"}
 	useragent := []string{userAgentString}
-	c.AdditionalHeaders = map[string][]string{
+	c.Header = http.Header{
 		"Accept":     accepttype,
 		"User-Agent": useragent,
 	}
@@ -238,12 +251,12 @@ func newPrivateOrPartnerConsumer(provider *Provider, authURL string) *oauth.Cons
 
 // RefreshOAuth1Token should be used instead of RefeshToken which is not compliant with the Oauth1.0a standard
 func (p *Provider) RefreshOAuth1Token(session *Session) error {
-	newAccessToken, err := p.consumer.RefreshToken(session.AccessToken)
+	newAccessToken, err := p.consumer.RefreshToken(context.Background(), session.AccessToken)
 	if err != nil {
 		return err
 	}
 	session.AccessToken = newAccessToken
-	session.AccessTokenExpires = time.Now().UTC().Add(30 * time.Minute)
+	session.AccessTokenExpires = goth.Now().UTC().Add(30 * time.Minute)
 	return nil
 }
 
@@ -92,7 +92,7 @@ func xeroProvider() *Provider {
 func init() {
 	p := pat.New()
 	p.Get("/oauth/RequestToken", func(res http.ResponseWriter, req *http.Request) {
-		fmt.Fprint(res, "oauth_token=TOKEN&oauth_token_secret=SECRET")
+		fmt.Fprint(res, "oauth_token=TOKEN&oauth_token_secret=SECRET&oauth_callback_confirmed=true")
 	})
 	p.Get("/oauth/Authorize", func(res http.ResponseWriter, req *http.Request) {
 		fmt.Fprint(res, "DO NOT USE THIS ENDPOINT")
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 	"golang.org/x/oauth2"
 )
 
@@ -16,6 +17,7 @@ type Session struct {
 	AccessToken  string
 	RefreshToken string
 	ExpiresAt    time.Time
+	CodeVerifier string `json:",omitempty"`
 }
 
 var _ goth.Session = &Session{}
@@ -42,6 +44,9 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 
 	// set code_verifier if passed as param
 	codeVerifier := params.Get("code_verifier")
+	if codeVerifier == "" {
+		codeVerifier = s.CodeVerifier
+	}
 	if codeVerifier != "" {
 		authParams = append(authParams, oauth2.SetAuthURLParam("code_verifier", codeVerifier))
 	}
@@ -58,6 +63,7 @@ func (s *Session) Authorize(provider goth.Provider, params goth.Params) (string,
 
 	s.AccessToken = token.AccessToken
 	s.RefreshToken = token.RefreshToken
+	s.ExpiresAt = token.Expiry
 	return token.AccessToken, err
 }
 
@@ -77,3 +83,13 @@ func (p *Provider) UnmarshalSession(data string) (goth.Session, error) {
 	err := json.NewDecoder(strings.NewReader(data)).Decode(s)
 	return s, err
 }
+
+// Token implements goth.TokenSession.
+func (s *Session) Token() *oauth2.Token {
+	return oauth2util.Token(s.AccessToken, s.RefreshToken, s.ExpiresAt)
+}
+
+// SetToken implements goth.TokenSession.
+func (s *Session) SetToken(token *oauth2.Token) {
+	oauth2util.SetToken(token, &s.AccessToken, &s.RefreshToken, &s.ExpiresAt)
+}
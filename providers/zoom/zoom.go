@@ -1,23 +1,26 @@
-// Package zoom implements the OAuth2 protocol for authenticating users through zoo.
+// Package zoom implements the OAuth2 protocol for authenticating users through Zoom.
 // This package can be used as a reference implementation of an OAuth2 provider for Goth.
 package zoom
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"golang.org/x/oauth2"
 
 	"github.com/markbates/goth"
+	"github.com/markbates/goth/internal/oauth2util"
 )
 
 var (
 	authorizeURL = "https://zoom.us/oauth/authorize"
 	tokenURL     = "https://zoom.us/oauth/token"
-	profileURL   = "https://zoom.us/v2/users/me"
+	profileURL   = "https://api.zoom.us/v2/users/me"
 )
 
 // Provider is the implementation of `goth.Provider` for accessing Zoom.
@@ -28,6 +31,7 @@ type Provider struct {
 	HTTPClient   *http.Client
 	config       *oauth2.Config
 	providerName string
+	pkce         bool
 }
 
 type profileResp struct {
@@ -36,6 +40,7 @@ type profileResp struct {
 	Email     string `json:"email"`
 	AvatarURL string `json:"pic_url"`
 	ID        string `json:"id"`
+	Location  string `json:"location"`
 }
 
 // New creates a new Zoom provider and sets up connection details.
@@ -60,6 +65,14 @@ func (p *Provider) SetName(name string) {
 	p.providerName = name
 }
 
+// WithCallbackURL implements goth.CallbackURLProvider.
+func (p *Provider) WithCallbackURL(callbackURL string) goth.Provider {
+	c := *p
+	c.CallbackURL = callbackURL
+	c.config = oauth2util.WithRedirectURL(p.config, callbackURL)
+	return &c
+}
+
 func (p *Provider) Client() *http.Client {
 	return goth.HTTPClientWithFallBack(p.HTTPClient)
 }
@@ -67,11 +80,22 @@ func (p *Provider) Client() *http.Client {
 // Debug is a no-op for the zoom package.
 func (p *Provider) Debug(debug bool) {}
 
+// SetPKCE enables or disables Proof Key for Code Exchange (PKCE) for the
+// authorization code flow. It is disabled by default for Zoom.
+func (p *Provider) SetPKCE(enabled bool) {
+	p.pkce = enabled
+}
+
 // BeginAuth returns zoom authentication endpoint.
 func (p *Provider) BeginAuth(state string) (goth.Session, error) {
-	return &Session{
-		AuthURL: p.config.AuthCodeURL(state),
-	}, nil
+	session := &Session{}
+	var opts []oauth2.AuthCodeOption
+	if p.pkce {
+		session.CodeVerifier = goth.GeneratePKCEVerifier()
+		opts = append(opts, goth.PKCEChallengeOption(session.CodeVerifier))
+	}
+	session.AuthURL = p.config.AuthCodeURL(state, opts...)
+	return session, nil
 }
 
 // FetchUser makes a request to profileURL and returns zoom user data.
@@ -81,6 +105,7 @@ func (p *Provider) FetchUser(session goth.Session) (goth.User, error) {
 		AccessToken:  s.AccessToken,
 		Provider:     p.Name(),
 		RefreshToken: s.RefreshToken,
+		ExpiresAt:    s.ExpiresAt,
 	}
 
 	if user.AccessToken == "" {
@@ -116,13 +141,12 @@ func (p *Provider) RefreshTokenAvailable() bool {
 
 // RefreshToken get new access token based on the refresh token
 func (p *Provider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
-	token := &oauth2.Token{RefreshToken: refreshToken}
-	ts := p.config.TokenSource(goth.ContextForClient(p.Client()), token)
-	newToken, err := ts.Token()
-	if err != nil {
-		return nil, err
-	}
-	return newToken, err
+	return p.RefreshTokenCtx(context.Background(), refreshToken)
+}
+
+// RefreshTokenCtx implements goth.ContextRefresher.
+func (p *Provider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return oauth2util.Refresh(ctx, p.config, p.Client(), refreshToken)
 }
 
 func newConfig(provider *Provider, scopes []string) *oauth2.Config {
@@ -133,6 +157,8 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  authorizeURL,
 			TokenURL: tokenURL,
+			// Zoom only accepts the client credentials as HTTP Basic auth.
+			AuthStyle: oauth2.AuthStyleInHeader,
 		},
 		Scopes: []string{},
 	}
@@ -146,6 +172,9 @@ func newConfig(provider *Provider, scopes []string) *oauth2.Config {
 	return c
 }
 
+// userFromReader decodes the /users/me response. Fields without a
+// counterpart in goth.User, such as account_id and pmi (the personal meeting
+// ID), are available in RawData.
 func userFromReader(r io.Reader, user *goth.User) error {
 	var rawData map[string]interface{}
 
@@ -169,9 +198,10 @@ func userFromReader(r io.Reader, user *goth.User) error {
 	user.Email = u.Email
 	user.FirstName = u.FirstName
 	user.LastName = u.LastName
-	user.Name = fmt.Sprintf("%s %s", u.FirstName, u.LastName)
+	user.Name = strings.TrimSpace(u.FirstName + " " + u.LastName)
 	user.UserID = u.ID
 	user.AvatarURL = u.AvatarURL
+	user.Location = u.Location
 	user.RawData = rawData
 
 	return nil
@@ -2,7 +2,10 @@ package zoom_test
 
 import (
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/markbates/goth"
@@ -53,3 +56,64 @@ func Test_SessionFromJSON(t *testing.T) {
 	a.Equal(session.AuthURL, "https://app.zoom.io/oauth")
 	a.Equal(session.AccessToken, "1234567890")
 }
+
+func Test_FetchUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := zoomProvider()
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("https://api.zoom.us/v2/users/me", req.URL.String())
+		a.Equal("Bearer TOKEN", req.Header.Get("Authorization"))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body: io.NopCloser(strings.NewReader(`{"id":"KDcuGIm1QgePTO8WbOqwIQ","first_name":"Jill","last_name":"Chill",` +
+				`"email":"jchill@example.com","pic_url":"https://example.com/jill.png","location":"Paris",` +
+				`"account_id":"q6gBJVO5TzexKYTb_I2rpg","pmi":3542471135}`)),
+		}, nil
+	})}
+
+	user, err := p.FetchUser(&zoom.Session{AccessToken: "TOKEN", RefreshToken: "REFRESH"})
+	a.NoError(err)
+	a.Equal("KDcuGIm1QgePTO8WbOqwIQ", user.UserID)
+	a.Equal("Jill Chill", user.Name)
+	a.Equal("jchill@example.com", user.Email)
+	a.Equal("Paris", user.Location)
+	a.Equal("REFRESH", user.RefreshToken)
+	a.Equal("q6gBJVO5TzexKYTb_I2rpg", user.RawData["account_id"])
+	a.Equal("3542471135", user.Claims().String("pmi"))
+}
+
+func Test_RefreshToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	p := zoomProvider()
+	a.True(p.RefreshTokenAvailable())
+	p.HTTPClient = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		a.Equal("https://zoom.us/oauth/token", req.URL.String())
+		_, _, ok := req.BasicAuth()
+		a.True(ok)
+		a.NoError(req.ParseForm())
+		a.Equal("refresh_token", req.PostForm.Get("grant_type"))
+		a.Equal("REFRESH", req.PostForm.Get("refresh_token"))
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"access_token":"NEW","refresh_token":"NEXT","token_type":"bearer","expires_in":3599}`)),
+		}, nil
+	})}
+
+	token, err := p.RefreshToken("REFRESH")
+	a.NoError(err)
+	a.Equal("NEW", token.AccessToken)
+	a.Equal("NEXT", token.RefreshToken)
+	a.False(token.Expiry.IsZero())
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
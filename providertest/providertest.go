@@ -0,0 +1,294 @@
+// Package providertest checks that goth providers behave the way gothic and
+// the other users of goth rely on. Provider authors, in-tree or not, can get
+// that coverage with a single call in their tests:
+//
+//	func Test_Conformance(t *testing.T) {
+//		providertest.RunConformance(t, New("key", "secret", "/foo"))
+//	}
+//
+// No requests should be needed to create a session, so BeginAuth must not
+// call the identity provider, or the provider must be given an HTTPClient
+// answering for it.
+package providertest
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/markbates/goth"
+)
+
+// state is the state passed to BeginAuth.
+const state = "providertest-state"
+
+// callbackURL is the URL passed to WithCallbackURL.
+const callbackURL = "https://providertest.example.com/callback"
+
+// Option tweaks the checks made by RunConformance.
+type Option func(*config)
+
+type config struct {
+	stateInAuthURL bool
+}
+
+// WithoutStateInAuthURL skips checking that the state given to BeginAuth is
+// a parameter of the authorization URL, for protocols that don't carry it
+// there, such as OAuth 1.0a.
+func WithoutStateInAuthURL() Option {
+	return func(c *config) {
+		c.stateInAuthURL = false
+	}
+}
+
+// RunConformance runs subtests checking that provider:
+//
+//   - has a name, and can be renamed with SetName
+//   - passes the state given to BeginAuth on to the authorization URL
+//   - marshals its sessions into strings UnmarshalSession turns back into the
+//     same sessions, and rejects strings that aren't sessions
+//   - fails to fetch the user of a session that hasn't been authorized, which
+//     holds no token, instead of making up a user
+//   - if it implements goth.CallbackURLProvider, sends users back to the URL
+//     given to WithCallbackURL, and keeps its own callback URL
+//   - doesn't panic on any of the above, nor in Debug and RefreshToken
+//
+// The provider is renamed during the run, and given its name back afterwards.
+func RunConformance(t *testing.T, provider goth.Provider, opts ...Option) {
+	t.Helper()
+	if provider == nil {
+		t.Fatal("providertest: the provider is nil")
+	}
+	c := &config{stateInAuthURL: true}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	t.Run("Name", func(t *testing.T) {
+		testName(t, provider)
+	})
+	t.Run("BeginAuth", func(t *testing.T) {
+		testBeginAuth(t, provider, c)
+	})
+	t.Run("SessionRoundTrip", func(t *testing.T) {
+		testSessionRoundTrip(t, provider)
+	})
+	t.Run("UnmarshalInvalidSession", func(t *testing.T) {
+		testUnmarshalInvalidSession(t, provider)
+	})
+	t.Run("FetchUserWithoutToken", func(t *testing.T) {
+		testFetchUserWithoutToken(t, provider)
+	})
+	t.Run("CallbackURL", func(t *testing.T) {
+		testCallbackURL(t, provider)
+	})
+	t.Run("NilSafety", func(t *testing.T) {
+		testNilSafety(t, provider)
+	})
+}
+
+func testName(t *testing.T, provider goth.Provider) {
+	var name string
+	if !call(t, "Name", func() { name = provider.Name() }) {
+		return
+	}
+	if name == "" {
+		t.Error("Name returned an empty name")
+	}
+
+	defer provider.SetName(name)
+	var renamed string
+	if !call(t, "SetName", func() {
+		provider.SetName("providertest-renamed")
+		renamed = provider.Name()
+	}) {
+		return
+	}
+	if renamed != "providertest-renamed" {
+		t.Errorf("Name returned %q after SetName(%q)", renamed, "providertest-renamed")
+	}
+}
+
+func testBeginAuth(t *testing.T, provider goth.Provider, c *config) {
+	u, ok := authURL(t, provider)
+	if !ok {
+		return
+	}
+	if c.stateInAuthURL && u.Query().Get("state") != state {
+		t.Errorf("the auth URL %q doesn't carry the state %q given to BeginAuth", u, state)
+	}
+}
+
+func testCallbackURL(t *testing.T, provider goth.Provider) {
+	p, ok := provider.(goth.CallbackURLProvider)
+	if !ok {
+		return
+	}
+	before, ok := authURL(t, provider)
+	if !ok {
+		return
+	}
+
+	var c goth.Provider
+	if !call(t, "WithCallbackURL", func() { c = p.WithCallbackURL(callbackURL) }) {
+		return
+	}
+	if c == nil {
+		t.Error("WithCallbackURL returned a nil provider")
+		return
+	}
+	u, ok := authURL(t, c)
+	if !ok {
+		return
+	}
+	// protocols like OAuth 1.0a don't pass the callback URL in the auth URL
+	if before.Query().Get("redirect_uri") == "" {
+		return
+	}
+	if got := u.Query().Get("redirect_uri"); got != callbackURL {
+		t.Errorf("the auth URL of WithCallbackURL(%q) has the redirect_uri %q", callbackURL, got)
+	}
+	after, ok := authURL(t, provider)
+	if ok && after.Query().Get("redirect_uri") != before.Query().Get("redirect_uri") {
+		t.Errorf("WithCallbackURL changed the redirect_uri of the provider to %q", after.Query().Get("redirect_uri"))
+	}
+}
+
+func testSessionRoundTrip(t *testing.T, provider goth.Provider) {
+	sess, ok := beginAuth(t, provider)
+	if !ok {
+		return
+	}
+
+	var data string
+	if !call(t, "Marshal", func() { data = sess.Marshal() }) {
+		return
+	}
+
+	var restored goth.Session
+	var err error
+	if !call(t, "UnmarshalSession", func() { restored, err = provider.UnmarshalSession(data) }) {
+		return
+	}
+	if err != nil {
+		t.Errorf("UnmarshalSession failed on the output of Marshal %q: %v", data, err)
+		return
+	}
+	if restored == nil {
+		t.Error("UnmarshalSession returned a nil session")
+		return
+	}
+
+	var again string
+	if !call(t, "Marshal", func() { again = restored.Marshal() }) {
+		return
+	}
+	if again != data {
+		t.Errorf("the session changed through Marshal and UnmarshalSession: %q became %q", data, again)
+	}
+
+	want, _ := sess.GetAuthURL()
+	got, _ := restored.GetAuthURL()
+	if got != want {
+		t.Errorf("the unmarshaled session has the auth URL %q instead of %q", got, want)
+	}
+}
+
+func testUnmarshalInvalidSession(t *testing.T, provider goth.Provider) {
+	var err error
+	if !call(t, "UnmarshalSession", func() { _, err = provider.UnmarshalSession("providertest: not a session") }) {
+		return
+	}
+	if err == nil {
+		t.Error("UnmarshalSession accepted a string that isn't a session")
+	}
+}
+
+func testFetchUserWithoutToken(t *testing.T, provider goth.Provider) {
+	sess, ok := beginAuth(t, provider)
+	if !ok {
+		return
+	}
+
+	var user goth.User
+	var err error
+	if !call(t, "FetchUser", func() { user, err = provider.FetchUser(sess) }) {
+		return
+	}
+	if err == nil {
+		t.Errorf("FetchUser returned %+v for a session that hasn't been authorized", user)
+	}
+}
+
+func testNilSafety(t *testing.T, provider goth.Provider) {
+	call(t, "Debug", func() {
+		provider.Debug(true)
+		provider.Debug(false)
+	})
+
+	var available bool
+	if !call(t, "RefreshTokenAvailable", func() { available = provider.RefreshTokenAvailable() }) {
+		return
+	}
+	if !available {
+		call(t, "RefreshToken", func() { _, _ = provider.RefreshToken("") })
+	}
+}
+
+// beginAuth starts a session, reporting failures to t.
+func beginAuth(t *testing.T, provider goth.Provider) (goth.Session, bool) {
+	t.Helper()
+	var sess goth.Session
+	var err error
+	if !call(t, "BeginAuth", func() { sess, err = provider.BeginAuth(state) }) {
+		return nil, false
+	}
+	if err != nil {
+		t.Errorf("BeginAuth failed: %v", err)
+		return nil, false
+	}
+	if sess == nil {
+		t.Error("BeginAuth returned a nil session")
+		return nil, false
+	}
+	return sess, true
+}
+
+// authURL begins the authentication with provider and parses the
+// authorization URL of the session.
+func authURL(t *testing.T, provider goth.Provider) (*url.URL, bool) {
+	t.Helper()
+	sess, ok := beginAuth(t, provider)
+	if !ok {
+		return nil, false
+	}
+
+	var authURL string
+	var err error
+	if !call(t, "GetAuthURL", func() { authURL, err = sess.GetAuthURL() }) {
+		return nil, false
+	}
+	if err != nil {
+		t.Errorf("GetAuthURL failed: %v", err)
+		return nil, false
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Errorf("GetAuthURL returned an invalid URL %q: %v", authURL, err)
+		return nil, false
+	}
+	return u, true
+}
+
+// call runs fn, reporting a panic to t as a failure of the method name.
+func call(t *testing.T, name string, fn func()) (ok bool) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf("%s panicked: %v", name, fmt.Sprint(r))
+			ok = false
+		}
+	}()
+	fn()
+	return true
+}
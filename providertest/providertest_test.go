@@ -0,0 +1,12 @@
+package providertest_test
+
+import (
+	"testing"
+
+	"github.com/markbates/goth/providers/faux"
+	"github.com/markbates/goth/providertest"
+)
+
+func Test_RunConformance(t *testing.T) {
+	providertest.RunConformance(t, &faux.Provider{})
+}
@@ -0,0 +1,104 @@
+package goth
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitError is returned by providers when the identity provider turned a
+// request down because of its rate limit. Use errors.As to retrieve it:
+//
+//	var rl *goth.RateLimitError
+//	if errors.As(err, &rl) {
+//		// try again in rl.RetryAfter
+//	}
+//
+// To have such requests retried once the limit resets, use a client whose
+// transport is a Transport with MaxRetries set, which waits as long as the
+// rate limit headers ask for, within its MaxBackoff.
+type RateLimitError struct {
+	Provider   string
+	StatusCode int
+	// Limit and Remaining are the values of the X-RateLimit-Limit and
+	// X-RateLimit-Remaining headers, or -1 if the response had none.
+	Limit     int
+	Remaining int
+	// Reset is the time the rate limit resets, or zero if unknown.
+	Reset time.Time
+	// RetryAfter is how long to wait before trying again, or zero if unknown.
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s responded with a %d: rate limit exceeded, retry in %s", e.Provider, e.StatusCode, e.RetryAfter)
+	}
+	return fmt.Sprintf("%s responded with a %d: rate limit exceeded", e.Provider, e.StatusCode)
+}
+
+// CheckRateLimit returns a *RateLimitError if res tells that provider rate
+// limited the request, i.e. it has a 429 status, or a 403 status with no
+// request remaining as GitHub responds with, and nil otherwise.
+func CheckRateLimit(provider string, res *http.Response) error {
+	if res == nil {
+		return nil
+	}
+	limit := headerInt(res.Header, "X-RateLimit-Limit")
+	remaining := headerInt(res.Header, "X-RateLimit-Remaining")
+	if res.StatusCode != http.StatusTooManyRequests && (res.StatusCode != http.StatusForbidden || remaining != 0) {
+		return nil
+	}
+
+	e := &RateLimitError{
+		Provider:   provider,
+		StatusCode: res.StatusCode,
+		Limit:      limit,
+		Remaining:  remaining,
+	}
+	now := time.Now()
+	if wait, ok := rateLimitWait(res.Header, now); ok {
+		e.RetryAfter = wait
+		e.Reset = now.Add(wait)
+	}
+	return e
+}
+
+// rateLimitWait returns how long the rate limit headers of a response ask to
+// wait for, looking at Retry-After, in seconds or as an HTTP date, and at
+// X-RateLimit-Reset-After (Discord) and X-RateLimit-Reset (GitHub), the
+// latter being a Unix time.
+func rateLimitWait(h http.Header, now time.Time) (time.Duration, bool) {
+	if v := h.Get("Retry-After"); v != "" {
+		if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+			return time.Duration(s) * time.Second, true
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return nonNegative(t.Sub(now)), true
+		}
+	}
+	if s, err := strconv.ParseFloat(h.Get("X-RateLimit-Reset-After"), 64); err == nil && s >= 0 {
+		return time.Duration(s * float64(time.Second)), true
+	}
+	if s, err := strconv.ParseFloat(h.Get("X-RateLimit-Reset"), 64); err == nil && s > 0 {
+		reset := time.Unix(0, int64(s*float64(time.Second)))
+		return nonNegative(reset.Sub(now)), true
+	}
+	return 0, false
+}
+
+func headerInt(h http.Header, name string) int {
+	n, err := strconv.Atoi(h.Get(name))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
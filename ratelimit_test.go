@@ -0,0 +1,82 @@
+package goth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_CheckRateLimit(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.NoError(goth.CheckRateLimit("faux", &http.Response{StatusCode: http.StatusOK}))
+	a.NoError(goth.CheckRateLimit("faux", &http.Response{StatusCode: http.StatusForbidden, Header: http.Header{}}))
+
+	err := goth.CheckRateLimit("faux", &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": {"30"}},
+	})
+	var rl *goth.RateLimitError
+	a.True(errors.As(err, &rl))
+	a.Equal("faux", rl.Provider)
+	a.Equal(30*time.Second, rl.RetryAfter)
+	a.WithinDuration(time.Now().Add(30*time.Second), rl.Reset, time.Second)
+	a.Equal(-1, rl.Limit)
+	a.Equal("faux responded with a 429: rate limit exceeded, retry in 30s", err.Error())
+
+	// GitHub's primary rate limit
+	reset := time.Now().Add(time.Minute).Truncate(time.Second)
+	err = goth.CheckRateLimit("github", &http.Response{
+		StatusCode: http.StatusForbidden,
+		Header: http.Header{
+			"X-Ratelimit-Limit":     {"5000"},
+			"X-Ratelimit-Remaining": {"0"},
+			"X-Ratelimit-Reset":     {strconv.FormatInt(reset.Unix(), 10)},
+		},
+	})
+	a.True(errors.As(err, &rl))
+	a.Equal(5000, rl.Limit)
+	a.Equal(0, rl.Remaining)
+	a.WithinDuration(reset, rl.Reset, time.Second)
+
+	// Discord's bucket reset
+	err = goth.CheckRateLimit("discord", &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"X-Ratelimit-Reset-After": {"1.5"}},
+	})
+	a.True(errors.As(err, &rl))
+	a.Equal(1500*time.Millisecond, rl.RetryAfter)
+}
+
+func Test_Transport_RetryAfterRateLimit(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("X-RateLimit-Reset-After", "0.05")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: &goth.Transport{MaxRetries: 1, Backoff: time.Hour}}
+	start := time.Now()
+	res, err := client.Get(ts.URL)
+	a.NoError(err)
+	res.Body.Close()
+	a.Equal(http.StatusOK, res.StatusCode)
+	a.Equal(int32(2), atomic.LoadInt32(&calls))
+	a.True(time.Since(start) >= 50*time.Millisecond)
+}
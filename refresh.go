@@ -0,0 +1,71 @@
+package goth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// ContextRefresher is implemented by providers whose refresh token request
+// can be bound to a context, e.g. to cancel it or to set a deadline.
+type ContextRefresher interface {
+	RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error)
+}
+
+// TokenRotated, if set, is called by RefreshToken whenever a provider hands out
+// a new refresh token in place of the one that was used. Several providers
+// (e.g. TikTok, Twitch, Fitbit) invalidate the old refresh token, so applications
+// should use this hook to persist the new one.
+var TokenRotated func(ctx context.Context, provider string, oldRefreshToken string, token *oauth2.Token)
+
+// GrantRevokedError is returned by RefreshToken when the provider refused the
+// refresh token as an invalid_grant, i.e. the user revoked the access of the
+// application, their account was deactivated, or the refresh token expired or
+// was rotated. The user has to sign in again, and applications may want to
+// deactivate the accounts linked to the grant. Use errors.As to retrieve it.
+type GrantRevokedError struct {
+	Provider string
+	// RefreshToken is the refresh token that was refused, to find the linked
+	// account by. It is left out of the error message.
+	RefreshToken string
+	// Err is the error returned by the provider, usually an
+	// *oauth2.RetrieveError.
+	Err error
+}
+
+func (e *GrantRevokedError) Error() string {
+	return fmt.Sprintf("%s revoked the grant: %v", e.Provider, e.Err)
+}
+
+func (e *GrantRevokedError) Unwrap() error {
+	return e.Err
+}
+
+// RefreshToken gets a new access token from provider based on refreshToken.
+// It uses RefreshTokenCtx when the provider implements ContextRefresher, and
+// calls TokenRotated if the provider rotated the refresh token. If the
+// provider refuses refreshToken as an invalid grant, the error is a
+// *GrantRevokedError.
+func RefreshToken(ctx context.Context, provider Provider, refreshToken string) (*oauth2.Token, error) {
+	var token *oauth2.Token
+	var err error
+	if p, ok := provider.(ContextRefresher); ok {
+		token, err = p.RefreshTokenCtx(ctx, refreshToken)
+	} else {
+		token, err = provider.RefreshToken(refreshToken)
+	}
+	if err != nil {
+		var re *oauth2.RetrieveError
+		if errors.As(err, &re) && re.ErrorCode == "invalid_grant" {
+			err = &GrantRevokedError{Provider: provider.Name(), RefreshToken: refreshToken, Err: err}
+		}
+		return nil, err
+	}
+
+	if token != nil && token.RefreshToken != "" && token.RefreshToken != refreshToken && TokenRotated != nil {
+		TokenRotated(ctx, provider.Name(), refreshToken, token)
+	}
+	return token, nil
+}
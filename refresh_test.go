@@ -0,0 +1,58 @@
+package goth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+type rotatingProvider struct {
+	faux.Provider
+}
+
+func (p *rotatingProvider) RefreshTokenCtx(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: "access", RefreshToken: refreshToken + "-rotated"}, nil
+}
+
+func Test_RefreshTokenRotated(t *testing.T) {
+	a := assert.New(t)
+
+	var rotated *oauth2.Token
+	goth.TokenRotated = func(ctx context.Context, provider string, old string, token *oauth2.Token) {
+		a.Equal("faux", provider)
+		a.Equal("refresh", old)
+		rotated = token
+	}
+	defer func() { goth.TokenRotated = nil }()
+
+	token, err := goth.RefreshToken(context.Background(), &rotatingProvider{}, "refresh")
+	a.NoError(err)
+	a.Equal("refresh-rotated", token.RefreshToken)
+	a.Equal(token, rotated)
+}
+
+type revokedProvider struct {
+	faux.Provider
+}
+
+func (p *revokedProvider) RefreshToken(refreshToken string) (*oauth2.Token, error) {
+	return nil, &oauth2.RetrieveError{ErrorCode: "invalid_grant", ErrorDescription: "Token has been expired or revoked."}
+}
+
+func Test_RefreshTokenGrantRevoked(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	_, err := goth.RefreshToken(context.Background(), &revokedProvider{}, "refresh")
+	var revoked *goth.GrantRevokedError
+	a.ErrorAs(err, &revoked)
+	a.Equal("faux", revoked.Provider)
+	a.Equal("refresh", revoked.RefreshToken)
+
+	var re *oauth2.RetrieveError
+	a.ErrorAs(err, &re)
+}
@@ -0,0 +1,26 @@
+package goth
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrRevocationNotSupported is returned by RevokeToken for providers that
+// don't implement TokenRevoker.
+var ErrRevocationNotSupported = errors.New("the provider does not support token revocation")
+
+// TokenRevoker is implemented by providers able to revoke an access or refresh
+// token they issued, e.g. when a user logs out or disconnects their account.
+type TokenRevoker interface {
+	RevokeToken(ctx context.Context, token string) error
+}
+
+// RevokeToken revokes token with provider, or returns ErrRevocationNotSupported
+// if the provider can't revoke tokens.
+func RevokeToken(ctx context.Context, provider Provider, token string) error {
+	p, ok := provider.(TokenRevoker)
+	if !ok {
+		return ErrRevocationNotSupported
+	}
+	return p.RevokeToken(ctx, token)
+}
@@ -0,0 +1,18 @@
+package goth
+
+import "strings"
+
+// DefaultScopeSeparator is the separator used between scopes by oauth2,
+// as mandated by https://datatracker.ietf.org/doc/html/rfc6749#section-3.3
+const DefaultScopeSeparator = " "
+
+// JoinScopes prepares scopes for use as oauth2.Config.Scopes by providers
+// that expect another separator than a space between scopes. oauth2 always
+// joins the configured scopes with a space, so they are joined with sep up
+// front into a single value.
+func JoinScopes(sep string, scopes ...string) []string {
+	if sep == "" || sep == DefaultScopeSeparator || len(scopes) < 2 {
+		return scopes
+	}
+	return []string{strings.Join(scopes, sep)}
+}
@@ -0,0 +1,223 @@
+/*
+Package scopes builds the scopes passed to providers, checking them against
+the scopes each provider is known to accept. Providers silently ignore or
+reject misspelled scopes, often only once a user signs in, so they are better
+caught when the application starts:
+
+	discordProvider := discord.New(key, secret, callbackURL,
+		scopes.Discord(discord.ScopeIdentify, discord.ScopeEmail)...)
+
+or, to fail instead of warning:
+
+	s := []string{"identify", "emial"}
+	if err := scopes.Validate("discord", s...); err != nil {
+		log.Fatal(err)
+	}
+
+The scopes of Discord, GitHub and Spotify are known out of the box, those of
+other providers can be added with Register.
+*/
+package scopes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/markbates/goth/providers/discord"
+	"github.com/markbates/goth/providers/github"
+	"github.com/markbates/goth/providers/spotify"
+)
+
+// UnknownScopeHandler is called by For when it is passed a scope the provider
+// isn't known to accept.
+type UnknownScopeHandler func(provider, scope string)
+
+var (
+	mu             sync.RWMutex
+	known          = map[string]map[string]bool{}
+	unknownHandler UnknownScopeHandler
+)
+
+func init() {
+	Register("discord",
+		discord.ScopeIdentify,
+		discord.ScopeEmail,
+		discord.ScopeConnections,
+		discord.ScopeGuilds,
+		discord.ScopeJoinGuild,
+		discord.ScopeGroupDMjoin,
+		discord.ScopeBot,
+		discord.ScopeWebhook,
+		discord.ScopeReadGuilds,
+		discord.ScopeOpenID,
+		discord.ScopeApplicationsCommands,
+		discord.ScopeRoleConnectionsWrite,
+		discord.ScopeActivitiesRead,
+		discord.ScopeDMChannelsRead,
+	)
+	Register("github",
+		github.ScopeRepo,
+		"repo:status",
+		"repo_deployment",
+		github.ScopePublicRepo,
+		"repo:invite",
+		"security_events",
+		"admin:repo_hook", "write:repo_hook", "read:repo_hook",
+		"admin:org", "write:org", github.ScopeReadOrg,
+		"admin:public_key", "write:public_key", "read:public_key",
+		"admin:org_hook",
+		github.ScopeGist,
+		github.ScopeNotifications,
+		github.ScopeUser,
+		github.ScopeReadUser,
+		github.ScopeUserEmail,
+		github.ScopeUserFollow,
+		"project", "read:project",
+		"delete_repo",
+		"write:packages", github.ScopeReadPackages, "delete:packages",
+		"admin:gpg_key", "write:gpg_key", "read:gpg_key",
+		"admin:ssh_signing_key", "write:ssh_signing_key", "read:ssh_signing_key",
+		"codespace",
+		github.ScopeWorkflow,
+		"write:discussion", "read:discussion",
+	)
+	Register("spotify",
+		spotify.ScopePlaylistReadCollaborative,
+		spotify.ScopePlaylistReadPrivate,
+		spotify.ScopePlaylistModifyPublic,
+		spotify.ScopePlaylistModifyPrivate,
+		spotify.ScopeUserFollowModify,
+		spotify.ScopeUserFollowRead,
+		spotify.ScopeUserLibraryModify,
+		spotify.ScopeUserLibraryRead,
+		spotify.ScopeUserReadPrivate,
+		spotify.ScopeUserReadEmail,
+		spotify.ScopeUGCImageUpload,
+		spotify.ScopeUserReadPlaybackState,
+		spotify.ScopeUserModifyPlaybackState,
+		spotify.ScopeUserReadCurrentlyPlaying,
+		spotify.ScopeStreaming,
+		spotify.ScopeAppRemoteControl,
+		spotify.ScopeUserTopRead,
+		spotify.ScopeUserReadRecentlyPlayed,
+		spotify.ScopeUserReadPlaybackPosition,
+	)
+}
+
+// Register adds scopes to those the named provider is known to accept, e.g.
+// for a provider this package doesn't know about, or for scopes it doesn't
+// know yet.
+func Register(provider string, scopes ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	if known[provider] == nil {
+		known[provider] = map[string]bool{}
+	}
+	for _, s := range scopes {
+		known[provider][s] = true
+	}
+}
+
+// Known returns the scopes the named provider is known to accept, sorted, or
+// nil if there is no list of them for that provider.
+func Known(provider string) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if known[provider] == nil {
+		return nil
+	}
+	list := make([]string, 0, len(known[provider]))
+	for s := range known[provider] {
+		list = append(list, s)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// OnUnknownScope sets the handler called by For, and so Discord, GitHub and
+// Spotify, for every scope the provider isn't known to accept, e.g. to log a
+// warning. Passing nil removes it.
+func OnUnknownScope(h UnknownScopeHandler) {
+	mu.Lock()
+	defer mu.Unlock()
+	unknownHandler = h
+}
+
+// UnknownScopesError is returned by Validate when some scopes aren't known to
+// be accepted by the provider.
+type UnknownScopesError struct {
+	Provider string
+	Scopes   []string
+}
+
+func (e *UnknownScopesError) Error() string {
+	return fmt.Sprintf("scopes: unknown %s scopes: %s", e.Provider, strings.Join(e.Scopes, ", "))
+}
+
+// Validate returns an *UnknownScopesError if some of scopes aren't known to be
+// accepted by the named provider. Providers without a list of scopes accept
+// any of them.
+func Validate(provider string, scopes ...string) error {
+	if unknown := unknownScopes(provider, scopes); len(unknown) > 0 {
+		return &UnknownScopesError{Provider: provider, Scopes: unknown}
+	}
+	return nil
+}
+
+// For returns scopes without duplicates, to pass to the constructor of the
+// named provider, calling the handler set with OnUnknownScope for those the
+// provider isn't known to accept. They are kept anyway, as the list of known
+// scopes may be behind the provider.
+func For(provider string, scopes ...string) []string {
+	mu.RLock()
+	h := unknownHandler
+	mu.RUnlock()
+	if h != nil {
+		for _, s := range unknownScopes(provider, scopes) {
+			h(provider, s)
+		}
+	}
+
+	result := make([]string, 0, len(scopes))
+	seen := map[string]bool{}
+	for _, s := range scopes {
+		if !seen[s] {
+			seen[s] = true
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// Discord returns the scopes for the discord provider, see For.
+func Discord(scopes ...string) []string {
+	return For("discord", scopes...)
+}
+
+// GitHub returns the scopes for the github provider, see For.
+func GitHub(scopes ...string) []string {
+	return For("github", scopes...)
+}
+
+// Spotify returns the scopes for the spotify provider, see For.
+func Spotify(scopes ...string) []string {
+	return For("spotify", scopes...)
+}
+
+func unknownScopes(provider string, scopes []string) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	allowed := known[provider]
+	if allowed == nil {
+		return nil
+	}
+	var unknown []string
+	for _, s := range scopes {
+		if !allowed[s] {
+			unknown = append(unknown, s)
+		}
+	}
+	return unknown
+}
@@ -0,0 +1,56 @@
+package scopes_test
+
+import (
+	"testing"
+
+	"github.com/markbates/goth/providers/discord"
+	"github.com/markbates/goth/providers/spotify"
+	"github.com/markbates/goth/scopes"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Validate(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.NoError(scopes.Validate("discord", discord.ScopeIdentify, discord.ScopeEmail))
+	a.NoError(scopes.Validate("github", "read:user", "user:email"))
+	a.NoError(scopes.Validate("spotify", spotify.ScopeUserReadEmail))
+	a.NoError(scopes.Validate("unlisted", "anything"))
+
+	err := scopes.Validate("discord", "identify", "emial", "guild")
+	var unknown *scopes.UnknownScopesError
+	a.ErrorAs(err, &unknown)
+	a.Equal("discord", unknown.Provider)
+	a.Equal([]string{"emial", "guild"}, unknown.Scopes)
+	a.Equal("scopes: unknown discord scopes: emial, guild", err.Error())
+}
+
+func Test_Register(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	a.Nil(scopes.Known("example"))
+	scopes.Register("example", "write", "read")
+	a.Equal([]string{"read", "write"}, scopes.Known("example"))
+	a.Error(scopes.Validate("example", "admin"))
+}
+
+func Test_For(t *testing.T) {
+	a := assert.New(t)
+
+	var warned []string
+	scopes.OnUnknownScope(func(provider, scope string) {
+		warned = append(warned, provider+":"+scope)
+	})
+	defer scopes.OnUnknownScope(nil)
+
+	s := scopes.Discord(discord.ScopeIdentify, "emial", discord.ScopeIdentify)
+	a.Equal([]string{"identify", "emial"}, s)
+	a.Equal([]string{"discord:emial"}, warned)
+
+	warned = nil
+	a.Equal([]string{"read:user"}, scopes.GitHub("read:user"))
+	a.Equal([]string{"user-top-read"}, scopes.Spotify(spotify.ScopeUserTopRead))
+	a.Empty(warned)
+}
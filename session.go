@@ -1,5 +1,7 @@
 package goth
 
+import "golang.org/x/oauth2"
+
 // Params is used to pass data to sessions for authorization. An existing
 // implementation, and the one most likely to be used, is `url.Values`.
 type Params interface {
@@ -19,3 +21,67 @@ type Session interface {
 	// that can be stored for later access to the provider.
 	Authorize(Provider, Params) (string, error)
 }
+
+// OAuth1Session is implemented by the sessions of OAuth1 providers, e.g.
+// Twitter or Tumblr, whose tokens come with a secret and which don't use the
+// state parameter. It exposes the tokens under common names, whatever the
+// fields of the session holding them, so that gothic, custom storages and
+// adapters can handle these sessions generically.
+type OAuth1Session interface {
+	Session
+	// GetRequestToken returns the request token obtained by BeginAuth and
+	// its secret, or empty strings.
+	GetRequestToken() (token, secret string)
+	// GetAccessToken returns the access token obtained by Authorize and its
+	// secret, or empty strings.
+	GetAccessToken() (token, secret string)
+}
+
+// TokenSession is implemented by sessions holding an OAuth2 token, which lets
+// the token be refreshed without going through the authorization again.
+type TokenSession interface {
+	Session
+	// Token returns the access token, refresh token and expiry held by the session.
+	Token() *oauth2.Token
+	// SetToken replaces the token held by the session, e.g. after a refresh.
+	// The refresh token is kept if token doesn't carry a new one.
+	SetToken(token *oauth2.Token)
+}
+
+// TokenExtrasKey is the key of User.RawData holding the extra values the
+// token endpoint of the provider returned along with the access token, e.g.
+// the granted scopes or the ID of the account, as a map[string]interface{}.
+// Only the providers whose token responses carry values found nowhere else
+// fill it, with the fields they name: github, salesforce, slack and stripe.
+const TokenExtrasKey = "token_extras"
+
+// TokenExtras returns the values of the extra fields named keys of the token
+// endpoint response token came from, leaving out the missing ones, or nil if
+// none is present. The oauth2 package doesn't tell which extra fields a
+// response has, so providers name those worth keeping.
+func TokenExtras(token *oauth2.Token, keys ...string) map[string]interface{} {
+	var extras map[string]interface{}
+	for _, k := range keys {
+		v := token.Extra(k)
+		if v == nil {
+			continue
+		}
+		if extras == nil {
+			extras = map[string]interface{}{}
+		}
+		extras[k] = v
+	}
+	return extras
+}
+
+// SetTokenExtras stores extras in user.RawData under TokenExtrasKey, unless
+// there are none.
+func SetTokenExtras(user *User, extras map[string]interface{}) {
+	if len(extras) == 0 {
+		return
+	}
+	if user.RawData == nil {
+		user.RawData = map[string]interface{}{}
+	}
+	user.RawData[TokenExtrasKey] = extras
+}
@@ -0,0 +1,28 @@
+package goth_test
+
+import (
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_TokenExtras(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	token := (&oauth2.Token{AccessToken: "token"}).WithExtra(map[string]interface{}{
+		"team_id": "T123",
+		"scope":   "identify",
+	})
+	extras := goth.TokenExtras(token, "team_id", "scope", "missing")
+	a.Equal(map[string]interface{}{"team_id": "T123", "scope": "identify"}, extras)
+	a.Nil(goth.TokenExtras(token, "missing"))
+
+	user := goth.User{}
+	goth.SetTokenExtras(&user, nil)
+	a.Nil(user.RawData)
+	goth.SetTokenExtras(&user, extras)
+	a.Equal(extras, user.RawData["token_extras"])
+}
@@ -0,0 +1,149 @@
+package goth
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrInvalidState is returned by StateStore.Validate and StateStore.Consume
+// when the state wasn't generated by the store for the provider, expired or
+// was already consumed.
+var ErrInvalidState = errors.New("goth: unknown, expired or already used state")
+
+// StateStore generates the state of the authentication processes and keeps
+// track of them on the server, so that every state can only be used once and
+// only for a while, rather than trusting any state echoed back by the
+// callback that matches the session. gothic uses the store assigned to
+// gothic.StateStore. MemoryStateStore is an in-memory implementation; others
+// would keep the states in a database, with their expiry.
+type StateStore interface {
+	// Generate returns a new unguessable state for an authentication
+	// process with provider, and records it.
+	Generate(ctx context.Context, provider string) (string, error)
+	// Validate returns ErrInvalidState unless state was generated for
+	// provider, hasn't expired and wasn't consumed.
+	Validate(ctx context.Context, provider, state string) error
+	// Consume invalidates state. It returns ErrInvalidState if state was
+	// already consumed, so that only one of several concurrent callbacks
+	// with the same state succeeds.
+	Consume(ctx context.Context, provider, state string) error
+}
+
+// DefaultStateLifetime is the lifetime of the states of a MemoryStateStore
+// created with a lifetime of zero.
+const DefaultStateLifetime = 10 * time.Minute
+
+// DefaultMaxStates is the number of states a MemoryStateStore created with a
+// size of zero holds.
+const DefaultMaxStates = 100000
+
+// MemoryStateStore is a StateStore keeping the states in memory, for tests
+// and single process applications, as the callback has to reach the process
+// that generated the state. It is safe for concurrent use.
+//
+// Anyone can start authentication processes, so the number of states held is
+// bounded: once it is reached, generating a state drops the oldest one, whose
+// user has to start over. The expired states are dropped as new ones are
+// generated.
+type MemoryStateStore struct {
+	lifetime time.Duration
+	size     int
+
+	mu     sync.Mutex
+	states map[string]*list.Element
+	// order holds the states oldest first, which is the order they expire in
+	order *list.List
+}
+
+type memoryState struct {
+	state    string
+	provider string
+	expires  time.Time
+}
+
+// NewMemoryStateStore returns an empty MemoryStateStore whose states expire
+// after lifetime, or DefaultStateLifetime if lifetime is zero, holding up to
+// size states, or DefaultMaxStates if size is zero.
+func NewMemoryStateStore(lifetime time.Duration, size int) *MemoryStateStore {
+	if lifetime == 0 {
+		lifetime = DefaultStateLifetime
+	}
+	if size < 1 {
+		size = DefaultMaxStates
+	}
+	return &MemoryStateStore{
+		lifetime: lifetime,
+		size:     size,
+		states:   map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// Generate implements StateStore.
+func (s *MemoryStateStore) Generate(_ context.Context, provider string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	state := base64.RawURLEncoding.EncodeToString(b)
+
+	now := Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for el := s.order.Front(); el != nil && !now.Before(el.Value.(*memoryState).expires); el = s.order.Front() {
+		s.remove(el)
+	}
+	for s.order.Len() >= s.size {
+		s.remove(s.order.Front())
+	}
+	s.states[state] = s.order.PushBack(&memoryState{state: state, provider: provider, expires: now.Add(s.lifetime)})
+	return state, nil
+}
+
+// Len returns the number of states held, including expired ones not dropped
+// yet.
+func (s *MemoryStateStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+// Validate implements StateStore.
+func (s *MemoryStateStore) Validate(_ context.Context, provider, state string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.check(provider, state)
+}
+
+// Consume implements StateStore.
+func (s *MemoryStateStore) Consume(_ context.Context, provider, state string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.check(provider, state); err != nil {
+		return err
+	}
+	s.remove(s.states[state])
+	return nil
+}
+
+func (s *MemoryStateStore) check(provider, state string) error {
+	el, ok := s.states[state]
+	if !ok {
+		return ErrInvalidState
+	}
+	v := el.Value.(*memoryState)
+	if v.provider != provider || !Now().Before(v.expires) {
+		return ErrInvalidState
+	}
+	return nil
+}
+
+func (s *MemoryStateStore) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.states, el.Value.(*memoryState).state)
+}
@@ -0,0 +1,68 @@
+package goth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MemoryStateStore(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	goth.SetClock(func() time.Time { return now })
+	defer goth.SetClock(nil)
+
+	ctx := context.Background()
+	s := goth.NewMemoryStateStore(time.Minute, 0)
+
+	state, err := s.Generate(ctx, "github")
+	a.NoError(err)
+	other, err := s.Generate(ctx, "github")
+	a.NoError(err)
+	a.NotEqual(state, other)
+
+	a.NoError(s.Validate(ctx, "github", state))
+	a.ErrorIs(s.Validate(ctx, "gitlab", state), goth.ErrInvalidState)
+	a.ErrorIs(s.Validate(ctx, "github", "forged"), goth.ErrInvalidState)
+
+	a.NoError(s.Consume(ctx, "github", state))
+	a.ErrorIs(s.Validate(ctx, "github", state), goth.ErrInvalidState)
+	a.ErrorIs(s.Consume(ctx, "github", state), goth.ErrInvalidState)
+
+	now = now.Add(time.Minute)
+	a.ErrorIs(s.Validate(ctx, "github", other), goth.ErrInvalidState)
+}
+
+func Test_MemoryStateStoreIsBounded(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	goth.SetClock(func() time.Time { return now })
+	defer goth.SetClock(nil)
+
+	ctx := context.Background()
+	s := goth.NewMemoryStateStore(time.Minute, 2)
+
+	first, err := s.Generate(ctx, "github")
+	a.NoError(err)
+	second, err := s.Generate(ctx, "github")
+	a.NoError(err)
+	third, err := s.Generate(ctx, "github")
+	a.NoError(err)
+	a.Equal(2, s.Len())
+
+	// the oldest state made room for the new one
+	a.ErrorIs(s.Validate(ctx, "github", first), goth.ErrInvalidState)
+	a.NoError(s.Validate(ctx, "github", second))
+	a.NoError(s.Validate(ctx, "github", third))
+
+	// the expired states are dropped when the next one is generated
+	now = now.Add(time.Minute)
+	_, err = s.Generate(ctx, "github")
+	a.NoError(err)
+	a.Equal(1, s.Len())
+}
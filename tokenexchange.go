@@ -0,0 +1,120 @@
+package goth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// GrantTypeTokenExchange is the grant type of the token exchange of RFC 8693.
+const GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+// Types of the tokens exchanged with TokenExchanger.
+// See https://www.rfc-editor.org/rfc/rfc8693#section-3
+const (
+	TokenTypeAccessToken  = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeRefreshToken = "urn:ietf:params:oauth:token-type:refresh_token"
+	TokenTypeIDToken      = "urn:ietf:params:oauth:token-type:id_token"
+	TokenTypeJWT          = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// TokenExchangeRequest is a token exchange request of RFC 8693, trading the
+// token of a subject, and possibly of an actor acting on their behalf, for a
+// new token. See https://www.rfc-editor.org/rfc/rfc8693#section-2.1
+type TokenExchangeRequest struct {
+	// SubjectToken is the token of the party the new token is issued for.
+	// Some identity providers let privileged clients omit it when
+	// RequestedSubject is set.
+	SubjectToken string
+	// SubjectTokenType is the type of SubjectToken, TokenTypeAccessToken if
+	// empty.
+	SubjectTokenType string
+	// ActorToken and ActorTokenType identify the party acting on behalf of
+	// the subject, e.g. the administrator impersonating a user, when the
+	// new token has to record it in its act claim.
+	ActorToken     string
+	ActorTokenType string
+	// RequestedTokenType is the type of the token wanted, left to the
+	// identity provider if empty.
+	RequestedTokenType string
+	// Audience and Resource are where the new token is going to be used.
+	Audience []string
+	Resource []string
+	Scopes   []string
+	// RequestedSubject is the ID or username of the user to impersonate, for
+	// the identity providers supporting it, e.g. Keycloak.
+	RequestedSubject string
+	// Params are added to the request, e.g. parameters specific to an
+	// identity provider.
+	Params url.Values
+}
+
+// TokenExchanger is implemented by the providers supporting the token
+// exchange of RFC 8693, e.g. so that administrators can get a token of a user
+// to act as them from support tooling, when the identity provider allows it:
+//
+//	token, err := provider.(goth.TokenExchanger).ExchangeToken(ctx, goth.TokenExchangeRequest{
+//		SubjectToken:     adminAccessToken,
+//		RequestedSubject: "homer",
+//	})
+type TokenExchanger interface {
+	ExchangeToken(ctx context.Context, r TokenExchangeRequest) (*oauth2.Token, error)
+}
+
+// ExchangeToken makes the token exchange request r to the token endpoint of
+// config, authenticating as its client, with client. Providers implement
+// TokenExchanger with it. The issued_token_type of the response is available
+// through the Extra method of the token. Errors of the identity provider are
+// *oauth2.RetrieveError.
+func ExchangeToken(ctx context.Context, client *http.Client, config *oauth2.Config, r TokenExchangeRequest) (*oauth2.Token, error) {
+	if r.SubjectToken == "" && r.RequestedSubject == "" {
+		return nil, errors.New("goth: a token exchange requires a subject token or a requested subject")
+	}
+
+	params := url.Values{"grant_type": {GrantTypeTokenExchange}}
+	for k, v := range r.Params {
+		params[k] = v
+	}
+	if r.SubjectToken != "" {
+		params.Set("subject_token", r.SubjectToken)
+		params.Set("subject_token_type", r.SubjectTokenType)
+		if r.SubjectTokenType == "" {
+			params.Set("subject_token_type", TokenTypeAccessToken)
+		}
+	}
+	if r.ActorToken != "" {
+		params.Set("actor_token", r.ActorToken)
+		params.Set("actor_token_type", r.ActorTokenType)
+		if r.ActorTokenType == "" {
+			params.Set("actor_token_type", TokenTypeAccessToken)
+		}
+	}
+	if r.RequestedTokenType != "" {
+		params.Set("requested_token_type", r.RequestedTokenType)
+	}
+	if r.RequestedSubject != "" {
+		params.Set("requested_subject", r.RequestedSubject)
+	}
+	if len(r.Audience) > 0 {
+		params["audience"] = r.Audience
+	}
+	if len(r.Resource) > 0 {
+		params["resource"] = r.Resource
+	}
+
+	// the client credentials grant lets grant_type be overridden, and takes
+	// care of the client authentication and of the errors
+	c := &clientcredentials.Config{
+		ClientID:       config.ClientID,
+		ClientSecret:   config.ClientSecret,
+		TokenURL:       config.Endpoint.TokenURL,
+		Scopes:         r.Scopes,
+		EndpointParams: params,
+		AuthStyle:      config.Endpoint.AuthStyle,
+	}
+	return c.Token(ContextWithClient(ctx, client))
+}
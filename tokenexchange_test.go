@@ -0,0 +1,76 @@
+package goth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_ExchangeToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		a.Equal("admin-tool", id)
+		a.Equal("secret", secret)
+		a.NoError(r.ParseForm())
+		form = r.PostForm
+
+		w.Header().Set("Content-Type", "application/json")
+		if form.Get("requested_subject") == "nobody" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "access_denied"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":      "impersonated",
+			"issued_token_type": goth.TokenTypeAccessToken,
+			"token_type":        "Bearer",
+			"expires_in":        300,
+		})
+	}))
+	defer server.Close()
+
+	config := &oauth2.Config{
+		ClientID:     "admin-tool",
+		ClientSecret: "secret",
+		Endpoint:     oauth2.Endpoint{TokenURL: server.URL, AuthStyle: oauth2.AuthStyleInHeader},
+	}
+	ctx := context.Background()
+
+	token, err := goth.ExchangeToken(ctx, server.Client(), config, goth.TokenExchangeRequest{
+		SubjectToken:     "admin-token",
+		RequestedSubject: "homer",
+		Audience:         []string{"support-app"},
+		Scopes:           []string{"openid", "profile"},
+	})
+	a.NoError(err)
+	a.Equal("impersonated", token.AccessToken)
+	a.Equal(goth.TokenTypeAccessToken, token.Extra("issued_token_type"))
+	a.False(token.Expiry.IsZero())
+	a.Equal(url.Values{
+		"grant_type":         {goth.GrantTypeTokenExchange},
+		"subject_token":      {"admin-token"},
+		"subject_token_type": {goth.TokenTypeAccessToken},
+		"requested_subject":  {"homer"},
+		"audience":           {"support-app"},
+		"scope":              {"openid profile"},
+	}, form)
+
+	_, err = goth.ExchangeToken(ctx, server.Client(), config, goth.TokenExchangeRequest{RequestedSubject: "nobody"})
+	var re *oauth2.RetrieveError
+	a.ErrorAs(err, &re)
+	a.Equal("access_denied", re.ErrorCode)
+
+	_, err = goth.ExchangeToken(ctx, server.Client(), config, goth.TokenExchangeRequest{})
+	a.Error(err)
+}
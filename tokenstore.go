@@ -0,0 +1,87 @@
+package goth
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrTokenNotFound is returned by TokenStore.Load when no token is stored for
+// the user.
+var ErrTokenNotFound = errors.New("goth: no token stored for the user")
+
+// TokenStore persists the tokens of users, per provider, so that applications
+// can keep calling APIs on their behalf, refreshing the tokens as needed,
+// after they signed in. gothic saves the tokens in the store assigned to
+// gothic.TokenStore. MemoryTokenStore is an in-memory implementation; others
+// would keep the tokens in a database, preferably encrypted.
+type TokenStore interface {
+	// Save stores token as the token of the user userID of provider,
+	// replacing any previous one.
+	Save(ctx context.Context, provider, userID string, token *oauth2.Token) error
+	// Load returns the token of the user userID of provider, or
+	// ErrTokenNotFound.
+	Load(ctx context.Context, provider, userID string) (*oauth2.Token, error)
+	// Delete removes the token of the user userID of provider, if any.
+	Delete(ctx context.Context, provider, userID string) error
+}
+
+// TokenFromUser returns the token of user, as handed out by the provider
+// which authenticated them. Its id_token, if any, is kept as an extra.
+func TokenFromUser(user User) *oauth2.Token {
+	token := &oauth2.Token{
+		AccessToken:  user.AccessToken,
+		RefreshToken: user.RefreshToken,
+		Expiry:       user.ExpiresAt,
+	}
+	if user.IDToken != "" {
+		token = token.WithExtra(map[string]interface{}{"id_token": user.IDToken})
+	}
+	return token
+}
+
+// MemoryTokenStore is a TokenStore keeping the tokens in memory, mostly for
+// tests and single process applications, as the tokens are lost when the
+// process exits. It is safe for concurrent use.
+type MemoryTokenStore struct {
+	mu     sync.RWMutex
+	tokens map[[2]string]oauth2.Token
+}
+
+// NewMemoryTokenStore returns an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: map[[2]string]oauth2.Token{}}
+}
+
+// Save implements TokenStore.
+func (s *MemoryTokenStore) Save(_ context.Context, provider, userID string, token *oauth2.Token) error {
+	if token == nil {
+		return errors.New("goth: cannot save a nil token")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[[2]string{provider, userID}] = *token
+	return nil
+}
+
+// Load implements TokenStore. The token returned is a copy, which can be
+// changed without affecting the stored one.
+func (s *MemoryTokenStore) Load(_ context.Context, provider, userID string) (*oauth2.Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, ok := s.tokens[[2]string{provider, userID}]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+	return &token, nil
+}
+
+// Delete implements TokenStore.
+func (s *MemoryTokenStore) Delete(_ context.Context, provider, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, [2]string{provider, userID})
+	return nil
+}
@@ -0,0 +1,52 @@
+package goth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func Test_MemoryTokenStore(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ctx := context.Background()
+	s := goth.NewMemoryTokenStore()
+	_, err := s.Load(ctx, "github", "1")
+	a.ErrorIs(err, goth.ErrTokenNotFound)
+
+	a.NoError(s.Save(ctx, "github", "1", &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}))
+	a.NoError(s.Save(ctx, "gitlab", "1", &oauth2.Token{AccessToken: "other"}))
+
+	token, err := s.Load(ctx, "github", "1")
+	a.NoError(err)
+	a.Equal("access", token.AccessToken)
+	a.Equal("refresh", token.RefreshToken)
+
+	token.AccessToken = "changed"
+	token, err = s.Load(ctx, "github", "1")
+	a.NoError(err)
+	a.Equal("access", token.AccessToken, "the stored token must not be shared")
+
+	a.NoError(s.Delete(ctx, "github", "1"))
+	_, err = s.Load(ctx, "github", "1")
+	a.ErrorIs(err, goth.ErrTokenNotFound)
+	_, err = s.Load(ctx, "gitlab", "1")
+	a.NoError(err)
+}
+
+func Test_TokenFromUser(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	expiry := time.Now().Add(time.Hour)
+	token := goth.TokenFromUser(goth.User{AccessToken: "access", RefreshToken: "refresh", ExpiresAt: expiry, IDToken: "id"})
+	a.Equal("access", token.AccessToken)
+	a.Equal("refresh", token.RefreshToken)
+	a.Equal(expiry, token.Expiry)
+	a.Equal("id", token.Extra("id_token"))
+}
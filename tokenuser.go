@@ -0,0 +1,60 @@
+package goth
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrFetchUserWithTokenNotSupported is returned by UserFromAccessToken for
+// providers that don't implement TokenUserFetcher.
+var ErrFetchUserWithTokenNotSupported = errors.New("the provider cannot fetch a user from an access token")
+
+// ErrTokenAudience is wrapped by the error of FetchUserWithToken when the
+// access token wasn't issued to the client of the provider. Such a token must
+// not be used to sign the user in: any application the user signed in to with
+// the same identity provider could replay it to impersonate them.
+var ErrTokenAudience = errors.New("the access token was issued for another application")
+
+// TokenUserFetcher is implemented by providers able to fetch a user from an
+// access token obtained outside of Goth, e.g. by a mobile app completing the
+// authorization on the device and sending the token to its API.
+//
+// Implementations must check with the identity provider that the token was
+// issued to their client, and return an error wrapping ErrTokenAudience if it
+// wasn't. Providers offering no way to check it must not implement the
+// interface.
+type TokenUserFetcher interface {
+	FetchUserWithToken(ctx context.Context, accessToken string) (User, error)
+}
+
+// UserFromAccessToken fetches the user accessToken was issued for from
+// provider, or returns ErrFetchUserWithTokenNotSupported if the provider
+// can't fetch users from a bare access token. Like FetchUser, it returns the
+// cached user if a cache is set with SetUserCache.
+//
+// A user is returned only if the provider accepts the token and confirms it
+// was issued to the client of provider; tokens of other applications fail
+// with an error wrapping ErrTokenAudience.
+func UserFromAccessToken(ctx context.Context, provider Provider, accessToken string) (User, error) {
+	p, ok := provider.(TokenUserFetcher)
+	if !ok {
+		return User{}, ErrFetchUserWithTokenNotSupported
+	}
+
+	cache, ttl := currentUserCache()
+	if cache == nil {
+		return p.FetchUserWithToken(ctx, accessToken)
+	}
+
+	key := UserCacheKey(provider.Name(), accessToken)
+	if user, ok := cache.Get(key); ok {
+		return user, nil
+	}
+
+	user, err := p.FetchUserWithToken(ctx, accessToken)
+	if err != nil {
+		return user, err
+	}
+	cache.Set(key, user, ttl)
+	return user, nil
+}
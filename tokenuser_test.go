@@ -0,0 +1,29 @@
+package goth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UserFromAccessToken(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	user, err := goth.UserFromAccessToken(context.Background(), &faux.Provider{}, "token")
+	a.NoError(err)
+	a.Equal("token", user.UserID)
+	a.Equal("faux", user.Provider)
+
+	_, err = goth.UserFromAccessToken(context.Background(), &faux.Provider{}, "invalid")
+	a.Error(err)
+
+	_, err = goth.UserFromAccessToken(context.Background(), &faux.Provider{}, "foreign")
+	a.ErrorIs(err, goth.ErrTokenAudience)
+
+	_, err = goth.UserFromAccessToken(context.Background(), noParamsProvider{&faux.Provider{}}, "token")
+	a.ErrorIs(err, goth.ErrFetchUserWithTokenNotSupported)
+}
@@ -0,0 +1,162 @@
+package goth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultClient is the HTTP client used by every provider whose HTTPClient
+// isn't set. Replace it, e.g. with a client using a Transport, to apply one
+// policy to all registered providers:
+//
+//	goth.DefaultClient = &http.Client{Transport: &goth.Transport{MaxRetries: 2}}
+var DefaultClient = http.DefaultClient
+
+// Transport is an http.RoundTripper retrying requests to identity providers
+// that fail with a network error or a transient status (429, 502, 503, 504),
+// with an exponential backoff in between. Only requests with an idempotent
+// method are retried, so that e.g. an authorization code is never exchanged
+// twice.
+type Transport struct {
+	// Base is the RoundTripper making the requests. http.DefaultTransport is
+	// used if nil.
+	Base http.RoundTripper
+	// MaxRetries is the number of times a failed request is retried.
+	MaxRetries int
+	// Backoff is the wait before the first retry, which doubles with every
+	// further retry up to MaxBackoff. It defaults to 100ms.
+	Backoff time.Duration
+	// MaxBackoff caps the wait between retries, including the one asked for
+	// by a Retry-After header. It defaults to 5s.
+	MaxBackoff time.Duration
+	// Timeout, if not zero, limits the time each attempt may take, including
+	// reading the response body.
+	Timeout time.Duration
+}
+
+const (
+	defaultTransportBackoff    = 100 * time.Millisecond
+	defaultTransportMaxBackoff = 5 * time.Second
+)
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := t.MaxRetries
+	if !idempotent(req) {
+		retries = 0
+	}
+
+	backoff := t.Backoff
+	if backoff <= 0 {
+		backoff = defaultTransportBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := t.attempt(req, attempt)
+		if attempt >= retries || !retryable(res, err) || req.Context().Err() != nil {
+			return res, err
+		}
+
+		wait := t.wait(backoff, res)
+		if res != nil {
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (t *Transport) attempt(req *http.Request, attempt int) (*http.Response, error) {
+	r := req
+	if attempt > 0 && req.Body != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r = req.Clone(req.Context())
+		r.Body = body
+	}
+
+	var cancel context.CancelFunc
+	if t.Timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), t.Timeout)
+		r = r.WithContext(ctx)
+	}
+
+	res, err := t.base().RoundTrip(r)
+	if cancel != nil {
+		if err != nil {
+			cancel()
+		} else {
+			res.Body = &cancelBody{ReadCloser: res.Body, cancel: cancel}
+		}
+	}
+	return res, err
+}
+
+// wait returns how long to wait before the next attempt, honoring the
+// Retry-After and rate limit headers of the response.
+func (t *Transport) wait(backoff time.Duration, res *http.Response) time.Duration {
+	max := t.MaxBackoff
+	if max <= 0 {
+		max = defaultTransportMaxBackoff
+	}
+	if res != nil {
+		if wait, ok := rateLimitWait(res.Header, time.Now()); ok {
+			backoff = wait
+		}
+	}
+	if backoff > max {
+		return max
+	}
+	return backoff
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return req.Body == nil || req.GetBody != nil
+	}
+	return false
+}
+
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// cancelBody releases the context of an attempt once its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,91 @@
+package goth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Transport_Retry(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: &goth.Transport{MaxRetries: 2, Backoff: time.Millisecond}}
+	res, err := client.Get(ts.URL)
+	a.NoError(err)
+	defer res.Body.Close()
+	a.Equal(http.StatusOK, res.StatusCode)
+	a.Equal(int32(3), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	client = &http.Client{Transport: &goth.Transport{MaxRetries: 1, Backoff: time.Millisecond}}
+	res, err = client.Get(ts.URL)
+	a.NoError(err)
+	res.Body.Close()
+	a.Equal(http.StatusBadGateway, res.StatusCode)
+	a.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func Test_Transport_NoRetryForPost(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: &goth.Transport{MaxRetries: 3, Backoff: time.Millisecond}}
+	res, err := client.Post(ts.URL, "application/x-www-form-urlencoded", strings.NewReader("code=abc"))
+	a.NoError(err)
+	res.Body.Close()
+	a.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func Test_Transport_Timeout(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	client := &http.Client{Transport: &goth.Transport{Timeout: 10 * time.Millisecond}}
+	_, err := client.Get(ts.URL)
+	a.ErrorIs(err, context.DeadlineExceeded)
+}
+
+func Test_HTTPClientWithFallBack(t *testing.T) {
+	a := assert.New(t)
+
+	client := &http.Client{Transport: &goth.Transport{}}
+	a.Equal(client, goth.HTTPClientWithFallBack(client))
+	a.Equal(http.DefaultClient, goth.HTTPClientWithFallBack(nil))
+
+	goth.DefaultClient = client
+	defer func() { goth.DefaultClient = http.DefaultClient }()
+	a.Equal(client, goth.HTTPClientWithFallBack(nil))
+}
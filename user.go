@@ -9,6 +9,16 @@ func init() {
 	gob.Register(User{})
 }
 
+// Sizes of the avatars in User.AvatarURLs. Small avatars are about 48 pixels
+// wide, medium ones about 200 and large ones 512 or more, while the original
+// is the image as the user uploaded it.
+const (
+	AvatarSmall    = "small"
+	AvatarMedium   = "medium"
+	AvatarLarge    = "large"
+	AvatarOriginal = "original"
+)
+
 // User contains the information common amongst most OAuth and OAuth2 providers.
 // All the "raw" data from the provider can be found in the `RawData` field.
 type User struct {
@@ -28,4 +38,59 @@ type User struct {
 	RefreshToken      string
 	ExpiresAt         time.Time
 	IDToken           string
+	// Groups and Roles hold the group and role memberships of the user for
+	// the providers exposing them:
+	//
+	//   - okta: the "groups" claim, or the Okta API if an API token is set
+	//   - entraid: the "groups" and "roles" claims of the id_token, or the
+	//     Graph API when expansion is enabled
+	//   - cognito: the "cognito:groups" claim of the access token
+	//   - openidConnect: the claims listed in GroupsClaims and RolesClaims
+	//   - jellyfin: "administrator" for the administrators of the server
+	//   - github: the role in the organization and the teams required with
+	//     RequireMembership
+	Groups []string
+	Roles  []string
+	// AvatarURLs holds the URLs of the avatar of the user at the sizes the
+	// provider offers, keyed by AvatarSmall, AvatarMedium, AvatarLarge and
+	// AvatarOriginal, for the slack, discord, twitch, github and google
+	// providers. AvatarURL remains the default size of the provider. It is
+	// nil for providers offering a single size.
+	AvatarURLs map[string]string
+}
+
+// NewAvatarURLs returns the AvatarURLs of a user from the URLs of the avatar
+// at each size, leaving out the empty ones. It returns nil if all are empty.
+func NewAvatarURLs(small, medium, large, original string) map[string]string {
+	urls := map[string]string{}
+	for size, url := range map[string]string{
+		AvatarSmall:    small,
+		AvatarMedium:   medium,
+		AvatarLarge:    large,
+		AvatarOriginal: original,
+	} {
+		if url != "" {
+			urls[size] = url
+		}
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+	return urls
+}
+
+// Avatar returns the URL of the avatar of the user at the given size, e.g.
+// AvatarSmall, or AvatarURL if the provider doesn't offer that size.
+func (u User) Avatar(size string) string {
+	if url := u.AvatarURLs[size]; url != "" {
+		return url
+	}
+	return u.AvatarURL
+}
+
+// TokenExpired reports whether the access token of the user has expired, or
+// expires within skew, e.g. to refresh it a little before it is rejected.
+// Tokens whose expiry the provider didn't tell never expire.
+func (u User) TokenExpired(skew time.Duration) bool {
+	return expired(u.ExpiresAt, skew)
 }
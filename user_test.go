@@ -1 +1,88 @@
 package goth_test
+
+import (
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UserClaims(t *testing.T) {
+	a := assert.New(t)
+
+	u := goth.User{
+		Provider: "google",
+		UserID:   "1234",
+		Email:    "homer@example.com",
+		RawData: map[string]interface{}{
+			"verified_email": true,
+			"locale":         "en",
+			"groups":         []interface{}{"admins", "users"},
+		},
+	}
+
+	c := u.Claims()
+	a.Equal("1234", c.Subject())
+	a.Equal("homer@example.com", c.Email())
+	a.True(c.EmailVerified())
+	a.Equal("en", c.Locale())
+	a.Equal([]string{"admins", "users"}, c.Groups())
+	a.Equal("", c.PhoneNumber())
+	a.Nil(c.Roles())
+}
+
+func Test_UserClaimsGroupsAndRoles(t *testing.T) {
+	a := assert.New(t)
+
+	u := goth.User{
+		Provider: "entraid",
+		Groups:   []string{"admins"},
+		Roles:    []string{"Reader"},
+	}
+	c := u.Claims()
+	a.Equal([]string{"admins"}, c.Groups())
+	a.Equal([]string{"Reader"}, c.Roles())
+
+	u = goth.User{
+		Provider: "cognito",
+		RawData:  map[string]interface{}{"cognito:groups": []interface{}{"editors"}},
+	}
+	a.Equal([]string{"editors"}, u.Claims().Groups())
+}
+
+func Test_RegisterClaimMapping(t *testing.T) {
+	a := assert.New(t)
+
+	goth.RegisterClaimMapping("custom", goth.ClaimMapping{
+		goth.ClaimPhoneNumber:   {"profile.phone"},
+		goth.ClaimEmailVerified: {"profile.email_ok"},
+	})
+
+	u := goth.User{
+		Provider: "custom",
+		RawData: map[string]interface{}{
+			"profile": map[string]interface{}{
+				"phone":    "+15555555555",
+				"email_ok": "true",
+			},
+		},
+	}
+
+	c := u.Claims()
+	a.Equal("+15555555555", c.PhoneNumber())
+	a.True(c.EmailVerified())
+}
+
+func Test_UserAvatar(t *testing.T) {
+	a := assert.New(t)
+
+	u := goth.User{
+		AvatarURL:  "https://example.com/avatar.png",
+		AvatarURLs: goth.NewAvatarURLs("https://example.com/avatar-48.png", "", "", ""),
+	}
+	a.Equal(map[string]string{goth.AvatarSmall: "https://example.com/avatar-48.png"}, u.AvatarURLs)
+	a.Equal("https://example.com/avatar-48.png", u.Avatar(goth.AvatarSmall))
+	a.Equal("https://example.com/avatar.png", u.Avatar(goth.AvatarLarge))
+
+	a.Nil(goth.NewAvatarURLs("", "", "", ""))
+}
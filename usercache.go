@@ -0,0 +1,199 @@
+package goth
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// UserCache stores the users fetched from providers, so that fetching the
+// user of the same token again doesn't call the provider. LRUUserCache is an
+// in-memory implementation; others may e.g. share the users between servers.
+type UserCache interface {
+	// Get returns the user stored under key, if it hasn't expired.
+	Get(key string) (User, bool)
+	// Set stores user under key for ttl.
+	Set(key string, user User, ttl time.Duration)
+	// Delete removes the user stored under key, if any.
+	Delete(key string)
+}
+
+var (
+	userCacheMu  sync.RWMutex
+	userCache    UserCache
+	userCacheTTL time.Duration
+)
+
+// SetUserCache makes FetchUser keep the users it fetched in cache for ttl.
+// Passing a nil cache disables the caching again.
+//
+//	goth.SetUserCache(goth.NewLRUUserCache(1000), 5*time.Minute)
+func SetUserCache(cache UserCache, ttl time.Duration) {
+	userCacheMu.Lock()
+	defer userCacheMu.Unlock()
+	userCache = cache
+	userCacheTTL = ttl
+}
+
+func currentUserCache() (UserCache, time.Duration) {
+	userCacheMu.RLock()
+	defer userCacheMu.RUnlock()
+	return userCache, userCacheTTL
+}
+
+// UserCacheKey returns the key the user of accessToken, fetched from the
+// provider named providerName, is cached under. The token is hashed so that it
+// isn't kept in the cache.
+func UserCacheKey(providerName, accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return providerName + ":" + hex.EncodeToString(sum[:])
+}
+
+// FetchUser fetches the user of session from provider like provider.FetchUser,
+// but returns the cached user if a cache is set with SetUserCache and the
+// user of the access token of session was fetched before. Sessions that don't
+// implement TokenSession are cached by their marshaled form. Only users
+// fetched successfully are cached.
+func FetchUser(provider Provider, session Session) (User, error) {
+	cache, ttl := currentUserCache()
+	if cache == nil {
+		return provider.FetchUser(session)
+	}
+
+	key := sessionCacheKey(provider.Name(), session)
+	if user, ok := cache.Get(key); ok {
+		return user, nil
+	}
+
+	user, err := provider.FetchUser(session)
+	if err != nil {
+		return user, err
+	}
+	cache.Set(key, user, ttl)
+	return user, nil
+}
+
+// InvalidateUser removes the user of accessToken, fetched from the provider
+// named providerName, from the cache set with SetUserCache, e.g. once the
+// token has been revoked or the user changed their profile.
+func InvalidateUser(providerName, accessToken string) {
+	if cache, _ := currentUserCache(); cache != nil {
+		cache.Delete(UserCacheKey(providerName, accessToken))
+	}
+}
+
+func sessionCacheKey(providerName string, session Session) string {
+	if ts, ok := session.(TokenSession); ok {
+		if token := ts.Token(); token != nil && token.AccessToken != "" {
+			return UserCacheKey(providerName, token.AccessToken)
+		}
+	}
+	return UserCacheKey(providerName, session.Marshal())
+}
+
+// LRUUserCache is an in-memory UserCache holding up to a fixed number of
+// users, evicting the least recently used ones first. It is safe for
+// concurrent use.
+type LRUUserCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type lruEntry struct {
+	key     string
+	user    User
+	expires time.Time
+}
+
+// NewLRUUserCache returns an LRUUserCache holding up to size users.
+func NewLRUUserCache(size int) *LRUUserCache {
+	if size < 1 {
+		size = 1
+	}
+	return &LRUUserCache{
+		size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// Get implements UserCache.
+func (c *LRUUserCache) Get(key string) (User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return User{}, false
+	}
+	e := el.Value.(*lruEntry)
+	if !Now().Before(e.expires) {
+		c.remove(el)
+		return User{}, false
+	}
+	c.order.MoveToFront(el)
+	return copyUser(e.user), true
+}
+
+// Set implements UserCache.
+func (c *LRUUserCache) Set(key string, user User, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &lruEntry{key: key, user: copyUser(user), expires: Now().Add(ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(e)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Delete implements UserCache.
+func (c *LRUUserCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Len returns the number of users held, including expired ones not evicted yet.
+func (c *LRUUserCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRUUserCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*lruEntry).key)
+}
+
+// copyUser returns a copy of user whose RawData and AvatarURLs can be changed
+// without affecting the cached user.
+func copyUser(user User) User {
+	if user.RawData != nil {
+		raw := make(map[string]interface{}, len(user.RawData))
+		for k, v := range user.RawData {
+			raw[k] = v
+		}
+		user.RawData = raw
+	}
+	if user.AvatarURLs != nil {
+		avatars := make(map[string]string, len(user.AvatarURLs))
+		for k, v := range user.AvatarURLs {
+			avatars[k] = v
+		}
+		user.AvatarURLs = avatars
+	}
+	return user
+}
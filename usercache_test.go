@@ -0,0 +1,99 @@
+package goth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/providers/faux"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingProvider counts the users it fetches.
+type countingProvider struct {
+	faux.Provider
+	fetched int
+}
+
+func (p *countingProvider) FetchUser(session goth.Session) (goth.User, error) {
+	p.fetched++
+	return p.Provider.FetchUser(session)
+}
+
+func Test_FetchUserCache(t *testing.T) {
+	a := assert.New(t)
+
+	p := &countingProvider{}
+	sess := &faux.Session{ID: "id", Name: "Homer Simpson", AccessToken: "token"}
+
+	// no cache is used by default
+	_, err := goth.FetchUser(p, sess)
+	a.NoError(err)
+	_, err = goth.FetchUser(p, sess)
+	a.NoError(err)
+	a.Equal(2, p.fetched)
+
+	goth.SetUserCache(goth.NewLRUUserCache(10), time.Minute)
+	defer goth.SetUserCache(nil, 0)
+
+	p.fetched = 0
+	for i := 0; i < 3; i++ {
+		user, err := goth.FetchUser(p, sess)
+		a.NoError(err)
+		a.Equal("Homer Simpson", user.Name)
+	}
+	a.Equal(1, p.fetched)
+
+	// failures aren't cached
+	_, err = goth.FetchUser(p, &faux.Session{})
+	a.Error(err)
+	_, err = goth.FetchUser(p, &faux.Session{})
+	a.Error(err)
+	a.Equal(3, p.fetched)
+}
+
+func Test_InvalidateUser(t *testing.T) {
+	a := assert.New(t)
+
+	cache := goth.NewLRUUserCache(10)
+	goth.SetUserCache(cache, time.Minute)
+	defer goth.SetUserCache(nil, 0)
+
+	cache.Set(goth.UserCacheKey("faux", "token"), goth.User{Name: "Homer"}, time.Minute)
+	_, ok := cache.Get(goth.UserCacheKey("faux", "token"))
+	a.True(ok)
+
+	goth.InvalidateUser("faux", "token")
+	_, ok = cache.Get(goth.UserCacheKey("faux", "token"))
+	a.False(ok)
+}
+
+func Test_LRUUserCache(t *testing.T) {
+	t.Parallel()
+	a := assert.New(t)
+
+	c := goth.NewLRUUserCache(2)
+	c.Set("a", goth.User{Name: "a", RawData: map[string]interface{}{"k": "v"}}, time.Minute)
+	c.Set("b", goth.User{Name: "b"}, time.Minute)
+
+	// a becomes the most recently used, so b is evicted
+	user, ok := c.Get("a")
+	a.True(ok)
+	user.RawData["k"] = "changed"
+	c.Set("c", goth.User{Name: "c"}, time.Minute)
+	a.Equal(2, c.Len())
+	_, ok = c.Get("b")
+	a.False(ok)
+
+	user, ok = c.Get("a")
+	a.True(ok)
+	a.Equal("v", user.RawData["k"])
+
+	c.Set("expired", goth.User{}, 0)
+	_, ok = c.Get("expired")
+	a.False(ok)
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	a.False(ok)
+}
@@ -0,0 +1,71 @@
+package goth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrUserNotAllowed is returned, possibly wrapped, when a user authenticated
+// by a provider is rejected by a UserValidator or by a provider's own
+// restrictions, like google's hosted domain or entraid's allowed tenants.
+var ErrUserNotAllowed = errors.New("the user is not allowed to sign in")
+
+// UserValidator checks a user once it has been fetched from the provider,
+// returning an error to reject them. user.Provider tells which provider
+// authenticated the user.
+type UserValidator func(user User) error
+
+var (
+	userValidatorsMu sync.RWMutex
+	userValidators   []UserValidator
+)
+
+// AddUserValidator registers a validator run by ValidateUser, which gothic
+// calls before handing out a user, e.g. to only let users of a given domain
+// sign in.
+func AddUserValidator(v UserValidator) {
+	userValidatorsMu.Lock()
+	defer userValidatorsMu.Unlock()
+	userValidators = append(userValidators, v)
+}
+
+// ClearUserValidators removes all the validators registered with
+// AddUserValidator. This is useful, mostly, for testing purposes.
+func ClearUserValidators() {
+	userValidatorsMu.Lock()
+	defer userValidatorsMu.Unlock()
+	userValidators = nil
+}
+
+// ValidateUser runs the validators registered with AddUserValidator on user,
+// in the order they were registered, and returns the first error.
+func ValidateUser(user User) error {
+	userValidatorsMu.RLock()
+	defer userValidatorsMu.RUnlock()
+	for _, v := range userValidators {
+		if err := v(user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AllowEmailDomains returns a UserValidator only accepting users whose email
+// address belongs to one of domains. Keep in mind that not every provider
+// verifies the email addresses of its users; prefer provider-level checks,
+// like google's SetHostedDomain, where available.
+func AllowEmailDomains(domains ...string) UserValidator {
+	allowed := make(map[string]bool, len(domains))
+	for _, d := range domains {
+		allowed[strings.ToLower(strings.TrimPrefix(d, "@"))] = true
+	}
+	return func(user User) error {
+		at := strings.LastIndex(user.Email, "@")
+		if at >= 0 && allowed[strings.ToLower(user.Email[at+1:])] {
+			return nil
+		}
+		return fmt.Errorf("%w: %q is not in an allowed domain", ErrUserNotAllowed, user.Email)
+	}
+}
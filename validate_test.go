@@ -0,0 +1,35 @@
+package goth_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/markbates/goth"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ValidateUser(t *testing.T) {
+	a := assert.New(t)
+	defer goth.ClearUserValidators()
+
+	a.NoError(goth.ValidateUser(goth.User{Email: "homer@example.com"}))
+
+	goth.AddUserValidator(goth.AllowEmailDomains("example.com", "@Example.org"))
+	a.NoError(goth.ValidateUser(goth.User{Email: "homer@example.com"}))
+	a.NoError(goth.ValidateUser(goth.User{Email: "homer@EXAMPLE.ORG"}))
+	a.ErrorIs(goth.ValidateUser(goth.User{Email: "homer@example.com.evil.com"}), goth.ErrUserNotAllowed)
+	a.ErrorIs(goth.ValidateUser(goth.User{Email: "example.com"}), goth.ErrUserNotAllowed)
+	a.ErrorIs(goth.ValidateUser(goth.User{}), goth.ErrUserNotAllowed)
+
+	errBanned := errors.New("banned")
+	goth.AddUserValidator(func(user goth.User) error {
+		if user.UserID == "42" {
+			return errBanned
+		}
+		return nil
+	})
+	a.ErrorIs(goth.ValidateUser(goth.User{UserID: "42", Email: "homer@example.com"}), errBanned)
+
+	goth.ClearUserValidators()
+	a.NoError(goth.ValidateUser(goth.User{}))
+}